@@ -0,0 +1,14 @@
+// Copyright 2009 The Go Authors. All rights reserved.
+// Copyright 2012 The Gorilla Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build go1.8
+// +build go1.8
+
+package rpc
+
+import "net/http"
+
+// errAbortHandler is the panic value aborting an HTTP response.
+var errAbortHandler = http.ErrAbortHandler
@@ -0,0 +1,15 @@
+// Copyright 2009 The Go Authors. All rights reserved.
+// Copyright 2012 The Gorilla Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build !go1.8
+// +build !go1.8
+
+package rpc
+
+import "errors"
+
+// errAbortHandler is the panic value aborting an HTTP response. Before Go
+// 1.8, the server closes the connection on any panic, but also logs it.
+var errAbortHandler = errors.New("net/http: abort Handler")
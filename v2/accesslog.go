@@ -0,0 +1,120 @@
+// Copyright 2009 The Go Authors. All rights reserved.
+// Copyright 2012 The Gorilla Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package rpc
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// AccessLogFormat is the line format of an AccessLog.
+type AccessLogFormat int
+
+const (
+	// CommonLogFormat writes lines in the style of the Common Log Format:
+	//
+	//	127.0.0.1 alice [16/Oct/2026:10:00:00 +0000] "Users.Get application/json" 200 42 1.2ms
+	//
+	// Unknown callers are written as "-".
+	CommonLogFormat AccessLogFormat = iota
+	// JSONLogFormat writes a JSON object per line, with the fields of
+	// AccessLogEntry.
+	JSONLogFormat
+)
+
+// AccessLogEntry is a line of an access log.
+type AccessLogEntry struct {
+	Time       time.Time     `json:"time"`
+	RemoteAddr string        `json:"remoteAddr"`
+	Caller     string        `json:"caller,omitempty"`
+	Method     string        `json:"method"`
+	Codec      string        `json:"codec"`
+	Status     int           `json:"status"`
+	Bytes      int64         `json:"bytes"`
+	Duration   time.Duration `json:"duration"`
+	Error      string        `json:"error,omitempty"`
+}
+
+// AccessLog writes a line for each call served, see Server.SetAccessLog.
+type AccessLog struct {
+	// Writer receives the lines, e.g. os.Stderr. Writes are serialized.
+	Writer io.Writer
+	// Format is the format of the lines.
+	Format AccessLogFormat
+	// SampleRate is the fraction of calls logged, e.g. 0.01 to log one
+	// call in a hundred. If zero, all the calls are logged. Failed calls,
+	// with a 5xx status, are always logged.
+	SampleRate float64
+	// SlowThreshold, if set, only logs the calls taking at least that
+	// long, and the failed ones.
+	SlowThreshold time.Duration
+	// Identify returns the identity of the caller, e.g. from an API key or
+	// the session. If nil, or if it returns an empty string, the caller
+	// is unknown.
+	Identify func(r *http.Request) string
+
+	mutex sync.Mutex
+}
+
+// SetAccessLog sets the access log written for the calls served. It
+// doesn't take the AfterFunc, so both can be used. A nil log disables the
+// access log.
+func (s *Server) SetAccessLog(l *AccessLog) {
+	s.accessLog = l
+}
+
+// log writes the entry of a call if it's sampled.
+func (l *AccessLog) log(r *http.Request, method, contentType string, status int, bytes int64, start time.Time, err error) {
+	d := time.Since(start)
+	if status < 500 {
+		if d < l.SlowThreshold {
+			return
+		}
+		if l.SampleRate > 0 && l.SampleRate < 1 && rand.Float64() >= l.SampleRate {
+			return
+		}
+	}
+	e := AccessLogEntry{
+		Time:       start,
+		RemoteAddr: r.RemoteAddr,
+		Method:     method,
+		Codec:      contentType,
+		Status:     status,
+		Bytes:      bytes,
+		Duration:   d,
+	}
+	if l.Identify != nil {
+		e.Caller = l.Identify(r)
+	}
+	if err != nil {
+		e.Error = err.Error()
+	}
+	l.write(&e)
+}
+
+func (l *AccessLog) write(e *AccessLogEntry) {
+	var line []byte
+	if l.Format == JSONLogFormat {
+		line, _ = json.Marshal(e)
+		line = append(line, '\n')
+	} else {
+		caller := e.Caller
+		if caller == "" {
+			caller = "-"
+		}
+		line = []byte(fmt.Sprintf("%s %s [%s] \"%s %s\" %d %d %s\n",
+			e.RemoteAddr, caller, e.Time.Format("02/Jan/2006:15:04:05 -0700"),
+			e.Method, e.Codec, e.Status, e.Bytes, e.Duration))
+	}
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	l.Writer.Write(line)
+}
@@ -0,0 +1,75 @@
+// Copyright 2009 The Go Authors. All rights reserved.
+// Copyright 2012 The Gorilla Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package rpc
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAccessLog(t *testing.T) {
+	s := NewServer()
+	s.RegisterCodec(MockCodec{A: 1, B: 2}, "mock")
+	s.RegisterService(new(Service1), "")
+	var fail bool
+	s.RegisterMiddleware(func(next CallFunc) CallFunc {
+		return func(r *http.Request, method string, args, reply interface{}) error {
+			if fail {
+				return statusError{}
+			}
+			return next(r, method, args, reply)
+		}
+	})
+	var buf bytes.Buffer
+	log := &AccessLog{
+		Writer:   &buf,
+		Identify: func(r *http.Request) string { return "alice" },
+	}
+	s.SetAccessLog(log)
+
+	serveMock(s, "Service1.Multiply", "10.0.0.1:1234")
+	line := buf.String()
+	if !strings.HasPrefix(line, "10.0.0.1:1234 alice [") || !strings.Contains(line, `] "Service1.Multiply mock" 200 1 `) {
+		t.Errorf("Wrong common log line %q", line)
+	}
+
+	buf.Reset()
+	log.Format = JSONLogFormat
+	fail = true
+	serveMock(s, "Service1.Multiply", "10.0.0.1:1234")
+	var e AccessLogEntry
+	if err := json.Unmarshal(buf.Bytes(), &e); err != nil {
+		t.Fatal(err)
+	}
+	if e.Method != "Service1.Multiply" || e.Codec != "mock" || e.Caller != "alice" || e.Status != 503 || e.Error == "" {
+		t.Errorf("Wrong JSON log entry %+v", e)
+	}
+
+	// Successful fast calls are skipped in slow-only mode, failed calls
+	// are always logged.
+	buf.Reset()
+	log.SlowThreshold = time.Hour
+	serveMock(s, "Service1.Multiply", "")
+	fail = false
+	serveMock(s, "Service1.Multiply", "")
+	if n := strings.Count(buf.String(), "\n"); n != 1 {
+		t.Errorf("Expected only the failed call to be logged, got %q", buf.String())
+	}
+
+	buf.Reset()
+	log.SlowThreshold = 0
+	log.SampleRate = 0.5
+	for i := 0; i < 1000; i++ {
+		serveMock(s, "Service1.Multiply", "")
+	}
+	if n := strings.Count(buf.String(), "\n"); n < 350 || n > 650 {
+		t.Errorf("Expected about half of the calls to be logged, got %d", n)
+	}
+}
@@ -0,0 +1,193 @@
+// Copyright 2009 The Go Authors. All rights reserved.
+// Copyright 2012 The Gorilla Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package rpc
+
+import (
+	"context"
+	"net/http"
+	"time"
+)
+
+// AdminService exposes methods to manage a running server. It must be
+// registered on another server, e.g. an admin server listening on a
+// private port, so that it's still served while the server drains:
+//
+//	admin := rpc.NewServer()
+//	admin.RegisterCodec(json2.NewCodec(), "application/json")
+//	admin.RegisterService(&rpc.AdminService{Server: s, Authorize: checkAdminToken}, "Admin")
+//	go http.ListenAndServe("127.0.0.1:9090", admin)
+//
+// The methods change the Config of the server, so changes are lost when a
+// watched config file is reloaded.
+type AdminService struct {
+	Server *Server
+	// Authorize returns an error if the caller isn't an operator, e.g.
+	// checking a token distinct from those of the clients. If nil, all
+	// the calls fail with ErrForbidden.
+	Authorize func(r *http.Request) error
+}
+
+// AdminMethodArgs are the args of AdminService methods changing the
+// settings of a method.
+type AdminMethodArgs struct {
+	Method string `json:"method"`
+	// Enabled switches the method on or off, for SetEnabled.
+	Enabled bool `json:"enabled,omitempty"`
+	// RateLimit and RateBurst are the rate limit, for SetRateLimit. A
+	// zero RateLimit removes the limit.
+	RateLimit float64 `json:"rateLimit,omitempty"`
+	RateBurst int     `json:"rateBurst,omitempty"`
+}
+
+// AdminDrainArgs are the args of AdminService.Drain.
+type AdminDrainArgs struct {
+	// Timeout is the time to wait for the calls in flight. If zero, Drain
+	// returns without waiting.
+	Timeout Duration `json:"timeout,omitempty"`
+	// CloseSessions closes the sessions once the calls in flight are
+	// done, so that clients reconnect to other servers.
+	CloseSessions bool `json:"closeSessions,omitempty"`
+}
+
+// AdminStats are the stats of a server returned by AdminService.Stats.
+type AdminStats struct {
+	InFlight int64          `json:"inFlight"`
+	Draining bool           `json:"draining"`
+	Sessions int            `json:"sessions"`
+	Latency  []LatencyStats `json:"latency"`
+}
+
+// AdminSessionArgs are the args of the AdminService methods acting on a
+// session.
+type AdminSessionArgs struct {
+	ID string `json:"id"`
+	// Method and Params are the notification sent by NotifySession.
+	Method string      `json:"method,omitempty"`
+	Params interface{} `json:"params,omitempty"`
+}
+
+func (t *AdminService) authorize(r *http.Request) error {
+	if t.Authorize == nil {
+		return ErrForbidden
+	}
+	return t.Authorize(r)
+}
+
+// SetEnabled switches a method on or off.
+func (t *AdminService) SetEnabled(r *http.Request, args *AdminMethodArgs, reply *struct{}) error {
+	if err := t.authorize(r); err != nil {
+		return err
+	}
+	return t.Server.UpdateMethodConfig(args.Method, func(m *MethodConfig) {
+		m.Disabled = !args.Enabled
+	})
+}
+
+// SetRateLimit sets the rate limit of a method.
+func (t *AdminService) SetRateLimit(r *http.Request, args *AdminMethodArgs, reply *struct{}) error {
+	if err := t.authorize(r); err != nil {
+		return err
+	}
+	return t.Server.UpdateMethodConfig(args.Method, func(m *MethodConfig) {
+		m.RateLimit, m.RateBurst = args.RateLimit, args.RateBurst
+	})
+}
+
+// Config returns the current configuration of the server.
+func (t *AdminService) Config(r *http.Request, args *struct{}, reply *Config) error {
+	if err := t.authorize(r); err != nil {
+		return err
+	}
+	if c := t.Server.Config(); c != nil {
+		*reply = *c
+	}
+	return nil
+}
+
+// Drain makes the server reject new calls and waits for the calls in
+// flight until the timeout, see Server.Drain. The error of the context is
+// returned if calls are still in flight, and the sessions aren't closed.
+func (t *AdminService) Drain(r *http.Request, args *AdminDrainArgs, reply *struct{}) error {
+	if err := t.authorize(r); err != nil {
+		return err
+	}
+	if args.Timeout <= 0 {
+		t.Server.drain.start()
+	} else {
+		ctx, cancel := context.WithTimeout(r.Context(), time.Duration(args.Timeout))
+		defer cancel()
+		if err := t.Server.Drain(ctx); err != nil {
+			return err
+		}
+	}
+	if args.CloseSessions {
+		for _, session := range t.Server.Sessions() {
+			session.Close()
+		}
+	}
+	return nil
+}
+
+// Resume accepts calls again after Drain.
+func (t *AdminService) Resume(r *http.Request, args *struct{}, reply *struct{}) error {
+	if err := t.authorize(r); err != nil {
+		return err
+	}
+	t.Server.Resume()
+	return nil
+}
+
+// Stats returns the stats of the server.
+func (t *AdminService) Stats(r *http.Request, args *struct{}, reply *AdminStats) error {
+	if err := t.authorize(r); err != nil {
+		return err
+	}
+	*reply = AdminStats{
+		InFlight: t.Server.InFlight(),
+		Draining: t.Server.Draining(),
+		Sessions: len(t.Server.Sessions()),
+		Latency:  t.Server.LatencyStats(),
+	}
+	return nil
+}
+
+// Sessions lists the active sessions of the server, see Server.Sessions.
+func (t *AdminService) Sessions(r *http.Request, args *struct{}, reply *[]SessionInfo) error {
+	if err := t.authorize(r); err != nil {
+		return err
+	}
+	*reply = []SessionInfo{}
+	for _, session := range t.Server.Sessions() {
+		*reply = append(*reply, session.Info())
+	}
+	return nil
+}
+
+// CloseSession closes the connection of a session, e.g. of an abusive
+// client.
+func (t *AdminService) CloseSession(r *http.Request, args *AdminSessionArgs, reply *struct{}) error {
+	if err := t.authorize(r); err != nil {
+		return err
+	}
+	session := t.Server.Session(args.ID)
+	if session == nil {
+		return ErrUnknownSession
+	}
+	return session.Close()
+}
+
+// NotifySession sends a notification to the client of a session, e.g. to
+// warn it of a maintenance.
+func (t *AdminService) NotifySession(r *http.Request, args *AdminSessionArgs, reply *struct{}) error {
+	if err := t.authorize(r); err != nil {
+		return err
+	}
+	session := t.Server.Session(args.ID)
+	if session == nil {
+		return ErrUnknownSession
+	}
+	return session.Notify(args.Method, args.Params)
+}
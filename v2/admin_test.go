@@ -0,0 +1,137 @@
+// Copyright 2009 The Go Authors. All rights reserved.
+// Copyright 2012 The Gorilla Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package rpc
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+)
+
+type blockingService struct {
+	started chan struct{}
+	release chan struct{}
+}
+
+func (t *blockingService) Wait(r *http.Request, req *Service1Request, res *Service1Response) error {
+	t.started <- struct{}{}
+	<-t.release
+	return nil
+}
+
+type nopConn struct {
+	notified []string
+	closed   bool
+}
+
+func (c *nopConn) Notify(method string, params interface{}) error {
+	c.notified = append(c.notified, method)
+	return nil
+}
+
+func (c *nopConn) Close() error {
+	c.closed = true
+	return nil
+}
+
+func TestAdminService(t *testing.T) {
+	s := NewServer()
+	s.RegisterService(new(Service1), "")
+	s.RegisterCodec(MockCodec{2, 3}, "mock")
+	admin := &AdminService{Server: s}
+	if err := NewServer().RegisterService(admin, "Admin"); err != nil {
+		t.Fatal(err)
+	}
+	r, _ := http.NewRequest("POST", "/", nil)
+	if err := admin.Stats(r, nil, new(AdminStats)); err != ErrForbidden {
+		t.Errorf("Expected calls to be forbidden without Authorize, got %v", err)
+	}
+	errToken := errors.New("bad token")
+	admin.Authorize = func(r *http.Request) error {
+		if r.Header.Get("X-Admin-Token") != "secret" {
+			return errToken
+		}
+		return nil
+	}
+	if err := admin.Stats(r, nil, new(AdminStats)); err != errToken {
+		t.Errorf("Expected the error of Authorize, got %v", err)
+	}
+	r.Header.Set("X-Admin-Token", "secret")
+
+	if err := admin.SetEnabled(r, &AdminMethodArgs{Method: "Service1.Multiply"}, nil); err != nil {
+		t.Fatal(err)
+	}
+	if w := serveMock(s, "Service1.Multiply", ""); w.Status != http.StatusForbidden {
+		t.Errorf("Expected the method to be disabled, got %d", w.Status)
+	}
+	admin.SetEnabled(r, &AdminMethodArgs{Method: "Service1.Multiply", Enabled: true}, nil)
+	admin.SetRateLimit(r, &AdminMethodArgs{Method: "Service1.Multiply", RateLimit: 0.001, RateBurst: 1}, nil)
+	serveMock(s, "Service1.Multiply", "")
+	if w := serveMock(s, "Service1.Multiply", ""); w.Status != http.StatusTooManyRequests {
+		t.Errorf("Expected the method to be rate limited, got %d", w.Status)
+	}
+	var c Config
+	if admin.Config(r, nil, &c); c.Methods["Service1.Multiply"].RateBurst != 1 {
+		t.Errorf("Wrong config %+v", c)
+	}
+
+	conn := new(nopConn)
+	session := NewSession("s1", conn)
+	session.Set("user", "bob")
+	s.AddSession(session)
+	var sessions []SessionInfo
+	if admin.Sessions(r, nil, &sessions); len(sessions) != 1 || sessions[0].ID != "s1" || sessions[0].Metadata["user"] != "bob" || sessions[0].Started.IsZero() {
+		t.Errorf("Wrong sessions %+v", sessions)
+	}
+	if err := admin.NotifySession(r, &AdminSessionArgs{ID: "s1", Method: "Maintenance"}, nil); err != nil || len(conn.notified) != 1 {
+		t.Errorf("Expected the session to be notified, got %v %v", conn.notified, err)
+	}
+	if err := admin.CloseSession(r, &AdminSessionArgs{ID: "s2"}, nil); err != ErrUnknownSession {
+		t.Errorf("Expected an unknown session, got %v", err)
+	}
+	if err := admin.CloseSession(r, &AdminSessionArgs{ID: "s1"}, nil); err != nil || !conn.closed {
+		t.Errorf("Expected the session to be closed, got %v", err)
+	}
+	session.End()
+	if len(s.Sessions()) != 0 {
+		t.Errorf("Expected the ended session to be removed")
+	}
+
+	conn = new(nopConn)
+	s.AddSession(NewSession("", conn))
+	if err := admin.Drain(r, &AdminDrainArgs{CloseSessions: true}, nil); err != nil || !s.Draining() || !conn.closed {
+		t.Errorf("Expected the server to drain and close the sessions, got %v", err)
+	}
+}
+
+func TestDrain(t *testing.T) {
+	s := NewServer()
+	block := &blockingService{started: make(chan struct{}), release: make(chan struct{})}
+	s.RegisterService(block, "Block")
+	s.RegisterCodec(MockCodec{2, 3}, "mock")
+	go serveMock(s, "Block.Wait", "")
+	<-block.started
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := s.Drain(ctx); err != context.DeadlineExceeded || !s.Draining() || s.InFlight() != 1 {
+		t.Errorf("Expected the call in flight to be waited for, got %v", err)
+	}
+	if w := serveMock(s, "Block.Wait", ""); w.Status != http.StatusServiceUnavailable || w.Header().Get("Retry-After") != "1" {
+		t.Errorf("Expected new calls to be rejected with a Retry-After, got %d %v", w.Status, w.Header())
+	}
+	close(block.release)
+	if err := s.Drain(context.Background()); err != nil || s.InFlight() != 0 {
+		t.Errorf("Expected the server to be drained, got %v", err)
+	}
+	s.Resume()
+	go func() { <-block.started }()
+	if w := serveMock(s, "Block.Wait", ""); w.Status != http.StatusOK {
+		t.Errorf("Expected calls after Resume, got %d", w.Status)
+	}
+}
@@ -0,0 +1,285 @@
+// Copyright 2009 The Go Authors. All rights reserved.
+// Copyright 2012 The Gorilla Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package admission
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/rpc/v2"
+)
+
+// DefaultRetryAfter is the default time after which shed calls can be
+// retried.
+const DefaultRetryAfter = time.Second
+
+// ErrShed is returned for calls shed by a queue. Its HTTP status is
+// 503 Service Unavailable.
+var ErrShed error = shedError{}
+
+type shedError struct{}
+
+func (shedError) Error() string   { return "rpc: call shed under load" }
+func (shedError) HTTPStatus() int { return http.StatusServiceUnavailable }
+
+// Priority is the priority class of a call.
+type Priority int
+
+const (
+	Low Priority = iota
+	Normal
+	High
+)
+
+const numPriorities = 3
+
+var priorityNames = [numPriorities]string{"low", "normal", "high"}
+
+func (p Priority) String() string {
+	if p < Low || p > High {
+		return strconv.Itoa(int(p))
+	}
+	return priorityNames[p]
+}
+
+// ParsePriority parses a priority name or number.
+func ParsePriority(s string) (Priority, bool) {
+	s = strings.ToLower(strings.TrimSpace(s))
+	for i, name := range priorityNames {
+		if s == name || s == strconv.Itoa(i) {
+			return Priority(i), true
+		}
+	}
+	return Normal, false
+}
+
+// Classifier returns the priority of a call.
+type Classifier func(r *http.Request, method string) Priority
+
+// MethodClassifier returns a classifier giving the priority of the method
+// from a map, or def for other methods.
+func MethodClassifier(methods map[string]Priority, def Priority) Classifier {
+	return func(r *http.Request, method string) Priority {
+		if p, ok := methods[method]; ok {
+			return p
+		}
+		return def
+	}
+}
+
+// HeaderClassifier returns a classifier reading the priority from a request
+// header, or calling next if the header is missing or invalid. Headers can
+// be set by any client: only trust them behind a gateway controlling them.
+func HeaderClassifier(header string, next Classifier) Classifier {
+	return func(r *http.Request, method string) Priority {
+		if p, ok := ParsePriority(r.Header.Get(header)); ok {
+			return p
+		}
+		return next(r, method)
+	}
+}
+
+// PriorityClassifier returns a classifier reading the priority carried by
+// the context of the call, received in the rpc.PriorityHeader or set with
+// WithPriority, or calling next if it's missing or invalid. As with
+// HeaderClassifier, only trust it behind a gateway controlling the header.
+func PriorityClassifier(next Classifier) Classifier {
+	return func(r *http.Request, method string) Priority {
+		if p, ok := ParsePriority(rpc.PriorityFromContext(r.Context())); ok {
+			return p
+		}
+		return next(r, method)
+	}
+}
+
+// WithPriority returns a context carrying the priority, sent in the
+// rpc.PriorityHeader of the calls made with it, e.g. by background jobs
+// calling other services with a Low priority.
+func WithPriority(ctx context.Context, p Priority) context.Context {
+	return rpc.WithPriority(ctx, p.String())
+}
+
+// Stats are the current state of a queue.
+type Stats struct {
+	Running int
+	Queued  map[Priority]int
+	Shed    map[Priority]uint64
+}
+
+// waiter is a queued call.
+type waiter struct {
+	ready chan error
+}
+
+// Queue limits the number of calls running at once. Calls over the limit
+// are queued and dispatched by priority, highest first. When the queue is
+// full, the newest call of the lowest priority is shed.
+type Queue struct {
+	// Classify returns the priority of calls. All calls are Normal by
+	// default.
+	Classify Classifier
+	// MaxWait is the time after which queued calls are shed. There is no
+	// limit if it is 0.
+	MaxWait time.Duration
+	// RetryAfter is sent in the Retry-After header of shed calls. If zero,
+	// DefaultRetryAfter is used.
+	RetryAfter time.Duration
+
+	concurrency int
+	maxQueued   int
+	mutex       sync.Mutex
+	running     int
+	queued      int
+	queues      [numPriorities][]*waiter
+	shed        [numPriorities]uint64
+}
+
+// New returns a queue running at most concurrency calls at once, and
+// queuing at most maxQueued calls.
+func New(concurrency, maxQueued int) *Queue {
+	return &Queue{concurrency: concurrency, maxQueued: maxQueued}
+}
+
+// Middleware admits calls through the queue, to be registered with
+// rpc.Server.RegisterMiddleware.
+func (q *Queue) Middleware(next rpc.CallFunc) rpc.CallFunc {
+	return func(r *http.Request, method string, args, reply interface{}) error {
+		p := Normal
+		if q.Classify != nil {
+			p = q.Classify(r, method)
+		}
+		if p < Low {
+			p = Low
+		} else if p > High {
+			p = High
+		}
+		if err := q.acquire(r, p); err != nil {
+			if meta := rpc.ResponseMetaFromContext(r.Context()); meta != nil && err == ErrShed {
+				meta.SetThrottle(rpc.Throttle{RetryAfter: q.retryAfter()})
+			}
+			return err
+		}
+		defer q.release()
+		return next(r, method, args, reply)
+	}
+}
+
+func (q *Queue) retryAfter() time.Duration {
+	if q.RetryAfter > 0 {
+		return q.RetryAfter
+	}
+	return DefaultRetryAfter
+}
+
+// acquire waits until the call can run.
+func (q *Queue) acquire(r *http.Request, p Priority) error {
+	q.mutex.Lock()
+	if q.running < q.concurrency && q.queued == 0 {
+		q.running++
+		q.mutex.Unlock()
+		return nil
+	}
+	if q.queued >= q.maxQueued && !q.evict(p) {
+		q.shed[p]++
+		q.mutex.Unlock()
+		return ErrShed
+	}
+	w := &waiter{ready: make(chan error, 1)}
+	q.queues[p] = append(q.queues[p], w)
+	q.queued++
+	q.mutex.Unlock()
+
+	var timeout <-chan time.Time
+	if q.MaxWait > 0 {
+		t := time.NewTimer(q.MaxWait)
+		defer t.Stop()
+		timeout = t.C
+	}
+	var err error
+	select {
+	case err = <-w.ready:
+		return err
+	case <-timeout:
+		err = ErrShed
+	case <-r.Context().Done():
+		err = r.Context().Err()
+	}
+	q.mutex.Lock()
+	if q.remove(p, w) {
+		q.shed[p]++
+		q.mutex.Unlock()
+		return err
+	}
+	q.mutex.Unlock()
+	// The call was admitted or evicted meanwhile.
+	if <-w.ready == nil {
+		q.release()
+	}
+	return err
+}
+
+// evict sheds the newest queued call with the lowest priority below p.
+func (q *Queue) evict(p Priority) bool {
+	for lp := Low; lp < p; lp++ {
+		if n := len(q.queues[lp]); n > 0 {
+			w := q.queues[lp][n-1]
+			q.queues[lp] = q.queues[lp][:n-1]
+			q.queued--
+			q.shed[lp]++
+			w.ready <- ErrShed
+			return true
+		}
+	}
+	return false
+}
+
+// remove removes a queued call, returning false if it is not queued.
+func (q *Queue) remove(p Priority, w *waiter) bool {
+	for i, v := range q.queues[p] {
+		if v == w {
+			q.queues[p] = append(q.queues[p][:i], q.queues[p][i+1:]...)
+			q.queued--
+			return true
+		}
+	}
+	return false
+}
+
+// release hands the slot of a finished call to the next queued call.
+func (q *Queue) release() {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	for p := High; p >= Low; p-- {
+		if len(q.queues[p]) > 0 {
+			w := q.queues[p][0]
+			q.queues[p] = q.queues[p][1:]
+			q.queued--
+			w.ready <- nil
+			return
+		}
+	}
+	q.running--
+}
+
+// Stats returns the current state of the queue.
+func (q *Queue) Stats() Stats {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	s := Stats{
+		Running: q.running,
+		Queued:  make(map[Priority]int),
+		Shed:    make(map[Priority]uint64),
+	}
+	for p := Low; p <= High; p++ {
+		s.Queued[p] = len(q.queues[p])
+		s.Shed[p] = q.shed[p]
+	}
+	return s
+}
@@ -0,0 +1,127 @@
+// Copyright 2009 The Go Authors. All rights reserved.
+// Copyright 2012 The Gorilla Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package admission
+
+import (
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+)
+
+// waitQueued waits until n calls of priority p are queued.
+func waitQueued(t *testing.T, q *Queue, p Priority, n int) {
+	for i := 0; i < 1000; i++ {
+		if q.Stats().Queued[p] == n {
+			return
+		}
+		time.Sleep(time.Millisecond)
+	}
+	t.Fatalf("Expected %d queued calls of priority %v", n, p)
+}
+
+func TestQueue(t *testing.T) {
+	q := New(1, 2)
+	q.Classify = HeaderClassifier("X-Priority", MethodClassifier(nil, Normal))
+	var mutex sync.Mutex
+	var order []string
+	block := make(chan struct{})
+	call := q.Middleware(func(r *http.Request, method string, args, reply interface{}) error {
+		if method == "block" {
+			<-block
+		}
+		mutex.Lock()
+		order = append(order, method)
+		mutex.Unlock()
+		return nil
+	})
+	request := func(priority string) *http.Request {
+		r, _ := http.NewRequest("POST", "/", nil)
+		r.Header.Set("X-Priority", priority)
+		return r
+	}
+
+	var wg sync.WaitGroup
+	errs := make(map[string]error)
+	run := func(method, priority string) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			err := call(request(priority), method, nil, nil)
+			mutex.Lock()
+			errs[method] = err
+			mutex.Unlock()
+		}()
+	}
+	run("block", "normal")
+	for q.Stats().Running != 1 {
+		time.Sleep(time.Millisecond)
+	}
+	run("low", "low")
+	waitQueued(t, q, Low, 1)
+	run("normal", "normal")
+	waitQueued(t, q, Normal, 1)
+	// The queue is full: the low priority call is shed.
+	run("high", "high")
+	waitQueued(t, q, High, 1)
+	// Nothing is lower than low: the new call is shed.
+	if err := call(request("low"), "low2", nil, nil); err != ErrShed {
+		t.Fatalf("Expected ErrShed, got %v", err)
+	}
+
+	stats := q.Stats()
+	if stats.Queued[Normal] != 1 || stats.Queued[High] != 1 || stats.Shed[Low] != 2 {
+		t.Fatalf("Wrong stats: %+v", stats)
+	}
+	close(block)
+	wg.Wait()
+
+	if errs["low"] != ErrShed {
+		t.Errorf("Expected low priority call to be shed, got %v", errs["low"])
+	}
+	if len(order) != 3 || order[0] != "block" || order[1] != "high" || order[2] != "normal" {
+		t.Errorf("Wrong dispatch order: %v", order)
+	}
+	if stats := q.Stats(); stats.Running != 0 {
+		t.Errorf("Expected no running calls, got %d", stats.Running)
+	}
+}
+
+func TestQueueMaxWait(t *testing.T) {
+	q := New(1, 1)
+	q.MaxWait = 10 * time.Millisecond
+	block := make(chan struct{})
+	call := q.Middleware(func(r *http.Request, method string, args, reply interface{}) error {
+		<-block
+		return nil
+	})
+	r, _ := http.NewRequest("POST", "/", nil)
+	done := make(chan struct{})
+	go func() {
+		call(r, "A", nil, nil)
+		close(done)
+	}()
+	for q.Stats().Running != 1 {
+		time.Sleep(time.Millisecond)
+	}
+	if err := call(r, "B", nil, nil); err != ErrShed {
+		t.Errorf("Expected ErrShed, got %v", err)
+	}
+	close(block)
+	<-done
+}
+
+func TestPriorityClassifier(t *testing.T) {
+	classify := PriorityClassifier(MethodClassifier(nil, Normal))
+	r, _ := http.NewRequest("POST", "/", nil)
+	if p := classify(r, "A"); p != Normal {
+		t.Errorf("Expected the default priority, got %v", p)
+	}
+	r = r.WithContext(WithPriority(r.Context(), High))
+	if p := classify(r, "A"); p != High {
+		t.Errorf("Expected the priority of the context, got %v", p)
+	}
+}
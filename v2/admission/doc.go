@@ -0,0 +1,27 @@
+// Copyright 2009 The Go Authors. All rights reserved.
+// Copyright 2012 The Gorilla Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+/*
+Package gorilla/rpc/v2/admission limits the number of calls running at once
+and, under load, dispatches queued calls by priority.
+
+Calls are classified into Low, Normal and High priorities, per method or
+per request header:
+
+	q := admission.New(64, 256)
+	q.MaxWait = time.Second
+	q.Classify = admission.HeaderClassifier("X-Priority",
+		admission.MethodClassifier(map[string]admission.Priority{
+			"Orders.Create": admission.High,
+			"Reports.Build": admission.Low,
+		}, admission.Normal))
+	s.RegisterMiddleware(q.Middleware)
+
+When the queue is full, queued calls of lower priority are shed to make room
+for new ones, and new calls are shed when no such call is queued. Shed calls
+fail with ErrShed. Stats returns the queue depths and shed counts per
+priority, to be exported as metrics.
+*/
+package admission
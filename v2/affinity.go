@@ -0,0 +1,52 @@
+// Copyright 2009 The Go Authors. All rights reserved.
+// Copyright 2012 The Gorilla Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package rpc
+
+import (
+	"context"
+	"net/http"
+)
+
+// AffinityHeader carries the affinity token of a client session. Servers
+// issue it in their responses and clients echo it in their requests, so
+// gateways can keep a client pinned to one backend instance.
+const AffinityHeader = "X-Rpc-Affinity"
+
+type affinityKey struct{}
+
+// EnableAffinity sets whether the server issues affinity tokens. When
+// enabled, requests without an AffinityHeader get a new random token, and
+// the token of each request is returned in the AffinityHeader of the
+// response and is available to methods with AffinityFromContext.
+func (s *Server) EnableAffinity(enabled bool) {
+	s.affinity = enabled
+}
+
+// withAffinity returns the request with its affinity token in the context, and
+// sets the token in the response headers.
+func (s *Server) withAffinity(w http.ResponseWriter, r *http.Request) *http.Request {
+	if !s.affinity {
+		return r
+	}
+	token := r.Header.Get(AffinityHeader)
+	if token == "" {
+		token = newID()
+	}
+	w.Header().Set(AffinityHeader, token)
+	return r.WithContext(NewAffinityContext(r.Context(), token))
+}
+
+// NewAffinityContext returns a context carrying the affinity token.
+func NewAffinityContext(ctx context.Context, token string) context.Context {
+	return context.WithValue(ctx, affinityKey{}, token)
+}
+
+// AffinityFromContext returns the affinity token of the call, or an empty
+// string if affinity is not enabled.
+func AffinityFromContext(ctx context.Context) string {
+	token, _ := ctx.Value(affinityKey{}).(string)
+	return token
+}
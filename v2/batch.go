@@ -0,0 +1,102 @@
+// Copyright 2009 The Go Authors. All rights reserved.
+// Copyright 2012 The Gorilla Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package rpc
+
+import (
+	"context"
+	"net/http"
+)
+
+// BatchCodecRequest is implemented by codec requests carrying several
+// calls, e.g. JSON-RPC 2.0 batches. The server serves the calls in order
+// and writes their responses together.
+type BatchCodecRequest interface {
+	CodecRequest
+	// Requests returns the requests of the calls of the batch.
+	Requests() []CodecRequest
+	// WriteBatch writes the responses written by the requests of the
+	// calls. Responses are empty for calls that don't reply, as for
+	// notifications.
+	WriteBatch(w http.ResponseWriter, responses [][]byte)
+}
+
+// StreamBatchCodecRequest is implemented by batch codec requests decoding
+// their calls one at a time. The server serves each call as soon as it's
+// decoded, so that large batches aren't held in decoded form at once.
+// When a TransactionManager is registered, the calls are read with
+// Requests instead, to run them in a transaction.
+type StreamBatchCodecRequest interface {
+	BatchCodecRequest
+	// Next returns the request of the next call of the batch, or false
+	// after the last one.
+	Next() (CodecRequest, bool)
+}
+
+// serveBatch serves the calls of a batch, in a transaction if they belong
+// to the method group of a TransactionManager.
+func (s *Server) serveBatch(w http.ResponseWriter, r *http.Request, batch BatchCodecRequest, contentType string) {
+	if stream, ok := batch.(StreamBatchCodecRequest); ok && len(s.transactions) == 0 {
+		s.serveBatchStream(w, r, stream, contentType)
+		return
+	}
+	reqs := batch.Requests()
+	responses := make([][]byte, len(reqs))
+	tm, err := s.transactions.batch(reqs)
+	if err == nil && tm != nil {
+		var ctx context.Context
+		if ctx, err = tm.Begin(r.Context()); err == nil {
+			r = r.WithContext(withTransaction(ctx))
+		}
+	}
+	if err != nil {
+		for i, req := range reqs {
+			responses[i] = writeBatchError(req, err)
+		}
+		batch.WriteBatch(w, responses)
+		return
+	}
+	for i, req := range reqs {
+		mw := &messageWriter{header: make(http.Header)}
+		err = s.serveRequest(mw, r, req, contentType)
+		responses[i] = mw.body.Bytes()
+		if err != nil && tm != nil {
+			// All or nothing: the other calls are aborted.
+			tm.Rollback(r.Context())
+			for j := range reqs {
+				if j != i {
+					responses[j] = writeBatchError(reqs[j], ErrTransactionAborted)
+				}
+			}
+			break
+		}
+	}
+	if err == nil && tm != nil {
+		if err = tm.Commit(r.Context()); err != nil {
+			for i, req := range reqs {
+				responses[i] = writeBatchError(req, err)
+			}
+		}
+	}
+	batch.WriteBatch(w, responses)
+}
+
+// serveBatchStream serves the calls of a batch as they are decoded.
+func (s *Server) serveBatchStream(w http.ResponseWriter, r *http.Request, batch StreamBatchCodecRequest, contentType string) {
+	var responses [][]byte
+	for req, ok := batch.Next(); ok; req, ok = batch.Next() {
+		mw := &messageWriter{header: make(http.Header)}
+		s.serveRequest(mw, r, req, contentType)
+		responses = append(responses, mw.body.Bytes())
+	}
+	batch.WriteBatch(w, responses)
+}
+
+// writeBatchError returns the error response of a call of a batch.
+func writeBatchError(req CodecRequest, err error) []byte {
+	mw := &messageWriter{header: make(http.Header)}
+	req.WriteError(mw, http.StatusInternalServerError, err)
+	return mw.body.Bytes()
+}
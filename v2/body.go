@@ -0,0 +1,55 @@
+// Copyright 2009 The Go Authors. All rights reserved.
+// Copyright 2012 The Gorilla Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package rpc
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"net/http"
+)
+
+// KeepRequestBody makes the server read the body of the requests before
+// the codecs, and keep it in RequestInfo.Body for the hooks, e.g. to
+// verify signatures or log the requests, instead of each hook reading it
+// and leaving nothing to the codec. The body is also returned by
+// RequestBodyFromContext.
+//
+// Bodies are fully buffered: limit their size, e.g. with
+// http.MaxBytesReader, when keeping them.
+func (s *Server) KeepRequestBody(keep bool) {
+	s.keepBody = keep
+}
+
+type requestBodyKey struct{}
+
+// keepBody reads the body of r and returns a copy of r whose body is
+// read from the buffered bytes, which are kept in its context.
+func keepBody(r *http.Request) (*http.Request, error) {
+	var body []byte
+	if r.Body != nil {
+		var err error
+		body, err = ioutil.ReadAll(r.Body)
+		r.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+	r = r.WithContext(context.WithValue(r.Context(), requestBodyKey{}, body))
+	r.Body = ioutil.NopCloser(bytes.NewReader(body))
+	return r, nil
+}
+
+// RequestBodyFromContext returns the raw body of the request kept by the
+// server, see Server.KeepRequestBody, or nil.
+func RequestBodyFromContext(ctx context.Context) []byte {
+	body, _ := ctx.Value(requestBodyKey{}).([]byte)
+	return body
+}
+
+func requestBody(r *http.Request) []byte {
+	return RequestBodyFromContext(r.Context())
+}
@@ -0,0 +1,233 @@
+// Copyright 2009 The Go Authors. All rights reserved.
+// Copyright 2012 The Gorilla Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package breaker
+
+import (
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gorilla/rpc/v2"
+	"github.com/gorilla/rpc/v2/json2"
+)
+
+// E_CIRCUIT_OPEN is the JSON-RPC error code of calls failed by an open
+// breaker, in the range reserved for server errors.
+const E_CIRCUIT_OPEN json2.ErrorCode = -32010
+
+// ErrCircuitOpen is returned for calls to a method whose breaker is open.
+var ErrCircuitOpen = &json2.Error{
+	Code:    E_CIRCUIT_OPEN,
+	Message: "rpc: circuit breaker is open",
+}
+
+// Defaults of the zero fields of a Policy.
+const (
+	DefaultErrorRate   = 0.5
+	DefaultMinRequests = 10
+	DefaultWindow      = 10 * time.Second
+	DefaultOpenTimeout = 5 * time.Second
+)
+
+// Policy configures the breaker of a method.
+//
+// The breaker opens when the rate of failed calls within Window reaches
+// ErrorRate, after at least MinRequests calls. While open, calls fail fast
+// with ErrCircuitOpen. After OpenTimeout a single probe call is let
+// through: the breaker closes if it succeeds and opens again otherwise.
+// Calls that panic are failures. Zero fields take their defaults, see
+// DefaultErrorRate.
+type Policy struct {
+	ErrorRate   float64
+	MinRequests int
+	Window      time.Duration
+	OpenTimeout time.Duration
+
+	// IsFailure returns true if an error counts as a failure. By default
+	// all errors are failures except JSON-RPC errors other than
+	// E_INTERNAL, which are considered application errors.
+	IsFailure func(err error) bool
+}
+
+func (p *Policy) errorRate() float64 {
+	if p.ErrorRate > 0 {
+		return p.ErrorRate
+	}
+	return DefaultErrorRate
+}
+
+func (p *Policy) minRequests() int {
+	if p.MinRequests > 0 {
+		return p.MinRequests
+	}
+	return DefaultMinRequests
+}
+
+func (p *Policy) window() time.Duration {
+	if p.Window > 0 {
+		return p.Window
+	}
+	return DefaultWindow
+}
+
+func (p *Policy) openTimeout() time.Duration {
+	if p.OpenTimeout > 0 {
+		return p.OpenTimeout
+	}
+	return DefaultOpenTimeout
+}
+
+// State is the state of a breaker.
+type State int
+
+const (
+	Closed State = iota
+	Open
+	HalfOpen
+)
+
+func (s State) String() string {
+	switch s {
+	case Open:
+		return "open"
+	case HalfOpen:
+		return "half-open"
+	}
+	return "closed"
+}
+
+// breaker is the circuit breaker of a method.
+type breaker struct {
+	policy      Policy
+	mutex       sync.Mutex
+	state       State
+	windowStart time.Time
+	requests    int
+	failures    int
+	openedAt    time.Time
+}
+
+// allow returns true if a call can go through.
+func (b *breaker) allow(now time.Time) bool {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	switch b.state {
+	case Open:
+		if now.Sub(b.openedAt) < b.policy.openTimeout() {
+			return false
+		}
+		// Let a single probe through.
+		b.state = HalfOpen
+		return true
+	case HalfOpen:
+		return false
+	}
+	return true
+}
+
+// record records the outcome of a call.
+func (b *breaker) record(now time.Time, failed bool) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	if b.state == HalfOpen {
+		if failed {
+			b.state, b.openedAt = Open, now
+		} else {
+			b.state = Closed
+			b.windowStart, b.requests, b.failures = now, 0, 0
+		}
+		return
+	}
+	if now.Sub(b.windowStart) >= b.policy.window() {
+		b.windowStart, b.requests, b.failures = now, 0, 0
+	}
+	b.requests++
+	if failed {
+		b.failures++
+	}
+	if failed && b.requests >= b.policy.minRequests() && float64(b.failures) >= b.policy.errorRate()*float64(b.requests) {
+		b.state, b.openedAt = Open, now
+	}
+}
+
+func (b *breaker) isFailure(err error) bool {
+	if err == nil {
+		return false
+	}
+	if b.policy.IsFailure != nil {
+		return b.policy.IsFailure(err)
+	}
+	if jsonErr, ok := err.(*json2.Error); ok {
+		return jsonErr.Code == json2.E_INTERNAL
+	}
+	return true
+}
+
+// Breakers holds the breakers attached to methods. Methods without a
+// breaker are called normally.
+type Breakers struct {
+	mutex    sync.RWMutex
+	breakers map[string]*breaker
+}
+
+// New returns a Breakers without any breaker attached.
+func New() *Breakers {
+	return &Breakers{breakers: make(map[string]*breaker)}
+}
+
+// Attach attaches a breaker to a method, replacing any previous one.
+func (bs *Breakers) Attach(method string, p Policy) {
+	bs.mutex.Lock()
+	defer bs.mutex.Unlock()
+	bs.breakers[method] = &breaker{policy: p, windowStart: time.Now()}
+}
+
+// Detach removes the breaker of a method.
+func (bs *Breakers) Detach(method string) {
+	bs.mutex.Lock()
+	defer bs.mutex.Unlock()
+	delete(bs.breakers, method)
+}
+
+// State returns the state of the breaker of a method, or Closed if it has
+// no breaker.
+func (bs *Breakers) State(method string) State {
+	if b := bs.get(method); b != nil {
+		b.mutex.Lock()
+		defer b.mutex.Unlock()
+		return b.state
+	}
+	return Closed
+}
+
+func (bs *Breakers) get(method string) *breaker {
+	bs.mutex.RLock()
+	defer bs.mutex.RUnlock()
+	return bs.breakers[method]
+}
+
+// Middleware fails fast the calls to methods whose breaker is open, to be
+// registered with rpc.Server.RegisterMiddleware.
+func (bs *Breakers) Middleware(next rpc.CallFunc) rpc.CallFunc {
+	return func(r *http.Request, method string, args, reply interface{}) error {
+		b := bs.get(method)
+		if b == nil {
+			return next(r, method, args, reply)
+		}
+		if !b.allow(time.Now()) {
+			return ErrCircuitOpen
+		}
+		// Panics are recorded as failures, so that a probe doesn't leave
+		// the breaker half-open.
+		failed := true
+		defer func() {
+			b.record(time.Now(), failed)
+		}()
+		err := next(r, method, args, reply)
+		failed = b.isFailure(err)
+		return err
+	}
+}
@@ -0,0 +1,120 @@
+// Copyright 2009 The Go Authors. All rights reserved.
+// Copyright 2012 The Gorilla Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package breaker
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/gorilla/rpc/v2/json2"
+)
+
+func TestBreakers(t *testing.T) {
+	bs := New()
+	bs.Attach("DB.Query", Policy{
+		ErrorRate:   0.5,
+		MinRequests: 2,
+		Window:      time.Minute,
+		OpenTimeout: 10 * time.Millisecond,
+	})
+	var err error
+	calls := 0
+	call := bs.Middleware(func(r *http.Request, method string, args, reply interface{}) error {
+		calls++
+		return err
+	})
+	r, _ := http.NewRequest("POST", "/", nil)
+
+	// Application errors don't trip the breaker.
+	err = &json2.Error{Code: json2.E_BAD_PARAMS}
+	call(r, "DB.Query", nil, nil)
+	call(r, "DB.Query", nil, nil)
+	if s := bs.State("DB.Query"); s != Closed {
+		t.Fatalf("Expected closed breaker, got %v", s)
+	}
+
+	err = errors.New("database is down")
+	call(r, "DB.Query", nil, nil)
+	call(r, "DB.Query", nil, nil)
+	if s := bs.State("DB.Query"); s != Open {
+		t.Fatalf("Expected open breaker, got %v", s)
+	}
+	calls = 0
+	if e := call(r, "DB.Query", nil, nil); e != ErrCircuitOpen || calls != 0 {
+		t.Fatalf("Expected fast failure, got %v after %d calls", e, calls)
+	}
+	// Methods without a breaker are not affected.
+	if e := call(r, "Other.Method", nil, nil); e != err {
+		t.Fatalf("Expected method error, got %v", e)
+	}
+
+	time.Sleep(20 * time.Millisecond)
+	err = nil
+	if e := call(r, "DB.Query", nil, nil); e != nil {
+		t.Fatalf("Expected probe to succeed, got %v", e)
+	}
+	if s := bs.State("DB.Query"); s != Closed {
+		t.Fatalf("Expected closed breaker, got %v", s)
+	}
+}
+
+func TestBreakerPanickingProbe(t *testing.T) {
+	bs := New()
+	bs.Attach("DB.Query", Policy{
+		MinRequests: 1,
+		OpenTimeout: 10 * time.Millisecond,
+	})
+	fail := errors.New("database is down")
+	call := bs.Middleware(func(r *http.Request, method string, args, reply interface{}) error {
+		if args != nil {
+			panic(args)
+		}
+		return fail
+	})
+	r, _ := http.NewRequest("POST", "/", nil)
+	call(r, "DB.Query", nil, nil)
+	if s := bs.State("DB.Query"); s != Open {
+		t.Fatalf("Expected open breaker, got %v", s)
+	}
+
+	time.Sleep(20 * time.Millisecond)
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Fatal("Expected the probe to panic")
+			}
+		}()
+		call(r, "DB.Query", "boom", nil)
+	}()
+	// The panicking probe is a failure: the breaker opens again, and lets
+	// another probe through after OpenTimeout.
+	if s := bs.State("DB.Query"); s != Open {
+		t.Fatalf("Expected open breaker, got %v", s)
+	}
+	time.Sleep(20 * time.Millisecond)
+	if e := call(r, "DB.Query", nil, nil); e != fail {
+		t.Fatalf("Expected a new probe, got %v", e)
+	}
+}
+
+func TestBreakerPolicyDefaults(t *testing.T) {
+	bs := New()
+	bs.Attach("DB.Query", Policy{})
+	call := bs.Middleware(func(r *http.Request, method string, args, reply interface{}) error {
+		return nil
+	})
+	r, _ := http.NewRequest("POST", "/", nil)
+	for i := 0; i < 2*DefaultMinRequests; i++ {
+		if e := call(r, "DB.Query", nil, nil); e != nil {
+			t.Fatalf("Expected success, got %v", e)
+		}
+	}
+	if s := bs.State("DB.Query"); s != Closed {
+		t.Fatalf("Expected closed breaker, got %v", s)
+	}
+}
@@ -0,0 +1,23 @@
+// Copyright 2009 The Go Authors. All rights reserved.
+// Copyright 2012 The Gorilla Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+/*
+Package gorilla/rpc/v2/breaker attaches circuit breakers to methods of a
+server, so that methods whose dependencies are down fail fast instead of
+tying up workers.
+
+	bs := breaker.New()
+	bs.Attach("Orders.Create", breaker.Policy{
+		ErrorRate:   0.5,
+		MinRequests: 20,
+		Window:      10 * time.Second,
+		OpenTimeout: 5 * time.Second,
+	})
+	s.RegisterMiddleware(bs.Middleware)
+
+Calls to a method whose breaker is open fail with ErrCircuitOpen, which has
+the E_CIRCUIT_OPEN error code, so clients can tell them from other errors.
+*/
+package breaker
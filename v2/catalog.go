@@ -0,0 +1,179 @@
+// Copyright 2009 The Go Authors. All rights reserved.
+// Copyright 2012 The Gorilla Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package rpc
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+)
+
+// CodedError is implemented by errors carrying an application error code,
+// e.g. json2.Error, checked against the ErrorCatalog of the server.
+type CodedError interface {
+	error
+	ErrorCode() int
+}
+
+// ErrorDefinition documents an error code returned by the methods of a
+// service.
+type ErrorDefinition struct {
+	Code int `json:"code"`
+	// Name is the stable name of the error, e.g. "ACCOUNT_LOCKED".
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	// Service is the service that registered the error.
+	Service string `json:"service,omitempty"`
+}
+
+// ErrorCatalog holds the error codes returned by the methods of a server,
+// so that clients can discover them, see ErrorCatalogService, and handle
+// each of them. Set on a server with SetErrorCatalog, it rejects the
+// errors of methods with codes that weren't registered.
+//
+// It is safe for concurrent use; the zero value is an empty catalog.
+type ErrorCatalog struct {
+	mutex sync.RWMutex
+	defs  map[int]*ErrorDefinition
+}
+
+// Register adds the errors of a service to the catalog. Codes can be
+// shared by services, but must keep the same name: a code registered with
+// another name is rejected, and none of the errors are added.
+func (c *ErrorCatalog) Register(service string, defs ...ErrorDefinition) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	for _, d := range defs {
+		if d.Name == "" {
+			return fmt.Errorf("rpc: error code %d has no name", d.Code)
+		}
+		if old := c.defs[d.Code]; old != nil && old.Name != d.Name {
+			return fmt.Errorf("rpc: error code %d already registered as %s", d.Code, old.Name)
+		}
+	}
+	if c.defs == nil {
+		c.defs = make(map[int]*ErrorDefinition)
+	}
+	for _, d := range defs {
+		if c.defs[d.Code] != nil {
+			continue
+		}
+		d := d
+		d.Service = service
+		c.defs[d.Code] = &d
+	}
+	return nil
+}
+
+// Lookup returns the definition of the code, or false if it isn't
+// registered.
+func (c *ErrorCatalog) Lookup(code int) (ErrorDefinition, bool) {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	if d := c.defs[code]; d != nil {
+		return *d, true
+	}
+	return ErrorDefinition{}, false
+}
+
+// Errors returns the definitions of the catalog, sorted by code.
+func (c *ErrorCatalog) Errors() []ErrorDefinition {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	defs := make([]ErrorDefinition, 0, len(c.defs))
+	for _, d := range c.defs {
+		defs = append(defs, *d)
+	}
+	sort.Sort(byCode(defs))
+	return defs
+}
+
+// byCode sorts error definitions by code.
+type byCode []ErrorDefinition
+
+func (d byCode) Len() int           { return len(d) }
+func (d byCode) Less(i, j int) bool { return d[i].Code < d[j].Code }
+func (d byCode) Swap(i, j int)      { d[i], d[j] = d[j], d[i] }
+
+// UncataloguedError replaces the error of a method whose code isn't in the
+// ErrorCatalog of the server. It has the 500 Internal Server Error status,
+// so that it's reported, see RegisterErrorReporter.
+type UncataloguedError struct {
+	Code int
+	Err  error
+}
+
+func (e *UncataloguedError) Error() string {
+	return fmt.Sprintf("rpc: error code %d isn't in the catalog: %v", e.Code, e.Err)
+}
+
+// Unwrap returns the error of the method.
+func (e *UncataloguedError) Unwrap() error {
+	return e.Err
+}
+
+// HTTPStatus returns the 500 Internal Server Error status.
+func (e *UncataloguedError) HTTPStatus() int {
+	return http.StatusInternalServerError
+}
+
+// SetErrorCatalog sets the catalog of the error codes the methods may
+// return. The CodedErrors of methods with other codes are replaced by an
+// UncataloguedError. A nil catalog disables the check.
+func (s *Server) SetErrorCatalog(c *ErrorCatalog) {
+	s.errorCatalog = c
+}
+
+// ErrorCatalog returns the catalog set with SetErrorCatalog, or nil.
+func (s *Server) ErrorCatalog() *ErrorCatalog {
+	return s.errorCatalog
+}
+
+// checkErrorCode returns the error of a method, or an UncataloguedError if
+// its code isn't in the catalog.
+func (s *Server) checkErrorCode(err error) error {
+	if s.errorCatalog == nil {
+		return err
+	}
+	for e := err; e != nil; e = unwrap(e) {
+		coded, ok := e.(CodedError)
+		if !ok {
+			continue
+		}
+		if _, ok := s.errorCatalog.Lookup(coded.ErrorCode()); !ok {
+			return &UncataloguedError{Code: coded.ErrorCode(), Err: err}
+		}
+		return err
+	}
+	return err
+}
+
+// unwrap returns the error wrapped by err, or nil, as errors.Unwrap of
+// Go 1.13.
+func unwrap(err error) error {
+	if u, ok := err.(interface{ Unwrap() error }); ok {
+		return u.Unwrap()
+	}
+	return nil
+}
+
+// ErrorCatalogService exposes the ErrorCatalog of a server, so that
+// clients can build their error handling from the codes it returns:
+//
+//	s.RegisterService(&rpc.ErrorCatalogService{Server: s}, "Errors")
+type ErrorCatalogService struct {
+	Server *Server
+}
+
+// List returns the errors of the catalog, sorted by code.
+func (t *ErrorCatalogService) List(r *http.Request, args *struct{}, reply *[]ErrorDefinition) error {
+	*reply = []ErrorDefinition{}
+	if c := t.Server.ErrorCatalog(); c != nil {
+		*reply = c.Errors()
+	}
+	return nil
+}
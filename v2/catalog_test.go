@@ -0,0 +1,62 @@
+// Copyright 2009 The Go Authors. All rights reserved.
+// Copyright 2012 The Gorilla Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package rpc
+
+import (
+	"net/http"
+	"testing"
+)
+
+type codedError int
+
+func (e codedError) Error() string  { return "coded error" }
+func (e codedError) ErrorCode() int { return int(e) }
+
+type CodedService struct{}
+
+func (CodedService) Fail(r *http.Request, req *Service1Request, res *Service1Response) error {
+	return codedError(req.A)
+}
+
+func TestErrorCatalog(t *testing.T) {
+	c := new(ErrorCatalog)
+	if err := c.Register("Coded", ErrorDefinition{Code: 2, Name: "LOCKED"}, ErrorDefinition{Code: 1, Name: "EXPIRED"}); err != nil {
+		t.Fatal(err)
+	}
+	if d, ok := c.Lookup(2); !ok || d.Name != "LOCKED" || d.Service != "Coded" {
+		t.Errorf("Wrong definition of code 2: %+v", d)
+	}
+	if err := c.Register("Other", ErrorDefinition{Code: 2, Name: "BUSY"}, ErrorDefinition{Code: 3, Name: "GONE"}); err == nil {
+		t.Error("Expected a conflicting name to be rejected")
+	}
+	if _, ok := c.Lookup(3); ok {
+		t.Error("Expected the errors of a rejected registration not to be added")
+	}
+	if err := c.Register("Other", ErrorDefinition{Code: 2, Name: "LOCKED"}); err != nil {
+		t.Errorf("Expected a shared code to be allowed, got %v", err)
+	}
+
+	s := NewServer()
+	s.RegisterService(new(CodedService), "")
+	s.RegisterService(&ErrorCatalogService{Server: s}, "Errors")
+	s.SetErrorCatalog(c)
+	s.RegisterCodec(MockCodec{2, 3}, "mock")
+	if w := serveMock(s, "CodedService.Fail", ""); w.Status != 400 || w.Body != "coded error" {
+		t.Errorf("Expected the catalogued error, got %d %s", w.Status, w.Body)
+	}
+	s.RegisterCodec(MockCodec{5, 3}, "mock")
+	if w := serveMock(s, "CodedService.Fail", ""); w.Status != 500 || w.Body != "rpc: error code 5 isn't in the catalog: coded error" {
+		t.Errorf("Expected an uncatalogued error, got %d %s", w.Status, w.Body)
+	}
+
+	var defs []ErrorDefinition
+	if err := (&ErrorCatalogService{Server: s}).List(nil, nil, &defs); err != nil {
+		t.Fatal(err)
+	}
+	if len(defs) != 2 || defs[0].Name != "EXPIRED" || defs[1].Name != "LOCKED" || defs[1].Service != "Coded" {
+		t.Errorf("Wrong catalog %+v", defs)
+	}
+}
@@ -0,0 +1,121 @@
+// Copyright 2009 The Go Authors. All rights reserved.
+// Copyright 2012 The Gorilla Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package chaos
+
+import (
+	"errors"
+	"math/rand"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gorilla/rpc/v2"
+)
+
+// ErrInjected is the error returned by faults without an error set.
+var ErrInjected = errors.New("rpc: injected fault")
+
+// AllMethods is the method name of faults applying to methods without
+// faults of their own.
+const AllMethods = "*"
+
+// Fault describes the faults injected in calls to a method. Percentages are
+// from 0 to 100 and are drawn independently for each call.
+type Fault struct {
+	// Latency is added before the call for LatencyPercent of the calls.
+	Latency        time.Duration
+	LatencyPercent float64
+
+	// Error is returned instead of calling the method for ErrorPercent of
+	// the calls. ErrInjected is returned if Error is nil.
+	Error        error
+	ErrorPercent float64
+
+	// The method is called but no reply is sent for DropPercent of the
+	// calls.
+	DropPercent float64
+}
+
+// Chaos injects faults in calls. Faults can be changed at any time.
+type Chaos struct {
+	mutex  sync.Mutex
+	faults map[string]Fault
+	rand   *rand.Rand
+}
+
+// New returns a Chaos without any fault.
+func New() *Chaos {
+	return &Chaos{
+		faults: make(map[string]Fault),
+		rand:   rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
+}
+
+// Set sets the faults injected in calls to method, or to all methods
+// without faults of their own if method is AllMethods.
+func (c *Chaos) Set(method string, f Fault) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.faults[method] = f
+}
+
+// Clear stops injecting faults in calls to method.
+func (c *Chaos) Clear(method string) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	delete(c.faults, method)
+}
+
+// Reset stops injecting faults in all calls.
+func (c *Chaos) Reset() {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.faults = make(map[string]Fault)
+}
+
+// draw returns the fault for method and which of its faults to inject.
+func (c *Chaos) draw(method string) (f Fault, delay, fail, drop bool) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	f, ok := c.faults[method]
+	if !ok {
+		if f, ok = c.faults[AllMethods]; !ok {
+			return f, false, false, false
+		}
+	}
+	delay = c.rand.Float64()*100 < f.LatencyPercent
+	fail = c.rand.Float64()*100 < f.ErrorPercent
+	drop = c.rand.Float64()*100 < f.DropPercent
+	return f, delay, fail, drop
+}
+
+// Middleware injects the faults, to be registered with
+// rpc.Server.RegisterMiddleware.
+func (c *Chaos) Middleware(next rpc.CallFunc) rpc.CallFunc {
+	return func(r *http.Request, method string, args, reply interface{}) error {
+		f, delay, fail, drop := c.draw(method)
+		if delay {
+			t := time.NewTimer(f.Latency)
+			select {
+			case <-t.C:
+			case <-r.Context().Done():
+				t.Stop()
+				return r.Context().Err()
+			}
+		}
+		if fail {
+			if f.Error != nil {
+				return f.Error
+			}
+			return ErrInjected
+		}
+		err := next(r, method, args, reply)
+		if drop {
+			return rpc.ErrDropReply
+		}
+		return err
+	}
+}
@@ -0,0 +1,83 @@
+// Copyright 2009 The Go Authors. All rights reserved.
+// Copyright 2012 The Gorilla Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package chaos
+
+import (
+	"bytes"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gorilla/rpc/v2"
+	"github.com/gorilla/rpc/v2/json2"
+)
+
+type Service1 struct {
+	calls int32
+}
+
+func (t *Service1) Echo(r *http.Request, req *string, res *string) error {
+	atomic.AddInt32(&t.calls, 1)
+	*res = *req
+	return nil
+}
+
+func TestChaos(t *testing.T) {
+	service := new(Service1)
+	s := rpc.NewServer()
+	s.RegisterCodec(json2.NewCodec(), "application/json")
+	s.RegisterService(service, "")
+	c := New()
+	s.RegisterMiddleware(c.Middleware)
+	ts := httptest.NewServer(s)
+	defer ts.Close()
+
+	call := func() error {
+		buf, _ := json2.EncodeClientRequest("Service1.Echo", "hi")
+		res, err := http.Post(ts.URL, "application/json", bytes.NewBuffer(buf))
+		if err != nil {
+			return err
+		}
+		defer res.Body.Close()
+		var reply string
+		return json2.DecodeClientResponse(res.Body, &reply)
+	}
+
+	if err := call(); err != nil {
+		t.Fatal(err)
+	}
+
+	c.Set(AllMethods, Fault{Latency: 50 * time.Millisecond, LatencyPercent: 100})
+	start := time.Now()
+	if err := call(); err != nil {
+		t.Fatal(err)
+	}
+	if d := time.Since(start); d < 50*time.Millisecond {
+		t.Errorf("Expected latency, call took %v", d)
+	}
+
+	c.Set("Service1.Echo", Fault{Error: errors.New("boom"), ErrorPercent: 100})
+	if err := call(); err == nil || err.(*json2.Error).Message != "boom" {
+		t.Errorf("Expected injected error, got %v", err)
+	}
+
+	c.Set("Service1.Echo", Fault{DropPercent: 100})
+	calls := atomic.LoadInt32(&service.calls)
+	if err := call(); err == nil {
+		t.Error("Expected dropped reply")
+	}
+	if atomic.LoadInt32(&service.calls) != calls+1 {
+		t.Error("Expected method to be called")
+	}
+
+	c.Reset()
+	if err := call(); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,23 @@
+// Copyright 2009 The Go Authors. All rights reserved.
+// Copyright 2012 The Gorilla Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+/*
+Package gorilla/rpc/v2/chaos injects latency, errors and dropped replies in
+calls, to test the resilience of clients without touching handler code.
+
+	c := chaos.New()
+	s.RegisterMiddleware(c.Middleware)
+
+	// Slow down 10% of all calls, and fail 5% of calls to one method.
+	c.Set(chaos.AllMethods, chaos.Fault{Latency: time.Second, LatencyPercent: 10})
+	c.Set("Orders.Create", chaos.Fault{ErrorPercent: 5})
+
+	// Back to normal.
+	c.Reset()
+
+Dropped replies abort the HTTP connection after the method is called, as if
+the reply was lost in the network.
+*/
+package chaos
@@ -0,0 +1,292 @@
+// Copyright 2009 The Go Authors. All rights reserved.
+// Copyright 2012 The Gorilla Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/printer"
+	"go/token"
+	"os"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// rpcPath is the import path of the rpc package.
+const rpcPath = "github.com/gorilla/rpc/v2"
+
+// method is a method of a service with a supported signature.
+type method struct {
+	name   string
+	args   string // type of the args, without pointer, or empty
+	reply  string // type of the reply, without pointer, or empty
+	header bool   // the method takes the http.Header of the response
+}
+
+// generator collects the methods and imports of the services.
+type generator struct {
+	fset    *token.FileSet
+	types   map[string]bool
+	methods map[string][]method
+	imports map[string]string // package name to import path
+	used    map[string]bool   // imports used by args and replies
+}
+
+// generate returns the source of the dispatch tables of the types of the
+// package in dir, skipping the output file.
+func generate(dir string, types []string, output string) ([]byte, error) {
+	g := &generator{
+		fset:    token.NewFileSet(),
+		types:   make(map[string]bool),
+		methods: make(map[string][]method),
+		imports: make(map[string]string),
+		used:    make(map[string]bool),
+	}
+	for _, t := range types {
+		g.types[strings.TrimSpace(t)] = true
+	}
+	pkgs, err := parser.ParseDir(g.fset, dir, func(fi os.FileInfo) bool {
+		return fi.Name() != output && !strings.HasSuffix(fi.Name(), "_test.go")
+	}, 0)
+	if err != nil {
+		return nil, err
+	}
+	if len(pkgs) != 1 {
+		return nil, fmt.Errorf("expected a single package in %s, found %d", dir, len(pkgs))
+	}
+	var pkgName string
+	for name, pkg := range pkgs {
+		pkgName = name
+		for _, f := range pkg.Files {
+			g.file(f)
+		}
+	}
+	for t := range g.types {
+		if len(g.methods[t]) == 0 {
+			return nil, fmt.Errorf("no suitable methods found for type %s", t)
+		}
+	}
+	return g.source(pkgName)
+}
+
+// file collects the methods of the types in f.
+func (g *generator) file(f *ast.File) {
+	imports := make(map[string]string)
+	for _, spec := range f.Imports {
+		p, _ := strconv.Unquote(spec.Path.Value)
+		name := importName(p)
+		if spec.Name != nil {
+			name = spec.Name.Name
+		}
+		imports[name] = p
+	}
+	for _, decl := range f.Decls {
+		fn, ok := decl.(*ast.FuncDecl)
+		if !ok || fn.Recv == nil || !fn.Name.IsExported() {
+			continue
+		}
+		rcvr := fn.Recv.List[0].Type
+		if star, ok := rcvr.(*ast.StarExpr); ok {
+			rcvr = star.X
+		}
+		ident, ok := rcvr.(*ast.Ident)
+		if !ok || !g.types[ident.Name] {
+			continue
+		}
+		m, ok := g.method(fn.Name.Name, fn.Type, imports)
+		if ok {
+			g.methods[ident.Name] = append(g.methods[ident.Name], m)
+		}
+	}
+}
+
+// method returns the method with the signature, and false if it isn't
+// supported.
+func (g *generator) method(name string, sig *ast.FuncType, imports map[string]string) (method, bool) {
+	m := method{name: name}
+	if sig.Results == nil || len(sig.Results.List) != 1 || len(sig.Results.List[0].Names) > 1 || g.expr(sig.Results.List[0].Type) != "error" {
+		return m, false
+	}
+	var params []ast.Expr
+	for _, field := range sig.Params.List {
+		for i := 0; i < len(field.Names) || i == 0 && len(field.Names) == 0; i++ {
+			params = append(params, field.Type)
+		}
+	}
+	if len(params) < 1 || len(params) > 4 || !g.isSelector(params[0], imports, "net/http", "Request", true) {
+		return m, false
+	}
+	if len(params) == 4 {
+		if !g.isSelector(params[3], imports, "net/http", "Header", false) {
+			return m, false
+		}
+		m.header = true
+	}
+	for i, p := range params[1:min(len(params), 3)] {
+		star, ok := p.(*ast.StarExpr)
+		if !ok {
+			return m, false
+		}
+		g.use(star.X, imports)
+		if i == 0 {
+			m.args = g.expr(star.X)
+		} else {
+			m.reply = g.expr(star.X)
+		}
+	}
+	return m, true
+}
+
+// isSelector returns true if e is the type name of the package path,
+// behind a pointer if ptr is set.
+func (g *generator) isSelector(e ast.Expr, imports map[string]string, pkgPath, name string, ptr bool) bool {
+	if ptr {
+		star, ok := e.(*ast.StarExpr)
+		if !ok {
+			return false
+		}
+		e = star.X
+	}
+	sel, ok := e.(*ast.SelectorExpr)
+	if !ok || sel.Sel.Name != name {
+		return false
+	}
+	pkg, ok := sel.X.(*ast.Ident)
+	return ok && imports[pkg.Name] == pkgPath
+}
+
+// use records the imports of the packages referenced by e.
+func (g *generator) use(e ast.Expr, imports map[string]string) {
+	ast.Inspect(e, func(n ast.Node) bool {
+		if sel, ok := n.(*ast.SelectorExpr); ok {
+			if pkg, ok := sel.X.(*ast.Ident); ok && imports[pkg.Name] != "" {
+				g.imports[pkg.Name] = imports[pkg.Name]
+				g.used[pkg.Name] = true
+			}
+			return false
+		}
+		return true
+	})
+}
+
+// expr returns the source of e.
+func (g *generator) expr(e ast.Expr) string {
+	var buf bytes.Buffer
+	printer.Fprint(&buf, g.fset, e)
+	return buf.String()
+}
+
+// source returns the formatted source of the generated file.
+func (g *generator) source(pkgName string) ([]byte, error) {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "// Code generated by rpcgen; DO NOT EDIT.\n\npackage %s\n\nimport (\n", pkgName)
+	g.imports["http"], g.used["http"] = "net/http", true
+	if pkgName != "rpc" {
+		g.imports["rpc"], g.used["rpc"] = rpcPath, true
+	}
+	var names []string
+	for name := range g.used {
+		names = append(names, name)
+	}
+	// Standard packages first, as goimports does.
+	sort.Sort(importOrder{names, g.imports})
+	for i, name := range names {
+		if i > 0 && isStd(g.imports[names[i-1]]) && !isStd(g.imports[name]) {
+			buf.WriteString("\n")
+		}
+		if importName(g.imports[name]) == name {
+			fmt.Fprintf(&buf, "\t%q\n", g.imports[name])
+		} else {
+			fmt.Fprintf(&buf, "\t%s %q\n", name, g.imports[name])
+		}
+	}
+	buf.WriteString(")\n\nfunc init() {\n")
+	var types []string
+	for t := range g.types {
+		types = append(types, t)
+	}
+	sort.Strings(types)
+	qualifier := "rpc."
+	if pkgName == "rpc" {
+		qualifier = ""
+	}
+	for _, t := range types {
+		fmt.Fprintf(&buf, "\t%sRegisterDispatchTable((*%s)(nil), %sDispatchTable{\n", qualifier, t, qualifier)
+		methods := g.methods[t]
+		sort.Sort(byName(methods))
+		for _, m := range methods {
+			fmt.Fprintf(&buf, "\t\t%q: func(rcvr interface{}, w http.ResponseWriter, r *http.Request, args, reply interface{}) error {\n", m.name)
+			in := []string{"r"}
+			if m.args != "" {
+				in = append(in, fmt.Sprintf("args.(*%s)", m.args))
+			}
+			if m.reply != "" {
+				in = append(in, fmt.Sprintf("reply.(*%s)", m.reply))
+			}
+			if m.header {
+				in = append(in, "w.Header()")
+			}
+			fmt.Fprintf(&buf, "\t\t\treturn rcvr.(*%s).%s(%s)\n\t\t},\n", t, m.name, strings.Join(in, ", "))
+		}
+		buf.WriteString("\t})\n")
+	}
+	buf.WriteString("}\n")
+	return format.Source(buf.Bytes())
+}
+
+// versionElem matches the major version elements of import paths.
+var versionElem = regexp.MustCompile(`^v[0-9]+$`)
+
+// importName returns the conventional name of the package path.
+func importName(p string) string {
+	name := path.Base(p)
+	if versionElem.MatchString(name) && path.Dir(p) != "." {
+		name = path.Base(path.Dir(p))
+	}
+	return strings.TrimPrefix(name, "go-")
+}
+
+func min(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+// isStd returns true for the import paths of standard packages.
+func isStd(path string) bool {
+	return !strings.Contains(strings.SplitN(path, "/", 2)[0], ".")
+}
+
+// importOrder sorts import names by path, standard packages first.
+type importOrder struct {
+	names   []string
+	imports map[string]string
+}
+
+func (o importOrder) Len() int      { return len(o.names) }
+func (o importOrder) Swap(i, j int) { o.names[i], o.names[j] = o.names[j], o.names[i] }
+
+func (o importOrder) Less(i, j int) bool {
+	pi, pj := o.imports[o.names[i]], o.imports[o.names[j]]
+	if isStd(pi) != isStd(pj) {
+		return isStd(pi)
+	}
+	return pi < pj
+}
+
+// byName sorts methods by name.
+type byName []method
+
+func (m byName) Len() int           { return len(m) }
+func (m byName) Less(i, j int) bool { return m[i].name < m[j].name }
+func (m byName) Swap(i, j int)      { m[i], m[j] = m[j], m[i] }
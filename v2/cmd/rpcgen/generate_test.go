@@ -0,0 +1,36 @@
+// Copyright 2009 The Go Authors. All rights reserved.
+// Copyright 2012 The Gorilla Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"flag"
+	"io/ioutil"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "update the golden files")
+
+func TestGenerate(t *testing.T) {
+	got, err := generate("testdata/users", []string{"Users"}, "rpc_dispatch.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	golden := "testdata/users/rpc_dispatch.golden"
+	if *update {
+		ioutil.WriteFile(golden, got, 0644)
+	}
+	want, err := ioutil.ReadFile(golden)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != string(want) {
+		t.Errorf("Generated:\n%s\nwant:\n%s", got, want)
+	}
+
+	if _, err := generate("testdata/users", []string{"Missing"}, "rpc_dispatch.go"); err == nil {
+		t.Error("Expected an error for a type without methods")
+	}
+}
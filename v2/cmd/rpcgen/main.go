@@ -0,0 +1,52 @@
+// Copyright 2009 The Go Authors. All rights reserved.
+// Copyright 2012 The Gorilla Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+/*
+Command rpcgen generates the dispatch tables of RPC services, so that
+servers call their methods without reflect.Call, see
+rpc.RegisterDispatchTable.
+
+Given the receiver types of the services of the package in the current
+directory, e.g. with a go:generate directive:
+
+	//go:generate rpcgen -type Users,Billing
+
+it writes rpc_dispatch.go, registering a table for each type in its init
+function. The services must be registered with a pointer receiver, e.g.
+s.RegisterService(new(Users), ""), and the file must be generated again
+when methods change. Methods taking a *rpc.ResponseMeta and promoted
+methods of embedded types aren't part of the tables: they're still called
+with reflection.
+*/
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"strings"
+)
+
+func main() {
+	types := flag.String("type", "", "comma-separated receiver types of the services")
+	output := flag.String("output", "rpc_dispatch.go", "name of the generated file")
+	flag.Parse()
+	if *types == "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+	log.SetFlags(0)
+	log.SetPrefix("rpcgen: ")
+	src, err := generate(".", strings.Split(*types, ","), *output)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := ioutil.WriteFile(*output, src, 0644); err != nil {
+		log.Fatal(err)
+	}
+	fmt.Fprintf(os.Stderr, "rpcgen: wrote %s\n", *output)
+}
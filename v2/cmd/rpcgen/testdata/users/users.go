@@ -0,0 +1,33 @@
+package users
+
+import (
+	"net/http"
+
+	"github.com/gorilla/rpc/v2"
+	"github.com/gorilla/rpc/v2/json2"
+	t "time"
+)
+
+type Users struct{}
+
+type GetArgs struct{ ID int }
+
+type User struct{ Name string }
+
+func (u *Users) Get(r *http.Request, args *GetArgs, reply *User) error { return nil }
+
+func (u *Users) Touch(r *http.Request, args *GetArgs) error { return nil }
+
+func (u *Users) Ping(r *http.Request) error { return nil }
+
+func (u *Users) Since(r *http.Request, args *t.Time, reply *[]User, h http.Header) error { return nil }
+
+func (u *Users) Fail(r *http.Request, args, reply *json2.Error) error { return nil }
+
+func (u *Users) Meta(r *http.Request, args *GetArgs, reply *User, meta *rpc.ResponseMeta) error {
+	return nil
+}
+
+func (u *Users) Count() int { return 0 }
+
+func (u *Users) unexported(r *http.Request, args *GetArgs, reply *User) error { return nil }
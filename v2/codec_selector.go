@@ -0,0 +1,84 @@
+// Copyright 2009 The Go Authors. All rights reserved.
+// Copyright 2012 The Gorilla Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package rpc
+
+import (
+	"net/http"
+	"path"
+	"strings"
+)
+
+// RegisterCodecSelector registers a function choosing the codec of each
+// request, instead of its "Content-Type" header. It returns the content
+// type the codec was registered with, or an empty string to select the
+// codec by the header. For instance, to serve JSON-RPC 1.0 under /v1 and
+// JSON-RPC 2.0 under /v2 with the same content type:
+//
+//	s.RegisterCodec(json.NewCodec(), "application/x-json-rpc-1")
+//	s.RegisterCodec(json2.NewCodec(), "application/json")
+//	s.RegisterCodecSelector(func(r *http.Request) string {
+//		if strings.HasPrefix(r.URL.Path, "/v1/") {
+//			return "application/x-json-rpc-1"
+//		}
+//		return ""
+//	})
+//
+// The content type of the Route of a request, if any, takes precedence.
+//
+// Note: Only one function can be registered, subsequent calls to this
+// method will overwrite all the previous functions.
+func (s *Server) RegisterCodecSelector(f func(r *http.Request) string) {
+	s.codecSelector = f
+}
+
+// codecPattern is a content type with wildcards and its codec.
+type codecPattern struct {
+	pattern string
+	codec   Codec
+}
+
+// selectCodec returns the codec of the request and its content type, or a
+// nil codec if none matches.
+func (s *Server) selectCodec(r *http.Request) (Codec, string) {
+	if route, ok := RouteFrom(r.Context()); ok && route.ContentType != "" {
+		return s.codec(route.ContentType), route.ContentType
+	}
+	if s.codecSelector != nil {
+		if contentType := s.codecSelector(r); contentType != "" {
+			return s.codec(contentType), contentType
+		}
+	}
+	contentType := mediaType(r.Header.Get("Content-Type"))
+	if contentType == "" && s.codecCount == 1 {
+		// If Content-Type is not set and only one codec has been registered,
+		// then default to that codec.
+		return s.lastCodec, contentType
+	}
+	return s.codec(contentType), contentType
+}
+
+// codec returns the codec registered for the content type, or nil.
+func (s *Server) codec(contentType string) Codec {
+	contentType = strings.ToLower(contentType)
+	if codec, ok := s.codecs[contentType]; ok {
+		return codec
+	}
+	for _, p := range s.codecPatterns {
+		if ok, _ := path.Match(p.pattern, contentType); ok {
+			return p.codec
+		}
+	}
+	return nil
+}
+
+// mediaType returns the media type of a "Content-Type" header, without
+// its parameters.
+func mediaType(contentType string) string {
+	if i := strings.Index(contentType, ";"); i != -1 {
+		contentType = contentType[:i]
+	}
+	return strings.TrimSpace(contentType)
+}
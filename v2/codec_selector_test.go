@@ -0,0 +1,66 @@
+// Copyright 2009 The Go Authors. All rights reserved.
+// Copyright 2012 The Gorilla Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package rpc
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestCodecSelector(t *testing.T) {
+	s := NewServer()
+	s.RegisterService(new(Service1), "")
+	s.RegisterCodec(MockCodec{2, 3}, "mock")
+	s.RegisterCodec(MockCodec{4, 5}, "mock-v2")
+	s.RegisterCodecSelector(func(r *http.Request) string {
+		if r.Header.Get("X-Version") == "2" {
+			return "mock-v2"
+		}
+		return ""
+	})
+	for _, test := range []struct {
+		version string
+		body    string
+	}{
+		{"", "6"},
+		{"2", "20"},
+	} {
+		r, _ := http.NewRequest("POST", "Service1.Multiply", nil)
+		r.Header.Set("Content-Type", "mock")
+		r.Header.Set("X-Version", test.version)
+		w := NewMockResponseWriter()
+		s.ServeHTTP(w, r)
+		if w.Body != test.body {
+			t.Errorf("Response was %q, should be %q.", w.Body, test.body)
+		}
+	}
+}
+
+func TestCodecAliases(t *testing.T) {
+	s := NewServer()
+	s.RegisterService(new(Service1), "")
+	s.RegisterCodec(MockCodec{2, 3}, "application/json", "application/json-rpc", "application/vnd.*+json")
+	for _, test := range []struct {
+		contentType string
+		status      int
+	}{
+		{"", 200},
+		{"application/json", 200},
+		{"Application/JSON ; charset=utf-8", 200},
+		{"application/json-rpc", 200},
+		{"application/vnd.myco+json", 200},
+		{"application/vnd.myco+xml", 415},
+		{"text/json", 415},
+	} {
+		r, _ := http.NewRequest("POST", "Service1.Multiply", nil)
+		r.Header.Set("Content-Type", test.contentType)
+		w := NewMockResponseWriter()
+		s.ServeHTTP(w, r)
+		if w.Status != test.status {
+			t.Errorf("Status for %q was %d, should be %d.", test.contentType, w.Status, test.status)
+		}
+	}
+}
@@ -0,0 +1,30 @@
+// Copyright 2009 The Go Authors. All rights reserved.
+// Copyright 2012 The Gorilla Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build brotli
+// +build brotli
+
+package compression
+
+import (
+	"io"
+
+	"github.com/andybalholm/brotli"
+	"github.com/gorilla/rpc/v2"
+)
+
+// RegisterBrotli registers the "br" content encoding with the quality, from
+// brotli.BestSpeed to brotli.BestCompression.
+func RegisterBrotli(quality int) {
+	rpc.RegisterCompression("br", func(w io.Writer) (rpc.Compressor, error) {
+		return brotli.NewWriterLevel(w, quality), nil
+	})
+}
+
+// NewBrotliReader returns a reader decompressing the "br" responses, for
+// clients.
+func NewBrotliReader(r io.Reader) io.Reader {
+	return brotli.NewReader(r)
+}
@@ -0,0 +1,57 @@
+// Copyright 2009 The Go Authors. All rights reserved.
+// Copyright 2012 The Gorilla Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build brotli && zstd
+// +build brotli,zstd
+
+package compression
+
+import (
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/andybalholm/brotli"
+	"github.com/gorilla/rpc/v2"
+	"github.com/klauspost/compress/zstd"
+)
+
+func TestCompression(t *testing.T) {
+	RegisterBrotli(brotli.DefaultCompression)
+	RegisterZstd(zstd.SpeedDefault)
+	body := strings.Repeat(`{"jsonrpc":"2.0","result":{"name":"item"},"id":1}`, 100)
+	for _, test := range []struct {
+		accept, encoding string
+		decompress       func(r io.Reader) (io.Reader, error)
+	}{
+		{"gzip, br, zstd", "zstd", func(r io.Reader) (io.Reader, error) { return NewZstdReader(r) }},
+		{"zstd;q=0.5, br", "br", func(r io.Reader) (io.Reader, error) { return NewBrotliReader(r), nil }},
+	} {
+		// Twice, to reuse the pooled compressors.
+		for i := 0; i < 2; i++ {
+			r, _ := http.NewRequest("POST", "/", nil)
+			r.Header.Set("Accept-Encoding", test.accept)
+			w := httptest.NewRecorder()
+			(&rpc.CompressionSelector{}).Select(r).Encode(w).Write([]byte(body))
+			if encoding := w.Header().Get("Content-Encoding"); encoding != test.encoding {
+				t.Fatalf("Expected %s for %q, got %s", test.encoding, test.accept, encoding)
+			}
+			if w.Body.Len() >= len(body) {
+				t.Errorf("Expected the %s body to be compressed, got %d bytes", test.encoding, w.Body.Len())
+			}
+			dr, err := test.decompress(w.Body)
+			if err != nil {
+				t.Fatal(err)
+			}
+			b, err := ioutil.ReadAll(dr)
+			if err != nil || string(b) != body {
+				t.Errorf("Wrong %s body: %v", test.encoding, err)
+			}
+		}
+	}
+}
@@ -0,0 +1,26 @@
+// Copyright 2009 The Go Authors. All rights reserved.
+// Copyright 2012 The Gorilla Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+/*
+Package gorilla/rpc/v2/compression registers the brotli and zstd content
+encodings, negotiated by rpc.CompressionSelector along with gzip and
+deflate.
+
+The brotli encoding is based on github.com/andybalholm/brotli and only
+built with the "brotli" build tag, the zstd encoding on
+github.com/klauspost/compress/zstd with the "zstd" build tag:
+
+	func init() {
+		compression.RegisterZstd(zstd.SpeedDefault)
+		compression.RegisterBrotli(brotli.DefaultCompression)
+	}
+
+	s := rpc.NewServer()
+	s.RegisterCodec(json2.NewCustomCodec(&rpc.CompressionSelector{}), "application/json")
+
+Compressors are pooled by the server and reused across responses, as
+their allocation dominates the cost of compressing small responses.
+*/
+package compression
@@ -0,0 +1,35 @@
+// Copyright 2009 The Go Authors. All rights reserved.
+// Copyright 2012 The Gorilla Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build zstd
+// +build zstd
+
+package compression
+
+import (
+	"io"
+
+	"github.com/gorilla/rpc/v2"
+	"github.com/klauspost/compress/zstd"
+)
+
+// RegisterZstd registers the "zstd" content encoding with the level, e.g.
+// zstd.SpeedDefault. Each compressor encodes on a single goroutine, as
+// responses are compressed concurrently.
+func RegisterZstd(level zstd.EncoderLevel) {
+	rpc.RegisterCompression("zstd", func(w io.Writer) (rpc.Compressor, error) {
+		return zstd.NewWriter(w, zstd.WithEncoderLevel(level), zstd.WithEncoderConcurrency(1))
+	})
+}
+
+// NewZstdReader returns a reader decompressing the "zstd" responses, for
+// clients. It must be closed to release its resources.
+func NewZstdReader(r io.Reader) (io.ReadCloser, error) {
+	d, err := zstd.NewReader(r, zstd.WithDecoderConcurrency(1))
+	if err != nil {
+		return nil, err
+	}
+	return d.IOReadCloser(), nil
+}
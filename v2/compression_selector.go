@@ -8,73 +8,168 @@ package rpc
 import (
 	"compress/flate"
 	"compress/gzip"
+	"errors"
 	"io"
+	"io/ioutil"
 	"net/http"
+	"strconv"
 	"strings"
-	"unicode"
+	"sync"
 )
 
-// gzipWriter writes and closes the gzip writer.
-type gzipWriter struct {
-	w *gzip.Writer
+// Compressor is a compressing writer that can be reused with Reset, such
+// as gzip.Writer, or the brotli and zstd writers of the compress package.
+type Compressor interface {
+	io.WriteCloser
+	// Reset discards the state of the compressor, to write to w.
+	Reset(w io.Writer)
 }
 
-func (gw *gzipWriter) Write(p []byte) (n int, err error) {
-	defer gw.w.Close()
-	return gw.w.Write(p)
+// compression is a content encoding with its pool of compressors.
+type compression struct {
+	encoding string
+	pool     sync.Pool
 }
 
-// gzipEncoder implements the gzip compressed http encoder.
-type gzipEncoder struct {
-}
+var compressions = struct {
+	sync.RWMutex
+	byEncoding map[string]*compression
+}{byEncoding: make(map[string]*compression)}
 
-func (enc *gzipEncoder) Encode(w http.ResponseWriter) io.Writer {
-	w.Header().Set("Content-Encoding", "gzip")
-	return &gzipWriter{gzip.NewWriter(w)}
+// RegisterCompression registers the function creating the compressors of a
+// content encoding, e.g. "br" or "zstd", negotiated by the
+// CompressionSelector from the "Accept-Encoding" header of the requests.
+// Compressors are pooled and reused with Reset. The gzip and deflate
+// encodings are registered by default.
+//
+// It must be called before serving requests, e.g. in an init function.
+func RegisterCompression(encoding string, newCompressor func(w io.Writer) (Compressor, error)) {
+	c := &compression{encoding: encoding}
+	c.pool.New = func() interface{} {
+		compressor, err := newCompressor(ioutil.Discard)
+		if err != nil {
+			return err
+		}
+		return compressor
+	}
+	compressions.Lock()
+	defer compressions.Unlock()
+	compressions.byEncoding[strings.ToLower(encoding)] = c
 }
 
-// flateWriter writes and closes the flate writer.
-type flateWriter struct {
-	w *flate.Writer
+func init() {
+	RegisterCompression("gzip", func(w io.Writer) (Compressor, error) {
+		return gzip.NewWriter(w), nil
+	})
+	RegisterCompression("deflate", func(w io.Writer) (Compressor, error) {
+		return flate.NewWriter(w, flate.DefaultCompression)
+	})
 }
 
-func (fw *flateWriter) Write(p []byte) (n int, err error) {
-	defer fw.w.Close()
-	return fw.w.Write(p)
+// errCompressed is returned by the writes following the first one to a
+// compressed response, as its compressor was released.
+var errCompressed = errors.New("rpc: compressed response already written")
+
+// compressWriter compresses the response with a pooled compressor, which
+// is closed and released after the first write.
+type compressWriter struct {
+	c          *compression
+	compressor Compressor
 }
 
-// flateEncoder implements the flate compressed http encoder.
-type flateEncoder struct {
+func (cw *compressWriter) Write(p []byte) (int, error) {
+	if cw.compressor == nil {
+		return 0, errCompressed
+	}
+	defer func() {
+		cw.c.pool.Put(cw.compressor)
+		cw.compressor = nil
+	}()
+	n, err := cw.compressor.Write(p)
+	if errClose := cw.compressor.Close(); err == nil {
+		err = errClose
+	}
+	return n, err
 }
 
-func (enc *flateEncoder) Encode(w http.ResponseWriter) io.Writer {
-	fw, err := flate.NewWriter(w, flate.DefaultCompression)
-	if err != nil {
+// Encode sets the Content-Encoding header of the response, and returns
+// the writer compressing it. The response isn't compressed if no
+// compressor can be created.
+func (c *compression) Encode(w http.ResponseWriter) io.Writer {
+	compressor, ok := c.pool.Get().(Compressor)
+	if !ok {
 		return w
 	}
-	w.Header().Set("Content-Encoding", "deflate")
-	return &flateWriter{fw}
+	compressor.Reset(w)
+	w.Header().Set("Content-Encoding", c.encoding)
+	w.Header().Add("Vary", "Accept-Encoding")
+	return &compressWriter{c: c, compressor: compressor}
 }
 
+// DefaultCompressionPreference is the order in which the
+// CompressionSelector prefers the encodings accepted as much by a client.
+var DefaultCompressionPreference = []string{"zstd", "br", "gzip", "deflate"}
+
 // CompressionSelector generates the compressed http encoder.
 type CompressionSelector struct {
+	// Preference is the order in which the registered encodings accepted
+	// with the same quality by a client are chosen, the others coming
+	// after them. If empty, DefaultCompressionPreference is used.
+	Preference []string
 }
 
 // Select method selects the correct compression encoder based on http HEADER.
-func (*CompressionSelector) Select(r *http.Request) Encoder {
-	encHeader := r.Header.Get("Accept-Encoding")
-	encTypes := strings.FieldsFunc(encHeader, func(r rune) bool {
-		return unicode.IsSpace(r) || r == ','
-	})
-
-	for _, enc := range encTypes {
-		switch enc {
-		case "gzip":
-			return &gzipEncoder{}
-		case "deflate":
-			return &flateEncoder{}
+//
+// The registered encoding with the highest quality value in the
+// "Accept-Encoding" header is chosen, e.g. "br" for "gzip;q=0.8, br".
+func (s *CompressionSelector) Select(r *http.Request) Encoder {
+	preference := s.Preference
+	if len(preference) == 0 {
+		preference = DefaultCompressionPreference
+	}
+	rank := func(encoding string) int {
+		for i, e := range preference {
+			if e == encoding {
+				return i
+			}
+		}
+		return len(preference)
+	}
+	compressions.RLock()
+	defer compressions.RUnlock()
+	var best *compression
+	var bestQ float64
+	for _, part := range strings.Split(r.Header.Get("Accept-Encoding"), ",") {
+		encoding, q := parseQuality(part)
+		c := compressions.byEncoding[encoding]
+		if c == nil || q <= 0 {
+			continue
+		}
+		if best == nil || q > bestQ || (q == bestQ && rank(encoding) < rank(best.encoding)) {
+			best, bestQ = c, q
 		}
 	}
+	if best == nil {
+		return DefaultEncoder
+	}
+	return best
+}
 
-	return DefaultEncoder
+// parseQuality returns the lowercase encoding of an element of the
+// "Accept-Encoding" header, and its quality value, 1 by default.
+func parseQuality(part string) (string, float64) {
+	fields := strings.Split(part, ";")
+	encoding := strings.ToLower(strings.TrimSpace(fields[0]))
+	q := 1.0
+	for _, param := range fields[1:] {
+		param = strings.TrimSpace(param)
+		if strings.HasPrefix(param, "q=") {
+			v, err := strconv.ParseFloat(param[2:], 64)
+			if err != nil {
+				return encoding, 0
+			}
+			q = v
+		}
+	}
+	return encoding, q
 }
@@ -0,0 +1,80 @@
+// Copyright 2009 The Go Authors. All rights reserved.
+// Copyright 2012 The Gorilla Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package rpc
+
+import (
+	"compress/gzip"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+)
+
+// identityCompressor counts its creations, to check that they're reused.
+type identityCompressor struct {
+	w io.Writer
+}
+
+func (c *identityCompressor) Write(p []byte) (int, error) { return c.w.Write(p) }
+func (c *identityCompressor) Close() error                { return nil }
+func (c *identityCompressor) Reset(w io.Writer)           { c.w = w }
+
+func TestCompressionSelector(t *testing.T) {
+	var created int32
+	RegisterCompression("x-test", func(w io.Writer) (Compressor, error) {
+		atomic.AddInt32(&created, 1)
+		return &identityCompressor{w}, nil
+	})
+	selector := &CompressionSelector{Preference: []string{"x-test", "gzip"}}
+	for _, test := range []struct {
+		accept, encoding string
+	}{
+		{"", ""},
+		{"identity", ""},
+		{"gzip;q=0", ""},
+		{"deflate, gzip", "gzip"},
+		{"deflate, GZIP;q=0.5", "deflate"},
+		{"gzip, x-test", "x-test"},
+		{"gzip, x-test;q=0.9", "gzip"},
+	} {
+		r, _ := http.NewRequest("POST", "/", nil)
+		r.Header.Set("Accept-Encoding", test.accept)
+		w := httptest.NewRecorder()
+		selector.Select(r).Encode(w).Write([]byte("hello"))
+		if encoding := w.Header().Get("Content-Encoding"); encoding != test.encoding {
+			t.Errorf("Expected %q for %q, got %q", test.encoding, test.accept, encoding)
+		}
+		if test.encoding == "gzip" {
+			gr, err := gzip.NewReader(w.Body)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if b, _ := ioutil.ReadAll(gr); string(b) != "hello" {
+				t.Errorf("Wrong gzip body %q", b)
+			}
+		}
+	}
+
+	r, _ := http.NewRequest("POST", "/", nil)
+	r.Header.Set("Accept-Encoding", "x-test")
+	for i := 0; i < 100; i++ {
+		w := httptest.NewRecorder()
+		out := selector.Select(r).Encode(w)
+		out.Write([]byte("hello"))
+		if _, err := out.Write([]byte("again")); err != errCompressed {
+			t.Errorf("Expected a second write to fail, got %v", err)
+		}
+		if w.Body.String() != "hello" {
+			t.Errorf("Wrong body %q", w.Body.String())
+		}
+	}
+	// The race detector makes sync.Pool drop some of the released ones.
+	if n := atomic.LoadInt32(&created); n > 50 {
+		t.Errorf("Expected the compressors to be reused, %d were created", n)
+	}
+}
@@ -0,0 +1,396 @@
+// Copyright 2009 The Go Authors. All rights reserved.
+// Copyright 2012 The Gorilla Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package rpc
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ErrForbidden is returned to the client when its address isn't allowed to
+// call a method.
+var ErrForbidden = errors.New("rpc: caller not allowed")
+
+// ErrInsufficientScope is returned to the client when it lacks a scope
+// required to call a method.
+var ErrInsufficientScope = errors.New("rpc: insufficient scope")
+
+// Duration is a time.Duration encoded in JSON as a string, e.g. "1.5s".
+type Duration time.Duration
+
+// MarshalJSON encodes the duration as a string.
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+// UnmarshalJSON decodes a duration string or a number of nanoseconds.
+func (d *Duration) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		var n int64
+		if err := json.Unmarshal(b, &n); err != nil {
+			return fmt.Errorf("rpc: invalid duration %s", b)
+		}
+		*d = Duration(n)
+		return nil
+	}
+	v, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	*d = Duration(v)
+	return nil
+}
+
+// MethodConfig holds the runtime settings of a method.
+type MethodConfig struct {
+	// Disabled switches the method off: calls return ErrMethodDisabled.
+	Disabled bool `json:"disabled,omitempty"`
+	// Timeout sets a deadline on the request context of each call.
+	Timeout Duration `json:"timeout,omitempty"`
+	// RateLimit is the number of calls per second allowed, with bursts of
+	// up to RateBurst calls. Zero means no limit. Exceeding calls return
+	// ErrRateLimited. Responses carry the Throttle headers of the limit.
+	RateLimit float64 `json:"rateLimit,omitempty"`
+	RateBurst int     `json:"rateBurst,omitempty"`
+	// Allow restricts the callers to the given IPs or CIDR networks, e.g.
+	// "10.0.0.0/8". Other callers get ErrForbidden.
+	Allow []string `json:"allow,omitempty"`
+	// Scopes are the scopes required to call the method, all of them,
+	// among those returned by the function registered with
+	// RegisterScopeFunc. Other callers get ErrInsufficientScope.
+	Scopes []string `json:"scopes,omitempty"`
+	// CacheTTL makes successful responses cacheable by the client for
+	// that long, with a "Cache-Control: private, max-age" header.
+	CacheTTL Duration `json:"cacheTTL,omitempty"`
+
+	allow []*net.IPNet
+}
+
+// Config is the runtime configuration of a server. It can be swapped at
+// any time with SetConfig or from a file with WatchConfigFile; requests in
+// flight finish under the configuration they started with.
+type Config struct {
+	// Default applies to methods not listed in Methods.
+	Default MethodConfig `json:"default"`
+	// Methods holds the settings per method, as in "Service.Method".
+	Methods map[string]*MethodConfig `json:"methods"`
+}
+
+// method returns the settings of a method.
+func (c *Config) method(method string) *MethodConfig {
+	if m := c.Methods[method]; m != nil {
+		return m
+	}
+	return &c.Default
+}
+
+// compile parses the allowed networks.
+func (c *Config) compile() error {
+	compile := func(m *MethodConfig) error {
+		m.allow = nil
+		for _, a := range m.Allow {
+			_, network, err := net.ParseCIDR(a)
+			if err != nil {
+				ip := net.ParseIP(a)
+				if ip == nil {
+					return fmt.Errorf("rpc: invalid address %q", a)
+				}
+				network = &net.IPNet{IP: ip, Mask: net.CIDRMask(len(ip)*8, len(ip)*8)}
+			}
+			m.allow = append(m.allow, network)
+		}
+		return nil
+	}
+	if err := compile(&c.Default); err != nil {
+		return err
+	}
+	for _, m := range c.Methods {
+		if err := compile(m); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// allows returns true if the remote address of r may call the method.
+func (m *MethodConfig) allows(r *http.Request) bool {
+	if len(m.allow) == 0 {
+		return true
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return false
+	}
+	for _, network := range m.allow {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// hasScopes returns true if the caller has all the scopes.
+func (s *Server) hasScopes(r *http.Request, scopes []string) bool {
+	if len(scopes) == 0 {
+		return true
+	}
+	if s.scopeFunc == nil {
+		return false
+	}
+	granted := s.scopeFunc(r)
+	for _, scope := range scopes {
+		found := false
+		for _, g := range granted {
+			if g == scope {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// RegisterScopeFunc registers the function returning the scopes granted to
+// the caller of a request, e.g. from its OAuth token, checked against the
+// Scopes of the Config.
+//
+// Note: Only one function can be registered, subsequent calls to this
+// method will overwrite all the previous functions.
+func (s *Server) RegisterScopeFunc(f func(r *http.Request) []string) {
+	s.scopeFunc = f
+}
+
+// CheckConfig returns the sorted methods of the config that aren't
+// registered, most likely misspelled, so that they can be reported at
+// startup.
+func (s *Server) CheckConfig(c *Config) []string {
+	var unknown []string
+	for method := range c.Methods {
+		if !s.HasMethod(method) {
+			unknown = append(unknown, method)
+		}
+	}
+	sort.Strings(unknown)
+	return unknown
+}
+
+// UnknownMethodError reports a method of a Config that isn't registered.
+type UnknownMethodError struct {
+	Method string
+}
+
+func (e *UnknownMethodError) Error() string {
+	return fmt.Sprintf("rpc: config of unknown method %q", e.Method)
+}
+
+// reportUnknownMethods passes the unknown methods of c to onError.
+func (s *Server) reportUnknownMethods(c *Config, onError func(error)) {
+	if onError == nil {
+		return
+	}
+	for _, method := range s.CheckConfig(c) {
+		onError(&UnknownMethodError{Method: method})
+	}
+}
+
+// SetConfig atomically replaces the runtime configuration of the server.
+// A nil config removes all runtime settings.
+func (s *Server) SetConfig(c *Config) error {
+	if c != nil {
+		if err := c.compile(); err != nil {
+			return err
+		}
+	}
+	s.config.Store(c)
+	return nil
+}
+
+// UpdateMethodConfig replaces the configuration with a copy where update
+// has changed the settings of the method, starting from the default ones
+// if it had none, e.g. to adjust a rate limit at runtime.
+func (s *Server) UpdateMethodConfig(method string, update func(m *MethodConfig)) error {
+	s.configMutex.Lock()
+	defer s.configMutex.Unlock()
+	c := new(Config)
+	if old := s.Config(); old != nil {
+		*c = *old
+	}
+	// The settings are copied, as SetConfig compiles them.
+	methods := make(map[string]*MethodConfig, len(c.Methods)+1)
+	for name, m := range c.Methods {
+		dup := *m
+		methods[name] = &dup
+	}
+	m := methods[method]
+	if m == nil {
+		dup := c.Default
+		m = &dup
+		methods[method] = m
+	}
+	update(m)
+	c.Methods = methods
+	return s.SetConfig(c)
+}
+
+// Config returns the current runtime configuration, or nil.
+func (s *Server) Config() *Config {
+	c, _ := s.config.Load().(*Config)
+	return c
+}
+
+// configFormats convert config files to JSON, by extension.
+var configFormats = make(map[string]func([]byte) ([]byte, error))
+
+// RegisterConfigFormat registers the function converting config files with
+// the extension to JSON, to be read by LoadConfigFile. For YAML, with
+// sigs.k8s.io/yaml:
+//
+//	rpc.RegisterConfigFormat(".yaml", yaml.YAMLToJSON)
+//	rpc.RegisterConfigFormat(".yml", yaml.YAMLToJSON)
+//
+// It must be called before loading configs, e.g. in an init function.
+func RegisterConfigFormat(ext string, toJSON func([]byte) ([]byte, error)) {
+	configFormats[strings.ToLower(ext)] = toJSON
+}
+
+// LoadConfigFile reads a Config from a file, in JSON or in a format
+// registered with RegisterConfigFormat for its extension. Unknown settings
+// are rejected, with Go 1.10 and later.
+func LoadConfigFile(path string) (*Config, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if toJSON := configFormats[strings.ToLower(filepath.Ext(path))]; toJSON != nil {
+		if b, err = toJSON(b); err != nil {
+			return nil, fmt.Errorf("rpc: invalid config %s: %v", path, err)
+		}
+	}
+	c := new(Config)
+	dec := json.NewDecoder(bytes.NewReader(b))
+	disallowUnknownFields(dec)
+	if err := dec.Decode(c); err != nil {
+		return nil, fmt.Errorf("rpc: invalid config %s: %v", path, err)
+	}
+	return c, nil
+}
+
+// WatchConfigFile loads the configuration from a file, see LoadConfigFile,
+// and reloads it each time the file changes, checking every interval,
+// until the returned function is called. Errors while reloading are passed
+// to onError, if not nil, and the previous configuration is kept. Methods
+// of the configuration that aren't registered, see CheckConfig, are also
+// passed to onError, as UnknownMethodErrors, but don't prevent loading
+// it.
+func (s *Server) WatchConfigFile(path string, interval time.Duration, onError func(error)) (stop func(), err error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	c, err := LoadConfigFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.SetConfig(c); err != nil {
+		return nil, err
+	}
+	s.reportUnknownMethods(c, onError)
+	done := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		modTime, size := info.ModTime(), info.Size()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+			}
+			info, err := os.Stat(path)
+			if err == nil && info.ModTime().Equal(modTime) && info.Size() == size {
+				continue
+			}
+			if err == nil {
+				modTime, size = info.ModTime(), info.Size()
+				var c *Config
+				if c, err = LoadConfigFile(path); err == nil {
+					if err = s.SetConfig(c); err == nil {
+						s.reportUnknownMethods(c, onError)
+					}
+				}
+			}
+			if err != nil && onError != nil {
+				onError(err)
+			}
+		}
+	}()
+	return func() { close(done) }, nil
+}
+
+// applyConfig enforces the runtime settings of the method on a request. It
+// returns the request to use, with a deadline if the method has a timeout,
+// a function releasing its resources, and an error if the call is
+// rejected, with its HTTP status. The throttling headers of rate limits
+// are set on the response header h.
+func (s *Server) applyConfig(h http.Header, r *http.Request, method string) (*http.Request, func(), int, error) {
+	c := s.Config()
+	if c == nil {
+		return r, func() {}, 0, nil
+	}
+	m := c.method(method)
+	if m.Disabled {
+		return r, func() {}, http.StatusForbidden, ErrMethodDisabled
+	}
+	if !m.allows(r) {
+		return r, func() {}, http.StatusForbidden, ErrForbidden
+	}
+	if !s.hasScopes(r, m.Scopes) {
+		return r, func() {}, http.StatusForbidden, ErrInsufficientScope
+	}
+	if m.RateLimit > 0 {
+		allowed, t := s.allow(r.Context(), method, m.RateLimit, m.RateBurst)
+		t.SetHeaders(h)
+		if !allowed {
+			return r, func() {}, http.StatusTooManyRequests, ErrRateLimited
+		}
+	}
+	if m.Timeout > 0 {
+		ctx, cancel := context.WithTimeout(r.Context(), time.Duration(m.Timeout))
+		return r.WithContext(ctx), cancel, 0, nil
+	}
+	return r, func() {}, 0, nil
+}
+
+// cacheControl sets the Cache-Control header of the successful responses of
+// methods with a CacheTTL.
+func (s *Server) cacheControl(h http.Header, method string) {
+	c := s.Config()
+	if c == nil {
+		return
+	}
+	if ttl := time.Duration(c.method(method).CacheTTL); ttl > 0 {
+		h.Set("Cache-Control", fmt.Sprintf("private, max-age=%d", int64(ttl/time.Second)))
+	}
+}
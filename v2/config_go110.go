@@ -0,0 +1,19 @@
+// Copyright 2009 The Go Authors. All rights reserved.
+// Copyright 2012 The Gorilla Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build go1.10
+// +build go1.10
+
+package rpc
+
+import "encoding/json"
+
+// rejectsUnknownFields is true if LoadConfigFile rejects unknown settings.
+const rejectsUnknownFields = true
+
+// disallowUnknownFields makes dec reject unknown settings.
+func disallowUnknownFields(dec *json.Decoder) {
+	dec.DisallowUnknownFields()
+}
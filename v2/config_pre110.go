@@ -0,0 +1,18 @@
+// Copyright 2009 The Go Authors. All rights reserved.
+// Copyright 2012 The Gorilla Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build !go1.10
+// +build !go1.10
+
+package rpc
+
+import "encoding/json"
+
+// rejectsUnknownFields is true if LoadConfigFile rejects unknown settings.
+const rejectsUnknownFields = false
+
+// disallowUnknownFields does nothing: json.Decoder.DisallowUnknownFields
+// needs Go 1.10, before which unknown settings are ignored.
+func disallowUnknownFields(dec *json.Decoder) {}
@@ -0,0 +1,173 @@
+// Copyright 2009 The Go Authors. All rights reserved.
+// Copyright 2012 The Gorilla Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package rpc
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func serveMock(s *Server, method, remoteAddr string) *MockResponseWriter {
+	r, _ := http.NewRequest("POST", method, nil)
+	r.Header.Set("Content-Type", "mock")
+	r.RemoteAddr = remoteAddr
+	w := NewMockResponseWriter()
+	s.ServeHTTP(w, r)
+	return w
+}
+
+func TestSetConfig(t *testing.T) {
+	s := NewServer()
+	s.RegisterService(new(Service1), "")
+	s.RegisterCodec(MockCodec{2, 3}, "mock")
+
+	err := s.SetConfig(&Config{
+		Methods: map[string]*MethodConfig{
+			"Service1.Multiply":            {RateLimit: 0.001, RateBurst: 1},
+			"Service1.MultiplyWithHeaders": {Allow: []string{"10.0.0.0/8"}},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if w := serveMock(s, "Service1.Multiply", "1.2.3.4:1234"); w.Status != 200 {
+		t.Errorf("Status was %d, should be 200.", w.Status)
+	}
+	if w := serveMock(s, "Service1.Multiply", "1.2.3.4:1234"); w.Status != 429 || w.Body != ErrRateLimited.Error() {
+		t.Errorf("Expected rate limited call, got %d %s", w.Status, w.Body)
+	}
+	if w := serveMock(s, "Service1.MultiplyWithHeaders", "1.2.3.4:1234"); w.Status != 403 || w.Body != ErrForbidden.Error() {
+		t.Errorf("Expected forbidden call, got %d %s", w.Status, w.Body)
+	}
+	if w := serveMock(s, "Service1.MultiplyWithHeaders", "10.1.2.3:1234"); w.Status != 200 {
+		t.Errorf("Status was %d, should be 200.", w.Status)
+	}
+
+	if err := s.SetConfig(&Config{Default: MethodConfig{Allow: []string{"nope"}}}); err == nil {
+		t.Errorf("Expected error for invalid address")
+	}
+	if err := s.SetConfig(&Config{Default: MethodConfig{Disabled: true}}); err != nil {
+		t.Fatal(err)
+	}
+	if w := serveMock(s, "Service1.Multiply", "1.2.3.4:1234"); w.Status != 403 || w.Body != ErrMethodDisabled.Error() {
+		t.Errorf("Expected disabled method, got %d %s", w.Status, w.Body)
+	}
+	s.SetConfig(nil)
+	if w := serveMock(s, "Service1.Multiply", "1.2.3.4:1234"); w.Status != 200 {
+		t.Errorf("Status was %d, should be 200.", w.Status)
+	}
+}
+
+func TestWatchConfigFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "rpc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "config.json")
+	if err := ioutil.WriteFile(path, []byte(`{"methods": {"Service1.Multiply": {"timeout": "1s"}}}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	s := NewServer()
+	stop, err := s.WatchConfigFile(path, 5*time.Millisecond, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stop()
+	if c := s.Config(); c == nil || c.Methods["Service1.Multiply"].Timeout != Duration(time.Second) {
+		t.Fatalf("Wrong config: %+v", c)
+	}
+
+	if err := ioutil.WriteFile(path, []byte(`{"default": {"disabled": true}}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 100; i++ {
+		if c := s.Config(); c.Default.Disabled {
+			return
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	t.Errorf("Expected config to be reloaded")
+}
+
+func TestConfigScopes(t *testing.T) {
+	s := NewServer()
+	s.RegisterService(new(Service1), "")
+	s.RegisterCodec(MockCodec{2, 3}, "mock")
+	s.SetConfig(&Config{
+		Methods: map[string]*MethodConfig{
+			"Service1.Multiply": {Scopes: []string{"read", "write"}, CacheTTL: Duration(90 * time.Second)},
+		},
+	})
+	if w := serveMock(s, "Service1.Multiply", ""); w.Status != 403 || w.Body != ErrInsufficientScope.Error() {
+		t.Errorf("Expected insufficient scope without a scope function, got %d %s", w.Status, w.Body)
+	}
+	var granted []string
+	s.RegisterScopeFunc(func(r *http.Request) []string {
+		return granted
+	})
+	granted = []string{"read"}
+	if w := serveMock(s, "Service1.Multiply", ""); w.Status != 403 {
+		t.Errorf("Expected insufficient scope, got %d", w.Status)
+	}
+	granted = []string{"write", "admin", "read"}
+	w := serveMock(s, "Service1.Multiply", "")
+	if w.Status != 200 || w.Header().Get("Cache-Control") != "private, max-age=90" {
+		t.Errorf("Expected a cacheable response, got %d %v", w.Status, w.Header())
+	}
+}
+
+func TestLoadConfigFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "rpc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	write := func(name, config string) string {
+		path := filepath.Join(dir, name)
+		if err := ioutil.WriteFile(path, []byte(config), 0644); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+
+	// A made up format, to stand for YAML.
+	RegisterConfigFormat(".conf", func(b []byte) ([]byte, error) {
+		return bytes.Replace(b, []byte("'"), []byte(`"`), -1), nil
+	})
+	defer delete(configFormats, ".conf")
+	c, err := LoadConfigFile(write("config.conf", `{'methods': {'Service1.Multiply': {'scopes': ['read']}}}`))
+	if err != nil || c.Methods["Service1.Multiply"].Scopes[0] != "read" {
+		t.Fatalf("Wrong config %+v, %v", c, err)
+	}
+	if _, err := LoadConfigFile(write("typo.json", `{"methods": {"Service1.Multiply": {"timout": "1s"}}}`)); err == nil && rejectsUnknownFields {
+		t.Error("Expected an error for an unknown setting")
+	}
+
+	s := NewServer()
+	s.RegisterService(new(Service1), "")
+	c = &Config{Methods: map[string]*MethodConfig{"Service1.Multiply": {}, "Service1.Multipy": {}, "Nope.Get": {}}}
+	if unknown := s.CheckConfig(c); len(unknown) != 2 || unknown[0] != "Nope.Get" || unknown[1] != "Service1.Multipy" {
+		t.Errorf("Wrong unknown methods %v", unknown)
+	}
+	var errs []error
+	stop, err := s.WatchConfigFile(write("watched.json", `{"methods": {"Nope.Get": {}}}`), time.Hour, func(err error) {
+		errs = append(errs, err)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	stop()
+	if e, ok := errs[0].(*UnknownMethodError); len(errs) != 1 || !ok || e.Method != "Nope.Get" {
+		t.Errorf("Expected the unknown method to be reported, got %v", errs)
+	}
+}
@@ -0,0 +1,205 @@
+// Copyright 2009 The Go Authors. All rights reserved.
+// Copyright 2012 The Gorilla Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package rpc
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// IdempotencyKeyHeader carries the id of a request that clients may retry.
+// Requests with the same id are processed once when a DedupStore is set.
+const IdempotencyKeyHeader = "Idempotency-Key"
+
+// ReplayedHeader is set in responses replayed from a DedupStore.
+const ReplayedHeader = "X-Rpc-Replayed"
+
+// DedupStore stores the responses of processed requests by id, so that the
+// server can replay them for duplicate requests instead of calling the
+// methods again, e.g. when a client retries after a timeout or a broker
+// redelivers a message.
+type DedupStore interface {
+	// Seen returns the response stored for id, and true if the request was
+	// already processed.
+	Seen(ctx context.Context, id string) (response []byte, ok bool, err error)
+	// MarkDone stores the response of the request with id.
+	MarkDone(ctx context.Context, id string, response []byte) error
+}
+
+// dedup is the DedupStore of a server and the requests in progress.
+type dedup struct {
+	store    DedupStore
+	mutex    sync.Mutex
+	inflight map[string]chan struct{}
+}
+
+// SetDedupStore sets the store consulted for requests with an id: the one
+// carried by the request context, see NewIdempotencyContext, or else the
+// one in the IdempotencyKeyHeader. Duplicate requests get the stored
+// response, and concurrent duplicates wait for the first one to finish.
+//
+// Responses with a 5xx or 429 status aren't stored, so requests that failed
+// on transient errors can be retried, nor are 304 responses, which depend
+// on the "If-None-Match" header of the request.
+func (s *Server) SetDedupStore(store DedupStore) {
+	s.dedup.store = store
+}
+
+// idempotencyKey returns the id of the request for deduplication.
+func idempotencyKey(r *http.Request) string {
+	if key := IdempotencyKeyFromContext(r.Context()); key != "" {
+		return key
+	}
+	return r.Header.Get(IdempotencyKeyHeader)
+}
+
+// serveDedup serves a request with an id, replaying the stored response if
+// it was already processed.
+func (s *Server) serveDedup(w http.ResponseWriter, r *http.Request, key string) {
+	d := &s.dedup
+	defer d.acquire(key)()
+	res, ok, err := d.store.Seen(r.Context(), key)
+	if err != nil {
+		WriteError(w, http.StatusServiceUnavailable, "rpc: dedup store: "+err.Error())
+		return
+	}
+	if ok {
+		if contentType := r.Header.Get("Content-Type"); contentType != "" {
+			w.Header().Set("Content-Type", contentType)
+		}
+		w.Header().Set(ReplayedHeader, "true")
+		w.Write(res)
+		return
+	}
+	var status int
+	if mw, ok := w.(*messageWriter); ok {
+		s.serveHTTP(mw, r)
+		status, res = mw.status, mw.body.Bytes()
+	} else {
+		dw := &dedupWriter{ResponseWriter: w}
+		s.serveHTTP(dw, r)
+		status, res = dw.status, dw.body.Bytes()
+	}
+	if status >= 500 || status == http.StatusTooManyRequests || status == http.StatusNotModified {
+		return
+	}
+	// The response was sent: a failure to store it only loses the dedup.
+	d.store.MarkDone(r.Context(), key, res)
+}
+
+// acquire waits for the request in progress with the same id, if any, and
+// returns the function releasing the id.
+func (d *dedup) acquire(key string) (release func()) {
+	for {
+		d.mutex.Lock()
+		wait, ok := d.inflight[key]
+		if !ok {
+			if d.inflight == nil {
+				d.inflight = make(map[string]chan struct{})
+			}
+			done := make(chan struct{})
+			d.inflight[key] = done
+			d.mutex.Unlock()
+			return func() {
+				d.mutex.Lock()
+				delete(d.inflight, key)
+				d.mutex.Unlock()
+				close(done)
+			}
+		}
+		d.mutex.Unlock()
+		<-wait
+	}
+}
+
+// dedupWriter is an http.ResponseWriter keeping a copy of the response.
+type dedupWriter struct {
+	http.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+func (w *dedupWriter) Write(p []byte) (int, error) {
+	w.body.Write(p)
+	return w.ResponseWriter.Write(p)
+}
+
+func (w *dedupWriter) WriteHeader(status int) {
+	w.status = status
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *dedupWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+type idempotencyKeyKey struct{}
+
+// NewIdempotencyContext returns a copy of ctx carrying the id of a request.
+// Transports use it to pass the id of the messages they receive to the
+// server, and clients to send it in the IdempotencyKeyHeader.
+func NewIdempotencyContext(ctx context.Context, key string) context.Context {
+	return context.WithValue(ctx, idempotencyKeyKey{}, key)
+}
+
+// IdempotencyKeyFromContext returns the request id carried by ctx, or an
+// empty string.
+func IdempotencyKeyFromContext(ctx context.Context) string {
+	key, _ := ctx.Value(idempotencyKeyKey{}).(string)
+	return key
+}
+
+// MemoryDedupStore is a DedupStore keeping the responses in memory for a
+// period of time.
+type MemoryDedupStore struct {
+	ttl     time.Duration
+	mutex   sync.Mutex
+	entries map[string]dedupEntry
+}
+
+type dedupEntry struct {
+	response []byte
+	expires  time.Time
+}
+
+// NewMemoryDedupStore returns a MemoryDedupStore keeping the responses for
+// ttl, which should exceed the time during which requests may be retried.
+func NewMemoryDedupStore(ttl time.Duration) *MemoryDedupStore {
+	return &MemoryDedupStore{ttl: ttl, entries: make(map[string]dedupEntry)}
+}
+
+// Seen returns the response stored for id, if it didn't expire.
+func (m *MemoryDedupStore) Seen(ctx context.Context, id string) ([]byte, bool, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	e, ok := m.entries[id]
+	if !ok || time.Now().After(e.expires) {
+		return nil, false, nil
+	}
+	return e.response, true, nil
+}
+
+// MarkDone stores the response for id, removing the expired ones.
+func (m *MemoryDedupStore) MarkDone(ctx context.Context, id string, response []byte) error {
+	now := time.Now()
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	for k, e := range m.entries {
+		if now.After(e.expires) {
+			delete(m.entries, k)
+		}
+	}
+	m.entries[id] = dedupEntry{
+		response: append([]byte(nil), response...),
+		expires:  now.Add(m.ttl),
+	}
+	return nil
+}
@@ -0,0 +1,59 @@
+// Copyright 2009 The Go Authors. All rights reserved.
+// Copyright 2012 The Gorilla Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package rpc
+
+import (
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestDedupStore(t *testing.T) {
+	s := NewServer()
+	s.RegisterCodec(MockCodec{A: 2, B: 3}, "mock")
+	s.RegisterService(new(Service1), "")
+	s.SetDedupStore(NewMemoryDedupStore(time.Minute))
+	calls := 0
+	s.RegisterMiddleware(func(next CallFunc) CallFunc {
+		return func(r *http.Request, method string, args, reply interface{}) error {
+			calls++
+			return next(r, method, args, reply)
+		}
+	})
+	serve := func(key string) *MockResponseWriter {
+		r, _ := http.NewRequest("POST", "Service1.Multiply", nil)
+		r.Header.Set("Content-Type", "mock")
+		if key != "" {
+			r.Header.Set(IdempotencyKeyHeader, key)
+		}
+		w := NewMockResponseWriter()
+		s.ServeHTTP(w, r)
+		return w
+	}
+
+	if w := serve("a"); w.Body != "6" || w.header.Get(ReplayedHeader) != "" {
+		t.Errorf("Wrong first response: %q %v", w.Body, w.header)
+	}
+	if w := serve("a"); w.Body != "6" || w.header.Get(ReplayedHeader) != "true" {
+		t.Errorf("Expected replayed response, got %q %v", w.Body, w.header)
+	}
+	if calls != 1 {
+		t.Errorf("Expected 1 call, got %d", calls)
+	}
+	serve("b")
+	serve("")
+	serve("")
+	if calls != 4 {
+		t.Errorf("Expected 4 calls, got %d", calls)
+	}
+
+	r, _ := http.NewRequest("POST", "Service1.Multiply", nil)
+	r.Header.Set("Content-Type", "mock")
+	r = r.WithContext(NewIdempotencyContext(r.Context(), "a"))
+	if res := s.ServeMessage(r, nil); string(res) != "6" || calls != 4 {
+		t.Errorf("Expected replayed message, got %q after %d calls", res, calls)
+	}
+}
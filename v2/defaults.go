@@ -0,0 +1,210 @@
+// Copyright 2009 The Go Authors. All rights reserved.
+// Copyright 2012 The Gorilla Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package rpc
+
+import (
+	"encoding"
+	"fmt"
+	"math"
+	"reflect"
+	"strconv"
+	"time"
+)
+
+// DefaultTag is the struct tag setting the default value of a field, e.g.:
+//
+//	type ListArgs struct {
+//		Limit   int           `default:"10"`
+//		Timeout time.Duration `default:"5s"`
+//		Order   *string       `default:"asc"`
+//	}
+//
+// The server sets the defaults after decoding the args and before
+// validating them, whatever the codec, so that methods don't have to check
+// for the optional fields. Defaults are set in the fields with zero
+// values: pointer fields allow clients to send zero values explicitly, as
+// only nil pointers are set.
+//
+// Defaults are parsed as the JSON codecs decode strings: fields may be
+// strings, booleans, numbers, durations such as "1m30s", or types
+// implementing encoding.TextUnmarshaler, e.g. UUID.
+const DefaultTag = "default"
+
+var (
+	typeOfDuration        = reflect.TypeOf(time.Duration(0))
+	typeOfTextUnmarshaler = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+)
+
+// ParseDefault returns the value of type t, or of its element type if t is
+// a pointer, for the default in the struct tag of a field.
+func ParseDefault(t reflect.Type, value string) (reflect.Value, error) {
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	v := reflect.New(t).Elem()
+	if reflect.PtrTo(t).Implements(typeOfTextUnmarshaler) {
+		err := v.Addr().Interface().(encoding.TextUnmarshaler).UnmarshalText([]byte(value))
+		return v, err
+	}
+	var err error
+	switch t.Kind() {
+	case reflect.String:
+		v.SetString(value)
+	case reflect.Bool:
+		var b bool
+		b, err = strconv.ParseBool(value)
+		v.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		var i int64
+		if t == typeOfDuration {
+			var d time.Duration
+			d, err = time.ParseDuration(value)
+			i = int64(d)
+		} else {
+			i, err = strconv.ParseInt(value, 10, t.Bits())
+		}
+		v.SetInt(i)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		var u uint64
+		u, err = strconv.ParseUint(value, 10, t.Bits())
+		v.SetUint(u)
+	case reflect.Float32, reflect.Float64:
+		var f float64
+		f, err = strconv.ParseFloat(value, t.Bits())
+		v.SetFloat(f)
+	default:
+		err = fmt.Errorf("rpc: default values of type %v aren't supported", t)
+	}
+	return v, err
+}
+
+// applyDefaults sets the defaults of the zero fields of v.
+func applyDefaults(v reflect.Value) error {
+	if !hasDefaults(v.Type()) {
+		return nil
+	}
+	return setDefaults(v)
+}
+
+func setDefaults(v reflect.Value) error {
+	switch v.Kind() {
+	case reflect.Ptr:
+		if v.IsNil() {
+			return nil
+		}
+		return setDefaults(v.Elem())
+	case reflect.Slice, reflect.Array:
+		if !hasDefaults(v.Type().Elem()) {
+			return nil
+		}
+		for i := 0; i < v.Len(); i++ {
+			if err := setDefaults(v.Index(i)); err != nil {
+				return err
+			}
+		}
+	case reflect.Struct:
+		t := v.Type()
+		for i := 0; i < t.NumField(); i++ {
+			sf := t.Field(i)
+			if sf.PkgPath != "" && !sf.Anonymous {
+				continue
+			}
+			f := v.Field(i)
+			if value, ok := sf.Tag.Lookup(DefaultTag); ok && isZero(f) {
+				d, err := ParseDefault(sf.Type, value)
+				if err != nil {
+					return fmt.Errorf("rpc: default of field %s: %v", sf.Name, err)
+				}
+				if f.Kind() == reflect.Ptr {
+					f.Set(reflect.New(d.Type()))
+					f = f.Elem()
+				}
+				f.Set(d)
+				continue
+			}
+			if err := setDefaults(f); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+var defaultTypes typeCache
+
+// hasDefaults returns true if values of type t may contain fields with a
+// default.
+func hasDefaults(t reflect.Type) bool {
+	if has, ok := defaultTypes.load(t); ok {
+		return has
+	}
+	has := findTag(t, DefaultTag, make(map[reflect.Type]bool))
+	defaultTypes.store(t, has)
+	return has
+}
+
+// checkDefaultTags returns an error if the defaults of fields of t can't
+// be parsed.
+func checkDefaultTags(t reflect.Type, visited map[reflect.Type]bool) error {
+	if !hasDefaults(t) || visited[t] {
+		return nil
+	}
+	visited[t] = true
+	switch t.Kind() {
+	case reflect.Ptr, reflect.Slice, reflect.Array, reflect.Map:
+		return checkDefaultTags(t.Elem(), visited)
+	case reflect.Struct:
+		for i := 0; i < t.NumField(); i++ {
+			sf := t.Field(i)
+			if value, ok := sf.Tag.Lookup(DefaultTag); ok {
+				if _, err := ParseDefault(sf.Type, value); err != nil {
+					return fmt.Errorf("default of field %s: %v", sf.Name, err)
+				}
+			}
+			if err := checkDefaultTags(sf.Type, visited); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// isZero returns true if v is the zero value of its type, as the IsZero
+// method of reflect.Value added in Go 1.13.
+func isZero(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Bool:
+		return !v.Bool()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return v.Int() == 0
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return v.Uint() == 0
+	case reflect.Float32, reflect.Float64:
+		return math.Float64bits(v.Float()) == 0
+	case reflect.Complex64, reflect.Complex128:
+		c := v.Complex()
+		return math.Float64bits(real(c)) == 0 && math.Float64bits(imag(c)) == 0
+	case reflect.String:
+		return v.Len() == 0
+	case reflect.Chan, reflect.Func, reflect.Interface, reflect.Map, reflect.Ptr, reflect.Slice, reflect.UnsafePointer:
+		return v.IsNil()
+	case reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			if !isZero(v.Index(i)) {
+				return false
+			}
+		}
+		return true
+	case reflect.Struct:
+		for i := 0; i < v.NumField(); i++ {
+			if !isZero(v.Field(i)) {
+				return false
+			}
+		}
+		return true
+	}
+	return false
+}
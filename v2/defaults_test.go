@@ -0,0 +1,43 @@
+// Copyright 2009 The Go Authors. All rights reserved.
+// Copyright 2012 The Gorilla Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package rpc
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+type PageArgs struct {
+	Limit   int           `default:"10"`
+	Order   *string       `default:"asc"`
+	Timeout time.Duration `default:"1m30s"`
+	Strict  bool          `default:"true"`
+	Owner   UUID          `default:"6ba7b810-9dad-11d1-80b4-00c04fd430c8"`
+	Nested  []PageArgs
+}
+
+func TestApplyDefaults(t *testing.T) {
+	desc := "desc"
+	args := &PageArgs{Limit: 5, Order: &desc, Nested: []PageArgs{{}}}
+	if err := applyDefaults(reflect.ValueOf(args)); err != nil {
+		t.Fatal(err)
+	}
+	if args.Limit != 5 || *args.Order != "desc" || args.Timeout != 90*time.Second || !args.Strict ||
+		args.Owner.String() != "6ba7b810-9dad-11d1-80b4-00c04fd430c8" {
+		t.Errorf("Wrong defaults %+v", args)
+	}
+	if nested := args.Nested[0]; nested.Limit != 10 || nested.Order == nil || *nested.Order != "asc" {
+		t.Errorf("Wrong nested defaults %+v", nested)
+	}
+
+	type badArgs struct {
+		Limit int `default:"ten"`
+	}
+	if err := checkDefaultTags(reflect.TypeOf(badArgs{}), make(map[reflect.Type]bool)); err == nil {
+		t.Error("Expected an error for an invalid default")
+	}
+}
@@ -0,0 +1,314 @@
+// Copyright 2009 The Go Authors. All rights reserved.
+// Copyright 2012 The Gorilla Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package rpc
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrorDetail is a typed detail of an error, encoded as the details of a
+// google.rpc.Status: a JSON object with the "@type" of the message, as in
+// the JSON mapping of protobuf Any.
+type ErrorDetail interface {
+	// TypeURL returns the type of the message, e.g.
+	// "type.googleapis.com/google.rpc.BadRequest".
+	TypeURL() string
+}
+
+// BadRequest describes the invalid fields of a request, as
+// google.rpc.BadRequest.
+type BadRequest struct {
+	FieldViolations []FieldViolation `json:"fieldViolations"`
+}
+
+// FieldViolation is an invalid field of a BadRequest, with the dotted path
+// of the field, e.g. "address.city".
+type FieldViolation struct {
+	Field       string `json:"field"`
+	Description string `json:"description"`
+}
+
+// TypeURL returns the type of google.rpc.BadRequest.
+func (*BadRequest) TypeURL() string {
+	return "type.googleapis.com/google.rpc.BadRequest"
+}
+
+// RetryInfo tells when the client may retry a call, as google.rpc.RetryInfo.
+type RetryInfo struct {
+	RetryDelay time.Duration
+}
+
+// TypeURL returns the type of google.rpc.RetryInfo.
+func (*RetryInfo) TypeURL() string {
+	return "type.googleapis.com/google.rpc.RetryInfo"
+}
+
+// MarshalJSON encodes the delay as a protobuf Duration, e.g. "1.5s".
+func (d *RetryInfo) MarshalJSON() ([]byte, error) {
+	delay := strconv.FormatFloat(d.RetryDelay.Seconds(), 'f', -1, 64) + "s"
+	return json.Marshal(struct {
+		RetryDelay string `json:"retryDelay"`
+	}{delay})
+}
+
+// UnmarshalJSON decodes the delay from a protobuf Duration.
+func (d *RetryInfo) UnmarshalJSON(b []byte) error {
+	var v struct {
+		RetryDelay string `json:"retryDelay"`
+	}
+	if err := json.Unmarshal(b, &v); err != nil {
+		return err
+	}
+	if v.RetryDelay == "" {
+		d.RetryDelay = 0
+		return nil
+	}
+	seconds, err := strconv.ParseFloat(strings.TrimSuffix(v.RetryDelay, "s"), 64)
+	if err != nil || !strings.HasSuffix(v.RetryDelay, "s") {
+		return fmt.Errorf("rpc: invalid retry delay %q", v.RetryDelay)
+	}
+	d.RetryDelay = time.Duration(seconds * float64(time.Second))
+	return nil
+}
+
+// QuotaFailure describes the quotas a call exceeded, as
+// google.rpc.QuotaFailure.
+type QuotaFailure struct {
+	Violations []QuotaViolation `json:"violations"`
+}
+
+// QuotaViolation is an exceeded quota of a QuotaFailure, e.g. the subject
+// "tenant:acme".
+type QuotaViolation struct {
+	Subject     string `json:"subject"`
+	Description string `json:"description"`
+}
+
+// TypeURL returns the type of google.rpc.QuotaFailure.
+func (*QuotaFailure) TypeURL() string {
+	return "type.googleapis.com/google.rpc.QuotaFailure"
+}
+
+// RawDetail is a detail of a decoded Status whose type isn't known.
+type RawDetail struct {
+	Type string
+	// JSON is the encoded message, with its "@type".
+	JSON json.RawMessage
+}
+
+// TypeURL returns the type of the message.
+func (d *RawDetail) TypeURL() string {
+	return d.Type
+}
+
+// MarshalJSON returns the encoded message.
+func (d *RawDetail) MarshalJSON() ([]byte, error) {
+	return d.JSON, nil
+}
+
+// detailTypes create the known details from their type.
+var detailTypes = map[string]func() ErrorDetail{
+	(*BadRequest)(nil).TypeURL():   func() ErrorDetail { return new(BadRequest) },
+	(*RetryInfo)(nil).TypeURL():    func() ErrorDetail { return new(RetryInfo) },
+	(*QuotaFailure)(nil).TypeURL(): func() ErrorDetail { return new(QuotaFailure) },
+}
+
+// detailedError is an error with details, see WithDetails.
+type detailedError struct {
+	error
+	details []ErrorDetail
+}
+
+func (e *detailedError) Unwrap() error {
+	return e.error
+}
+
+// HTTPStatus returns the status of the wrapped error, if any, so that
+// details don't change it.
+func (e *detailedError) HTTPStatus() int {
+	for err := e.error; err != nil; err = unwrap(err) {
+		if s, ok := err.(StatusError); ok {
+			return s.HTTPStatus()
+		}
+	}
+	return http.StatusBadRequest
+}
+
+// WithDetails returns an error wrapping err with the details, encoded by
+// the codecs along with it, e.g.:
+//
+//	return rpc.WithDetails(ErrTooManyItems, &rpc.QuotaFailure{
+//		Violations: []rpc.QuotaViolation{{Subject: "user:" + id, Description: "100 items max"}},
+//	})
+func WithDetails(err error, details ...ErrorDetail) error {
+	return &detailedError{error: err, details: details}
+}
+
+// ErrorDetails returns the details of the errors wrapped by err, outermost
+// first.
+func ErrorDetails(err error) []ErrorDetail {
+	var details []ErrorDetail
+	for err != nil {
+		if e, ok := err.(*detailedError); ok {
+			details = append(details, e.details...)
+		}
+		err = unwrap(err)
+	}
+	return details
+}
+
+// Status is an error in the JSON mapping of google.rpc.Status, with a code
+// of the google.rpc.Code enum, see StatusCode.
+type Status struct {
+	Code    int           `json:"code"`
+	Message string        `json:"message"`
+	Details []ErrorDetail `json:"details,omitempty"`
+}
+
+// NewStatus returns the Status of the error of a call with the HTTP
+// status, with its details.
+func NewStatus(status int, err error) *Status {
+	return &Status{
+		Code:    StatusCode(status),
+		Message: err.Error(),
+		Details: ErrorDetails(err),
+	}
+}
+
+func (s *Status) Error() string {
+	return s.Message
+}
+
+// UnmarshalJSON decodes a Status, with the known details decoded into
+// their types and the others into RawDetails.
+func (s *Status) UnmarshalJSON(b []byte) error {
+	var v struct {
+		Code    int               `json:"code"`
+		Message string            `json:"message"`
+		Details []json.RawMessage `json:"details"`
+	}
+	if err := json.Unmarshal(b, &v); err != nil {
+		return err
+	}
+	details, err := DecodeDetails(v.Details)
+	if err != nil {
+		return err
+	}
+	s.Code, s.Message, s.Details = v.Code, v.Message, details
+	return nil
+}
+
+// DecodeDetails decodes encoded details, e.g. from the data of a JSON-RPC
+// error, with the known ones decoded into their types and the others into
+// RawDetails.
+func DecodeDetails(encoded []json.RawMessage) ([]ErrorDetail, error) {
+	var details []ErrorDetail
+	for _, raw := range encoded {
+		var header struct {
+			Type string `json:"@type"`
+		}
+		if err := json.Unmarshal(raw, &header); err != nil {
+			return nil, err
+		}
+		newDetail := detailTypes[header.Type]
+		if newDetail == nil {
+			details = append(details, &RawDetail{Type: header.Type, JSON: raw})
+			continue
+		}
+		d := newDetail()
+		if err := json.Unmarshal(raw, d); err != nil {
+			return nil, err
+		}
+		details = append(details, d)
+	}
+	return details, nil
+}
+
+// EncodeDetails encodes the details as protobuf Any messages, each with
+// its "@type".
+func EncodeDetails(details []ErrorDetail) ([]json.RawMessage, error) {
+	encoded := make([]json.RawMessage, len(details))
+	for i, d := range details {
+		if raw, ok := d.(*RawDetail); ok {
+			encoded[i] = raw.JSON
+			continue
+		}
+		b, err := json.Marshal(d)
+		if err != nil {
+			return nil, err
+		}
+		if len(b) < 2 || b[0] != '{' {
+			return nil, fmt.Errorf("rpc: detail %s isn't an object", d.TypeURL())
+		}
+		typ, _ := json.Marshal(d.TypeURL())
+		var buf bytes.Buffer
+		buf.WriteString(`{"@type":`)
+		buf.Write(typ)
+		if body := bytes.TrimSpace(b[1:]); len(body) > 1 {
+			buf.WriteByte(',')
+		}
+		buf.Write(b[1:])
+		encoded[i] = buf.Bytes()
+	}
+	return encoded, nil
+}
+
+// MarshalJSON encodes the Status with the "@type" of its details.
+func (s *Status) MarshalJSON() ([]byte, error) {
+	details, err := EncodeDetails(s.Details)
+	if err != nil {
+		return nil, err
+	}
+	if len(details) == 0 {
+		details = nil
+	}
+	return json.Marshal(struct {
+		Code    int               `json:"code"`
+		Message string            `json:"message"`
+		Details []json.RawMessage `json:"details,omitempty"`
+	}{s.Code, s.Message, details})
+}
+
+// StatusCode returns the google.rpc.Code of an HTTP status, as gRPC
+// gateways map them, e.g. 3 (INVALID_ARGUMENT) for 400 Bad Request.
+func StatusCode(status int) int {
+	switch status {
+	case http.StatusOK:
+		return 0
+	case http.StatusBadRequest:
+		return 3
+	case http.StatusUnauthorized:
+		return 16
+	case http.StatusForbidden:
+		return 7
+	case http.StatusNotFound:
+		return 5
+	case http.StatusConflict:
+		return 10
+	case http.StatusPreconditionFailed:
+		return 9
+	case http.StatusTooManyRequests:
+		return 8
+	case 499:
+		return 1
+	case http.StatusNotImplemented:
+		return 12
+	case http.StatusServiceUnavailable:
+		return 14
+	case http.StatusGatewayTimeout:
+		return 4
+	}
+	if status >= 500 {
+		return 13
+	}
+	return 2
+}
@@ -0,0 +1,75 @@
+// Copyright 2009 The Go Authors. All rights reserved.
+// Copyright 2012 The Gorilla Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package rpc
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+type quotaError struct{}
+
+func (quotaError) Error() string   { return "quota exceeded" }
+func (quotaError) HTTPStatus() int { return 429 }
+
+// wrappedError wraps an error with a message prefix.
+type wrappedError struct {
+	prefix string
+	err    error
+}
+
+func (e *wrappedError) Error() string { return e.prefix + e.err.Error() }
+func (e *wrappedError) Unwrap() error { return e.err }
+
+func TestStatus(t *testing.T) {
+	err := WithDetails(quotaError{},
+		&QuotaFailure{Violations: []QuotaViolation{{Subject: "tenant:acme", Description: "100 calls per day"}}},
+		&RetryInfo{RetryDelay: 1500 * time.Millisecond},
+	)
+	detailed := WithDetails(err, &BadRequest{FieldViolations: []FieldViolation{{Field: "a", Description: "too big"}}})
+	err = &wrappedError{"wrapped: ", detailed}
+	wrapped := false
+	for e := err; e != nil; e = unwrap(e) {
+		wrapped = wrapped || e == quotaError{}
+	}
+	if !wrapped {
+		t.Error("Expected the details to wrap the error")
+	}
+	if s, ok := detailed.(StatusError); !ok || s.HTTPStatus() != 429 {
+		t.Error("Expected the details to keep the status of the error")
+	}
+
+	b, errJSON := json.Marshal(NewStatus(429, err))
+	if errJSON != nil {
+		t.Fatal(errJSON)
+	}
+	const expected = `{"code":8,"message":"wrapped: quota exceeded","details":[` +
+		`{"@type":"type.googleapis.com/google.rpc.BadRequest","fieldViolations":[{"field":"a","description":"too big"}]},` +
+		`{"@type":"type.googleapis.com/google.rpc.QuotaFailure","violations":[{"subject":"tenant:acme","description":"100 calls per day"}]},` +
+		`{"@type":"type.googleapis.com/google.rpc.RetryInfo","retryDelay":"1.5s"}]}`
+	if string(b) != expected {
+		t.Errorf("Expected %s, got %s", expected, b)
+	}
+
+	b = []byte(`{"code":8,"message":"m","details":[{"@type":"type.googleapis.com/google.rpc.RetryInfo","retryDelay":"2s"},{"@type":"example.com/Other","x":1}]}`)
+	var status Status
+	if err := json.Unmarshal(b, &status); err != nil {
+		t.Fatal(err)
+	}
+	if len(status.Details) != 2 {
+		t.Fatalf("Wrong details %+v", status.Details)
+	}
+	if info, ok := status.Details[0].(*RetryInfo); !ok || info.RetryDelay != 2*time.Second {
+		t.Errorf("Wrong retry info %+v", status.Details[0])
+	}
+	if raw, ok := status.Details[1].(*RawDetail); !ok || raw.Type != "example.com/Other" {
+		t.Errorf("Wrong raw detail %+v", status.Details[1])
+	}
+	if again, _ := json.Marshal(&status); string(again) != string(b) {
+		t.Errorf("Expected %s, got %s", b, again)
+	}
+}
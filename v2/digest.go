@@ -0,0 +1,108 @@
+// Copyright 2009 The Go Authors. All rights reserved.
+// Copyright 2012 The Gorilla Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package rpc
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"hash"
+	"hash/crc32"
+	"io/ioutil"
+	"net/http"
+	"strings"
+)
+
+// DigestHeader is the header carrying the checksums of request bodies, in
+// the format of RFC 9530, e.g.:
+//
+//	Content-Digest: sha-256=:X48E9qOokqqrvdts8nOJRJN3OWDUoyWxBf7kbu9DBPE=:
+//
+// The "sha-256" and "crc32c" algorithms are supported.
+const DigestHeader = "Content-Digest"
+
+var crc32c = crc32.MakeTable(crc32.Castagnoli)
+
+// digests are the supported digest algorithms.
+var digests = map[string]func() hash.Hash{
+	"sha-256": sha256.New,
+	"crc32c":  func() hash.Hash { return crc32.New(crc32c) },
+}
+
+// EnableBodyVerification makes the server verify the bodies of the
+// requests before decoding them: their length must match their
+// Content-Length, and their checksums the ones of the DigestHeader, if
+// sent. Corrupted requests, e.g. truncated by flaky mobile networks, are
+// rejected with a 400 status instead of being decoded. Requests without a
+// DigestHeader, or with only unsupported algorithms, are only checked
+// against their Content-Length.
+//
+// Bodies are fully buffered: limit their size, e.g. with
+// http.MaxBytesReader, when verifying them.
+func (s *Server) EnableBodyVerification(enabled bool) {
+	s.verifyBody = enabled
+}
+
+// SetContentDigest sets the DigestHeader of a request with the "sha-256"
+// checksum of its body, for servers verifying the bodies.
+func SetContentDigest(h http.Header, body []byte) {
+	sum := sha256.Sum256(body)
+	h.Set(DigestHeader, "sha-256=:"+base64.StdEncoding.EncodeToString(sum[:])+":")
+}
+
+// verifyBody reads the body of r and verifies it, returning a copy of r
+// whose body is read from the buffered bytes.
+func verifyBody(r *http.Request) (*http.Request, error) {
+	var body []byte
+	if r.Body != nil {
+		var err error
+		body, err = ioutil.ReadAll(r.Body)
+		r.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("rpc: reading body: %v", err)
+		}
+	}
+	if r.ContentLength >= 0 && int64(len(body)) != r.ContentLength {
+		return nil, fmt.Errorf("rpc: body of %d bytes doesn't match Content-Length %d", len(body), r.ContentLength)
+	}
+	if err := checkDigest(r.Header.Get(DigestHeader), body); err != nil {
+		return nil, err
+	}
+	verified := *r
+	verified.Body = ioutil.NopCloser(bytes.NewReader(body))
+	return &verified, nil
+}
+
+// checkDigest checks the body against the supported digests of the
+// header value.
+func checkDigest(header string, body []byte) error {
+	if header == "" {
+		return nil
+	}
+	for _, member := range strings.Split(header, ",") {
+		parts := strings.SplitN(strings.TrimSpace(member), "=", 2)
+		if len(parts) != 2 {
+			continue
+		}
+		alg := strings.ToLower(parts[0])
+		newHash, ok := digests[alg]
+		if !ok {
+			continue
+		}
+		value := strings.Trim(parts[1], ":")
+		want, err := base64.StdEncoding.DecodeString(value)
+		if err != nil {
+			return fmt.Errorf("rpc: invalid %s digest %q", alg, value)
+		}
+		h := newHash()
+		h.Write(body)
+		if !bytes.Equal(h.Sum(nil), want) {
+			return fmt.Errorf("rpc: body doesn't match its %s digest", alg)
+		}
+	}
+	return nil
+}
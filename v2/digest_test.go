@@ -0,0 +1,58 @@
+// Copyright 2009 The Go Authors. All rights reserved.
+// Copyright 2012 The Gorilla Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package rpc
+
+import (
+	"encoding/base64"
+	"encoding/binary"
+	"hash/crc32"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestBodyVerification(t *testing.T) {
+	s := NewServer()
+	s.RegisterCodec(MockCodec{A: 1, B: 2}, "mock")
+	s.RegisterService(new(Service1), "")
+	s.EnableBodyVerification(true)
+
+	serve := func(body string, length int64, digest string) *MockResponseWriter {
+		r, _ := http.NewRequest("POST", "Service1.Multiply", strings.NewReader(body))
+		r.Header.Set("Content-Type", "mock")
+		r.ContentLength = length
+		if digest != "" {
+			r.Header.Set(DigestHeader, digest)
+		}
+		w := NewMockResponseWriter()
+		s.ServeHTTP(w, r)
+		return w
+	}
+	body := "payload"
+	h := make(http.Header)
+	SetContentDigest(h, []byte(body))
+	sha := h.Get(DigestHeader)
+	var sum [4]byte
+	binary.BigEndian.PutUint32(sum[:], crc32.Checksum([]byte(body), crc32.MakeTable(crc32.Castagnoli)))
+	crc := "crc32c=:" + base64.StdEncoding.EncodeToString(sum[:]) + ":"
+
+	for _, test := range []struct {
+		length int64
+		digest string
+		status int
+	}{
+		{7, "", http.StatusOK},
+		{-1, sha, http.StatusOK},
+		{7, crc + ", md5=:unsupported:", http.StatusOK},
+		{8, "", http.StatusBadRequest},
+		{7, "sha-256=:AAAA:", http.StatusBadRequest},
+		{7, "crc32c=:AAAAAA==:", http.StatusBadRequest},
+	} {
+		if w := serve(body, test.length, test.digest); w.Status != test.status {
+			t.Errorf("Content-Length %d and digest %q: expected status %d, got %d %s", test.length, test.digest, test.status, w.Status, w.Body)
+		}
+	}
+}
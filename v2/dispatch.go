@@ -0,0 +1,52 @@
+// Copyright 2009 The Go Authors. All rights reserved.
+// Copyright 2012 The Gorilla Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package rpc
+
+import (
+	"net/http"
+	"reflect"
+	"sync"
+)
+
+// DispatchFunc calls a method of rcvr with args and reply, pointers to
+// values of its args and reply types, without reflection. The response
+// writer is only used by methods taking the headers of the response.
+type DispatchFunc func(rcvr interface{}, w http.ResponseWriter, r *http.Request, args, reply interface{}) error
+
+// DispatchTable maps the names of methods to their DispatchFunc.
+type DispatchTable map[string]DispatchFunc
+
+// dispatchTables maps the types of receivers to their DispatchTable.
+var dispatchTables = struct {
+	sync.RWMutex
+	tables map[reflect.Type]DispatchTable
+}{tables: make(map[reflect.Type]DispatchTable)}
+
+// RegisterDispatchTable registers the dispatch table of the receivers of
+// the type of rcvr, usually a nil pointer. Services registered later with
+// such a receiver call the methods of the table through their DispatchFunc
+// instead of reflect.Call, keeping the same registration API.
+//
+// Tables are usually generated by cmd/rpcgen and registered by the init
+// function of the generated file:
+//
+//	//go:generate rpcgen -type Users
+//
+// Methods missing from the table, e.g. the ones taking a *ResponseMeta, are
+// called with reflection.
+func RegisterDispatchTable(rcvr interface{}, table DispatchTable) {
+	dispatchTables.Lock()
+	defer dispatchTables.Unlock()
+	dispatchTables.tables[reflect.TypeOf(rcvr)] = table
+}
+
+// dispatchFunc returns the DispatchFunc of the method of the receiver
+// type, or nil.
+func dispatchFunc(rcvrType reflect.Type, method string) DispatchFunc {
+	dispatchTables.RLock()
+	defer dispatchTables.RUnlock()
+	return dispatchTables.tables[rcvrType][method]
+}
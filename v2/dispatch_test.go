@@ -0,0 +1,38 @@
+// Copyright 2009 The Go Authors. All rights reserved.
+// Copyright 2012 The Gorilla Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package rpc
+
+import (
+	"net/http"
+	"testing"
+)
+
+type DispatchService struct {
+	Service1
+}
+
+func TestDispatchTable(t *testing.T) {
+	var dispatched int
+	RegisterDispatchTable((*DispatchService)(nil), DispatchTable{
+		"Multiply": func(rcvr interface{}, w http.ResponseWriter, r *http.Request, args, reply interface{}) error {
+			dispatched++
+			return rcvr.(*DispatchService).Multiply(r, args.(*Service1Request), reply.(*Service1Response))
+		},
+	})
+	s := NewServer()
+	s.RegisterCodec(MockCodec{A: 3, B: 4}, "mock")
+	s.RegisterService(new(DispatchService), "")
+
+	w := serveMock(s, "DispatchService.Multiply", "")
+	if w.Status != http.StatusOK || w.Body != "12" || dispatched != 1 {
+		t.Errorf("Expected the dispatch table to be used, got %d %s %d", w.Status, w.Body, dispatched)
+	}
+	// Methods missing from the table use reflection.
+	w = serveMock(s, "DispatchService.MultiplyWithHeaders", "")
+	if w.Status != http.StatusOK || dispatched != 1 {
+		t.Errorf("Expected reflection to be used, got %d %d", w.Status, dispatched)
+	}
+}
@@ -0,0 +1,83 @@
+// Copyright 2009 The Go Authors. All rights reserved.
+// Copyright 2012 The Gorilla Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package rpc
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"time"
+)
+
+// ErrDraining is returned to the client, with the 503 Service Unavailable
+// status, when calling a draining server.
+var ErrDraining = errors.New("rpc: server is draining")
+
+// DrainRetryAfter is sent in the Retry-After header of the calls rejected
+// by a draining server, so that clients retry them once another server
+// took over.
+const DrainRetryAfter = time.Second
+
+// drainPollInterval is the interval at which Drain checks the calls in
+// flight.
+const drainPollInterval = 10 * time.Millisecond
+
+// drain counts the calls in flight and rejects new ones when draining.
+type drain struct {
+	draining int32
+	inFlight int64
+}
+
+// Drain makes the server reject new calls with ErrDraining, e.g. before
+// taking it out of a load balancer, and waits for the calls in flight to
+// finish, or for the context to be done. Resume accepts calls again.
+func (s *Server) Drain(ctx context.Context) error {
+	s.drain.start()
+	ticker := time.NewTicker(drainPollInterval)
+	defer ticker.Stop()
+	for atomic.LoadInt64(&s.drain.inFlight) > 0 {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+	return nil
+}
+
+// Resume accepts calls again after Drain.
+func (s *Server) Resume() {
+	atomic.StoreInt32(&s.drain.draining, 0)
+}
+
+// Draining returns true if the server rejects new calls, see Drain.
+func (s *Server) Draining() bool {
+	return atomic.LoadInt32(&s.drain.draining) == 1
+}
+
+// InFlight returns the number of calls being served.
+func (s *Server) InFlight() int64 {
+	return atomic.LoadInt64(&s.drain.inFlight)
+}
+
+func (d *drain) start() {
+	atomic.StoreInt32(&d.draining, 1)
+}
+
+// begin counts a call in flight, and returns false if the server is
+// draining. end must be called when it returns true.
+func (d *drain) begin() bool {
+	atomic.AddInt64(&d.inFlight, 1)
+	if atomic.LoadInt32(&d.draining) == 1 {
+		atomic.AddInt64(&d.inFlight, -1)
+		return false
+	}
+	return true
+}
+
+func (d *drain) end() {
+	atomic.AddInt64(&d.inFlight, -1)
+}
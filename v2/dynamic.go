@@ -0,0 +1,97 @@
+// Copyright 2009 The Go Authors. All rights reserved.
+// Copyright 2012 The Gorilla Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package rpc
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// MethodFunc handles the calls of a method registered with RegisterMethod.
+// It receives the raw params as sent by the client, and returns the reply
+// to be encoded by the codec. Returning a json.RawMessage passes the reply
+// through untouched.
+type MethodFunc func(ctx context.Context, params json.RawMessage) (interface{}, error)
+
+// RegisterMethod registers a method whose name and behavior are only known
+// at runtime, e.g. a webhook defined by users. It can be called while the
+// server serves calls.
+//
+// The method goes through the same codecs, middlewares and hooks as the
+// methods of services; the args passed to the validate function is a
+// *json.RawMessage. It uses a dotted notation as in "Service.Method", and
+// may add a method to a service registered with RegisterService.
+func (s *Server) RegisterMethod(method string, f MethodFunc) error {
+	parts := strings.Split(method, ".")
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return fmt.Errorf("rpc: service/method name ill-formed: %q", method)
+	}
+	m := NewRawMethod(method, func(r *http.Request, _ string, params json.RawMessage) (interface{}, error) {
+		return f(r.Context(), params)
+	})
+	return s.services.add(parts[0], parts[1], m)
+}
+
+// UnregisterMethod removes a method, and its service if it was the last
+// one. It returns false if the method wasn't registered. It can be called
+// while the server serves calls.
+func (s *Server) UnregisterMethod(method string) bool {
+	parts := strings.Split(method, ".")
+	if len(parts) != 2 {
+		return false
+	}
+	return s.services.remove(parts[0], parts[1])
+}
+
+// add adds a method to a service, creating it if needed. Services are
+// copied on write, so that Resolve reads them without locking.
+func (m *serviceMap) add(serviceName, methodName string, method *ServiceMethod) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	if m.services == nil {
+		m.services = make(map[string]*service)
+	}
+	s := &service{name: serviceName, methods: make(map[string]*ServiceMethod)}
+	if old := m.services[serviceName]; old != nil {
+		if old.methods[methodName] != nil {
+			return fmt.Errorf("rpc: method already defined: %q", serviceName+"."+methodName)
+		}
+		*s = *old
+		s.methods = make(map[string]*ServiceMethod, len(old.methods)+1)
+		for name, sm := range old.methods {
+			s.methods[name] = sm
+		}
+	}
+	s.methods[methodName] = method
+	m.services[serviceName] = s
+	return nil
+}
+
+// remove removes a method, and its service if it was the last one.
+func (m *serviceMap) remove(serviceName, methodName string) bool {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	old := m.services[serviceName]
+	if old == nil || old.methods[methodName] == nil {
+		return false
+	}
+	if len(old.methods) == 1 {
+		delete(m.services, serviceName)
+		return true
+	}
+	s := *old
+	s.methods = make(map[string]*ServiceMethod, len(old.methods)-1)
+	for name, sm := range old.methods {
+		if name != methodName {
+			s.methods[name] = sm
+		}
+	}
+	m.services[serviceName] = &s
+	return true
+}
@@ -0,0 +1,41 @@
+// Copyright 2009 The Go Authors. All rights reserved.
+// Copyright 2012 The Gorilla Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package rpc
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+)
+
+func TestRegisterMethod(t *testing.T) {
+	s := NewServer()
+	s.RegisterService(new(Service1), "")
+	echo := func(ctx context.Context, params json.RawMessage) (interface{}, error) {
+		return params, nil
+	}
+	if err := s.RegisterMethod("Hooks.Echo", echo); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.RegisterMethod("Service1.Echo", echo); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.RegisterMethod("Service1.Multiply", echo); err == nil {
+		t.Error("Expected an error for a registered method")
+	}
+	if err := s.RegisterMethod("Echo", echo); err == nil {
+		t.Error("Expected an error for a method without service")
+	}
+	if !s.HasMethod("Hooks.Echo") || !s.HasMethod("Service1.Echo") || !s.HasMethod("Service1.Multiply") {
+		t.Errorf("Expected the methods to be registered, got %v", s.Methods())
+	}
+	if !s.UnregisterMethod("Hooks.Echo") || s.HasService("Hooks") || s.UnregisterMethod("Hooks.Echo") {
+		t.Error("Expected Hooks.Echo to be unregistered once")
+	}
+	if !s.UnregisterMethod("Service1.Echo") || !s.HasMethod("Service1.Multiply") {
+		t.Error("Expected only Service1.Echo to be unregistered")
+	}
+}
@@ -0,0 +1,81 @@
+// Copyright 2009 The Go Authors. All rights reserved.
+// Copyright 2012 The Gorilla Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package rpc
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// EmbedTag is the struct tag opting out embedded fields of services.
+//
+// Services can be composed of embedded structs: the methods promoted from
+// them are registered as the methods of the service, e.g.
+//
+//	type Users struct {
+//		CRUDBase
+//		*Logger `rpc:"-"`
+//	}
+//
+// Embedded fields tagged with `rpc:"-"` are opted out: the methods with
+// the names of their methods aren't registered, including methods of the
+// service itself with the same names.
+const EmbedTag = "rpc"
+
+// checkEmbedded returns the names of the methods opted out from the
+// embedded fields of the receiver type, or an error if allowed methods of embedded
+// fields are ambiguous: Go doesn't promote methods with the same name
+// embedded at the same depth, which would silently not be registered.
+func checkEmbedded(rcvrType reflect.Type, opts registerOptions) (map[string]bool, error) {
+	excluded := make(map[string]bool)
+	var walk func(t reflect.Type, visited map[reflect.Type]bool) error
+	walk = func(t reflect.Type, visited map[reflect.Type]bool) error {
+		for t.Kind() == reflect.Ptr {
+			t = t.Elem()
+		}
+		if t.Kind() != reflect.Struct || visited[t] {
+			return nil
+		}
+		visited[t] = true
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			if !f.Anonymous {
+				continue
+			}
+			ft := f.Type
+			if ft.Kind() != reflect.Ptr && ft.Kind() != reflect.Interface {
+				ft = reflect.PtrTo(ft)
+			}
+			for j := 0; j < ft.NumMethod(); j++ {
+				m := ft.Method(j)
+				if f.Tag.Get(EmbedTag) == "-" {
+					excluded[m.Name] = true
+				} else if _, ok := rcvrType.MethodByName(m.Name); !ok && opts.allows(m.Name) && isRPCMethod(m.Type, ft.Kind() != reflect.Interface) {
+					return fmt.Errorf("rpc: method %q of %v is ambiguous: it is embedded several times at the same depth", m.Name, rcvrType)
+				}
+			}
+			if f.Tag.Get(EmbedTag) != "-" {
+				if err := walk(f.Type, visited); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	}
+	return excluded, walk(rcvrType, make(map[reflect.Type]bool))
+}
+
+// isRPCMethod returns true if the method type has the shape of the
+// methods of services: (*http.Request, *args, *reply, ...) error. The type
+// of methods from types includes the receiver, not the one of interfaces.
+func isRPCMethod(t reflect.Type, hasReceiver bool) bool {
+	first := 0
+	if hasReceiver {
+		first = 1
+	}
+	return t.NumIn() > first && t.In(first) == reflect.PtrTo(typeOfRequest) &&
+		t.NumOut() == 1 && t.Out(0) == typeOfError
+}
@@ -0,0 +1,63 @@
+// Copyright 2009 The Go Authors. All rights reserved.
+// Copyright 2012 The Gorilla Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package rpc
+
+import (
+	"net/http"
+	"testing"
+)
+
+type CRUDBase struct{}
+
+func (CRUDBase) Get(r *http.Request, args *Service1Request, reply *Service1Response) error {
+	reply.Result = args.A
+	return nil
+}
+
+func (CRUDBase) Delete(r *http.Request, args *Service1Request, reply *Service1Response) error {
+	return nil
+}
+
+type Helper struct{}
+
+func (Helper) Audit(r *http.Request, args *Service1Request, reply *Service1Response) error {
+	return nil
+}
+
+type Users struct {
+	CRUDBase
+	*Helper `rpc:"-"`
+}
+
+func (Users) Delete(r *http.Request, args *Service1Request, reply *Service1Response) error {
+	reply.Result = -1
+	return nil
+}
+
+type Ambiguous struct {
+	CRUDBase
+	Other
+}
+
+type Other struct{}
+
+func (Other) Get(r *http.Request, args *Service1Request, reply *Service1Response) error {
+	return nil
+}
+
+func TestEmbeddedServices(t *testing.T) {
+	s := NewServer()
+	if err := s.RegisterService(new(Users), ""); err != nil {
+		t.Fatal(err)
+	}
+	info, _ := s.ServiceInfo("Users")
+	if len(info.Methods) != 2 || info.Methods[0] != "Delete" || info.Methods[1] != "Get" {
+		t.Errorf("Wrong methods %v", info.Methods)
+	}
+	if err := s.RegisterService(new(Ambiguous), ""); err == nil {
+		t.Error("Expected an error for an ambiguous method")
+	}
+}
@@ -0,0 +1,28 @@
+// Copyright 2009 The Go Authors. All rights reserved.
+// Copyright 2012 The Gorilla Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package rpc
+
+import (
+	"errors"
+)
+
+// ErrMethodDisabled is returned to the client when calling a method switched
+// off by the function registered with RegisterMethodEnablerFunc.
+var ErrMethodDisabled = errors.New("rpc: method is disabled")
+
+// RegisterMethodEnablerFunc registers the specified function as the function
+// that will be called before decoding every request, to decide whether the
+// method is enabled. Disabled methods aren't called and ErrMethodDisabled is
+// returned instead.
+//
+// This allows switching methods off at runtime, e.g. from a feature-flag
+// provider, without redeploying.
+//
+// Note: Only one function can be registered, subsequent calls to this
+// method will overwrite all the previous functions.
+func (s *Server) RegisterMethodEnablerFunc(f func(i *RequestInfo) bool) {
+	s.enablerFunc = f
+}
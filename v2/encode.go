@@ -0,0 +1,66 @@
+// Copyright 2009 The Go Authors. All rights reserved.
+// Copyright 2012 The Gorilla Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package rpc
+
+import (
+	"net/http"
+)
+
+// ResponseEncoder is implemented by codec requests that can report the
+// failure to encode a reply, e.g. a value that can't be marshaled. The
+// server then writes an EncodeError instead, with the 500 Internal Server
+// Error status, rather than a partial or malformed response.
+type ResponseEncoder interface {
+	// EncodeResponse writes the response as WriteResponse does, or
+	// returns the error encoding the reply without writing anything.
+	EncodeResponse(w http.ResponseWriter, reply interface{}) error
+}
+
+// EncodeError is the error of a call whose reply couldn't be encoded. It's
+// passed to the AfterFunc and the error reporter.
+type EncodeError struct {
+	Err error
+}
+
+func (e *EncodeError) Error() string {
+	return "rpc: encoding the reply: " + e.Err.Error()
+}
+
+// Unwrap returns the error of the codec.
+func (e *EncodeError) Unwrap() error {
+	return e.Err
+}
+
+// encodeResponse writes the reply with the codec, and returns the error
+// encoding it, or writing it, e.g. when the client went away.
+func encodeResponse(w http.ResponseWriter, codecReq CodecRequest, reply interface{}) error {
+	ew := &errorWriter{ResponseWriter: w}
+	enc, ok := codecReq.(ResponseEncoder)
+	if !ok {
+		codecReq.WriteResponse(ew, reply)
+		return ew.err
+	}
+	if err := enc.EncodeResponse(ew, reply); err != nil {
+		err = &EncodeError{Err: err}
+		codecReq.WriteError(ew, http.StatusInternalServerError, err)
+		return err
+	}
+	return ew.err
+}
+
+// errorWriter records the first error writing a response.
+type errorWriter struct {
+	http.ResponseWriter
+	err error
+}
+
+func (w *errorWriter) Write(p []byte) (int, error) {
+	n, err := w.ResponseWriter.Write(p)
+	if err != nil && w.err == nil {
+		w.err = err
+	}
+	return n, err
+}
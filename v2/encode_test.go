@@ -0,0 +1,72 @@
+// Copyright 2009 The Go Authors. All rights reserved.
+// Copyright 2012 The Gorilla Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package rpc
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+)
+
+var errEncode = errors.New("can't encode")
+
+type encodeErrorCodec struct{}
+
+func (c encodeErrorCodec) NewRequest(r *http.Request) CodecRequest {
+	return encodeErrorCodecRequest{MockCodecRequest{2, 3, r.URL.Path}}
+}
+
+type encodeErrorCodecRequest struct {
+	MockCodecRequest
+}
+
+func (r encodeErrorCodecRequest) EncodeResponse(w http.ResponseWriter, reply interface{}) error {
+	return errEncode
+}
+
+type failingWriter struct {
+	*MockResponseWriter
+}
+
+func (w failingWriter) Write(p []byte) (int, error) {
+	return 0, errors.New("broken pipe")
+}
+
+func TestEncodeError(t *testing.T) {
+	s := NewServer()
+	s.RegisterService(new(Service1), "")
+	s.RegisterCodec(encodeErrorCodec{}, "mock")
+	var info *RequestInfo
+	s.RegisterAfterFunc(func(i *RequestInfo) {
+		info = i
+	})
+	w := serveMock(s, "Service1.Multiply", "")
+	if w.Status != 500 || w.Body != "rpc: encoding the reply: can't encode" {
+		t.Errorf("Expected an encode error, got %d %q", w.Status, w.Body)
+	}
+	if info == nil {
+		t.Fatal("Expected the AfterFunc to be called")
+	}
+	if encErr, ok := info.Error.(*EncodeError); !ok || encErr.Err != errEncode || info.StatusCode != 500 {
+		t.Errorf("Expected the encode error in the AfterFunc, got %+v", info)
+	}
+}
+
+func TestWriteResponseError(t *testing.T) {
+	s := NewServer()
+	s.RegisterService(new(Service1), "")
+	s.RegisterCodec(MockCodec{2, 3}, "mock")
+	var info *RequestInfo
+	s.RegisterAfterFunc(func(i *RequestInfo) {
+		info = i
+	})
+	r, _ := http.NewRequest("POST", "Service1.Multiply", nil)
+	r.Header.Set("Content-Type", "mock")
+	s.ServeHTTP(failingWriter{NewMockResponseWriter()}, r)
+	if info == nil || info.Error == nil || info.Error.Error() != "broken pipe" {
+		t.Errorf("Expected the write error in the AfterFunc, got %+v", info)
+	}
+}
@@ -0,0 +1,227 @@
+// Copyright 2009 The Go Authors. All rights reserved.
+// Copyright 2012 The Gorilla Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package rpc
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+)
+
+// EnumTag is the struct tag naming the enum of the values allowed in a
+// field, e.g.:
+//
+//	type Args struct {
+//		Color string `json:"color" enum:"Color"`
+//	}
+const EnumTag = "enum"
+
+// InvalidParamsError is returned when the args of a call are invalid.
+// Codecs report it with their invalid params error, e.g. -32602 for
+// JSON-RPC 2.0.
+type InvalidParamsError struct {
+	// Field is the dotted path of the invalid field, e.g. "Address.City".
+	Field   string
+	Message string
+}
+
+func (e *InvalidParamsError) Error() string {
+	if e.Field == "" {
+		return "rpc: invalid params: " + e.Message
+	}
+	return "rpc: invalid params: " + e.Field + ": " + e.Message
+}
+
+var enums = struct {
+	sync.RWMutex
+	values map[string][]interface{}
+}{values: make(map[string][]interface{})}
+
+// RegisterEnum registers the values allowed in the fields tagged with the
+// enum name, replacing the previous ones. Values are strings, booleans or
+// numbers, or values of types with one of these kinds, such as the
+// constants of the enum type:
+//
+//	rpc.RegisterEnum("Color", Red, Green, Blue)
+//
+// The server validates the args of the calls before calling the methods,
+// whatever the codec, and answers with an InvalidParamsError for values
+// that aren't allowed. Fields may be pointers, slices or arrays of enum
+// values; nil pointers aren't validated.
+func RegisterEnum(name string, values ...interface{}) {
+	enums.Lock()
+	defer enums.Unlock()
+	enums.values[name] = append([]interface{}(nil), values...)
+}
+
+// EnumValues returns the values allowed for the enum name, and false if it
+// isn't registered.
+func EnumValues(name string) ([]interface{}, bool) {
+	enums.RLock()
+	defer enums.RUnlock()
+	values, ok := enums.values[name]
+	return append([]interface{}(nil), values...), ok
+}
+
+// validateEnums checks the fields of v tagged with an enum.
+func validateEnums(v reflect.Value) error {
+	if !hasEnums(v.Type()) {
+		return nil
+	}
+	return checkEnums(v, "", "")
+}
+
+// checkEnums checks the value v of the field at path, tagged with the enum
+// name if not empty.
+func checkEnums(v reflect.Value, path, name string) error {
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		if v.IsNil() {
+			return nil
+		}
+		return checkEnums(v.Elem(), path, name)
+	case reflect.Slice, reflect.Array:
+		if name == "" && !hasEnums(v.Type().Elem()) {
+			return nil
+		}
+		for i := 0; i < v.Len(); i++ {
+			if err := checkEnums(v.Index(i), fmt.Sprintf("%s[%d]", path, i), name); err != nil {
+				return err
+			}
+		}
+		return nil
+	case reflect.Map:
+		if !hasEnums(v.Type().Elem()) {
+			return nil
+		}
+		for _, k := range v.MapKeys() {
+			if err := checkEnums(v.MapIndex(k), joinPath(path, fmt.Sprint(k.Interface())), ""); err != nil {
+				return err
+			}
+		}
+		return nil
+	case reflect.Struct:
+		if name != "" {
+			break
+		}
+		t := v.Type()
+		for i := 0; i < t.NumField(); i++ {
+			sf := t.Field(i)
+			if sf.PkgPath != "" && !sf.Anonymous {
+				continue
+			}
+			fieldPath := path
+			if !sf.Anonymous {
+				fieldPath = joinPath(path, sf.Name)
+			}
+			if err := checkEnums(v.Field(i), fieldPath, sf.Tag.Get(EnumTag)); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if name == "" {
+		return nil
+	}
+	values, ok := EnumValues(name)
+	if !ok {
+		return fmt.Errorf("rpc: enum %q isn't registered", name)
+	}
+	value := enumKey(v)
+	allowed := make([]string, len(values))
+	for i, a := range values {
+		if enumKey(reflect.ValueOf(a)) == value {
+			return nil
+		}
+		allowed[i] = fmt.Sprint(a)
+	}
+	return &InvalidParamsError{
+		Field:   path,
+		Message: fmt.Sprintf("%v isn't one of %s", v.Interface(), strings.Join(allowed, ", ")),
+	}
+}
+
+// enumKey returns the basic value of v, so that values of different types
+// with the same kind can be compared.
+func enumKey(v reflect.Value) interface{} {
+	switch v.Kind() {
+	case reflect.String:
+		return v.String()
+	case reflect.Bool:
+		return v.Bool()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(v.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return float64(v.Uint())
+	case reflect.Float32, reflect.Float64:
+		return v.Float()
+	}
+	return nil
+}
+
+func joinPath(path, name string) string {
+	if path == "" {
+		return name
+	}
+	return path + "." + name
+}
+
+var enumTypes typeCache
+
+// hasEnums returns true if values of type t may contain fields tagged with
+// an enum.
+func hasEnums(t reflect.Type) bool {
+	if has, ok := enumTypes.load(t); ok {
+		return has
+	}
+	has := findTag(t, EnumTag, make(map[reflect.Type]bool))
+	enumTypes.store(t, has)
+	return has
+}
+
+// typeCache caches a property of types.
+type typeCache struct {
+	mutex sync.RWMutex
+	types map[reflect.Type]bool
+}
+
+func (c *typeCache) load(t reflect.Type) (has, ok bool) {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	has, ok = c.types[t]
+	return has, ok
+}
+
+func (c *typeCache) store(t reflect.Type, has bool) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if c.types == nil {
+		c.types = make(map[reflect.Type]bool)
+	}
+	c.types[t] = has
+}
+
+// findTag returns true if values of type t may contain fields with the
+// struct tag.
+func findTag(t reflect.Type, tag string, visited map[reflect.Type]bool) bool {
+	if visited[t] {
+		return false
+	}
+	visited[t] = true
+	switch t.Kind() {
+	case reflect.Ptr, reflect.Slice, reflect.Array, reflect.Map:
+		return findTag(t.Elem(), tag, visited)
+	case reflect.Struct:
+		for i := 0; i < t.NumField(); i++ {
+			sf := t.Field(i)
+			if sf.Tag.Get(tag) != "" || findTag(sf.Type, tag, visited) {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,55 @@
+// Copyright 2009 The Go Authors. All rights reserved.
+// Copyright 2012 The Gorilla Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package rpc
+
+import (
+	"reflect"
+	"testing"
+)
+
+type Size int
+
+const (
+	Small Size = iota + 1
+	Large
+)
+
+type ShirtArgs struct {
+	Color  string   `enum:"TestColor"`
+	Size   Size     `enum:"TestSize"`
+	Extra  []string `enum:"TestColor"`
+	Parent *ShirtArgs
+}
+
+func TestValidateEnums(t *testing.T) {
+	RegisterEnum("TestColor", "red", "blue")
+	RegisterEnum("TestSize", Small, Large)
+	for _, test := range []struct {
+		args  ShirtArgs
+		field string
+	}{
+		{ShirtArgs{Color: "red", Size: Large, Extra: []string{"blue"}}, ""},
+		{ShirtArgs{Color: "green", Size: Large}, "Color"},
+		{ShirtArgs{Color: "red", Size: 3}, "Size"},
+		{ShirtArgs{Color: "red", Size: Small, Extra: []string{"blue", "pink"}}, "Extra[1]"},
+		{ShirtArgs{Color: "red", Size: Small, Parent: &ShirtArgs{Color: "red"}}, "Parent.Size"},
+	} {
+		err := validateEnums(reflect.ValueOf(&test.args))
+		if test.field == "" {
+			if err != nil {
+				t.Errorf("Unexpected error %v", err)
+			}
+			continue
+		}
+		e, ok := err.(*InvalidParamsError)
+		if !ok || e.Field != test.field {
+			t.Errorf("Expected invalid %s, got %v", test.field, err)
+		}
+	}
+	if values, ok := EnumValues("TestColor"); !ok || len(values) != 2 {
+		t.Errorf("Wrong values %v", values)
+	}
+}
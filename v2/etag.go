@@ -0,0 +1,57 @@
+// Copyright 2009 The Go Authors. All rights reserved.
+// Copyright 2012 The Gorilla Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package rpc
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+)
+
+// ErrNotModified is written instead of the reply of a call when the
+// "If-None-Match" header of the request matches the ETag of the reply.
+// Over HTTP the response has the 304 Not Modified status and no body, while
+// message transports get the error written by the codec.
+var ErrNotModified = errors.New("rpc: not modified")
+
+// ETagger is implemented by replies that have a version, e.g. the revision
+// of a resource. The server sets the "ETag" header of the response to the
+// tag of the reply, and answers requests whose "If-None-Match" header
+// matches it with ErrNotModified, so clients polling a method don't get
+// the same reply again.
+type ETagger interface {
+	// ETag returns the entity tag of the reply, without quotes. An empty
+	// tag disables conditional requests for the reply.
+	ETag() string
+}
+
+// etag sets the ETag header for the reply and returns true if the request
+// is not modified.
+func etag(w http.ResponseWriter, r *http.Request, reply interface{}) bool {
+	e, ok := reply.(ETagger)
+	if !ok {
+		return false
+	}
+	tag := e.ETag()
+	if tag == "" {
+		return false
+	}
+	tag = `"` + tag + `"`
+	w.Header().Set("ETag", tag)
+	return matchETag(r.Header.Get("If-None-Match"), tag)
+}
+
+// matchETag returns true if the "If-None-Match" header matches the tag,
+// using the weak comparison.
+func matchETag(header, tag string) bool {
+	for _, t := range strings.Split(header, ",") {
+		t = strings.TrimSpace(t)
+		if t == "*" || strings.TrimPrefix(t, "W/") == tag {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,56 @@
+// Copyright 2009 The Go Authors. All rights reserved.
+// Copyright 2012 The Gorilla Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package rpc
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+)
+
+var (
+	typeOfRawMessage = reflect.TypeOf(json.RawMessage(nil))
+	typeOfInterface  = reflect.TypeOf((*interface{})(nil)).Elem()
+)
+
+// FallbackFunc handles calls to methods that can't be resolved.
+//
+// It receives the requested method name and the raw params as sent by the
+// client, and returns the reply to be encoded by the codec. Returning a
+// json.RawMessage passes the reply through untouched.
+type FallbackFunc func(r *http.Request, method string, params json.RawMessage) (interface{}, error)
+
+// RegisterFallbackFunc registers the function called when the requested
+// method is unknown, instead of returning a method not found error. This
+// allows gateways to proxy unrecognized calls to other servers.
+//
+// The fallback goes through the same intercept, before, validate and after
+// functions as regular methods. The args passed to the validate function is
+// a *json.RawMessage.
+//
+// Note: Only one function can be registered, subsequent calls to this
+// method will overwrite all the previous functions.
+func (s *Server) RegisterFallbackFunc(f FallbackFunc) {
+	s.fallbackFunc = f
+}
+
+// NewRawMethod returns a method that calls f with the given method name and
+// the raw params of each call. This is useful to build routers for methods
+// implemented outside of Go types, e.g. by other processes.
+func NewRawMethod(method string, f FallbackFunc) *ServiceMethod {
+	return &ServiceMethod{
+		argsType:  typeOfRawMessage,
+		replyType: typeOfInterface,
+		fn: func(r *http.Request, args, reply interface{}) error {
+			res, err := f(r, method, *args.(*json.RawMessage))
+			if err != nil {
+				return err
+			}
+			*reply.(*interface{}) = res
+			return nil
+		},
+	}
+}
@@ -0,0 +1,115 @@
+// Copyright 2009 The Go Authors. All rights reserved.
+// Copyright 2012 The Gorilla Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package rpc
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// RegisterOption is an option of RegisterService.
+type RegisterOption func(*registerOptions)
+
+type registerOptions struct {
+	filters []func(name string) bool
+	strict  bool
+}
+
+// MethodFilter registers only the methods for which f returns true.
+func MethodFilter(f func(name string) bool) RegisterOption {
+	return func(o *registerOptions) {
+		o.filters = append(o.filters, f)
+	}
+}
+
+// MethodPrefix registers only the methods whose name starts with prefix,
+// e.g. "RPC". The prefix is part of the method names.
+func MethodPrefix(prefix string) RegisterOption {
+	return MethodFilter(func(name string) bool {
+		return strings.HasPrefix(name, prefix)
+	})
+}
+
+// MethodsOf registers only the methods of an interface, given as a nil
+// pointer to it:
+//
+//	s.RegisterService(users, "", rpc.MethodsOf((*UsersAPI)(nil)))
+func MethodsOf(iface interface{}) RegisterOption {
+	t := reflect.TypeOf(iface).Elem()
+	return MethodFilter(func(name string) bool {
+		_, ok := t.MethodByName(name)
+		return ok
+	})
+}
+
+// MethodLister is implemented by services listing the methods to register,
+// so that their other exported methods, e.g. helpers, aren't exposed.
+type MethodLister interface {
+	Methods() []string
+}
+
+// allows returns true if the method passes the filters of the options.
+func (o *registerOptions) allows(name string) bool {
+	for _, f := range o.filters {
+		if !f(name) {
+			return false
+		}
+	}
+	return true
+}
+
+// withLister returns the options with the allowlist of the receiver, if it
+// implements MethodLister.
+func (o registerOptions) withLister(rcvr interface{}) registerOptions {
+	l, ok := rcvr.(MethodLister)
+	if !ok {
+		return o
+	}
+	allowed := make(map[string]bool)
+	for _, name := range l.Methods() {
+		allowed[name] = true
+	}
+	o.filters = append(o.filters[:len(o.filters):len(o.filters)], func(name string) bool {
+		return allowed[name]
+	})
+	return o
+}
+
+// SkippedMethod is an exported method that wasn't registered, and why.
+type SkippedMethod struct {
+	Name   string
+	Reason string
+}
+
+// RegistrationError is returned by RegisterService when a service has no
+// methods of suitable type, or skipped methods with strict registration.
+// It lists the exported methods skipped and why.
+type RegistrationError struct {
+	Service string
+	Skipped []SkippedMethod
+	// Strict is set when the service has methods of suitable type, but
+	// strict registration rejects the skipped ones.
+	Strict bool
+}
+
+func (e *RegistrationError) Error() string {
+	msg := fmt.Sprintf("rpc: %q has no exported methods of suitable type", e.Service)
+	if e.Strict {
+		msg = fmt.Sprintf("rpc: %q has exported methods of unsuitable type", e.Service)
+	}
+	for _, m := range e.Skipped {
+		msg += fmt.Sprintf("\n\t%s: %s", m.Name, m.Reason)
+	}
+	return msg
+}
+
+// StrictRegistration makes RegisterService fail if it skips any exported
+// method of the services that isn't filtered out by the options, instead
+// of silently not registering it.
+func (s *Server) StrictRegistration(strict bool) {
+	s.strictRegister = strict
+}
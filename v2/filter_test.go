@@ -0,0 +1,85 @@
+// Copyright 2009 The Go Authors. All rights reserved.
+// Copyright 2012 The Gorilla Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package rpc
+
+import (
+	"net/http"
+	"testing"
+)
+
+type Multiplier interface {
+	Multiply(r *http.Request, req *Service1Request, res *Service1Response) error
+}
+
+type ListedService struct {
+	Service1
+}
+
+func (ListedService) Methods() []string {
+	return []string{"MultiplyWithHeaders"}
+}
+
+func TestMethodFilters(t *testing.T) {
+	for _, test := range []struct {
+		service interface{}
+		opts    []RegisterOption
+		methods []string
+	}{
+		{new(Service1), []RegisterOption{MethodsOf((*Multiplier)(nil))}, []string{"Multiply"}},
+		{new(Service1), []RegisterOption{MethodPrefix("MultiplyWith")}, []string{"MultiplyWithHeaders"}},
+		{new(Service1), []RegisterOption{MethodFilter(func(name string) bool { return name != "Multiply" })}, []string{"MultiplyWithHeaders"}},
+		{new(ListedService), nil, []string{"MultiplyWithHeaders"}},
+	} {
+		s := NewServer()
+		if err := s.RegisterService(test.service, "S", test.opts...); err != nil {
+			t.Fatal(err)
+		}
+		info, _ := s.ServiceInfo("S")
+		if len(info.Methods) != len(test.methods) || info.Methods[0] != test.methods[0] {
+			t.Errorf("Registered %v, expected %v", info.Methods, test.methods)
+		}
+	}
+	if err := NewServer().RegisterService(new(Service1), "", MethodPrefix("RPC")); err == nil {
+		t.Error("Expected an error without methods")
+	}
+	if err := NewServer().RegisterService(new(Ambiguous), "", MethodPrefix("Delete")); err != nil {
+		t.Errorf("Expected filtered ambiguous methods to be ignored, got %v", err)
+	}
+}
+
+type MixedService struct{}
+
+func (MixedService) Multiply(r *http.Request, req *Service1Request, res *Service1Response) error {
+	return nil
+}
+
+func (MixedService) Helper(a int) int {
+	return a
+}
+
+func (MixedService) Value(r *http.Request, req Service1Request, res *Service1Response) error {
+	return nil
+}
+
+func TestRegistrationDiagnostics(t *testing.T) {
+	s := NewServer()
+	if err := s.RegisterService(new(MixedService), ""); err != nil {
+		t.Fatal(err)
+	}
+	s = NewServer()
+	s.StrictRegistration(true)
+	err := s.RegisterService(new(MixedService), "")
+	e, ok := err.(*RegistrationError)
+	if !ok || !e.Strict || len(e.Skipped) != 2 {
+		t.Fatalf("Expected 2 skipped methods, got %v", err)
+	}
+	if e.Skipped[0].Name != "Helper" || e.Skipped[1].Reason != "args type rpc.Service1Request is not a pointer" {
+		t.Errorf("Wrong diagnostics %v", e)
+	}
+	if err := s.RegisterService(new(MixedService), "", MethodPrefix("Multiply")); err != nil {
+		t.Errorf("Expected filtered methods not to fail, got %v", err)
+	}
+}
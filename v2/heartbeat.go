@@ -0,0 +1,70 @@
+// Copyright 2009 The Go Authors. All rights reserved.
+// Copyright 2012 The Gorilla Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package rpc
+
+import (
+	"context"
+	"time"
+)
+
+// DefaultHeartbeatInterval is the time between pings of a Heartbeat without
+// Interval.
+const DefaultHeartbeatInterval = 30 * time.Second
+
+// Heartbeat configures the keep-alive checks of the connections of
+// stateful transports, so that dead sessions are detected and ended
+// promptly.
+type Heartbeat struct {
+	// Interval is the time between pings. If zero,
+	// DefaultHeartbeatInterval is used.
+	Interval time.Duration
+	// Timeout is the time the client has to answer a ping. If zero,
+	// Interval is used.
+	Timeout time.Duration
+	// MaxMissed is the number of consecutive unanswered pings after which
+	// the connection is closed. If zero, one missed ping closes it.
+	MaxMissed int
+}
+
+// Run pings the client every Interval until done is closed. The context
+// passed to ping expires after Timeout. After MaxMissed consecutive pings
+// fail, dead is called and Run returns. It's meant to be run by transports
+// in its own goroutine.
+func (h *Heartbeat) Run(done <-chan struct{}, ping func(ctx context.Context) error, dead func()) {
+	interval := h.Interval
+	if interval <= 0 {
+		interval = DefaultHeartbeatInterval
+	}
+	timeout := h.Timeout
+	if timeout <= 0 {
+		timeout = interval
+	}
+	maxMissed := h.MaxMissed
+	if maxMissed <= 0 {
+		maxMissed = 1
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	missed := 0
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), timeout)
+		err := ping(ctx)
+		cancel()
+		if err == nil {
+			missed = 0
+			continue
+		}
+		if missed++; missed >= maxMissed {
+			dead()
+			return
+		}
+	}
+}
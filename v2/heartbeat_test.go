@@ -0,0 +1,46 @@
+// Copyright 2009 The Go Authors. All rights reserved.
+// Copyright 2012 The Gorilla Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package rpc
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestHeartbeatRun(t *testing.T) {
+	// A client missing 2 pings is dead.
+	h := &Heartbeat{Interval: time.Millisecond, MaxMissed: 2}
+	pings := 0
+	dead := make(chan struct{})
+	go h.Run(nil, func(ctx context.Context) error {
+		pings++
+		return errors.New("no pong")
+	}, func() { close(dead) })
+	select {
+	case <-dead:
+	case <-time.After(time.Second):
+		t.Fatal("Expected the client to be dead")
+	}
+	if pings != 2 {
+		t.Errorf("Expected 2 pings, got %d", pings)
+	}
+
+	// The zero Heartbeat pings at the default interval.
+	done := make(chan struct{})
+	returned := make(chan struct{})
+	go func() {
+		new(Heartbeat).Run(done, func(ctx context.Context) error { return nil }, func() {})
+		close(returned)
+	}()
+	close(done)
+	select {
+	case <-returned:
+	case <-time.After(time.Second):
+		t.Fatal("Expected Run to return when done")
+	}
+}
@@ -0,0 +1,70 @@
+// Copyright 2009 The Go Authors. All rights reserved.
+// Copyright 2012 The Gorilla Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package rpc
+
+import (
+	"context"
+	"net/http"
+)
+
+// backfillContext is the context of a request returned by the intercept
+// function, falling back to the values of the context of the original
+// request, e.g. set by an authentication or tracing middleware.
+type backfillContext struct {
+	context.Context
+	values context.Context
+}
+
+func (c *backfillContext) Value(key interface{}) interface{} {
+	if v := c.Context.Value(key); v != nil {
+		return v
+	}
+	return c.values.Value(key)
+}
+
+// EnableContextMerge makes the context of the requests returned by the
+// function registered with RegisterInterceptFunc also done when the
+// context of the original request is, e.g. when the client goes away,
+// even if the function built a new context instead of deriving it from
+// the original one.
+//
+// The values of the original context are always visible through the new
+// one, whether this is enabled or not.
+func (s *Server) EnableContextMerge(enable bool) {
+	s.contextMerge = enable
+}
+
+// intercept calls the intercept function and returns the request to serve
+// the call with, and a function releasing its resources.
+func (s *Server) intercept(r *http.Request, method string) (*http.Request, func()) {
+	req := s.interceptFunc(&RequestInfo{
+		Request: r,
+		Method:  method,
+		Body:    requestBody(r),
+	})
+	if req == nil {
+		return r, func() {}
+	}
+	if req.Context() == r.Context() {
+		return req, func() {}
+	}
+	ctx := context.Context(&backfillContext{Context: req.Context(), values: r.Context()})
+	release := func() {}
+	if s.contextMerge {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithCancel(ctx)
+		done := r.Context().Done()
+		go func() {
+			select {
+			case <-done:
+				cancel()
+			case <-ctx.Done():
+			}
+		}()
+		release = cancel
+	}
+	return req.WithContext(ctx), release
+}
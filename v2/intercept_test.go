@@ -0,0 +1,60 @@
+// Copyright 2009 The Go Authors. All rights reserved.
+// Copyright 2012 The Gorilla Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package rpc
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+)
+
+type interceptKey string
+
+func TestInterceptBackfill(t *testing.T) {
+	s := NewServer()
+	s.RegisterService(new(Service1), "")
+	s.RegisterCodec(MockCodec{2, 3}, "mock")
+	s.RegisterInterceptFunc(func(i *RequestInfo) *http.Request {
+		// A new context, not derived from the one of the request.
+		ctx := context.WithValue(context.Background(), interceptKey("added"), "intercept")
+		return i.Request.WithContext(ctx)
+	})
+	var hooked context.Context
+	s.RegisterBeforeFunc(func(i *RequestInfo) {
+		hooked = i.Request.Context()
+	})
+
+	serve := func(ctx context.Context) {
+		r, _ := http.NewRequest("POST", "Service1.Multiply", nil)
+		r.Header.Set("Content-Type", "mock")
+		s.ServeHTTP(NewMockResponseWriter(), r.WithContext(ctx))
+	}
+	ctx, cancel := context.WithCancel(context.WithValue(context.Background(), interceptKey("gateway"), "auth"))
+	serve(ctx)
+	if hooked.Value(interceptKey("added")) != "intercept" || hooked.Value(interceptKey("gateway")) != "auth" {
+		t.Errorf("Expected the values of both contexts")
+	}
+	cancel()
+	select {
+	case <-hooked.Done():
+		t.Error("Expected the new context not to be merged")
+	default:
+	}
+
+	s.EnableContextMerge(true)
+	ctx, cancel = context.WithCancel(context.Background())
+	s.RegisterBeforeFunc(func(i *RequestInfo) {
+		cancel()
+		hooked = i.Request.Context()
+		select {
+		case <-hooked.Done():
+		case <-time.After(time.Second):
+			t.Error("Expected the merged context to be done with the original one")
+		}
+	})
+	serve(ctx)
+}
@@ -9,12 +9,14 @@ import (
 	"bytes"
 	"encoding/json"
 	"errors"
+	"math/big"
 	"net/http"
 	"net/http/httptest"
 	"reflect"
 	"testing"
 
 	"github.com/gorilla/rpc/v2"
+	"github.com/gorilla/rpc/v2/jsonopt"
 )
 
 var (
@@ -130,3 +132,54 @@ func TestClientNullResult(t *testing.T) {
 		t.Fatalf("Unexpected error: %s", err)
 	}
 }
+
+type BigService struct{}
+
+func (BigService) Double(r *http.Request, args *big.Int, reply *big.Int) error {
+	reply.Mul(args, big.NewInt(2))
+	return nil
+}
+
+func TestCodecOptions(t *testing.T) {
+	s := rpc.NewServer()
+	codec := NewCodec()
+	codec.SetOptions(jsonopt.Options{BigNumbers: jsonopt.BigAsString})
+	s.RegisterCodec(codec, "application/json")
+	s.RegisterService(new(BigService), "Big")
+
+	code, res := executeRaw(t, s, json.RawMessage(`{"method":"Big.Double","params":["100000000000000000000"],"id":1}`))
+	if code != 200 {
+		t.Fatalf("Expected response code to be 200, but got %d: %s", code, res)
+	}
+	if v, ok := field("result", res.Bytes()); !ok || v != "200000000000000000000" {
+		t.Errorf("Expected result as string, but got %v", v)
+	}
+}
+
+type Item struct {
+	Name string
+}
+
+type BulkService struct{}
+
+func (BulkService) Count(r *http.Request, args *[]Item, reply *int) error {
+	*reply = len(*args)
+	return nil
+}
+
+func TestSliceParams(t *testing.T) {
+	s := rpc.NewServer()
+	s.RegisterCodec(NewCodec(), "application/json")
+	s.RegisterService(new(BulkService), "Bulk")
+
+	var count int
+	if err := execute(t, s, "Bulk.Count", []Item{{"a"}, {"b"}}, &count); err != nil || count != 2 {
+		t.Errorf("Expected 2 items, got %d %v", count, err)
+	}
+	for _, params := range []string{`[{"Name":"a"},{"Name":"b"},{"Name":"c"}]`, `[[{"Name":"a"},{"Name":"b"},{"Name":"c"}]]`} {
+		code, res := executeRaw(t, s, json.RawMessage(`{"method":"Bulk.Count","params":`+params+`,"id":1}`))
+		if v, ok := field("result", res.Bytes()); code != 200 || !ok || v != 3.0 {
+			t.Errorf("Expected 3 items, got %v: %s", v, res)
+		}
+	}
+}
@@ -10,8 +10,10 @@ import (
 	"errors"
 	"fmt"
 	"net/http"
+	"reflect"
 
 	"github.com/gorilla/rpc/v2"
+	"github.com/gorilla/rpc/v2/jsonopt"
 )
 
 var null = json.RawMessage([]byte("null"))
@@ -65,11 +67,44 @@ func NewCodec() *Codec {
 
 // Codec creates a CodecRequest to process each request.
 type Codec struct {
+	opts       *jsonopt.Options
+	methodOpts map[string]*jsonopt.Options
+}
+
+// SetOptions sets the options encoding the replies and decoding the params,
+// e.g. to encode big numbers losslessly.
+func (c *Codec) SetOptions(opts jsonopt.Options) {
+	c.opts = &opts
+}
+
+// SetMethodOptions sets the options of a method, replacing those set with
+// SetOptions, e.g. to pretty print the replies of a debug method.
+//
+// The method uses a dotted notation as in "Service.Method".
+func (c *Codec) SetMethodOptions(method string, opts jsonopt.Options) {
+	if c.methodOpts == nil {
+		c.methodOpts = make(map[string]*jsonopt.Options)
+	}
+	c.methodOpts[method] = &opts
+}
+
+// options returns the options of the method.
+func (c *Codec) options(method string) *jsonopt.Options {
+	if opts, ok := c.methodOpts[method]; ok {
+		return opts
+	}
+	return c.opts
 }
 
 // NewRequest returns a CodecRequest.
 func (c *Codec) NewRequest(r *http.Request) rpc.CodecRequest {
-	return newCodecRequest(r)
+	return newCodecRequest(r, c.options)
+}
+
+// Warmup prepares the JSON encoding of args and reply, see rpc.Warmer.
+func (c *Codec) Warmup(args, reply reflect.Type) {
+	jsonopt.Warmup(args)
+	jsonopt.Warmup(reply)
 }
 
 // ----------------------------------------------------------------------------
@@ -77,18 +112,20 @@ func (c *Codec) NewRequest(r *http.Request) rpc.CodecRequest {
 // ----------------------------------------------------------------------------
 
 // newCodecRequest returns a new CodecRequest.
-func newCodecRequest(r *http.Request) rpc.CodecRequest {
+func newCodecRequest(r *http.Request, opts func(method string) *jsonopt.Options) rpc.CodecRequest {
 	// Decode the request body and check if RPC method is valid.
 	req := new(serverRequest)
 	err := json.NewDecoder(r.Body).Decode(req)
 	r.Body.Close()
-	return &CodecRequest{request: req, err: err}
+	return &CodecRequest{request: req, err: err, opts: opts(req.Method)}
 }
 
 // CodecRequest decodes and encodes a single request.
 type CodecRequest struct {
 	request *serverRequest
 	err     error
+	opts    *jsonopt.Options
+	fields  []string
 }
 
 // Method returns the RPC method for the current request.
@@ -107,26 +144,72 @@ func (c *CodecRequest) ReadRequest(args interface{}) error {
 		if c.request.Params != nil {
 			// JSON params is array value. RPC params is struct.
 			// Unmarshal into array containing the request struct.
-			params := [1]interface{}{args}
-			c.err = json.Unmarshal(*c.request.Params, &params)
-		} else {
+			var params [1]json.RawMessage
+			if c.err = json.Unmarshal(*c.request.Params, &params); c.err == nil && params[0] != nil {
+				data := params[0]
+				c.err = c.opts.Unmarshal(data, args)
+				if c.err != nil && isSlice(args) {
+					// Slice args also take the params themselves, e.g.
+					// [a, b] instead of [[a, b]].
+					data = *c.request.Params
+					c.err = c.opts.Unmarshal(data, args)
+				}
+				if c.err == nil {
+					jsonopt.RecordPresence(data, args)
+					c.fields = jsonopt.Fields(data)
+				}
+			}
+		} else if !rpc.IsEmptyArgs(args) {
 			c.err = errors.New("rpc: method request ill-formed: missing params field")
 		}
 	}
 	return c.err
 }
 
+// isSlice returns true if args points to a slice.
+func isSlice(args interface{}) bool {
+	t := reflect.TypeOf(args)
+	return t.Kind() == reflect.Ptr && t.Elem().Kind() == reflect.Slice
+}
+
 // WriteResponse encodes the response and writes it to the ResponseWriter.
 func (c *CodecRequest) WriteResponse(w http.ResponseWriter, reply interface{}) {
-	if c.request.Id != nil {
+	if err := c.EncodeResponse(w, reply); err != nil {
+		c.WriteError(w, 400, err)
+	}
+}
+
+// EncodeResponse encodes the response and writes it to the ResponseWriter,
+// or returns the error encoding the reply without writing anything, see
+// rpc.ResponseEncoder.
+func (c *CodecRequest) EncodeResponse(w http.ResponseWriter, reply interface{}) error {
+	if c.request.Id == nil {
 		// Id is null for notifications and they don't have a response.
-		res := &serverResponse{
-			Result: reply,
-			Error:  &null,
-			Id:     c.request.Id,
+		return nil
+	}
+	res := &serverResponse{
+		Result: reply,
+		Error:  &null,
+		Id:     c.request.Id,
+	}
+	if c.opts.Converts(reply) || len(c.fields) > 0 {
+		result, err := c.opts.Marshal(reply)
+		if err == nil && len(c.fields) > 0 {
+			result, err = jsonopt.Prune(result, c.fields)
 		}
-		c.writeServerResponse(w, 200, res)
+		if err != nil {
+			return err
+		}
+		res.Result = json.RawMessage(result)
+	}
+	b, err := c.opts.Format(res)
+	if err != nil {
+		return err
 	}
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(200)
+	w.Write(b)
+	return nil
 }
 
 func (c *CodecRequest) WriteError(w http.ResponseWriter, _ int, err error) {
@@ -143,7 +226,7 @@ func (c *CodecRequest) WriteError(w http.ResponseWriter, _ int, err error) {
 }
 
 func (c *CodecRequest) writeServerResponse(w http.ResponseWriter, status int, res *serverResponse) {
-	b, err := json.Marshal(res)
+	b, err := c.opts.Format(res)
 	if err == nil {
 		w.Header().Set("Content-Type", "application/json; charset=utf-8")
 		w.WriteHeader(status)
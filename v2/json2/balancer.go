@@ -0,0 +1,105 @@
+// Copyright 2009 The Go Authors. All rights reserved.
+// Copyright 2012 The Gorilla Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package json2
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Policy selects the endpoint used for each call when a Client has several.
+type Policy int
+
+const (
+	RoundRobin   Policy = iota // endpoints are used in turn
+	LeastPending               // the endpoint with fewest calls in flight
+)
+
+// endpoint is a server url with its balancing and health state.
+type endpoint struct {
+	// pending is first to be 64-bit aligned for the atomic operations on
+	// 32-bit platforms.
+	pending  int64 // calls in flight
+	url      string
+	mutex    sync.Mutex
+	healthy  bool
+	failedAt time.Time
+}
+
+func newEndpoint(url string) *endpoint {
+	return &endpoint{url: url, healthy: true}
+}
+
+// available returns true if the endpoint is healthy or it has been unhealthy
+// for longer than retryAfter, so that it's given a new chance.
+func (e *endpoint) available(now time.Time, retryAfter time.Duration) bool {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	return e.healthy || now.Sub(e.failedAt) >= retryAfter
+}
+
+// setHealthy updates the health state of the endpoint.
+func (e *endpoint) setHealthy(healthy bool) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	if !healthy {
+		e.failedAt = time.Now()
+	}
+	e.healthy = healthy
+}
+
+// isHealthy returns the last known health state of the endpoint.
+func (e *endpoint) isHealthy() bool {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	return e.healthy
+}
+
+// balancer picks endpoints according to a policy.
+type balancer struct {
+	// next is first to be 64-bit aligned for the atomic operations on
+	// 32-bit platforms.
+	next      uint64
+	endpoints []*endpoint
+}
+
+// pick returns the endpoint to use for the next attempt, skipping the ones
+// already tried. Unavailable endpoints are only returned if there is
+// nothing else left to try.
+func (b *balancer) pick(policy Policy, tried map[*endpoint]bool, retryAfter time.Duration) *endpoint {
+	now := time.Now()
+	var candidates, fallback []*endpoint
+	for _, e := range b.endpoints {
+		if tried[e] {
+			continue
+		}
+		if e.available(now, retryAfter) {
+			candidates = append(candidates, e)
+		} else {
+			fallback = append(fallback, e)
+		}
+	}
+	if len(candidates) == 0 {
+		candidates = fallback
+	}
+	if len(candidates) == 0 {
+		return nil
+	}
+	switch policy {
+	case LeastPending:
+		best := candidates[0]
+		for _, e := range candidates[1:] {
+			if atomic.LoadInt64(&e.pending) < atomic.LoadInt64(&best.pending) {
+				best = e
+			}
+		}
+		return best
+	default:
+		n := atomic.AddUint64(&b.next, 1) - 1
+		return candidates[n%uint64(len(candidates))]
+	}
+}
@@ -0,0 +1,155 @@
+// Copyright 2009 The Go Authors. All rights reserved.
+// Copyright 2012 The Gorilla Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package json2
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+
+	"github.com/gorilla/rpc/v2"
+	"github.com/gorilla/rpc/v2/jsonopt"
+)
+
+var errBatch = errors.New("rpc: batch request")
+
+// isBatch returns true if the next JSON value of r is an array.
+func isBatch(r *bufio.Reader) bool {
+	for {
+		c, err := r.ReadByte()
+		if err != nil {
+			return false
+		}
+		switch c {
+		case ' ', '\t', '\r', '\n':
+			continue
+		}
+		r.UnreadByte()
+		return c == '['
+	}
+}
+
+// newBatchCodecRequest returns a request decoding the calls of a batch
+// one at a time, see Next. A batch that doesn't start as a JSON array, or
+// an empty one, is answered by a single error response.
+func newBatchCodecRequest(r io.Reader, encoder rpc.Encoder, errorMapper func(error) error, opts func(method string) *jsonopt.Options) rpc.CodecRequest {
+	dec := json.NewDecoder(r)
+	if _, err := dec.Token(); err != nil {
+		return parsedCodecRequest(new(serverRequest), err, encoder, errorMapper, opts)
+	}
+	if !dec.More() {
+		if _, err := dec.Token(); err != nil {
+			return parsedCodecRequest(new(serverRequest), err, encoder, errorMapper, opts)
+		}
+		return &CodecRequest{
+			request: &serverRequest{Id: &null},
+			err:     &Error{Code: E_INVALID_REQ, Message: "empty batch"},
+			encoder: encoder,
+		}
+	}
+	return &BatchCodecRequest{
+		encoder:     encoder,
+		dec:         dec,
+		errorMapper: errorMapper,
+		opts:        opts,
+	}
+}
+
+// BatchCodecRequest decodes and encodes a batch of requests.
+type BatchCodecRequest struct {
+	requests    []rpc.CodecRequest
+	encoder     rpc.Encoder
+	dec         *json.Decoder // decoder of the remaining calls, or nil
+	errorMapper func(error) error
+	opts        func(method string) *jsonopt.Options
+}
+
+// Next decodes the request of the next call of the batch, or returns false
+// after the last one. Calls that aren't valid requests are answered with
+// an Invalid Request error. If the batch isn't valid JSON, the last
+// request is answered with a Parse error: the calls before it are still
+// served.
+func (b *BatchCodecRequest) Next() (rpc.CodecRequest, bool) {
+	if b.dec == nil {
+		return nil, false
+	}
+	if !b.dec.More() {
+		b.dec = nil
+		return nil, false
+	}
+	var raw json.RawMessage
+	if err := b.dec.Decode(&raw); err != nil {
+		b.dec = nil
+		return parsedCodecRequest(&serverRequest{Id: &null}, err, rpc.DefaultEncoder, b.errorMapper, b.opts), true
+	}
+	req := new(serverRequest)
+	err := json.Unmarshal(raw, req)
+	if err != nil {
+		// The call is invalid but the batch was parsed: answer with
+		// an Invalid Request error.
+		req = &serverRequest{Id: &null}
+		err = &Error{Code: E_INVALID_REQ, Message: err.Error()}
+	}
+	return parsedCodecRequest(req, err, rpc.DefaultEncoder, b.errorMapper, b.opts), true
+}
+
+// Requests decodes the remaining requests of the batch and returns them
+// with the ones already decoded by Requests, but not those returned by
+// Next.
+func (b *BatchCodecRequest) Requests() []rpc.CodecRequest {
+	for req, ok := b.Next(); ok; req, ok = b.Next() {
+		b.requests = append(b.requests, req)
+	}
+	return b.requests
+}
+
+// WriteBatch writes the responses of the batch as an array. Nothing is
+// written if all the calls were notifications.
+func (b *BatchCodecRequest) WriteBatch(w http.ResponseWriter, responses [][]byte) {
+	var buf bytes.Buffer
+	for _, res := range responses {
+		res = bytes.TrimSpace(res)
+		if len(res) == 0 {
+			continue
+		}
+		if buf.Len() == 0 {
+			buf.WriteByte('[')
+		} else {
+			buf.WriteByte(',')
+		}
+		buf.Write(res)
+	}
+	if buf.Len() == 0 {
+		return
+	}
+	buf.WriteString("]\n")
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	b.encoder.Encode(w).Write(buf.Bytes())
+}
+
+// Method returns an error: the methods are those of the requests of the
+// batch.
+func (b *BatchCodecRequest) Method() (string, error) {
+	return "", errBatch
+}
+
+// ReadRequest returns an error, see Method.
+func (b *BatchCodecRequest) ReadRequest(args interface{}) error {
+	return errBatch
+}
+
+// WriteResponse does nothing: responses are written by WriteBatch.
+func (b *BatchCodecRequest) WriteResponse(w http.ResponseWriter, reply interface{}) {
+}
+
+// WriteError writes a single error response, as for invalid batches.
+func (b *BatchCodecRequest) WriteError(w http.ResponseWriter, status int, err error) {
+	c := &CodecRequest{request: &serverRequest{Id: &null}, encoder: b.encoder}
+	c.WriteError(w, status, err)
+}
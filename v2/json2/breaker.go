@@ -0,0 +1,183 @@
+// Copyright 2009 The Go Authors. All rights reserved.
+// Copyright 2012 The Gorilla Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package json2
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+// Defaults of the zero fields of a BreakerPolicy.
+const (
+	DefaultBreakerErrorRate   = 0.5
+	DefaultBreakerMinRequests = 10
+	DefaultBreakerWindow      = 10 * time.Second
+	DefaultBreakerOpenTimeout = 5 * time.Second
+)
+
+// ErrCircuitOpen is returned by a Client when the circuit breakers of all
+// endpoints are open for the called method.
+var ErrCircuitOpen = errors.New("rpc: circuit breaker is open")
+
+// BreakerPolicy configures the circuit breakers of a Client.
+//
+// A breaker is kept per endpoint and method. It opens when the rate of
+// failed calls within Window reaches ErrorRate, after at least MinRequests
+// calls. While open, calls fail fast with ErrCircuitOpen. After OpenTimeout
+// a single probe call is let through: the breaker closes if it succeeds and
+// opens again otherwise. A probe without outcome after OpenTimeout, e.g.
+// canceled, lets another one through. Zero fields take their defaults, see
+// DefaultBreakerErrorRate.
+//
+// Failures are transport errors, 5xx responses, undecodable responses and
+// E_INTERNAL errors. Other JSON-RPC errors are considered application
+// errors and don't trip the breaker.
+type BreakerPolicy struct {
+	ErrorRate   float64
+	MinRequests int
+	Window      time.Duration
+	OpenTimeout time.Duration
+}
+
+func (p *BreakerPolicy) errorRate() float64 {
+	if p.ErrorRate > 0 {
+		return p.ErrorRate
+	}
+	return DefaultBreakerErrorRate
+}
+
+func (p *BreakerPolicy) minRequests() int {
+	if p.MinRequests > 0 {
+		return p.MinRequests
+	}
+	return DefaultBreakerMinRequests
+}
+
+func (p *BreakerPolicy) window() time.Duration {
+	if p.Window > 0 {
+		return p.Window
+	}
+	return DefaultBreakerWindow
+}
+
+func (p *BreakerPolicy) openTimeout() time.Duration {
+	if p.OpenTimeout > 0 {
+		return p.OpenTimeout
+	}
+	return DefaultBreakerOpenTimeout
+}
+
+type breakerState int
+
+const (
+	breakerClosed breakerState = iota
+	breakerOpen
+	breakerHalfOpen
+)
+
+// breaker is the circuit breaker of an endpoint and method.
+type breaker struct {
+	mutex       sync.Mutex
+	state       breakerState
+	windowStart time.Time
+	requests    int
+	failures    int
+	openedAt    time.Time
+	probedAt    time.Time
+}
+
+// allow returns true if a call can go through.
+func (b *breaker) allow(p *BreakerPolicy, now time.Time) bool {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	switch b.state {
+	case breakerOpen:
+		if now.Sub(b.openedAt) < p.openTimeout() {
+			return false
+		}
+	case breakerHalfOpen:
+		if now.Sub(b.probedAt) < p.openTimeout() {
+			return false
+		}
+	default:
+		return true
+	}
+	// Let a single probe through.
+	b.state, b.probedAt = breakerHalfOpen, now
+	return true
+}
+
+// release gives up a call without outcome, e.g. canceled, so that a
+// probe doesn't leave the breaker half-open.
+func (b *breaker) release() {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	if b.state == breakerHalfOpen {
+		b.state = breakerOpen
+	}
+}
+
+// record records the outcome of a call.
+func (b *breaker) record(p *BreakerPolicy, now time.Time, failed bool) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	if b.state == breakerHalfOpen {
+		if failed {
+			b.state, b.openedAt = breakerOpen, now
+		} else {
+			b.state = breakerClosed
+			b.windowStart, b.requests, b.failures = now, 0, 0
+		}
+		return
+	}
+	if now.Sub(b.windowStart) >= p.window() {
+		b.windowStart, b.requests, b.failures = now, 0, 0
+	}
+	b.requests++
+	if failed {
+		b.failures++
+	}
+	if failed && b.requests >= p.minRequests() && float64(b.failures) >= p.errorRate()*float64(b.requests) {
+		b.state, b.openedAt = breakerOpen, now
+	}
+}
+
+// breakers holds the breakers of a Client by endpoint and method.
+type breakers struct {
+	mutex sync.Mutex
+	m     map[breakerKey]*breaker
+}
+
+type breakerKey struct {
+	url, method string
+}
+
+func (bs *breakers) get(url, method string) *breaker {
+	bs.mutex.Lock()
+	defer bs.mutex.Unlock()
+	if bs.m == nil {
+		bs.m = make(map[breakerKey]*breaker)
+	}
+	key := breakerKey{url, method}
+	b := bs.m[key]
+	if b == nil {
+		b = &breaker{windowStart: time.Now()}
+		bs.m[key] = b
+	}
+	return b
+}
+
+// isBreakerFailure returns true if err counts as a failure for breakers.
+func isBreakerFailure(err error) bool {
+	if err == nil || err == ErrNullResult {
+		return false
+	}
+	if jsonErr, ok := err.(*Error); ok {
+		return jsonErr.Code == E_INTERNAL
+	}
+	return true
+}
@@ -0,0 +1,252 @@
+// Copyright 2009 The Go Authors. All rights reserved.
+// Copyright 2012 The Gorilla Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package json2
+
+import (
+	"container/list"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/rpc/v2"
+	"github.com/gorilla/rpc/v2/jsonopt"
+)
+
+// DefaultCacheEntries is the number of responses kept by the cache of a
+// Client if CachePolicy.MaxEntries is zero.
+const DefaultCacheEntries = 1024
+
+// CachePolicy configures the response cache of a Client.
+//
+// Responses with an ETag, see rpc.ETagger, are stored per method, params
+// and tenant, see rpc.WithTenant. Later calls with the same params send the ETag in the
+// "If-None-Match" header, and get the stored result when the server
+// answers that it's not modified, instead of rpc.ErrNotModified. The
+// least recently used responses are evicted first.
+//
+// With a TTL, stored responses are used without a request until they are
+// older than the TTL, counted from the last time the server sent or
+// confirmed them. Expired responses can then still be used for
+// StaleWhileRevalidate, while a single request refreshes them in the
+// background, so that the calls of a hot method don't all wait for, and
+// hit, the server when its response expires.
+type CachePolicy struct {
+	// Methods are the cached methods. If empty, all methods are cached.
+	Methods []string
+	// MaxEntries is the number of responses kept. If zero,
+	// DefaultCacheEntries is used.
+	MaxEntries int
+	// TTL is the time stored responses are used without a request. If
+	// zero, the max-age of their "Cache-Control" header is used, see
+	// rpc.MethodConfig.CacheTTL, and without one a conditional request is
+	// sent for each call.
+	TTL time.Duration
+	// StaleWhileRevalidate is the time after the TTL that stored responses
+	// are still used, while they are refreshed in the background.
+	StaleWhileRevalidate time.Duration
+	// Singleflight makes concurrent calls with the same params share a
+	// single request when their response isn't stored or has expired,
+	// as for a CoalescePolicy.
+	Singleflight bool
+}
+
+// hasMethod returns true if methods is empty or contains the method.
+func hasMethod(methods []string, method string) bool {
+	if len(methods) == 0 {
+		return true
+	}
+	for _, m := range methods {
+		if m == method {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *CachePolicy) maxEntries() int {
+	if p.MaxEntries > 0 {
+		return p.MaxEntries
+	}
+	return DefaultCacheEntries
+}
+
+// cacheEntry is a cached response. Entries are replaced, not modified, so
+// they can be used after get returns.
+type cacheEntry struct {
+	key    string
+	etag   string
+	body   []byte
+	stored time.Time // when the server last sent or confirmed the response
+	maxAge time.Duration
+}
+
+// cache is the LRU cache of the responses of a Client.
+type cache struct {
+	mutex      sync.Mutex
+	entries    map[string]*list.Element
+	lru        list.List
+	refreshing map[string]bool
+}
+
+// callKey returns the key identifying the calls of the method with args
+// made for the tenant of the context, if any, so that tenants don't share
+// responses.
+func callKey(ctx context.Context, method string, args interface{}, opts *jsonopt.Options) (string, error) {
+	params, err := opts.Marshal(args)
+	if err != nil {
+		return "", err
+	}
+	h := sha256.New()
+	if tenant, ok := rpc.TenantFrom(ctx); ok {
+		h.Write([]byte(tenant))
+	}
+	h.Write([]byte{0})
+	h.Write(params)
+	return method + ":" + hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// get returns the entry stored for key, or nil.
+func (c *cache) get(key string) *cacheEntry {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	el, ok := c.entries[key]
+	if !ok {
+		return nil
+	}
+	c.lru.MoveToFront(el)
+	return el.Value.(*cacheEntry)
+}
+
+// put stores the response body with its ETag and max-age, evicting the
+// least recently used entries beyond max.
+func (c *cache) put(key, etag string, body []byte, maxAge time.Duration, max int) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if c.entries == nil {
+		c.entries = make(map[string]*list.Element)
+	}
+	entry := &cacheEntry{key: key, etag: etag, body: body, stored: time.Now(), maxAge: maxAge}
+	if el, ok := c.entries[key]; ok {
+		el.Value = entry
+		c.lru.MoveToFront(el)
+		return
+	}
+	c.entries[key] = c.lru.PushFront(entry)
+	for c.lru.Len() > max {
+		el := c.lru.Back()
+		c.lru.Remove(el)
+		delete(c.entries, el.Value.(*cacheEntry).key)
+	}
+}
+
+// touch marks the entry of key as confirmed by the server, with a new
+// max-age.
+func (c *cache) touch(key string, maxAge time.Duration) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if el, ok := c.entries[key]; ok {
+		entry := *el.Value.(*cacheEntry)
+		entry.stored, entry.maxAge = time.Now(), maxAge
+		el.Value = &entry
+	}
+}
+
+// startRefresh returns true if the entry of key isn't being refreshed, and
+// marks it as being refreshed until endRefresh is called.
+func (c *cache) startRefresh(key string) bool {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if c.refreshing[key] {
+		return false
+	}
+	if c.refreshing == nil {
+		c.refreshing = make(map[string]bool)
+	}
+	c.refreshing[key] = true
+	return true
+}
+
+func (c *cache) endRefresh(key string) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	delete(c.refreshing, key)
+}
+
+// maxAge returns the max-age of the "Cache-Control" header, or zero.
+func maxAge(h http.Header) time.Duration {
+	for _, directive := range strings.Split(h.Get("Cache-Control"), ",") {
+		directive = strings.TrimSpace(directive)
+		if strings.HasPrefix(directive, "max-age=") {
+			if n, err := strconv.Atoi(directive[len("max-age="):]); err == nil && n > 0 {
+				return time.Duration(n) * time.Second
+			}
+		}
+	}
+	return 0
+}
+
+// cachedReply returns the stored response of a call if it can be used
+// without waiting for a request, refreshing it in the background if it's
+// stale.
+func (c *Client) cachedReply(ctx context.Context, method, key string, entry *cacheEntry, body []byte) ([]byte, bool) {
+	if entry == nil {
+		return nil, false
+	}
+	ttl := c.Cache.TTL
+	if ttl <= 0 {
+		ttl = entry.maxAge
+	}
+	if ttl <= 0 {
+		return nil, false
+	}
+	age := time.Since(entry.stored)
+	if age < ttl {
+		return entry.body, true
+	}
+	if age >= ttl+c.Cache.StaleWhileRevalidate {
+		return nil, false
+	}
+	if c.cache.startRefresh(key) {
+		go c.refresh(ctx, method, key, entry, body)
+	}
+	return entry.body, true
+}
+
+// refresh sends the request of a stale entry, to store its new response.
+// The request isn't tied to the call ctx that found the entry stale: it
+// only carries its values, e.g. its tenant, without its deadline nor its
+// ResponseInfo.
+func (c *Client) refresh(ctx context.Context, method, key string, entry *cacheEntry, body []byte) {
+	defer c.cache.endRefresh(key)
+	ctx = WithResponseInfo(detachedContext{ctx}, nil)
+	ctx = withCachedCall(ctx, &cachedCall{key: key, entry: entry})
+	var result json.RawMessage
+	c.call(ctx, method, body, &result)
+}
+
+// cachedCall is a call whose response is cached under key; entry is nil
+// if it isn't cached yet.
+type cachedCall struct {
+	key   string
+	entry *cacheEntry
+}
+
+type cachedCallKey struct{}
+
+func withCachedCall(ctx context.Context, call *cachedCall) context.Context {
+	return context.WithValue(ctx, cachedCallKey{}, call)
+}
+
+func cachedCallFromContext(ctx context.Context) *cachedCall {
+	call, _ := ctx.Value(cachedCallKey{}).(*cachedCall)
+	return call
+}
@@ -9,6 +9,8 @@ import (
 	"encoding/json"
 	"io"
 	"math/rand"
+
+	"github.com/gorilla/rpc/v2/jsonopt"
 )
 
 // ----------------------------------------------------------------------------
@@ -40,18 +42,35 @@ type clientResponse struct {
 
 // EncodeClientRequest encodes parameters for a JSON-RPC client request.
 func EncodeClientRequest(method string, args interface{}) ([]byte, error) {
+	return encodeClientRequest(method, args, nil)
+}
+
+// encodeClientRequest encodes a client request with the JSON options.
+func encodeClientRequest(method string, args interface{}, opts *jsonopt.Options) ([]byte, error) {
 	c := &clientRequest{
 		Version: "2.0",
 		Method:  method,
 		Params:  args,
 		Id:      uint64(rand.Int63()),
 	}
+	if opts.Converts(args) {
+		params, err := opts.Marshal(args)
+		if err != nil {
+			return nil, err
+		}
+		c.Params = json.RawMessage(params)
+	}
 	return json.Marshal(c)
 }
 
 // DecodeClientResponse decodes the response body of a client request into
 // the interface reply.
 func DecodeClientResponse(r io.Reader, reply interface{}) error {
+	return decodeClientResponse(r, reply, nil)
+}
+
+// decodeClientResponse decodes a response with the JSON options.
+func decodeClientResponse(r io.Reader, reply interface{}, opts *jsonopt.Options) error {
 	var c clientResponse
 	if err := json.NewDecoder(r).Decode(&c); err != nil {
 		return err
@@ -71,5 +90,5 @@ func DecodeClientResponse(r io.Reader, reply interface{}) error {
 		return ErrNullResult
 	}
 
-	return json.Unmarshal(*c.Result, reply)
+	return opts.Unmarshal(*c.Result, reply)
 }
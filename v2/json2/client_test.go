@@ -0,0 +1,869 @@
+// Copyright 2009 The Go Authors. All rights reserved.
+// Copyright 2012 The Gorilla Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package json2
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gorilla/rpc/v2"
+)
+
+func newTestServer() *httptest.Server {
+	s := rpc.NewServer()
+	s.RegisterCodec(NewCodec(), "application/json")
+	s.RegisterService(new(Service1), "")
+	return httptest.NewServer(s)
+}
+
+func TestClientFailover(t *testing.T) {
+	up := newTestServer()
+	defer up.Close()
+	down := newTestServer()
+	down.Close()
+
+	c := NewClient(down.URL, up.URL)
+	for i := 0; i < 3; i++ {
+		var res Service1Response
+		if err := c.Call(context.Background(), "Service1.Multiply", &Service1Request{4, 2}, &res); err != nil {
+			t.Fatal("Expected err to be nil, but got:", err)
+		}
+		if res.Result != 8 {
+			t.Errorf("Wrong response: %v.", res.Result)
+		}
+	}
+	if healthy := c.Healthy(); len(healthy) != 1 || healthy[0] != up.URL {
+		t.Errorf("Expected only %s to be healthy, got %v", up.URL, healthy)
+	}
+
+	c.CheckHealth(context.Background())
+	if healthy := c.Healthy(); len(healthy) != 1 {
+		t.Errorf("Expected one healthy endpoint after health check, got %v", healthy)
+	}
+}
+
+func TestClientNoFailoverAfterSend(t *testing.T) {
+	release := make(chan struct{})
+	slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	defer slow.Close()
+	defer close(release)
+	calls := 0
+	up := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+	}))
+	defer up.Close()
+
+	// The slow server may have executed the call that timed out.
+	c := NewClient(slow.URL, up.URL)
+	c.HTTPClient = &http.Client{Timeout: 20 * time.Millisecond}
+	var res Service1Response
+	if err := c.Call(context.Background(), "Service1.Multiply", &Service1Request{4, 2}, &res); err == nil {
+		t.Error("Expected a timeout error")
+	}
+	if calls != 0 {
+		t.Errorf("Expected no fail over, got %d calls", calls)
+	}
+}
+
+func TestClientNoEndpoints(t *testing.T) {
+	var res Service1Response
+	if err := NewClient().Call(context.Background(), "Service1.Multiply", &Service1Request{}, &res); err != ErrNoEndpoints {
+		t.Errorf("Expected ErrNoEndpoints, got %v", err)
+	}
+}
+
+func TestBalancerPolicies(t *testing.T) {
+	b := &balancer{endpoints: []*endpoint{newEndpoint("a"), newEndpoint("b")}}
+	first := b.pick(RoundRobin, nil, DefaultRetryUnhealthyAfter)
+	second := b.pick(RoundRobin, nil, DefaultRetryUnhealthyAfter)
+	if first == second {
+		t.Errorf("Expected round robin to alternate endpoints")
+	}
+
+	b.endpoints[0].pending = 3
+	if e := b.pick(LeastPending, nil, DefaultRetryUnhealthyAfter); e != b.endpoints[1] {
+		t.Errorf("Expected least pending endpoint b, got %s", e.url)
+	}
+
+	b.endpoints[1].setHealthy(false)
+	if e := b.pick(LeastPending, nil, DefaultRetryUnhealthyAfter); e != b.endpoints[0] {
+		t.Errorf("Expected healthy endpoint a, got %s", e.url)
+	}
+}
+
+func TestClientBreaker(t *testing.T) {
+	failing := true
+	backend := newTestServer()
+	defer backend.Close()
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if failing {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		backend.Config.Handler.ServeHTTP(w, r)
+	}))
+	defer ts.Close()
+
+	c := NewClient(ts.URL)
+	c.Breaker = &BreakerPolicy{ErrorRate: 0.5, MinRequests: 2, Window: time.Minute, OpenTimeout: 20 * time.Millisecond}
+	var res Service1Response
+	for i := 0; i < 2; i++ {
+		if err := c.Call(context.Background(), "Service1.Multiply", &Service1Request{4, 2}, &res); err == nil || err == ErrCircuitOpen {
+			t.Fatalf("Expected server error, got %v", err)
+		}
+	}
+	if err := c.Call(context.Background(), "Service1.Multiply", &Service1Request{4, 2}, &res); err != ErrCircuitOpen {
+		t.Fatalf("Expected ErrCircuitOpen, got %v", err)
+	}
+
+	// After the open timeout a successful probe closes the breaker.
+	failing = false
+	time.Sleep(30 * time.Millisecond)
+	for i := 0; i < 2; i++ {
+		if err := c.Call(context.Background(), "Service1.Multiply", &Service1Request{4, 2}, &res); err != nil {
+			t.Fatalf("Expected err to be nil, but got: %v", err)
+		}
+	}
+}
+
+func TestClientBreakerCanceledProbe(t *testing.T) {
+	release := make(chan struct{})
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	defer ts.Close()
+	defer close(release)
+
+	c := NewClient(ts.URL)
+	c.Breaker = &BreakerPolicy{OpenTimeout: time.Hour}
+	b := c.breakers.get(ts.URL, "Service1.Multiply")
+	b.state = breakerOpen
+	// The probe is canceled, and the next call probes again.
+	for i := 0; i < 2; i++ {
+		ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+		var res Service1Response
+		err := c.Call(ctx, "Service1.Multiply", &Service1Request{4, 2}, &res)
+		cancel()
+		if err == nil || err == ErrCircuitOpen {
+			t.Fatalf("Expected the probe to time out, got %v", err)
+		}
+	}
+}
+
+func TestBreakerPolicyDefaults(t *testing.T) {
+	p, b, now := &BreakerPolicy{}, &breaker{windowStart: time.Now()}, time.Now()
+	for i := 0; i < DefaultBreakerMinRequests; i++ {
+		b.record(p, now, false)
+	}
+	if !b.allow(p, now) {
+		t.Fatal("Expected successful calls to keep the breaker closed")
+	}
+	for i := 0; i < DefaultBreakerMinRequests; i++ {
+		b.record(p, now, true)
+	}
+	if b.allow(p, now) {
+		t.Fatal("Expected failures to open the breaker")
+	}
+	if !b.allow(p, now.Add(DefaultBreakerOpenTimeout)) || b.allow(p, now.Add(DefaultBreakerOpenTimeout)) {
+		t.Fatal("Expected a single probe after the open timeout")
+	}
+	if !b.allow(p, now.Add(2*DefaultBreakerOpenTimeout)) {
+		t.Error("Expected another probe when the first one has no outcome")
+	}
+}
+
+func TestClientAffinity(t *testing.T) {
+	s := rpc.NewServer()
+	s.RegisterCodec(NewCodec(), "application/json")
+	s.RegisterService(new(Service1), "")
+	s.EnableAffinity(true)
+	tokens := make(chan string, 2)
+	s.RegisterBeforeFunc(func(i *rpc.RequestInfo) {
+		tokens <- rpc.AffinityFromContext(i.Request.Context())
+	})
+	ts := httptest.NewServer(s)
+	defer ts.Close()
+
+	c := NewClient(ts.URL)
+	for i := 0; i < 2; i++ {
+		var res Service1Response
+		if err := c.Call(context.Background(), "Service1.Multiply", &Service1Request{4, 2}, &res); err != nil {
+			t.Fatal(err)
+		}
+	}
+	first, second := <-tokens, <-tokens
+	if first == "" || first != second || c.Affinity() != first {
+		t.Errorf("Expected the token to be echoed, got %q, %q, %q", first, second, c.Affinity())
+	}
+}
+
+func TestClientPropagation(t *testing.T) {
+	s := rpc.NewServer()
+	s.RegisterCodec(NewCodec(), "application/json")
+	s.RegisterService(new(Service1), "")
+	headers := make(chan http.Header, 1)
+	s.RegisterBeforeFunc(func(i *rpc.RequestInfo) {
+		h := make(http.Header)
+		rpc.Propagate(i.Request.Context(), h)
+		headers <- h
+	})
+	ts := httptest.NewServer(s)
+	defer ts.Close()
+
+	in := make(http.Header)
+	in.Set("Traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	in.Set("X-Correlation-Id", "42")
+	in.Set("Authorization", "secret")
+	ctx := rpc.WithPropagation(context.Background(), in)
+
+	var res Service1Response
+	if err := NewClient(ts.URL).Call(ctx, "Service1.Multiply", &Service1Request{4, 2}, &res); err != nil {
+		t.Fatal(err)
+	}
+	h := <-headers
+	if h.Get("Traceparent") != in.Get("Traceparent") || h.Get("X-Correlation-Id") != "42" {
+		t.Errorf("Expected headers to be propagated, got %v", h)
+	}
+	if h.Get("Authorization") != "" {
+		t.Error("Expected Authorization not to be propagated")
+	}
+}
+
+type CountService struct{}
+
+func (t *CountService) Count(r *http.Request, n *int, s *rpc.Stream) error {
+	for i := 1; i <= *n; i++ {
+		if err := s.Send(i); err != nil {
+			return err
+		}
+	}
+	if *n > 2 {
+		return errors.New("too many")
+	}
+	return nil
+}
+
+func TestClientStream(t *testing.T) {
+	s := rpc.NewServer()
+	s.RegisterCodec(NewCodec(), "application/json")
+	s.RegisterService(new(CountService), "")
+	ts := httptest.NewServer(s)
+	defer ts.Close()
+	c := NewClient(ts.URL)
+
+	read := func(n int) ([]int, error) {
+		stream, err := c.Stream(context.Background(), "CountService.Count", n)
+		if err != nil {
+			return nil, err
+		}
+		defer stream.Close()
+		var results []int
+		for {
+			var i int
+			if err := stream.Recv(&i); err == io.EOF {
+				return results, nil
+			} else if err != nil {
+				return results, err
+			}
+			results = append(results, i)
+		}
+	}
+
+	results, err := read(2)
+	if err != nil || len(results) != 2 || results[1] != 2 {
+		t.Fatalf("Wrong results: %v, %v", results, err)
+	}
+	results, err = read(3)
+	if len(results) != 3 || err == nil || err.(*Error).Message != "too many" {
+		t.Fatalf("Expected results then error, got %v, %v", results, err)
+	}
+}
+
+func TestClientStreamTrailers(t *testing.T) {
+	s := rpc.NewServer()
+	s.RegisterCodec(NewCodec(), "application/json")
+	s.RegisterService(new(CountService), "")
+	ts := httptest.NewServer(s)
+	defer ts.Close()
+
+	stream, err := NewClient(ts.URL).Stream(context.Background(), "CountService.Count", 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var i int
+	for err == nil {
+		err = stream.Recv(&i)
+	}
+	stream.Close()
+	if jsonErr, ok := err.(*Error); !ok || jsonErr.Message != "too many" {
+		t.Fatalf("Expected the error of the method, got %v", err)
+	}
+	if got := stream.trailer.Get(rpc.StatusTrailer); got != "400" {
+		t.Errorf("Expected the status trailer 400, got %q", got)
+	}
+
+	// A stream interrupted before its trailers isn't a success.
+	truncated := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Trailer", rpc.StatusTrailer)
+		w.Write([]byte(`{"jsonrpc":"2.0","result":1,"id":1}` + "\n"))
+	}))
+	defer truncated.Close()
+	stream, err = NewClient(truncated.URL).Stream(context.Background(), "CountService.Count", 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stream.Close()
+	if err := stream.Recv(&i); err != nil || i != 1 {
+		t.Fatalf("Expected the first result, got %d %v", i, err)
+	}
+	if err := stream.Recv(&i); err != io.ErrUnexpectedEOF {
+		t.Errorf("Expected io.ErrUnexpectedEOF, got %v", err)
+	}
+}
+
+type ExportService struct{}
+
+func (ExportService) Channel(r *http.Request, args *int, reply *<-chan int) error {
+	ch := make(chan int)
+	go func() {
+		defer close(ch)
+		for i := 1; i <= *args; i++ {
+			select {
+			case ch <- i:
+			case <-r.Context().Done():
+				return
+			}
+		}
+	}()
+	*reply = ch
+	return nil
+}
+
+func (ExportService) Iterator(r *http.Request, args *int, reply *func(yield func(int, error) bool)) error {
+	n := *args
+	*reply = func(yield func(int, error) bool) {
+		for i := 1; i <= n; i++ {
+			if i == 3 {
+				yield(0, errors.New("too many"))
+				return
+			}
+			if !yield(i, nil) {
+				return
+			}
+		}
+	}
+	return nil
+}
+
+func TestClientStreamItems(t *testing.T) {
+	s := rpc.NewServer()
+	s.RegisterCodec(NewCodec(), "application/json")
+	s.RegisterService(new(ExportService), "")
+	ts := httptest.NewServer(s)
+	defer ts.Close()
+	c := NewClient(ts.URL)
+
+	read := func(method string, n int, items bool) ([]int, error) {
+		stream, err := c.Stream(context.Background(), method, n)
+		if items {
+			stream, err = c.StreamItems(context.Background(), method, n)
+		}
+		if err != nil {
+			return nil, err
+		}
+		defer stream.Close()
+		if stream.ndjson != items {
+			t.Errorf("Expected NDJSON %v for %s, got %v", items, method, stream.ndjson)
+		}
+		var results []int
+		var i int
+		for stream.Next(&i) {
+			results = append(results, i)
+		}
+		return results, stream.Err()
+	}
+	for _, items := range []bool{false, true} {
+		results, err := read("ExportService.Channel", 3, items)
+		if err != nil || len(results) != 3 || results[2] != 3 {
+			t.Errorf("Wrong results: %v, %v", results, err)
+		}
+		results, err = read("ExportService.Channel", 0, items)
+		if err != nil || len(results) != 0 {
+			t.Errorf("Expected no results, got %v, %v", results, err)
+		}
+		results, err = read("ExportService.Iterator", 2, items)
+		if err != nil || len(results) != 2 {
+			t.Errorf("Wrong results: %v, %v", results, err)
+		}
+		results, err = read("ExportService.Iterator", 5, items)
+		if len(results) != 2 || err == nil || err.(*Error).Message != "too many" {
+			t.Errorf("Expected results then error, got %v, %v", results, err)
+		}
+	}
+
+	r, _ := http.NewRequest("POST", ts.URL, strings.NewReader(`{"jsonrpc":"2.0","method":"ExportService.Channel","params":2,"id":1}`))
+	r.Header.Set("Content-Type", "application/json")
+	r.Header.Set("Accept", rpc.NDJSONContentType)
+	resp, err := http.DefaultClient.Do(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if b, _ := ioutil.ReadAll(resp.Body); string(b) != "1\n2\n" || resp.Header.Get("Content-Type") != rpc.NDJSONContentType {
+		t.Errorf("Wrong NDJSON response %q", b)
+	}
+}
+
+type SessionService struct{}
+
+func (SessionService) Login(r *http.Request, args *string, reply *string, meta *rpc.ResponseMeta) error {
+	meta.SetCookie(&http.Cookie{Name: "session", Value: *args})
+	meta.SetHeader("X-User", *args)
+	meta.SetTrailer("X-Audit", "logged")
+	*reply = "welcome"
+	return nil
+}
+
+func TestClientResponseInfo(t *testing.T) {
+	s := rpc.NewServer()
+	s.RegisterCodec(NewCodec(), "application/json")
+	s.RegisterService(new(SessionService), "Session")
+	ts := httptest.NewServer(s)
+	defer ts.Close()
+
+	var info ResponseInfo
+	var reply string
+	ctx := WithResponseInfo(context.Background(), &info)
+	if err := NewClient(ts.URL).Call(ctx, "Session.Login", "bob", &reply); err != nil || reply != "welcome" {
+		t.Fatalf("Expected welcome, got %q %v", reply, err)
+	}
+	if cookies := info.Cookies(); len(cookies) != 1 || cookies[0].Value != "bob" {
+		t.Errorf("Wrong cookies %v", cookies)
+	}
+	if info.StatusCode != http.StatusOK || info.Header.Get("X-User") != "bob" || info.Trailer.Get("X-Audit") != "logged" {
+		t.Errorf("Wrong response %+v", info)
+	}
+}
+
+func TestClientCache(t *testing.T) {
+	s := rpc.NewServer()
+	s.RegisterCodec(NewCodec(), "application/json")
+	s.RegisterService(new(VersionedService), "Versioned")
+	var notModified int
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			notModified++
+		}
+		s.ServeHTTP(w, r)
+	}))
+	defer ts.Close()
+
+	c := NewClient(ts.URL)
+	c.Cache = &CachePolicy{Methods: []string{"Versioned.Get"}}
+	for i := 0; i < 3; i++ {
+		var reply VersionedResponse
+		if err := c.Call(context.Background(), "Versioned.Get", struct{}{}, &reply); err != nil || reply.Version != "v1" {
+			t.Fatalf("Expected v1, got %q %v", reply.Version, err)
+		}
+	}
+	if notModified != 2 {
+		t.Errorf("Expected 2 conditional calls, got %d", notModified)
+	}
+
+	var cache cache
+	cache.put("a", `"1"`, nil, 0, 2)
+	cache.put("b", `"1"`, nil, 0, 2)
+	cache.get("a")
+	cache.put("c", `"1"`, nil, 0, 2)
+	if cache.get("b") != nil || cache.get("a") == nil || cache.get("c") == nil {
+		t.Error("Expected the least recently used entry to be evicted")
+	}
+}
+
+func TestClientCacheStampede(t *testing.T) {
+	s := rpc.NewServer()
+	s.RegisterCodec(NewCodec(), "application/json")
+	s.RegisterService(new(VersionedService), "Versioned")
+	var requests int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		time.Sleep(50 * time.Millisecond)
+		s.ServeHTTP(w, r)
+	}))
+	defer ts.Close()
+
+	c := NewClient(ts.URL)
+	c.Cache = &CachePolicy{TTL: 100 * time.Millisecond, StaleWhileRevalidate: time.Minute, Singleflight: true}
+	calls := func() {
+		var wg sync.WaitGroup
+		for i := 0; i < 5; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				var reply VersionedResponse
+				if err := c.Call(context.Background(), "Versioned.Get", struct{}{}, &reply); err != nil || reply.Version != "v1" {
+					t.Errorf("Expected v1, got %q %v", reply.Version, err)
+				}
+			}()
+		}
+		wg.Wait()
+	}
+	calls()
+	if n := atomic.LoadInt32(&requests); n != 1 {
+		t.Errorf("Expected the calls to share a request, got %d", n)
+	}
+	calls()
+	if n := atomic.LoadInt32(&requests); n != 1 {
+		t.Errorf("Expected the fresh response to be used, got %d requests", n)
+	}
+
+	// Stale responses are used while a single request refreshes them.
+	time.Sleep(100 * time.Millisecond)
+	start := time.Now()
+	calls()
+	if d := time.Since(start); d >= 50*time.Millisecond {
+		t.Errorf("Expected the stale response without waiting, waited %v", d)
+	}
+	for deadline := time.Now().Add(time.Second); atomic.LoadInt32(&requests) < 2 && time.Now().Before(deadline); {
+		time.Sleep(time.Millisecond)
+	}
+	time.Sleep(100 * time.Millisecond)
+	if n := atomic.LoadInt32(&requests); n != 2 {
+		t.Errorf("Expected a single refresh, got %d requests", n-1)
+	}
+	calls()
+	if n := atomic.LoadInt32(&requests); n != 2 {
+		t.Errorf("Expected the refreshed response to be fresh, got %d requests", n)
+	}
+}
+
+func TestClientCacheMaxAge(t *testing.T) {
+	s := rpc.NewServer()
+	s.RegisterCodec(NewCodec(), "application/json")
+	s.RegisterService(new(VersionedService), "Versioned")
+	s.SetConfig(&rpc.Config{Methods: map[string]*rpc.MethodConfig{
+		"Versioned.Get": {CacheTTL: rpc.Duration(time.Minute)},
+	}})
+	var requests int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		s.ServeHTTP(w, r)
+	}))
+	defer ts.Close()
+
+	c := NewClient(ts.URL)
+	c.Cache = &CachePolicy{}
+	for i := 0; i < 3; i++ {
+		var reply VersionedResponse
+		if err := c.Call(context.Background(), "Versioned.Get", struct{}{}, &reply); err != nil || reply.Version != "v1" {
+			t.Fatalf("Expected v1, got %q %v", reply.Version, err)
+		}
+	}
+	if n := atomic.LoadInt32(&requests); n != 1 {
+		t.Errorf("Expected the max-age of the response to be used, got %d requests", n)
+	}
+}
+
+type TenantService struct{}
+
+func (TenantService) Get(r *http.Request, args *struct{}, reply *VersionedResponse) error {
+	reply.Version = r.Header.Get(rpc.TenantHeader)
+	return nil
+}
+
+func TestClientCacheTenant(t *testing.T) {
+	s := rpc.NewServer()
+	s.RegisterCodec(NewCodec(), "application/json")
+	s.RegisterService(new(TenantService), "Tenant")
+	s.SetConfig(&rpc.Config{Methods: map[string]*rpc.MethodConfig{
+		"Tenant.Get": {CacheTTL: rpc.Duration(time.Minute)},
+	}})
+	ts := httptest.NewServer(s)
+	defer ts.Close()
+
+	c := NewClient(ts.URL)
+	c.Cache = &CachePolicy{Singleflight: true}
+	for _, tenant := range []string{"a", "b", "a", "b"} {
+		var reply VersionedResponse
+		ctx := rpc.WithTenant(context.Background(), tenant)
+		if err := c.Call(ctx, "Tenant.Get", struct{}{}, &reply); err != nil || reply.Version != tenant {
+			t.Fatalf("Expected the response of tenant %s, got %q %v", tenant, reply.Version, err)
+		}
+	}
+}
+
+func TestClientHedge(t *testing.T) {
+	fast := newTestServer()
+	defer fast.Close()
+	s := rpc.NewServer()
+	s.RegisterCodec(NewCodec(), "application/json")
+	s.RegisterService(new(Service1), "")
+	slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-time.After(5 * time.Second):
+		}
+		s.ServeHTTP(w, r)
+	}))
+	defer slow.Close()
+
+	c := NewClient(slow.URL, fast.URL)
+	// Calls aren't hedged without Methods.
+	c.Hedge = &HedgePolicy{Delay: 10 * time.Millisecond}
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	var res Service1Response
+	if err := c.Call(ctx, "Service1.Multiply", &Service1Request{4, 2}, &res); err == nil {
+		t.Fatalf("Expected the unhedged call to time out, got %v", res.Result)
+	}
+
+	c = NewClient(slow.URL, fast.URL)
+	c.Hedge = &HedgePolicy{Delay: 10 * time.Millisecond, Methods: []string{"Service1.Multiply"}}
+	start := time.Now()
+	if err := c.Call(context.Background(), "Service1.Multiply", &Service1Request{4, 2}, &res); err != nil || res.Result != 8 {
+		t.Fatalf("Expected 8, got %v %v", res.Result, err)
+	}
+	if d := time.Since(start); d > time.Second {
+		t.Errorf("Expected the hedged response, waited %v", d)
+	}
+}
+
+type SlowService struct {
+	calls   int32
+	release chan struct{}
+}
+
+func (s *SlowService) Get(r *http.Request, args *string, reply *string) error {
+	atomic.AddInt32(&s.calls, 1)
+	<-s.release
+	*reply = "hello " + *args
+	return nil
+}
+
+func TestClientCoalesce(t *testing.T) {
+	slow := &SlowService{release: make(chan struct{})}
+	s := rpc.NewServer()
+	s.RegisterCodec(NewCodec(), "application/json")
+	s.RegisterService(slow, "Slow")
+	ts := httptest.NewServer(s)
+	defer ts.Close()
+
+	c := NewClient(ts.URL)
+	c.Coalesce = &CoalescePolicy{}
+	var wg sync.WaitGroup
+	replies := make([]string, 5)
+	for i := range replies {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			if err := c.Call(context.Background(), "Slow.Get", "bob", &replies[i]); err != nil {
+				t.Error(err)
+			}
+		}(i)
+	}
+	// Let the calls join the first one before releasing it.
+	for atomic.LoadInt32(&slow.calls) == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	time.Sleep(20 * time.Millisecond)
+	close(slow.release)
+	wg.Wait()
+	for _, reply := range replies {
+		if reply != "hello bob" {
+			t.Errorf("Expected hello bob, got %q", reply)
+		}
+	}
+	if calls := atomic.LoadInt32(&slow.calls); calls != 1 {
+		t.Errorf("Expected a single request, got %d", calls)
+	}
+
+	c.Coalesce.Exclude = []string{"Slow.Get"}
+	var reply string
+	err := c.Call(context.Background(), "Slow.Get", "bob", &reply)
+	if calls := atomic.LoadInt32(&slow.calls); err != nil || calls != 2 {
+		t.Errorf("Expected an excluded method to be called, got %d calls %v", calls, err)
+	}
+}
+
+func TestClientCoalesceCanceled(t *testing.T) {
+	slow := &SlowService{release: make(chan struct{})}
+	s := rpc.NewServer()
+	s.RegisterCodec(NewCodec(), "application/json")
+	s.RegisterService(slow, "Slow")
+	ts := httptest.NewServer(s)
+	defer ts.Close()
+
+	c := NewClient(ts.URL)
+	c.Coalesce = &CoalescePolicy{}
+	ctx, cancel := context.WithCancel(context.Background())
+	first := make(chan error, 1)
+	go func() {
+		var reply string
+		first <- c.Call(ctx, "Slow.Get", "bob", &reply)
+	}()
+	for atomic.LoadInt32(&slow.calls) == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	second := make(chan error, 1)
+	var reply string
+	go func() {
+		second <- c.Call(context.Background(), "Slow.Get", "bob", &reply)
+	}()
+	time.Sleep(20 * time.Millisecond)
+
+	// The first call gives up without failing the second one.
+	cancel()
+	if err := <-first; err != context.Canceled {
+		t.Fatalf("Expected context.Canceled, got %v", err)
+	}
+	close(slow.release)
+	if err := <-second; err != nil || reply != "hello bob" {
+		t.Fatalf("Expected hello bob, got %q %v", reply, err)
+	}
+	if calls := atomic.LoadInt32(&slow.calls); calls != 1 {
+		t.Errorf("Expected a single request, got %d", calls)
+	}
+}
+
+func TestClientTransport(t *testing.T) {
+	s := rpc.NewServer()
+	s.RegisterCodec(NewCodec(), "application/json")
+	s.RegisterService(new(Service1), "")
+	var proto int32
+	ts := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.StoreInt32(&proto, int32(r.ProtoMajor))
+		s.ServeHTTP(w, r)
+	}))
+	ts.TLS = &tls.Config{NextProtos: []string{"h2", "http/1.1"}}
+	ts.StartTLS()
+	defer ts.Close()
+	cert, err := x509.ParseCertificate(ts.TLS.Certificates[0].Certificate[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	roots := x509.NewCertPool()
+	roots.AddCert(cert)
+
+	for _, test := range []struct {
+		disableHTTP2 bool
+		proto        int32
+	}{{false, 2}, {true, 1}} {
+		if !forceAttemptHTTP2(&http.Transport{}) {
+			// HTTP/2 needs Go 1.13.
+			test.proto = 1
+		}
+		var proxied int32
+		c := NewClient(ts.URL)
+		c.HTTPClient = NewHTTPClient(TransportOptions{
+			TLSConfig: &tls.Config{RootCAs: roots},
+			Proxy: func(r *http.Request) (*url.URL, error) {
+				atomic.AddInt32(&proxied, 1)
+				return nil, nil
+			},
+			DisableHTTP2: test.disableHTTP2,
+		})
+		var res Service1Response
+		if err := c.Call(context.Background(), "Service1.Multiply", &Service1Request{4, 2}, &res); err != nil || res.Result != 8 {
+			t.Fatalf("Expected 8, got %v %v", res.Result, err)
+		}
+		if p := atomic.LoadInt32(&proto); p != test.proto {
+			t.Errorf("Expected HTTP/%d, got HTTP/%d", test.proto, p)
+		}
+		if atomic.LoadInt32(&proxied) == 0 {
+			t.Error("Expected the proxy function to be called")
+		}
+	}
+}
+
+func TestClientDigest(t *testing.T) {
+	s := rpc.NewServer()
+	s.RegisterCodec(NewCodec(), "application/json")
+	s.RegisterService(new(Service1), "")
+	s.EnableBodyVerification(true)
+	digests := make(chan string, 1)
+	s.RegisterBeforeFunc(func(i *rpc.RequestInfo) {
+		digests <- i.Request.Header.Get(rpc.DigestHeader)
+	})
+	ts := httptest.NewServer(s)
+	defer ts.Close()
+
+	c := NewClient(ts.URL)
+	c.Digest = true
+	var res Service1Response
+	if err := c.Call(context.Background(), "Service1.Multiply", &Service1Request{4, 2}, &res); err != nil || res.Result != 8 {
+		t.Fatalf("Wrong result %v %v", res.Result, err)
+	}
+	if digest := <-digests; len(digest) < 9 || digest[:9] != "sha-256=:" {
+		t.Errorf("Expected a sha-256 digest, got %q", digest)
+	}
+}
+
+func TestClientRetry(t *testing.T) {
+	s := rpc.NewServer()
+	s.RegisterCodec(NewCodec(), "application/json")
+	s.RegisterService(new(Service1), "")
+	err := s.SetConfig(&rpc.Config{
+		Methods: map[string]*rpc.MethodConfig{
+			"Service1.Multiply": {RateLimit: 2, RateBurst: 1},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var calls int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		s.ServeHTTP(w, r)
+	}))
+	defer ts.Close()
+
+	// The second call is rate limited, and retried after a second.
+	c := NewClient(ts.URL)
+	c.Retry = &RetryPolicy{}
+	for i := 0; i < 2; i++ {
+		var res Service1Response
+		if err := c.Call(context.Background(), "Service1.Multiply", &Service1Request{4, 2}, &res); err != nil || res.Result != 8 {
+			t.Fatalf("Expected 8, got %v %v", res.Result, err)
+		}
+	}
+	if calls != 3 {
+		t.Errorf("Expected 3 attempts, got %d", calls)
+	}
+
+	// Delays over MaxWait aren't waited.
+	atomic.StoreInt32(&calls, 0)
+	c.Retry.MaxWait = time.Nanosecond
+	var res Service1Response
+	err = c.Call(context.Background(), "Service1.Multiply", &Service1Request{4, 2}, &res)
+	if e, ok := err.(*Error); !ok || e.Code != E_THROTTLED || calls != 1 {
+		t.Errorf("Expected a single throttled attempt, got %d %v", calls, err)
+	}
+
+	// Calls to draining servers are retried.
+	atomic.StoreInt32(&calls, 0)
+	c.Retry.MaxWait = 0
+	s.SetConfig(&rpc.Config{})
+	s.Drain(context.Background())
+	time.AfterFunc(100*time.Millisecond, s.Resume)
+	if err := c.Call(context.Background(), "Service1.Multiply", &Service1Request{4, 2}, &res); err != nil || calls != 2 {
+		t.Errorf("Expected a retry after draining, got %d %v", calls, err)
+	}
+}
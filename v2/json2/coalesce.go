@@ -0,0 +1,125 @@
+// Copyright 2009 The Go Authors. All rights reserved.
+// Copyright 2012 The Gorilla Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package json2
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"time"
+)
+
+// CoalescePolicy configures the coalescing of the calls of a Client.
+//
+// Concurrent calls of a method with the same params and tenant, see
+// rpc.WithTenant, are coalesced into a single request, whose result is
+// decoded into the reply of each call, e.g. so that a burst of identical
+// refreshes of a UI makes one request. The request carries the values of
+// the context of the first call, but isn't canceled with it, and each call
+// gets its ResponseInfo. Methods that aren't idempotent must be excluded,
+// so that each call reaches the server.
+type CoalescePolicy struct {
+	// Exclude are the methods that aren't coalesced.
+	Exclude []string
+}
+
+// coalesces returns true if the calls of the method are coalesced.
+func (p *CoalescePolicy) coalesces(method string) bool {
+	for _, m := range p.Exclude {
+		if m == method {
+			return false
+		}
+	}
+	return true
+}
+
+// flight is a request in flight for coalesced calls.
+type flight struct {
+	done    chan struct{}
+	result  json.RawMessage
+	info    ResponseInfo
+	err     error
+	waiters int
+	cancel  context.CancelFunc
+}
+
+// flights are the requests in flight of a Client, by call key.
+type flights struct {
+	mutex sync.Mutex
+	calls map[string]*flight
+}
+
+// coalescedCall calls the method as call does, unless a call with the same
+// key is in flight, in which case its result is decoded into reply.
+//
+// The request runs on a context detached from the calls, so that a call
+// giving up doesn't fail the others; it is canceled when all of them have
+// given up.
+func (c *Client) coalescedCall(ctx context.Context, method, key string, body []byte, reply interface{}) error {
+	c.flights.mutex.Lock()
+	f, ok := c.flights.calls[key]
+	if !ok {
+		if c.flights.calls == nil {
+			c.flights.calls = make(map[string]*flight)
+		}
+		f = &flight{done: make(chan struct{})}
+		c.flights.calls[key] = f
+		var shared context.Context
+		shared, f.cancel = context.WithCancel(detachedContext{ctx})
+		go func() {
+			f.err = c.call(WithResponseInfo(shared, &f.info), method, body, &f.result)
+			c.flights.mutex.Lock()
+			if c.flights.calls[key] == f {
+				delete(c.flights.calls, key)
+			}
+			c.flights.mutex.Unlock()
+			f.cancel()
+			close(f.done)
+		}()
+	}
+	f.waiters++
+	c.flights.mutex.Unlock()
+
+	select {
+	case <-f.done:
+	case <-ctx.Done():
+		c.flights.mutex.Lock()
+		f.waiters--
+		if f.waiters == 0 {
+			if c.flights.calls[key] == f {
+				delete(c.flights.calls, key)
+			}
+			f.cancel()
+		}
+		c.flights.mutex.Unlock()
+		return ctx.Err()
+	}
+	if info := responseInfoFromContext(ctx); info != nil {
+		*info = f.info
+	}
+	if f.err != nil {
+		return f.err
+	}
+	return c.Options.Unmarshal(f.result, reply)
+}
+
+// detachedContext carries the values of a context without its deadline
+// and cancellation.
+type detachedContext struct {
+	context.Context
+}
+
+func (detachedContext) Deadline() (time.Time, bool) {
+	return time.Time{}, false
+}
+
+func (detachedContext) Done() <-chan struct{} {
+	return nil
+}
+
+func (detachedContext) Err() error {
+	return nil
+}
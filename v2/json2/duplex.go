@@ -0,0 +1,113 @@
+// Copyright 2009 The Go Authors. All rights reserved.
+// Copyright 2012 The Gorilla Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package json2
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"sync"
+)
+
+// ErrPeerClosed is returned by calls in flight when the connection to the
+// peer is closed.
+var ErrPeerClosed = errors.New("rpc: connection to peer closed")
+
+// PendingCalls tracks the calls sent to the peer of a duplex connection,
+// e.g. from a server to the client of a WebSocket session, matching them to
+// the responses received by id.
+type PendingCalls struct {
+	mutex   sync.Mutex
+	nextId  uint64
+	pending map[uint64]chan []byte
+	closed  bool
+}
+
+// Call encodes a request for the method, sends it and waits for the
+// response delivered with Deliver, decoding it into reply.
+func (p *PendingCalls) Call(ctx context.Context, send func([]byte) error, method string, args, reply interface{}) error {
+	p.mutex.Lock()
+	if p.closed {
+		p.mutex.Unlock()
+		return ErrPeerClosed
+	}
+	if p.pending == nil {
+		p.pending = make(map[uint64]chan []byte)
+	}
+	p.nextId++
+	id := p.nextId
+	ch := make(chan []byte, 1)
+	p.pending[id] = ch
+	p.mutex.Unlock()
+	defer p.forget(id)
+
+	req, err := json.Marshal(&clientRequest{
+		Version: Version,
+		Method:  method,
+		Params:  args,
+		Id:      id,
+	})
+	if err != nil {
+		return err
+	}
+	if err := send(req); err != nil {
+		return err
+	}
+	select {
+	case res, ok := <-ch:
+		if !ok {
+			return ErrPeerClosed
+		}
+		return DecodeClientResponse(bytes.NewReader(res), reply)
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Deliver passes a message received from the peer to the call waiting for
+// it. It returns false if the message isn't a response, so it must be
+// handled as a request.
+func (p *PendingCalls) Deliver(data []byte) bool {
+	var m struct {
+		Method *string          `json:"method"`
+		Id     *json.RawMessage `json:"id"`
+	}
+	if err := json.Unmarshal(data, &m); err != nil || m.Method != nil || m.Id == nil {
+		return false
+	}
+	var id uint64
+	if err := json.Unmarshal(*m.Id, &id); err != nil {
+		// Not one of ours: drop it.
+		return true
+	}
+	p.mutex.Lock()
+	ch := p.pending[id]
+	delete(p.pending, id)
+	p.mutex.Unlock()
+	if ch != nil {
+		ch <- data
+	}
+	return true
+}
+
+// Close makes the calls in flight and the following ones fail with
+// ErrPeerClosed.
+func (p *PendingCalls) Close() {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	p.closed = true
+	for id, ch := range p.pending {
+		close(ch)
+		delete(p.pending, id)
+	}
+}
+
+func (p *PendingCalls) forget(id uint64) {
+	p.mutex.Lock()
+	delete(p.pending, id)
+	p.mutex.Unlock()
+}
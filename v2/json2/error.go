@@ -18,6 +18,16 @@ const (
 	E_BAD_PARAMS  ErrorCode = -32602
 	E_INTERNAL    ErrorCode = -32603
 	E_SERVER      ErrorCode = -32000
+
+	// E_NOT_MODIFIED is the code of rpc.ErrNotModified, returned instead
+	// of a reply matching the "If-None-Match" header of the request.
+	E_NOT_MODIFIED ErrorCode = -32012
+
+	// E_THROTTLED is the code of the calls rejected with the 429 Too Many
+	// Requests or 503 Service Unavailable status, e.g. rate limited or
+	// sent to a draining server. They can be retried after the delay of
+	// the Retry-After header of the response, see RetryPolicy.
+	E_THROTTLED ErrorCode = -32013
 )
 
 var ErrNullResult = errors.New("result is null")
@@ -37,3 +47,9 @@ type Error struct {
 func (e *Error) Error() string {
 	return e.Message
 }
+
+// ErrorCode returns the code of the error, checked against the
+// rpc.ErrorCatalog of the server.
+func (e *Error) ErrorCode() int {
+	return int(e.Code)
+}
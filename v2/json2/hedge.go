@@ -0,0 +1,111 @@
+// Copyright 2009 The Go Authors. All rights reserved.
+// Copyright 2012 The Gorilla Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package json2
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"time"
+)
+
+// HedgePolicy configures the hedged calls of a Client.
+//
+// A hedged call is sent again when it has no response after Delay, and
+// gets the first successful response of both requests; the other one is
+// canceled. The second request goes to the endpoint selected by the
+// Policy, usually another one than the first request with RoundRobin or
+// LeastPending, so that latency-sensitive reads don't wait for slow
+// backends. Only idempotent methods should be hedged.
+type HedgePolicy struct {
+	// Delay is the time after which a call without response is sent again.
+	Delay time.Duration
+	// Methods are the hedged methods. If empty, no method is hedged.
+	Methods []string
+}
+
+// postResult is the outcome of the i-th request of a hedged call.
+type postResult struct {
+	i    int
+	resp *http.Response
+	e    *endpoint
+	err  error
+}
+
+// failed returns true if the request failed, or the server did.
+func (r postResult) failed() bool {
+	return r.err != nil || r.resp.StatusCode >= 500
+}
+
+// hedgedPost posts the body as post does, sending it again after the
+// delay of the HedgePolicy if the method is hedged.
+func (c *Client) hedgedPost(ctx context.Context, method string, body []byte) (*http.Response, *endpoint, error) {
+	if c.Hedge == nil || len(c.Hedge.Methods) == 0 || !hasMethod(c.Hedge.Methods, method) {
+		return c.post(ctx, method, body)
+	}
+	results := make(chan postResult, 2)
+	var cancels []context.CancelFunc
+	send := func() {
+		ctx, cancel := context.WithCancel(ctx)
+		cancels = append(cancels, cancel)
+		go func(i int) {
+			resp, e, err := c.post(ctx, method, body)
+			if err == nil {
+				// The request is canceled once its body is read.
+				resp.Body = &cancelBody{ReadCloser: resp.Body, cancel: cancel}
+			}
+			results <- postResult{i, resp, e, err}
+		}(len(cancels) - 1)
+	}
+	send()
+	timer := time.NewTimer(c.Hedge.Delay)
+	defer timer.Stop()
+	pending := 1
+	for {
+		select {
+		case <-timer.C:
+			pending++
+			send()
+		case r := <-results:
+			pending--
+			if r.failed() && pending > 0 {
+				if r.err == nil {
+					c.record(r.e, method, statusError(r.resp))
+					r.resp.Body.Close()
+				}
+				continue
+			}
+			for i, cancel := range cancels {
+				if i != r.i || r.err != nil {
+					cancel()
+				}
+			}
+			go func(pending int) {
+				// Close the responses of the canceled requests, whose
+				// breakers were released by post otherwise.
+				for ; pending > 0; pending-- {
+					if r := <-results; r.err == nil {
+						c.record(r.e, method, statusError(r.resp))
+						r.resp.Body.Close()
+					}
+				}
+			}(pending)
+			return r.resp, r.e, r.err
+		}
+	}
+}
+
+// cancelBody cancels the context of a request when its body is closed.
+type cancelBody struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (b *cancelBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.cancel()
+	return err
+}
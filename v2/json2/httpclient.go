@@ -0,0 +1,365 @@
+// Copyright 2009 The Go Authors. All rights reserved.
+// Copyright 2012 The Gorilla Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package json2
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	neturl "net/url"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gorilla/rpc/v2"
+	"github.com/gorilla/rpc/v2/jsonopt"
+)
+
+// DefaultRetryUnhealthyAfter is the time after which an endpoint marked as
+// unhealthy is used again by a Client.
+const DefaultRetryUnhealthyAfter = 10 * time.Second
+
+// ErrNoEndpoints is returned by a Client without server urls.
+var ErrNoEndpoints = errors.New("rpc: no endpoints")
+
+// Client calls methods of JSON-RPC 2.0 servers over HTTP.
+//
+// A Client can be given several server urls. Calls are balanced across them
+// according to the Policy and fail over to the next endpoint when a server
+// can't be reached. Endpoints that fail are skipped until they are checked
+// again, see StartHealthChecks and RetryUnhealthyAfter.
+type Client struct {
+	// balancer is first to be 64-bit aligned for the atomic operations on
+	// 32-bit platforms.
+	balancer balancer
+
+	// HTTPClient sends the requests. If nil, http.DefaultClient is used.
+	// See NewHTTPClient to configure TLS, proxies and timeouts.
+	HTTPClient *http.Client
+	// Policy selects the endpoint for each call.
+	Policy Policy
+	// RetryUnhealthyAfter is the time after which an unhealthy endpoint is
+	// used again. If zero, DefaultRetryUnhealthyAfter is used.
+	RetryUnhealthyAfter time.Duration
+	// HealthCheck checks if the server at url is healthy. If nil, a server
+	// answering any HTTP response to a GET request is considered healthy.
+	HealthCheck func(ctx context.Context, url string) error
+	// Breaker enables circuit breakers per endpoint and method when set.
+	Breaker *BreakerPolicy
+	// Options, if set, encode the params and decode the results, as the
+	// options of the server codec.
+	Options *jsonopt.Options
+	// Cache enables the cache of the responses with an ETag when set.
+	Cache *CachePolicy
+	// Hedge enables hedged calls when set.
+	Hedge *HedgePolicy
+	// Coalesce enables the coalescing of identical concurrent calls when
+	// set.
+	Coalesce *CoalescePolicy
+	// Retry enables the retries of throttled calls when set.
+	Retry *RetryPolicy
+	// Digest makes the client send the checksum of the requests in the
+	// rpc.DigestHeader, for servers verifying the bodies.
+	Digest bool
+
+	breakers breakers
+	cache    cache
+	flights  flights
+
+	mutex    sync.Mutex
+	affinity string
+}
+
+// NewClient returns a new Client calling the servers at the given urls.
+func NewClient(urls ...string) *Client {
+	c := &Client{}
+	for _, url := range urls {
+		c.balancer.endpoints = append(c.balancer.endpoints, newEndpoint(url))
+	}
+	return c
+}
+
+// Call calls the method with args and decodes the result into reply.
+func (c *Client) Call(ctx context.Context, method string, args, reply interface{}) error {
+	body, err := encodeClientRequest(method, args, c.Options)
+	if err != nil {
+		return err
+	}
+	cached := c.Cache != nil && hasMethod(c.Cache.Methods, method)
+	coalesced := c.Coalesce != nil && c.Coalesce.coalesces(method)
+	if !cached && !coalesced {
+		return c.call(ctx, method, body, reply)
+	}
+	key, err := callKey(ctx, method, args, c.Options)
+	if err != nil {
+		return err
+	}
+	if cached {
+		entry := c.cache.get(key)
+		if data, ok := c.cachedReply(ctx, method, key, entry, body); ok {
+			return decodeClientResponse(bytes.NewReader(data), reply, c.Options)
+		}
+		ctx = withCachedCall(ctx, &cachedCall{key: key, entry: entry})
+	}
+	if coalesced || cached && c.Cache.Singleflight {
+		return c.coalescedCall(ctx, method, key, body, reply)
+	}
+	return c.call(ctx, method, body, reply)
+}
+
+// call posts the encoded request and decodes the response into reply.
+func (c *Client) call(ctx context.Context, method string, body []byte, reply interface{}) error {
+	resp, e, err := c.retriedPost(ctx, method, body)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if token := resp.Header.Get(rpc.AffinityHeader); token != "" {
+		c.SetAffinity(token)
+	}
+	cached := cachedCallFromContext(ctx)
+	if resp.StatusCode == http.StatusNotModified {
+		err = rpc.ErrNotModified
+		if cached != nil && cached.entry != nil {
+			c.cache.touch(cached.key, maxAge(resp.Header))
+			err = decodeClientResponse(bytes.NewReader(cached.entry.body), reply, c.Options)
+		}
+	} else if resp.StatusCode >= 500 {
+		err = statusError(resp)
+	} else if tag := resp.Header.Get("ETag"); cached != nil && tag != "" {
+		var data []byte
+		if data, err = ioutil.ReadAll(resp.Body); err == nil {
+			err = decodeClientResponse(bytes.NewReader(data), reply, c.Options)
+		}
+		if err == nil {
+			c.cache.put(cached.key, tag, data, maxAge(resp.Header), c.Cache.maxEntries())
+		}
+	} else {
+		err = decodeClientResponse(resp.Body, reply, c.Options)
+		// Trailers are received once the body is read.
+		io.Copy(ioutil.Discard, resp.Body)
+	}
+	if info := responseInfoFromContext(ctx); info != nil {
+		info.StatusCode, info.Header, info.Trailer = resp.StatusCode, resp.Header, resp.Trailer
+	}
+	c.record(e, method, err)
+	return err
+}
+
+// ResponseInfo receives the HTTP status, headers and trailers of the
+// response of a call, see WithResponseInfo.
+type ResponseInfo struct {
+	StatusCode int
+	Header     http.Header
+	Trailer    http.Header
+}
+
+// Cookies returns the cookies set by the response.
+func (i *ResponseInfo) Cookies() []*http.Cookie {
+	return (&http.Response{Header: i.Header}).Cookies()
+}
+
+type responseInfoKey struct{}
+
+// WithResponseInfo returns a copy of ctx making the calls of a Client with
+// it fill info with their response, e.g. to read the cookies set by a
+// method:
+//
+//	var info json2.ResponseInfo
+//	err := client.Call(json2.WithResponseInfo(ctx, &info), "Session.Login", args, &reply)
+func WithResponseInfo(ctx context.Context, info *ResponseInfo) context.Context {
+	return context.WithValue(ctx, responseInfoKey{}, info)
+}
+
+func responseInfoFromContext(ctx context.Context) *ResponseInfo {
+	info, _ := ctx.Value(responseInfoKey{}).(*ResponseInfo)
+	return info
+}
+
+// post sends the body to the selected endpoint, failing over to the next
+// ones when the connection can't be established or the circuit breaker is
+// open. Other errors aren't failed over, since the server may have received
+// the call.
+func (c *Client) post(ctx context.Context, method string, body []byte) (*http.Response, *endpoint, error) {
+	tried := make(map[*endpoint]bool)
+	err := ErrNoEndpoints
+	for {
+		e := c.balancer.pick(c.Policy, tried, c.retryUnhealthyAfter())
+		if e == nil {
+			return nil, nil, err
+		}
+		tried[e] = true
+		if c.Breaker != nil && !c.breakers.get(e.url, method).allow(c.Breaker, time.Now()) {
+			err = ErrCircuitOpen
+			continue
+		}
+		var resp *http.Response
+		resp, err = c.send(ctx, e, body)
+		if err == nil {
+			e.setHealthy(true)
+			return resp, e, nil
+		}
+		if ctx.Err() != nil {
+			c.release(e, method)
+			return nil, nil, err
+		}
+		e.setHealthy(false)
+		c.record(e, method, err)
+		if !isDialError(err) {
+			return nil, nil, err
+		}
+	}
+}
+
+// isDialError returns true if the error happened while connecting to the
+// server, before the call was sent.
+func isDialError(err error) bool {
+	if e, ok := err.(*neturl.Error); ok {
+		err = e.Err
+	}
+	e, ok := err.(*net.OpError)
+	return ok && e.Op == "dial"
+}
+
+// record records the outcome of a call in the breaker of the endpoint.
+func (c *Client) record(e *endpoint, method string, err error) {
+	if c.Breaker != nil {
+		c.breakers.get(e.url, method).record(c.Breaker, time.Now(), isBreakerFailure(err))
+	}
+}
+
+// release gives up a call without outcome in the breaker of the endpoint.
+func (c *Client) release(e *endpoint, method string) {
+	if c.Breaker != nil {
+		c.breakers.get(e.url, method).release()
+	}
+}
+
+// statusError returns the error of a response with a 5xx status, or nil.
+func statusError(resp *http.Response) error {
+	if resp.StatusCode >= 500 {
+		return errors.New("rpc: server returned " + resp.Status)
+	}
+	return nil
+}
+
+// send posts the body to a single endpoint.
+func (c *Client) send(ctx context.Context, e *endpoint, body []byte) (*http.Response, error) {
+	req, err := http.NewRequest("POST", e.url, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Content-Type", "application/json")
+	if ctx.Value(ndjsonKey{}) != nil {
+		req.Header.Set("Accept", rpc.NDJSONContentType)
+	}
+	if c.Digest {
+		rpc.SetContentDigest(req.Header, body)
+	}
+	if token := c.Affinity(); token != "" {
+		req.Header.Set(rpc.AffinityHeader, token)
+	}
+	rpc.Propagate(ctx, req.Header)
+	if key := rpc.IdempotencyKeyFromContext(ctx); key != "" {
+		req.Header.Set(rpc.IdempotencyKeyHeader, key)
+	}
+	if cached := cachedCallFromContext(ctx); cached != nil && cached.entry != nil {
+		req.Header.Set("If-None-Match", cached.entry.etag)
+	}
+	atomic.AddInt64(&e.pending, 1)
+	defer atomic.AddInt64(&e.pending, -1)
+	return c.httpClient().Do(req)
+}
+
+// Affinity returns the affinity token issued by the servers, echoed in
+// each call so that gateways can route the calls of the client to the same
+// backend. See rpc.AffinityHeader.
+func (c *Client) Affinity() string {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.affinity
+}
+
+// SetAffinity sets the affinity token sent in each call, e.g. to restore a
+// session. It is replaced by the token returned by the servers.
+func (c *Client) SetAffinity(token string) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.affinity = token
+}
+
+// StartHealthChecks checks the health of every endpoint at each interval
+// until the returned function is called.
+func (c *Client) StartHealthChecks(interval time.Duration) (stop func()) {
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				c.CheckHealth(ctx)
+			}
+		}
+	}()
+	return cancel
+}
+
+// CheckHealth checks the health of every endpoint once.
+func (c *Client) CheckHealth(ctx context.Context) {
+	check := c.HealthCheck
+	if check == nil {
+		check = c.defaultHealthCheck
+	}
+	for _, e := range c.balancer.endpoints {
+		e.setHealthy(check(ctx, e.url) == nil)
+	}
+}
+
+// Healthy returns the urls of the endpoints currently considered healthy.
+func (c *Client) Healthy() []string {
+	var urls []string
+	for _, e := range c.balancer.endpoints {
+		if e.isHealthy() {
+			urls = append(urls, e.url)
+		}
+	}
+	return urls
+}
+
+func (c *Client) defaultHealthCheck(ctx context.Context, url string) error {
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := c.httpClient().Do(req.WithContext(ctx))
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+func (c *Client) httpClient() *http.Client {
+	if c.HTTPClient != nil {
+		return c.HTTPClient
+	}
+	return http.DefaultClient
+}
+
+func (c *Client) retryUnhealthyAfter() time.Duration {
+	if c.RetryUnhealthyAfter > 0 {
+		return c.RetryUnhealthyAfter
+	}
+	return DefaultRetryUnhealthyAfter
+}
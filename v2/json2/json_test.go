@@ -7,13 +7,19 @@ package json2
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"net/http"
+	"net/http/httptest"
+	"strconv"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/gorilla/rpc/v2"
+	"github.com/gorilla/rpc/v2/jsonopt"
 )
 
 // ResponseRecorder is an implementation of http.ResponseWriter that
@@ -285,3 +291,750 @@ func TestDecodeNullResult(t *testing.T) {
 		t.Error("Expected result to be nil, but got:", result)
 	}
 }
+
+func TestFallbackFunc(t *testing.T) {
+	s := rpc.NewServer()
+	s.RegisterCodec(NewCodec(), "application/json")
+	s.RegisterService(new(Service1), "")
+	s.RegisterFallbackFunc(func(r *http.Request, method string, params json.RawMessage) (interface{}, error) {
+		if method != "Upstream.Multiply" {
+			return nil, &Error{Code: E_NO_METHOD, Message: "unknown method " + method}
+		}
+		var req Service1Request
+		if err := json.Unmarshal(params, &req); err != nil {
+			return nil, err
+		}
+		return json.RawMessage(`{"Result":` + strconv.Itoa(req.A*req.B) + `}`), nil
+	})
+
+	var res Service1Response
+	buf, _ := EncodeClientRequest("Upstream.Multiply", &Service1Request{4, 2})
+	if err := executeRaw(t, s, json.RawMessage(buf), &res); err != nil {
+		t.Error("Expected err to be nil, but got:", err)
+	}
+	if res.Result != 8 {
+		t.Errorf("Wrong response: %v.", res.Result)
+	}
+
+	// Registered methods don't reach the fallback.
+	if err := execute(t, s, "Service1.Multiply", &Service1Request{3, 2}, &res); err != nil {
+		t.Error("Expected err to be nil, but got:", err)
+	}
+	if res.Result != 6 {
+		t.Errorf("Wrong response: %v.", res.Result)
+	}
+
+	buf, _ = EncodeClientRequest("Other.Method", &Service1Request{3, 2})
+	if err := executeRaw(t, s, json.RawMessage(buf), &res); err == nil {
+		t.Error("Expected an error, but got nil")
+	} else if jsonRpcErr, ok := err.(*Error); !ok || jsonRpcErr.Code != E_NO_METHOD {
+		t.Errorf("Expected an E_NO_METHOD error, but got %v", err)
+	}
+
+	// The latencies of the fallback calls share a histogram.
+	stats := s.LatencyStats()
+	if len(stats) != 2 || stats[0].Method != rpc.FallbackStatsMethod || stats[0].Count != 2 ||
+		stats[1].Method != "Service1.Multiply" || stats[1].Count != 1 {
+		t.Errorf("Wrong stats %+v", stats)
+	}
+}
+
+type batchResponse struct {
+	Result *json.RawMessage `json:"result"`
+	Error  *json.RawMessage `json:"error"`
+	Id     *json.RawMessage `json:"id"`
+}
+
+func serveBatch(s *rpc.Server, body string) []batchResponse {
+	r, _ := http.NewRequest("POST", "http://localhost:8080/", strings.NewReader(body))
+	r.Header.Set("Content-Type", "application/json")
+	w := NewRecorder()
+	s.ServeHTTP(w, r)
+	var res []batchResponse
+	json.Unmarshal(w.Body.Bytes(), &res)
+	return res
+}
+
+func TestBatch(t *testing.T) {
+	s := rpc.NewServer()
+	s.RegisterCodec(NewCodec(), "application/json")
+	s.RegisterService(new(Service1), "")
+
+	res := serveBatch(s, `[
+		{"jsonrpc":"2.0","method":"Service1.Multiply","params":{"A":2,"B":3},"id":1},
+		{"jsonrpc":"2.0","method":"Service1.Multiply","params":{"A":4,"B":5}},
+		{"jsonrpc":"2.0","method":"Service1.ResponseError","id":2},
+		1
+	]`)
+	if len(res) != 3 {
+		t.Fatalf("Expected 3 responses, got %d", len(res))
+	}
+	if res[0].Result == nil || string(*res[0].Result) != `{"Result":6}` {
+		t.Errorf("Wrong first response: %+v", res[0])
+	}
+	if res[1].Error == nil || string(*res[1].Id) != "2" {
+		t.Errorf("Expected error for id 2, got %+v", res[1])
+	}
+	if res[2].Error == nil || res[2].Id != nil {
+		t.Errorf("Expected invalid request error, got %+v", res[2])
+	}
+
+	r, _ := http.NewRequest("POST", "http://localhost:8080/", strings.NewReader(`[]`))
+	r.Header.Set("Content-Type", "application/json")
+	w := NewRecorder()
+	s.ServeHTTP(w, r)
+	var single batchResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &single); err != nil || single.Error == nil {
+		t.Errorf("Expected a single error for an empty batch, got %q", w.Body)
+	}
+}
+
+func TestBatchStream(t *testing.T) {
+	s := rpc.NewServer()
+	s.RegisterCodec(NewCodec(), "application/json")
+	s.RegisterService(new(Service1), "")
+
+	// Calls are served as they're decoded: the calls before a syntax
+	// error are answered, followed by a parse error.
+	res := serveBatch(s, `[
+		{"jsonrpc":"2.0","method":"Service1.Multiply","params":{"A":2,"B":3},"id":1},
+		{"jsonrpc":"2.0","method":"Service1.Multiply","params":{"A":4,"B":5},"id":2},
+		{"jsonrpc":"2.0","method":`)
+	if len(res) != 3 {
+		t.Fatalf("Expected 3 responses, got %d", len(res))
+	}
+	if res[1].Result == nil || string(*res[1].Result) != `{"Result":20}` {
+		t.Errorf("Wrong second response: %+v", res[1])
+	}
+	var jsonErr Error
+	if res[2].Error == nil || json.Unmarshal(*res[2].Error, &jsonErr) != nil || jsonErr.Code != E_PARSE {
+		t.Errorf("Expected a parse error, got %+v", res[2])
+	}
+
+	codec := NewCodec()
+	batch := codec.NewRequest(httptest.NewRequest("POST", "/", strings.NewReader(`[{"jsonrpc":"2.0","method":"A.B","id":1}, 2]`))).(*BatchCodecRequest)
+	if req, ok := batch.Next(); !ok {
+		t.Fatal("Expected a first request")
+	} else if method, _ := req.Method(); method != "A.B" {
+		t.Errorf("Wrong method %q", method)
+	}
+	if reqs := batch.Requests(); len(reqs) != 1 {
+		t.Errorf("Expected the remaining request, got %d", len(reqs))
+	}
+	if _, ok := batch.Next(); ok {
+		t.Error("Expected the end of the batch")
+	}
+}
+
+type txManager struct {
+	log []string
+}
+
+func (m *txManager) Begin(ctx context.Context) (context.Context, error) {
+	m.log = append(m.log, "begin")
+	return ctx, nil
+}
+
+func (m *txManager) Commit(ctx context.Context) error {
+	m.log = append(m.log, "commit")
+	return nil
+}
+
+func (m *txManager) Rollback(ctx context.Context) error {
+	m.log = append(m.log, "rollback")
+	return nil
+}
+
+func TestBatchTransaction(t *testing.T) {
+	s := rpc.NewServer()
+	s.RegisterCodec(NewCodec(), "application/json")
+	s.RegisterService(new(Service1), "")
+	tm := new(txManager)
+	s.RegisterTransactionManager(tm, "Service1.Multiply", "Service1.ResponseError")
+
+	res := serveBatch(s, `[
+		{"jsonrpc":"2.0","method":"Service1.Multiply","params":{"A":2,"B":3},"id":1},
+		{"jsonrpc":"2.0","method":"Service1.Multiply","params":{"A":4,"B":5},"id":2}
+	]`)
+	if len(res) != 2 || res[0].Error != nil || res[1].Error != nil {
+		t.Errorf("Expected 2 results, got %+v", res)
+	}
+	if got := strings.Join(tm.log, ","); got != "begin,commit" {
+		t.Errorf("Expected a committed transaction, got %s", got)
+	}
+
+	tm.log = nil
+	res = serveBatch(s, `[
+		{"jsonrpc":"2.0","method":"Service1.Multiply","params":{"A":2,"B":3},"id":1},
+		{"jsonrpc":"2.0","method":"Service1.ResponseError","id":2},
+		{"jsonrpc":"2.0","method":"Service1.Multiply","params":{"A":4,"B":5},"id":3}
+	]`)
+	if len(res) != 3 {
+		t.Fatalf("Expected 3 responses, got %d", len(res))
+	}
+	for i, r := range res {
+		if r.Error == nil {
+			t.Errorf("Expected error for call %d", i)
+		}
+	}
+	if got := strings.Join(tm.log, ","); got != "begin,rollback" {
+		t.Errorf("Expected a rolled back transaction, got %s", got)
+	}
+
+	tm.log = nil
+	var reply Service1Response
+	if err := execute(t, s, "Service1.Multiply", &Service1Request{2, 3}, &reply); err != nil || reply.Result != 6 {
+		t.Errorf("Wrong single call: %v %v", reply, err)
+	}
+	if got := strings.Join(tm.log, ","); got != "begin,commit" {
+		t.Errorf("Expected a transaction for the single call, got %s", got)
+	}
+}
+
+type VersionedResponse struct {
+	Version string
+}
+
+func (r *VersionedResponse) ETag() string {
+	return r.Version
+}
+
+type VersionedService struct{}
+
+func (VersionedService) Get(r *http.Request, args *struct{}, reply *VersionedResponse) error {
+	reply.Version = "v1"
+	return nil
+}
+
+func TestETag(t *testing.T) {
+	s := rpc.NewServer()
+	s.RegisterCodec(NewCodec(), "application/json")
+	s.RegisterService(new(VersionedService), "Versioned")
+
+	serve := func(ifNoneMatch string) *ResponseRecorder {
+		buf, _ := EncodeClientRequest("Versioned.Get", struct{}{})
+		r, _ := http.NewRequest("POST", "http://localhost:8080/", bytes.NewReader(buf))
+		r.Header.Set("Content-Type", "application/json")
+		if ifNoneMatch != "" {
+			r.Header.Set("If-None-Match", ifNoneMatch)
+		}
+		w := NewRecorder()
+		s.ServeHTTP(w, r)
+		return w
+	}
+
+	w := serve("")
+	if w.Code != http.StatusOK || w.HeaderMap.Get("ETag") != `"v1"` {
+		t.Errorf("Expected ETag \"v1\", got %d %q", w.Code, w.HeaderMap.Get("ETag"))
+	}
+	if w := serve(`"v0", W/"v1"`); w.Code != http.StatusNotModified || w.Body.Len() != 0 {
+		t.Errorf("Expected status 304 without body, got %d %q", w.Code, w.Body)
+	}
+	if w := serve(`"v0"`); w.Code != http.StatusOK {
+		t.Errorf("Expected status 200, got %d", w.Code)
+	}
+
+	buf, _ := EncodeClientRequest("Versioned.Get", struct{}{})
+	r, _ := http.NewRequest("POST", "ws://localhost:8080/", nil)
+	r.Header.Set("Content-Type", "application/json")
+	r.Header.Set("If-None-Match", `"v1"`)
+	var reply VersionedResponse
+	err := DecodeClientResponse(bytes.NewReader(s.ServeMessage(r, buf)), &reply)
+	if e, ok := err.(*Error); !ok || e.Code != E_NOT_MODIFIED {
+		t.Errorf("Expected E_NOT_MODIFIED, got %v", err)
+	}
+}
+
+type Int64Service struct{}
+
+func (Int64Service) Next(r *http.Request, args *int64, reply *struct{ ID int64 }) error {
+	reply.ID = *args + 1
+	return nil
+}
+
+func TestCodecOptions(t *testing.T) {
+	s := rpc.NewServer()
+	codec := NewCodec()
+	codec.SetOptions(jsonopt.Options{Int64AsString: true})
+	s.RegisterCodec(codec, "application/json")
+	s.RegisterService(new(Int64Service), "Int64")
+
+	r, _ := http.NewRequest("POST", "http://localhost:8080/",
+		strings.NewReader(`{"jsonrpc":"2.0","method":"Int64.Next","params":["9007199254740993"],"id":1}`))
+	r.Header.Set("Content-Type", "application/json")
+	w := NewRecorder()
+	s.ServeHTTP(w, r)
+	if !strings.Contains(w.Body.String(), `"result":{"ID":"9007199254740994"}`) {
+		t.Errorf("Expected id as string, got %s", w.Body)
+	}
+}
+
+type PatchArgs struct {
+	rpc.Presence
+	Name    *string
+	Email   *string
+	Address struct {
+		City string
+	}
+}
+
+type PatchService struct {
+	args PatchArgs
+}
+
+func (s *PatchService) Update(r *http.Request, args *PatchArgs, reply *struct{}) error {
+	s.args = *args
+	return nil
+}
+
+func TestPresence(t *testing.T) {
+	s := rpc.NewServer()
+	s.RegisterCodec(NewCodec(), "application/json")
+	service := new(PatchService)
+	s.RegisterService(service, "Patch")
+
+	for _, params := range []string{`{"name":"","Email":null,"address":{"city":"x"}}`, `[{"name":"","Email":null,"address":{"city":"x"}}]`} {
+		r, _ := http.NewRequest("POST", "http://localhost:8080/",
+			strings.NewReader(`{"jsonrpc":"2.0","method":"Patch.Update","params":`+params+`,"id":1}`))
+		r.Header.Set("Content-Type", "application/json")
+		s.ServeHTTP(NewRecorder(), r)
+		args := service.args
+		if !args.Has("Name") || args.IsNull("Name") || args.Name == nil || *args.Name != "" {
+			t.Errorf("Expected empty Name to be sent, got %v", args.Fields())
+		}
+		if !args.Has("Email") || !args.IsNull("Email") {
+			t.Errorf("Expected null Email, got %v", args.Fields())
+		}
+		if !args.Has("Address.City") {
+			t.Errorf("Expected Address.City to be sent, got %v", args.Fields())
+		}
+	}
+}
+
+// Color is an enum encoded as its name by rpc.Marshaler.
+type Color int
+
+var colorNames = []string{"red", "green", "blue"}
+
+func (c Color) MarshalRPC() (interface{}, error) {
+	return colorNames[c], nil
+}
+
+func (c *Color) UnmarshalRPC(v interface{}) error {
+	for i, name := range colorNames {
+		if v == name {
+			*c = Color(i)
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown color %v", v)
+}
+
+type ColorService struct{}
+
+func (ColorService) Next(r *http.Request, args *Color, reply *struct{ Color Color }) error {
+	reply.Color = (*args + 1) % Color(len(colorNames))
+	return nil
+}
+
+func TestRPCMarshaler(t *testing.T) {
+	s := rpc.NewServer()
+	s.RegisterCodec(NewCodec(), "application/json")
+	s.RegisterService(new(ColorService), "Color")
+
+	r, _ := http.NewRequest("POST", "http://localhost:8080/",
+		strings.NewReader(`{"jsonrpc":"2.0","method":"Color.Next","params":["green"],"id":1}`))
+	r.Header.Set("Content-Type", "application/json")
+	w := NewRecorder()
+	s.ServeHTTP(w, r)
+	if !strings.Contains(w.Body.String(), `"result":{"Color":"blue"}`) {
+		t.Errorf("Expected color name, got %s", w.Body)
+	}
+}
+
+type PaintArgs struct {
+	Color string `json:"color" enum:"PaintColor"`
+}
+
+type PaintService struct{}
+
+func (PaintService) Paint(r *http.Request, args *PaintArgs, reply *string) error {
+	*reply = args.Color
+	return nil
+}
+
+func TestEnum(t *testing.T) {
+	rpc.RegisterEnum("PaintColor", "red", "blue")
+	s := rpc.NewServer()
+	s.RegisterCodec(NewCodec(), "application/json")
+	s.RegisterService(new(PaintService), "Paint")
+
+	var reply string
+	if err := execute(t, s, "Paint.Paint", &PaintArgs{Color: "blue"}, &reply); err != nil || reply != "blue" {
+		t.Errorf("Expected blue, got %q %v", reply, err)
+	}
+	err := execute(t, s, "Paint.Paint", &PaintArgs{Color: "green"}, &reply)
+	if e, ok := err.(*Error); !ok || e.Code != E_BAD_PARAMS || e.Data != "Color" {
+		t.Errorf("Expected invalid params, got %#v", err)
+	}
+}
+
+type OrderService struct{}
+
+func (OrderService) Get(r *http.Request, args *struct{ ID rpc.UUID }, reply *rpc.UUID) error {
+	*reply = args.ID
+	return nil
+}
+
+func TestUUID(t *testing.T) {
+	s := rpc.NewServer()
+	s.RegisterCodec(NewCodec(), "application/json")
+	s.RegisterService(new(OrderService), "Order")
+
+	for _, params := range []string{`{"ID":"not-a-uuid"}`, `[{"ID":"not-a-uuid"}]`} {
+		r, _ := http.NewRequest("POST", "http://localhost:8080/",
+			strings.NewReader(`{"jsonrpc":"2.0","method":"Order.Get","params":`+params+`,"id":1}`))
+		r.Header.Set("Content-Type", "application/json")
+		w := NewRecorder()
+		s.ServeHTTP(w, r)
+		err := DecodeClientResponse(w.Body, new(rpc.UUID))
+		if e, ok := err.(*Error); !ok || e.Code != E_BAD_PARAMS {
+			t.Errorf("Expected invalid params, got %#v", err)
+		}
+	}
+	id := rpc.NewUUID()
+	var reply rpc.UUID
+	if err := execute(t, s, "Order.Get", &struct{ ID rpc.UUID }{id}, &reply); err != nil || reply != id {
+		t.Errorf("Expected %s, got %s %v", id, reply, err)
+	}
+}
+
+func TestKeepRequestBody(t *testing.T) {
+	s := rpc.NewServer()
+	s.RegisterCodec(NewCodec(), "application/json")
+	s.RegisterService(new(Service1), "")
+	s.KeepRequestBody(true)
+	var validated, after []byte
+	s.RegisterValidateRequestFunc(func(info *rpc.RequestInfo, args interface{}) error {
+		validated = info.Body
+		return nil
+	})
+	s.RegisterAfterFunc(func(info *rpc.RequestInfo) {
+		after = info.Body
+	})
+
+	var res Service1Response
+	if err := execute(t, s, "Service1.Multiply", &Service1Request{4, 2}, &res); err != nil || res.Result != 8 {
+		t.Fatalf("Expected 8, got %d %v", res.Result, err)
+	}
+	if !strings.Contains(string(validated), `"method":"Service1.Multiply"`) || string(after) != string(validated) {
+		t.Errorf("Wrong bodies %q and %q", validated, after)
+	}
+}
+
+func TestAfterFuncCapture(t *testing.T) {
+	s := rpc.NewServer()
+	s.RegisterCodec(NewCodec(), "application/json")
+	s.RegisterService(new(Service1), "")
+	var info rpc.RequestInfo
+	s.RegisterAfterFunc(func(i *rpc.RequestInfo) {
+		info = *i
+	})
+
+	buf, _ := EncodeClientRequest("Service1.ResponseError", &Service1Request{4, 2})
+	r, _ := http.NewRequest("POST", "http://localhost:8080/", bytes.NewBuffer(buf))
+	r.Header.Set("Content-Type", "application/json")
+	w := NewRecorder()
+	s.ServeHTTP(w, r)
+	// JSON-RPC errors are sent with a 200 status.
+	if info.StatusCode != http.StatusOK || info.BytesWritten != int64(w.Body.Len()) || info.Error == nil {
+		t.Errorf("Wrong status %d or size %d of %d", info.StatusCode, info.BytesWritten, w.Body.Len())
+	}
+}
+
+func TestRegisterMethod(t *testing.T) {
+	s := rpc.NewServer()
+	s.RegisterCodec(NewCodec(), "application/json")
+	err := s.RegisterMethod("Hooks.Sum", func(ctx context.Context, params json.RawMessage) (interface{}, error) {
+		var args []int
+		if err := json.Unmarshal(params, &args); err != nil {
+			return nil, err
+		}
+		sum := 0
+		for _, n := range args {
+			sum += n
+		}
+		return sum, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var sum int
+	if err := execute(t, s, "Hooks.Sum", []int{1, 2, 3}, &sum); err != nil || sum != 6 {
+		t.Errorf("Expected 6, got %d %v", sum, err)
+	}
+}
+
+type VersionInfo struct {
+	Version string
+}
+
+type PingService struct {
+	pings int
+}
+
+func (s *PingService) Ping(r *http.Request) error {
+	s.pings++
+	return nil
+}
+
+func (s *PingService) Version(r *http.Request, _ *struct{}, res *VersionInfo) error {
+	res.Version = "1.0"
+	return nil
+}
+
+func (s *PingService) Reset(r *http.Request, args *int) error {
+	s.pings = *args
+	return nil
+}
+
+func TestNoArgsMethods(t *testing.T) {
+	s := rpc.NewServer()
+	s.RegisterCodec(NewCodec(), "application/json")
+	service := new(PingService)
+	s.RegisterService(service, "Ping")
+
+	var res struct{}
+	if err := executeRaw(t, s, &struct {
+		Version string `json:"jsonrpc"`
+		Method  string `json:"method"`
+		Id      int    `json:"id"`
+	}{"2.0", "Ping.Ping", 1}, &res); err != nil || service.pings != 1 {
+		t.Errorf("Expected a ping, got %d %v", service.pings, err)
+	}
+	var version VersionInfo
+	if err := execute(t, s, "Ping.Version", nil, &version); err != nil || version.Version != "1.0" {
+		t.Errorf("Expected version 1.0, got %+v %v", version, err)
+	}
+	if err := execute(t, s, "Ping.Reset", 5, &res); err != nil || service.pings != 5 {
+		t.Errorf("Expected 5 pings, got %d %v", service.pings, err)
+	}
+}
+
+type Item struct {
+	Name string
+}
+
+type BulkService struct{}
+
+func (BulkService) Count(r *http.Request, args *[]Item, reply *int) error {
+	*reply = len(*args)
+	return nil
+}
+
+func TestSliceParams(t *testing.T) {
+	s := rpc.NewServer()
+	s.RegisterCodec(NewCodec(), "application/json")
+	s.RegisterService(new(BulkService), "Bulk")
+
+	var count int
+	if err := execute(t, s, "Bulk.Count", []Item{{"a"}, {"b"}}, &count); err != nil || count != 2 {
+		t.Errorf("Expected 2 items, got %d %v", count, err)
+	}
+	if err := execute(t, s, "Bulk.Count", []Item{{"a"}}, &count); err != nil || count != 1 {
+		t.Errorf("Expected 1 item, got %d %v", count, err)
+	}
+}
+
+type SearchArgs struct {
+	Query string
+	Limit int `default:"10"`
+}
+
+type SearchService struct{}
+
+func (SearchService) Limit(r *http.Request, args *SearchArgs, reply *int) error {
+	*reply = args.Limit
+	return nil
+}
+
+func TestDefaults(t *testing.T) {
+	s := rpc.NewServer()
+	s.RegisterCodec(NewCodec(), "application/json")
+	s.RegisterService(new(SearchService), "Search")
+
+	var limit int
+	if err := execute(t, s, "Search.Limit", map[string]interface{}{"Query": "go"}, &limit); err != nil || limit != 10 {
+		t.Errorf("Expected the default limit, got %d %v", limit, err)
+	}
+	if err := execute(t, s, "Search.Limit", SearchArgs{Query: "go", Limit: 3}, &limit); err != nil || limit != 3 {
+		t.Errorf("Expected limit 3, got %d %v", limit, err)
+	}
+}
+
+type Profile struct {
+	ID      int    `json:"id"`
+	Name    string `json:"name"`
+	Email   string `json:"email"`
+	Address struct {
+		City    string `json:"city"`
+		Country string `json:"country"`
+	} `json:"address"`
+}
+
+type ProfileService struct{}
+
+func (ProfileService) Get(r *http.Request, args *struct{ ID int }, reply *Profile) error {
+	*reply = Profile{ID: args.ID, Name: "Ada", Email: "ada@example.com"}
+	reply.Address.City = "London"
+	reply.Address.Country = "UK"
+	return nil
+}
+
+func TestSparseFieldsets(t *testing.T) {
+	s := rpc.NewServer()
+	s.RegisterCodec(NewCodec(), "application/json")
+	s.RegisterService(new(ProfileService), "Profile")
+
+	var reply map[string]interface{}
+	err := execute(t, s, "Profile.Get", map[string]interface{}{"ID": 7, "_fields": []string{"id", "address.city"}}, &reply)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(reply) != 2 || reply["id"] != 7.0 || fmt.Sprint(reply["address"]) != "map[city:London]" {
+		t.Errorf("Wrong sparse reply %v", reply)
+	}
+	reply = nil
+	if err := execute(t, s, "Profile.Get", map[string]interface{}{"ID": 7}, &reply); err != nil || len(reply) != 4 {
+		t.Errorf("Expected the whole reply, got %v %v", reply, err)
+	}
+}
+
+type UnencodableResponse struct {
+	Result int
+}
+
+func (r *UnencodableResponse) MarshalJSON() ([]byte, error) {
+	return nil, errors.New("can't encode")
+}
+
+type UnencodableService struct{}
+
+func (t *UnencodableService) Get(r *http.Request, req *Service1Request, res *UnencodableResponse) error {
+	res.Result = 1
+	return nil
+}
+
+func TestEncodeError(t *testing.T) {
+	s := rpc.NewServer()
+	s.RegisterCodec(NewCodec(), "application/json")
+	s.RegisterService(new(UnencodableService), "")
+
+	var res UnencodableResponse
+	err := execute(t, s, "UnencodableService.Get", &Service1Request{4, 2}, &res)
+	jsonErr, ok := err.(*Error)
+	if !ok || jsonErr.Code != E_SERVER || !strings.HasPrefix(jsonErr.Message, "rpc: encoding the reply") {
+		t.Errorf("Expected an encode error, got %v", err)
+	}
+}
+
+type DetailsService struct{}
+
+func (t *DetailsService) Get(r *http.Request, req *Service1Request, res *Service1Response) error {
+	return rpc.WithDetails(&Error{Code: -32042, Message: "item locked"}, &rpc.RetryInfo{RetryDelay: 2 * time.Second})
+}
+
+func TestErrorDetails(t *testing.T) {
+	s := rpc.NewServer()
+	s.RegisterCodec(NewCodec(), "application/json")
+	s.RegisterService(new(DetailsService), "")
+
+	var res Service1Response
+	err := execute(t, s, "DetailsService.Get", &Service1Request{4, 2}, &res)
+	jsonErr, ok := err.(*Error)
+	if !ok || jsonErr.Code != -32042 || jsonErr.Message != "item locked" {
+		t.Fatalf("Expected the error of the method, got %v", err)
+	}
+	b, _ := json.Marshal(jsonErr.Data)
+	var encoded []json.RawMessage
+	json.Unmarshal(b, &encoded)
+	details, errDetails := rpc.DecodeDetails(encoded)
+	if errDetails != nil || len(details) != 1 {
+		t.Fatalf("Wrong details %s: %v", b, errDetails)
+	}
+	if info, ok := details[0].(*rpc.RetryInfo); !ok || info.RetryDelay != 2*time.Second {
+		t.Errorf("Wrong retry info %+v", details[0])
+	}
+}
+
+type FormatService struct{}
+
+type FormatResponse struct {
+	Text   string  `json:"text"`
+	Parent *string `json:"parent"`
+}
+
+func (t *FormatService) Get(r *http.Request, req *Service1Request, res *FormatResponse) error {
+	res.Text = "<b>"
+	return nil
+}
+
+func (t *FormatService) Debug(r *http.Request, req *Service1Request, res *FormatResponse) error {
+	res.Text = "<b>"
+	return nil
+}
+
+func TestMethodOptions(t *testing.T) {
+	s := rpc.NewServer()
+	codec := NewCodec()
+	codec.SetOptions(jsonopt.Options{OmitNull: true, DisableHTMLEscape: true})
+	codec.SetMethodOptions("FormatService.Debug", jsonopt.Options{Indent: " "})
+	s.RegisterCodec(codec, "application/json")
+	s.RegisterService(new(FormatService), "")
+
+	for _, test := range []struct {
+		method, expected string
+	}{
+		{"FormatService.Get", `{"jsonrpc":"2.0","result":{"text":"<b>"},"id":1}` + "\n"},
+		{"FormatService.Debug", "{\n \"jsonrpc\": \"2.0\",\n \"result\": {\n  \"text\": \"\\u003cb\\u003e\",\n  \"parent\": null\n },\n \"id\": 1\n}\n"},
+	} {
+		body := `{"jsonrpc":"2.0","method":"` + test.method + `","params":{},"id":1}`
+		r, _ := http.NewRequest("POST", "http://localhost:8080/", strings.NewReader(body))
+		r.Header.Set("Content-Type", "application/json")
+		w := NewRecorder()
+		s.ServeHTTP(w, r)
+		if w.Body.String() != test.expected {
+			t.Errorf("Expected %q for %s, got %q", test.expected, test.method, w.Body.String())
+		}
+	}
+}
+
+func TestPathRouting(t *testing.T) {
+	s := rpc.NewServer()
+	s.RegisterCodec(NewCodec(), "application/json")
+	s.RegisterService(new(Service1), "")
+	s.SetPathPrefix("/rpc")
+
+	for _, test := range []struct {
+		path, body string
+		status     int
+		expected   string
+	}{
+		{"/rpc/Service1/Multiply", `{"A": 4, "B": 2}`, 200, `{"Result":8}` + "\n"},
+		{"/rpc/Service1/Multiply", ``, 200, `{"Result":9999}` + "\n"},
+		{"/rpc/Service1/ResponseError", `{}`, 400, `{"code":-32000,"message":"` + ErrResponseError.Error() + `","data":null}` + "\n"},
+		{"/rpc/Service1/Multiply", `{"A": "4"}`, 400, ""},
+		{"/rpc/Service1/Multiply/x", `{}`, 404, ""},
+		{"/rpc/Service1.Multiply", `{}`, 404, ""},
+		// Requests to the prefix itself have an envelope.
+		{"/rpc", `{"jsonrpc":"2.0","method":"Service1.Multiply","params":{"A":3,"B":3},"id":1}`, 200, `{"jsonrpc":"2.0","result":{"Result":9},"id":1}` + "\n"},
+	} {
+		r, _ := http.NewRequest("POST", "http://localhost:8080"+test.path, strings.NewReader(test.body))
+		r.Header.Set("Content-Type", "application/json")
+		w := NewRecorder()
+		s.ServeHTTP(w, r)
+		if w.Code != test.status || (test.expected != "" && w.Body.String() != test.expected) {
+			t.Errorf("Expected %d %q for %s, got %d %q", test.status, test.expected, test.path, w.Code, w.Body.String())
+		}
+	}
+}
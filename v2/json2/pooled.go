@@ -0,0 +1,92 @@
+// Copyright 2009 The Go Authors. All rights reserved.
+// Copyright 2012 The Gorilla Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package json2
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"sync"
+
+	"github.com/gorilla/rpc/v2"
+	"github.com/gorilla/rpc/v2/jsonopt"
+)
+
+// MaxPooledBuffer is the capacity above which the request buffers of the
+// pooled decoding aren't reused, so that a single huge request doesn't
+// keep its buffer alive.
+const MaxPooledBuffer = 64 << 20
+
+var requestBuffers = sync.Pool{
+	New: func() interface{} { return new(bytes.Buffer) },
+}
+
+// EnablePooledDecoding makes the codec read the requests into pooled
+// buffers, decoding the params from them without the intermediate copies
+// of the default decoding. Only the decoded args, which methods may
+// retain, are allocated for each call, reducing the peak memory when many
+// concurrent requests carry multi-MB params.
+//
+// Buffers are reused once the args are decoded, unless decoding failed.
+// Batches are decoded as usual.
+func (c *Codec) EnablePooledDecoding(enabled bool) {
+	c.pooled = enabled
+}
+
+// pooledRequest is a serverRequest whose params reference the buffer
+// they're decoded from.
+type pooledRequest struct {
+	Version string           `json:"jsonrpc"`
+	Method  string           `json:"method"`
+	Params  rawRef           `json:"params"`
+	Id      *json.RawMessage `json:"id"`
+}
+
+// rawRef is a raw JSON value referencing the decoded data, instead of
+// copying it as json.RawMessage does.
+type rawRef []byte
+
+func (m *rawRef) UnmarshalJSON(data []byte) error {
+	if string(data) != "null" {
+		*m = data
+	}
+	return nil
+}
+
+// newPooledCodecRequest returns a CodecRequest decoding the request from a
+// pooled buffer.
+func newPooledCodecRequest(r *http.Request, encoder rpc.Encoder, errorMapper func(error) error, opts func(method string) *jsonopt.Options) rpc.CodecRequest {
+	buf := requestBuffers.Get().(*bytes.Buffer)
+	buf.Reset()
+	_, err := buf.ReadFrom(r.Body)
+	r.Body.Close()
+	if err != nil {
+		return parsedCodecRequest(new(serverRequest), err, encoder, errorMapper, opts)
+	}
+	if body := bufio.NewReader(bytes.NewReader(buf.Bytes())); isBatch(body) {
+		// The calls of the batch are decoded from the buffer as they're
+		// served, so it isn't reused.
+		return newBatchCodecRequest(body, encoder, errorMapper, opts)
+	}
+	var pr pooledRequest
+	err = json.Unmarshal(buf.Bytes(), &pr)
+	req := &serverRequest{Version: pr.Version, Method: pr.Method, Id: pr.Id}
+	if pr.Params != nil {
+		params := json.RawMessage(pr.Params)
+		req.Params = &params
+	}
+	c := parsedCodecRequest(req, err, encoder, errorMapper, opts)
+	if c.err == nil {
+		c.release = func() {
+			req.Params = nil
+			if buf.Cap() <= MaxPooledBuffer {
+				requestBuffers.Put(buf)
+			}
+		}
+	}
+	return c
+}
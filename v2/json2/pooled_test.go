@@ -0,0 +1,57 @@
+// Copyright 2009 The Go Authors. All rights reserved.
+// Copyright 2012 The Gorilla Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package json2
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/rpc/v2"
+)
+
+func TestPooledDecoding(t *testing.T) {
+	s := rpc.NewServer()
+	codec := NewCodec()
+	codec.EnablePooledDecoding(true)
+	s.RegisterCodec(codec, "application/json")
+	s.RegisterService(new(Service1), "")
+	var retained []json.RawMessage
+	s.RegisterFallbackFunc(func(r *http.Request, method string, params json.RawMessage) (interface{}, error) {
+		retained = append(retained, params)
+		return len(params), nil
+	})
+
+	for i := 1; i <= 3; i++ {
+		var res Service1Response
+		if err := execute(t, s, "Service1.Multiply", &Service1Request{i, 10}, &res); err != nil {
+			t.Fatal(err)
+		}
+		if res.Result != i*10 {
+			t.Errorf("Wrong result %d for %d", res.Result, i)
+		}
+	}
+
+	// Params retained by methods are copies of the reused buffers.
+	large := strings.Repeat("x", 1<<20)
+	for _, p := range []string{large, "y"} {
+		var n int
+		if err := executeRaw(t, s, map[string]interface{}{"jsonrpc": "2.0", "method": "Raw.Echo", "params": []string{p}, "id": 1}, &n); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(retained) != 2 || string(retained[0]) != `["`+large+`"]` || string(retained[1]) != `["y"]` {
+		t.Errorf("Retained params were modified")
+	}
+
+	// Errors still carry the params.
+	var res Service1Response
+	err := executeRaw(t, s, map[string]interface{}{"jsonrpc": "2.0", "method": "Service1.Multiply", "params": "bad", "id": 1}, &res)
+	if jsonErr, ok := err.(*Error); !ok || jsonErr.Code != E_INVALID_REQ || jsonErr.Data != "bad" {
+		t.Errorf("Expected an invalid request error with the params, got %#v", err)
+	}
+}
@@ -0,0 +1,117 @@
+// Copyright 2009 The Go Authors. All rights reserved.
+// Copyright 2012 The Gorilla Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package json2
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/rpc/v2"
+)
+
+const (
+	// DefaultMaxAttempts is the default number of attempts of calls.
+	DefaultMaxAttempts = 3
+	// DefaultMaxRetryWait is the default longest Retry-After honored.
+	DefaultMaxRetryWait = 30 * time.Second
+)
+
+// RetryPolicy configures the retries of the calls of a Client throttled by
+// the servers: calls answered with an E_THROTTLED error, or a 429 or 503
+// status, and a Retry-After header are sent again once the delay has
+// passed.
+type RetryPolicy struct {
+	// MaxAttempts is the number of attempts of a call, including the
+	// first one. If zero, DefaultMaxAttempts is used.
+	MaxAttempts int
+	// MaxWait is the longest delay waited before a retry: calls asked to
+	// wait longer fail right away. If zero, DefaultMaxRetryWait is used.
+	MaxWait time.Duration
+	// Methods are the retried methods. If empty, all methods are retried.
+	Methods []string
+}
+
+func (p *RetryPolicy) maxAttempts() int {
+	if p.MaxAttempts > 0 {
+		return p.MaxAttempts
+	}
+	return DefaultMaxAttempts
+}
+
+func (p *RetryPolicy) maxWait() time.Duration {
+	if p.MaxWait > 0 {
+		return p.MaxWait
+	}
+	return DefaultMaxRetryWait
+}
+
+// retriedPost posts the body as hedgedPost does, retrying throttled calls
+// as configured by the RetryPolicy.
+func (c *Client) retriedPost(ctx context.Context, method string, body []byte) (*http.Response, *endpoint, error) {
+	if c.Retry == nil || !hasMethod(c.Retry.Methods, method) {
+		return c.hedgedPost(ctx, method, body)
+	}
+	for attempt := 1; ; attempt++ {
+		resp, e, err := c.hedgedPost(ctx, method, body)
+		if err != nil || attempt >= c.Retry.maxAttempts() {
+			return resp, e, err
+		}
+		wait, ok := rpc.ParseRetryAfter(resp.Header, time.Now())
+		if !ok || wait > c.Retry.maxWait() || !isThrottled(resp) {
+			return resp, e, err
+		}
+		c.record(e, method, statusError(resp))
+		io.Copy(ioutil.Discard, resp.Body)
+		resp.Body.Close()
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, nil, ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// isThrottled returns true if the call was throttled: its response has a
+// 429 or 503 status, or is an E_THROTTLED error. The body of the response
+// is read to be decoded, and replaced to be read again.
+func isThrottled(resp *http.Response) bool {
+	if resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode == http.StatusServiceUnavailable {
+		return true
+	}
+	if resp.StatusCode != http.StatusOK {
+		return false
+	}
+	body, err := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		// The caller gets the error reading the body.
+		resp.Body = ioutil.NopCloser(io.MultiReader(bytes.NewReader(body), errorReader{err}))
+		return false
+	}
+	resp.Body = ioutil.NopCloser(bytes.NewReader(body))
+	var res struct {
+		Error *struct {
+			Code ErrorCode `json:"code"`
+		} `json:"error"`
+	}
+	return json.Unmarshal(body, &res) == nil && res.Error != nil && res.Error.Code == E_THROTTLED
+}
+
+// errorReader is a reader failing with err.
+type errorReader struct {
+	err error
+}
+
+func (r errorReader) Read([]byte) (int, error) {
+	return 0, r.err
+}
@@ -6,10 +6,15 @@
 package json2
 
 import (
+	"bufio"
+	"bytes"
 	"encoding/json"
+	"io/ioutil"
 	"net/http"
+	"reflect"
 
 	"github.com/gorilla/rpc/v2"
+	"github.com/gorilla/rpc/v2/jsonopt"
 )
 
 var null = json.RawMessage([]byte("null"))
@@ -86,11 +91,67 @@ func NewCodec() *Codec {
 type Codec struct {
 	encSel      rpc.EncoderSelector
 	errorMapper func(error) error
+	opts        *jsonopt.Options
+	methodOpts  map[string]*jsonopt.Options
+	pooled      bool
+}
+
+// SetOptions sets the options encoding the replies and decoding the params,
+// e.g. to encode 64-bit integers as strings.
+func (c *Codec) SetOptions(opts jsonopt.Options) {
+	c.opts = &opts
+}
+
+// SetMethodOptions sets the options of a method, replacing those set with
+// SetOptions, e.g. to pretty print the replies of a debug method:
+//
+//	codec.SetMethodOptions("Debug.Dump", jsonopt.Options{Indent: "  "})
+//
+// The method uses a dotted notation as in "Service.Method".
+func (c *Codec) SetMethodOptions(method string, opts jsonopt.Options) {
+	if c.methodOpts == nil {
+		c.methodOpts = make(map[string]*jsonopt.Options)
+	}
+	c.methodOpts[method] = &opts
+}
+
+// options returns the options of the method.
+func (c *Codec) options(method string) *jsonopt.Options {
+	if opts, ok := c.methodOpts[method]; ok {
+		return opts
+	}
+	return c.opts
 }
 
 // NewRequest returns a CodecRequest.
 func (c *Codec) NewRequest(r *http.Request) rpc.CodecRequest {
-	return newCodecRequest(r, c.encSel.Select(r), c.errorMapper)
+	if c.pooled {
+		return newPooledCodecRequest(r, c.encSel.Select(r), c.errorMapper, c.options)
+	}
+	return newCodecRequest(r, c.encSel.Select(r), c.errorMapper, c.options)
+}
+
+// NewPathRequest returns a CodecRequest for the path routing of the
+// server, see rpc.PathCodec: the body is only the params of the method,
+// e.g. {"A": 2, "B": 3}, and the response only its result, or the error
+// object with the HTTP status of the call.
+func (c *Codec) NewPathRequest(r *http.Request, method string) rpc.CodecRequest {
+	params, err := ioutil.ReadAll(r.Body)
+	r.Body.Close()
+	req := &serverRequest{Version: Version, Method: method, Id: &null}
+	if len(bytes.TrimSpace(params)) > 0 {
+		raw := json.RawMessage(params)
+		req.Params = &raw
+	}
+	codecReq := parsedCodecRequest(req, err, c.encSel.Select(r), c.errorMapper, c.options)
+	codecReq.bare = true
+	return codecReq
+}
+
+// Warmup prepares the JSON encoding of args and reply, see rpc.Warmer.
+func (c *Codec) Warmup(args, reply reflect.Type) {
+	jsonopt.Warmup(args)
+	jsonopt.Warmup(reply)
 }
 
 // ----------------------------------------------------------------------------
@@ -98,11 +159,22 @@ func (c *Codec) NewRequest(r *http.Request) rpc.CodecRequest {
 // ----------------------------------------------------------------------------
 
 // newCodecRequest returns a new CodecRequest.
-func newCodecRequest(r *http.Request, encoder rpc.Encoder, errorMapper func(error) error) rpc.CodecRequest {
+func newCodecRequest(r *http.Request, encoder rpc.Encoder, errorMapper func(error) error, opts func(method string) *jsonopt.Options) rpc.CodecRequest {
+	body := bufio.NewReader(r.Body)
+	if isBatch(body) {
+		// The calls are decoded from the body as they're served.
+		return newBatchCodecRequest(body, encoder, errorMapper, opts)
+	}
 	// Decode the request body and check if RPC method is valid.
 	req := new(serverRequest)
-	err := json.NewDecoder(r.Body).Decode(req)
+	err := json.NewDecoder(body).Decode(req)
+	r.Body.Close()
+	return parsedCodecRequest(req, err, encoder, errorMapper, opts)
+}
 
+// parsedCodecRequest returns a CodecRequest for a decoded request, with the
+// options of its method.
+func parsedCodecRequest(req *serverRequest, err error, encoder rpc.Encoder, errorMapper func(error) error, opts func(method string) *jsonopt.Options) *CodecRequest {
 	if err != nil {
 		err = &Error{
 			Code:    E_PARSE,
@@ -116,9 +188,7 @@ func newCodecRequest(r *http.Request, encoder rpc.Encoder, errorMapper func(erro
 			Data:    req,
 		}
 	}
-
-	r.Body.Close()
-	return &CodecRequest{request: req, err: err, encoder: encoder, errorMapper: errorMapper}
+	return &CodecRequest{request: req, err: err, encoder: encoder, errorMapper: errorMapper, opts: opts(req.Method)}
 }
 
 // CodecRequest decodes and encodes a single request.
@@ -127,6 +197,12 @@ type CodecRequest struct {
 	err         error
 	encoder     rpc.Encoder
 	errorMapper func(error) error
+	opts        *jsonopt.Options
+	fields      []string
+	// bare is set for the calls of path routing, without envelope
+	bare bool
+	// returns the buffer of the pooled decoding, if set
+	release func()
 }
 
 // Method returns the RPC method for the current request.
@@ -145,7 +221,8 @@ func (c *CodecRequest) Method() (string, error) {
 // accordance with http://www.jsonrpc.org/specification#parameter_structures
 //
 // by-position: params MUST be an Array, containing the
-// values in the Server expected order.
+// values in the Server expected order. Args of slice type, e.g. *[]Item,
+// take the Array itself, so that methods can take a list of values.
 //
 // by-name: params MUST be an Object, with member names
 // that match the Server expected parameter names. The
@@ -156,43 +233,145 @@ func (c *CodecRequest) ReadRequest(args interface{}) error {
 	if c.err == nil && c.request.Params != nil {
 		// Note: if c.request.Params is nil it's not an error, it's an optional member.
 		// JSON params structured object. Unmarshal to the args object.
-		if err := json.Unmarshal(*c.request.Params, args); err != nil {
+		if err := c.opts.Unmarshal(*c.request.Params, args); err != nil {
+			// Invalid values, e.g. UUIDs, are reported even if the
+			// fallback fails on the shape of the params.
+			errParams, _ := err.(*rpc.InvalidParamsError)
 			// Clearly JSON params is not a structured object,
 			// fallback and attempt an unmarshal with JSON params as
 			// array value and RPC params is struct. Unmarshal into
 			// array containing the request struct.
-			params := [1]interface{}{args}
-			if err = json.Unmarshal(*c.request.Params, &params); err != nil {
+			var params [1]json.RawMessage
+			if err = json.Unmarshal(*c.request.Params, &params); err == nil && params[0] != nil {
+				if err = c.opts.Unmarshal(params[0], args); err == nil {
+					jsonopt.RecordPresence(params[0], args)
+					c.fields = jsonopt.Fields(params[0])
+				} else if e, ok := err.(*rpc.InvalidParamsError); ok {
+					errParams = e
+				}
+			}
+			if err != nil && errParams != nil {
+				c.err = invalidParams(errParams)
+			} else if err != nil {
 				c.err = &Error{
 					Code:    E_INVALID_REQ,
 					Message: err.Error(),
 					Data:    c.request.Params,
 				}
 			}
+		} else {
+			jsonopt.RecordPresence(*c.request.Params, args)
+			c.fields = jsonopt.Fields(*c.request.Params)
 		}
 	}
+	if c.release != nil && c.err == nil {
+		c.release()
+		c.release = nil
+	}
 	return c.err
 }
 
 // WriteResponse encodes the response and writes it to the ResponseWriter.
+// If the reply can't be encoded, an error response is written instead.
 func (c *CodecRequest) WriteResponse(w http.ResponseWriter, reply interface{}) {
+	if err := c.EncodeResponse(w, reply); err != nil {
+		c.WriteError(w, http.StatusInternalServerError, err)
+	}
+}
+
+// EncodeResponse encodes the response and writes it to the ResponseWriter,
+// or returns the error encoding the reply without writing anything, see
+// rpc.ResponseEncoder.
+func (c *CodecRequest) EncodeResponse(w http.ResponseWriter, reply interface{}) error {
 	res := &serverResponse{
 		Version: Version,
 		Result:  reply,
 		Id:      c.request.Id,
 	}
-	c.writeServerResponse(w, res)
+	if c.opts.Converts(reply) || len(c.fields) > 0 {
+		result, err := c.opts.Marshal(reply)
+		if err == nil && len(c.fields) > 0 {
+			result, err = jsonopt.Prune(result, c.fields)
+		}
+		if err != nil {
+			return err
+		}
+		res.Result = json.RawMessage(result)
+	}
+	if c.request.Id == nil {
+		// Notifications don't have a response.
+		return nil
+	}
+	// The response is encoded before writing anything, so that a reply
+	// that can't be encoded isn't partially written.
+	var b []byte
+	var err error
+	if c.bare {
+		b, err = c.opts.Format(res.Result)
+	} else {
+		b, err = c.opts.Format(res)
+	}
+	if err != nil {
+		return err
+	}
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	c.encoder.Encode(w).Write(append(b, '\n'))
+	return nil
+}
+
+// EncodeItem encodes a result of an NDJSON stream with the options of the
+// codec, on a single line, see rpc.ItemEncoder.
+func (c *CodecRequest) EncodeItem(v interface{}) ([]byte, error) {
+	opts := c.opts
+	if opts != nil && opts.Indent != "" {
+		line := *opts
+		line.Indent = ""
+		opts = &line
+	}
+	return opts.Marshal(v)
 }
 
 func (c *CodecRequest) WriteError(w http.ResponseWriter, status int, err error) {
 	err = c.tryToMapIfNotAnErrorAlready(err)
 	jsonErr, ok := err.(*Error)
-	if !ok {
+	details := rpc.ErrorDetails(err)
+	if !ok && len(details) > 0 {
+		jsonErr, ok = unwrapError(err)
+	}
+	if err == rpc.ErrNotModified {
+		jsonErr = &Error{
+			Code:    E_NOT_MODIFIED,
+			Message: err.Error(),
+		}
+	} else if e, isParams := err.(*rpc.InvalidParamsError); isParams {
+		jsonErr = invalidParams(e)
+	} else if isInternal(err) {
+		jsonErr = &Error{
+			Code:    E_INTERNAL,
+			Message: err.Error(),
+		}
+	} else if !ok && (status == http.StatusTooManyRequests || status == http.StatusServiceUnavailable) {
+		jsonErr = &Error{
+			Code:    E_THROTTLED,
+			Message: err.Error(),
+		}
+	} else if !ok {
 		jsonErr = &Error{
 			Code:    E_SERVER,
 			Message: err.Error(),
 		}
 	}
+	if len(details) > 0 && jsonErr.Data == nil {
+		// The details are the data of the error, as those of a
+		// google.rpc.Status.
+		if data, errDetails := rpc.EncodeDetails(details); errDetails == nil {
+			jsonErr = &Error{Code: jsonErr.Code, Message: jsonErr.Message, Data: data}
+		}
+	}
+	if c.bare {
+		c.writeBareError(w, status, jsonErr)
+		return
+	}
 	res := &serverResponse{
 		Version: Version,
 		Error:   jsonErr,
@@ -201,6 +380,64 @@ func (c *CodecRequest) WriteError(w http.ResponseWriter, status int, err error)
 	c.writeServerResponse(w, res)
 }
 
+// unwrapError returns the *Error wrapped by err, e.g. with its details,
+// see rpc.WithDetails, as errors.As of Go 1.13.
+func unwrapError(err error) (*Error, bool) {
+	for err != nil {
+		if e, ok := err.(*Error); ok {
+			return e, true
+		}
+		u, ok := err.(interface{ Unwrap() error })
+		if !ok {
+			return nil, false
+		}
+		err = u.Unwrap()
+	}
+	return nil, false
+}
+
+// writeBareError writes the error object of a call of path routing, with
+// the HTTP status of the call.
+func (c *CodecRequest) writeBareError(w http.ResponseWriter, status int, err *Error) {
+	if status == http.StatusNotModified {
+		w.WriteHeader(status)
+		return
+	}
+	b, errFormat := c.opts.Format(err)
+	if errFormat != nil {
+		rpc.WriteError(w, http.StatusInternalServerError, errFormat.Error())
+		return
+	}
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	// The encoder sets its headers before the status is written.
+	ew := c.encoder.Encode(w)
+	w.WriteHeader(status)
+	ew.Write(append(b, '\n'))
+}
+
+// isInternal returns true for the errors of methods breaking their
+// contract, e.g. with an invalid reply.
+func isInternal(err error) bool {
+	switch err.(type) {
+	case *rpc.InvalidReplyError, *rpc.UncataloguedError:
+		return true
+	}
+	return false
+}
+
+// invalidParams returns the E_BAD_PARAMS error of e, with the path of the
+// invalid field as data, if known.
+func invalidParams(e *rpc.InvalidParamsError) *Error {
+	err := &Error{
+		Code:    E_BAD_PARAMS,
+		Message: e.Error(),
+	}
+	if e.Field != "" {
+		err.Data = e.Field
+	}
+	return err
+}
+
 func (c CodecRequest) tryToMapIfNotAnErrorAlready(err error) error {
 	if _, ok := err.(*Error); ok || c.errorMapper == nil {
 		return err
@@ -212,14 +449,15 @@ func (c *CodecRequest) writeServerResponse(w http.ResponseWriter, res *serverRes
 	// Id is null for notifications and they don't have a response, unless we couldn't even parse the JSON, in that
 	// case we can't know whether it was intended to be a notification
 	if c.request.Id != nil || isParseErrorResponse(res) {
-		w.Header().Set("Content-Type", "application/json; charset=utf-8")
-		encoder := json.NewEncoder(c.encoder.Encode(w))
-		err := encoder.Encode(res)
+		b, err := c.opts.Format(res)
 
 		// Not sure in which case will this happen. But seems harmless.
 		if err != nil {
 			rpc.WriteError(w, http.StatusInternalServerError, err.Error())
+			return
 		}
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		c.encoder.Encode(w).Write(append(b, '\n'))
 	}
 }
 
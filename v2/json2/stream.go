@@ -0,0 +1,164 @@
+// Copyright 2009 The Go Authors. All rights reserved.
+// Copyright 2012 The Gorilla Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package json2
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/gorilla/rpc/v2"
+	"github.com/gorilla/rpc/v2/jsonopt"
+)
+
+// StreamReader reads the results of a streaming method, see rpc.Stream.
+type StreamReader struct {
+	body    io.ReadCloser
+	trailer http.Header
+	dec     *json.Decoder
+	ndjson  bool
+	opts    *jsonopt.Options
+	err     error
+}
+
+// Stream calls a streaming method and returns a reader for its results.
+// The call is canceled with the context or by closing the reader.
+func (c *Client) Stream(ctx context.Context, method string, args interface{}) (*StreamReader, error) {
+	body, err := encodeClientRequest(method, args, c.Options)
+	if err != nil {
+		return nil, err
+	}
+	resp, e, err := c.post(ctx, method, body)
+	if err != nil {
+		return nil, err
+	}
+	if err = statusError(resp); err != nil {
+		resp.Body.Close()
+		c.record(e, method, err)
+		return nil, err
+	}
+	c.record(e, method, nil)
+	sr := NewStreamReader(resp)
+	sr.opts = c.Options
+	return sr, nil
+}
+
+// StreamItems calls a streaming method as Stream does, asking for the bare
+// results, one JSON value per line, see rpc.NDJSONContentType. It suits
+// large exports, whose results are read as they're produced:
+//
+//	rows, err := c.StreamItems(ctx, "Export.Rows", args)
+//	if err != nil {
+//		return err
+//	}
+//	defer rows.Close()
+//	var row Row
+//	for rows.Next(&row) {
+//		// ...
+//	}
+//	return rows.Err()
+func (c *Client) StreamItems(ctx context.Context, method string, args interface{}) (*StreamReader, error) {
+	return c.Stream(context.WithValue(ctx, ndjsonKey{}, true), method, args)
+}
+
+type ndjsonKey struct{}
+
+// NewStreamReader returns a reader for the results of a streaming method
+// in the response body.
+func NewStreamReader(resp *http.Response) *StreamReader {
+	ndjson := strings.HasPrefix(resp.Header.Get("Content-Type"), rpc.NDJSONContentType)
+	return &StreamReader{body: resp.Body, trailer: resp.Trailer, dec: json.NewDecoder(resp.Body), ndjson: ndjson}
+}
+
+// Recv decodes the next result into reply. It returns io.EOF after the
+// last result, or the error returned by the method.
+//
+// When the server declared the rpc.StatusTrailer, a stream ending without
+// it was interrupted: io.ErrUnexpectedEOF is returned instead of io.EOF.
+// A failure status in the trailers is returned as an E_SERVER error.
+func (s *StreamReader) Recv(reply interface{}) error {
+	if s.err != nil {
+		return s.err
+	}
+	if s.ndjson {
+		var item json.RawMessage
+		if err := s.dec.Decode(&item); err != nil {
+			if err == io.EOF {
+				err = s.trailerErr()
+			}
+			s.err = err
+			return err
+		}
+		return s.opts.Unmarshal(item, reply)
+	}
+	var c clientResponse
+	if err := s.dec.Decode(&c); err != nil {
+		if err == io.EOF {
+			err = s.trailerErr()
+		}
+		s.err = err
+		return err
+	}
+	if c.Error != nil {
+		jsonErr := &Error{}
+		if err := json.Unmarshal(*c.Error, jsonErr); err != nil {
+			jsonErr = &Error{Code: E_SERVER, Message: string(*c.Error)}
+		}
+		s.err = jsonErr
+		return jsonErr
+	}
+	if c.Result == nil {
+		return ErrNullResult
+	}
+	return s.opts.Unmarshal(*c.Result, reply)
+}
+
+// Next decodes the next result into reply, and returns false after the
+// last one or on error, see Err.
+func (s *StreamReader) Next(reply interface{}) bool {
+	err := s.Recv(reply)
+	if err != nil && s.err == nil {
+		// A result that couldn't be decoded ends the iteration.
+		s.err = err
+	}
+	return err == nil
+}
+
+// Err returns the error that ended the iteration with Next, or nil if all
+// the results were read.
+func (s *StreamReader) Err() error {
+	if s.err == io.EOF {
+		return nil
+	}
+	return s.err
+}
+
+// trailerErr returns the error of the trailers of a stream read to the
+// end, or io.EOF if it completed.
+func (s *StreamReader) trailerErr() error {
+	if _, declared := s.trailer[rpc.StatusTrailer]; !declared {
+		return io.EOF
+	}
+	status := s.trailer.Get(rpc.StatusTrailer)
+	switch status {
+	case "":
+		return io.ErrUnexpectedEOF
+	case "200":
+		return io.EOF
+	}
+	msg := s.trailer.Get(rpc.ErrorTrailer)
+	if msg == "" {
+		msg = "rpc: stream failed with status " + status
+	}
+	return &Error{Code: E_SERVER, Message: msg}
+}
+
+// Close closes the response body, canceling the call.
+func (s *StreamReader) Close() error {
+	return s.body.Close()
+}
@@ -0,0 +1,115 @@
+// Copyright 2009 The Go Authors. All rights reserved.
+// Copyright 2012 The Gorilla Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package json2
+
+import (
+	"crypto/tls"
+	"net"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// Defaults of TransportOptions.
+const (
+	DefaultDialTimeout         = 10 * time.Second
+	DefaultKeepAlive           = 30 * time.Second
+	DefaultTLSHandshakeTimeout = 10 * time.Second
+	DefaultIdleConnTimeout     = 90 * time.Second
+	DefaultMaxIdleConnsPerHost = 16
+)
+
+// TransportOptions configure the HTTP transport of a Client, without
+// building an http.Transport. The zero value has defaults suited for
+// production: connections time out after DefaultDialTimeout, TLS requires
+// TLS 1.2 or later, proxies are taken from the environment and HTTP/2 is
+// used when the servers support it, with Go 1.13 and later. Calls themselves are bounded by the
+// deadline of their context, not by the transport.
+type TransportOptions struct {
+	// TLSConfig is the TLS configuration, e.g. with the root CAs or the
+	// client certificates. If nil, the system roots are used with a
+	// minimum of TLS 1.2.
+	TLSConfig *tls.Config
+	// Proxy returns the proxy of a request, nil for none. If nil,
+	// http.ProxyFromEnvironment is used.
+	Proxy func(*http.Request) (*url.URL, error)
+	// NoProxy disables proxies, including the ones of the environment.
+	NoProxy bool
+	// DialTimeout limits the time to connect. If zero,
+	// DefaultDialTimeout is used.
+	DialTimeout time.Duration
+	// KeepAlive is the interval of the TCP keep-alives. If zero,
+	// DefaultKeepAlive is used.
+	KeepAlive time.Duration
+	// TLSHandshakeTimeout limits the time of TLS handshakes. If zero,
+	// DefaultTLSHandshakeTimeout is used.
+	TLSHandshakeTimeout time.Duration
+	// ResponseHeaderTimeout limits the time to wait for the response
+	// headers after sending a request. If zero, there is no limit.
+	ResponseHeaderTimeout time.Duration
+	// IdleConnTimeout is the time after which idle connections are
+	// closed. If zero, DefaultIdleConnTimeout is used.
+	IdleConnTimeout time.Duration
+	// MaxIdleConnsPerHost is the number of idle connections kept per
+	// server. If zero, DefaultMaxIdleConnsPerHost is used.
+	MaxIdleConnsPerHost int
+	// DisableHTTP2 forces HTTP/1.1.
+	DisableHTTP2 bool
+}
+
+// NewHTTPClient returns an HTTP client with a transport configured by the
+// options, for Client.HTTPClient:
+//
+//	c := json2.NewClient("https://api.example.com/rpc")
+//	c.HTTPClient = json2.NewHTTPClient(json2.TransportOptions{
+//		TLSConfig: &tls.Config{RootCAs: pool},
+//	})
+func NewHTTPClient(opts TransportOptions) *http.Client {
+	return &http.Client{Transport: opts.transport()}
+}
+
+func (o TransportOptions) transport() *http.Transport {
+	dialer := &net.Dialer{
+		Timeout:   orDuration(o.DialTimeout, DefaultDialTimeout),
+		KeepAlive: orDuration(o.KeepAlive, DefaultKeepAlive),
+	}
+	t := &http.Transport{
+		Proxy:                 o.Proxy,
+		DialContext:           dialer.DialContext,
+		TLSClientConfig:       o.TLSConfig,
+		TLSHandshakeTimeout:   orDuration(o.TLSHandshakeTimeout, DefaultTLSHandshakeTimeout),
+		ResponseHeaderTimeout: o.ResponseHeaderTimeout,
+		IdleConnTimeout:       orDuration(o.IdleConnTimeout, DefaultIdleConnTimeout),
+		MaxIdleConns:          100, // as http.DefaultTransport
+		MaxIdleConnsPerHost:   o.MaxIdleConnsPerHost,
+	}
+	switch {
+	case o.NoProxy:
+		t.Proxy = nil
+	case t.Proxy == nil:
+		t.Proxy = http.ProxyFromEnvironment
+	}
+	if t.TLSClientConfig == nil {
+		t.TLSClientConfig = &tls.Config{MinVersion: tls.VersionTLS12}
+	}
+	if t.MaxIdleConnsPerHost == 0 {
+		t.MaxIdleConnsPerHost = DefaultMaxIdleConnsPerHost
+	}
+	if o.DisableHTTP2 {
+		// A non-nil empty map disables HTTP/2.
+		t.TLSNextProto = make(map[string]func(string, *tls.Conn) http.RoundTripper)
+	} else {
+		forceAttemptHTTP2(t)
+	}
+	return t
+}
+
+func orDuration(d, def time.Duration) time.Duration {
+	if d > 0 {
+		return d
+	}
+	return def
+}
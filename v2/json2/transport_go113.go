@@ -0,0 +1,18 @@
+// Copyright 2009 The Go Authors. All rights reserved.
+// Copyright 2012 The Gorilla Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build go1.13
+// +build go1.13
+
+package json2
+
+import "net/http"
+
+// forceAttemptHTTP2 enables HTTP/2 on a transport with a custom dialer and
+// TLS configuration, and returns true.
+func forceAttemptHTTP2(t *http.Transport) bool {
+	t.ForceAttemptHTTP2 = true
+	return true
+}
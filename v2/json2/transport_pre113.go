@@ -0,0 +1,18 @@
+// Copyright 2009 The Go Authors. All rights reserved.
+// Copyright 2012 The Gorilla Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build !go1.13
+// +build !go1.13
+
+package json2
+
+import "net/http"
+
+// forceAttemptHTTP2 returns false: before Go 1.13, transports with a
+// custom dialer and TLS configuration only use HTTP/1.1 without
+// golang.org/x/net/http2.
+func forceAttemptHTTP2(t *http.Transport) bool {
+	return false
+}
@@ -0,0 +1,170 @@
+// Copyright 2009 The Go Authors. All rights reserved.
+// Copyright 2012 The Gorilla Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package jsonopt
+
+import (
+	"bytes"
+	"encoding/json"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+)
+
+// Canonicalize returns the canonical form of the JSON value data, as the
+// RFC 8785 JSON Canonicalization Scheme: no whitespace, object members
+// sorted by the UTF-16 code units of their names, strings with the minimal
+// escaping and numbers in their shortest ECMAScript form. Integers without
+// fraction or exponent are kept exact, so int64 values don't round through
+// float64.
+//
+// Equal values have the same canonical form whatever the marshalers, Go
+// version or map iteration order that produced them, so it's fit for
+// signatures, ETags and cache keys computed from the encoded bytes.
+func Canonicalize(data []byte) ([]byte, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var tree interface{}
+	if err := dec.Decode(&tree); err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	if err := writeCanonical(&buf, tree); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func writeCanonical(buf *bytes.Buffer, tree interface{}) error {
+	switch t := tree.(type) {
+	case nil:
+		buf.WriteString("null")
+	case bool:
+		buf.WriteString(strconv.FormatBool(t))
+	case string:
+		writeCanonicalString(buf, t)
+	case json.Number:
+		n, err := canonicalNumber(t)
+		if err != nil {
+			return err
+		}
+		buf.WriteString(n)
+	case []interface{}:
+		buf.WriteByte('[')
+		for i, v := range t {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			if err := writeCanonical(buf, v); err != nil {
+				return err
+			}
+		}
+		buf.WriteByte(']')
+	case map[string]interface{}:
+		names := make([]string, 0, len(t))
+		for name := range t {
+			names = append(names, name)
+		}
+		sort.Sort(byUTF16(names))
+		buf.WriteByte('{')
+		for i, name := range names {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			writeCanonicalString(buf, name)
+			buf.WriteByte(':')
+			if err := writeCanonical(buf, t[name]); err != nil {
+				return err
+			}
+		}
+		buf.WriteByte('}')
+	}
+	return nil
+}
+
+// lessUTF16 compares strings by their UTF-16 code units, as RFC 8785 sorts
+// the members of objects.
+func lessUTF16(a, b string) bool {
+	ua, ub := utf16.Encode([]rune(a)), utf16.Encode([]rune(b))
+	for i := 0; i < len(ua) && i < len(ub); i++ {
+		if ua[i] != ub[i] {
+			return ua[i] < ub[i]
+		}
+	}
+	return len(ua) < len(ub)
+}
+
+// byUTF16 sorts strings by their UTF-16 code units.
+type byUTF16 []string
+
+func (s byUTF16) Len() int           { return len(s) }
+func (s byUTF16) Less(i, j int) bool { return lessUTF16(s[i], s[j]) }
+func (s byUTF16) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
+
+// writeCanonicalString writes s with only the quote, the backslash and the
+// control characters escaped.
+func writeCanonicalString(buf *bytes.Buffer, s string) {
+	const hex = "0123456789abcdef"
+	buf.WriteByte('"')
+	for _, r := range s {
+		switch r {
+		case '"', '\\':
+			buf.WriteByte('\\')
+			buf.WriteRune(r)
+		case '\b':
+			buf.WriteString(`\b`)
+		case '\f':
+			buf.WriteString(`\f`)
+		case '\n':
+			buf.WriteString(`\n`)
+		case '\r':
+			buf.WriteString(`\r`)
+		case '\t':
+			buf.WriteString(`\t`)
+		default:
+			if r < 0x20 {
+				buf.WriteString(`\u00`)
+				buf.WriteByte(hex[r>>4])
+				buf.WriteByte(hex[r&0xf])
+			} else {
+				buf.WriteRune(r)
+			}
+		}
+	}
+	buf.WriteByte('"')
+}
+
+// canonicalNumber returns the shortest ECMAScript form of a number, e.g.
+// 100 for 1E2 and 1e+21 for 1000000000000000000000.0. Integers are kept as
+// is, but for -0.
+func canonicalNumber(n json.Number) (string, error) {
+	s := string(n)
+	if !strings.ContainsAny(s, ".eE") {
+		if s == "-0" {
+			return "0", nil
+		}
+		return s, nil
+	}
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return "", err
+	}
+	if f == 0 {
+		return "0", nil
+	}
+	if abs := math.Abs(f); abs >= 1e21 || abs < 1e-6 {
+		// Exponent form, e.g. 1e-7 and 1e+21, as encoding/json encodes
+		// float64 values.
+		e := strconv.FormatFloat(f, 'e', -1, 64)
+		if i := len(e) - 4; i >= 0 && e[i] == 'e' && e[i+2] == '0' {
+			// Clean up e-09 to e-9.
+			e = e[:i+2] + e[i+3:]
+		}
+		return e, nil
+	}
+	return strconv.FormatFloat(f, 'f', -1, 64), nil
+}
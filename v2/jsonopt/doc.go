@@ -0,0 +1,38 @@
+// Copyright 2009 The Go Authors. All rights reserved.
+// Copyright 2012 The Gorilla Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+/*
+Package gorilla/rpc/v2/jsonopt encodes and decodes JSON with options
+applied to all the values of a given type, instead of custom marshalers on
+each struct. It is used by the JSON codecs and clients:
+
+	codec := json2.NewCodec()
+	codec.SetOptions(jsonopt.Options{Int64AsString: true})
+	s.RegisterCodec(codec, "application/json")
+
+With the zero Options, values are encoded exactly as by encoding/json.
+Otherwise values are converted with reflection following the rules of
+encoding/json, which is slower.
+
+Values implementing rpc.Marshaler and rpc.Unmarshaler are encoded with
+them, taking precedence over encoding/json marshalers, so that they're
+encoded consistently by all codecs. Types are only converted when they
+may contain such values, so other types keep the fast path.
+
+The Canonical option encodes the responses in the RFC 8785 canonical form,
+with sorted members and fixed number formatting, so that signatures, ETags
+and cache keys computed from their bytes are stable:
+
+	codec.SetMethodOptions("Prices.Quote", jsonopt.Options{Canonical: true})
+
+Clients may ask for sparse replies with the reserved "_fields" member of
+the params, listing the members of the reply to send back; the JSON codecs
+prune the other ones before writing the reply, e.g. to reduce the payloads
+of mobile clients without a reply struct per view:
+
+	{"jsonrpc": "2.0", "method": "Users.Get", "id": 1,
+		"params": {"id": 42, "_fields": ["id", "name", "address.city"]}}
+*/
+package jsonopt
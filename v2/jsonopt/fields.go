@@ -0,0 +1,96 @@
+// Copyright 2009 The Go Authors. All rights reserved.
+// Copyright 2012 The Gorilla Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package jsonopt
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+)
+
+// FieldsParam is the reserved member of the params objects listing the
+// members of the reply sent back to the client, e.g.:
+//
+//	{"id": 42, "_fields": ["id", "name", "owner.email"]}
+//
+// Members of nested objects are listed with dotted paths, and the members
+// of the objects in arrays are pruned in each object.
+const FieldsParam = "_fields"
+
+// Fields returns the members listed in the FieldsParam member of the JSON
+// object params, or nil if it's absent.
+func Fields(params []byte) []string {
+	var p struct {
+		Fields []string `json:"_fields"`
+	}
+	if !bytes.Contains(params, []byte(`"`+FieldsParam+`"`)) || json.Unmarshal(params, &p) != nil {
+		return nil
+	}
+	return p.Fields
+}
+
+// Prune returns the JSON value data with only the members of its objects
+// listed in fields, as returned by Fields.
+func Prune(data []byte, fields []string) ([]byte, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var tree interface{}
+	if err := dec.Decode(&tree); err != nil {
+		return nil, err
+	}
+	return json.Marshal(prune(tree, newFieldSet(fields)))
+}
+
+// fieldSet is the tree of the dotted paths of members; a nil fieldSet
+// keeps all the members.
+type fieldSet map[string]fieldSet
+
+func newFieldSet(fields []string) fieldSet {
+	set := make(fieldSet)
+	for _, f := range fields {
+		names := strings.Split(f, ".")
+		cur := set
+		for i, name := range names {
+			next, ok := cur[name]
+			if ok && next == nil {
+				// The member is already kept whole.
+				break
+			}
+			if i == len(names)-1 {
+				cur[name] = nil
+				break
+			}
+			if !ok {
+				next = make(fieldSet)
+				cur[name] = next
+			}
+			cur = next
+		}
+	}
+	return set
+}
+
+func prune(tree interface{}, set fieldSet) interface{} {
+	if set == nil {
+		return tree
+	}
+	switch t := tree.(type) {
+	case map[string]interface{}:
+		for name, v := range t {
+			sub, ok := set[name]
+			if !ok {
+				delete(t, name)
+				continue
+			}
+			t[name] = prune(v, sub)
+		}
+	case []interface{}:
+		for i, v := range t {
+			t[i] = prune(v, set)
+		}
+	}
+	return tree
+}
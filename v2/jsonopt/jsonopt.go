@@ -0,0 +1,922 @@
+// Copyright 2009 The Go Authors. All rights reserved.
+// Copyright 2012 The Gorilla Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package jsonopt
+
+import (
+	"bytes"
+	"encoding"
+	"encoding/json"
+	"fmt"
+	"math"
+	"math/big"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/rpc/v2"
+)
+
+// BigFormat is the encoding of big numbers and decimals.
+type BigFormat int
+
+const (
+	// BigDefault encodes big numbers as encoding/json does, and doesn't
+	// support Decimal.
+	BigDefault BigFormat = iota
+	// BigAsString encodes big numbers and decimals as JSON strings, e.g.
+	// "12.30", which clients decode without losing precision.
+	BigAsString
+	// BigAsNumber encodes big numbers and decimals as JSON numbers, e.g.
+	// 12.30, exact in the JSON text. Rationals without an exact decimal
+	// representation, e.g. 1/3, are encoded as strings.
+	BigAsNumber
+)
+
+// TimeFormat is the encoding of time.Time values.
+type TimeFormat int
+
+const (
+	// TimeDefault encodes times as encoding/json does, in RFC 3339
+	// format with nanoseconds.
+	TimeDefault TimeFormat = iota
+	// TimeRFC3339 encodes times in RFC 3339 format, truncated to the
+	// second, e.g. "2006-01-02T15:04:05Z".
+	TimeRFC3339
+	// TimeRFC3339Nano encodes times in RFC 3339 format with nanoseconds.
+	TimeRFC3339Nano
+	// TimeUnix encodes times as the number of seconds since the Unix
+	// epoch, e.g. 1136214245.
+	TimeUnix
+	// TimeUnixMilli encodes times as the number of milliseconds since the
+	// Unix epoch.
+	TimeUnixMilli
+)
+
+// Options are the JSON encoding options.
+type Options struct {
+	// Int64AsString encodes int64 and uint64 values as JSON strings, since
+	// JavaScript numbers lose precision above 2^53. Both strings and
+	// numbers are accepted when decoding.
+	Int64AsString bool
+	// BigNumbers encodes *big.Int, *big.Rat, *big.Float and Decimal values
+	// losslessly, so amounts don't round-trip through float64. Both
+	// strings and numbers are accepted when decoding.
+	BigNumbers BigFormat
+	// Time is the format of time.Time values. RFC 3339 strings and
+	// numbers in the unit of the format, seconds by default, are accepted
+	// when decoding.
+	Time TimeFormat
+	// TimeLocation, if set, is the location of the encoded and decoded
+	// times, e.g. time.UTC.
+	TimeLocation *time.Location
+	// OmitNull omits the struct fields that would be encoded as null, nil
+	// pointers, slices, maps and interfaces, even without the omitempty
+	// option, for clients telling missing fields from null ones.
+	OmitNull bool
+
+	// DisableHTMLEscape keeps the <, > and & characters of strings as is,
+	// instead of escaping them as \u003c, \u003e and \u0026 for HTML
+	// pages, as json.Encoder.SetEscapeHTML(false).
+	DisableHTMLEscape bool
+	// Indent pretty prints the JSON with the indent, e.g. "  ", for the
+	// responses read by people, such as debug endpoints.
+	Indent string
+	// Canonical encodes the JSON in its canonical form, see Canonicalize,
+	// so that responses are signed, tagged or cached from stable bytes.
+	// It takes precedence over DisableHTMLEscape and Indent.
+	Canonical bool
+}
+
+// Decimal is implemented by pointers to decimal number types, e.g. of
+// money amounts, to be encoded losslessly with the BigNumbers option.
+type Decimal interface {
+	// DecimalString returns the exact decimal representation, e.g.
+	// "-12.30".
+	DecimalString() string
+	// SetDecimalString sets the value from a decimal representation.
+	SetDecimalString(s string) error
+}
+
+// active returns true if the options change the encoded values, not only
+// their formatting.
+func (o *Options) active() bool {
+	if o == nil {
+		return false
+	}
+	values := *o
+	values.DisableHTMLEscape, values.Indent, values.Canonical = false, "", false
+	return values != Options{}
+}
+
+// Format returns the JSON encoding of v by encoding/json with the
+// formatting options only, DisableHTMLEscape, Indent and Canonical, e.g.
+// for the envelope of a result encoded with Marshal.
+func (o *Options) Format(v interface{}) ([]byte, error) {
+	if o != nil && o.Canonical {
+		b, err := json.Marshal(v)
+		if err != nil {
+			return nil, err
+		}
+		return Canonicalize(b)
+	}
+	if o == nil || (!o.DisableHTMLEscape && o.Indent == "") {
+		return json.Marshal(v)
+	}
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(!o.DisableHTMLEscape)
+	enc.SetIndent("", o.Indent)
+	if err := enc.Encode(v); err != nil {
+		return nil, err
+	}
+	return bytes.TrimSuffix(buf.Bytes(), []byte("\n")), nil
+}
+
+// Marshal returns the JSON encoding of v without options, honoring
+// rpc.Marshaler.
+func Marshal(v interface{}) ([]byte, error) {
+	return (*Options)(nil).Marshal(v)
+}
+
+// Unmarshal decodes the JSON data into v without options, honoring
+// rpc.Unmarshaler.
+func Unmarshal(data []byte, v interface{}) error {
+	return (*Options)(nil).Unmarshal(data, v)
+}
+
+// Converts returns true if v isn't encoded exactly as by encoding/json,
+// because of the options or of rpc.Marshaler values. Values in interface
+// fields are only converted when the options are active.
+func (o *Options) Converts(v interface{}) bool {
+	return o.active() || hasMarshalers(reflect.TypeOf(v))
+}
+
+// Marshal returns the JSON encoding of v with the options.
+func (o *Options) Marshal(v interface{}) (b []byte, err error) {
+	if !o.Converts(v) {
+		return o.Format(v)
+	}
+	if o == nil {
+		o = &Options{}
+	}
+	defer func() {
+		if r := recover(); r != nil {
+			e, ok := r.(marshalError)
+			if !ok {
+				panic(r)
+			}
+			err = e.err
+		}
+	}()
+	return o.Format(o.convert(reflect.ValueOf(v)))
+}
+
+// marshalError wraps the errors of rpc.Marshalers, to abort the
+// conversion.
+type marshalError struct {
+	err error
+}
+
+// Unmarshal decodes the JSON data into v with the options.
+func (o *Options) Unmarshal(data []byte, v interface{}) error {
+	if !o.active() && !hasMarshalers(reflect.TypeOf(v)) {
+		return json.Unmarshal(data, v)
+	}
+	if o == nil {
+		o = &Options{}
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var tree interface{}
+	if err := dec.Decode(&tree); err != nil {
+		return err
+	}
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() {
+		return &json.InvalidUnmarshalError{Type: reflect.TypeOf(v)}
+	}
+	return o.decode(rv.Elem(), tree)
+}
+
+var (
+	typeOfRPCMarshaler    = reflect.TypeOf((*rpc.Marshaler)(nil)).Elem()
+	typeOfRPCUnmarshaler  = reflect.TypeOf((*rpc.Unmarshaler)(nil)).Elem()
+	typeOfDecimal         = reflect.TypeOf((*Decimal)(nil)).Elem()
+	typeOfBigInt          = reflect.TypeOf(big.Int{})
+	typeOfBigRat          = reflect.TypeOf(big.Rat{})
+	typeOfBigFloat        = reflect.TypeOf(big.Float{})
+	typeOfTime            = reflect.TypeOf(time.Time{})
+	typeOfMarshaler       = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+	typeOfTextMarshaler   = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+	typeOfUnmarshaler     = reflect.TypeOf((*json.Unmarshaler)(nil)).Elem()
+	typeOfTextUnmarshaler = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+)
+
+// marshals returns true if values of type t encode themselves.
+func marshals(t reflect.Type) bool {
+	return t.Implements(typeOfMarshaler) || t.Implements(typeOfTextMarshaler) ||
+		(t.Kind() != reflect.Ptr && (reflect.PtrTo(t).Implements(typeOfMarshaler) ||
+			reflect.PtrTo(t).Implements(typeOfTextMarshaler)))
+}
+
+// unmarshals returns true if values of type t decode themselves.
+func unmarshals(t reflect.Type) bool {
+	if t.Kind() != reflect.Ptr {
+		t = reflect.PtrTo(t)
+	}
+	return t.Implements(typeOfUnmarshaler) || t.Implements(typeOfTextUnmarshaler)
+}
+
+// convert returns a value encoded by encoding/json as v is encoded with the
+// options.
+func (o *Options) convert(v reflect.Value) interface{} {
+	if !v.IsValid() {
+		return nil
+	}
+	t := v.Type()
+	if m, ok := rpcMarshaler(v); ok {
+		value, err := m.MarshalRPC()
+		if err != nil {
+			panic(marshalError{err})
+		}
+		return o.convert(reflect.ValueOf(value))
+	}
+	if t.Kind() == reflect.Ptr && o.special(t.Elem()) {
+		if v.IsNil() {
+			return nil
+		}
+		v, t = v.Elem(), t.Elem()
+	}
+	if o.special(t) {
+		if t == typeOfTime {
+			return o.timeValue(v.Interface().(time.Time))
+		}
+		if !v.CanAddr() {
+			p := reflect.New(t)
+			p.Elem().Set(v)
+			v = p.Elem()
+		}
+		return o.bigValue(v.Addr().Interface())
+	}
+	if marshals(t) {
+		if v.CanAddr() && !t.Implements(typeOfMarshaler) && !t.Implements(typeOfTextMarshaler) {
+			return v.Addr().Interface()
+		}
+		return v.Interface()
+	}
+	switch t.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		if v.IsNil() {
+			return nil
+		}
+		return o.convert(v.Elem())
+	case reflect.Int64:
+		if o.Int64AsString {
+			return strconv.FormatInt(v.Int(), 10)
+		}
+	case reflect.Uint64:
+		if o.Int64AsString {
+			return strconv.FormatUint(v.Uint(), 10)
+		}
+	case reflect.Struct:
+		return o.convertStruct(v)
+	case reflect.Slice:
+		if v.IsNil() {
+			return nil
+		}
+		if t.Elem().Kind() == reflect.Uint8 {
+			return v.Interface()
+		}
+		fallthrough
+	case reflect.Array:
+		a := make([]interface{}, v.Len())
+		for i := range a {
+			a[i] = o.convert(v.Index(i))
+		}
+		return a
+	case reflect.Map:
+		if v.IsNil() {
+			return nil
+		}
+		m := make(map[string]interface{}, v.Len())
+		for _, k := range v.MapKeys() {
+			key, err := mapKey(k)
+			if err != nil {
+				// Let encoding/json report the unsupported key.
+				return v.Interface()
+			}
+			m[key] = o.convert(v.MapIndex(k))
+		}
+		return m
+	}
+	return v.Interface()
+}
+
+// mapKey returns the JSON key of a map key, as encoding/json does.
+func mapKey(k reflect.Value) (string, error) {
+	if k.Kind() == reflect.String {
+		return k.String(), nil
+	}
+	if tm, ok := k.Interface().(encoding.TextMarshaler); ok {
+		b, err := tm.MarshalText()
+		return string(b), err
+	}
+	switch k.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(k.Int(), 10), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return strconv.FormatUint(k.Uint(), 10), nil
+	}
+	return "", &json.UnsupportedTypeError{Type: k.Type()}
+}
+
+// convertStruct converts a struct to an object keeping the order of its
+// fields.
+func (o *Options) convertStruct(v reflect.Value) interface{} {
+	obj := make(object, 0, v.NumField())
+	for _, f := range fields(v.Type()) {
+		fv, ok := fieldByIndex(v, f.index)
+		if !ok || (f.omitEmpty && isEmpty(fv)) || (o.OmitNull && isNull(fv)) {
+			continue
+		}
+		var value interface{}
+		if f.quoted {
+			value = fv.Interface()
+			if b, err := json.Marshal(value); err == nil {
+				value = string(b)
+			}
+		} else {
+			value = o.convert(fv)
+		}
+		obj = append(obj, member{f.name, value})
+	}
+	return obj
+}
+
+// fieldByIndex returns the field of v at index, or false if it is in a nil
+// embedded pointer.
+func fieldByIndex(v reflect.Value, index []int) (reflect.Value, bool) {
+	for i, x := range index {
+		if i > 0 && v.Kind() == reflect.Ptr {
+			if v.IsNil() {
+				return reflect.Value{}, false
+			}
+			v = v.Elem()
+		}
+		v = v.Field(x)
+	}
+	return v, true
+}
+
+func isEmpty(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Array, reflect.Map, reflect.Slice, reflect.String:
+		return v.Len() == 0
+	case reflect.Bool:
+		return !v.Bool()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return v.Int() == 0
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return v.Uint() == 0
+	case reflect.Float32, reflect.Float64:
+		return v.Float() == 0
+	case reflect.Interface, reflect.Ptr:
+		return v.IsNil()
+	}
+	return false
+}
+
+// isNull returns true if v is encoded as null.
+func isNull(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Map, reflect.Slice, reflect.Interface, reflect.Ptr:
+		return v.IsNil()
+	}
+	return false
+}
+
+// member is a member of an object.
+type member struct {
+	name  string
+	value interface{}
+}
+
+// object is a JSON object keeping the order of its members.
+type object []member
+
+func (o object) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for i, m := range o {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		name, _ := marshalUnescaped(m.name)
+		buf.Write(name)
+		buf.WriteByte(':')
+		value, err := marshalUnescaped(m.value)
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(value)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+// marshalUnescaped encodes v without escaping HTML characters: encoding/json
+// escapes the output of MarshalJSON unless DisableHTMLEscape is set.
+func marshalUnescaped(v interface{}) ([]byte, error) {
+	return (&Options{DisableHTMLEscape: true}).Format(v)
+}
+
+// field is a struct field encoded by encoding/json.
+type field struct {
+	name      string
+	index     []int
+	tagged    bool
+	omitEmpty bool
+	quoted    bool
+}
+
+// fields returns the encoded fields of a struct, including the fields of
+// embedded structs. Fields hidden by others of the same name are dropped
+// like encoding/json does: the shallowest field wins, a tagged one if
+// several are at the same depth, and ambiguous fields are all dropped.
+func fields(t reflect.Type) []field {
+	var all []field
+	walking := make(map[reflect.Type]bool)
+	var walk func(t reflect.Type, index []int)
+	walk = func(t reflect.Type, index []int) {
+		walking[t] = true
+		defer delete(walking, t)
+		for i := 0; i < t.NumField(); i++ {
+			sf := t.Field(i)
+			tag := sf.Tag.Get("json")
+			if tag == "-" {
+				continue
+			}
+			name, opts := tag, ""
+			if j := strings.Index(tag, ","); j >= 0 {
+				name, opts = tag[:j], tag[j:]
+			}
+			ft := sf.Type
+			if ft.Kind() == reflect.Ptr {
+				ft = ft.Elem()
+			}
+			idx := append(append([]int(nil), index...), i)
+			if sf.Anonymous && name == "" && ft.Kind() == reflect.Struct {
+				if !walking[ft] {
+					walk(ft, idx)
+				}
+				continue
+			}
+			if sf.PkgPath != "" {
+				continue
+			}
+			tagged := name != ""
+			if !tagged {
+				name = sf.Name
+			}
+			all = append(all, field{
+				name:      name,
+				index:     idx,
+				tagged:    tagged,
+				omitEmpty: strings.Contains(opts, ",omitempty"),
+				quoted:    strings.Contains(opts, ",string"),
+			})
+		}
+	}
+	walk(t, nil)
+
+	byName := make(map[string][]int)
+	for i, f := range all {
+		byName[f.name] = append(byName[f.name], i)
+	}
+	fs := make([]field, 0, len(byName))
+	for i, f := range all {
+		if dominantField(all, byName[f.name]) == i {
+			fs = append(fs, f)
+		}
+	}
+	return fs
+}
+
+// dominantField returns the index of the field hiding the others of the
+// same name at indexes, or -1 if none does.
+func dominantField(all []field, indexes []int) int {
+	depth := len(all[indexes[0]].index)
+	for _, i := range indexes[1:] {
+		if d := len(all[i].index); d < depth {
+			depth = d
+		}
+	}
+	dominant, count, tagged := -1, 0, 0
+	for _, i := range indexes {
+		if len(all[i].index) != depth {
+			continue
+		}
+		count++
+		if all[i].tagged {
+			tagged++
+			dominant = i
+		} else if tagged == 0 {
+			dominant = i
+		}
+	}
+	if tagged > 1 || (tagged == 0 && count > 1) {
+		return -1
+	}
+	return dominant
+}
+
+// special returns true for the types encoded according to the options
+// instead of their own marshalers.
+func (o *Options) special(t reflect.Type) bool {
+	return (o.BigNumbers != BigDefault && isBig(t)) ||
+		((o.Time != TimeDefault || o.TimeLocation != nil) && t == typeOfTime)
+}
+
+// isBig returns true for the big number types and the Decimal types.
+func isBig(t reflect.Type) bool {
+	return t == typeOfBigInt || t == typeOfBigRat || t == typeOfBigFloat ||
+		reflect.PtrTo(t).Implements(typeOfDecimal)
+}
+
+// bigValue returns the value encoding the big number or decimal p.
+func (o *Options) bigValue(p interface{}) interface{} {
+	var s string
+	exact := true
+	switch x := p.(type) {
+	case Decimal:
+		s = x.DecimalString()
+	case *big.Int:
+		s = x.String()
+	case *big.Rat:
+		s, exact = decimalString(x)
+	case *big.Float:
+		s = x.Text('g', -1)
+		exact = !x.IsInf()
+	}
+	if o.BigNumbers == BigAsNumber && exact {
+		return json.Number(s)
+	}
+	return s
+}
+
+// decimalString returns the exact decimal representation of r, or its
+// fraction representation and false if it has none.
+func decimalString(r *big.Rat) (string, bool) {
+	d := new(big.Int).Set(r.Denom())
+	var e2, e5 int
+	two, five, m := big.NewInt(2), big.NewInt(5), new(big.Int)
+	for d.QuoRem(d, two, m); m.Sign() == 0; d.QuoRem(d, two, m) {
+		e2++
+	}
+	d.Mul(d, two).Add(d, m)
+	for d.QuoRem(d, five, m); m.Sign() == 0; d.QuoRem(d, five, m) {
+		e5++
+	}
+	d.Mul(d, five).Add(d, m)
+	if d.Cmp(big.NewInt(1)) != 0 {
+		return r.RatString(), false
+	}
+	if e5 > e2 {
+		e2 = e5
+	}
+	return r.FloatString(e2), true
+}
+
+// decode decodes the JSON tree into v, which is addressable, as
+// encoding/json does with the options.
+func (o *Options) decode(v reflect.Value, tree interface{}) error {
+	t := v.Type()
+	if reflect.PtrTo(t).Implements(typeOfRPCUnmarshaler) {
+		return v.Addr().Interface().(rpc.Unmarshaler).UnmarshalRPC(rpcValue(tree))
+	}
+	if o.special(t) {
+		if t == typeOfTime {
+			return o.decodeTime(v, tree)
+		}
+		return o.decodeBig(v, tree)
+	}
+	if unmarshals(t) && !(t.Kind() == reflect.Ptr && o.special(t.Elem())) {
+		return decodeJSON(v, tree)
+	}
+	switch t.Kind() {
+	case reflect.Ptr:
+		if tree == nil {
+			v.Set(reflect.Zero(t))
+			return nil
+		}
+		if v.IsNil() {
+			v.Set(reflect.New(t.Elem()))
+		}
+		return o.decode(v.Elem(), tree)
+	case reflect.Int64, reflect.Uint64:
+		if s, ok := tree.(string); ok && o.Int64AsString {
+			tree = json.Number(s)
+		}
+	case reflect.Struct:
+		if m, ok := tree.(map[string]interface{}); ok {
+			return o.decodeStruct(v, m)
+		}
+	case reflect.Slice:
+		a, ok := tree.([]interface{})
+		if !ok || t.Elem().Kind() == reflect.Uint8 {
+			break
+		}
+		v.Set(reflect.MakeSlice(t, len(a), len(a)))
+		for i := range a {
+			if err := o.decode(v.Index(i), a[i]); err != nil {
+				return err
+			}
+		}
+		return nil
+	case reflect.Array:
+		a, ok := tree.([]interface{})
+		if !ok {
+			break
+		}
+		for i := 0; i < v.Len(); i++ {
+			if i >= len(a) {
+				v.Index(i).Set(reflect.Zero(t.Elem()))
+			} else if err := o.decode(v.Index(i), a[i]); err != nil {
+				return err
+			}
+		}
+		return nil
+	case reflect.Map:
+		m, ok := tree.(map[string]interface{})
+		if !ok || t.Key().Kind() != reflect.String {
+			break
+		}
+		if v.IsNil() {
+			v.Set(reflect.MakeMap(t))
+		}
+		for key, value := range m {
+			elem := reflect.New(t.Elem()).Elem()
+			if err := o.decode(elem, value); err != nil {
+				return err
+			}
+			v.SetMapIndex(reflect.ValueOf(key).Convert(t.Key()), elem)
+		}
+		return nil
+	}
+	return decodeJSON(v, tree)
+}
+
+// decodeStruct decodes the members of a JSON object into the fields of
+// the struct v.
+func (o *Options) decodeStruct(v reflect.Value, m map[string]interface{}) error {
+	fs := fields(v.Type())
+	for key, value := range m {
+		f := findField(fs, key)
+		if f == nil {
+			continue
+		}
+		fv := v
+		for i, x := range f.index {
+			if i > 0 && fv.Kind() == reflect.Ptr {
+				if fv.IsNil() {
+					fv.Set(reflect.New(fv.Type().Elem()))
+				}
+				fv = fv.Elem()
+			}
+			fv = fv.Field(x)
+		}
+		var err error
+		if s, ok := value.(string); ok && f.quoted {
+			err = json.Unmarshal([]byte(s), fv.Addr().Interface())
+		} else {
+			err = o.decode(fv, value)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// findField returns the field matching the key, preferring an exact match
+// as encoding/json does.
+func findField(fs []field, key string) *field {
+	var fold *field
+	for i := range fs {
+		if fs[i].name == key {
+			return &fs[i]
+		}
+		if fold == nil && strings.EqualFold(fs[i].name, key) {
+			fold = &fs[i]
+		}
+	}
+	return fold
+}
+
+// decodeBig decodes a big number or a decimal from a JSON string or number.
+func (o *Options) decodeBig(v reflect.Value, tree interface{}) error {
+	var s string
+	switch x := tree.(type) {
+	case nil:
+		return nil
+	case string:
+		s = x
+	case json.Number:
+		s = string(x)
+	default:
+		return &json.UnmarshalTypeError{Value: fmt.Sprintf("%T", tree), Type: v.Type()}
+	}
+	var ok bool
+	switch x := v.Addr().Interface().(type) {
+	case Decimal:
+		return x.SetDecimalString(s)
+	case *big.Int:
+		_, ok = x.SetString(s, 10)
+	case *big.Rat:
+		_, ok = x.SetString(s)
+	case *big.Float:
+		_, ok = x.SetString(s)
+	}
+	if !ok {
+		return fmt.Errorf("jsonopt: invalid %s: %q", v.Type(), s)
+	}
+	return nil
+}
+
+// timeValue returns the value encoding the time.
+func (o *Options) timeValue(t time.Time) interface{} {
+	if o.TimeLocation != nil {
+		t = t.In(o.TimeLocation)
+	}
+	switch o.Time {
+	case TimeRFC3339:
+		return t.Format(time.RFC3339)
+	case TimeUnix:
+		return t.Unix()
+	case TimeUnixMilli:
+		return t.Unix()*1e3 + int64(t.Nanosecond())/1e6
+	}
+	return t.Format(time.RFC3339Nano)
+}
+
+// decodeTime decodes a time from an RFC 3339 string or a number.
+func (o *Options) decodeTime(v reflect.Value, tree interface{}) error {
+	var t time.Time
+	switch x := tree.(type) {
+	case nil:
+		return nil
+	case string:
+		var err error
+		if t, err = time.Parse(time.RFC3339Nano, x); err != nil {
+			return err
+		}
+	case json.Number:
+		if n, err := x.Int64(); err == nil {
+			if o.Time == TimeUnixMilli {
+				t = time.Unix(n/1e3, n%1e3*1e6)
+			} else {
+				t = time.Unix(n, 0)
+			}
+		} else if f, err := x.Float64(); err == nil {
+			if o.Time == TimeUnixMilli {
+				f /= 1e3
+			}
+			sec := math.Floor(f)
+			t = time.Unix(int64(sec), int64((f-sec)*1e9))
+		} else {
+			return err
+		}
+	default:
+		return &json.UnmarshalTypeError{Value: fmt.Sprintf("%T", tree), Type: v.Type()}
+	}
+	if o.TimeLocation != nil {
+		t = t.In(o.TimeLocation)
+	}
+	v.Set(reflect.ValueOf(t))
+	return nil
+}
+
+// rpcMarshaler returns the rpc.Marshaler of v, if any. Nil pointers are
+// encoded as null instead.
+func rpcMarshaler(v reflect.Value) (rpc.Marshaler, bool) {
+	t := v.Type()
+	if t.Implements(typeOfRPCMarshaler) {
+		if t.Kind() == reflect.Ptr && v.IsNil() {
+			return nil, false
+		}
+		return v.Interface().(rpc.Marshaler), true
+	}
+	if t.Kind() != reflect.Ptr && reflect.PtrTo(t).Implements(typeOfRPCMarshaler) {
+		if !v.CanAddr() {
+			p := reflect.New(t)
+			p.Elem().Set(v)
+			v = p.Elem()
+		}
+		return v.Addr().Interface().(rpc.Marshaler), true
+	}
+	return nil, false
+}
+
+// rpcValue returns the decoded JSON tree with the values documented by
+// rpc.Unmarshaler.
+func rpcValue(tree interface{}) interface{} {
+	switch x := tree.(type) {
+	case json.Number:
+		if n, err := x.Int64(); err == nil {
+			return n
+		}
+		if n, err := strconv.ParseUint(string(x), 10, 64); err == nil {
+			return n
+		}
+		f, _ := x.Float64()
+		return f
+	case []interface{}:
+		for i := range x {
+			x[i] = rpcValue(x[i])
+		}
+	case map[string]interface{}:
+		for k, v := range x {
+			x[k] = rpcValue(v)
+		}
+	}
+	return tree
+}
+
+// Warmup fills the caches used to encode and decode values of type t, of
+// this package and of encoding/json, before they are first used.
+func Warmup(t reflect.Type) {
+	hasMarshalers(t)
+	if t.Kind() != reflect.Ptr {
+		return
+	}
+	defer func() {
+		// The marshalers of the type may not support zero values.
+		recover()
+	}()
+	json.Marshal(reflect.New(t.Elem()).Interface())
+}
+
+// hasMarshalers returns true if values of type t may contain values
+// implementing rpc.Marshaler or rpc.Unmarshaler.
+func hasMarshalers(t reflect.Type) bool {
+	if t == nil {
+		return false
+	}
+	marshalersCache.RLock()
+	has, ok := marshalersCache.types[t]
+	marshalersCache.RUnlock()
+	if ok {
+		return has
+	}
+	has = findMarshalers(t, make(map[reflect.Type]bool))
+	marshalersCache.Lock()
+	if marshalersCache.types == nil {
+		marshalersCache.types = make(map[reflect.Type]bool)
+	}
+	marshalersCache.types[t] = has
+	marshalersCache.Unlock()
+	return has
+}
+
+// marshalersCache caches the results of hasMarshalers.
+var marshalersCache struct {
+	sync.RWMutex
+	types map[reflect.Type]bool
+}
+
+func findMarshalers(t reflect.Type, visited map[reflect.Type]bool) bool {
+	if visited[t] {
+		return false
+	}
+	visited[t] = true
+	if t.Implements(typeOfRPCMarshaler) || t.Implements(typeOfRPCUnmarshaler) ||
+		(t.Kind() != reflect.Ptr && (reflect.PtrTo(t).Implements(typeOfRPCMarshaler) ||
+			reflect.PtrTo(t).Implements(typeOfRPCUnmarshaler))) {
+		return true
+	}
+	switch t.Kind() {
+	case reflect.Ptr, reflect.Slice, reflect.Array, reflect.Map:
+		return findMarshalers(t.Elem(), visited)
+	case reflect.Struct:
+		for i := 0; i < t.NumField(); i++ {
+			if findMarshalers(t.Field(i).Type, visited) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// decodeJSON decodes the JSON tree into v with encoding/json.
+func decodeJSON(v reflect.Value, tree interface{}) error {
+	b, err := json.Marshal(tree)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(b, v.Addr().Interface())
+}
@@ -0,0 +1,380 @@
+// Copyright 2009 The Go Authors. All rights reserved.
+// Copyright 2012 The Gorilla Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package jsonopt
+
+import (
+	"fmt"
+	"math/big"
+	"testing"
+	"time"
+)
+
+type Base struct {
+	ID uint64
+}
+
+type Account struct {
+	Base
+	Name    string           `json:"name"`
+	Balance int64            `json:"balance,omitempty"`
+	Count   int              `json:"count"`
+	Tags    map[string]int64 `json:"tags,omitempty"`
+	Parent  *Account         `json:"parent,omitempty"`
+	Timeout time.Duration    `json:"timeout,string"`
+	Created time.Time        `json:"-"`
+	secret  string
+}
+
+func TestInt64AsString(t *testing.T) {
+	o := &Options{Int64AsString: true}
+	a := Account{
+		Base:    Base{ID: 1 << 60},
+		Name:    "a",
+		Balance: -9007199254740993,
+		Count:   3,
+		Tags:    map[string]int64{"x": 1},
+		Parent:  &Account{Name: "p"},
+		secret:  "s",
+	}
+	b, err := o.Marshal(&a)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `{"ID":"1152921504606846976","name":"a","balance":"-9007199254740993","count":3,` +
+		`"tags":{"x":"1"},"parent":{"ID":"0","name":"p","count":0,"timeout":"0"},"timeout":"0"}`
+	if string(b) != expected {
+		t.Errorf("Wrong encoding:\n%s\n%s", b, expected)
+	}
+
+	var decoded Account
+	if err := o.Unmarshal(b, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if decoded.ID != a.ID || decoded.Balance != a.Balance || decoded.Tags["x"] != 1 || decoded.Parent.Name != "p" {
+		t.Errorf("Wrong decoding: %+v", decoded)
+	}
+	// Numbers are accepted too.
+	if err := o.Unmarshal([]byte(`{"id":5,"balance":7}`), &decoded); err != nil || decoded.ID != 5 || decoded.Balance != 7 {
+		t.Errorf("Wrong decoding of numbers: %+v %v", decoded, err)
+	}
+}
+
+type Inner struct {
+	Name string
+}
+
+type Left struct {
+	Dup int
+}
+
+type Right struct {
+	Dup int
+}
+
+type Tagged struct {
+	Dup int `json:"Dup"`
+}
+
+type Outer struct {
+	Inner
+	Left
+	Right
+	Other int64
+	Name  string
+}
+
+type TaggedOuter struct {
+	Left
+	Tagged
+}
+
+func TestHiddenFields(t *testing.T) {
+	o := &Options{OmitNull: true}
+	// The shallower Name hides the embedded one, and the Dup fields at the
+	// same depth are ambiguous, as with encoding/json.
+	b, err := o.Marshal(&Outer{Inner: Inner{"in"}, Left: Left{1}, Right: Right{2}, Other: 1, Name: "out"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := `{"Other":1,"Name":"out"}`; string(b) != expected {
+		t.Errorf("Wrong encoding:\n%s\n%s", b, expected)
+	}
+	var decoded Outer
+	if err := o.Unmarshal([]byte(`{"Name":"x","Dup":3}`), &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if decoded.Name != "x" || decoded.Inner.Name != "" || decoded.Left.Dup != 0 || decoded.Right.Dup != 0 {
+		t.Errorf("Wrong decoding: %+v", decoded)
+	}
+
+	// A tagged field wins over the others at the same depth.
+	b, err = o.Marshal(&TaggedOuter{Left{1}, Tagged{2}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := `{"Dup":2}`; string(b) != expected {
+		t.Errorf("Wrong encoding:\n%s\n%s", b, expected)
+	}
+}
+
+func TestZeroOptions(t *testing.T) {
+	var o *Options
+	b, err := o.Marshal(Base{ID: 1})
+	if err != nil || string(b) != `{"ID":1}` {
+		t.Errorf("Expected encoding/json encoding, got %s %v", b, err)
+	}
+	if err := o.Unmarshal([]byte(`{"ID":"1"}`), new(Base)); err == nil {
+		t.Error("Expected error decoding a string without options")
+	}
+}
+
+// cents is a decimal with two digits.
+type cents int64
+
+func (c *cents) DecimalString() string {
+	return fmt.Sprintf("%d.%02d", *c/100, *c%100)
+}
+
+func (c *cents) SetDecimalString(s string) error {
+	r, ok := new(big.Rat).SetString(s)
+	if !ok {
+		return fmt.Errorf("invalid amount %q", s)
+	}
+	r.Mul(r, big.NewRat(100, 1))
+	if !r.IsInt() {
+		return fmt.Errorf("invalid amount %q", s)
+	}
+	*c = cents(r.Num().Int64())
+	return nil
+}
+
+type Invoice struct {
+	Total  cents
+	Units  *big.Int
+	Rate   *big.Rat
+	Third  *big.Rat
+	Factor *big.Float
+}
+
+func TestBigNumbers(t *testing.T) {
+	units, _ := new(big.Int).SetString("123456789012345678901234567890", 10)
+	in := Invoice{
+		Total:  1230,
+		Units:  units,
+		Rate:   big.NewRat(1, 8),
+		Third:  big.NewRat(1, 3),
+		Factor: big.NewFloat(1.5),
+	}
+	for _, test := range []struct {
+		format   BigFormat
+		expected string
+	}{
+		{BigAsString, `{"Total":"12.30","Units":"123456789012345678901234567890","Rate":"0.125","Third":"1/3","Factor":"1.5"}`},
+		{BigAsNumber, `{"Total":12.30,"Units":123456789012345678901234567890,"Rate":0.125,"Third":"1/3","Factor":1.5}`},
+	} {
+		o := &Options{BigNumbers: test.format}
+		b, err := o.Marshal(in)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(b) != test.expected {
+			t.Errorf("Wrong encoding:\n%s\n%s", b, test.expected)
+		}
+		var out Invoice
+		if err := o.Unmarshal(b, &out); err != nil {
+			t.Fatal(err)
+		}
+		if out.Total != in.Total || out.Units.Cmp(in.Units) != 0 || out.Rate.Cmp(in.Rate) != 0 ||
+			out.Third.Cmp(in.Third) != 0 || out.Factor.Cmp(in.Factor) != 0 {
+			t.Errorf("Wrong decoding: %+v", out)
+		}
+	}
+}
+
+type Event struct {
+	At      time.Time
+	Expires *time.Time `json:"expires,omitempty"`
+}
+
+func TestTime(t *testing.T) {
+	paris := time.FixedZone("CET", 3600)
+	at := time.Date(2006, 1, 2, 16, 4, 5, 123456789, paris)
+	for _, test := range []struct {
+		opts     Options
+		expected string
+		decoded  time.Time
+	}{
+		{Options{Time: TimeRFC3339, TimeLocation: time.UTC}, `{"At":"2006-01-02T15:04:05Z","expires":"2006-01-02T15:04:05Z"}`, at.Truncate(time.Second)},
+		{Options{Time: TimeRFC3339Nano}, `{"At":"2006-01-02T16:04:05.123456789+01:00","expires":"2006-01-02T16:04:05.123456789+01:00"}`, at},
+		{Options{Time: TimeUnix}, `{"At":1136214245,"expires":1136214245}`, at.Truncate(time.Second)},
+		{Options{Time: TimeUnixMilli}, `{"At":1136214245123,"expires":1136214245123}`, at.Truncate(time.Millisecond)},
+	} {
+		b, err := test.opts.Marshal(Event{At: at, Expires: &at})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(b) != test.expected {
+			t.Errorf("Wrong encoding:\n%s\n%s", b, test.expected)
+		}
+		var e Event
+		if err := test.opts.Unmarshal(b, &e); err != nil {
+			t.Fatal(err)
+		}
+		if !e.At.Equal(test.decoded) || !e.Expires.Equal(test.decoded) {
+			t.Errorf("Wrong decoding: %v, expected %v", e.At, test.decoded)
+		}
+		if test.opts.TimeLocation != nil && e.At.Location() != test.opts.TimeLocation {
+			t.Errorf("Expected time in %v, got %v", test.opts.TimeLocation, e.At.Location())
+		}
+	}
+	// RFC 3339 strings are accepted with any format.
+	var e Event
+	o := &Options{Time: TimeUnix}
+	if err := o.Unmarshal([]byte(`{"At":"2006-01-02T15:04:05Z","expires":null}`), &e); err != nil || e.At.Unix() != 1136214245 || e.Expires != nil {
+		t.Errorf("Wrong decoding: %+v %v", e, err)
+	}
+}
+
+// temperature is encoded as a unit value by rpc.Marshaler.
+type temperature float64
+
+func (t temperature) MarshalRPC() (interface{}, error) {
+	if t < -273.15 {
+		return nil, fmt.Errorf("invalid temperature %v", float64(t))
+	}
+	return map[string]interface{}{"value": float64(t), "unit": "C"}, nil
+}
+
+func (t *temperature) UnmarshalRPC(v interface{}) error {
+	m, ok := v.(map[string]interface{})
+	if !ok {
+		return fmt.Errorf("invalid temperature %v", v)
+	}
+	switch x := m["value"].(type) {
+	case int64:
+		*t = temperature(x)
+	case float64:
+		*t = temperature(x)
+	default:
+		return fmt.Errorf("invalid temperature %v", v)
+	}
+	if m["unit"] == "F" {
+		*t = (*t - 32) * 5 / 9
+	}
+	return nil
+}
+
+type Reading struct {
+	Sensor string        `json:"sensor"`
+	Temp   temperature   `json:"temp"`
+	Max    *temperature  `json:"max,omitempty"`
+	Log    []temperature `json:"log"`
+}
+
+func TestRPCMarshaler(t *testing.T) {
+	max := temperature(30)
+	r := Reading{Sensor: "s", Temp: 21.5, Max: &max, Log: []temperature{20}}
+	if !(*Options)(nil).Converts(r) || (*Options)(nil).Converts(Event{}) {
+		t.Error("Wrong types converted")
+	}
+	b, err := Marshal(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `{"sensor":"s","temp":{"unit":"C","value":21.5},"max":{"unit":"C","value":30},"log":[{"unit":"C","value":20}]}`
+	if string(b) != expected {
+		t.Errorf("Wrong encoding:\n%s\n%s", b, expected)
+	}
+	var d Reading
+	if err := Unmarshal([]byte(`{"temp":{"value":212,"unit":"F"},"max":{"value":30},"log":[{"value":1.5}]}`), &d); err != nil {
+		t.Fatal(err)
+	}
+	if d.Temp != 100 || d.Max == nil || *d.Max != 30 || len(d.Log) != 1 || d.Log[0] != 1.5 {
+		t.Errorf("Wrong decoding: %+v", d)
+	}
+	if err := Unmarshal([]byte(`{"temp":"hot"}`), &d); err == nil {
+		t.Error("Expected an error decoding an invalid temperature")
+	}
+	if _, err := Marshal(Reading{Temp: -300}); err == nil {
+		t.Error("Expected an error encoding an invalid temperature")
+	}
+}
+
+func TestPrune(t *testing.T) {
+	fields := Fields([]byte(`{"id": 1, "_fields": ["id", "owner.email", "tags", "tags.name"]}`))
+	if len(fields) != 4 {
+		t.Fatalf("Wrong fields %v", fields)
+	}
+	data := `[{"id":1,"name":"a","owner":{"email":"e","phone":"p"},"tags":[{"name":"x","id":2}]},{"id":2,"size":12345678901234567890}]`
+	b, err := Prune([]byte(data), fields)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `[{"id":1,"owner":{"email":"e"},"tags":[{"id":2,"name":"x"}]},{"id":2}]`
+	if string(b) != expected {
+		t.Errorf("Expected %s, got %s", expected, b)
+	}
+	if Fields([]byte(`{"id": 1}`)) != nil {
+		t.Error("Expected no fields")
+	}
+}
+
+type Note struct {
+	Text   string         `json:"text"`
+	Parent *Note          `json:"parent"`
+	Tags   []string       `json:"tags"`
+	Extra  map[string]int `json:"extra,omitempty"`
+}
+
+func TestFormat(t *testing.T) {
+	n := Note{Text: "<b>&</b>"}
+	for _, test := range []struct {
+		opts     *Options
+		expected string
+	}{
+		{nil, `{"text":"\u003cb\u003e\u0026\u003c/b\u003e","parent":null,"tags":null}`},
+		{&Options{DisableHTMLEscape: true}, `{"text":"<b>&</b>","parent":null,"tags":null}`},
+		{&Options{OmitNull: true}, `{"text":"\u003cb\u003e\u0026\u003c/b\u003e"}`},
+		{&Options{OmitNull: true, DisableHTMLEscape: true}, `{"text":"<b>&</b>"}`},
+		{&Options{OmitNull: true, Indent: "  "}, "{\n  \"text\": \"\\u003cb\\u003e\\u0026\\u003c/b\\u003e\"\n}"},
+		{&Options{Indent: "  ", Canonical: true}, `{"parent":null,"tags":null,"text":"<b>&</b>"}`},
+	} {
+		b, err := test.opts.Marshal(n)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(b) != test.expected {
+			t.Errorf("Expected %s with %+v, got %s", test.expected, test.opts, b)
+		}
+	}
+	if (&Options{Indent: "  ", DisableHTMLEscape: true, Canonical: true}).Converts(n) {
+		t.Error("Expected the formatting options not to convert the values")
+	}
+}
+
+func TestCanonicalize(t *testing.T) {
+	for _, test := range []struct {
+		data     string
+		expected string
+	}{
+		{`{"b": 1, "a": [true, null, "x"]}`, `{"a":[true,null,"x"],"b":1}`},
+		{`{"\ufb33": 1, "\ud83d\ude00": 2, "z": 3}`, "{\"z\":3,\"\U0001F600\":2,\"\ufb33\":1}"},
+		{`[1.0, 1E2, -0, -0.0, 0.000001, 1e-7, 1e21, 123456789012345678901.5, 9007199254740993]`,
+			`[1,100,0,0,0.000001,1e-7,1e+21,123456789012345680000,9007199254740993]`},
+		{`"\u003c\u2028\n\u001f\"\\/"`, "\"<\u2028\\n\\u001f\\\"\\\\/\""},
+	} {
+		b, err := Canonicalize([]byte(test.data))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(b) != test.expected {
+			t.Errorf("Expected %s for %s, got %s", test.expected, test.data, b)
+		}
+	}
+	if _, err := Canonicalize([]byte(`{"a":`)); err == nil {
+		t.Error("Expected an error for invalid JSON")
+	}
+}
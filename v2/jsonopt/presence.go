@@ -0,0 +1,37 @@
+// Copyright 2009 The Go Authors. All rights reserved.
+// Copyright 2012 The Gorilla Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package jsonopt
+
+import (
+	"encoding/json"
+
+	"github.com/gorilla/rpc/v2"
+)
+
+// RecordPresence records the members of the JSON object data in v, if it
+// implements rpc.PresenceSetter. Members of nested objects are recorded
+// with dotted paths.
+func RecordPresence(data []byte, v interface{}) {
+	p, ok := v.(rpc.PresenceSetter)
+	if !ok {
+		return
+	}
+	var m map[string]json.RawMessage
+	if json.Unmarshal(data, &m) == nil {
+		recordPresence(p, "", m)
+	}
+}
+
+func recordPresence(p rpc.PresenceSetter, prefix string, m map[string]json.RawMessage) {
+	for key, value := range m {
+		path := prefix + key
+		p.SetPresent(path, string(value) == "null")
+		var nested map[string]json.RawMessage
+		if len(value) > 0 && value[0] == '{' && json.Unmarshal(value, &nested) == nil {
+			recordPresence(p, path+".", nested)
+		}
+	}
+}
@@ -0,0 +1,28 @@
+// Copyright 2009 The Go Authors. All rights reserved.
+// Copyright 2012 The Gorilla Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+/*
+Package gorilla/rpc/v2/kafka serves calls consumed from Kafka topics, for
+fully asynchronous service-to-service calls.
+
+Callers produce request records, with the codec message as value, a
+"correlation-id" header and a "reply-to" header naming the topic of the
+response. The transport consumes them with a consumer group, serves them
+with the server and produces the responses keyed by correlation id:
+
+	t := kafka.NewTransport(s)
+	t.ReplyTopic = "billing.replies"
+	t.Dedup = func(ctx context.Context, id string) (bool, error) {
+		return seen.CheckAndSet(ctx, id)
+	}
+	err := t.Serve(ctx, consumer, producer)
+
+The transport works with any Kafka client through the Consumer and Producer
+interfaces. Records are processed at least once; duplicates delivered
+after a failure are detected by Dedup, or replayed by a server with a
+DedupStore, which gets the correlation id as request id. Methods get the request record with
+RecordFromContext.
+*/
+package kafka
@@ -0,0 +1,191 @@
+// Copyright 2009 The Go Authors. All rights reserved.
+// Copyright 2012 The Gorilla Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package kafka
+
+import (
+	"context"
+	"net/http"
+	"sync"
+
+	"github.com/gorilla/rpc/v2"
+)
+
+// Record headers used by the transport.
+const (
+	// CorrelationHeader identifies a call. Responses carry the correlation
+	// id of their request, as header and as key.
+	CorrelationHeader = "correlation-id"
+	// ReplyToHeader is the topic where the response is produced.
+	ReplyToHeader = "reply-to"
+)
+
+// Record is a Kafka record.
+type Record struct {
+	Topic     string
+	Partition int32
+	Offset    int64
+	Key       []byte
+	Value     []byte
+	Headers   map[string]string
+}
+
+// Consumer is the subset of a Kafka consumer group client used by the
+// transport.
+type Consumer interface {
+	// Poll returns the next records, waiting until some are available.
+	Poll(ctx context.Context) ([]Record, error)
+	// Commit commits the offsets of the records.
+	Commit(ctx context.Context, records []Record) error
+}
+
+// Producer is the subset of a Kafka producer used by the transport.
+type Producer interface {
+	// Produce produces the record and waits for it to be acknowledged.
+	Produce(ctx context.Context, r Record) error
+}
+
+type recordKey struct{}
+
+// RecordFromContext returns the request record of the call, or nil if the
+// call wasn't received from Kafka.
+func RecordFromContext(ctx context.Context) *Record {
+	r, _ := ctx.Value(recordKey{}).(*Record)
+	return r
+}
+
+// Transport serves calls consumed from Kafka and produces their responses.
+//
+// The records of a partition are served in order, one after the other,
+// while the partitions are served concurrently.
+//
+// Records are processed at least once: their offsets are committed after
+// the responses of all the records of a poll are produced. A record is
+// processed again when the transport fails before committing it, so
+// methods must be idempotent, or duplicates must be detected: the
+// correlation id of each record is passed to the server as the request id,
+// so a server with a DedupStore replays their responses; Dedup skips them.
+type Transport struct {
+	// Server serves the calls.
+	Server *rpc.Server
+	// ContentType selects the server codec. If empty, "application/json"
+	// is used.
+	ContentType string
+	// ReplyTopic is the topic of the responses to records without a
+	// ReplyToHeader.
+	ReplyTopic string
+	// Dedup, if set, is called with the correlation id of each record
+	// before it's served. Records for which it returns true were already
+	// processed and are skipped.
+	Dedup func(ctx context.Context, id string) (bool, error)
+}
+
+// NewTransport returns a new Transport serving calls with s.
+func NewTransport(s *rpc.Server) *Transport {
+	return &Transport{Server: s}
+}
+
+// Serve consumes records and serves them until the context is done or
+// the consumer or the producer fail.
+func (t *Transport) Serve(ctx context.Context, c Consumer, p Producer) error {
+	for {
+		records, err := c.Poll(ctx)
+		if err != nil {
+			return err
+		}
+		partitions := partition(records)
+		errs := make([]error, len(partitions))
+		var wg sync.WaitGroup
+		for i, part := range partitions {
+			wg.Add(1)
+			go func(i int, part []*Record) {
+				defer wg.Done()
+				for _, rec := range part {
+					if errs[i] = t.serve(ctx, p, rec); errs[i] != nil {
+						return
+					}
+				}
+			}(i, part)
+		}
+		wg.Wait()
+		for _, err := range errs {
+			if err != nil {
+				return err
+			}
+		}
+		if err := c.Commit(ctx, records); err != nil {
+			return err
+		}
+	}
+}
+
+// partitionKey identifies the partition of a record.
+type partitionKey struct {
+	topic     string
+	partition int32
+}
+
+// partition groups the records by partition, keeping their order.
+func partition(records []Record) [][]*Record {
+	index := make(map[partitionKey]int)
+	var partitions [][]*Record
+	for i := range records {
+		key := partitionKey{records[i].Topic, records[i].Partition}
+		j, ok := index[key]
+		if !ok {
+			j = len(partitions)
+			index[key] = j
+			partitions = append(partitions, nil)
+		}
+		partitions[j] = append(partitions[j], &records[i])
+	}
+	return partitions
+}
+
+// serve serves a record and produces its response.
+func (t *Transport) serve(ctx context.Context, p Producer, rec *Record) error {
+	id := rec.Headers[CorrelationHeader]
+	if id == "" {
+		id = string(rec.Key)
+	}
+	if t.Dedup != nil && id != "" {
+		if seen, err := t.Dedup(ctx, id); err != nil {
+			return err
+		} else if seen {
+			return nil
+		}
+	}
+	r, err := http.NewRequest("POST", "kafka://"+rec.Topic, nil)
+	if err != nil {
+		return err
+	}
+	contentType := t.ContentType
+	if contentType == "" {
+		contentType = "application/json"
+	}
+	r.Header.Set("Content-Type", contentType)
+	ctx = context.WithValue(ctx, recordKey{}, rec)
+	if id != "" {
+		ctx = rpc.NewIdempotencyContext(ctx, id)
+	}
+	r = r.WithContext(ctx)
+	res := t.Server.ServeMessage(r, rec.Value)
+	if len(res) == 0 {
+		return nil
+	}
+	topic := rec.Headers[ReplyToHeader]
+	if topic == "" {
+		topic = t.ReplyTopic
+	}
+	if topic == "" {
+		return nil
+	}
+	return p.Produce(ctx, Record{
+		Topic:   topic,
+		Key:     []byte(id),
+		Value:   res,
+		Headers: map[string]string{CorrelationHeader: id},
+	})
+}
@@ -0,0 +1,130 @@
+// Copyright 2009 The Go Authors. All rights reserved.
+// Copyright 2012 The Gorilla Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package kafka
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/gorilla/rpc/v2"
+	"github.com/gorilla/rpc/v2/json2"
+)
+
+type EchoService struct{}
+
+func (s *EchoService) Echo(r *http.Request, args *string, reply *string) error {
+	*reply = *args + "@" + RecordFromContext(r.Context()).Topic
+	return nil
+}
+
+var errDone = errors.New("done")
+
+// queue is an in-memory topic consumed in a single poll.
+type queue struct {
+	mutex     sync.Mutex
+	records   []Record
+	committed []Record
+	produced  []Record
+}
+
+func (q *queue) Poll(ctx context.Context) ([]Record, error) {
+	records := q.records
+	q.records = nil
+	if records == nil {
+		return nil, errDone
+	}
+	return records, nil
+}
+
+func (q *queue) Commit(ctx context.Context, records []Record) error {
+	q.committed = append(q.committed, records...)
+	return nil
+}
+
+func (q *queue) Produce(ctx context.Context, r Record) error {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	q.produced = append(q.produced, r)
+	return nil
+}
+
+func TestTransport(t *testing.T) {
+	s := rpc.NewServer()
+	s.RegisterCodec(json2.NewCodec(), "application/json")
+	s.RegisterService(new(EchoService), "")
+
+	req, _ := json2.EncodeClientRequest("EchoService.Echo", "hi")
+	q := &queue{records: []Record{
+		{Topic: "requests", Value: req, Headers: map[string]string{CorrelationHeader: "1", ReplyToHeader: "replies"}},
+		{Topic: "requests", Value: req, Headers: map[string]string{CorrelationHeader: "2"}},
+	}}
+	tr := NewTransport(s)
+	tr.ReplyTopic = "default.replies"
+	tr.Dedup = func(ctx context.Context, id string) (bool, error) {
+		return id == "2", nil
+	}
+	if err := tr.Serve(context.Background(), q, q); err != errDone {
+		t.Fatalf("Expected errDone, got %v", err)
+	}
+	if len(q.committed) != 2 {
+		t.Errorf("Expected records to be committed, got %v", q.committed)
+	}
+	if len(q.produced) != 1 {
+		t.Fatalf("Expected one response, got %v", q.produced)
+	}
+	res := q.produced[0]
+	if res.Topic != "replies" || string(res.Key) != "1" || res.Headers[CorrelationHeader] != "1" {
+		t.Errorf("Wrong response record: %+v", res)
+	}
+	var reply string
+	if err := json2.DecodeClientResponse(bytes.NewReader(res.Value), &reply); err != nil {
+		t.Fatal(err)
+	}
+	if reply != "hi@requests" {
+		t.Errorf("Wrong reply: %q", reply)
+	}
+}
+
+// OrderService records the order of the calls of each partition.
+type OrderService struct {
+	mutex sync.Mutex
+	calls map[int32][]string
+}
+
+func (s *OrderService) Record(r *http.Request, args *string, reply *string) error {
+	rec := RecordFromContext(r.Context())
+	s.mutex.Lock()
+	s.calls[rec.Partition] = append(s.calls[rec.Partition], *args)
+	s.mutex.Unlock()
+	*reply = *args
+	return nil
+}
+
+func TestTransportPartitionOrder(t *testing.T) {
+	s := rpc.NewServer()
+	s.RegisterCodec(json2.NewCodec(), "application/json")
+	order := &OrderService{calls: make(map[int32][]string)}
+	s.RegisterService(order, "")
+
+	q := &queue{}
+	for i := 0; i < 20; i++ {
+		req, _ := json2.EncodeClientRequest("OrderService.Record", string(rune('a'+i)))
+		q.records = append(q.records, Record{Topic: "requests", Partition: int32(i % 2), Value: req})
+	}
+	if err := NewTransport(s).Serve(context.Background(), q, q); err != errDone {
+		t.Fatalf("Expected errDone, got %v", err)
+	}
+	for p, expected := range map[int32]string{0: "acegikmoqs", 1: "bdfhjlnprt"} {
+		if got := strings.Join(order.calls[p], ""); got != expected {
+			t.Errorf("Expected partition %d to be served in order %s, got %s", p, expected, got)
+		}
+	}
+}
@@ -0,0 +1,208 @@
+// Copyright 2009 The Go Authors. All rights reserved.
+// Copyright 2012 The Gorilla Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package rpc
+
+import (
+	"net/http"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// FallbackStatsMethod is the method of the stats of the calls served by
+// the FallbackFunc, whatever method they name, so that clients can't
+// create a histogram per method name.
+const FallbackStatsMethod = "(fallback)"
+
+// LatencyStats are the latencies of the calls of a method, from the
+// decoding of the call to the encoding of its response.
+type LatencyStats struct {
+	Method string        `json:"method"`
+	Count  uint64        `json:"count"`
+	Mean   time.Duration `json:"mean"`
+	P50    time.Duration `json:"p50"`
+	P95    time.Duration `json:"p95"`
+	P99    time.Duration `json:"p99"`
+	Max    time.Duration `json:"max"`
+}
+
+// LatencyStats returns the latencies of the calls of each method since the
+// server started or the stats were reset, sorted by method.
+//
+// The server always records the latencies in log-linear histograms with
+// atomic counters, cheap enough to leave on, so that small deployments get
+// percentiles without external metrics. Percentiles are accurate to
+// 12.5%, with a resolution of a microsecond.
+func (s *Server) LatencyStats() []LatencyStats {
+	s.latencies.mutex.RLock()
+	var stats []LatencyStats
+	for method, h := range s.latencies.histograms {
+		stats = append(stats, h.stats(method))
+	}
+	s.latencies.mutex.RUnlock()
+	sort.Sort(byMethod(stats))
+	return stats
+}
+
+// ResetLatencyStats forgets the recorded latencies.
+func (s *Server) ResetLatencyStats() {
+	s.latencies.mutex.Lock()
+	defer s.latencies.mutex.Unlock()
+	s.latencies.histograms = nil
+}
+
+// recordLatency records the latency of a call of the method started at
+// start.
+func (s *Server) recordLatency(method string, start time.Time) {
+	s.latencies.get(method).record(time.Since(start))
+}
+
+// latencies holds the histogram of each method.
+type latencies struct {
+	mutex      sync.RWMutex
+	histograms map[string]*histogram
+}
+
+// get returns the histogram of the method, created if needed.
+func (l *latencies) get(method string) *histogram {
+	l.mutex.RLock()
+	h := l.histograms[method]
+	l.mutex.RUnlock()
+	if h != nil {
+		return h
+	}
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	if h = l.histograms[method]; h == nil {
+		if l.histograms == nil {
+			l.histograms = make(map[string]*histogram)
+		}
+		h = new(histogram)
+		l.histograms[method] = h
+	}
+	return h
+}
+
+// byMethod sorts stats by method.
+type byMethod []LatencyStats
+
+func (s byMethod) Len() int           { return len(s) }
+func (s byMethod) Less(i, j int) bool { return s[i].Method < s[j].Method }
+func (s byMethod) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
+
+// StatsService exposes the stats of a server as RPC methods, e.g. on an
+// admin server:
+//
+//	admin.RegisterService(&rpc.StatsService{Server: s}, "Stats")
+type StatsService struct {
+	Server *Server
+}
+
+// Latency returns the latencies of the methods, see Server.LatencyStats.
+func (t *StatsService) Latency(r *http.Request, args *struct{}, reply *[]LatencyStats) error {
+	*reply = t.Server.LatencyStats()
+	return nil
+}
+
+// Reset resets the latencies of the methods.
+func (t *StatsService) Reset(r *http.Request) error {
+	t.Server.ResetLatencyStats()
+	return nil
+}
+
+// subBits is the number of bits of the linear sub-buckets of each power
+// of two in histograms.
+const subBits = 3
+
+// histogram is a log-linear histogram of latencies in microseconds.
+type histogram struct {
+	sum     uint64
+	max     uint64
+	buckets [(64 - subBits + 1) << subBits]uint64
+}
+
+// bucket returns the index of the bucket of the value v.
+func bucket(v uint64) int {
+	if v < 1<<subBits {
+		return int(v)
+	}
+	shift := bitLen(v) - 1 - subBits
+	return (shift+1)<<subBits + int(v>>uint(shift)) - 1<<subBits
+}
+
+// bitLen returns the number of bits needed to represent v, as
+// math/bits.Len64 added in Go 1.9.
+func bitLen(v uint64) int {
+	n := 0
+	for _, shift := range [...]uint{32, 16, 8} {
+		if v >= 1<<shift {
+			v >>= shift
+			n += int(shift)
+		}
+	}
+	for ; v != 0; v >>= 1 {
+		n++
+	}
+	return n
+}
+
+// bucketMax returns the largest value of the bucket i.
+func bucketMax(i int) uint64 {
+	if i < 1<<subBits {
+		return uint64(i)
+	}
+	shift := uint(i>>subBits - 1)
+	top := uint64(i&(1<<subBits-1) + 1<<subBits)
+	return (top+1)<<shift - 1
+}
+
+func (h *histogram) record(d time.Duration) {
+	v := uint64(d / time.Microsecond)
+	atomic.AddUint64(&h.buckets[bucket(v)], 1)
+	atomic.AddUint64(&h.sum, v)
+	for {
+		max := atomic.LoadUint64(&h.max)
+		if v <= max || atomic.CompareAndSwapUint64(&h.max, max, v) {
+			break
+		}
+	}
+}
+
+func (h *histogram) stats(method string) LatencyStats {
+	var buckets [len(h.buckets)]uint64
+	var count uint64
+	for i := range buckets {
+		buckets[i] = atomic.LoadUint64(&h.buckets[i])
+		count += buckets[i]
+	}
+	s := LatencyStats{Method: method, Count: count}
+	if count == 0 {
+		return s
+	}
+	max := atomic.LoadUint64(&h.max)
+	s.Mean = time.Duration(atomic.LoadUint64(&h.sum)/count) * time.Microsecond
+	s.Max = time.Duration(max) * time.Microsecond
+	quantile := func(q float64) time.Duration {
+		target := uint64(q*float64(count) + 0.5)
+		if target == 0 {
+			target = 1
+		}
+		var seen uint64
+		for i, n := range buckets {
+			if seen += n; seen >= target {
+				v := bucketMax(i)
+				if v > max {
+					v = max
+				}
+				return time.Duration(v) * time.Microsecond
+			}
+		}
+		return s.Max
+	}
+	s.P50, s.P95, s.P99 = quantile(0.50), quantile(0.95), quantile(0.99)
+	return s
+}
@@ -0,0 +1,71 @@
+// Copyright 2009 The Go Authors. All rights reserved.
+// Copyright 2012 The Gorilla Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package rpc
+
+import (
+	"testing"
+	"time"
+)
+
+func TestHistogram(t *testing.T) {
+	for _, v := range []uint64{0, 1, 255, 256, 1 << 32, 1<<64 - 1} {
+		n := 0
+		for x := v; x != 0; x >>= 1 {
+			n++
+		}
+		if l := bitLen(v); l != n {
+			t.Errorf("Expected %d bits for %d, got %d", n, v, l)
+		}
+	}
+	for _, v := range []uint64{0, 7, 8, 15, 16, 17, 1000, 123456789, 1<<64 - 1} {
+		i := bucket(v)
+		if v > bucketMax(i) || i > 0 && v <= bucketMax(i-1) {
+			t.Errorf("Value %d isn't in bucket %d", v, i)
+		}
+	}
+
+	h := new(histogram)
+	for i := 1; i <= 100; i++ {
+		h.record(time.Duration(i) * time.Millisecond)
+	}
+	s := h.stats("Service1.Multiply")
+	if s.Count != 100 || s.Max != 100*time.Millisecond {
+		t.Errorf("Wrong stats %+v", s)
+	}
+	for _, p := range []struct {
+		got, want time.Duration
+	}{{s.P50, 50 * time.Millisecond}, {s.P95, 95 * time.Millisecond}, {s.P99, 99 * time.Millisecond}} {
+		if p.got < p.want || p.got > p.want*9/8 {
+			t.Errorf("Expected about %v, got %v", p.want, p.got)
+		}
+	}
+}
+
+func TestLatencyStats(t *testing.T) {
+	s := NewServer()
+	s.RegisterCodec(MockCodec{2, 3}, "mock")
+	s.RegisterService(new(Service1), "")
+	for i := 0; i < 3; i++ {
+		serveMock(s, "Service1.Multiply", "")
+	}
+	serveMock(s, "Service1.Unknown", "")
+
+	stats := s.LatencyStats()
+	if len(stats) != 1 || stats[0].Method != "Service1.Multiply" || stats[0].Count != 3 {
+		t.Fatalf("Wrong stats %+v", stats)
+	}
+	service := &StatsService{Server: s}
+	if err := NewServer().RegisterService(service, "Stats"); err != nil {
+		t.Fatal(err)
+	}
+	var reply []LatencyStats
+	if err := service.Latency(nil, nil, &reply); err != nil || len(reply) != 1 {
+		t.Errorf("Wrong stats reply %+v", reply)
+	}
+	if err := service.Reset(nil); err != nil || len(s.LatencyStats()) != 0 {
+		t.Errorf("Expected no stats after reset, got %+v", s.LatencyStats())
+	}
+}
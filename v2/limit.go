@@ -0,0 +1,95 @@
+// Copyright 2009 The Go Authors. All rights reserved.
+// Copyright 2012 The Gorilla Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package rpc
+
+import (
+	"bytes"
+	"errors"
+	"net/http"
+)
+
+// ErrResponseTooLarge is returned instead of replies larger than the
+// maximum response size, with the LimitError policy.
+var ErrResponseTooLarge = errors.New("rpc: response too large")
+
+// TruncatedMarker is appended to replies truncated with the LimitTruncate
+// policy.
+const TruncatedMarker = "...[truncated]"
+
+// LimitPolicy is what the server does with replies larger than the
+// maximum response size.
+type LimitPolicy int
+
+const (
+	// LimitError replaces the reply with ErrResponseTooLarge.
+	LimitError LimitPolicy = iota
+	// LimitTruncate cuts the encoded reply at the maximum size and appends
+	// TruncatedMarker. The response is not valid for the codec anymore:
+	// use it only when a readable prefix is better than an error.
+	LimitTruncate
+)
+
+// SetMaxResponseBytes limits the size of encoded replies to n bytes,
+// protecting clients and proxies from unbounded replies. Replies are
+// buffered up to the limit while the codec writes them, so no partial
+// response is sent. There is no limit if n is 0.
+func (s *Server) SetMaxResponseBytes(n int, policy LimitPolicy) {
+	s.maxResponseBytes = n
+	s.limitPolicy = policy
+}
+
+// writeResponse writes the reply within the response size limit, and
+// returns ErrResponseTooLarge if the reply was replaced by an error, or the
+// error encoding or writing it, see encodeResponse.
+func (s *Server) writeResponse(w http.ResponseWriter, codecReq CodecRequest, reply interface{}) error {
+	if s.maxResponseBytes <= 0 {
+		return encodeResponse(w, codecReq, reply)
+	}
+	lw := &limitWriter{ResponseWriter: w, max: s.maxResponseBytes}
+	err := encodeResponse(lw, codecReq, reply)
+	if lw.overflow && s.limitPolicy == LimitError {
+		codecReq.WriteError(w, http.StatusInternalServerError, ErrResponseTooLarge)
+		return ErrResponseTooLarge
+	}
+	if lw.status != 0 {
+		w.WriteHeader(lw.status)
+	}
+	if lw.overflow {
+		lw.buf.WriteString(TruncatedMarker)
+	}
+	if lw.buf.Len() > 0 {
+		if _, errWrite := w.Write(lw.buf.Bytes()); err == nil {
+			err = errWrite
+		}
+	}
+	return err
+}
+
+// limitWriter buffers up to max bytes of a response, and discards the
+// rest.
+type limitWriter struct {
+	http.ResponseWriter
+	max      int
+	buf      bytes.Buffer
+	status   int
+	overflow bool
+}
+
+func (w *limitWriter) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+	}
+}
+
+func (w *limitWriter) Write(p []byte) (int, error) {
+	if n := w.max - w.buf.Len(); len(p) > n {
+		w.buf.Write(p[:n])
+		w.overflow = true
+	} else {
+		w.buf.Write(p)
+	}
+	return len(p), nil
+}
@@ -9,6 +9,7 @@ import (
 	"fmt"
 	"net/http"
 	"reflect"
+	"sort"
 	"strings"
 	"sync"
 	"unicode"
@@ -20,6 +21,8 @@ var (
 	typeOfError   = reflect.TypeOf((*error)(nil)).Elem()
 	typeOfRequest = reflect.TypeOf((*http.Request)(nil)).Elem()
 	typeOfHeader  = reflect.TypeOf((*http.Header)(nil)).Elem()
+	typeOfMeta    = reflect.TypeOf((*ResponseMeta)(nil))
+	typeOfEmpty   = reflect.TypeOf(struct{}{})
 )
 
 // ----------------------------------------------------------------------------
@@ -31,20 +34,188 @@ type MethodClass int
 const (
 	MethodClassBase       MethodClass = iota // base method
 	MethodClassWithHeader                    // method with header argument
+	MethodClassStream                        // method with a *Stream reply
+	MethodClassWithMeta                      // method with *ResponseMeta argument
+	MethodClassNoReply                       // method without reply argument
+	MethodClassNoArgs                        // method without args and reply arguments
 )
 
 type service struct {
 	name     string                    // name of service
 	rcvr     reflect.Value             // receiver of methods for the service
 	rcvrType reflect.Type              // type of the receiver
-	methods  map[string]*serviceMethod // registered methods
+	methods  map[string]*ServiceMethod // registered methods
+	filtered bool                      // methods restricted at registration
+	strict   bool                      // registered with strict registration
 }
 
-type serviceMethod struct {
+// ServiceMethod is a method resolved by a Router, bound to its receiver.
+type ServiceMethod struct {
 	class     MethodClass    // method class
+	rcvr      reflect.Value  // receiver of the method
 	method    reflect.Method // receiver method
 	argsType  reflect.Type   // type of the request argument
 	replyType reflect.Type   // type of the response argument
+	// handler called instead of method, if set
+	fn func(r *http.Request, args, reply interface{}) error
+	// generated dispatch function called instead of method, if set
+	dispatch DispatchFunc
+	sequence bool // reply is a channel or an iterator streamed to the client
+}
+
+// NewServiceMethod returns the named method of the receiver, bound to it.
+//
+// The method must follow the same rules as the methods extracted by
+// Server.RegisterService. This is useful to build custom routers.
+func NewServiceMethod(rcvr interface{}, name string) (*ServiceMethod, error) {
+	method, ok := reflect.TypeOf(rcvr).MethodByName(name)
+	if !ok {
+		return nil, fmt.Errorf("rpc: method %q not found", name)
+	}
+	m, reason := newServiceMethod(reflect.ValueOf(rcvr), method)
+	if m == nil {
+		return nil, fmt.Errorf("rpc: method %q is not of suitable type: %s", name, reason)
+	}
+	return m, nil
+}
+
+// ArgsType returns the type of the args of the method, without pointer.
+func (m *ServiceMethod) ArgsType() reflect.Type {
+	return m.argsType
+}
+
+// ReplyType returns the type of the reply of the method, without pointer.
+func (m *ServiceMethod) ReplyType() reflect.Type {
+	return m.replyType
+}
+
+// Invoke calls the method with args and reply, pointers to values of
+// ArgsType and ReplyType, bypassing the codecs, hooks and middlewares of
+// the server, e.g. to benchmark the method alone. The response writer is
+// only used by methods taking the headers of the response. Methods with a
+// stream reply can't be invoked.
+func (m *ServiceMethod) Invoke(w http.ResponseWriter, r *http.Request, args, reply interface{}) error {
+	if m.class == MethodClassStream {
+		return fmt.Errorf("rpc: method %q has a stream reply", m.method.Name)
+	}
+	return m.call(w, r, reflect.ValueOf(args), reflect.ValueOf(reply))
+}
+
+// call invokes the method with the given request, args and reply.
+func (m *ServiceMethod) call(w http.ResponseWriter, r *http.Request, args, reply reflect.Value) error {
+	if m.fn != nil {
+		return m.fn(r, args.Interface(), reply.Interface())
+	}
+	if m.dispatch != nil {
+		return m.dispatch(m.rcvr.Interface(), w, r, args.Interface(), reply.Interface())
+	}
+	in := []reflect.Value{m.rcvr, reflect.ValueOf(r), args, reply}
+	switch m.class {
+	case MethodClassNoArgs:
+		in = in[:2]
+	case MethodClassNoReply:
+		in = in[:3]
+	}
+	if m.class == MethodClassWithHeader {
+		in = append(in, reflect.ValueOf(w.Header()))
+	} else if m.class == MethodClassWithMeta {
+		meta := ResponseMetaFromContext(r.Context())
+		if meta == nil {
+			meta = new(ResponseMeta)
+		}
+		in = append(in, reflect.ValueOf(meta))
+	}
+	errValue := m.method.Func.Call(in)
+	if errInter := errValue[0].Interface(); errInter != nil {
+		return errInter.(error)
+	}
+	return nil
+}
+
+// IsEmptyArgs returns true if args points to a struct without fields, as
+// the args of methods taking no args. Codecs requiring params accept calls
+// without params for them.
+func IsEmptyArgs(args interface{}) bool {
+	t := reflect.TypeOf(args)
+	return t != nil && t.Kind() == reflect.Ptr && t.Elem().Kind() == reflect.Struct && t.Elem().NumField() == 0
+}
+
+// newServiceMethod returns the method bound to the receiver, or the reason
+// why the method doesn't have a suitable signature.
+func newServiceMethod(rcvr reflect.Value, method reflect.Method) (*ServiceMethod, string) {
+	mtype := method.Type
+	class := MethodClassBase
+	// Method must be exported.
+	if method.PkgPath != "" {
+		return nil, "method is not exported"
+	}
+	// Method must have from two to five ins.
+	// MethodClassBase: receiver, *http.Request, *args, *reply
+	// MethodClassWithHeader adds: http.Header
+	// MethodClassWithMeta adds: *ResponseMeta
+	// MethodClassNoReply: receiver, *http.Request, *args
+	// MethodClassNoArgs: receiver, *http.Request
+	switch {
+	case mtype.NumIn() == 5 && mtype.In(4) == typeOfMeta:
+		class = MethodClassWithMeta
+	case mtype.NumIn() == 5:
+		class = MethodClassWithHeader
+	case mtype.NumIn() == 3:
+		class = MethodClassNoReply
+	case mtype.NumIn() == 2:
+		class = MethodClassNoArgs
+	case mtype.NumIn() != 4:
+		return nil, fmt.Sprintf("method has %d arguments, expected (*http.Request, *args, *reply)", mtype.NumIn()-1)
+	}
+	// First argument must be a pointer and must be http.Request.
+	reqType := mtype.In(1)
+	if reqType.Kind() != reflect.Ptr || reqType.Elem() != typeOfRequest {
+		return nil, fmt.Sprintf("first argument is %v, expected *http.Request", reqType)
+	}
+	// Second argument must be a pointer and must be exported.
+	args, reply := reflect.PtrTo(typeOfEmpty), reflect.PtrTo(typeOfEmpty)
+	if class != MethodClassNoArgs {
+		args = mtype.In(2)
+	}
+	if args.Kind() != reflect.Ptr {
+		return nil, fmt.Sprintf("args type %v is not a pointer", args)
+	}
+	if !isExportedOrBuiltin(args) {
+		return nil, fmt.Sprintf("args type %v is not exported", args)
+	}
+	// Third argument must be a pointer and must be exported.
+	if class != MethodClassNoArgs && class != MethodClassNoReply {
+		reply = mtype.In(3)
+	}
+	if reply.Kind() != reflect.Ptr {
+		return nil, fmt.Sprintf("reply type %v is not a pointer", reply)
+	}
+	if !isExportedOrBuiltin(reply) {
+		return nil, fmt.Sprintf("reply type %v is not exported", reply)
+	}
+	if reply == typeOfStream && class == MethodClassBase {
+		class = MethodClassStream
+	}
+	if class == MethodClassWithHeader {
+		// Fourth argument must be http.Header interface.
+		hdrType := mtype.In(4)
+		if hdrType.Kind() != reflect.Map || hdrType != typeOfHeader {
+			return nil, fmt.Sprintf("fourth argument is %v, expected http.Header or *rpc.ResponseMeta", hdrType)
+		}
+	}
+	// Method needs one out: error.
+	if mtype.NumOut() != 1 || mtype.Out(0) != typeOfError {
+		return nil, "method doesn't return a single error"
+	}
+	return &ServiceMethod{
+		class:     class,
+		rcvr:      rcvr,
+		method:    method,
+		argsType:  args.Elem(),
+		replyType: reply.Elem(),
+		dispatch:  dispatchFunc(rcvr.Type(), method.Name),
+		sequence:  isSequence(reply.Elem()),
+	}, ""
 }
 
 // ----------------------------------------------------------------------------
@@ -58,13 +229,15 @@ type serviceMap struct {
 }
 
 // register adds a new service using reflection to extract its methods.
-func (m *serviceMap) register(rcvr interface{}, name string) error {
+func (m *serviceMap) register(rcvr interface{}, name string, opts registerOptions) error {
 	// Setup service.
 	s := &service{
 		name:     name,
 		rcvr:     reflect.ValueOf(rcvr),
 		rcvrType: reflect.TypeOf(rcvr),
-		methods:  make(map[string]*serviceMethod),
+		methods:  make(map[string]*ServiceMethod),
+		filtered: len(opts.filters) > 0,
+		strict:   opts.strict,
 	}
 	if name == "" {
 		s.name = reflect.Indirect(s.rcvr).Type().Name()
@@ -77,61 +250,24 @@ func (m *serviceMap) register(rcvr interface{}, name string) error {
 			s.rcvrType.String())
 	}
 	// Setup methods.
+	excluded, err := checkEmbedded(s.rcvrType, opts)
+	if err != nil {
+		return err
+	}
+	var skipped []SkippedMethod
 	for i := 0; i < s.rcvrType.NumMethod(); i++ {
 		method := s.rcvrType.Method(i)
-		mtype := method.Type
-		class := MethodClassBase
-		// Method must be exported.
-		if method.PkgPath != "" {
-			continue
-		}
-		// Method must have either four or five ins.
-		// MethodClassBase: receiver, *http.Request, *args, *reply
-		// MethodClassWithHeader adds: http.Header
-		if mtype.NumIn() == 5 {
-			class = MethodClassWithHeader
-		} else if mtype.NumIn() != 4 {
+		if excluded[method.Name] || !opts.allows(method.Name) {
 			continue
 		}
-		// First argument must be a pointer and must be http.Request.
-		reqType := mtype.In(1)
-		if reqType.Kind() != reflect.Ptr || reqType.Elem() != typeOfRequest {
-			continue
-		}
-		// Second argument must be a pointer and must be exported.
-		args := mtype.In(2)
-		if args.Kind() != reflect.Ptr || !isExportedOrBuiltin(args) {
-			continue
-		}
-		// Third argument must be a pointer and must be exported.
-		reply := mtype.In(3)
-		if reply.Kind() != reflect.Ptr || !isExportedOrBuiltin(reply) {
-			continue
-		}
-		if class == MethodClassWithHeader {
-			// Fourth argument must be http.Header interface.
-			hdrType := mtype.In(4)
-			if hdrType.Kind() != reflect.Map || hdrType != typeOfHeader {
-				continue
-			}
-		}
-		// Method needs one out: error.
-		if mtype.NumOut() != 1 {
-			continue
-		}
-		if returnType := mtype.Out(0); returnType != typeOfError {
-			continue
-		}
-		s.methods[method.Name] = &serviceMethod{
-			class:     class,
-			method:    method,
-			argsType:  args.Elem(),
-			replyType: reply.Elem(),
+		if m, reason := newServiceMethod(s.rcvr, method); m != nil {
+			s.methods[method.Name] = m
+		} else {
+			skipped = append(skipped, SkippedMethod{method.Name, reason})
 		}
 	}
-	if len(s.methods) == 0 {
-		return fmt.Errorf("rpc: %q has no exported methods of suitable type",
-			s.name)
+	if len(s.methods) == 0 || (opts.strict && len(skipped) > 0) {
+		return &RegistrationError{Service: s.name, Skipped: skipped, Strict: len(s.methods) > 0}
 	}
 	// Add to the map.
 	m.mutex.Lock()
@@ -145,28 +281,63 @@ func (m *serviceMap) register(rcvr interface{}, name string) error {
 	return nil
 }
 
-// get returns a registered service given a method name.
+// Resolve returns a registered service method given a method name.
 //
 // The method name uses a dotted notation as in "Service.Method".
-func (m *serviceMap) get(method string) (*service, *serviceMethod, error) {
+func (m *serviceMap) Resolve(method string) (*ServiceMethod, error) {
 	parts := strings.Split(method, ".")
 	if len(parts) != 2 {
 		err := fmt.Errorf("rpc: service/method request ill-formed: %q", method)
-		return nil, nil, err
+		return nil, err
 	}
 	m.mutex.Lock()
 	service := m.services[parts[0]]
 	m.mutex.Unlock()
 	if service == nil {
 		err := fmt.Errorf("rpc: can't find service %q", method)
-		return nil, nil, err
+		return nil, err
 	}
 	serviceMethod := service.methods[parts[1]]
 	if serviceMethod == nil {
 		err := fmt.Errorf("rpc: can't find method %q", method)
-		return nil, nil, err
+		return nil, err
+	}
+	return serviceMethod, nil
+}
+
+// methods returns the sorted names of the registered methods.
+func (m *serviceMap) methods() []string {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	var names []string
+	for name, service := range m.services {
+		for method := range service.methods {
+			names = append(names, name+"."+method)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// info returns the description of a registered service, or false.
+func (m *serviceMap) info(name string) (ServiceInfo, bool) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	service := m.services[name]
+	if service == nil {
+		return ServiceInfo{}, false
+	}
+	info := ServiceInfo{
+		Name:         service.name,
+		ReceiverType: service.rcvrType,
+		Filtered:     service.filtered,
+		Strict:       service.strict,
+	}
+	for method := range service.methods {
+		info.Methods = append(info.Methods, method)
 	}
-	return service, serviceMethod, nil
+	sort.Strings(info.Methods)
+	return info, true
 }
 
 // isExported returns true of a string is an exported (upper case) name.
@@ -0,0 +1,29 @@
+// Copyright 2009 The Go Authors. All rights reserved.
+// Copyright 2012 The Gorilla Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package rpc
+
+// Marshaler is implemented by types with a wire representation independent
+// of the codec, e.g. UUIDs, enums or units, so they're encoded the same by
+// all codecs. Codecs consult it before their native encoding.
+//
+// MarshalRPC returns a value made of nil, bool, string, []byte, numbers,
+// []interface{} and map[string]interface{}, which the codec encodes.
+// Codecs without a binary type encode []byte as they usually do, e.g.
+// JSON codecs as base64 strings.
+type Marshaler interface {
+	MarshalRPC() (interface{}, error)
+}
+
+// Unmarshaler is implemented by pointers to types decoding their wire
+// representation, see Marshaler. Codecs consult it before their native
+// decoding.
+//
+// UnmarshalRPC receives the decoded value: nil, bool, string, []byte,
+// numbers as int64, uint64 or float64, []interface{} or
+// map[string]interface{}.
+type Unmarshaler interface {
+	UnmarshalRPC(v interface{}) error
+}
@@ -0,0 +1,50 @@
+// Copyright 2009 The Go Authors. All rights reserved.
+// Copyright 2012 The Gorilla Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package rpc
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+)
+
+// ServeMessage serves a request message received by a transport other than
+// HTTP, e.g. a WebSocket message, and returns the encoded response. The
+// response is empty when the codec doesn't reply, as for notifications.
+//
+// The request r is used as a template for the request passed to the codec
+// and the methods: its context, headers and remote address are kept while
+// its body is replaced by the message. Its "Content-Type" header selects the
+// codec as usual.
+func (s *Server) ServeMessage(r *http.Request, body []byte) []byte {
+	req := new(http.Request)
+	*req = *r
+	req.Method = "POST"
+	req.Body = ioutil.NopCloser(bytes.NewReader(body))
+	req.ContentLength = int64(len(body))
+	w := &messageWriter{header: make(http.Header)}
+	s.ServeHTTP(w, req)
+	return w.body.Bytes()
+}
+
+// messageWriter is an http.ResponseWriter buffering the response.
+type messageWriter struct {
+	header http.Header
+	status int
+	body   bytes.Buffer
+}
+
+func (w *messageWriter) Header() http.Header {
+	return w.header
+}
+
+func (w *messageWriter) Write(p []byte) (int, error) {
+	return w.body.Write(p)
+}
+
+func (w *messageWriter) WriteHeader(status int) {
+	w.status = status
+}
@@ -0,0 +1,26 @@
+// Copyright 2009 The Go Authors. All rights reserved.
+// Copyright 2012 The Gorilla Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+/*
+Package gorilla/rpc/v2/metering records the usage of successful calls per
+caller, to drive usage-based billing from the RPC layer.
+
+	b := metering.NewBatcher(metering.ExporterFunc(
+		func(ctx context.Context, records []metering.Record) error {
+			return billing.Send(ctx, records)
+		}), 500, 10*time.Second)
+	defer b.Close()
+
+	s.RegisterMiddleware(metering.Middleware(b, func(r *http.Request) string {
+		return r.Header.Get("X-Api-Key")
+	}))
+
+Each call consumes one unit, unless its reply implements Metered:
+
+	func (r *SearchReply) MeteredUnits() int64 {
+		return int64(len(r.Results))
+	}
+*/
+package metering
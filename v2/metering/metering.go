@@ -0,0 +1,185 @@
+// Copyright 2009 The Go Authors. All rights reserved.
+// Copyright 2012 The Gorilla Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package metering
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gorilla/rpc/v2"
+)
+
+// Record is the usage of a successful call.
+type Record struct {
+	Caller string
+	Method string
+	Units  int64
+	Time   time.Time
+}
+
+// Metering receives the usage of successful calls.
+type Metering interface {
+	Record(caller, method string, units int64, t time.Time)
+}
+
+// Metered is implemented by replies to report the units consumed by a
+// call. Calls whose reply doesn't implement it consume one unit.
+type Metered interface {
+	MeteredUnits() int64
+}
+
+// Middleware returns a middleware recording successful calls in m, to be
+// registered with rpc.Server.RegisterMiddleware. identify returns the
+// identity of the caller, e.g. from an API key; calls for which it returns
+// an empty string are not recorded.
+func Middleware(m Metering, identify func(r *http.Request) string) rpc.Middleware {
+	return func(next rpc.CallFunc) rpc.CallFunc {
+		return func(r *http.Request, method string, args, reply interface{}) error {
+			err := next(r, method, args, reply)
+			if err != nil {
+				return err
+			}
+			caller := identify(r)
+			if caller == "" {
+				return nil
+			}
+			units := int64(1)
+			if metered, ok := reply.(Metered); ok {
+				units = metered.MeteredUnits()
+			}
+			m.Record(caller, method, units, time.Now())
+			return nil
+		}
+	}
+}
+
+// ----------------------------------------------------------------------------
+// Batcher
+// ----------------------------------------------------------------------------
+
+// Exporter sends batches of records to a billing system.
+type Exporter interface {
+	Export(ctx context.Context, records []Record) error
+}
+
+// ExporterFunc is an adapter to use a function as an Exporter.
+type ExporterFunc func(ctx context.Context, records []Record) error
+
+// Export calls f(ctx, records).
+func (f ExporterFunc) Export(ctx context.Context, records []Record) error {
+	return f(ctx, records)
+}
+
+// Batcher is a Metering exporting records in batches, in the background,
+// when a batch is full or at each interval.
+type Batcher struct {
+	// OnError is called with export errors. The records of a failed
+	// export are dropped.
+	OnError func(err error)
+
+	exporter Exporter
+	size     int
+	records  chan Record
+	flush    chan chan struct{}
+	done     chan struct{}
+	once     sync.Once
+	dropped  uint64
+}
+
+// NewBatcher returns a started Batcher exporting batches of up to size
+// records, at least at each interval. Up to 4*size records are buffered;
+// records over that limit are dropped while the exporter is slow.
+func NewBatcher(e Exporter, size int, interval time.Duration) *Batcher {
+	b := &Batcher{
+		exporter: e,
+		size:     size,
+		records:  make(chan Record, 4*size),
+		flush:    make(chan chan struct{}),
+		done:     make(chan struct{}),
+	}
+	go b.run(interval)
+	return b
+}
+
+// Record queues a record for export.
+func (b *Batcher) Record(caller, method string, units int64, t time.Time) {
+	select {
+	case b.records <- Record{Caller: caller, Method: method, Units: units, Time: t}:
+	default:
+		atomic.AddUint64(&b.dropped, 1)
+	}
+}
+
+// Dropped returns the number of records dropped because the buffer was
+// full.
+func (b *Batcher) Dropped() uint64 {
+	return atomic.LoadUint64(&b.dropped)
+}
+
+// Flush exports the queued records and waits for the export to finish.
+func (b *Batcher) Flush() {
+	done := make(chan struct{})
+	select {
+	case b.flush <- done:
+		<-done
+	case <-b.done:
+	}
+}
+
+// Close exports the queued records and stops the batcher.
+func (b *Batcher) Close() {
+	b.once.Do(func() {
+		b.Flush()
+		close(b.done)
+	})
+}
+
+func (b *Batcher) run(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	batch := make([]Record, 0, b.size)
+	fill := func() {
+		for len(batch) < b.size {
+			select {
+			case r := <-b.records:
+				batch = append(batch, r)
+			default:
+				return
+			}
+		}
+	}
+	export := func() {
+		fill()
+		if len(batch) == 0 {
+			return
+		}
+		if err := b.exporter.Export(context.Background(), batch); err != nil && b.OnError != nil {
+			b.OnError(err)
+		}
+		batch = make([]Record, 0, b.size)
+	}
+	for {
+		select {
+		case r := <-b.records:
+			batch = append(batch, r)
+			if len(batch) >= b.size {
+				export()
+			}
+		case <-ticker.C:
+			export()
+		case done := <-b.flush:
+			for len(b.records) > 0 || len(batch) > 0 {
+				export()
+			}
+			close(done)
+		case <-b.done:
+			return
+		}
+	}
+}
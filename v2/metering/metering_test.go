@@ -0,0 +1,63 @@
+// Copyright 2009 The Go Authors. All rights reserved.
+// Copyright 2012 The Gorilla Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package metering
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+)
+
+type searchReply struct {
+	Results []string
+}
+
+func (r *searchReply) MeteredUnits() int64 {
+	return int64(len(r.Results))
+}
+
+func TestMetering(t *testing.T) {
+	var mutex sync.Mutex
+	var batches [][]Record
+	b := NewBatcher(ExporterFunc(func(ctx context.Context, records []Record) error {
+		mutex.Lock()
+		defer mutex.Unlock()
+		batches = append(batches, records)
+		return nil
+	}), 2, time.Hour)
+	defer b.Close()
+
+	call := Middleware(b, func(r *http.Request) string {
+		return r.Header.Get("X-Api-Key")
+	})(func(r *http.Request, method string, args, reply interface{}) error {
+		if method == "Fail" {
+			return errors.New("failed")
+		}
+		return nil
+	})
+	r, _ := http.NewRequest("POST", "/", nil)
+	r.Header.Set("X-Api-Key", "acme")
+	anonymous, _ := http.NewRequest("POST", "/", nil)
+
+	call(r, "Ping", nil, nil)
+	call(r, "Search", nil, &searchReply{Results: []string{"a", "b", "c"}})
+	call(r, "Fail", nil, nil)
+	call(anonymous, "Ping", nil, nil)
+	call(r, "Ping", nil, nil)
+	b.Flush()
+
+	mutex.Lock()
+	defer mutex.Unlock()
+	if len(batches) != 2 || len(batches[0]) != 2 || len(batches[1]) != 1 {
+		t.Fatalf("Wrong batches: %v", batches)
+	}
+	if rec := batches[0][1]; rec.Caller != "acme" || rec.Method != "Search" || rec.Units != 3 {
+		t.Errorf("Wrong record: %+v", rec)
+	}
+}
@@ -0,0 +1,117 @@
+// Copyright 2009 The Go Authors. All rights reserved.
+// Copyright 2012 The Gorilla Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package rpc
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// MethodInfo documents a method.
+type MethodInfo struct {
+	// Description of the method.
+	Description string
+	// ExampleParams is an example of the method params.
+	ExampleParams interface{}
+	// ExampleResult is an example of the method result.
+	ExampleResult interface{}
+	// Deprecated marks the method as deprecated. Responses of deprecated
+	// methods have a "Warning" header, and a "Sunset" header if Sunset is
+	// set.
+	Deprecated bool
+	// DeprecationMessage is added to the "Warning" header, e.g. to tell
+	// which method should be used instead.
+	DeprecationMessage string
+	// Sunset is the time after which the method will be removed.
+	Sunset time.Time
+	// Paginated is set for methods whose args embed PageRequest. It is
+	// set automatically.
+	Paginated bool
+	// HTTP is the RESTful route of the method, e.g. "GET /users/{id}",
+	// served by the handlers of the rest package. Its variables and the
+	// query params are fields of the method args.
+	HTTP string
+}
+
+// methodInfos holds the documentation of methods and the number of calls
+// to deprecated ones.
+type methodInfos struct {
+	mutex           sync.RWMutex
+	infos           map[string]*MethodInfo
+	deprecatedCalls map[string]*uint64
+}
+
+// RegisterMethodInfo documents a registered method.
+//
+// The method uses a dotted notation as in "Service.Method".
+func (s *Server) RegisterMethodInfo(method string, info MethodInfo) error {
+	if !s.HasMethod(method) {
+		return fmt.Errorf("rpc: can't find method %q", method)
+	}
+	s.methodInfos.mutex.Lock()
+	defer s.methodInfos.mutex.Unlock()
+	if s.methodInfos.infos == nil {
+		s.methodInfos.infos = make(map[string]*MethodInfo)
+		s.methodInfos.deprecatedCalls = make(map[string]*uint64)
+	}
+	s.methodInfos.infos[method] = &info
+	if info.Deprecated && s.methodInfos.deprecatedCalls[method] == nil {
+		s.methodInfos.deprecatedCalls[method] = new(uint64)
+	}
+	return nil
+}
+
+// MethodInfo returns the documentation of the method, or false if it
+// wasn't documented.
+func (s *Server) MethodInfo(method string) (MethodInfo, bool) {
+	var info MethodInfo
+	s.methodInfos.mutex.RLock()
+	documented := s.methodInfos.infos[method]
+	s.methodInfos.mutex.RUnlock()
+	if documented != nil {
+		info = *documented
+	}
+	if m, err := s.router.Resolve(method); err == nil {
+		info.Paginated = isPaginated(m.argsType)
+	}
+	return info, documented != nil
+}
+
+// DeprecatedCalls returns the number of calls received by each deprecated
+// method, helping to drive clients off them.
+func (s *Server) DeprecatedCalls() map[string]uint64 {
+	s.methodInfos.mutex.RLock()
+	defer s.methodInfos.mutex.RUnlock()
+	calls := make(map[string]uint64, len(s.methodInfos.deprecatedCalls))
+	for method, n := range s.methodInfos.deprecatedCalls {
+		calls[method] = atomic.LoadUint64(n)
+	}
+	return calls
+}
+
+// deprecation counts a call to a deprecated method and sets the deprecation
+// headers of its response.
+func (m *methodInfos) deprecation(w http.ResponseWriter, method string) {
+	m.mutex.RLock()
+	info, n := m.infos[method], m.deprecatedCalls[method]
+	m.mutex.RUnlock()
+	if info == nil || !info.Deprecated {
+		return
+	}
+	atomic.AddUint64(n, 1)
+	text := "Deprecated method " + method
+	if info.DeprecationMessage != "" {
+		text += ": " + info.DeprecationMessage
+	}
+	w.Header().Set("Warning", "299 - "+strconv.Quote(text))
+	if !info.Sunset.IsZero() {
+		w.Header().Set("Sunset", info.Sunset.UTC().Format(http.TimeFormat))
+	}
+}
@@ -0,0 +1,54 @@
+// Copyright 2009 The Go Authors. All rights reserved.
+// Copyright 2012 The Gorilla Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package rpc
+
+import (
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestMethodInfo(t *testing.T) {
+	s := NewServer()
+	s.RegisterService(new(Service1), "")
+	s.RegisterCodec(MockCodec{2, 3}, "mock")
+
+	if err := s.RegisterMethodInfo("Service1.Divide", MethodInfo{}); err == nil {
+		t.Errorf("Expected error documenting unknown method")
+	}
+	sunset := time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)
+	err := s.RegisterMethodInfo("Service1.Multiply", MethodInfo{
+		Description:        "Multiplies A by B.",
+		ExampleParams:      &Service1Request{2, 3},
+		ExampleResult:      &Service1Response{6},
+		Deprecated:         true,
+		DeprecationMessage: "use Service1.MultiplyWithHeaders",
+		Sunset:             sunset,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info, ok := s.MethodInfo("Service1.Multiply"); !ok || info.Description != "Multiplies A by B." {
+		t.Errorf("Wrong method info: %v", info)
+	}
+
+	r, _ := http.NewRequest("POST", "Service1.Multiply", nil)
+	r.Header.Set("Content-Type", "mock")
+	w := NewMockResponseWriter()
+	s.ServeHTTP(w, r)
+	if w.Status != 200 {
+		t.Errorf("Status was %d, should be 200.", w.Status)
+	}
+	if got, want := w.header.Get("Warning"), `299 - "Deprecated method Service1.Multiply: use Service1.MultiplyWithHeaders"`; got != want {
+		t.Errorf("Warning header was %s, should be %s", got, want)
+	}
+	if got, want := w.header.Get("Sunset"), "Tue, 01 Jan 2030 00:00:00 GMT"; got != want {
+		t.Errorf("Sunset header was %s, should be %s", got, want)
+	}
+	if n := s.DeprecatedCalls()["Service1.Multiply"]; n != 1 {
+		t.Errorf("Expected 1 deprecated call, got %d", n)
+	}
+}
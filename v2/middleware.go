@@ -0,0 +1,38 @@
+// Copyright 2009 The Go Authors. All rights reserved.
+// Copyright 2012 The Gorilla Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package rpc
+
+import (
+	"errors"
+	"net/http"
+)
+
+// ErrDropReply is returned by a middleware so that no response at all is
+// written for the call. HTTP connections are aborted, and no message is
+// sent on message transports.
+var ErrDropReply = errors.New("rpc: reply dropped")
+
+// CallFunc calls a method with decoded args and a reply to fill.
+type CallFunc func(r *http.Request, method string, args, reply interface{}) error
+
+// Middleware wraps the call of methods, after the args are decoded and
+// validated and before the reply is encoded.
+type Middleware func(next CallFunc) CallFunc
+
+// RegisterMiddleware adds a middleware wrapping the call of all methods.
+// Middlewares are run in the order they are registered, the first one
+// being the outermost.
+func (s *Server) RegisterMiddleware(m Middleware) {
+	s.middlewares = append(s.middlewares, m)
+}
+
+// chain returns a CallFunc calling next through the middlewares.
+func (s *Server) chain(next CallFunc) CallFunc {
+	for i := len(s.middlewares) - 1; i >= 0; i-- {
+		next = s.middlewares[i](next)
+	}
+	return next
+}
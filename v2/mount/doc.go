@@ -0,0 +1,51 @@
+// Copyright 2009 The Go Authors. All rights reserved.
+// Copyright 2012 The Gorilla Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+/*
+Package gorilla/rpc/v2/mount embeds RPC servers in the routes of existing
+routers, e.g. gorilla/mux, chi or http.ServeMux, instead of a single opaque
+endpoint. A route may call the method named by its path, bind a codec, and
+expose its variables to the methods:
+
+	s := rpc.NewServer()
+	s.RegisterCodec(json2.NewCodec(), "application/json")
+	s.RegisterService(new(Users), "")
+
+	r := mux.NewRouter()
+	r.Handle("/tenants/{tenant}/rpc/{service}/{method}", mount.Handler(s, mount.Options{
+		Vars:       mux.Vars,
+		ServiceVar: "service",
+		MethodVar:  "method",
+	})).Methods("POST")
+
+The package doesn't depend on the routers: Vars returns the variables of
+the route matching a request. With chi, they're read from its route
+context:
+
+	Vars: func(r *http.Request) map[string]string {
+		vars := make(map[string]string)
+		params := chi.RouteContext(r).URLParams
+		for i, k := range params.Keys {
+			vars[k] = params.Values[i]
+		}
+		return vars
+	},
+
+and with the http.ServeMux patterns of Go 1.22 or later by PathValues,
+e.g. mount.PathValues("tenant", "service", "method").
+
+Methods read the variables with rpc.RouteVar, whatever the router:
+
+	func (u *Users) List(r *http.Request, args *ListArgs, reply *ListReply) error {
+		tenant := rpc.RouteVar(r, "tenant")
+		...
+	}
+
+Bind returns the binding as a router middleware, e.g. for mux.Router.Use
+or chi.Router.With, so that the middlewares after it see the route of the
+requests with rpc.RouteFrom, e.g. to log or authorize the called method
+before the server decodes the body.
+*/
+package mount
@@ -0,0 +1,76 @@
+// Copyright 2009 The Go Authors. All rights reserved.
+// Copyright 2012 The Gorilla Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package mount
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/gorilla/rpc/v2"
+)
+
+// Options configure the binding of a route to a server.
+type Options struct {
+	// Vars returns the variables of the route matching a request, e.g.
+	// mux.Vars. The route has no variables if nil.
+	Vars func(r *http.Request) map[string]string
+	// Method, if set, is the method called by the route, e.g. "Users.Get".
+	Method string
+	// ServiceVar and MethodVar are the variables of the route naming the
+	// called method, e.g. "service" and "method" for
+	// /rpc/{service}/{method}. If only MethodVar is set, it holds the
+	// dotted name of the method, e.g. "Users.Get".
+	ServiceVar string
+	MethodVar  string
+	// ContentType, if set, selects the codec registered with it for the
+	// requests of the route, whatever their "Content-Type" header.
+	ContentType string
+	// Bare has the bodies of the requests carry only the params of the
+	// method, as with the path routing of the server, see rpc.PathCodec.
+	Bare bool
+}
+
+// Bind returns a router middleware binding the requests to their route,
+// see rpc.Route. Requests whose route doesn't name a method get a 404 Not
+// Found error.
+func Bind(opts Options) func(http.Handler) http.Handler {
+	return func(h http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			route := &rpc.Route{Method: opts.Method, ContentType: opts.ContentType, Bare: opts.Bare}
+			if opts.Vars != nil {
+				route.Vars = opts.Vars(r)
+			}
+			if opts.MethodVar != "" {
+				method, ok := routeMethod(route.Vars, opts.ServiceVar, opts.MethodVar)
+				if !ok {
+					rpc.WriteError(w, http.StatusNotFound, "rpc: no method in the route "+r.URL.Path)
+					return
+				}
+				route.Method = method
+			}
+			h.ServeHTTP(w, r.WithContext(rpc.WithRoute(r.Context(), route)))
+		})
+	}
+}
+
+// Handler returns the handler serving the requests of a route with h, e.g.
+// an rpc.Server, see Bind.
+func Handler(h http.Handler, opts Options) http.Handler {
+	return Bind(opts)(h)
+}
+
+// routeMethod returns the method named by the variables of a route.
+func routeMethod(vars map[string]string, serviceVar, methodVar string) (string, bool) {
+	method := vars[methodVar]
+	if serviceVar == "" {
+		return method, strings.Count(method, ".") == 1 && method[0] != '.' && method[len(method)-1] != '.'
+	}
+	service := vars[serviceVar]
+	if service == "" || method == "" || strings.Contains(service, ".") || strings.Contains(method, ".") {
+		return "", false
+	}
+	return service + "." + method, true
+}
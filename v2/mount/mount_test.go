@@ -0,0 +1,133 @@
+// Copyright 2009 The Go Authors. All rights reserved.
+// Copyright 2012 The Gorilla Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package mount
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/rpc/v2"
+	"github.com/gorilla/rpc/v2/json2"
+)
+
+type EchoService struct{}
+
+func (EchoService) Tenant(r *http.Request, args *struct{}, reply *string) error {
+	*reply = rpc.RouteVar(r, "tenant")
+	return nil
+}
+
+type varsKey struct{}
+
+// vars returns the variables set by the router of newRouter, as mux.Vars.
+func vars(r *http.Request) map[string]string {
+	v, _ := r.Context().Value(varsKey{}).(map[string]string)
+	return v
+}
+
+func newRouter() http.Handler {
+	s := rpc.NewServer()
+	s.RegisterCodec(json2.NewCodec(), "application/json")
+	s.RegisterService(new(EchoService), "")
+
+	logMethod := func(h http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if route, ok := rpc.RouteFrom(r.Context()); ok {
+				w.Header().Set("X-Method", route.Method)
+			}
+			h.ServeHTTP(w, r)
+		})
+	}
+	rpcRoute := Bind(Options{
+		Vars:       vars,
+		ServiceVar: "service",
+		MethodVar:  "method",
+	})(logMethod(s))
+	callRoute := Handler(s, Options{
+		Vars:        vars,
+		MethodVar:   "method",
+		ContentType: "application/json",
+	})
+	// A router matching /tenants/{tenant}/rpc/{service}/{method} and
+	// /tenants/{tenant}/call/{method}, as gorilla/mux does.
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		parts := strings.Split(r.URL.Path, "/")
+		v := map[string]string{"tenant": parts[2]}
+		r = r.WithContext(context.WithValue(r.Context(), varsKey{}, v))
+		switch {
+		case len(parts) == 6 && parts[3] == "rpc":
+			v["service"], v["method"] = parts[4], parts[5]
+			rpcRoute.ServeHTTP(w, r)
+		case len(parts) == 5 && parts[3] == "call":
+			v["method"] = parts[4]
+			callRoute.ServeHTTP(w, r)
+		default:
+			http.NotFound(w, r)
+		}
+	})
+}
+
+func call(t *testing.T, h http.Handler, path, method, contentType string) (*httptest.ResponseRecorder, string, error) {
+	buf, _ := json2.EncodeClientRequest(method, struct{}{})
+	r := httptest.NewRequest("POST", path, bytes.NewBuffer(buf))
+	r.Header.Set("Content-Type", contentType)
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	var reply string
+	err := json2.DecodeClientResponse(bytes.NewReader(w.Body.Bytes()), &reply)
+	return w, reply, err
+}
+
+func TestHandler(t *testing.T) {
+	router := newRouter()
+
+	w, reply, err := call(t, router, "/tenants/acme/rpc/EchoService/Tenant", "", "application/json")
+	if err != nil || reply != "acme" {
+		t.Errorf("Expected the tenant of the route, got %q, %v", reply, err)
+	}
+	if w.Header().Get("X-Method") != "EchoService.Tenant" {
+		t.Errorf("Expected the middleware to see the method, got %q", w.Header().Get("X-Method"))
+	}
+	if _, reply, err = call(t, router, "/tenants/acme/rpc/EchoService/Tenant", "EchoService.Tenant", "application/json"); err != nil || reply != "acme" {
+		t.Errorf("Expected the same method to be accepted, got %q, %v", reply, err)
+	}
+	if _, _, err = call(t, router, "/tenants/acme/rpc/EchoService/Tenant", "EchoService.Other", "application/json"); err == nil || err.Error() != rpc.ErrRouteMethod.Error() {
+		t.Errorf("Expected %v, got %v", rpc.ErrRouteMethod, err)
+	}
+
+	// The codec is bound to the route.
+	if _, reply, err = call(t, router, "/tenants/acme/call/EchoService.Tenant", "", "text/plain"); err != nil || reply != "acme" {
+		t.Errorf("Expected the codec of the route, got %q, %v", reply, err)
+	}
+	if w, _, _ = call(t, router, "/tenants/acme/call/Tenant", "", "application/json"); w.Code != http.StatusNotFound {
+		t.Errorf("Expected 404 for a method without service, got %d", w.Code)
+	}
+}
+
+func TestRouteMethod(t *testing.T) {
+	for _, test := range []struct {
+		vars       map[string]string
+		serviceVar string
+		expected   string
+		ok         bool
+	}{
+		{map[string]string{"s": "Users", "m": "Get"}, "s", "Users.Get", true},
+		{map[string]string{"s": "Users", "m": "Get.Other"}, "s", "", false},
+		{map[string]string{"m": "Get"}, "s", "", false},
+		{map[string]string{"m": "Users.Get"}, "", "Users.Get", true},
+		{map[string]string{"m": "Users."}, "", "Users.", false},
+		{map[string]string{"m": "a.b.c"}, "", "a.b.c", false},
+	} {
+		method, ok := routeMethod(test.vars, test.serviceVar, "m")
+		if ok != test.ok || (ok && method != test.expected) {
+			t.Errorf("Expected %q, %v for %v, got %q, %v", test.expected, test.ok, test.vars, method, ok)
+		}
+	}
+}
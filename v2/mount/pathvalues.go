@@ -0,0 +1,26 @@
+// Copyright 2009 The Go Authors. All rights reserved.
+// Copyright 2012 The Gorilla Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build go1.22
+// +build go1.22
+
+package mount
+
+import "net/http"
+
+// PathValues returns the function reading the variables of the
+// http.ServeMux patterns of Go 1.22 or later with the names, e.g. PathValues("service", "method") for
+// "POST /rpc/{service}/{method}".
+func PathValues(names ...string) func(r *http.Request) map[string]string {
+	return func(r *http.Request) map[string]string {
+		vars := make(map[string]string, len(names))
+		for _, name := range names {
+			if v := r.PathValue(name); v != "" {
+				vars[name] = v
+			}
+		}
+		return vars
+	}
+}
@@ -0,0 +1,24 @@
+// Copyright 2009 The Go Authors. All rights reserved.
+// Copyright 2012 The Gorilla Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build go1.22
+// +build go1.22
+
+package mount
+
+import (
+	"net/http/httptest"
+	"testing"
+)
+
+func TestPathValues(t *testing.T) {
+	r := httptest.NewRequest("POST", "/rpc/Users/Get", nil)
+	r.SetPathValue("service", "Users")
+	r.SetPathValue("method", "Get")
+	vars := PathValues("tenant", "service", "method")(r)
+	if len(vars) != 2 || vars["service"] != "Users" || vars["method"] != "Get" {
+		t.Errorf("Wrong variables %v", vars)
+	}
+}
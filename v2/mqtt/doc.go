@@ -0,0 +1,38 @@
+// Copyright 2009 The Go Authors. All rights reserved.
+// Copyright 2012 The Gorilla Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+/*
+Package gorilla/rpc/v2/mqtt serves calls published on MQTT topics, so
+embedded devices can call the same services as web clients.
+
+The transport works with any MQTT client through the Client interface. With
+the Eclipse Paho client:
+
+	type pahoClient struct {
+		c paho.Client
+	}
+
+	func (p pahoClient) Subscribe(filter string, handler func(string, []byte)) error {
+		t := p.c.Subscribe(filter, 1, func(_ paho.Client, m paho.Message) {
+			go handler(m.Topic(), m.Payload())
+		})
+		t.Wait()
+		return t.Error()
+	}
+
+	func (p pahoClient) Publish(topic string, payload []byte) error {
+		t := p.c.Publish(topic, 1, false, payload)
+		t.Wait()
+		return t.Error()
+	}
+
+	t := mqtt.NewTransport(s)
+	err := t.Serve(pahoClient{c})
+
+Device "thermo-42" publishes its requests on "rpc/thermo-42/request" and
+subscribes to "rpc/thermo-42/reply". Methods get the device id with
+DeviceFromContext.
+*/
+package mqtt
@@ -0,0 +1,102 @@
+// Copyright 2009 The Go Authors. All rights reserved.
+// Copyright 2012 The Gorilla Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package mqtt
+
+import (
+	"context"
+	"net/http"
+	"strings"
+
+	"github.com/gorilla/rpc/v2"
+)
+
+// DefaultPrefix is the default prefix of the topics.
+const DefaultPrefix = "rpc"
+
+// Client is the subset of an MQTT client used by the transport. Handlers
+// may be called concurrently.
+type Client interface {
+	// Subscribe calls handler for each message published on topics
+	// matching the topic filter.
+	Subscribe(filter string, handler func(topic string, payload []byte)) error
+	// Publish publishes the payload on the topic.
+	Publish(topic string, payload []byte) error
+}
+
+type deviceKey struct{}
+
+// DeviceFromContext returns the id of the device making the call, or an
+// empty string if the call wasn't received over MQTT.
+func DeviceFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(deviceKey{}).(string)
+	return id
+}
+
+// Transport serves calls published by devices on MQTT topics.
+//
+// Devices publish requests on "<prefix>/<device>/request" and receive the
+// responses on "<prefix>/<device>/reply". Payloads are messages of the
+// codec registered in the server for ContentType, e.g. JSON-RPC 2.0
+// requests, or a compact binary codec for constrained devices. Brokers can
+// restrict each device to its own topics with ACLs, so the device id from
+// the topic can be trusted by the methods.
+type Transport struct {
+	// Server serves the calls.
+	Server *rpc.Server
+	// ContentType selects the server codec. If empty, "application/json"
+	// is used.
+	ContentType string
+	// Prefix is the prefix of the topics. If empty, DefaultPrefix is used.
+	Prefix string
+	// OnError is called with the errors publishing responses.
+	OnError func(err error)
+}
+
+// NewTransport returns a new Transport serving calls with s.
+func NewTransport(s *rpc.Server) *Transport {
+	return &Transport{Server: s}
+}
+
+func (t *Transport) prefix() string {
+	if t.Prefix == "" {
+		return DefaultPrefix
+	}
+	return t.Prefix
+}
+
+// Serve subscribes to the request topics of all devices.
+func (t *Transport) Serve(c Client) error {
+	return c.Subscribe(t.prefix()+"/+/request", func(topic string, payload []byte) {
+		t.serve(c, topic, payload)
+	})
+}
+
+// serve serves a request and publishes its response.
+func (t *Transport) serve(c Client, topic string, payload []byte) {
+	parts := strings.Split(topic, "/")
+	if len(parts) < 3 {
+		return
+	}
+	device := parts[len(parts)-2]
+	r, err := http.NewRequest("POST", "mqtt://"+topic, nil)
+	if err != nil {
+		return
+	}
+	contentType := t.ContentType
+	if contentType == "" {
+		contentType = "application/json"
+	}
+	r.Header.Set("Content-Type", contentType)
+	r.RemoteAddr = device
+	r = r.WithContext(context.WithValue(r.Context(), deviceKey{}, device))
+	res := t.Server.ServeMessage(r, payload)
+	if len(res) == 0 {
+		return
+	}
+	if err := c.Publish(t.prefix()+"/"+device+"/reply", res); err != nil && t.OnError != nil {
+		t.OnError(err)
+	}
+}
@@ -0,0 +1,74 @@
+// Copyright 2009 The Go Authors. All rights reserved.
+// Copyright 2012 The Gorilla Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package mqtt
+
+import (
+	"bytes"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/rpc/v2"
+	"github.com/gorilla/rpc/v2/json2"
+)
+
+type DeviceService struct{}
+
+func (s *DeviceService) Whoami(r *http.Request, args *struct{}, reply *string) error {
+	*reply = DeviceFromContext(r.Context())
+	return nil
+}
+
+// broker is an in-memory broker with a single subscription.
+type broker struct {
+	filter    string
+	handler   func(topic string, payload []byte)
+	published map[string][]byte
+}
+
+func (b *broker) Subscribe(filter string, handler func(topic string, payload []byte)) error {
+	b.filter, b.handler = filter, handler
+	return nil
+}
+
+func (b *broker) Publish(topic string, payload []byte) error {
+	b.published[topic] = payload
+	return nil
+}
+
+func TestTransport(t *testing.T) {
+	s := rpc.NewServer()
+	s.RegisterCodec(json2.NewCodec(), "application/json")
+	s.RegisterService(new(DeviceService), "")
+	b := &broker{published: make(map[string][]byte)}
+	if err := NewTransport(s).Serve(b); err != nil {
+		t.Fatal(err)
+	}
+	if b.filter != "rpc/+/request" {
+		t.Fatalf("Wrong topic filter: %q", b.filter)
+	}
+
+	req, _ := json2.EncodeClientRequest("DeviceService.Whoami", struct{}{})
+	b.handler("rpc/thermo-42/request", req)
+	res, ok := b.published["rpc/thermo-42/reply"]
+	if !ok {
+		t.Fatalf("Expected a reply, got %v", b.published)
+	}
+	var device string
+	if err := json2.DecodeClientResponse(bytes.NewReader(res), &device); err != nil {
+		t.Fatal(err)
+	}
+	if device != "thermo-42" {
+		t.Errorf("Wrong device: %q", device)
+	}
+
+	// Notifications have no reply.
+	delete(b.published, "rpc/thermo-42/reply")
+	b.handler("rpc/thermo-42/request", []byte(strings.Replace(string(req), `"id"`, `"x"`, 1)))
+	if len(b.published) != 0 {
+		t.Errorf("Expected no reply, got %v", b.published)
+	}
+}
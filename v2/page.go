@@ -0,0 +1,97 @@
+// Copyright 2009 The Go Authors. All rights reserved.
+// Copyright 2012 The Gorilla Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package rpc
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"reflect"
+)
+
+// ErrInvalidCursor is returned when decoding a malformed cursor.
+var ErrInvalidCursor = errors.New("rpc: invalid page cursor")
+
+// PageRequest is embedded in the args of methods returning lists, so all
+// of them share the same pagination contract:
+//
+//	type ListUsersArgs struct {
+//		rpc.PageRequest
+//		Team string `json:"team"`
+//	}
+//
+//	type ListUsersReply struct {
+//		rpc.PageResponse
+//		Users []*User `json:"users"`
+//	}
+//
+// Methods whose args embed PageRequest are reported as paginated by
+// MethodInfo.
+type PageRequest struct {
+	// Cursor is the NextCursor of the previous page, empty for the first
+	// page.
+	Cursor string `json:"cursor,omitempty"`
+	// Limit is the maximum number of items requested.
+	Limit int `json:"limit,omitempty"`
+}
+
+// PageSize returns the number of items to return: the requested limit, or
+// def if not set, capped to max.
+func (p PageRequest) PageSize(def, max int) int {
+	n := p.Limit
+	if n <= 0 {
+		n = def
+	}
+	if n > max {
+		n = max
+	}
+	return n
+}
+
+// PageResponse is embedded in the replies of methods returning lists.
+type PageResponse struct {
+	// NextCursor is passed in the next request to get the next page. It
+	// is empty on the last page.
+	NextCursor string `json:"nextCursor,omitempty"`
+}
+
+// HasMore returns true if there are more pages.
+func (p PageResponse) HasMore() bool {
+	return p.NextCursor != ""
+}
+
+// EncodeCursor returns an opaque cursor encoding v as JSON, e.g. the key
+// of the last item of a page.
+func EncodeCursor(v interface{}) (string, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(data), nil
+}
+
+// DecodeCursor decodes a cursor returned by EncodeCursor into v.
+func DecodeCursor(cursor string, v interface{}) error {
+	data, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return ErrInvalidCursor
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		return ErrInvalidCursor
+	}
+	return nil
+}
+
+var typeOfPageRequest = reflect.TypeOf(PageRequest{})
+
+// isPaginated returns true if the args type embeds PageRequest.
+func isPaginated(t reflect.Type) bool {
+	if t.Kind() != reflect.Struct {
+		return false
+	}
+	f, ok := t.FieldByName("PageRequest")
+	return ok && f.Anonymous && f.Type == typeOfPageRequest
+}
@@ -0,0 +1,83 @@
+// Copyright 2009 The Go Authors. All rights reserved.
+// Copyright 2012 The Gorilla Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package rpc
+
+import (
+	"net/http"
+	"testing"
+)
+
+type ListArgs struct {
+	PageRequest
+}
+
+type ListReply struct {
+	PageResponse
+	Items []int
+}
+
+type ListService struct {
+	items []int
+}
+
+func (s *ListService) List(r *http.Request, args *ListArgs, reply *ListReply) error {
+	offset := 0
+	if args.Cursor != "" {
+		if err := DecodeCursor(args.Cursor, &offset); err != nil {
+			return err
+		}
+	}
+	end := offset + args.PageSize(2, 10)
+	if end >= len(s.items) {
+		end = len(s.items)
+	} else {
+		reply.NextCursor, _ = EncodeCursor(end)
+	}
+	reply.Items = s.items[offset:end]
+	return nil
+}
+
+func TestPagination(t *testing.T) {
+	s := NewServer()
+	service := &ListService{items: []int{1, 2, 3, 4, 5}}
+	s.RegisterService(service, "")
+	s.RegisterService(new(Service1), "")
+
+	if info, _ := s.MethodInfo("ListService.List"); !info.Paginated {
+		t.Error("Expected ListService.List to be paginated")
+	}
+	if info, _ := s.MethodInfo("Service1.Multiply"); info.Paginated {
+		t.Error("Expected Service1.Multiply not to be paginated")
+	}
+
+	var items []int
+	args := &ListArgs{}
+	for pages := 0; ; pages++ {
+		if pages > 3 {
+			t.Fatal("Too many pages")
+		}
+		reply := &ListReply{}
+		if err := service.List(nil, args, reply); err != nil {
+			t.Fatal(err)
+		}
+		items = append(items, reply.Items...)
+		if !reply.HasMore() {
+			break
+		}
+		args.Cursor = reply.NextCursor
+	}
+	if len(items) != 5 || items[4] != 5 {
+		t.Errorf("Wrong items: %v", items)
+	}
+
+	args.Cursor = "!"
+	if err := service.List(nil, args, &ListReply{}); err != ErrInvalidCursor {
+		t.Errorf("Expected ErrInvalidCursor, got %v", err)
+	}
+	if n := (PageRequest{Limit: 100}).PageSize(10, 50); n != 50 {
+		t.Errorf("Expected page size to be capped, got %d", n)
+	}
+}
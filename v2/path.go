@@ -0,0 +1,71 @@
+// Copyright 2009 The Go Authors. All rights reserved.
+// Copyright 2012 The Gorilla Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package rpc
+
+import (
+	"net/http"
+	"strings"
+)
+
+// PathCodec is implemented by codecs decoding the calls of path routing,
+// see SetPathPrefix.
+type PathCodec interface {
+	// NewPathRequest returns the CodecRequest of a call of the method
+	// whose body is only its params. The response is only the result,
+	// or the error with the HTTP status of the call.
+	NewPathRequest(r *http.Request, method string) CodecRequest
+}
+
+// SetPathPrefix enables path routing: the method of the requests to the
+// prefix followed by "Service/Method" is taken from their URL path instead
+// of their body, e.g. "Service1.Multiply" for POST /rpc/Service1/Multiply
+// with the "/rpc/" prefix. CDN rules, WAF policies and access logs can
+// then tell the methods apart.
+//
+// With codecs implementing PathCodec, the bodies of these requests are
+// only the params, and their responses only the result or the error.
+// Other codecs decode the usual requests, whose method, if any, must be
+// the one of the path. Requests to the prefix itself are served as usual.
+// The prefix is empty by default, disabling path routing.
+func (s *Server) SetPathPrefix(prefix string) {
+	if prefix != "" && !strings.HasSuffix(prefix, "/") {
+		prefix += "/"
+	}
+	s.pathPrefix = prefix
+}
+
+// routePath returns the request with the Route of the method of its path,
+// or false if its path doesn't name a method.
+func (s *Server) routePath(r *http.Request) (*http.Request, bool) {
+	name := strings.TrimPrefix(r.URL.Path, s.pathPrefix)
+	if s.pathPrefix == "" || name == r.URL.Path || name == "" {
+		return r, true
+	}
+	route, ok := RouteFrom(r.Context())
+	if ok && route.Method != "" {
+		return r, true
+	}
+	i := strings.Index(name, "/")
+	if i <= 0 || i == len(name)-1 || strings.ContainsAny(name, ".") || strings.Contains(name[i+1:], "/") {
+		return r, false
+	}
+	pathRoute := &Route{Method: name[:i] + "." + name[i+1:], Bare: true}
+	if ok {
+		pathRoute.ContentType, pathRoute.Vars = route.ContentType, route.Vars
+	}
+	return r.WithContext(WithRoute(r.Context(), pathRoute)), true
+}
+
+// newCodecRequest returns the CodecRequest of a request, decoding only the
+// params of bare routes with PathCodecs.
+func newCodecRequest(codec Codec, r *http.Request) CodecRequest {
+	if route, ok := RouteFrom(r.Context()); ok && route.Bare && route.Method != "" {
+		if pc, ok := codec.(PathCodec); ok {
+			return pc.NewPathRequest(r, route.Method)
+		}
+	}
+	return codec.NewRequest(r)
+}
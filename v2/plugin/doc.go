@@ -0,0 +1,44 @@
+// Copyright 2009 The Go Authors. All rights reserved.
+// Copyright 2012 The Gorilla Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+/*
+Package gorilla/rpc/v2/plugin loads services implemented outside of the main
+program, so optional features can be shipped and updated independently.
+
+Go plugins, with Go 1.8 and later, export a RegisterServices function
+called with the server:
+
+	// In the plugin, built with -buildmode=plugin.
+	func RegisterServices(s *rpc.Server) error {
+		return s.RegisterService(new(ReportService), "")
+	}
+
+	// In the main program.
+	err := plugin.LoadGoPlugin(s, "reports.so")
+
+External processes serve their methods over their standard input and output,
+one JSON-RPC 2.0 message per line. The methods they advertise are registered
+into the server, and calls to them are forwarded to the process:
+
+	// In the process, written in any language. In Go:
+	func main() {
+		s := rpc.NewServer()
+		s.RegisterCodec(json2.NewCodec(), "application/json")
+		s.RegisterService(new(ReportService), "")
+		plugin.ServeStdio(s)
+	}
+
+	// In the main program.
+	p, err := plugin.Start(exec.Command("reports"))
+	if err != nil {
+		return err
+	}
+	defer p.Close()
+	plugin.Register(s, p)
+
+Processes advertise their methods by answering the "rpc.methods" call with
+an array of method names.
+*/
+package plugin
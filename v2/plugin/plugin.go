@@ -0,0 +1,40 @@
+// Copyright 2009 The Go Authors. All rights reserved.
+// Copyright 2012 The Gorilla Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build go1.8
+// +build go1.8
+
+package plugin
+
+import (
+	"fmt"
+	goplugin "plugin"
+
+	"github.com/gorilla/rpc/v2"
+)
+
+// RegisterSymbol is the name of the function looked up in Go plugins.
+const RegisterSymbol = "RegisterServices"
+
+// LoadGoPlugin opens the Go plugin at path and calls its RegisterServices
+// function to register its services into the server. The function must
+// have the signature:
+//
+//	func RegisterServices(s *rpc.Server) error
+func LoadGoPlugin(s *rpc.Server, path string) error {
+	p, err := goplugin.Open(path)
+	if err != nil {
+		return err
+	}
+	sym, err := p.Lookup(RegisterSymbol)
+	if err != nil {
+		return err
+	}
+	register, ok := sym.(func(*rpc.Server) error)
+	if !ok {
+		return fmt.Errorf("rpc: plugin %s: %s has type %T", path, RegisterSymbol, sym)
+	}
+	return register(s)
+}
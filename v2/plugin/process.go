@@ -0,0 +1,202 @@
+// Copyright 2009 The Go Authors. All rights reserved.
+// Copyright 2012 The Gorilla Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package plugin
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"os"
+	"os/exec"
+	"sync"
+
+	"github.com/gorilla/rpc/v2"
+	"github.com/gorilla/rpc/v2/json2"
+)
+
+// MethodsMethod is the method called on a process to get the methods it
+// serves, as a JSON array of names.
+const MethodsMethod = "rpc.methods"
+
+// Process is an external process serving methods over its standard input
+// and output: JSON-RPC 2.0 messages are exchanged one JSON value per line.
+// Processes written in Go can use ServeStdio.
+type Process struct {
+	cmd     *exec.Cmd
+	stdin   io.WriteCloser
+	mutex   sync.Mutex
+	calls   json2.PendingCalls
+	methods []string
+	done    chan struct{}
+}
+
+// Start starts the command and asks it for the methods it serves. The
+// standard error of the command is left untouched; set cmd.Stderr to see
+// its logs.
+func Start(cmd *exec.Cmd) (*Process, error) {
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+	p := &Process{cmd: cmd, stdin: stdin, done: make(chan struct{})}
+	go p.receive(stdout)
+	if err := p.calls.Call(context.Background(), p.write, MethodsMethod, nil, &p.methods); err != nil {
+		p.Close()
+		return nil, err
+	}
+	return p, nil
+}
+
+// Methods returns the methods served by the process.
+func (p *Process) Methods() []string {
+	return p.methods
+}
+
+// Call calls a method of the process with raw params and returns the raw
+// result.
+func (p *Process) Call(ctx context.Context, method string, params json.RawMessage) (json.RawMessage, error) {
+	var result json.RawMessage
+	var args interface{}
+	if len(params) > 0 {
+		args = params
+	}
+	if err := p.calls.Call(ctx, p.write, method, args, &result); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// Close closes the standard input of the process and waits for it to exit.
+func (p *Process) Close() error {
+	p.stdin.Close()
+	<-p.done
+	return p.cmd.Wait()
+}
+
+func (p *Process) write(data []byte) error {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	_, err := p.stdin.Write(append(data, '\n'))
+	return err
+}
+
+func (p *Process) receive(stdout io.Reader) {
+	defer close(p.done)
+	defer p.calls.Close()
+	dec := json.NewDecoder(stdout)
+	for {
+		var data json.RawMessage
+		if err := dec.Decode(&data); err != nil {
+			return
+		}
+		p.calls.Deliver(data)
+	}
+}
+
+// ----------------------------------------------------------------------------
+// Registration
+// ----------------------------------------------------------------------------
+
+// router resolves the methods of a process, and the other methods with the
+// router it wraps.
+type router struct {
+	methods map[string]*rpc.ServiceMethod
+	next    rpc.Router
+}
+
+func (r *router) Resolve(method string) (*rpc.ServiceMethod, error) {
+	if m := r.methods[method]; m != nil {
+		return m, nil
+	}
+	return r.next.Resolve(method)
+}
+
+// Register registers the methods advertised by the process into the
+// server. Calls to them are forwarded to the process.
+func Register(s *rpc.Server, p *Process) {
+	r := &router{methods: make(map[string]*rpc.ServiceMethod), next: s.Router()}
+	call := func(req *http.Request, method string, params json.RawMessage) (interface{}, error) {
+		return p.Call(req.Context(), method, params)
+	}
+	for _, method := range p.Methods() {
+		r.methods[method] = rpc.NewRawMethod(method, call)
+	}
+	s.RegisterRouter(r)
+}
+
+// ----------------------------------------------------------------------------
+// Serving
+// ----------------------------------------------------------------------------
+
+// ServeStdio serves the methods of the server over the standard input and
+// output, for a process started by Start. It returns when the standard
+// input is closed.
+func ServeStdio(s *rpc.Server) error {
+	return Serve(s, os.Stdin, os.Stdout)
+}
+
+// Serve serves the methods of the server over r and w, one JSON-RPC 2.0
+// message per line. The server must have a codec for "application/json".
+func Serve(s *rpc.Server, r io.Reader, w io.Writer) error {
+	tmpl, err := http.NewRequest("POST", "stdio:", nil)
+	if err != nil {
+		return err
+	}
+	tmpl.Header.Set("Content-Type", "application/json")
+	var mutex sync.Mutex
+	write := func(data []byte) {
+		mutex.Lock()
+		defer mutex.Unlock()
+		if data[len(data)-1] != '\n' {
+			data = append(data, '\n')
+		}
+		w.Write(data)
+	}
+	var wg sync.WaitGroup
+	defer wg.Wait()
+	dec := json.NewDecoder(r)
+	for {
+		var data json.RawMessage
+		if err := dec.Decode(&data); err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			return err
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if res := serveMessage(s, tmpl, data); len(res) > 0 {
+				write(res)
+			}
+		}()
+	}
+}
+
+// serveMessage answers MethodsMethod and serves the other messages with s.
+func serveMessage(s *rpc.Server, tmpl *http.Request, data []byte) []byte {
+	var req struct {
+		Method string           `json:"method"`
+		Id     *json.RawMessage `json:"id"`
+	}
+	if json.Unmarshal(data, &req) == nil && req.Method == MethodsMethod {
+		res, _ := json.Marshal(map[string]interface{}{
+			"jsonrpc": json2.Version,
+			"result":  s.Methods(),
+			"id":      req.Id,
+		})
+		return res
+	}
+	return s.ServeMessage(tmpl, data)
+}
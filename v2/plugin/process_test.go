@@ -0,0 +1,85 @@
+// Copyright 2009 The Go Authors. All rights reserved.
+// Copyright 2012 The Gorilla Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package plugin
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"testing"
+
+	"github.com/gorilla/rpc/v2"
+	"github.com/gorilla/rpc/v2/json2"
+)
+
+type Service1Request struct {
+	A int
+	B int
+}
+
+type Service1Response struct {
+	Result int
+}
+
+type Service1 struct {
+}
+
+func (t *Service1) Multiply(r *http.Request, req *Service1Request, res *Service1Response) error {
+	res.Result = req.A * req.B
+	return nil
+}
+
+func newServer() *rpc.Server {
+	s := rpc.NewServer()
+	s.RegisterCodec(json2.NewCodec(), "application/json")
+	return s
+}
+
+// TestMain runs the test binary as a plugin process when asked to.
+func TestMain(m *testing.M) {
+	if os.Getenv("RPC_PLUGIN_PROCESS") == "1" {
+		s := newServer()
+		s.RegisterService(new(Service1), "")
+		ServeStdio(s)
+		os.Exit(0)
+	}
+	os.Exit(m.Run())
+}
+
+func TestProcess(t *testing.T) {
+	cmd := exec.Command(os.Args[0])
+	cmd.Env = append(os.Environ(), "RPC_PLUGIN_PROCESS=1")
+	cmd.Stderr = os.Stderr
+	p, err := Start(cmd)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.Close()
+	if methods := p.Methods(); len(methods) != 1 || methods[0] != "Service1.Multiply" {
+		t.Fatalf("Wrong methods: %v", methods)
+	}
+
+	s := newServer()
+	Register(s, p)
+	if !s.HasMethod("Service1.Multiply") {
+		t.Fatal("Expected to be registered: Service1.Multiply")
+	}
+
+	buf, _ := json2.EncodeClientRequest("Service1.Multiply", &Service1Request{4, 2})
+	r, _ := http.NewRequest("POST", "http://localhost:8080/", bytes.NewBuffer(buf))
+	r.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	s.ServeHTTP(w, r)
+	var res Service1Response
+	if err := json2.DecodeClientResponse(w.Body, &res); err != nil {
+		t.Fatal(err)
+	}
+	if res.Result != 8 {
+		t.Errorf("Wrong response: %v.", res.Result)
+	}
+}
@@ -0,0 +1,133 @@
+// Copyright 2009 The Go Authors. All rights reserved.
+// Copyright 2012 The Gorilla Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package rpc
+
+import (
+	"fmt"
+	"reflect"
+	"sync"
+)
+
+// Pool supplies the args and reply values of a method, see RegisterPool.
+type Pool struct {
+	// NewArgs and NewReply return pointers to new args and reply values.
+	// If nil, the values are allocated with their zero value.
+	NewArgs, NewReply func() interface{}
+	// Reset resets args and reply before they are reused. If nil, they are
+	// set to their zero value; Reset may instead keep their buffers, e.g.
+	// truncate slices, but must clear what shouldn't leak between calls.
+	// The reply is nil for streaming methods.
+	Reset func(args, reply interface{})
+}
+
+// pools are the pools of the args and replies of methods.
+type pools struct {
+	mutex   sync.RWMutex
+	methods map[string]*methodPool
+}
+
+type methodPool struct {
+	Pool
+	args, reply sync.Pool
+}
+
+// RegisterPool reuses the args and reply values of a method across calls,
+// instead of allocating them for each call, to reduce the garbage of
+// methods with large args or replies called at high rates.
+//
+// The values are reused once the response is written: methods,
+// middlewares and hooks must not keep references to them, including to
+// their slices and maps, after the call. Replies of streaming methods
+// aren't pooled.
+//
+// The method uses a dotted notation as in "Service.Method".
+func (s *Server) RegisterPool(method string, p Pool) error {
+	m, err := s.router.Resolve(method)
+	if err != nil {
+		return fmt.Errorf("rpc: can't find method %q", method)
+	}
+	mp := &methodPool{Pool: p}
+	mp.args.New = newPooled(p.NewArgs, m.argsType)
+	mp.reply.New = newPooled(p.NewReply, m.replyType)
+	if err := checkPooled(mp.args.Get(), m.argsType); err != nil {
+		return err
+	}
+	if m.class != MethodClassStream {
+		if err := checkPooled(mp.reply.Get(), m.replyType); err != nil {
+			return err
+		}
+	}
+	s.pools.mutex.Lock()
+	defer s.pools.mutex.Unlock()
+	if s.pools.methods == nil {
+		s.pools.methods = make(map[string]*methodPool)
+	}
+	s.pools.methods[method] = mp
+	return nil
+}
+
+func newPooled(f func() interface{}, t reflect.Type) func() interface{} {
+	if f != nil {
+		return f
+	}
+	return func() interface{} {
+		return reflect.New(t).Interface()
+	}
+}
+
+func checkPooled(v interface{}, t reflect.Type) error {
+	if reflect.TypeOf(v) != reflect.PtrTo(t) {
+		return fmt.Errorf("rpc: pool returns %T instead of %v", v, reflect.PtrTo(t))
+	}
+	return nil
+}
+
+// get returns the pool of the method, or nil.
+func (p *pools) get(method string) *methodPool {
+	p.mutex.RLock()
+	defer p.mutex.RUnlock()
+	return p.methods[method]
+}
+
+// newArgs returns the args of a call to the method.
+func (p *methodPool) newArgs(t reflect.Type) reflect.Value {
+	if p == nil {
+		return reflect.New(t)
+	}
+	return reflect.ValueOf(p.args.Get())
+}
+
+// newReply returns the reply of a call to the method.
+func (p *methodPool) newReply(t reflect.Type) reflect.Value {
+	if p == nil {
+		return reflect.New(t)
+	}
+	return reflect.ValueOf(p.reply.Get())
+}
+
+// put resets the args and reply of a call and returns them to the pool.
+// Replies of streaming methods aren't pooled.
+func (p *methodPool) put(args, reply reflect.Value, stream bool) {
+	if p == nil {
+		return
+	}
+	var r interface{}
+	if !stream {
+		r = reply.Interface()
+	}
+	if p.Reset != nil {
+		p.Reset(args.Interface(), r)
+	} else {
+		args.Elem().Set(reflect.Zero(args.Type().Elem()))
+		if !stream {
+			reply.Elem().Set(reflect.Zero(reply.Type().Elem()))
+		}
+	}
+	p.args.Put(args.Interface())
+	if !stream {
+		p.reply.Put(r)
+	}
+}
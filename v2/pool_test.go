@@ -0,0 +1,46 @@
+// Copyright 2009 The Go Authors. All rights reserved.
+// Copyright 2012 The Gorilla Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package rpc
+
+import (
+	"testing"
+)
+
+func TestRegisterPool(t *testing.T) {
+	s := NewServer()
+	s.RegisterService(new(Service1), "")
+	s.RegisterCodec(MockCodec{2, 3}, "mock")
+
+	var resets []int
+	err := s.RegisterPool("Service1.Multiply", Pool{
+		NewArgs: func() interface{} { return new(Service1Request) },
+		Reset: func(args, reply interface{}) {
+			res := reply.(*Service1Response)
+			resets = append(resets, res.Result)
+			*args.(*Service1Request) = Service1Request{}
+			*res = Service1Response{}
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 3; i++ {
+		if w := serveMock(s, "Service1.Multiply", ""); w.Body != "6" {
+			t.Errorf("Response was %q, should be 6.", w.Body)
+		}
+	}
+	if len(resets) != 3 || resets[2] != 6 {
+		t.Errorf("Expected 3 resets of the reply, got %v", resets)
+	}
+
+	err = s.RegisterPool("Service1.Multiply", Pool{NewArgs: func() interface{} { return new(Service1Response) }})
+	if err == nil {
+		t.Error("Expected an error for a pool of the wrong type")
+	}
+	if err := s.RegisterPool("Service1.Unknown", Pool{}); err == nil {
+		t.Error("Expected an error for an unknown method")
+	}
+}
@@ -0,0 +1,71 @@
+// Copyright 2009 The Go Authors. All rights reserved.
+// Copyright 2012 The Gorilla Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package rpc
+
+import (
+	"sort"
+	"strings"
+)
+
+// PresenceSetter is implemented by args recording the fields sent by the
+// client. Codecs call SetPresent for each field of the params, with the
+// dotted path of nested fields, e.g. "address.city".
+type PresenceSetter interface {
+	SetPresent(field string, null bool)
+}
+
+// Presence records the fields sent by the client, so that methods can
+// tell an absent field from a null or a zero one, e.g. to update only the
+// fields sent to a PATCH-style method. It's embedded in args:
+//
+//	type UpdateArgs struct {
+//		rpc.Presence
+//		ID    string
+//		Name  *string
+//		Email *string
+//	}
+//
+//	func (t *Users) Update(r *http.Request, args *UpdateArgs, reply *User) error {
+//		if args.Has("Email") {
+//			// args.Email is nil if the client sent null.
+//		}
+//		...
+//	}
+//
+// Fields are named as in the params, compared without case as the JSON
+// codecs decode them.
+type Presence struct {
+	fields map[string]bool
+}
+
+// SetPresent records that the field was sent, and whether it was null.
+func (p *Presence) SetPresent(field string, null bool) {
+	if p.fields == nil {
+		p.fields = make(map[string]bool)
+	}
+	p.fields[strings.ToLower(field)] = null
+}
+
+// Has returns true if the field was sent, even as null.
+func (p *Presence) Has(field string) bool {
+	_, ok := p.fields[strings.ToLower(field)]
+	return ok
+}
+
+// IsNull returns true if the field was sent as null.
+func (p *Presence) IsNull(field string) bool {
+	return p.fields[strings.ToLower(field)]
+}
+
+// Fields returns the sorted paths of the fields sent, in lower case.
+func (p *Presence) Fields() []string {
+	fields := make([]string, 0, len(p.fields))
+	for f := range p.fields {
+		fields = append(fields, f)
+	}
+	sort.Strings(fields)
+	return fields
+}
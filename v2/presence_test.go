@@ -0,0 +1,33 @@
+// Copyright 2009 The Go Authors. All rights reserved.
+// Copyright 2012 The Gorilla Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package rpc
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestPresence(t *testing.T) {
+	var p Presence
+	if p.Has("Name") || len(p.Fields()) != 0 {
+		t.Error("Expected no fields")
+	}
+	p.SetPresent("Name", false)
+	p.SetPresent("email", true)
+	p.SetPresent("address.City", false)
+	if !p.Has("name") || p.IsNull("Name") {
+		t.Error("Expected Name to be set")
+	}
+	if !p.Has("Email") || !p.IsNull("Email") {
+		t.Error("Expected Email to be null")
+	}
+	if p.Has("Phone") || p.IsNull("Phone") {
+		t.Error("Expected Phone to be absent")
+	}
+	if fields := p.Fields(); !reflect.DeepEqual(fields, []string{"address.city", "email", "name"}) {
+		t.Errorf("Wrong fields: %v", fields)
+	}
+}
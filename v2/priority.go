@@ -0,0 +1,31 @@
+// Copyright 2009 The Go Authors. All rights reserved.
+// Copyright 2012 The Gorilla Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package rpc
+
+import (
+	"context"
+)
+
+// PriorityHeader is the header carrying the priority of calls, e.g. "low",
+// "normal" or "high", as understood by the admission package. It's one of
+// the PropagatedHeaders: the priority of a call received by a server is
+// sent with the calls its methods make, so that priorities hold end to
+// end across services. Headers can be set by any client: only trust them
+// behind a gateway controlling them.
+const PriorityHeader = "X-Rpc-Priority"
+
+// WithPriority returns a context carrying the priority, sent in the
+// PriorityHeader of the calls made with it.
+func WithPriority(ctx context.Context, priority string) context.Context {
+	return withPropagatedHeader(ctx, PriorityHeader, priority)
+}
+
+// PriorityFromContext returns the priority carried by the context, set by
+// WithPriority or received in the PriorityHeader of the call, or an empty
+// string.
+func PriorityFromContext(ctx context.Context) string {
+	return propagatedHeader(ctx, PriorityHeader)
+}
@@ -0,0 +1,36 @@
+// Copyright 2009 The Go Authors. All rights reserved.
+// Copyright 2012 The Gorilla Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package rpc
+
+import (
+	"context"
+	"net/http"
+	"testing"
+)
+
+func TestPriority(t *testing.T) {
+	in := http.Header{"X-Rpc-Priority": {"high"}, "Traceparent": {"00-1-2-01"}}
+	ctx := WithPropagation(context.Background(), in)
+	if p := PriorityFromContext(ctx); p != "high" {
+		t.Errorf("Expected the received priority, got %q", p)
+	}
+
+	low := WithPriority(ctx, "low")
+	if p := PriorityFromContext(low); p != "low" {
+		t.Errorf("Expected the priority to be overridden, got %q", p)
+	}
+	if p := PriorityFromContext(ctx); p != "high" {
+		t.Errorf("Expected the parent context to be unchanged, got %q", p)
+	}
+	out := make(http.Header)
+	Propagate(low, out)
+	if out.Get(PriorityHeader) != "low" || out.Get("Traceparent") != "00-1-2-01" {
+		t.Errorf("Wrong propagated headers %v", out)
+	}
+	if p := PriorityFromContext(context.Background()); p != "" {
+		t.Errorf("Expected no priority, got %q", p)
+	}
+}
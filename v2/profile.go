@@ -0,0 +1,42 @@
+// Copyright 2009 The Go Authors. All rights reserved.
+// Copyright 2012 The Gorilla Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package rpc
+
+import (
+	"net/http"
+	"strings"
+)
+
+// Profiler label keys set on calls when profiler labels are enabled.
+const (
+	LabelService = "rpc.service"
+	LabelMethod  = "rpc.method"
+	LabelCodec   = "rpc.codec"
+)
+
+// EnableProfilerLabels sets whether methods are called with pprof labels for
+// their service, method and codec, so CPU and goroutine profiles attribute
+// time to methods. The labels are also set in the request context, so
+// goroutines started with pprof.Do from that context inherit them.
+//
+// The codec label is the Content-Type the codec was registered for. Labels
+// are only set with Go 1.9 and later.
+func (s *Server) EnableProfilerLabels(enabled bool) {
+	s.profilerLabels = enabled
+}
+
+// profile runs f with the profiler labels of the call if they are enabled.
+func (s *Server) profile(r *http.Request, method, contentType string, f func(r *http.Request)) {
+	if !s.profilerLabels {
+		f(r)
+		return
+	}
+	service := method
+	if i := strings.LastIndex(method, "."); i != -1 {
+		service = method[:i]
+	}
+	doWithLabels(r, f, LabelService, service, LabelMethod, method, LabelCodec, contentType)
+}
@@ -0,0 +1,22 @@
+// Copyright 2009 The Go Authors. All rights reserved.
+// Copyright 2012 The Gorilla Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build go1.9
+// +build go1.9
+
+package rpc
+
+import (
+	"context"
+	"net/http"
+	"runtime/pprof"
+)
+
+// doWithLabels runs f with the profiler labels, given as key-value pairs.
+func doWithLabels(r *http.Request, f func(r *http.Request), labels ...string) {
+	pprof.Do(r.Context(), pprof.Labels(labels...), func(ctx context.Context) {
+		f(r.WithContext(ctx))
+	})
+}
@@ -0,0 +1,16 @@
+// Copyright 2009 The Go Authors. All rights reserved.
+// Copyright 2012 The Gorilla Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build !go1.9
+// +build !go1.9
+
+package rpc
+
+import "net/http"
+
+// doWithLabels runs f: profiler labels need Go 1.9.
+func doWithLabels(r *http.Request, f func(r *http.Request), labels ...string) {
+	f(r)
+}
@@ -0,0 +1,51 @@
+// Copyright 2009 The Go Authors. All rights reserved.
+// Copyright 2012 The Gorilla Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build go1.9
+// +build go1.9
+
+package rpc
+
+import (
+	"net/http"
+	"runtime/pprof"
+	"testing"
+)
+
+func TestProfilerLabels(t *testing.T) {
+	s := NewServer()
+	s.RegisterCodec(MockCodec{A: 2, B: 3}, "mock")
+	s.RegisterService(new(Service1), "")
+	labels := make(map[string]string)
+	s.RegisterMiddleware(func(next CallFunc) CallFunc {
+		return func(r *http.Request, method string, args, reply interface{}) error {
+			pprof.ForLabels(r.Context(), func(key, value string) bool {
+				labels[key] = value
+				return true
+			})
+			return next(r, method, args, reply)
+		}
+	})
+
+	serveMock(s, "Service1.Multiply", "")
+	if len(labels) != 0 {
+		t.Fatalf("Expected no labels, got %v", labels)
+	}
+
+	s.EnableProfilerLabels(true)
+	if w := serveMock(s, "Service1.Multiply", ""); w.Body != "6" {
+		t.Fatalf("Wrong response: %q", w.Body)
+	}
+	expected := map[string]string{
+		LabelService: "Service1",
+		LabelMethod:  "Service1.Multiply",
+		LabelCodec:   "mock",
+	}
+	for k, v := range expected {
+		if labels[k] != v {
+			t.Errorf("Expected label %s=%q, got %q", k, v, labels[k])
+		}
+	}
+}
@@ -0,0 +1,94 @@
+// Copyright 2009 The Go Authors. All rights reserved.
+// Copyright 2012 The Gorilla Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package rpc
+
+import (
+	"context"
+	"net/http"
+)
+
+// PropagatedHeaders are the request headers propagated from the calls a
+// server receives to the calls its methods make with the clients of this
+// package: W3C trace context and baggage, correlation ids and priorities.
+var PropagatedHeaders = []string{
+	"Traceparent",
+	"Tracestate",
+	"Baggage",
+	"X-Correlation-Id",
+	RequestIDHeader,
+	PriorityHeader,
+}
+
+// RequestIDHeader is the header carrying the id of a request, propagated
+// with the calls made to serve it.
+const RequestIDHeader = "X-Request-Id"
+
+type propagationKey struct{}
+
+// WithPropagation returns a context carrying the PropagatedHeaders found
+// in h. The server calls it for each request, so methods only need to
+// pass the request context to outgoing calls.
+func WithPropagation(ctx context.Context, h http.Header) context.Context {
+	var p http.Header
+	for _, name := range PropagatedHeaders {
+		if v := h[name]; len(v) > 0 {
+			if p == nil {
+				p = make(http.Header)
+			}
+			p[name] = v
+		}
+	}
+	if p == nil {
+		return ctx
+	}
+	return context.WithValue(ctx, propagationKey{}, p)
+}
+
+// Propagate sets the headers carried by the context on an outgoing request
+// header, unless they are already set, and the TenantHeader of the tenant
+// carried by the context, see WithTenant.
+func Propagate(ctx context.Context, h http.Header) {
+	p, _ := ctx.Value(propagationKey{}).(http.Header)
+	for name, v := range p {
+		if _, ok := h[name]; !ok {
+			h[name] = v
+		}
+	}
+	if tenant, ok := TenantFrom(ctx); ok && h.Get(TenantHeader) == "" {
+		h.Set(TenantHeader, tenant)
+	}
+}
+
+// withPropagatedHeader returns a copy of ctx propagating the header with
+// the value.
+func withPropagatedHeader(ctx context.Context, name, value string) context.Context {
+	p, _ := ctx.Value(propagationKey{}).(http.Header)
+	h := make(http.Header, len(p)+1)
+	for k, v := range p {
+		h[k] = v
+	}
+	h.Set(name, value)
+	return context.WithValue(ctx, propagationKey{}, h)
+}
+
+// propagatedHeader returns the value of the header propagated by ctx.
+func propagatedHeader(ctx context.Context, name string) string {
+	p, _ := ctx.Value(propagationKey{}).(http.Header)
+	return p.Get(name)
+}
+
+// WithRequestID returns a context carrying the id of a request, sent in
+// the RequestIDHeader of the calls made with it.
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return withPropagatedHeader(ctx, RequestIDHeader, id)
+}
+
+// RequestIDFromContext returns the request id carried by the context, set
+// by WithRequestID or received in the RequestIDHeader of the call, or an
+// empty string.
+func RequestIDFromContext(ctx context.Context) string {
+	return propagatedHeader(ctx, RequestIDHeader)
+}
@@ -46,6 +46,12 @@ func (t *Service1) ResponseError(r *http.Request, req *Service1Request, res *Ser
 	return ErrResponseError
 }
 
+func (t *Service1) BadRequest(r *http.Request, req *Service1Request, res *Service1Response) error {
+	return rpc.WithDetails(ErrResponseError, &rpc.BadRequest{
+		FieldViolations: []rpc.FieldViolation{{Field: "A", Description: "must be positive"}},
+	})
+}
+
 func execute(t *testing.T, s *rpc.Server, method string, req, res interface{}) (*httptest.ResponseRecorder, error) {
 	if !s.HasMethod(method) {
 		t.Fatal("Expected to be registered:", method)
@@ -95,3 +101,21 @@ func TestService(t *testing.T) {
 	}
 
 }
+
+func TestErrorDetails(t *testing.T) {
+	s := rpc.NewServer()
+	s.RegisterCodec(NewCodec(), "application/json")
+	s.RegisterService(new(Service1), "")
+
+	var res rpc.Status
+	rec, err := execute(t, s, "Service1.BadRequest", &Service1Request{-1, 2}, &res)
+	if err != nil || rec.Code != 400 {
+		t.Fatalf("Expected code to be 400 and error to be nil, but got %v (%v)", rec.Code, err)
+	}
+	if res.Code != 3 || res.Message != ErrResponseError.Error() || len(res.Details) != 1 {
+		t.Fatalf("Wrong status %+v", res)
+	}
+	if d, ok := res.Details[0].(*rpc.BadRequest); !ok || d.FieldViolations[0].Field != "A" {
+		t.Errorf("Wrong details %+v", res.Details[0])
+	}
+}
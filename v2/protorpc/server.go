@@ -11,9 +11,11 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"reflect"
 	"strings"
 
 	"github.com/gorilla/rpc/v2"
+	"github.com/gorilla/rpc/v2/jsonopt"
 )
 
 var null = json.RawMessage([]byte("null"))
@@ -63,6 +65,12 @@ func (c *Codec) NewRequest(r *http.Request) rpc.CodecRequest {
 	return newCodecRequest(r)
 }
 
+// Warmup prepares the JSON encoding of args and reply, see rpc.Warmer.
+func (c *Codec) Warmup(args, reply reflect.Type) {
+	jsonopt.Warmup(args)
+	jsonopt.Warmup(reply)
+}
+
 // ----------------------------------------------------------------------------
 // CodecRequest
 // ----------------------------------------------------------------------------
@@ -106,8 +114,8 @@ func (c *CodecRequest) Method() (string, error) {
 func (c *CodecRequest) ReadRequest(args interface{}) error {
 	if c.err == nil {
 		if c.request.Params != nil {
-			c.err = json.Unmarshal(*c.request.Params, args)
-		} else {
+			c.err = jsonopt.Unmarshal(*c.request.Params, args)
+		} else if !rpc.IsEmptyArgs(args) {
 			c.err = errors.New("rpc: method request ill-formed: missing params field")
 		}
 	}
@@ -124,18 +132,24 @@ func (c *CodecRequest) WriteResponse(w http.ResponseWriter, reply interface{}) {
 	c.writeServerResponse(w, 200, res)
 }
 
+// WriteError encodes the error in the "error_message" field, and as a
+// google.rpc.Status, with the details of the error, see rpc.WithDetails.
 func (c *CodecRequest) WriteError(w http.ResponseWriter, status int, err error) {
+	details, _ := rpc.EncodeDetails(rpc.ErrorDetails(err))
 	res := &serverResponse{
 		Result: &struct {
-			ErrorMessage interface{} `json:"error_message"`
-		}{err.Error()},
+			ErrorMessage interface{}       `json:"error_message"`
+			Code         int               `json:"code"`
+			Message      string            `json:"message"`
+			Details      []json.RawMessage `json:"details,omitempty"`
+		}{err.Error(), rpc.StatusCode(status), err.Error(), details},
 		Id: c.request.Id,
 	}
 	c.writeServerResponse(w, status, res)
 }
 
 func (c *CodecRequest) writeServerResponse(w http.ResponseWriter, status int, res *serverResponse) {
-	b, err := json.Marshal(res.Result)
+	b, err := jsonopt.Marshal(res.Result)
 	if err != nil {
 		rpc.WriteError(w, 400, err.Error())
 	}
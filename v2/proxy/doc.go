@@ -0,0 +1,48 @@
+// Copyright 2009 The Go Authors. All rights reserved.
+// Copyright 2012 The Gorilla Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+/*
+Package gorilla/rpc/v2/proxy forwards JSON-RPC 2.0 calls to upstream servers,
+letting a server act as a gateway in front of several backends.
+
+Methods are forwarded by prefix. Register a proxy as the fallback of a
+server, so calls to methods that aren't registered locally are sent to the
+matching upstream:
+
+	import (
+		"http"
+		"github.com/gorilla/rpc/v2"
+		"github.com/gorilla/rpc/v2/json2"
+		"github.com/gorilla/rpc/v2/proxy"
+	)
+
+	func init() {
+		p := proxy.New()
+		p.Retries = 2
+		p.Forward("Users.", "http://users.internal/rpc")
+		p.Forward("Billing.", "http://billing.internal/rpc")
+
+		s := rpc.NewServer()
+		s.RegisterCodec(json2.NewCodec(), "application/json")
+		s.RegisterFallbackFunc(p.Fallback)
+		http.Handle("/rpc", s)
+	}
+
+Upstream results and errors are passed through to the client as they were
+received. Calls are retried when the upstream can't be reached or answers
+with a 502, 503 or 504 status.
+
+Gateways in front of many small services can import their registries
+instead of configuring prefixes: each backend registers an
+rpc.RegistryService as "Registry", and the gateway forwards each of their
+methods to the backend owning it:
+
+	p.ImportFrom("http://users.internal/rpc")
+	p.ImportFrom("http://billing.internal/rpc")
+
+In replicated deployments, Leader forwards calls to leader-only methods,
+such as writes, from followers to the current leader.
+*/
+package proxy
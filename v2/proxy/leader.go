@@ -0,0 +1,105 @@
+// Copyright 2009 The Go Authors. All rights reserved.
+// Copyright 2012 The Gorilla Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package proxy
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sync"
+
+	"github.com/gorilla/rpc/v2"
+	"github.com/gorilla/rpc/v2/json2"
+)
+
+// ForwardedHeader is set on calls forwarded to the leader. A follower
+// receiving such a call fails it with ErrNotLeader instead of forwarding it
+// again, so calls don't loop while the leadership changes.
+const ForwardedHeader = "X-Rpc-Forwarded"
+
+// ErrNotLeader is returned for forwarded calls received by a follower.
+var ErrNotLeader = &json2.Error{
+	Code:    json2.E_SERVER,
+	Message: "rpc: not the leader",
+}
+
+// Leader forwards calls to leader-only methods to the current leader when
+// the server is a follower, e.g. write methods of a replicated service.
+//
+//	l := proxy.NewLeader(proxy.New(), func(ctx context.Context) (string, error) {
+//		if raft.IsLeader() {
+//			return "", nil
+//		}
+//		return raft.LeaderURL(), nil
+//	})
+//	l.LeaderOnly("Store.Put", "Store.Delete")
+//	s.RegisterMiddleware(l.Middleware)
+//
+// Forwarded calls go through the middlewares registered before the leader
+// on the follower, and through all the middlewares on the leader.
+type Leader struct {
+	proxy   *Proxy
+	resolve func(ctx context.Context) (string, error)
+	mutex   sync.RWMutex
+	methods map[string]bool
+}
+
+// NewLeader returns a Leader forwarding calls with the proxy. resolve
+// returns the url of the current leader, or an empty string if this server
+// is the leader.
+func NewLeader(p *Proxy, resolve func(ctx context.Context) (string, error)) *Leader {
+	return &Leader{
+		proxy:   p,
+		resolve: resolve,
+		methods: make(map[string]bool),
+	}
+}
+
+// LeaderOnly marks methods as leader-only.
+func (l *Leader) LeaderOnly(methods ...string) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	for _, method := range methods {
+		l.methods[method] = true
+	}
+}
+
+// IsLeaderOnly returns true if the method is leader-only.
+func (l *Leader) IsLeaderOnly(method string) bool {
+	l.mutex.RLock()
+	defer l.mutex.RUnlock()
+	return l.methods[method]
+}
+
+// Middleware forwards calls to leader-only methods to the leader, to be
+// registered with rpc.Server.RegisterMiddleware.
+func (l *Leader) Middleware(next rpc.CallFunc) rpc.CallFunc {
+	return func(r *http.Request, method string, args, reply interface{}) error {
+		if !l.IsLeaderOnly(method) {
+			return next(r, method, args, reply)
+		}
+		url, err := l.resolve(r.Context())
+		if err != nil {
+			return &json2.Error{Code: json2.E_SERVER, Message: err.Error()}
+		}
+		if url == "" {
+			return next(r, method, args, reply)
+		}
+		if r.Header.Get(ForwardedHeader) != "" {
+			return ErrNotLeader
+		}
+		params, err := json.Marshal(args)
+		if err != nil {
+			return err
+		}
+		header := http.Header{ForwardedHeader: {"1"}}
+		result, err := l.proxy.call(r, url, method, params, header)
+		if err != nil {
+			return err
+		}
+		return json.Unmarshal(result, reply)
+	}
+}
@@ -0,0 +1,85 @@
+// Copyright 2009 The Go Authors. All rights reserved.
+// Copyright 2012 The Gorilla Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package proxy
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"github.com/gorilla/rpc/v2"
+	"github.com/gorilla/rpc/v2/json2"
+)
+
+// countCalls returns a middleware counting the calls.
+func countCalls(n *int32) rpc.Middleware {
+	return func(next rpc.CallFunc) rpc.CallFunc {
+		return func(r *http.Request, method string, args, reply interface{}) error {
+			atomic.AddInt32(n, 1)
+			return next(r, method, args, reply)
+		}
+	}
+}
+
+func TestLeader(t *testing.T) {
+	leaderURL := ""
+	resolve := func(ctx context.Context) (string, error) {
+		return leaderURL, nil
+	}
+
+	var leaderCalls int32
+	leader := newServer()
+	leader.RegisterService(new(Service1), "")
+	leader.RegisterMiddleware(countCalls(&leaderCalls))
+	// The leader first believes another server is the leader.
+	var elected int32
+	l := NewLeader(New(), func(ctx context.Context) (string, error) {
+		if atomic.LoadInt32(&elected) == 1 {
+			return "", nil
+		}
+		return "http://127.0.0.1:1", nil
+	})
+	l.LeaderOnly("Service1.Multiply")
+	leader.RegisterMiddleware(l.Middleware)
+	ts := httptest.NewServer(leader)
+	defer ts.Close()
+
+	var followerCalls int32
+	follower := newServer()
+	follower.RegisterService(new(Service1), "")
+	f := NewLeader(New(), resolve)
+	f.LeaderOnly("Service1.Multiply")
+	follower.RegisterMiddleware(f.Middleware)
+	follower.RegisterMiddleware(countCalls(&followerCalls))
+
+	var res Service1Response
+	if err := execute(t, follower, "Service1.Multiply", &Service1Request{4, 2}, &res); err != nil {
+		t.Fatal(err)
+	}
+	if res.Result != 8 || atomic.LoadInt32(&followerCalls) != 1 || atomic.LoadInt32(&leaderCalls) != 0 {
+		t.Fatalf("Expected local call, got %v, %d, %d", res.Result, followerCalls, leaderCalls)
+	}
+
+	leaderURL = ts.URL
+	err := execute(t, follower, "Service1.Multiply", &Service1Request{4, 3}, &res)
+	// The leader refuses to forward the call again.
+	if err == nil || err.(*json2.Error).Message != ErrNotLeader.Message {
+		t.Fatalf("Expected ErrNotLeader, got %v", err)
+	}
+	if atomic.LoadInt32(&leaderCalls) != 1 || followerCalls != 1 {
+		t.Fatalf("Expected forwarded call, got %d, %d", followerCalls, leaderCalls)
+	}
+
+	atomic.StoreInt32(&elected, 1)
+	if err := execute(t, follower, "Service1.Multiply", &Service1Request{4, 3}, &res); err != nil {
+		t.Fatal(err)
+	}
+	if res.Result != 12 || atomic.LoadInt32(&leaderCalls) != 2 || followerCalls != 1 {
+		t.Fatalf("Expected forwarded call, got %v, %d, %d", res.Result, followerCalls, leaderCalls)
+	}
+}
@@ -0,0 +1,312 @@
+// Copyright 2009 The Go Authors. All rights reserved.
+// Copyright 2012 The Gorilla Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package proxy
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"math/rand"
+	"net"
+	"net/http"
+	neturl "net/url"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gorilla/rpc/v2"
+	"github.com/gorilla/rpc/v2/json2"
+)
+
+// DefaultMaxIdleConnsPerHost is the number of idle connections kept open to
+// each upstream by the client created by New.
+const DefaultMaxIdleConnsPerHost = 32
+
+// DefaultRetryBackoff is the default delay before the first retry of a
+// call, doubled before each next one.
+const DefaultRetryBackoff = 50 * time.Millisecond
+
+// ----------------------------------------------------------------------------
+// Request and Response
+// ----------------------------------------------------------------------------
+
+// upstreamRequest represents a JSON-RPC request forwarded upstream.
+type upstreamRequest struct {
+	Version string          `json:"jsonrpc"`
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params,omitempty"`
+	Id      uint64          `json:"id"`
+}
+
+// upstreamResponse represents a JSON-RPC response returned by an upstream.
+type upstreamResponse struct {
+	Result json.RawMessage `json:"result"`
+	Error  json.RawMessage `json:"error"`
+}
+
+// ----------------------------------------------------------------------------
+// Proxy
+// ----------------------------------------------------------------------------
+
+// route maps a method prefix to upstream endpoints.
+type route struct {
+	prefix string
+	urls   []string
+}
+
+// byPrefixLength sorts routes by decreasing prefix length.
+type byPrefixLength []route
+
+func (r byPrefixLength) Len() int           { return len(r) }
+func (r byPrefixLength) Less(i, j int) bool { return len(r[i].prefix) > len(r[j].prefix) }
+func (r byPrefixLength) Swap(i, j int)      { r[i], r[j] = r[j], r[i] }
+
+// pick returns the upstream for the request, selected by affinity token.
+func (rt route) pick(r *http.Request) string {
+	if len(rt.urls) == 1 || r == nil {
+		return rt.urls[0]
+	}
+	token := rpc.AffinityFromContext(r.Context())
+	if token == "" {
+		token = r.Header.Get(rpc.AffinityHeader)
+	}
+	h := fnv.New32a()
+	h.Write([]byte(token))
+	return rt.urls[h.Sum32()%uint32(len(rt.urls))]
+}
+
+// Proxy forwards JSON-RPC 2.0 calls to upstream servers selected by method
+// prefix. Results and errors returned by the upstream are passed through
+// to the client untouched.
+//
+// A Proxy is installed as the fallback of a server, so methods registered
+// locally take precedence over forwarded ones:
+//
+//	p := proxy.New()
+//	p.Forward("Users.", "http://users.internal/rpc")
+//	p.Forward("Billing.", "http://billing.internal/rpc")
+//	s.RegisterFallbackFunc(p.Fallback)
+type Proxy struct {
+	// id is first to be 64-bit aligned for the atomic operations on
+	// 32-bit platforms.
+	id uint64
+
+	// Client is used to send requests upstream.
+	Client *http.Client
+	// Retries is the number of times a call is retried when the upstream
+	// provably didn't process it: the connection couldn't be established,
+	// or the upstream answered with 502 or 503. Calls that timed out or
+	// were answered with 504 aren't retried, since the upstream may have
+	// executed them.
+	Retries int
+	// RetryBackoff is the delay before the first retry, doubled before
+	// each next one, with jitter. If zero, DefaultRetryBackoff is used.
+	RetryBackoff time.Duration
+	// Director, if set, is called to modify each outgoing request, e.g. to
+	// copy authentication headers from the incoming one.
+	Director func(in, out *http.Request)
+
+	mutex    sync.RWMutex
+	routes   []route
+	imported map[string]imported
+}
+
+// New returns a new Proxy using a pooled HTTP client.
+func New() *Proxy {
+	return &Proxy{
+		Client: &http.Client{Transport: NewTransport()},
+	}
+}
+
+// NewTransport returns an http.Transport keeping DefaultMaxIdleConnsPerHost
+// idle connections open to each upstream.
+func NewTransport() *http.Transport {
+	return &http.Transport{
+		Proxy: http.ProxyFromEnvironment,
+		DialContext: (&net.Dialer{
+			Timeout:   30 * time.Second,
+			KeepAlive: 30 * time.Second,
+		}).DialContext,
+		MaxIdleConnsPerHost: DefaultMaxIdleConnsPerHost,
+		IdleConnTimeout:     90 * time.Second,
+	}
+}
+
+// Forward forwards methods starting with prefix to the upstream url. When
+// several prefixes match a method, the longest one wins.
+func (p *Proxy) Forward(prefix, url string) {
+	p.ForwardAffinity(prefix, url)
+}
+
+// ForwardAffinity forwards methods starting with prefix to one of the
+// upstream urls, selected by the affinity token of the call so that calls
+// of a client session always reach the same upstream. The server must
+// issue affinity tokens, see rpc.Server.EnableAffinity.
+func (p *Proxy) ForwardAffinity(prefix string, urls ...string) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	p.routes = append(p.routes, route{prefix: prefix, urls: urls})
+	sort.Stable(byPrefixLength(p.routes))
+}
+
+// Upstream returns the upstream url for the method, or false if the method
+// isn't forwarded. For routes with several upstreams, the first one is
+// returned.
+func (p *Proxy) Upstream(method string) (string, bool) {
+	return p.upstream(nil, method)
+}
+
+// upstream returns the upstream url for the method and request.
+func (p *Proxy) upstream(r *http.Request, method string) (string, bool) {
+	p.mutex.RLock()
+	defer p.mutex.RUnlock()
+	if i, ok := p.imported[method]; ok {
+		return i.url, true
+	}
+	for _, route := range p.routes {
+		if strings.HasPrefix(method, route.prefix) {
+			return route.pick(r), true
+		}
+	}
+	return "", false
+}
+
+// Fallback forwards the call to the matching upstream. It has the signature
+// of rpc.FallbackFunc.
+func (p *Proxy) Fallback(r *http.Request, method string, params json.RawMessage) (interface{}, error) {
+	url, ok := p.upstream(r, method)
+	if !ok {
+		return nil, &json2.Error{
+			Code:    json2.E_NO_METHOD,
+			Message: fmt.Sprintf("rpc: can't find method %q", method),
+		}
+	}
+	return p.Call(r, url, method, params)
+}
+
+// Call sends the call to the upstream url and returns the raw result.
+func (p *Proxy) Call(r *http.Request, url, method string, params json.RawMessage) (json.RawMessage, error) {
+	return p.call(r, url, method, params, nil)
+}
+
+// call sends the call with additional headers.
+func (p *Proxy) call(r *http.Request, url, method string, params json.RawMessage, header http.Header) (json.RawMessage, error) {
+	body, err := json.Marshal(&upstreamRequest{
+		Version: json2.Version,
+		Method:  method,
+		Params:  params,
+		Id:      atomic.AddUint64(&p.id, 1),
+	})
+	if err != nil {
+		return nil, err
+	}
+	var resp *http.Response
+	for attempt := 0; ; attempt++ {
+		resp, err = p.send(r, url, body, header)
+		if err == nil && !isRetryStatus(resp.StatusCode) || err != nil && !isDialError(err) {
+			break
+		}
+		if attempt >= p.Retries {
+			break
+		}
+		if err == nil {
+			resp.Body.Close()
+		}
+		if errWait := p.backoff(r, attempt); errWait != nil {
+			err = errWait
+			break
+		}
+	}
+	if err != nil {
+		return nil, &json2.Error{Code: json2.E_SERVER, Message: err.Error()}
+	}
+	defer resp.Body.Close()
+	var res upstreamResponse
+	if err := json.NewDecoder(resp.Body).Decode(&res); err != nil {
+		return nil, &json2.Error{
+			Code:    json2.E_SERVER,
+			Message: fmt.Sprintf("rpc: bad upstream response (%s): %v", resp.Status, err),
+		}
+	}
+	if len(res.Error) > 0 && string(res.Error) != "null" {
+		jsonErr := &json2.Error{}
+		if err := json.Unmarshal(res.Error, jsonErr); err != nil {
+			return nil, &json2.Error{Code: json2.E_SERVER, Message: string(res.Error)}
+		}
+		return nil, jsonErr
+	}
+	return res.Result, nil
+}
+
+func (p *Proxy) send(r *http.Request, url string, body []byte, header http.Header) (*http.Response, error) {
+	req, err := http.NewRequest("POST", url, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	if r != nil {
+		req = req.WithContext(r.Context())
+		if token := rpc.AffinityFromContext(r.Context()); token != "" {
+			req.Header.Set(rpc.AffinityHeader, token)
+		}
+		rpc.Propagate(r.Context(), req.Header)
+		if p.Director != nil {
+			p.Director(r, req)
+		}
+	}
+	return p.Client.Do(req)
+}
+
+// backoff waits before the retry following the attempt, or returns the
+// error of the context of the incoming request if it's done first.
+func (p *Proxy) backoff(r *http.Request, attempt int) error {
+	delay := p.RetryBackoff
+	if delay <= 0 {
+		delay = DefaultRetryBackoff
+	}
+	delay <<= uint(attempt)
+	// Up to 50% of jitter, so that the retries of concurrent calls don't
+	// hit a recovering upstream at once.
+	delay += time.Duration(rand.Int63n(int64(delay)/2 + 1))
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	if r == nil {
+		<-timer.C
+		return nil
+	}
+	select {
+	case <-r.Context().Done():
+		return r.Context().Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// isRetryStatus returns true if the status means the upstream didn't
+// process the call and it can be safely retried.
+func isRetryStatus(status int) bool {
+	switch status {
+	case http.StatusBadGateway, http.StatusServiceUnavailable:
+		return true
+	}
+	return false
+}
+
+// isDialError returns true if the error happened while connecting to the
+// upstream, before the call was sent.
+func isDialError(err error) bool {
+	if e, ok := err.(*neturl.Error); ok {
+		err = e.Err
+	}
+	e, ok := err.(*net.OpError)
+	return ok && e.Op == "dial"
+}
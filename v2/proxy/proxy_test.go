@@ -0,0 +1,173 @@
+// Copyright 2009 The Go Authors. All rights reserved.
+// Copyright 2012 The Gorilla Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package proxy
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gorilla/rpc/v2"
+	"github.com/gorilla/rpc/v2/json2"
+)
+
+type Service1Request struct {
+	A int
+	B int
+}
+
+type Service1Response struct {
+	Result int
+}
+
+type Service1 struct {
+}
+
+func (t *Service1) Multiply(r *http.Request, req *Service1Request, res *Service1Response) error {
+	res.Result = req.A * req.B
+	return nil
+}
+
+func (t *Service1) Fail(r *http.Request, req *Service1Request, res *Service1Response) error {
+	return &json2.Error{Code: 42, Message: "failed"}
+}
+
+func newServer() *rpc.Server {
+	s := rpc.NewServer()
+	s.RegisterCodec(json2.NewCodec(), "application/json")
+	return s
+}
+
+func execute(t *testing.T, s *rpc.Server, method string, req, res interface{}) error {
+	buf, _ := json2.EncodeClientRequest(method, req)
+	r, _ := http.NewRequest("POST", "http://localhost:8080/", bytes.NewBuffer(buf))
+	r.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	s.ServeHTTP(w, r)
+	return json2.DecodeClientResponse(w.Body, res)
+}
+
+func TestProxy(t *testing.T) {
+	backend := newServer()
+	backend.RegisterService(new(Service1), "")
+	upstream := httptest.NewServer(backend)
+	defer upstream.Close()
+
+	p := New()
+	p.Forward("Service1.", upstream.URL)
+	gateway := newServer()
+	gateway.RegisterFallbackFunc(p.Fallback)
+
+	var res Service1Response
+	if err := execute(t, gateway, "Service1.Multiply", &Service1Request{4, 2}, &res); err != nil {
+		t.Fatal("Expected err to be nil, but got:", err)
+	}
+	if res.Result != 8 {
+		t.Errorf("Wrong response: %v.", res.Result)
+	}
+
+	// Upstream errors are passed through.
+	err := execute(t, gateway, "Service1.Fail", &Service1Request{}, &res)
+	if jsonErr, ok := err.(*json2.Error); !ok || jsonErr.Code != 42 {
+		t.Errorf("Expected upstream error code 42, but got %v", err)
+	}
+
+	// Methods not matching a prefix aren't found.
+	err = execute(t, gateway, "Service2.Multiply", &Service1Request{}, &res)
+	if jsonErr, ok := err.(*json2.Error); !ok || jsonErr.Code != json2.E_NO_METHOD {
+		t.Errorf("Expected E_NO_METHOD, but got %v", err)
+	}
+}
+
+func TestProxyRetries(t *testing.T) {
+	backend := newServer()
+	backend.RegisterService(new(Service1), "")
+	calls := 0
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if calls == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		backend.ServeHTTP(w, r)
+	}))
+	defer upstream.Close()
+
+	p := New()
+	p.Retries = 1
+	p.Forward("Service1.", upstream.URL)
+
+	raw, err := p.Call(nil, upstream.URL, "Service1.Multiply", []byte(`{"A":3,"B":5}`))
+	if err != nil {
+		t.Fatal("Expected err to be nil, but got:", err)
+	}
+	if string(raw) != `{"Result":15}` {
+		t.Errorf("Wrong raw result: %s", raw)
+	}
+	if calls != 2 {
+		t.Errorf("Expected 2 upstream calls, got %d", calls)
+	}
+
+	// The upstream may have executed calls that timed out.
+	timeouts := 0
+	timeout := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		timeouts++
+		w.WriteHeader(http.StatusGatewayTimeout)
+	}))
+	defer timeout.Close()
+	if _, err := p.Call(nil, timeout.URL, "Service1.Multiply", []byte(`{"A":3,"B":5}`)); err == nil || timeouts != 1 {
+		t.Errorf("Expected 1 call and an error, got %d calls and %v", timeouts, err)
+	}
+
+	// Calls are retried with backoff when the connection is refused.
+	refused := httptest.NewServer(backend)
+	refused.Close()
+	p.Retries, p.RetryBackoff = 2, 20*time.Millisecond
+	start := time.Now()
+	if _, err := p.Call(nil, refused.URL, "Service1.Multiply", []byte(`{"A":3,"B":5}`)); err == nil {
+		t.Error("Expected an error from a closed upstream")
+	}
+	if elapsed := time.Since(start); elapsed < 60*time.Millisecond {
+		t.Errorf("Expected retries after 20ms and 40ms, got %v", elapsed)
+	}
+}
+
+func TestProxyAffinity(t *testing.T) {
+	var upstreams []string
+	for i := 0; i < 4; i++ {
+		backend := newServer()
+		backend.RegisterService(new(Service1), "")
+		ts := httptest.NewServer(backend)
+		defer ts.Close()
+		upstreams = append(upstreams, ts.URL)
+	}
+	p := New()
+	p.ForwardAffinity("Service1.", upstreams...)
+
+	for _, token := range []string{"a", "b", "c"} {
+		r, _ := http.NewRequest("POST", "/", nil)
+		r = r.WithContext(rpc.NewAffinityContext(r.Context(), token))
+		first, _ := p.upstream(r, "Service1.Multiply")
+		for i := 0; i < 5; i++ {
+			if url, _ := p.upstream(r, "Service1.Multiply"); url != first {
+				t.Fatalf("Expected token %q to be pinned to %s, got %s", token, first, url)
+			}
+		}
+	}
+
+	gateway := newServer()
+	gateway.EnableAffinity(true)
+	gateway.RegisterFallbackFunc(p.Fallback)
+	var res Service1Response
+	if err := execute(t, gateway, "Service1.Multiply", &Service1Request{4, 2}, &res); err != nil {
+		t.Fatal(err)
+	}
+	if res.Result != 8 {
+		t.Errorf("Wrong response: %v.", res.Result)
+	}
+}
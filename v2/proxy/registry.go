@@ -0,0 +1,87 @@
+// Copyright 2009 The Go Authors. All rights reserved.
+// Copyright 2012 The Gorilla Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package proxy
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	"github.com/gorilla/rpc/v2"
+)
+
+// RegistryMethod is the method called by ImportFrom to fetch the registry
+// of a backend, served by rpc.RegistryService.
+const RegistryMethod = "Registry.Export"
+
+// imported is a method imported from the registry of a backend.
+type imported struct {
+	url    string
+	method rpc.RegistryMethod
+}
+
+// Import forwards the methods of the registry to the backend url, taking
+// precedence over the prefixes of Forward. The RegistryMethod of backends
+// isn't imported. It fails without importing any
+// method if one of them was already imported from another backend;
+// importing the registry of a backend again replaces its methods.
+func (p *Proxy) Import(url string, reg rpc.Registry) error {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	for _, m := range reg.Methods {
+		if i, ok := p.imported[m.Name]; ok && i.url != url && m.Name != RegistryMethod {
+			return fmt.Errorf("rpc: method %q is already imported from %s", m.Name, i.url)
+		}
+	}
+	if p.imported == nil {
+		p.imported = make(map[string]imported)
+	}
+	for name, i := range p.imported {
+		if i.url == url {
+			delete(p.imported, name)
+		}
+	}
+	for _, m := range reg.Methods {
+		if m.Name != RegistryMethod {
+			p.imported[m.Name] = imported{url: url, method: m}
+		}
+	}
+	return nil
+}
+
+// ImportFrom fetches the registry of the backend url, calling its
+// RegistryMethod, and imports it.
+func (p *Proxy) ImportFrom(url string) error {
+	res, err := p.Call(nil, url, RegistryMethod, json.RawMessage("{}"))
+	if err != nil {
+		return err
+	}
+	var reg rpc.Registry
+	if err := json.Unmarshal(res, &reg); err != nil {
+		return fmt.Errorf("rpc: bad registry from %s: %v", url, err)
+	}
+	return p.Import(url, reg)
+}
+
+// Registry returns the imported methods sorted by name, e.g. to be merged
+// with the registry of the gateway.
+func (p *Proxy) Registry() rpc.Registry {
+	p.mutex.RLock()
+	defer p.mutex.RUnlock()
+	reg := rpc.Registry{Methods: []rpc.RegistryMethod{}}
+	for _, i := range p.imported {
+		reg.Methods = append(reg.Methods, i.method)
+	}
+	sort.Sort(byName(reg.Methods))
+	return reg
+}
+
+// byName sorts registry methods by name.
+type byName []rpc.RegistryMethod
+
+func (m byName) Len() int           { return len(m) }
+func (m byName) Less(i, j int) bool { return m[i].Name < m[j].Name }
+func (m byName) Swap(i, j int)      { m[i], m[j] = m[j], m[i] }
@@ -0,0 +1,63 @@
+// Copyright 2009 The Go Authors. All rights reserved.
+// Copyright 2012 The Gorilla Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package proxy
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/rpc/v2"
+)
+
+func TestImport(t *testing.T) {
+	backend := newServer()
+	backend.RegisterService(new(Service1), "")
+	backend.RegisterService(&rpc.RegistryService{Server: backend}, "Registry")
+	backend.RegisterMethodInfo("Service1.Multiply", rpc.MethodInfo{Description: "Multiplies A and B."})
+	upstream := httptest.NewServer(backend)
+	defer upstream.Close()
+
+	p := New()
+	if err := p.ImportFrom(upstream.URL); err != nil {
+		t.Fatal(err)
+	}
+	gateway := newServer()
+	gateway.RegisterFallbackFunc(p.Fallback)
+
+	var res Service1Response
+	if err := execute(t, gateway, "Service1.Multiply", &Service1Request{4, 2}, &res); err != nil {
+		t.Fatal("Expected err to be nil, but got:", err)
+	}
+	if res.Result != 8 {
+		t.Errorf("Wrong response: %v.", res.Result)
+	}
+	if url, ok := p.Upstream("Service1.Multiply"); !ok || url != upstream.URL {
+		t.Errorf("Wrong upstream %q", url)
+	}
+	if _, ok := p.Upstream("Service1.Multiply2"); ok {
+		t.Error("Expected only the imported methods to be forwarded")
+	}
+
+	// Several registries are imported.
+	err := p.Import("http://other.internal/rpc", rpc.Registry{Methods: []rpc.RegistryMethod{{Name: "Registry.Export"}, {Name: "Users.Get"}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if url, _ := p.Upstream("Users.Get"); url != "http://other.internal/rpc" {
+		t.Errorf("Wrong upstream %q", url)
+	}
+
+	reg := p.Registry()
+	if len(reg.Methods) != 3 || reg.Methods[1].Name != "Service1.Multiply" || reg.Methods[1].Description != "Multiplies A and B." {
+		t.Errorf("Wrong registry %+v", reg)
+	}
+
+	// Methods are owned by a single backend.
+	err = p.Import("http://other.internal/rpc", rpc.Registry{Methods: []rpc.RegistryMethod{{Name: "Service1.Multiply"}}})
+	if err == nil {
+		t.Error("Expected the conflicting import to fail")
+	}
+}
@@ -0,0 +1,86 @@
+// Copyright 2009 The Go Authors. All rights reserved.
+// Copyright 2012 The Gorilla Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package quic
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+
+	"github.com/gorilla/rpc/v2"
+	"github.com/gorilla/rpc/v2/json2"
+)
+
+// MaxDatagramSize is the size of the largest notification sent in a
+// datagram. It fits in the smallest packets QUIC supports.
+const MaxDatagramSize = 1200
+
+// ErrDatagramTooLarge is returned when a notification doesn't fit in a
+// datagram.
+var ErrDatagramTooLarge = errors.New("rpc: notification too large for a datagram")
+
+// DatagramConn sends and receives unreliable datagrams, like HTTP/3
+// request streams with datagrams enabled.
+type DatagramConn interface {
+	SendDatagram(b []byte) error
+	ReceiveDatagram(ctx context.Context) ([]byte, error)
+}
+
+// notification is a JSON-RPC 2.0 notification.
+type notification struct {
+	Version string      `json:"jsonrpc"`
+	Method  string      `json:"method"`
+	Params  interface{} `json:"params,omitempty"`
+}
+
+// datagramConn sends the notifications of a session as datagrams.
+type datagramConn struct {
+	conn  DatagramConn
+	close func() error
+}
+
+// NewSessionConn returns a connection sending the notifications of a
+// session as datagrams, for rpc.NewSession. Datagrams are not
+// retransmitted: notifications can be lost, but are never delayed by the
+// loss of previous ones. close is called when the session is closed.
+func NewSessionConn(conn DatagramConn, close func() error) rpc.SessionConn {
+	return &datagramConn{conn: conn, close: close}
+}
+
+// Notify sends a JSON-RPC 2.0 notification in a datagram.
+func (c *datagramConn) Notify(method string, params interface{}) error {
+	data, err := json.Marshal(&notification{Version: json2.Version, Method: method, Params: params})
+	if err != nil {
+		return err
+	}
+	if len(data) > MaxDatagramSize {
+		return ErrDatagramTooLarge
+	}
+	return c.conn.SendDatagram(data)
+}
+
+// Close closes the connection.
+func (c *datagramConn) Close() error {
+	return c.close()
+}
+
+// ReceiveNotifications calls f for each notification received as a
+// datagram, until the context is done or the connection fails.
+func ReceiveNotifications(ctx context.Context, conn DatagramConn, f func(method string, params json.RawMessage)) error {
+	for {
+		data, err := conn.ReceiveDatagram(ctx)
+		if err != nil {
+			return err
+		}
+		var n struct {
+			Method string          `json:"method"`
+			Params json.RawMessage `json:"params"`
+		}
+		if json.Unmarshal(data, &n) == nil && n.Method != "" {
+			f(n.Method, n.Params)
+		}
+	}
+}
@@ -0,0 +1,54 @@
+// Copyright 2009 The Go Authors. All rights reserved.
+// Copyright 2012 The Gorilla Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package quic
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/rpc/v2"
+)
+
+// pipe is a DatagramConn delivering datagrams to itself.
+type pipe chan []byte
+
+func (p pipe) SendDatagram(b []byte) error {
+	p <- b
+	return nil
+}
+
+func (p pipe) ReceiveDatagram(ctx context.Context) ([]byte, error) {
+	select {
+	case b := <-p:
+		return b, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+func TestDatagramNotifications(t *testing.T) {
+	p := make(pipe, 2)
+	session := rpc.NewSession("", NewSessionConn(p, func() error { return nil }))
+	if err := session.Notify("Position.Update", map[string]int{"x": 1}); err != nil {
+		t.Fatal(err)
+	}
+	if err := session.Notify("Big", strings.Repeat("x", MaxDatagramSize)); err != ErrDatagramTooLarge {
+		t.Errorf("Expected ErrDatagramTooLarge, got %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	var method string
+	var params json.RawMessage
+	ReceiveNotifications(ctx, p, func(m string, ps json.RawMessage) {
+		method, params = m, ps
+		cancel()
+	})
+	if method != "Position.Update" || string(params) != `{"x":1}` {
+		t.Errorf("Wrong notification: %s %s", method, params)
+	}
+}
@@ -0,0 +1,38 @@
+// Copyright 2009 The Go Authors. All rights reserved.
+// Copyright 2012 The Gorilla Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+/*
+Package gorilla/rpc/v2/quic serves and calls methods over HTTP/3, which
+doesn't suffer from head-of-line blocking on lossy networks, and delivers
+notifications in QUIC datagrams.
+
+The HTTP/3 support is based on quic-go and only built with the "quic" build
+tag:
+
+	s := rpc.NewServer()
+	s.RegisterCodec(json2.NewCodec(), "application/json")
+	http.Handle("/rpc", s)
+	http.Handle("/notifications", &quic.NotificationHandler{
+		OnConnect: func(session *rpc.Session) {
+			hub.Add(session)
+		},
+	})
+	go quic.ListenAndServeQUIC(":443", "cert.pem", "key.pem", nil)
+
+Clients call methods with a json2.Client using an HTTP/3 transport, and
+receive notifications sent with Session.Notify:
+
+	c := quic.NewClient(nil, "https://example.com/rpc")
+	go quic.Subscribe(ctx, "https://example.com/notifications", nil,
+		func(method string, params json.RawMessage) {
+			// ...
+		})
+
+Datagrams are never retransmitted: use them for notifications that are
+superseded by the next ones, such as positions or progress, and keep
+reliable notifications on streams. Notifications larger than
+MaxDatagramSize fail with ErrDatagramTooLarge.
+*/
+package quic
@@ -0,0 +1,124 @@
+// Copyright 2009 The Go Authors. All rights reserved.
+// Copyright 2012 The Gorilla Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build quic
+// +build quic
+
+package quic
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/url"
+
+	"github.com/gorilla/rpc/v2"
+	"github.com/gorilla/rpc/v2/json2"
+	quicgo "github.com/quic-go/quic-go"
+	"github.com/quic-go/quic-go/http3"
+)
+
+// ListenAndServeQUIC serves HTTP/3 on the UDP address addr, with datagrams
+// enabled.
+func ListenAndServeQUIC(addr, certFile, keyFile string, handler http.Handler) error {
+	s := &http3.Server{
+		Addr:            addr,
+		Handler:         handler,
+		EnableDatagrams: true,
+	}
+	return s.ListenAndServeTLS(certFile, keyFile)
+}
+
+// NewTransport returns an HTTP/3 transport for clients.
+func NewTransport(config *tls.Config) *http3.Transport {
+	return &http3.Transport{TLSClientConfig: config, EnableDatagrams: true}
+}
+
+// NewClient returns a JSON-RPC 2.0 client calling the servers over HTTP/3.
+func NewClient(config *tls.Config, urls ...string) *json2.Client {
+	c := json2.NewClient(urls...)
+	c.HTTPClient = &http.Client{Transport: NewTransport(config)}
+	return c
+}
+
+// NotificationHandler holds HTTP/3 requests open to send notifications to
+// clients as datagrams. Each request starts a session ending when the
+// client goes away.
+type NotificationHandler struct {
+	// OnConnect is called with the session of each client.
+	OnConnect func(s *rpc.Session)
+	// OnDisconnect is called when a client goes away.
+	OnDisconnect func(s *rpc.Session)
+}
+
+func (h *NotificationHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	streamer, ok := w.(http3.HTTPStreamer)
+	if !ok {
+		rpc.WriteError(w, http.StatusBadRequest, "rpc: HTTP/3 required")
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	w.(http.Flusher).Flush()
+	str := streamer.HTTPStream()
+	session := rpc.NewSession("", NewSessionConn(str, str.Close))
+	defer func() {
+		if h.OnDisconnect != nil {
+			h.OnDisconnect(session)
+		}
+	}()
+	defer session.End()
+	if h.OnConnect != nil {
+		h.OnConnect(session)
+	}
+	select {
+	case <-r.Context().Done():
+	case <-session.Done():
+	}
+}
+
+// Subscribe connects to a NotificationHandler at rawurl and calls f for
+// each notification received, until the context is done.
+func Subscribe(ctx context.Context, rawurl string, config *tls.Config, f func(method string, params json.RawMessage)) error {
+	u, err := url.Parse(rawurl)
+	if err != nil {
+		return err
+	}
+	addr := u.Host
+	if u.Port() == "" {
+		addr = net.JoinHostPort(u.Hostname(), "443")
+	}
+	if config == nil {
+		config = &tls.Config{}
+	}
+	config = config.Clone()
+	config.NextProtos = []string{http3.NextProtoH3}
+	conn, err := quicgo.DialAddr(ctx, addr, config, &quicgo.Config{EnableDatagrams: true})
+	if err != nil {
+		return err
+	}
+	defer conn.CloseWithError(0, "")
+	tr := &http3.Transport{EnableDatagrams: true}
+	str, err := tr.NewClientConn(conn).OpenRequestStream(ctx)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(http.MethodGet, rawurl, nil)
+	if err != nil {
+		return err
+	}
+	if err := str.SendRequestHeader(req.WithContext(ctx)); err != nil {
+		return err
+	}
+	resp, err := str.ReadResponse()
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return &json2.Error{Code: json2.E_SERVER, Message: "rpc: subscription failed: " + resp.Status}
+	}
+	return ReceiveNotifications(ctx, str, f)
+}
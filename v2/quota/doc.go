@@ -0,0 +1,29 @@
+// Copyright 2009 The Go Authors. All rights reserved.
+// Copyright 2012 The Gorilla Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+/*
+Package gorilla/rpc/v2/quota enforces daily and monthly request quotas per
+caller, with counters kept in a store shared by all servers.
+
+	q := quota.New(&quota.SQLStore{DB: db}, func(r *http.Request) string {
+		return r.Header.Get("X-Api-Key")
+	}, quota.Limit{Requests: 10000, Period: quota.Day})
+	s.RegisterMiddleware(q.Middleware)
+
+Calls over quota fail with the E_QUOTA_EXCEEDED error code. The error data
+tells when the quota resets:
+
+	{"code": -32011, "message": "rpc: quota exceeded",
+	 "data": {"limit": 10000, "period": "day", "reset": "2024-05-02T00:00:00Z"}}
+
+Stores are provided for memory, Redis and SQL databases. Limits can vary
+per caller by setting Quota.Limits.
+
+Shared stores cost a round trip per call. A CachedStore reserves counts in
+batches and serves them from memory:
+
+	q := quota.New(quota.NewCachedStore(&quota.RedisStore{Do: do}, 20), identify, limits...)
+*/
+package quota
@@ -0,0 +1,157 @@
+// Copyright 2009 The Go Authors. All rights reserved.
+// Copyright 2012 The Gorilla Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package quota
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/rpc/v2"
+	"github.com/gorilla/rpc/v2/json2"
+)
+
+// E_QUOTA_EXCEEDED is the JSON-RPC error code of calls over quota, in the
+// range reserved for server errors.
+const E_QUOTA_EXCEEDED json2.ErrorCode = -32011
+
+// Period is the period over which a quota is counted. Periods start at
+// midnight UTC.
+type Period int
+
+const (
+	Day Period = iota
+	Month
+)
+
+func (p Period) String() string {
+	if p == Month {
+		return "month"
+	}
+	return "day"
+}
+
+// bounds returns the start of the period containing t and the start of the
+// next one.
+func (p Period) bounds(t time.Time) (start, reset time.Time) {
+	t = t.UTC()
+	if p == Month {
+		start = time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+		return start, start.AddDate(0, 1, 0)
+	}
+	start = time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+	return start, start.AddDate(0, 0, 1)
+}
+
+// Limit is a number of requests allowed per period.
+type Limit struct {
+	Requests int64
+	Period   Period
+}
+
+// ExceededData is the data of the error returned for calls over quota.
+type ExceededData struct {
+	Limit  int64     `json:"limit"`
+	Period string    `json:"period"`
+	Reset  time.Time `json:"reset"`
+}
+
+// Store keeps the usage counters. Counters must be shared by all the
+// servers enforcing the same quotas.
+type Store interface {
+	// Incr adds n to the counter of key and returns its new value. The
+	// counter can be forgotten after reset.
+	Incr(ctx context.Context, key string, n int64, reset time.Time) (int64, error)
+}
+
+// Quota enforces request quotas per caller.
+type Quota struct {
+	// Limits returns the limits of a caller. Calls are checked against
+	// all the limits returned.
+	Limits func(caller string) []Limit
+	// OnError is called with store errors. Calls are allowed when the
+	// store fails.
+	OnError func(err error)
+
+	store    Store
+	identify func(r *http.Request) string
+}
+
+// New returns a Quota counting calls in the store per caller, identified
+// by identify, e.g. from an API key. Calls for which identify returns an
+// empty string are not counted.
+func New(store Store, identify func(r *http.Request) string, limits ...Limit) *Quota {
+	return &Quota{
+		Limits: func(string) []Limit {
+			return limits
+		},
+		store:    store,
+		identify: identify,
+	}
+}
+
+// Middleware fails calls over quota, to be registered with
+// rpc.Server.RegisterMiddleware. The error has the E_QUOTA_EXCEEDED code
+// and ExceededData. Responses carry the rpc.Throttle headers of the
+// limit closest to be exceeded.
+func (q *Quota) Middleware(next rpc.CallFunc) rpc.CallFunc {
+	return func(r *http.Request, method string, args, reply interface{}) error {
+		if caller := q.identify(r); caller != "" {
+			t, err := q.use(r.Context(), caller, time.Now())
+			if meta := rpc.ResponseMetaFromContext(r.Context()); meta != nil && t.Limit > 0 {
+				meta.SetThrottle(t)
+			}
+			if err != nil {
+				return err
+			}
+		}
+		return next(r, method, args, reply)
+	}
+}
+
+// Use counts a call of the caller, and returns an error if it is over one
+// of its limits.
+func (q *Quota) Use(ctx context.Context, caller string, now time.Time) error {
+	_, err := q.use(ctx, caller, now)
+	return err
+}
+
+// use counts a call of the caller and returns the throttle of the limit
+// with the fewest remaining calls.
+func (q *Quota) use(ctx context.Context, caller string, now time.Time) (rpc.Throttle, error) {
+	var t rpc.Throttle
+	for _, limit := range q.Limits(caller) {
+		start, reset := limit.Period.bounds(now)
+		key := "quota:" + caller + ":" + limit.Period.String() + ":" + start.Format("2006-01-02")
+		used, err := q.store.Incr(ctx, key, 1, reset)
+		if err != nil {
+			if q.OnError != nil {
+				q.OnError(err)
+			}
+			continue
+		}
+		remaining := limit.Requests - used
+		if remaining < 0 {
+			remaining = 0
+		}
+		if t.Limit == 0 || int(remaining) < t.Remaining || used > limit.Requests {
+			t = rpc.Throttle{Limit: int(limit.Requests), Remaining: int(remaining), Reset: reset.Sub(now)}
+		}
+		if used > limit.Requests {
+			t.RetryAfter = t.Reset
+			return t, &json2.Error{
+				Code:    E_QUOTA_EXCEEDED,
+				Message: "rpc: quota exceeded",
+				Data: &ExceededData{
+					Limit:  limit.Requests,
+					Period: limit.Period.String(),
+					Reset:  reset,
+				},
+			}
+		}
+	}
+	return t, nil
+}
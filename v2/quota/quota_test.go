@@ -0,0 +1,115 @@
+// Copyright 2009 The Go Authors. All rights reserved.
+// Copyright 2012 The Gorilla Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package quota
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/gorilla/rpc/v2/json2"
+)
+
+func TestQuota(t *testing.T) {
+	q := New(NewMemoryStore(), func(r *http.Request) string {
+		return r.Header.Get("X-Api-Key")
+	}, Limit{Requests: 2, Period: Day}, Limit{Requests: 3, Period: Month})
+	call := q.Middleware(func(r *http.Request, method string, args, reply interface{}) error {
+		return nil
+	})
+	r, _ := http.NewRequest("POST", "/", nil)
+	r.Header.Set("X-Api-Key", "acme")
+
+	for i := 0; i < 2; i++ {
+		if err := call(r, "Ping", nil, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	err := call(r, "Ping", nil, nil)
+	jsonErr, ok := err.(*json2.Error)
+	if !ok || jsonErr.Code != E_QUOTA_EXCEEDED {
+		t.Fatalf("Expected quota exceeded, got %v", err)
+	}
+	data := jsonErr.Data.(*ExceededData)
+	_, reset := Day.bounds(time.Now())
+	if data.Limit != 2 || data.Period != "day" || !data.Reset.Equal(reset) {
+		t.Errorf("Wrong error data: %+v", data)
+	}
+
+	anonymous, _ := http.NewRequest("POST", "/", nil)
+	if err := call(anonymous, "Ping", nil, nil); err != nil {
+		t.Errorf("Expected anonymous calls not to be counted, got %v", err)
+	}
+}
+
+func TestQuotaPeriods(t *testing.T) {
+	q := New(NewMemoryStore(), nil, Limit{Requests: 2, Period: Day}, Limit{Requests: 3, Period: Month})
+	ctx := context.Background()
+	day1 := time.Now().AddDate(0, 0, 1)
+	day2 := day1.AddDate(0, 0, 1)
+	if day1.Month() != day2.Month() {
+		day1, day2 = day1.AddDate(0, 0, 2), day2.AddDate(0, 0, 2)
+	}
+	for _, now := range []time.Time{day1, day1, day2} {
+		if err := q.Use(ctx, "acme", now); err != nil {
+			t.Fatal(err)
+		}
+	}
+	err := q.Use(ctx, "acme", day2)
+	if err == nil || err.(*json2.Error).Data.(*ExceededData).Period != "month" {
+		t.Errorf("Expected monthly quota to be exceeded, got %v", err)
+	}
+}
+
+func TestPeriodBounds(t *testing.T) {
+	now := time.Date(2024, 1, 31, 15, 4, 5, 0, time.UTC)
+	start, reset := Month.bounds(now)
+	if !start.Equal(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)) || !reset.Equal(time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("Wrong month bounds: %v, %v", start, reset)
+	}
+	start, reset = Day.bounds(now)
+	if !start.Equal(time.Date(2024, 1, 31, 0, 0, 0, 0, time.UTC)) || !reset.Equal(time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("Wrong day bounds: %v, %v", start, reset)
+	}
+}
+
+// countingStore counts the calls to its store.
+type countingStore struct {
+	Store
+	calls int
+}
+
+func (s *countingStore) Incr(ctx context.Context, key string, n int64, reset time.Time) (int64, error) {
+	s.calls++
+	return s.Store.Incr(ctx, key, n, reset)
+}
+
+func TestCachedStore(t *testing.T) {
+	store := &countingStore{Store: NewMemoryStore()}
+	servers := []*CachedStore{NewCachedStore(store, 3), NewCachedStore(store, 3)}
+	ctx := context.Background()
+	reset := time.Now().Add(time.Hour)
+	var counts []int64
+	for i := 0; i < 4; i++ {
+		n, err := servers[i/2].Incr(ctx, "quota:acme:day:2024-05-01", 1, reset)
+		if err != nil {
+			t.Fatal(err)
+		}
+		counts = append(counts, n)
+	}
+	if counts[0] != 1 || counts[1] != 2 || counts[2] != 4 || counts[3] != 5 {
+		t.Errorf("Wrong counts %v", counts)
+	}
+	if store.calls != 2 {
+		t.Errorf("Expected the counts to be reserved in batches, got %d calls", store.calls)
+	}
+
+	servers[0].Incr(ctx, "quota:acme:day:2024-05-02", 1, reset)
+	if len(servers[0].reservations) != 1 {
+		t.Errorf("Expected the reservations of the previous period to be dropped, got %v", servers[0].reservations)
+	}
+}
@@ -0,0 +1,83 @@
+// Copyright 2009 The Go Authors. All rights reserved.
+// Copyright 2012 The Gorilla Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build go1.8
+// +build go1.8
+
+package quota
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// ----------------------------------------------------------------------------
+// SQLStore
+// ----------------------------------------------------------------------------
+
+// SQLStore keeps the counters in a SQL table, with Go 1.8 and later. The
+// table is created with:
+//
+//	CREATE TABLE quota (
+//		quota_key VARCHAR(255) PRIMARY KEY,
+//		used BIGINT NOT NULL,
+//		reset_at TIMESTAMP NOT NULL
+//	)
+type SQLStore struct {
+	DB *sql.DB
+	// Table is the name of the table, "quota" by default.
+	Table string
+	// Dollar selects $1 placeholders, e.g. for PostgreSQL, instead of ?.
+	Dollar bool
+}
+
+func (s *SQLStore) query(q string) string {
+	table := s.Table
+	if table == "" {
+		table = "quota"
+	}
+	q = strings.Replace(q, "TABLE", table, 1)
+	if s.Dollar {
+		for i := 1; strings.Contains(q, "?"); i++ {
+			q = strings.Replace(q, "?", fmt.Sprintf("$%d", i), 1)
+		}
+	}
+	return q
+}
+
+// Incr adds n to the counter of key in a transaction.
+func (s *SQLStore) Incr(ctx context.Context, key string, n int64, reset time.Time) (int64, error) {
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+	res, err := tx.ExecContext(ctx, s.query("UPDATE TABLE SET used = used + ? WHERE quota_key = ?"), n, key)
+	if err != nil {
+		return 0, err
+	}
+	if rows, err := res.RowsAffected(); err != nil {
+		return 0, err
+	} else if rows == 0 {
+		_, err = tx.ExecContext(ctx, s.query("INSERT INTO TABLE (quota_key, used, reset_at) VALUES (?, ?, ?)"), key, n, reset)
+		if err != nil {
+			return 0, err
+		}
+	}
+	var used int64
+	if err := tx.QueryRowContext(ctx, s.query("SELECT used FROM TABLE WHERE quota_key = ?"), key).Scan(&used); err != nil {
+		return 0, err
+	}
+	return used, tx.Commit()
+}
+
+// Cleanup deletes the expired counters.
+func (s *SQLStore) Cleanup(ctx context.Context) error {
+	_, err := s.DB.ExecContext(ctx, s.query("DELETE FROM TABLE WHERE reset_at <= ?"), time.Now())
+	return err
+}
@@ -0,0 +1,146 @@
+// Copyright 2009 The Go Authors. All rights reserved.
+// Copyright 2012 The Gorilla Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package quota
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ----------------------------------------------------------------------------
+// MemoryStore
+// ----------------------------------------------------------------------------
+
+type counter struct {
+	n     int64
+	reset time.Time
+}
+
+// MemoryStore keeps the counters in memory, for single servers and tests.
+type MemoryStore struct {
+	mutex    sync.Mutex
+	counters map[string]*counter
+}
+
+// NewMemoryStore returns an empty MemoryStore.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{counters: make(map[string]*counter)}
+}
+
+// Incr adds n to the counter of key. Expired counters are removed.
+func (s *MemoryStore) Incr(ctx context.Context, key string, n int64, reset time.Time) (int64, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	now := time.Now()
+	for k, c := range s.counters {
+		if !now.Before(c.reset) {
+			delete(s.counters, k)
+		}
+	}
+	c := s.counters[key]
+	if c == nil {
+		c = &counter{reset: reset}
+		s.counters[key] = c
+	}
+	c.n += n
+	return c.n, nil
+}
+
+// ----------------------------------------------------------------------------
+// RedisStore
+// ----------------------------------------------------------------------------
+
+// redisIncr increments the counter and sets its expiration when created.
+const redisIncr = `local n = redis.call('INCRBY', KEYS[1], ARGV[1])
+if n == tonumber(ARGV[1]) then redis.call('EXPIREAT', KEYS[1], ARGV[2]) end
+return n`
+
+// RedisStore keeps the counters in Redis. It sends commands with Do, so it
+// works with any Redis client. With go-redis:
+//
+//	store := &quota.RedisStore{Do: func(ctx context.Context, args ...interface{}) (interface{}, error) {
+//		return rdb.Do(ctx, args...).Result()
+//	}}
+type RedisStore struct {
+	Do func(ctx context.Context, args ...interface{}) (interface{}, error)
+}
+
+// Incr adds n to the counter of key, which expires at reset.
+func (s *RedisStore) Incr(ctx context.Context, key string, n int64, reset time.Time) (int64, error) {
+	res, err := s.Do(ctx, "EVAL", redisIncr, 1, key, n, reset.Unix())
+	if err != nil {
+		return 0, err
+	}
+	used, ok := res.(int64)
+	if !ok {
+		return 0, fmt.Errorf("rpc: unexpected redis reply %T", res)
+	}
+	return used, nil
+}
+
+// ----------------------------------------------------------------------------
+// CachedStore
+// ----------------------------------------------------------------------------
+
+// reservation is a range of counts reserved in the store.
+type reservation struct {
+	next, last int64
+}
+
+// CachedStore reserves counts in Store Batch at a time and serves them from
+// memory, to save a round trip to a shared store per call. Counts reserved
+// by a server but not used are counted anyway, so quotas are slightly
+// stricter, and more so with many servers.
+type CachedStore struct {
+	Store Store
+	// Batch is the number of counts reserved at once.
+	Batch int64
+
+	mutex        sync.Mutex
+	reservations map[string]*reservation
+}
+
+// NewCachedStore returns a CachedStore reserving batch counts at once in
+// store.
+func NewCachedStore(store Store, batch int64) *CachedStore {
+	return &CachedStore{Store: store, Batch: batch}
+}
+
+// Incr adds n to the counter of key, reserving counts in Store if those
+// reserved are used.
+func (s *CachedStore) Incr(ctx context.Context, key string, n int64, reset time.Time) (int64, error) {
+	if s.Batch <= 1 || n != 1 {
+		return s.Store.Incr(ctx, key, n, reset)
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.reservations == nil {
+		s.reservations = make(map[string]*reservation)
+	}
+	res := s.reservations[key]
+	if res == nil || res.next > res.last {
+		last, err := s.Store.Incr(ctx, key, s.Batch, reset)
+		if err != nil {
+			return 0, err
+		}
+		if res == nil {
+			// Keys change with the period: forget the previous ones.
+			for k := range s.reservations {
+				if k[:strings.LastIndex(k, ":")] == key[:strings.LastIndex(key, ":")] {
+					delete(s.reservations, k)
+				}
+			}
+		}
+		res = &reservation{next: last - s.Batch + 1, last: last}
+		s.reservations[key] = res
+	}
+	used := res.next
+	res.next++
+	return used, nil
+}
@@ -0,0 +1,107 @@
+// Copyright 2009 The Go Authors. All rights reserved.
+// Copyright 2012 The Gorilla Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package rpc
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// ErrRateLimited is returned to the client when a method is called more
+// often than allowed by its rate limit.
+var ErrRateLimited = errors.New("rpc: rate limit exceeded")
+
+// RateLimitStore keeps the token buckets of the rate limits of the Config,
+// see Server.SetRateLimitStore. Buckets are keyed by method.
+type RateLimitStore interface {
+	// Allow takes a token from the bucket of key, refilled at rate tokens
+	// per second up to burst tokens. It returns false if the bucket is
+	// empty, and the state of the bucket.
+	Allow(ctx context.Context, key string, rate float64, burst int) (bool, Throttle, error)
+}
+
+// SetRateLimitStore sets the store of the rate limits of the Config, e.g.
+// to share them by all the servers of a fleet. Rate limits are kept in
+// memory by default, or if store is nil. Calls are allowed when the store
+// fails.
+func (s *Server) SetRateLimitStore(store RateLimitStore) {
+	s.rateLimitStore = store
+}
+
+// allow takes a token for a call of the method, from the store if set.
+func (s *Server) allow(ctx context.Context, method string, rate float64, burst int) (bool, Throttle) {
+	if s.rateLimitStore == nil {
+		return s.rateLimiters.allow(method, rate, burst)
+	}
+	allowed, t, err := s.rateLimitStore.Allow(ctx, method, rate, burst)
+	if err != nil {
+		return true, Throttle{}
+	}
+	return allowed, t
+}
+
+// tokenBucket is a token bucket rate limiter. Its rate and burst are given
+// on each call, so that they can be changed at any time.
+type tokenBucket struct {
+	mutex  sync.Mutex
+	tokens float64
+	last   time.Time
+}
+
+// allow takes a token from the bucket, refilled at rate tokens per second
+// up to burst tokens. It returns false if the bucket is empty, and the
+// state of the bucket.
+func (b *tokenBucket) allow(now time.Time, rate float64, burst int) (bool, Throttle) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	if burst < 1 {
+		burst = 1
+	}
+	if b.last.IsZero() {
+		b.tokens = float64(burst)
+	} else {
+		b.tokens += now.Sub(b.last).Seconds() * rate
+	}
+	if b.tokens > float64(burst) {
+		b.tokens = float64(burst)
+	}
+	b.last = now
+	allowed := b.tokens >= 1
+	if allowed {
+		b.tokens--
+	}
+	t := Throttle{
+		Limit:     burst,
+		Remaining: int(b.tokens),
+		Reset:     time.Duration((float64(burst) - b.tokens) / rate * float64(time.Second)),
+	}
+	if !allowed {
+		t.RetryAfter = time.Duration((1 - b.tokens) / rate * float64(time.Second))
+	}
+	return allowed, t
+}
+
+// rateLimiters holds a token bucket per key.
+type rateLimiters struct {
+	mutex   sync.Mutex
+	buckets map[string]*tokenBucket
+}
+
+func (l *rateLimiters) allow(key string, rate float64, burst int) (bool, Throttle) {
+	l.mutex.Lock()
+	if l.buckets == nil {
+		l.buckets = make(map[string]*tokenBucket)
+	}
+	b := l.buckets[key]
+	if b == nil {
+		b = new(tokenBucket)
+		l.buckets[key] = b
+	}
+	l.mutex.Unlock()
+	return b.allow(time.Now(), rate, burst)
+}
@@ -0,0 +1,26 @@
+// Copyright 2009 The Go Authors. All rights reserved.
+// Copyright 2012 The Gorilla Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+/*
+Package gorilla/rpc/v2/ratelimit keeps the rate limits of the server Config
+in Redis, so that they are enforced across all the servers of a fleet
+rather than per server:
+
+	s.SetRateLimitStore(ratelimit.NewRedisStore(func(ctx context.Context, args ...interface{}) (interface{}, error) {
+		return rdb.Do(ctx, args...).Result()
+	}))
+
+The buckets are updated by a Lua script, atomically. To save a round trip
+per call, the store can take several tokens at once and serve them from
+memory:
+
+	store.Batch = 10
+
+Tokens taken but not used by a server are lost after MaxHold, so limits
+are slightly stricter with batches, and more so with many servers.
+
+The store works with any Redis client through the Do function.
+*/
+package ratelimit
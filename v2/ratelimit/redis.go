@@ -0,0 +1,184 @@
+// Copyright 2009 The Go Authors. All rights reserved.
+// Copyright 2012 The Gorilla Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gorilla/rpc/v2"
+)
+
+// DefaultMaxHold is the time tokens taken in a batch are kept in memory.
+const DefaultMaxHold = time.Second
+
+// redisTake takes up to ARGV[4] tokens from the bucket, refilled at ARGV[1]
+// tokens per second up to ARGV[2] tokens, at ARGV[3] milliseconds. It
+// returns the number of tokens taken and the tokens left.
+const redisTake = `local rate = tonumber(ARGV[1])
+local burst = tonumber(ARGV[2])
+local now = tonumber(ARGV[3])
+local b = redis.call('HMGET', KEYS[1], 'tokens', 'last')
+local tokens = burst
+if b[1] then
+	tokens = math.min(burst, tonumber(b[1]) + math.max(0, now - tonumber(b[2])) * rate / 1000)
+end
+local n = math.min(tonumber(ARGV[4]), math.floor(tokens))
+tokens = tokens - n
+redis.call('HSET', KEYS[1], 'tokens', tostring(tokens), 'last', ARGV[3])
+redis.call('PEXPIRE', KEYS[1], math.ceil(burst / rate * 1000) + 1000)
+return {n, tostring(tokens)}`
+
+// lease holds the tokens of a bucket taken in a batch.
+type lease struct {
+	// expires is first to be 64-bit aligned for the atomic operations on
+	// 32-bit platforms. It is in Unix nanoseconds, and is read without the
+	// mutex to evict idle leases.
+	expires int64
+	mutex   sync.Mutex
+	tokens  int
+	left    float64 // tokens left in Redis when the lease was taken
+}
+
+// RedisStore keeps the token buckets in Redis, see rpc.RateLimitStore. It
+// sends commands with Do, so it works with any Redis client.
+type RedisStore struct {
+	// Prefix is prepended to the keys of the buckets, "ratelimit:" by
+	// default.
+	Prefix string
+	// Batch is the number of tokens taken from Redis at once, and served
+	// from memory. If zero or one, each call takes its token from Redis.
+	Batch int
+	// MaxHold is the time tokens of a batch are kept in memory,
+	// DefaultMaxHold if zero. Leases idle for longer are evicted.
+	MaxHold time.Duration
+
+	do      func(ctx context.Context, args ...interface{}) (interface{}, error)
+	mutex   sync.Mutex
+	leases  map[string]*lease
+	evicted time.Time
+	now     func() time.Time
+}
+
+// NewRedisStore returns a RedisStore sending commands with do. With
+// go-redis:
+//
+//	store := ratelimit.NewRedisStore(func(ctx context.Context, args ...interface{}) (interface{}, error) {
+//		return rdb.Do(ctx, args...).Result()
+//	})
+func NewRedisStore(do func(ctx context.Context, args ...interface{}) (interface{}, error)) *RedisStore {
+	return &RedisStore{
+		do:     do,
+		leases: make(map[string]*lease),
+		now:    time.Now,
+	}
+}
+
+func (s *RedisStore) maxHold() time.Duration {
+	if s.MaxHold > 0 {
+		return s.MaxHold
+	}
+	return DefaultMaxHold
+}
+
+// Allow takes a token from the bucket of key, from memory if some are left
+// from the last batch, or else from Redis. The rate must be positive.
+func (s *RedisStore) Allow(ctx context.Context, key string, rate float64, burst int) (bool, rpc.Throttle, error) {
+	if !(rate > 0) {
+		return false, rpc.Throttle{}, fmt.Errorf("rpc: invalid rate limit %v", rate)
+	}
+	if burst < 1 {
+		burst = 1
+	}
+	maxHold := s.maxHold()
+	s.mutex.Lock()
+	now := s.now()
+	if now.Sub(s.evicted) >= maxHold {
+		s.evict(now.Add(-maxHold))
+		s.evicted = now
+	}
+	l := s.leases[key]
+	if l == nil {
+		// The lease isn't evicted before its first batch is taken.
+		l = &lease{expires: now.UnixNano()}
+		s.leases[key] = l
+	}
+	s.mutex.Unlock()
+
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	now = s.now()
+	if l.tokens == 0 || now.UnixNano() >= atomic.LoadInt64(&l.expires) {
+		n, left, err := s.take(ctx, key, rate, burst, now)
+		if err != nil {
+			return false, rpc.Throttle{}, err
+		}
+		l.tokens, l.left = n, left
+		atomic.StoreInt64(&l.expires, now.Add(maxHold).UnixNano())
+	}
+	t := rpc.Throttle{Limit: burst}
+	allowed := l.tokens > 0
+	if allowed {
+		l.tokens--
+	}
+	tokens := l.left + float64(l.tokens)
+	t.Remaining = int(tokens)
+	t.Reset = time.Duration((float64(burst) - tokens) / rate * float64(time.Second))
+	if !allowed {
+		t.RetryAfter = time.Duration((1 - l.left) / rate * float64(time.Second))
+	}
+	return allowed, t, nil
+}
+
+// evict deletes the leases expired before the given time, so that keys no
+// longer called don't accumulate. Their tokens aren't served anymore. The
+// mutex must be held.
+func (s *RedisStore) evict(before time.Time) {
+	for key, l := range s.leases {
+		if atomic.LoadInt64(&l.expires) < before.UnixNano() {
+			delete(s.leases, key)
+		}
+	}
+}
+
+// take takes a batch of tokens from Redis, and returns the number of
+// tokens taken and the tokens left.
+func (s *RedisStore) take(ctx context.Context, key string, rate float64, burst int, now time.Time) (int, float64, error) {
+	batch := s.Batch
+	if batch < 1 {
+		batch = 1
+	}
+	if batch > burst {
+		batch = burst
+	}
+	prefix := s.Prefix
+	if prefix == "" {
+		prefix = "ratelimit:"
+	}
+	res, err := s.do(ctx, "EVAL", redisTake, 1, prefix+key,
+		strconv.FormatFloat(rate, 'g', -1, 64), burst, now.UnixNano()/int64(time.Millisecond), batch)
+	if err != nil {
+		return 0, 0, err
+	}
+	values, ok := res.([]interface{})
+	if !ok || len(values) != 2 {
+		return 0, 0, fmt.Errorf("rpc: unexpected redis reply %v", res)
+	}
+	n, ok := values[0].(int64)
+	if !ok {
+		return 0, 0, fmt.Errorf("rpc: unexpected redis reply %v", res)
+	}
+	str, _ := values[1].(string)
+	left, err := strconv.ParseFloat(str, 64)
+	if err != nil {
+		return 0, 0, fmt.Errorf("rpc: unexpected redis reply %v", res)
+	}
+	return int(n), left, nil
+}
@@ -0,0 +1,126 @@
+// Copyright 2009 The Go Authors. All rights reserved.
+// Copyright 2012 The Gorilla Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package ratelimit
+
+import (
+	"context"
+	"math"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeRedis runs the token bucket script of the store.
+type fakeRedis struct {
+	mutex   sync.Mutex
+	buckets map[string][2]float64
+	calls   int
+}
+
+func (f *fakeRedis) Do(ctx context.Context, args ...interface{}) (interface{}, error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	f.calls++
+	key := args[3].(string)
+	rate, _ := strconv.ParseFloat(args[4].(string), 64)
+	burst := float64(args[5].(int))
+	now := float64(args[6].(int64))
+	tokens := burst
+	if b, ok := f.buckets[key]; ok {
+		tokens = math.Min(burst, b[0]+math.Max(0, now-b[1])*rate/1000)
+	}
+	n := math.Min(float64(args[7].(int)), math.Floor(tokens))
+	tokens -= n
+	f.buckets[key] = [2]float64{tokens, now}
+	return []interface{}{int64(n), strconv.FormatFloat(tokens, 'g', -1, 64)}, nil
+}
+
+func TestRedisStore(t *testing.T) {
+	f := &fakeRedis{buckets: make(map[string][2]float64)}
+	now := time.Unix(1700000000, 0)
+	// Two servers sharing the bucket.
+	stores := []*RedisStore{NewRedisStore(f.Do), NewRedisStore(f.Do)}
+	for _, s := range stores {
+		s.now = func() time.Time { return now }
+	}
+	ctx := context.Background()
+	for i := 0; i < 4; i++ {
+		allowed, th, err := stores[i%2].Allow(ctx, "Users.Get", 1, 4)
+		if err != nil || !allowed {
+			t.Fatalf("Expected call %d to be allowed, got %v, %v", i, allowed, err)
+		}
+		if th.Limit != 4 || th.Remaining != 3-i {
+			t.Errorf("Wrong throttle %+v", th)
+		}
+	}
+	allowed, th, _ := stores[0].Allow(ctx, "Users.Get", 1, 4)
+	if allowed || th.RetryAfter != time.Second || th.Reset != 4*time.Second {
+		t.Errorf("Expected the call to be rejected, got %v, %+v", allowed, th)
+	}
+	now = now.Add(time.Second)
+	if allowed, _, _ := stores[1].Allow(ctx, "Users.Get", 1, 4); !allowed {
+		t.Error("Expected the bucket to be refilled")
+	}
+	if _, ok := f.buckets["ratelimit:Users.Get"]; !ok {
+		t.Errorf("Expected the bucket in Redis, got %v", f.buckets)
+	}
+}
+
+func TestRedisStoreBatch(t *testing.T) {
+	f := &fakeRedis{buckets: make(map[string][2]float64)}
+	now := time.Unix(1700000000, 0)
+	s := NewRedisStore(f.Do)
+	s.Batch = 3
+	s.now = func() time.Time { return now }
+	ctx := context.Background()
+	for i := 0; i < 6; i++ {
+		if allowed, _, _ := s.Allow(ctx, "Users.Get", 1, 10); !allowed {
+			t.Fatalf("Expected call %d to be allowed", i)
+		}
+	}
+	if f.calls != 2 {
+		t.Errorf("Expected the tokens to be taken in batches, got %d calls", f.calls)
+	}
+
+	// Unused tokens are dropped after MaxHold.
+	s.Allow(ctx, "Users.Get", 1, 10)
+	now = now.Add(DefaultMaxHold)
+	s.Allow(ctx, "Users.Get", 1, 10)
+	if f.calls != 4 {
+		t.Errorf("Expected the batch to expire, got %d calls", f.calls)
+	}
+}
+
+func TestRedisStoreEvict(t *testing.T) {
+	f := &fakeRedis{buckets: make(map[string][2]float64)}
+	now := time.Unix(1700000000, 0)
+	s := NewRedisStore(f.Do)
+	s.now = func() time.Time { return now }
+	ctx := context.Background()
+	for i := 0; i < 100; i++ {
+		s.Allow(ctx, "Users.Get:"+strconv.Itoa(i), 1, 10)
+	}
+	// The leases of the keys no longer called are evicted.
+	now = now.Add(3 * DefaultMaxHold)
+	s.Allow(ctx, "Users.Get:0", 1, 10)
+	if n := len(s.leases); n != 1 {
+		t.Errorf("Expected a single lease, got %d", n)
+	}
+}
+
+func TestRedisStoreInvalidRate(t *testing.T) {
+	f := &fakeRedis{buckets: make(map[string][2]float64)}
+	s := NewRedisStore(f.Do)
+	for _, rate := range []float64{0, -1, math.NaN()} {
+		if allowed, _, err := s.Allow(context.Background(), "Users.Get", rate, 10); allowed || err == nil {
+			t.Errorf("Expected rate %v to be rejected, got %v", rate, allowed)
+		}
+	}
+	if f.calls != 0 || len(s.leases) != 0 {
+		t.Errorf("Expected no call to Redis, got %d", f.calls)
+	}
+}
@@ -0,0 +1,47 @@
+// Copyright 2009 The Go Authors. All rights reserved.
+// Copyright 2012 The Gorilla Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package rpc
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+)
+
+type mockRateLimitStore struct {
+	keys []string
+	err  error
+}
+
+func (m *mockRateLimitStore) Allow(ctx context.Context, key string, rate float64, burst int) (bool, Throttle, error) {
+	m.keys = append(m.keys, key)
+	return false, Throttle{Limit: burst, RetryAfter: 1}, m.err
+}
+
+func TestRateLimitStore(t *testing.T) {
+	s := NewServer()
+	s.RegisterService(new(Service1), "")
+	s.RegisterCodec(MockCodec{2, 3}, "mock")
+	s.SetConfig(&Config{
+		Methods: map[string]*MethodConfig{
+			"Service1.Multiply": {RateLimit: 1, RateBurst: 5},
+		},
+	})
+	store := new(mockRateLimitStore)
+	s.SetRateLimitStore(store)
+	if w := serveMock(s, "Service1.Multiply", ""); w.Status != http.StatusTooManyRequests {
+		t.Errorf("Expected the call to be rejected by the store, got %d", w.Status)
+	}
+	if len(store.keys) != 1 || store.keys[0] != "Service1.Multiply" {
+		t.Errorf("Wrong keys %v", store.keys)
+	}
+
+	store.err = errors.New("down")
+	if w := serveMock(s, "Service1.Multiply", ""); w.Status != http.StatusOK {
+		t.Errorf("Expected the call to be allowed when the store fails, got %d", w.Status)
+	}
+}
@@ -0,0 +1,20 @@
+// Copyright 2009 The Go Authors. All rights reserved.
+// Copyright 2012 The Gorilla Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+/*
+Package gorilla/rpc/v2/recorder keeps the slowest recent calls of a server,
+with their params and trace id, to diagnose tail latency in production
+without enabling full tracing.
+
+	rec := recorder.New()
+	s.RegisterMiddleware(rec.Middleware)
+
+	// Plain JSON endpoint.
+	http.Handle("/debug/rpc/slowest", rec)
+
+	// Or as a service of an admin server.
+	admin.RegisterService(&recorder.Service{Recorder: rec}, "Recorder")
+*/
+package recorder
@@ -0,0 +1,204 @@
+// Copyright 2009 The Go Authors. All rights reserved.
+// Copyright 2012 The Gorilla Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package recorder
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/rpc/v2"
+)
+
+const (
+	// DefaultSize is the default number of calls kept by a recorder.
+	DefaultSize = 32
+	// DefaultWindow is the default age after which calls are forgotten.
+	DefaultWindow = 10 * time.Minute
+	// DefaultMaxParams is the default size of params summaries.
+	DefaultMaxParams = 256
+)
+
+// Call is a recorded call.
+type Call struct {
+	Method   string        `json:"method"`
+	Start    time.Time     `json:"start"`
+	Duration time.Duration `json:"duration"`
+	Params   string        `json:"params"`
+	TraceID  string        `json:"traceId,omitempty"`
+	Error    string        `json:"error,omitempty"`
+}
+
+// Recorder keeps the slowest recent calls, so tail latency can be diagnosed
+// without tracing all calls.
+type Recorder struct {
+	// Size is the number of calls kept.
+	Size int
+	// Window is the age after which calls are forgotten.
+	Window time.Duration
+	// MaxParams is the size after which params summaries are truncated.
+	MaxParams int
+	// TraceID returns the trace id of a request. By default it is read from
+	// the W3C traceparent header.
+	TraceID func(r *http.Request) string
+
+	mutex sync.Mutex
+	calls []*Call
+}
+
+// New returns a recorder with the default settings.
+func New() *Recorder {
+	return &Recorder{
+		Size:      DefaultSize,
+		Window:    DefaultWindow,
+		MaxParams: DefaultMaxParams,
+	}
+}
+
+// Middleware records the calls, to be registered with
+// rpc.Server.RegisterMiddleware.
+func (rec *Recorder) Middleware(next rpc.CallFunc) rpc.CallFunc {
+	return func(r *http.Request, method string, args, reply interface{}) error {
+		start := time.Now()
+		err := next(r, method, args, reply)
+		d := time.Since(start)
+		if !rec.slow(start, d) {
+			return err
+		}
+		c := &Call{
+			Method:   method,
+			Start:    start,
+			Duration: d,
+			Params:   rec.summary(args),
+		}
+		if rec.TraceID != nil {
+			c.TraceID = rec.TraceID(r)
+		} else {
+			c.TraceID = TraceParentID(r)
+		}
+		if err != nil {
+			c.Error = err.Error()
+		}
+		rec.add(c)
+		return err
+	}
+}
+
+// expire forgets calls older than the window.
+func (rec *Recorder) expire(now time.Time) {
+	calls := rec.calls[:0]
+	for _, c := range rec.calls {
+		if now.Sub(c.Start) < rec.Window {
+			calls = append(calls, c)
+		}
+	}
+	rec.calls = calls
+}
+
+// slow returns true if a call is slow enough to be recorded.
+func (rec *Recorder) slow(start time.Time, d time.Duration) bool {
+	rec.mutex.Lock()
+	defer rec.mutex.Unlock()
+	rec.expire(start)
+	return len(rec.calls) < rec.Size || d > rec.calls[len(rec.calls)-1].Duration
+}
+
+// add inserts the call, keeping the calls sorted by decreasing duration.
+func (rec *Recorder) add(c *Call) {
+	rec.mutex.Lock()
+	defer rec.mutex.Unlock()
+	i := sort.Search(len(rec.calls), func(i int) bool {
+		return rec.calls[i].Duration < c.Duration
+	})
+	rec.calls = append(rec.calls, nil)
+	copy(rec.calls[i+1:], rec.calls[i:])
+	rec.calls[i] = c
+	if len(rec.calls) > rec.Size {
+		rec.calls = rec.calls[:rec.Size]
+	}
+}
+
+// summary returns the args encoded as JSON, truncated to MaxParams.
+func (rec *Recorder) summary(args interface{}) string {
+	data, err := json.Marshal(args)
+	if err != nil {
+		return "<" + err.Error() + ">"
+	}
+	if rec.MaxParams > 0 && len(data) > rec.MaxParams {
+		return string(data[:rec.MaxParams]) + "..."
+	}
+	return string(data)
+}
+
+// Slowest returns the recorded calls, slowest first.
+func (rec *Recorder) Slowest() []Call {
+	rec.mutex.Lock()
+	defer rec.mutex.Unlock()
+	rec.expire(time.Now())
+	calls := make([]Call, len(rec.calls))
+	for i, c := range rec.calls {
+		calls[i] = *c
+	}
+	return calls
+}
+
+// Reset forgets all the recorded calls.
+func (rec *Recorder) Reset() {
+	rec.mutex.Lock()
+	defer rec.mutex.Unlock()
+	rec.calls = nil
+}
+
+// ServeHTTP writes the recorded calls as JSON.
+func (rec *Recorder) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	json.NewEncoder(w).Encode(rec.Slowest())
+}
+
+// TraceParentID returns the trace id of the W3C traceparent header of the
+// request, or an empty string.
+func TraceParentID(r *http.Request) string {
+	parts := strings.Split(r.Header.Get("traceparent"), "-")
+	if len(parts) != 4 {
+		return ""
+	}
+	return parts[1]
+}
+
+// ----------------------------------------------------------------------------
+// Service
+// ----------------------------------------------------------------------------
+
+// SlowestArgs are the args of Service.Slowest.
+type SlowestArgs struct {
+	// Method limits the calls to a method.
+	Method string `json:"method,omitempty"`
+}
+
+// SlowestReply is the reply of Service.Slowest.
+type SlowestReply struct {
+	Calls []Call `json:"calls"`
+}
+
+// Service exposes a recorder as an RPC service, usually registered with
+// the name "Recorder" on an admin server.
+type Service struct {
+	Recorder *Recorder
+}
+
+// Slowest returns the recorded calls, slowest first.
+func (s *Service) Slowest(r *http.Request, args *SlowestArgs, reply *SlowestReply) error {
+	reply.Calls = []Call{}
+	for _, c := range s.Recorder.Slowest() {
+		if args.Method == "" || args.Method == c.Method {
+			reply.Calls = append(reply.Calls, c)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,49 @@
+// Copyright 2009 The Go Authors. All rights reserved.
+// Copyright 2012 The Gorilla Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package recorder
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestRecorder(t *testing.T) {
+	rec := New()
+	rec.Size = 2
+	rec.MaxParams = 8
+	call := rec.Middleware(func(r *http.Request, method string, args, reply interface{}) error {
+		time.Sleep(args.(time.Duration))
+		if method == "Fail" {
+			return errors.New("failed")
+		}
+		return nil
+	})
+	r, _ := http.NewRequest("POST", "/", nil)
+	r.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+
+	call(r, "A", 10*time.Millisecond, nil)
+	call(r, "Fail", 30*time.Millisecond, nil)
+	call(r, "B", 20*time.Millisecond, nil)
+	call(r, "C", time.Millisecond, nil)
+
+	calls := rec.Slowest()
+	if len(calls) != 2 || calls[0].Method != "Fail" || calls[1].Method != "B" {
+		t.Fatalf("Wrong calls: %+v", calls)
+	}
+	if calls[0].Error != "failed" || calls[0].Params != "30000000" {
+		t.Errorf("Wrong call: %+v", calls[0])
+	}
+	if calls[0].TraceID != "4bf92f3577b34da6a3ce929d0e0e4736" {
+		t.Errorf("Wrong trace id: %q", calls[0].TraceID)
+	}
+
+	rec.Window = time.Nanosecond
+	if calls := rec.Slowest(); len(calls) != 0 {
+		t.Errorf("Expected calls to expire, got %+v", calls)
+	}
+}
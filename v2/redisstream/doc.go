@@ -0,0 +1,27 @@
+// Copyright 2009 The Go Authors. All rights reserved.
+// Copyright 2012 The Gorilla Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+/*
+Package gorilla/rpc/v2/redisstream serves calls read from a Redis Stream,
+giving lightweight deployments a brokered transport without running Kafka
+or RabbitMQ.
+
+Callers add entries with the codec message in the "body" field, a
+"correlation-id" and the "reply-to" stream where they read the response:
+
+	XADD billing.requests * correlation-id 42 reply-to client-7.replies body '{"jsonrpc":"2.0",...}'
+
+Servers read the requests as consumers of the same group, so each request
+is served once:
+
+	t := redisstream.NewTransport(s, func(ctx context.Context, args ...interface{}) (interface{}, error) {
+		return rdb.Do(ctx, args...).Result()
+	}, "billing.requests", "billing", hostname)
+	t.Nil = redis.Nil
+	err := t.Serve(ctx)
+
+The transport works with any Redis client through the Do function.
+*/
+package redisstream
@@ -0,0 +1,214 @@
+// Copyright 2009 The Go Authors. All rights reserved.
+// Copyright 2012 The Gorilla Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package redisstream
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/rpc/v2"
+)
+
+// Entry fields used by the transport.
+const (
+	// BodyField holds the codec message.
+	BodyField = "body"
+	// CorrelationField identifies a call. Responses carry the correlation
+	// id of their request.
+	CorrelationField = "correlation-id"
+	// ReplyToField is the stream where the response is added.
+	ReplyToField = "reply-to"
+)
+
+// Defaults of the transport.
+const (
+	DefaultCount       = 10
+	DefaultBlock       = 5 * time.Second
+	DefaultReplyMaxLen = 10000
+)
+
+// Transport serves calls read from a Redis Stream with a consumer group,
+// and adds their responses to reply streams.
+//
+// Entries are acknowledged after their response is added, so they are
+// processed at least once: entries left pending by a consumer that failed
+// are processed again when it restarts with the same name. The correlation
+// id is passed to the server as the request id, so a server with a
+// DedupStore replays the responses of entries processed again.
+type Transport struct {
+	// Server serves the calls.
+	Server *rpc.Server
+	// ContentType selects the server codec. If empty, "application/json"
+	// is used.
+	ContentType string
+	// Do sends a command to Redis. With go-redis:
+	//
+	//	func(ctx context.Context, args ...interface{}) (interface{}, error) {
+	//		return rdb.Do(ctx, args...).Result()
+	//	}
+	Do func(ctx context.Context, args ...interface{}) (interface{}, error)
+	// Nil is the error returned by Do on nil replies, e.g. redis.Nil with
+	// go-redis. Clients returning nil replies without error leave it nil.
+	Nil error
+	// Stream, Group and Consumer name the stream of the requests, the
+	// consumer group of the servers and this server within the group.
+	Stream, Group, Consumer string
+	// ReplyStream is the stream of the responses to entries without a
+	// ReplyToField.
+	ReplyStream string
+	// Count is the maximum number of entries read at once. If zero,
+	// DefaultCount is used.
+	Count int
+	// ReplyMaxLen trims reply streams to about this length. If zero,
+	// DefaultReplyMaxLen is used.
+	ReplyMaxLen int
+}
+
+// NewTransport returns a new Transport serving calls from stream with s.
+func NewTransport(s *rpc.Server, do func(ctx context.Context, args ...interface{}) (interface{}, error), stream, group, consumer string) *Transport {
+	return &Transport{
+		Server:   s,
+		Do:       do,
+		Stream:   stream,
+		Group:    group,
+		Consumer: consumer,
+	}
+}
+
+// entry is a stream entry.
+type entry struct {
+	id     string
+	fields map[string]string
+}
+
+// Serve creates the consumer group if needed, and serves the entries until
+// the context is done or Redis fails.
+func (t *Transport) Serve(ctx context.Context) error {
+	_, err := t.Do(ctx, "XGROUP", "CREATE", t.Stream, t.Group, "$", "MKSTREAM")
+	if err != nil && !strings.Contains(err.Error(), "BUSYGROUP") {
+		return err
+	}
+	count := t.Count
+	if count == 0 {
+		count = DefaultCount
+	}
+	// Process the pending entries first, then the new ones.
+	start := "0"
+	for ctx.Err() == nil {
+		res, err := t.Do(ctx, "XREADGROUP", "GROUP", t.Group, t.Consumer,
+			"COUNT", count, "BLOCK", int64(DefaultBlock/time.Millisecond),
+			"STREAMS", t.Stream, start)
+		if err != nil && err != t.Nil {
+			return err
+		}
+		entries, err := parseEntries(res)
+		if err != nil {
+			return err
+		}
+		if len(entries) == 0 {
+			start = ">"
+			continue
+		}
+		errs := make([]error, len(entries))
+		var wg sync.WaitGroup
+		for i := range entries {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				errs[i] = t.serve(ctx, entries[i])
+			}(i)
+		}
+		wg.Wait()
+		for _, err := range errs {
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return ctx.Err()
+}
+
+// serve serves an entry, adds its response and acknowledges it.
+func (t *Transport) serve(ctx context.Context, e entry) error {
+	r, err := http.NewRequest("POST", "redis://"+t.Stream, nil)
+	if err != nil {
+		return err
+	}
+	contentType := t.ContentType
+	if contentType == "" {
+		contentType = "application/json"
+	}
+	r.Header.Set("Content-Type", contentType)
+	if id := e.fields[CorrelationField]; id != "" {
+		ctx = rpc.NewIdempotencyContext(ctx, id)
+	}
+	r = r.WithContext(ctx)
+	res := t.Server.ServeMessage(r, []byte(e.fields[BodyField]))
+	stream := e.fields[ReplyToField]
+	if stream == "" {
+		stream = t.ReplyStream
+	}
+	if len(res) > 0 && stream != "" {
+		maxLen := t.ReplyMaxLen
+		if maxLen == 0 {
+			maxLen = DefaultReplyMaxLen
+		}
+		_, err := t.Do(ctx, "XADD", stream, "MAXLEN", "~", maxLen, "*",
+			CorrelationField, e.fields[CorrelationField], BodyField, string(res))
+		if err != nil {
+			return err
+		}
+	}
+	_, err = t.Do(ctx, "XACK", t.Stream, t.Group, e.id)
+	return err
+}
+
+// parseEntries parses the reply of XREADGROUP for a single stream:
+// [[stream, [[id, [field, value, ...]], ...]]].
+func parseEntries(res interface{}) ([]entry, error) {
+	if res == nil {
+		return nil, nil
+	}
+	streams, ok := res.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("rpc: unexpected XREADGROUP reply %T", res)
+	}
+	var entries []entry
+	for _, s := range streams {
+		stream, ok := s.([]interface{})
+		if !ok || len(stream) != 2 {
+			return nil, fmt.Errorf("rpc: unexpected XREADGROUP stream %v", s)
+		}
+		items, _ := stream[1].([]interface{})
+		for _, item := range items {
+			kv, ok := item.([]interface{})
+			if !ok || len(kv) != 2 {
+				return nil, fmt.Errorf("rpc: unexpected XREADGROUP entry %v", item)
+			}
+			e := entry{id: toString(kv[0]), fields: make(map[string]string)}
+			values, _ := kv[1].([]interface{})
+			for i := 0; i+1 < len(values); i += 2 {
+				e.fields[toString(values[i])] = toString(values[i+1])
+			}
+			entries = append(entries, e)
+		}
+	}
+	return entries, nil
+}
+
+func toString(v interface{}) string {
+	switch v := v.(type) {
+	case string:
+		return v
+	case []byte:
+		return string(v)
+	}
+	return fmt.Sprint(v)
+}
@@ -0,0 +1,117 @@
+// Copyright 2009 The Go Authors. All rights reserved.
+// Copyright 2012 The Gorilla Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package redisstream
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/gorilla/rpc/v2"
+	"github.com/gorilla/rpc/v2/json2"
+)
+
+type EchoService struct{}
+
+func (s *EchoService) Echo(r *http.Request, args *string, reply *string) error {
+	*reply = *args
+	return nil
+}
+
+// errNil is the error of fakeRedis on nil replies.
+var errNil = errors.New("redis: nil")
+
+// fakeRedis answers the commands of the transport.
+type fakeRedis struct {
+	mutex   sync.Mutex
+	pending []interface{}
+	fresh   []interface{}
+	acked   []string
+	added   map[string][]interface{}
+	cancel  func()
+}
+
+func (f *fakeRedis) Do(ctx context.Context, args ...interface{}) (interface{}, error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	switch args[0] {
+	case "XGROUP":
+		return nil, errors.New("BUSYGROUP Consumer Group name already exists")
+	case "XREADGROUP":
+		var items []interface{}
+		if args[len(args)-1] == "0" {
+			items, f.pending = f.pending, nil
+		} else {
+			items, f.fresh = f.fresh, nil
+			if items == nil {
+				f.cancel()
+				return nil, errNil
+			}
+		}
+		return []interface{}{[]interface{}{"requests", items}}, nil
+	case "XADD":
+		f.added[args[1].(string)] = args[6:]
+	case "XACK":
+		f.acked = append(f.acked, args[3].(string))
+	}
+	return "OK", nil
+}
+
+func request(id, correlation string) interface{} {
+	body, _ := json2.EncodeClientRequest("EchoService.Echo", "hi "+correlation)
+	return []interface{}{id, []interface{}{
+		CorrelationField, correlation,
+		ReplyToField, "replies." + correlation,
+		BodyField, string(body),
+	}}
+}
+
+func TestTransport(t *testing.T) {
+	s := rpc.NewServer()
+	s.RegisterCodec(json2.NewCodec(), "application/json")
+	s.RegisterService(new(EchoService), "")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	f := &fakeRedis{
+		pending: []interface{}{request("1-0", "a")},
+		fresh:   []interface{}{request("2-0", "b")},
+		added:   make(map[string][]interface{}),
+		cancel:  cancel,
+	}
+	tr := NewTransport(s, f.Do, "requests", "group", "consumer")
+	tr.Nil = errNil
+	if err := tr.Serve(ctx); err != context.Canceled {
+		t.Fatalf("Expected context.Canceled, got %v", err)
+	}
+	if len(f.acked) != 2 || f.acked[0] != "1-0" || f.acked[1] != "2-0" {
+		t.Errorf("Wrong acknowledged entries: %v", f.acked)
+	}
+	for _, c := range []string{"a", "b"} {
+		reply := f.added["replies."+c]
+		if len(reply) != 4 || reply[1] != c || !strings.Contains(reply[3].(string), `"result":"hi `+c+`"`) {
+			t.Errorf("Wrong reply for %s: %v", c, reply)
+		}
+	}
+}
+
+func TestTransportError(t *testing.T) {
+	s := rpc.NewServer()
+	s.RegisterCodec(json2.NewCodec(), "application/json")
+	fail := errors.New("WRONGTYPE value is nil or not a stream")
+	tr := NewTransport(s, func(ctx context.Context, args ...interface{}) (interface{}, error) {
+		if args[0] == "XREADGROUP" {
+			return nil, fail
+		}
+		return "OK", nil
+	}, "requests", "group", "consumer")
+	tr.Nil = errNil
+	if err := tr.Serve(context.Background()); err != fail {
+		t.Fatalf("Expected %v, got %v", fail, err)
+	}
+}
@@ -0,0 +1,75 @@
+// Copyright 2009 The Go Authors. All rights reserved.
+// Copyright 2012 The Gorilla Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package rpc
+
+import (
+	"net/http"
+	"time"
+)
+
+// Registry is the serializable description of the methods of a server,
+// e.g. for a gateway to route the methods of many small services to the
+// backends owning them, see proxy.Proxy.Import.
+type Registry struct {
+	Methods []RegistryMethod `json:"methods"`
+}
+
+// RegistryMethod describes a method of a Registry.
+type RegistryMethod struct {
+	// Name is the name of the method in dotted notation.
+	Name string `json:"name"`
+	// Params and Result are the names of the Go types of the args and
+	// reply, for documentation.
+	Params string `json:"params"`
+	Result string `json:"result"`
+	// Description, Deprecated and Sunset are set from the MethodInfo of
+	// documented methods.
+	Description string     `json:"description,omitempty"`
+	Deprecated  bool       `json:"deprecated,omitempty"`
+	Sunset      *time.Time `json:"sunset,omitempty"`
+	Paginated   bool       `json:"paginated,omitempty"`
+}
+
+// Registry returns the description of the methods of the registered
+// services, sorted by name.
+func (s *Server) Registry() Registry {
+	reg := Registry{Methods: []RegistryMethod{}}
+	for _, name := range s.Methods() {
+		m, err := s.router.Resolve(name)
+		if err != nil {
+			continue
+		}
+		info, _ := s.MethodInfo(name)
+		rm := RegistryMethod{
+			Name:        name,
+			Params:      m.argsType.String(),
+			Result:      m.replyType.String(),
+			Description: info.Description,
+			Deprecated:  info.Deprecated,
+			Paginated:   info.Paginated,
+		}
+		if !info.Sunset.IsZero() {
+			sunset := info.Sunset
+			rm.Sunset = &sunset
+		}
+		reg.Methods = append(reg.Methods, rm)
+	}
+	return reg
+}
+
+// RegistryService exposes the registry of a server as an RPC service,
+// usually registered with the name "Registry" for gateways to import it:
+//
+//	s.RegisterService(&rpc.RegistryService{Server: s}, "Registry")
+type RegistryService struct {
+	Server *Server
+}
+
+// Export returns the registry of the server.
+func (t *RegistryService) Export(r *http.Request, args *struct{}, reply *Registry) error {
+	*reply = t.Server.Registry()
+	return nil
+}
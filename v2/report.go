@@ -0,0 +1,91 @@
+// Copyright 2009 The Go Authors. All rights reserved.
+// Copyright 2012 The Gorilla Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package rpc
+
+import (
+	"fmt"
+	"net/http"
+	"runtime/debug"
+)
+
+// ErrInternal is written instead of the error of methods that panicked,
+// when an error reporter is registered.
+var ErrInternal error = internalError{}
+
+type internalError struct{}
+
+func (internalError) Error() string   { return "rpc: internal error" }
+func (internalError) HTTPStatus() int { return http.StatusInternalServerError }
+
+// PanicError is reported for the methods that panicked, with the value
+// passed to panic.
+type PanicError struct {
+	Value interface{}
+}
+
+func (e *PanicError) Error() string {
+	return fmt.Sprintf("rpc: panic: %v", e.Value)
+}
+
+// RegisterErrorReporter registers the function called with the internal
+// errors of calls, e.g. to send them to a crash reporting service.
+//
+// Internal errors are the errors with a 5xx status: the errors of methods
+// implementing StatusError with such a status, and the errors encoding the
+// replies. Expected errors, e.g. invalid params or the errors of methods
+// with the default 400 status, aren't reported.
+//
+// When a reporter is registered, the panics of methods and middlewares
+// are recovered: they're reported as a *PanicError with the stack of the
+// panic, and the client gets ErrInternal. The stack is nil for other
+// errors.
+//
+// Note: Only one function can be registered, subsequent calls to this
+// method will overwrite all the previous functions.
+func (s *Server) RegisterErrorReporter(f func(i *RequestInfo, err error, stack []byte)) {
+	s.errorReporter = f
+}
+
+// reportError reports the error of a call with the status, if internal.
+func (s *Server) reportError(r *http.Request, method string, status int, err error) {
+	if s.errorReporter == nil || err == nil || err == ErrInternal || status < 500 {
+		return
+	}
+	s.errorReporter(&RequestInfo{
+		Request:    r,
+		Method:     method,
+		Error:      err,
+		StatusCode: status,
+		Body:       requestBody(r),
+	}, err, nil)
+}
+
+// recoverCall calls the method with call, recovering and reporting its
+// panics if an error reporter is registered.
+func (s *Server) recoverCall(call CallFunc, r *http.Request, method string, args, reply interface{}) (err error) {
+	if s.errorReporter == nil {
+		return call(r, method, args, reply)
+	}
+	defer func() {
+		v := recover()
+		if v == nil {
+			return
+		}
+		if v == errAbortHandler {
+			panic(v)
+		}
+		e := &PanicError{Value: v}
+		s.errorReporter(&RequestInfo{
+			Request:    r,
+			Method:     method,
+			Error:      e,
+			StatusCode: http.StatusInternalServerError,
+			Body:       requestBody(r),
+		}, e, debug.Stack())
+		err = ErrInternal
+	}()
+	return call(r, method, args, reply)
+}
@@ -0,0 +1,62 @@
+// Copyright 2009 The Go Authors. All rights reserved.
+// Copyright 2012 The Gorilla Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package rpc
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestErrorReporter(t *testing.T) {
+	s := NewServer()
+	s.RegisterCodec(MockCodec{A: 1, B: 2}, "mock")
+	s.RegisterService(new(Service1), "")
+	var fail func() error
+	s.RegisterMiddleware(func(next CallFunc) CallFunc {
+		return func(r *http.Request, method string, args, reply interface{}) error {
+			if fail != nil {
+				return fail()
+			}
+			return next(r, method, args, reply)
+		}
+	})
+	type report struct {
+		err   error
+		stack []byte
+	}
+	var reports []report
+	s.RegisterErrorReporter(func(i *RequestInfo, err error, stack []byte) {
+		reports = append(reports, report{err, stack})
+	})
+
+	// Successful calls and expected errors aren't reported.
+	serveMock(s, "Service1.Multiply", "")
+	fail = func() error { return errors.New("invalid") }
+	serveMock(s, "Service1.Multiply", "")
+	if len(reports) != 0 {
+		t.Fatalf("Unexpected reports %v", reports)
+	}
+
+	fail = func() error { return statusError{} }
+	serveMock(s, "Service1.Multiply", "")
+	if len(reports) != 1 || reports[0].err != (statusError{}) || reports[0].stack != nil {
+		t.Fatalf("Expected the internal error to be reported, got %v", reports)
+	}
+
+	fail = func() error { panic("boom") }
+	w := serveMock(s, "Service1.Multiply", "")
+	if w.Status != http.StatusInternalServerError || w.Body != ErrInternal.Error() {
+		t.Errorf("Expected an internal error, got %d %s", w.Status, w.Body)
+	}
+	if len(reports) != 2 {
+		t.Fatalf("Expected the panic to be reported, got %v", reports)
+	}
+	if e, ok := reports[1].err.(*PanicError); !ok || e.Value != "boom" || !strings.Contains(string(reports[1].stack), "TestErrorReporter") {
+		t.Errorf("Wrong panic report %v %s", reports[1].err, reports[1].stack)
+	}
+}
@@ -0,0 +1,191 @@
+// Copyright 2009 The Go Authors. All rights reserved.
+// Copyright 2012 The Gorilla Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package rpc
+
+import (
+	"context"
+	"net/http"
+	"sync"
+)
+
+// StatusReply is implemented by replies setting the HTTP status of their
+// response, e.g. 201 Created, instead of 200 OK. See also SetHTTPStatus.
+type StatusReply interface {
+	HTTPStatus() int
+}
+
+// ResponseMeta holds what a method sets on the response of its call,
+// besides the reply: its HTTP status, headers, cookies and trailers. It is
+// carried by the context of the call, see ResponseMetaFromContext, and
+// methods may also take it as a fifth argument:
+//
+//	func (s *Service) Login(r *http.Request, args *Args, reply *Reply, meta *rpc.ResponseMeta) error
+//
+// Headers and cookies are sent with errors too; the status only with
+// replies. Other transports than HTTP ignore the meta. It is safe for
+// concurrent use.
+type ResponseMeta struct {
+	mutex   sync.Mutex
+	status  int
+	header  http.Header
+	trailer http.Header
+}
+
+type responseMetaKey struct{}
+
+// ResponseMetaFromContext returns the meta of the response of the call
+// whose context is ctx, or nil if ctx isn't the context of a call.
+func ResponseMetaFromContext(ctx context.Context) *ResponseMeta {
+	meta, _ := ctx.Value(responseMetaKey{}).(*ResponseMeta)
+	return meta
+}
+
+// with returns a copy of r whose context carries the meta, for the call of
+// the method.
+func (m *ResponseMeta) with(r *http.Request) *http.Request {
+	return r.WithContext(context.WithValue(r.Context(), responseMetaKey{}, m))
+}
+
+// SetHTTPStatus sets the HTTP status of the response of the call whose
+// context is ctx, if it succeeds, e.g. to 201 Created or 202 Accepted. It
+// returns false if ctx isn't the context of a call. Codecs writing their
+// own status keep it.
+func SetHTTPStatus(ctx context.Context, status int) bool {
+	meta := ResponseMetaFromContext(ctx)
+	if meta == nil {
+		return false
+	}
+	meta.SetStatus(status)
+	return true
+}
+
+// SetStatus sets the HTTP status of the response, if the call succeeds.
+func (m *ResponseMeta) SetStatus(status int) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.status = status
+}
+
+// SetHeader sets a header of the response.
+func (m *ResponseMeta) SetHeader(key, value string) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	if m.header == nil {
+		m.header = make(http.Header)
+	}
+	m.header.Set(key, value)
+}
+
+// AddHeader adds a value to a header of the response.
+func (m *ResponseMeta) AddHeader(key, value string) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	if m.header == nil {
+		m.header = make(http.Header)
+	}
+	m.header.Add(key, value)
+}
+
+// SetCookie adds a "Set-Cookie" header to the response.
+func (m *ResponseMeta) SetCookie(cookie *http.Cookie) {
+	if v := cookie.String(); v != "" {
+		m.AddHeader("Set-Cookie", v)
+	}
+}
+
+// SetTrailer sets a trailer of the response, sent after its body.
+func (m *ResponseMeta) SetTrailer(key, value string) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	if m.trailer == nil {
+		m.trailer = make(http.Header)
+	}
+	m.trailer.Set(key, value)
+}
+
+// writeHeader adds the headers to the response and declares the trailers,
+// before it's written.
+func (m *ResponseMeta) writeHeader(w http.ResponseWriter) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	for k, v := range m.header {
+		w.Header()[k] = append(w.Header()[k], v...)
+	}
+	for k := range m.trailer {
+		w.Header().Add("Trailer", k)
+	}
+}
+
+// writeTrailer sets the trailers of the response, after it's written.
+// Trailers set after the response was written are sent undeclared, with
+// the "Trailer:" prefix of http.TrailerPrefix. Go 1.7 drops them.
+func (m *ResponseMeta) writeTrailer(w http.ResponseWriter) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	declared := w.Header()["Trailer"]
+	for k, v := range m.trailer {
+		if !containsString(declared, k) {
+			k = "Trailer:" + k
+		}
+		w.Header()[k] = v
+	}
+}
+
+func containsString(values []string, s string) bool {
+	for _, v := range values {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// replyStatus returns the HTTP status set for the response of the call,
+// or zero.
+func (m *ResponseMeta) replyStatus(reply interface{}) int {
+	m.mutex.Lock()
+	status := m.status
+	m.mutex.Unlock()
+	if status != 0 {
+		return status
+	}
+	if sr, ok := reply.(StatusReply); ok {
+		return sr.HTTPStatus()
+	}
+	return 0
+}
+
+// statusWriter is an http.ResponseWriter replacing the 200 status written
+// by codecs with the status set by the method.
+type statusWriter struct {
+	http.ResponseWriter
+	status      int
+	wroteHeader bool
+}
+
+func (w *statusWriter) WriteHeader(status int) {
+	if w.wroteHeader {
+		return
+	}
+	w.wroteHeader = true
+	if status == http.StatusOK {
+		status = w.status
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *statusWriter) Write(p []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	return w.ResponseWriter.Write(p)
+}
+
+func (w *statusWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
@@ -0,0 +1,32 @@
+// Copyright 2009 The Go Authors. All rights reserved.
+// Copyright 2012 The Gorilla Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package rpc
+
+import (
+	"context"
+	"net/http"
+	"testing"
+)
+
+func TestSetHTTPStatus(t *testing.T) {
+	s := NewServer()
+	s.RegisterService(new(Service1), "")
+	s.RegisterCodec(MockCodec{2, 3}, "mock")
+	s.RegisterMiddleware(func(next CallFunc) CallFunc {
+		return func(r *http.Request, method string, args, reply interface{}) error {
+			if !SetHTTPStatus(r.Context(), http.StatusCreated) {
+				t.Error("Expected the context of a call")
+			}
+			return next(r, method, args, reply)
+		}
+	})
+	if w := serveMock(s, "Service1.Multiply", ""); w.Status != http.StatusCreated || w.Body != "6" {
+		t.Errorf("Status was %d, should be 201.", w.Status)
+	}
+	if SetHTTPStatus(context.Background(), http.StatusCreated) {
+		t.Error("Expected no call in the background context")
+	}
+}
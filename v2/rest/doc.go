@@ -0,0 +1,39 @@
+// Copyright 2009 The Go Authors. All rights reserved.
+// Copyright 2012 The Gorilla Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+/*
+Package gorilla/rpc/v2/rest serves RESTful routes in front of an RPC server,
+so that one implementation serves both RPC and REST clients. The routes are
+declared in the HTTP field of the rpc.MethodInfo of the methods:
+
+	s := rpc.NewServer()
+	s.RegisterCodec(json2.NewCodec(), "application/json")
+	s.RegisterService(new(UserService), "")
+	s.RegisterMethodInfo("UserService.Get", rpc.MethodInfo{HTTP: "GET /users/{id}"})
+	s.RegisterMethodInfo("UserService.List", rpc.MethodInfo{HTTP: "GET /users"})
+	s.RegisterMethodInfo("UserService.Update", rpc.MethodInfo{HTTP: "PUT /users/{id}"})
+
+	h, err := rest.NewHandler(s, "application/json")
+	if err != nil {
+		log.Fatal(err)
+	}
+	http.Handle("/rpc", s)
+	http.Handle("/users", h)
+	http.Handle("/users/", h)
+
+The variables of the paths and the query params are the fields of the
+args structs with the same JSON names, e.g. GET /users/42 calls
+UserService.Get with {"id": 42}, and GET /users?limit=10&tag=a&tag=b
+calls UserService.List with {"limit": 10, "tag": ["a", "b"]}. The JSON
+bodies of the other requests are the params, with the variables and query
+params added to them. The "_fields" query param lists the members of
+sparse replies, see jsonopt, e.g. ?_fields=id,name.
+
+Calls go through the server as those of its path routing: the codec
+registered with the content type, which must implement rpc.PathCodec,
+encodes the result, or the error with its HTTP status, as the body of the
+response. Methods read the variables with rpc.RouteVar too.
+*/
+package rest
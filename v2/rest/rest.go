@@ -0,0 +1,322 @@
+// Copyright 2009 The Go Authors. All rights reserved.
+// Copyright 2012 The Gorilla Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package rest
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/gorilla/rpc/v2"
+	"github.com/gorilla/rpc/v2/jsonopt"
+)
+
+// route is the RESTful route of a method.
+type route struct {
+	httpMethod string
+	// segments of the path, variables being "{name}"
+	segments []string
+	method   string
+	fields   map[string]reflect.Type
+}
+
+// Handler serves the RESTful routes of the methods of a server.
+type Handler struct {
+	server      *rpc.Server
+	contentType string
+	routes      []*route
+}
+
+// NewHandler returns the handler serving the routes declared in the HTTP
+// field of the rpc.MethodInfo of the methods of the server, calling them
+// with the codec registered with the content type. The routes are those
+// of the methods documented before it's called.
+func NewHandler(s *rpc.Server, contentType string) (*Handler, error) {
+	h := &Handler{server: s, contentType: contentType}
+	seen := make(map[string]string)
+	for _, method := range s.Methods() {
+		info, _ := s.MethodInfo(method)
+		if info.HTTP == "" {
+			continue
+		}
+		m, err := s.Router().Resolve(method)
+		if err != nil {
+			return nil, err
+		}
+		rt, err := newRoute(method, info.HTTP, m.ArgsType())
+		if err != nil {
+			return nil, err
+		}
+		key := rt.httpMethod + " " + rt.pattern()
+		if other, ok := seen[key]; ok {
+			return nil, fmt.Errorf("rpc: route %q of %s is already the route of %s", info.HTTP, method, other)
+		}
+		seen[key] = method
+		h.routes = append(h.routes, rt)
+	}
+	// Constant segments are matched before the variables at the same
+	// place, e.g. /users/me before /users/{id}.
+	sort.Stable(byPattern(h.routes))
+	return h, nil
+}
+
+// newRoute parses a route, e.g. "GET /users/{id}", whose variables must
+// be fields of the args.
+func newRoute(method, pattern string, argsType reflect.Type) (*route, error) {
+	parts := strings.Fields(pattern)
+	if len(parts) != 2 || !strings.HasPrefix(parts[1], "/") {
+		return nil, fmt.Errorf("rpc: invalid route %q of %s, expected e.g. \"GET /users/{id}\"", pattern, method)
+	}
+	rt := &route{
+		httpMethod: strings.ToUpper(parts[0]),
+		segments:   strings.Split(strings.Trim(parts[1], "/"), "/"),
+		method:     method,
+		fields:     jsonFields(argsType),
+	}
+	for _, seg := range rt.segments {
+		if name, ok := variable(seg); ok {
+			if _, t := rt.field(name); t == nil {
+				return nil, fmt.Errorf("rpc: variable %q of route %q isn't a field of the args of %s", name, pattern, method)
+			}
+		}
+	}
+	return rt, nil
+}
+
+// variable returns the name of the variable of a segment, and false if
+// it's constant.
+func variable(segment string) (string, bool) {
+	if len(segment) > 2 && segment[0] == '{' && segment[len(segment)-1] == '}' {
+		return segment[1 : len(segment)-1], true
+	}
+	return "", false
+}
+
+// pattern returns the path of the route, its variables replaced with "*"
+// sorting before the letters of constant segments.
+func (rt *route) pattern() string {
+	segments := make([]string, len(rt.segments))
+	for i, seg := range rt.segments {
+		if _, ok := variable(seg); ok {
+			seg = "*"
+		}
+		segments[i] = seg
+	}
+	return "/" + strings.Join(segments, "/")
+}
+
+// byPattern sorts routes by decreasing pattern.
+type byPattern []*route
+
+func (r byPattern) Len() int           { return len(r) }
+func (r byPattern) Less(i, j int) bool { return r[i].pattern() > r[j].pattern() }
+func (r byPattern) Swap(i, j int)      { r[i], r[j] = r[j], r[i] }
+
+// match returns the variables of the path, and false if the route doesn't
+// match it.
+func (rt *route) match(path string) (map[string]string, bool) {
+	segments := strings.Split(strings.Trim(path, "/"), "/")
+	if len(segments) != len(rt.segments) {
+		return nil, false
+	}
+	vars := make(map[string]string)
+	for i, seg := range rt.segments {
+		if name, ok := variable(seg); ok && segments[i] != "" {
+			vars[name] = segments[i]
+		} else if seg != segments[i] {
+			return nil, false
+		}
+	}
+	return vars, true
+}
+
+// field returns the JSON name and the type of the field of the args named
+// name, matched case-insensitively as encoding/json does.
+func (rt *route) field(name string) (string, reflect.Type) {
+	if t, ok := rt.fields[name]; ok {
+		return name, t
+	}
+	for n, t := range rt.fields {
+		if strings.EqualFold(n, name) {
+			return n, t
+		}
+	}
+	return "", nil
+}
+
+// jsonFields returns the types of the fields of a struct by JSON name.
+func jsonFields(t reflect.Type) map[string]reflect.Type {
+	fields := make(map[string]reflect.Type)
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct {
+		return fields
+	}
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.PkgPath != "" && !f.Anonymous {
+			continue
+		}
+		name := f.Tag.Get("json")
+		if i := strings.Index(name, ","); i >= 0 {
+			name = name[:i]
+		}
+		if name == "-" {
+			continue
+		}
+		if f.Anonymous && name == "" {
+			ft := f.Type
+			if ft.Kind() == reflect.Ptr {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Struct {
+				for n, t := range jsonFields(ft) {
+					if _, ok := fields[n]; !ok {
+						fields[n] = t
+					}
+				}
+				continue
+			}
+		}
+		if f.PkgPath != "" {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		fields[name] = f.Type
+	}
+	return fields
+}
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var allowed []string
+	for _, rt := range h.routes {
+		vars, ok := rt.match(r.URL.Path)
+		if !ok {
+			continue
+		}
+		if rt.httpMethod != r.Method {
+			allowed = append(allowed, rt.httpMethod)
+			continue
+		}
+		h.serveRoute(w, r, rt, vars)
+		return
+	}
+	if len(allowed) > 0 {
+		w.Header().Set("Allow", strings.Join(allowed, ", "))
+		rpc.WriteError(w, http.StatusMethodNotAllowed, "rpc: method "+r.Method+" not allowed")
+		return
+	}
+	http.NotFound(w, r)
+}
+
+// serveRoute calls the method of the route with the params of the
+// request.
+func (h *Handler) serveRoute(w http.ResponseWriter, r *http.Request, rt *route, vars map[string]string) {
+	params, err := rt.params(r, vars)
+	if err != nil {
+		rpc.WriteError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	ctx := rpc.WithRoute(r.Context(), &rpc.Route{
+		Method:      rt.method,
+		ContentType: h.contentType,
+		Vars:        vars,
+		Bare:        true,
+	})
+	call := r.WithContext(ctx)
+	call.Method = "POST"
+	call.Body = ioutil.NopCloser(bytes.NewReader(params))
+	call.ContentLength = int64(len(params))
+	call.Header = make(http.Header, len(r.Header))
+	for k, v := range r.Header {
+		call.Header[k] = v
+	}
+	call.Header.Set("Content-Type", h.contentType)
+	h.server.ServeHTTP(w, call)
+}
+
+// params returns the JSON params of a request: its body, with the
+// variables and the query params added.
+func (rt *route) params(r *http.Request, vars map[string]string) ([]byte, error) {
+	body, err := ioutil.ReadAll(r.Body)
+	r.Body.Close()
+	if err != nil {
+		return nil, fmt.Errorf("rpc: reading body: %v", err)
+	}
+	query := r.URL.Query()
+	if len(vars) == 0 && len(query) == 0 {
+		return body, nil
+	}
+	params := make(map[string]json.RawMessage)
+	if len(bytes.TrimSpace(body)) > 0 {
+		if err := json.Unmarshal(body, &params); err != nil {
+			return nil, fmt.Errorf("rpc: the body must be a JSON object: %v", err)
+		}
+	}
+	for name, values := range query {
+		if name == jsonopt.FieldsParam {
+			// Sparse fieldsets, e.g. ?_fields=id,name.
+			fields, _ := json.Marshal(strings.Split(strings.Join(values, ","), ","))
+			params[name] = fields
+			continue
+		}
+		field, t := rt.field(name)
+		if t == nil {
+			return nil, fmt.Errorf("rpc: unknown query param %q", name)
+		}
+		params[field] = encodeParam(values, t)
+	}
+	for name, value := range vars {
+		field, t := rt.field(name)
+		params[field] = encodeParam([]string{value}, t)
+	}
+	return json.Marshal(params)
+}
+
+// jsonNumber matches the JSON numbers.
+var jsonNumber = regexp.MustCompile(`^-?(0|[1-9][0-9]*)(\.[0-9]+)?([eE][+-]?[0-9]+)?$`)
+
+// encodeParam returns the JSON value of a param for a field of type t:
+// numbers and booleans are kept as is when valid, other values are strings
+// decoded by the codec, e.g. as UUIDs or times. Slices take all the
+// values.
+func encodeParam(values []string, t reflect.Type) json.RawMessage {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t.Kind() == reflect.Slice && t.Elem().Kind() != reflect.Uint8 {
+		items := make([]json.RawMessage, len(values))
+		for i, v := range values {
+			items[i] = encodeParam([]string{v}, t.Elem())
+		}
+		b, _ := json.Marshal(items)
+		return b
+	}
+	v := values[len(values)-1]
+	switch t.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		if jsonNumber.MatchString(v) {
+			return json.RawMessage(v)
+		}
+	case reflect.Bool:
+		if v == "true" || v == "false" {
+			return json.RawMessage(v)
+		}
+	}
+	b, _ := json.Marshal(v)
+	return b
+}
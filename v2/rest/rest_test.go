@@ -0,0 +1,141 @@
+// Copyright 2009 The Go Authors. All rights reserved.
+// Copyright 2012 The Gorilla Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package rest
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/rpc/v2"
+	"github.com/gorilla/rpc/v2/json2"
+)
+
+type User struct {
+	ID   int    `json:"id"`
+	Name string `json:"name"`
+}
+
+type GetArgs struct {
+	ID int `json:"id"`
+}
+
+type ListArgs struct {
+	Limit  int
+	Tags   []string `json:"tag"`
+	Active *bool    `json:"active"`
+}
+
+type UpdateArgs struct {
+	GetArgs
+	Name string `json:"name"`
+}
+
+type UserService struct{}
+
+func (UserService) Get(r *http.Request, args *GetArgs, reply *User) error {
+	if args.ID != 42 {
+		return &json2.Error{Code: 404, Message: "no user"}
+	}
+	*reply = User{ID: 42, Name: "Ada"}
+	return nil
+}
+
+func (UserService) Me(r *http.Request, args *struct{}, reply *User) error {
+	*reply = User{ID: 1, Name: "me"}
+	return nil
+}
+
+func (UserService) List(r *http.Request, args *ListArgs, reply *[]string) error {
+	active := "nil"
+	if args.Active != nil && *args.Active {
+		active = "true"
+	}
+	*reply = append([]string{active}, args.Tags...)
+	for i := 0; i < args.Limit; i++ {
+		*reply = append(*reply, "user")
+	}
+	return nil
+}
+
+func (UserService) Update(r *http.Request, args *UpdateArgs, reply *User) error {
+	if rpc.RouteVar(r, "id") == "" {
+		return errors.New("no route")
+	}
+	*reply = User{ID: args.ID, Name: args.Name}
+	return nil
+}
+
+func newHandler(t *testing.T) *Handler {
+	s := rpc.NewServer()
+	s.RegisterCodec(json2.NewCodec(), "application/json")
+	s.RegisterService(new(UserService), "")
+	for method, route := range map[string]string{
+		"UserService.Get":    "GET /users/{id}",
+		"UserService.Me":     "GET /users/me",
+		"UserService.List":   "GET /users",
+		"UserService.Update": "PUT /users/{id}",
+	} {
+		if err := s.RegisterMethodInfo(method, rpc.MethodInfo{HTTP: route}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	h, err := NewHandler(s, "application/json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return h
+}
+
+func TestHandler(t *testing.T) {
+	h := newHandler(t)
+	for _, test := range []struct {
+		method, url, body string
+		status            int
+		expected          string
+	}{
+		{"GET", "/users/42", "", 200, `{"id":42,"name":"Ada"}`},
+		{"GET", "/users/me", "", 200, `{"id":1,"name":"me"}`},
+		{"GET", "/users/7", "", 400, `{"code":404,"message":"no user","data":null}`},
+		{"GET", "/users/x", "", 400, ""},
+		{"GET", "/users?limit=2&tag=a&tag=b&active=true", "", 200, `["true","a","b","user","user"]`},
+		{"GET", "/users?Limit=1", "", 200, `["nil","user"]`},
+		{"GET", "/users", "", 200, `["nil"]`},
+		{"GET", "/users?other=1", "", 400, ""},
+		{"GET", "/users/42?_fields=name", "", 200, `{"name":"Ada"}`},
+		{"PUT", "/users/42", `{"name":"Bob","id":1}`, 200, `{"id":42,"name":"Bob"}`},
+		{"PUT", "/users/42", `[1]`, 400, ""},
+		{"DELETE", "/users/42", "", 405, ""},
+		{"GET", "/groups", "", 404, ""},
+	} {
+		r := httptest.NewRequest(test.method, test.url, strings.NewReader(test.body))
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		body := strings.TrimSpace(w.Body.String())
+		if w.Code != test.status || (test.expected != "" && body != test.expected) {
+			t.Errorf("Expected %d %s for %s %s, got %d %s", test.status, test.expected, test.method, test.url, w.Code, body)
+		}
+	}
+}
+
+func TestNewHandlerErrors(t *testing.T) {
+	for _, routes := range []map[string]string{
+		{"UserService.Get": "/users/{id}"},
+		{"UserService.Get": "GET /users/{name}"},
+		{"UserService.Get": "GET /users/{id}", "UserService.Update": "GET /users/{id}"},
+	} {
+		s := rpc.NewServer()
+		s.RegisterService(new(UserService), "")
+		for method, route := range routes {
+			s.RegisterMethodInfo(method, rpc.MethodInfo{HTTP: route})
+		}
+		if _, err := NewHandler(s, "application/json"); err == nil {
+			t.Errorf("Expected an error for %v", routes)
+		}
+	}
+}
@@ -0,0 +1,79 @@
+// Copyright 2009 The Go Authors. All rights reserved.
+// Copyright 2012 The Gorilla Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package rpc
+
+import (
+	"context"
+	"errors"
+	"net/http"
+)
+
+// ErrRouteMethod is returned for requests whose body calls another method
+// than the one of their route.
+var ErrRouteMethod = errors.New("rpc: method doesn't match the route")
+
+// Route is the route of a router, e.g. gorilla/mux or chi, through which
+// a request reached the server, see WithRoute. The mount package binds
+// the routes of the common routers.
+type Route struct {
+	// Method, if set, is the method called by the requests of the route,
+	// e.g. "Users.Get" for /rpc/Users/Get. The method of their body, if
+	// any, must be the same.
+	Method string
+	// ContentType, if set, selects the codec registered with this content
+	// type for the requests of the route, whatever their "Content-Type"
+	// header.
+	ContentType string
+	// Vars are the variables of the route, e.g. {"tenant": "acme"} for
+	// /tenants/{tenant}/rpc.
+	Vars map[string]string
+	// Bare, if set with Method, has the requests of the route carry only
+	// the params of the method, and the responses only its result or
+	// error, with the codecs implementing PathCodec.
+	Bare bool
+}
+
+type routeKey struct{}
+
+// WithRoute returns a context carrying the route of a request, to be set
+// by routers before calling the server.
+func WithRoute(ctx context.Context, route *Route) context.Context {
+	return context.WithValue(ctx, routeKey{}, route)
+}
+
+// RouteFrom returns the route carried by the context, and false if
+// there's none.
+func RouteFrom(ctx context.Context) (*Route, bool) {
+	route, ok := ctx.Value(routeKey{}).(*Route)
+	return route, ok && route != nil
+}
+
+// RouteVar returns the variable of the route of a request, or an empty
+// string, so that methods read them whatever the router, e.g.:
+//
+//	func (s *Users) List(r *http.Request, args *ListArgs, reply *ListReply) error {
+//		tenant := rpc.RouteVar(r, "tenant")
+//		...
+//	}
+func RouteVar(r *http.Request, name string) string {
+	if route, ok := RouteFrom(r.Context()); ok {
+		return route.Vars[name]
+	}
+	return ""
+}
+
+// routeMethod returns the method of a call, from its route if it has one.
+func routeMethod(r *http.Request, codecReq CodecRequest) (string, error) {
+	method, err := codecReq.Method()
+	route, ok := RouteFrom(r.Context())
+	if err != nil || !ok || route.Method == "" {
+		return method, err
+	}
+	if method != "" && method != route.Method {
+		return "", ErrRouteMethod
+	}
+	return route.Method, nil
+}
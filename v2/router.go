@@ -0,0 +1,38 @@
+// Copyright 2009 The Go Authors. All rights reserved.
+// Copyright 2012 The Gorilla Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package rpc
+
+// Router resolves an RPC method name to the ServiceMethod that handles it.
+//
+// By default the server resolves methods using the services added with
+// RegisterService. A custom Router can be registered to look up methods
+// elsewhere, e.g. methods stored in a database, pattern-matched names or
+// catch-all handlers.
+type Router interface {
+	// Resolve returns the method to be called for the given method name.
+	// An error is returned to the client if the method can't be resolved.
+	Resolve(method string) (*ServiceMethod, error)
+}
+
+// RegisterRouter replaces the router used to resolve method names.
+//
+// The router returned by ServiceRouter can be used by custom routers to
+// fall back to the registered services.
+func (s *Server) RegisterRouter(r Router) {
+	s.router = r
+}
+
+// Router returns the router used to resolve method names, so that a custom
+// router can wrap it.
+func (s *Server) Router() Router {
+	return s.router
+}
+
+// ServiceRouter returns the router resolving the methods of the services
+// added with RegisterService.
+func (s *Server) ServiceRouter() Router {
+	return s.services
+}
@@ -0,0 +1,64 @@
+// Copyright 2009 The Go Authors. All rights reserved.
+// Copyright 2012 The Gorilla Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package rpc
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// prefixRouter resolves any method ending in ".Multiply" to Service1.Multiply
+// and falls back to the registered services otherwise.
+type prefixRouter struct {
+	fallback Router
+}
+
+func (r *prefixRouter) Resolve(method string) (*ServiceMethod, error) {
+	if strings.HasSuffix(method, ".Multiply") {
+		return NewServiceMethod(new(Service1), "Multiply")
+	}
+	return r.fallback.Resolve(method)
+}
+
+func TestNewServiceMethod(t *testing.T) {
+	if _, err := NewServiceMethod(new(Service1), "Multiply"); err != nil {
+		t.Errorf("Expected Service1.Multiply to be suitable, got %v", err)
+	}
+	if _, err := NewServiceMethod(new(Service1), "Divide"); err == nil {
+		t.Errorf("Expected error for missing method")
+	}
+}
+
+func TestRegisterRouter(t *testing.T) {
+	s := NewServer()
+	s.RegisterCodec(MockCodec{2, 3}, "mock")
+	s.RegisterService(new(Service1), "")
+	s.RegisterRouter(&prefixRouter{fallback: s.ServiceRouter()})
+
+	for _, method := range []string{"Any.Multiply", "Service1.MultiplyWithHeaders"} {
+		if !s.HasMethod(method) {
+			t.Errorf("Expected to be resolved: %s", method)
+		}
+		r, err := http.NewRequest("POST", method, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		r.Header.Set("Content-Type", "mock")
+		w := NewMockResponseWriter()
+		s.ServeHTTP(w, r)
+		if w.Status != 200 {
+			t.Errorf("Status was %d, should be 200.", w.Status)
+		}
+		if w.Body != strconv.Itoa(6) {
+			t.Errorf("Response body was %s, should be 6.", w.Body)
+		}
+	}
+	if s.HasMethod("Service1.Divide") {
+		t.Errorf("Expected not to be resolved: Service1.Divide")
+	}
+}
@@ -0,0 +1,28 @@
+// Copyright 2009 The Go Authors. All rights reserved.
+// Copyright 2012 The Gorilla Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+/*
+Package gorilla/rpc/v2/rpcctx gathers the typed accessors of the values
+carried by the context of calls, so that methods and middlewares don't need
+their own keys:
+
+	func (s *Orders) Create(r *http.Request, args *CreateArgs, reply *Order) error {
+		ctx := r.Context()
+		caller, ok := rpcctx.Caller(ctx)
+		if !ok {
+			return errUnauthenticated
+		}
+		rpcctx.Logger(ctx).Printf("request %s: order created by %s", rpcctx.RequestID(ctx), caller)
+		...
+	}
+
+Getters of optional values return false when the value is missing, rather
+than a nil interface. Values set by the server and the transports, e.g. the
+session or the RequestInfo of the call, are read with the accessors of the
+rpc package; rpcctx returns the same values. The caller identity and the
+logger are only set with rpcctx, typically by a middleware after
+authenticating the request.
+*/
+package rpcctx
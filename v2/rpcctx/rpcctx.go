@@ -0,0 +1,121 @@
+// Copyright 2009 The Go Authors. All rights reserved.
+// Copyright 2012 The Gorilla Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package rpcctx
+
+import (
+	"context"
+	"log"
+	"os"
+
+	"github.com/gorilla/rpc/v2"
+)
+
+// DefaultLogger is returned by Logger for contexts without a logger.
+var DefaultLogger = log.New(os.Stderr, "", log.LstdFlags)
+
+type callerKey struct{}
+
+type loggerKey struct{}
+
+// WithCaller returns a copy of ctx carrying the identity of the caller,
+// e.g. the user or the API key authenticated by a middleware.
+func WithCaller(ctx context.Context, caller string) context.Context {
+	return context.WithValue(ctx, callerKey{}, caller)
+}
+
+// Caller returns the identity of the caller, and false if it's unknown.
+func Caller(ctx context.Context) (string, bool) {
+	caller, ok := ctx.Value(callerKey{}).(string)
+	return caller, ok && caller != ""
+}
+
+// WithLogger returns a copy of ctx carrying the logger of the call, e.g.
+// with a prefix identifying the request.
+func WithLogger(ctx context.Context, logger *log.Logger) context.Context {
+	return context.WithValue(ctx, loggerKey{}, logger)
+}
+
+// Logger returns the logger of the call, or DefaultLogger. It's never nil.
+func Logger(ctx context.Context) *log.Logger {
+	if logger, ok := ctx.Value(loggerKey{}).(*log.Logger); ok && logger != nil {
+		return logger
+	}
+	return DefaultLogger
+}
+
+// WithRequestID returns a copy of ctx carrying the id of the request, see
+// rpc.WithRequestID.
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return rpc.WithRequestID(ctx, id)
+}
+
+// RequestID returns the id of the request, received in the
+// rpc.RequestIDHeader or set by WithRequestID, or an empty string.
+func RequestID(ctx context.Context) string {
+	return rpc.RequestIDFromContext(ctx)
+}
+
+// WithSession returns a copy of ctx carrying the session, see
+// rpc.NewSessionContext.
+func WithSession(ctx context.Context, session *rpc.Session) context.Context {
+	return rpc.NewSessionContext(ctx, session)
+}
+
+// Session returns the session of a call received by a stateful transport,
+// and false for other calls.
+func Session(ctx context.Context) (*rpc.Session, bool) {
+	session := rpc.SessionFromContext(ctx)
+	return session, session != nil
+}
+
+// WithTenant returns a copy of ctx carrying the tenant, see rpc.WithTenant.
+func WithTenant(ctx context.Context, tenant string) context.Context {
+	return rpc.WithTenant(ctx, tenant)
+}
+
+// Tenant returns the tenant of the call, and false if there's none.
+func Tenant(ctx context.Context) (string, bool) {
+	return rpc.TenantFrom(ctx)
+}
+
+// WithPriority returns a copy of ctx carrying the priority, see
+// rpc.WithPriority.
+func WithPriority(ctx context.Context, priority string) context.Context {
+	return rpc.WithPriority(ctx, priority)
+}
+
+// Priority returns the priority of the call, and false if there's none.
+func Priority(ctx context.Context) (string, bool) {
+	priority := rpc.PriorityFromContext(ctx)
+	return priority, priority != ""
+}
+
+// IdempotencyKey returns the idempotency key of the call, and false if
+// there's none.
+func IdempotencyKey(ctx context.Context) (string, bool) {
+	key := rpc.IdempotencyKeyFromContext(ctx)
+	return key, key != ""
+}
+
+// ResponseMeta returns the meta of the response of the call, and false if
+// ctx isn't the context of a call.
+func ResponseMeta(ctx context.Context) (*rpc.ResponseMeta, bool) {
+	meta := rpc.ResponseMetaFromContext(ctx)
+	return meta, meta != nil
+}
+
+// WithRequestInfo returns a copy of ctx carrying the info of a call, see
+// rpc.NewRequestInfoContext.
+func WithRequestInfo(ctx context.Context, info *rpc.RequestInfo) context.Context {
+	return rpc.NewRequestInfoContext(ctx, info)
+}
+
+// RequestInfo returns the info of the call, with its method, remote
+// address and codec, and false if ctx isn't the context of a call.
+func RequestInfo(ctx context.Context) (*rpc.RequestInfo, bool) {
+	info := rpc.RequestInfoFromContext(ctx)
+	return info, info != nil
+}
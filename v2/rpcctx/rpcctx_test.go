@@ -0,0 +1,60 @@
+// Copyright 2009 The Go Authors. All rights reserved.
+// Copyright 2012 The Gorilla Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package rpcctx
+
+import (
+	"bytes"
+	"context"
+	"log"
+	"net/http"
+	"testing"
+
+	"github.com/gorilla/rpc/v2"
+)
+
+func TestAccessors(t *testing.T) {
+	ctx := context.Background()
+	if _, ok := Caller(ctx); ok {
+		t.Error("Expected no caller")
+	}
+	if _, ok := Session(ctx); ok {
+		t.Error("Expected no session")
+	}
+	if Logger(ctx) != DefaultLogger || RequestID(ctx) != "" {
+		t.Error("Expected the default logger and no request id")
+	}
+
+	var buf bytes.Buffer
+	ctx = WithLogger(ctx, log.New(&buf, "", 0))
+	ctx = WithCaller(ctx, "bob")
+	ctx = WithRequestID(ctx, "42")
+	ctx = WithTenant(ctx, "acme")
+	ctx = WithPriority(ctx, "high")
+	if caller, ok := Caller(ctx); !ok || caller != "bob" {
+		t.Errorf("Wrong caller %q", caller)
+	}
+	if tenant, ok := Tenant(ctx); !ok || tenant != "acme" {
+		t.Errorf("Wrong tenant %q", tenant)
+	}
+	if priority, ok := Priority(ctx); !ok || priority != "high" {
+		t.Errorf("Wrong priority %q", priority)
+	}
+	Logger(ctx).Print("hello")
+	if buf.String() != "hello\n" {
+		t.Errorf("Wrong log %q", buf.String())
+	}
+
+	// The request id is propagated with the priority.
+	h := make(http.Header)
+	rpc.Propagate(ctx, h)
+	if h.Get(rpc.RequestIDHeader) != "42" || h.Get(rpc.PriorityHeader) != "high" {
+		t.Errorf("Wrong propagated headers %v", h)
+	}
+	received := rpc.WithPropagation(context.Background(), h)
+	if RequestID(received) != "42" {
+		t.Errorf("Expected the received request id, got %q", RequestID(received))
+	}
+}
@@ -0,0 +1,126 @@
+// Copyright 2009 The Go Authors. All rights reserved.
+// Copyright 2012 The Gorilla Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package rpctest
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/gorilla/rpc/v2"
+)
+
+// Bench benchmarks the stages of the calls of a server with a canned
+// request, so that regressions of the dispatch path are caught before a
+// release:
+//
+//	func BenchmarkUsersGet(b *testing.B) {
+//		rpctest.Bench{
+//			Server:      newServer(),
+//			Codec:       json2.NewCodec(),
+//			ContentType: "application/json",
+//			Body:        []byte(`{"jsonrpc":"2.0","method":"Users.Get","params":{"ID":42},"id":1}`),
+//		}.Run(b)
+//	}
+//
+// Comparing the results of releases, e.g. with benchstat, shows which
+// stage regressed.
+type Bench struct {
+	// Server serves the calls; the codec must be registered for the
+	// content type.
+	Server *rpc.Server
+	// Codec decodes and encodes the calls in the stage benchmarks.
+	Codec rpc.Codec
+	// ContentType is the content type of the requests.
+	ContentType string
+	// Body is the encoded request of a call.
+	Body []byte
+}
+
+// Run runs a sub-benchmark for each stage of the calls, reporting their
+// ns/op and allocs/op:
+//
+//	route   the codec reads the method name and the router resolves it
+//	decode  the codec decodes the args
+//	invoke  the method is called
+//	encode  the codec encodes the reply
+//	serve   the whole call, through the hooks and middlewares of the server
+//
+// It fails if the call doesn't succeed with the 200 status.
+func (bench Bench) Run(b *testing.B) {
+	if h, ok := interface{}(b).(interface{ Helper() }); ok {
+		// Helper needs Go 1.9.
+		h.Helper()
+	}
+	w := httptest.NewRecorder()
+	bench.Server.ServeHTTP(w, bench.request())
+	if w.Code != http.StatusOK {
+		b.Fatalf("Call failed with status %d: %s", w.Code, w.Body)
+	}
+	r := bench.request()
+	codecReq := bench.Codec.NewRequest(r)
+	name, err := codecReq.Method()
+	if err != nil {
+		b.Fatal(err)
+	}
+	method, err := bench.Server.Router().Resolve(name)
+	if err != nil {
+		b.Fatal(err)
+	}
+	args := reflect.New(method.ArgsType()).Interface()
+	if err := codecReq.ReadRequest(args); err != nil {
+		b.Fatal(err)
+	}
+	reply := reflect.New(method.ReplyType()).Interface()
+	if err := method.Invoke(w, r, args, reply); err != nil {
+		b.Fatal(err)
+	}
+
+	b.Run("route", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			r.Body = ioutil.NopCloser(bytes.NewReader(bench.Body))
+			name, _ := bench.Codec.NewRequest(r).Method()
+			bench.Server.Router().Resolve(name)
+		}
+	})
+	b.Run("decode", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			codecReq.ReadRequest(reflect.New(method.ArgsType()).Interface())
+		}
+	})
+	b.Run("invoke", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			method.Invoke(w, r, args, reflect.New(method.ReplyType()).Interface())
+		}
+	})
+	b.Run("encode", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			w.Body.Reset()
+			codecReq.WriteResponse(w, reply)
+		}
+	})
+	b.Run("serve", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			w.Body.Reset()
+			bench.Server.ServeHTTP(w, bench.request())
+		}
+	})
+}
+
+// request returns a request of the call.
+func (bench Bench) request() *http.Request {
+	r := httptest.NewRequest("POST", "/", bytes.NewReader(bench.Body))
+	r.Header.Set("Content-Type", bench.ContentType)
+	return r
+}
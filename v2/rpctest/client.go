@@ -0,0 +1,156 @@
+// Copyright 2009 The Go Authors. All rights reserved.
+// Copyright 2012 The Gorilla Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package rpctest
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sync"
+	"testing"
+)
+
+// Call is a call recorded by a MockClient.
+type Call struct {
+	Method string
+	Args   interface{}
+}
+
+// HandlerFunc returns the reply of a call of a MockClient for its args.
+type HandlerFunc func(ctx context.Context, args interface{}) (interface{}, error)
+
+// MockClient is an rpc.Caller answering the calls with programmed replies
+// and errors, and recording them. Its methods are safe for concurrent use.
+type MockClient struct {
+	mutex    sync.Mutex
+	handlers map[string]HandlerFunc
+	calls    []Call
+}
+
+// NewMockClient returns a MockClient without programmed methods.
+func NewMockClient() *MockClient {
+	return &MockClient{handlers: make(map[string]HandlerFunc)}
+}
+
+// Handle programs the method to be answered by f.
+func (m *MockClient) Handle(method string, f HandlerFunc) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.handlers[method] = f
+}
+
+// Return programs the method to reply with reply.
+func (m *MockClient) Return(method string, reply interface{}) {
+	m.Handle(method, func(context.Context, interface{}) (interface{}, error) {
+		return reply, nil
+	})
+}
+
+// Fail programs the method to fail with err.
+func (m *MockClient) Fail(method string, err error) {
+	m.Handle(method, func(context.Context, interface{}) (interface{}, error) {
+		return nil, err
+	})
+}
+
+// Call records the call and answers it with the programmed method. The
+// programmed reply is copied into reply, through JSON if their types
+// differ. Calls of methods that aren't programmed fail.
+func (m *MockClient) Call(ctx context.Context, method string, args, reply interface{}) error {
+	m.mutex.Lock()
+	m.calls = append(m.calls, Call{Method: method, Args: args})
+	f, ok := m.handlers[method]
+	m.mutex.Unlock()
+	if !ok {
+		return fmt.Errorf("rpc: unexpected call of %q", method)
+	}
+	res, err := f(ctx, args)
+	if err != nil {
+		return err
+	}
+	return copyValue(reply, res)
+}
+
+// Calls returns the recorded calls of the method, or of all the methods
+// if method is empty.
+func (m *MockClient) Calls(method string) []Call {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	var calls []Call
+	for _, c := range m.calls {
+		if method == "" || c.Method == method {
+			calls = append(calls, c)
+		}
+	}
+	return calls
+}
+
+// AssertCalled reports an error to t unless the method was called the
+// given number of times.
+func (m *MockClient) AssertCalled(t testing.TB, method string, times int) {
+	if h, ok := t.(interface{ Helper() }); ok {
+		// Helper needs Go 1.9.
+		h.Helper()
+	}
+	if n := len(m.Calls(method)); n != times {
+		t.Errorf("Expected %d calls of %s, got %d", times, method, n)
+	}
+}
+
+// AssertCalledWith reports an error to t unless the method was called
+// with args, compared through JSON.
+func (m *MockClient) AssertCalledWith(t testing.TB, method string, args interface{}) {
+	if h, ok := t.(interface{ Helper() }); ok {
+		// Helper needs Go 1.9.
+		h.Helper()
+	}
+	want, err := json.Marshal(args)
+	if err != nil {
+		t.Fatal(err)
+	}
+	calls := m.Calls(method)
+	for _, c := range calls {
+		if got, err := json.Marshal(c.Args); err == nil && string(got) == string(want) {
+			return
+		}
+	}
+	t.Errorf("Expected a call of %s with %s, got %d other calls", method, want, len(calls))
+}
+
+// Reset forgets the recorded calls.
+func (m *MockClient) Reset() {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.calls = nil
+}
+
+// copyValue copies the value of src into the pointer dst: directly if
+// they have the same type, or else through JSON.
+func copyValue(dst, src interface{}) error {
+	if dst == nil || src == nil {
+		return nil
+	}
+	dv, sv := reflect.ValueOf(dst), reflect.ValueOf(src)
+	if dv.Kind() != reflect.Ptr || dv.IsNil() {
+		return fmt.Errorf("rpc: reply must be a non-nil pointer, got %T", dst)
+	}
+	if sv.Type() == dv.Type() {
+		if !sv.IsNil() {
+			dv.Elem().Set(sv.Elem())
+		}
+		return nil
+	}
+	if sv.Type() == dv.Type().Elem() {
+		dv.Elem().Set(sv)
+		return nil
+	}
+	b, err := json.Marshal(src)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(b, dst)
+}
@@ -0,0 +1,38 @@
+// Copyright 2009 The Go Authors. All rights reserved.
+// Copyright 2012 The Gorilla Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+/*
+Package gorilla/rpc/v2/rpctest provides mocks to test code calling or
+serving RPC methods, without HTTP servers or real codecs.
+
+A MockClient is an rpc.Caller with programmed replies, recording the calls
+so that tests can check them:
+
+	client := rpctest.NewMockClient()
+	client.Return("Users.Get", &User{Name: "bob"})
+	client.Fail("Users.Delete", errors.New("forbidden"))
+
+	svc := NewProfileService(client) // takes an rpc.Caller
+	...
+	client.AssertCalled(t, "Users.Get", 1)
+
+A MockServer calls the methods of services through an rpc.Server, with its
+hooks and middlewares, passing the args and replies as Go values:
+
+	s := rpctest.NewMockServer().
+		Register(new(Users), "Users").
+		Use(authMiddleware)
+
+	var user User
+	err := s.Call(ctx, "Users.Get", &GetArgs{ID: 42}, &user)
+
+AssertGolden compares the exact responses of a server, through its real
+codecs, with golden files, to catch changes of the envelopes; the files are
+written by running the tests with the -rpctest.update flag.
+
+Bench benchmarks the stages of the calls of a server: routing, decoding
+the args, invoking the method and encoding the reply.
+*/
+package rpctest
@@ -0,0 +1,71 @@
+// Copyright 2009 The Go Authors. All rights reserved.
+// Copyright 2012 The Gorilla Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package rpctest
+
+import (
+	"bytes"
+	"flag"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// Update makes AssertGolden write the golden files instead of comparing
+// them. It's set by the -rpctest.update flag of the tests:
+//
+//	go test ./... -rpctest.update
+var Update = flag.Bool("rpctest.update", false, "write the golden files of rpctest.AssertGolden")
+
+// ServeRequest posts the encoded request body with the content type to
+// the handler, usually an rpc.Server, and returns the recorded response.
+func ServeRequest(h http.Handler, contentType string, body []byte) *httptest.ResponseRecorder {
+	r := httptest.NewRequest("POST", "/", bytes.NewReader(body))
+	r.Header.Set("Content-Type", contentType)
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	return w
+}
+
+// AssertGolden posts the encoded request body to the handler, through the
+// codec registered for the content type, and compares the exact bytes of
+// the response body with the golden file at path, e.g.
+// "testdata/users_get.golden":
+//
+//	rpctest.AssertGolden(t, s, "application/json",
+//		[]byte(`{"jsonrpc":"2.0","method":"Users.Get","params":{"ID":42},"id":1}`),
+//		"testdata/users_get.golden")
+//
+// Mismatches are reported to t with both bodies. With Update set, the
+// golden file is written instead. The response is returned for further
+// checks, e.g. of its status or headers.
+func AssertGolden(t testing.TB, h http.Handler, contentType string, body []byte, path string) *httptest.ResponseRecorder {
+	if h, ok := t.(interface{ Helper() }); ok {
+		// Helper needs Go 1.9.
+		h.Helper()
+	}
+	w := ServeRequest(h, contentType, body)
+	got := w.Body.Bytes()
+	if *Update {
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, got, 0644); err != nil {
+			t.Fatal(err)
+		}
+		return w
+	}
+	want, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Reading golden file: %v (run with -rpctest.update to create it)", err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("Response doesn't match %s:\ngot:  %s\nwant: %s", path, got, want)
+	}
+	return w
+}
@@ -0,0 +1,156 @@
+// Copyright 2009 The Go Authors. All rights reserved.
+// Copyright 2012 The Gorilla Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package rpctest
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/gorilla/rpc/v2"
+	"github.com/gorilla/rpc/v2/json2"
+)
+
+type User struct {
+	ID   int
+	Name string
+}
+
+type GetArgs struct {
+	ID int
+}
+
+var errNotFound = errors.New("not found")
+
+type Users struct{}
+
+func (Users) Get(r *http.Request, args *GetArgs, reply *User) error {
+	if args.ID != 42 {
+		return errNotFound
+	}
+	*reply = User{ID: 42, Name: r.Header.Get("X-Name")}
+	return nil
+}
+
+func TestMockClient(t *testing.T) {
+	c := NewMockClient()
+	c.Return("Users.Get", &User{ID: 42, Name: "bob"})
+	c.Fail("Users.Delete", errNotFound)
+
+	var user User
+	if err := c.Call(context.Background(), "Users.Get", &GetArgs{ID: 42}, &user); err != nil || user.Name != "bob" {
+		t.Errorf("Expected bob, got %v %v", user, err)
+	}
+	var generic map[string]interface{}
+	if err := c.Call(context.Background(), "Users.Get", &GetArgs{ID: 42}, &generic); err != nil || generic["Name"] != "bob" {
+		t.Errorf("Expected bob through JSON, got %v %v", generic, err)
+	}
+	if err := c.Call(context.Background(), "Users.Delete", &GetArgs{ID: 42}, nil); err != errNotFound {
+		t.Errorf("Expected errNotFound, got %v", err)
+	}
+	if err := c.Call(context.Background(), "Users.List", nil, nil); err == nil {
+		t.Error("Expected an error for an unexpected call")
+	}
+	c.AssertCalled(t, "Users.Get", 2)
+	c.AssertCalledWith(t, "Users.Delete", GetArgs{ID: 42})
+	if calls := c.Calls(""); len(calls) != 4 {
+		t.Errorf("Expected 4 calls, got %v", calls)
+	}
+}
+
+func TestMockServer(t *testing.T) {
+	var called []string
+	s := NewMockServer().
+		Register(new(Users), "Users").
+		Header("X-Name", "bob").
+		Use(func(next rpc.CallFunc) rpc.CallFunc {
+			return func(r *http.Request, method string, args, reply interface{}) error {
+				called = append(called, method)
+				return next(r, method, args, reply)
+			}
+		})
+	if err := s.Err(); err != nil {
+		t.Fatal(err)
+	}
+
+	var user User
+	if err := s.Call(context.Background(), "Users.Get", &GetArgs{ID: 42}, &user); err != nil || user.Name != "bob" {
+		t.Errorf("Expected bob, got %v %v", user, err)
+	}
+	if err := s.Call(context.Background(), "Users.Get", map[string]int{"ID": 42}, &user); err != nil || user.ID != 42 {
+		t.Errorf("Expected args through JSON, got %v %v", user, err)
+	}
+	if err := s.Call(context.Background(), "Users.Get", &GetArgs{ID: 1}, &user); err != errNotFound {
+		t.Errorf("Expected errNotFound, got %v", err)
+	}
+	if err := s.Call(context.Background(), "Users.Remove", &GetArgs{}, &user); err == nil {
+		t.Error("Expected an error for an unknown method")
+	}
+	if len(called) != 3 {
+		t.Errorf("Expected the middleware to be called 3 times, got %v", called)
+	}
+}
+
+// failTB records the failures of a test.
+type failTB struct {
+	testing.TB
+	failed bool
+}
+
+func (t *failTB) Errorf(format string, args ...interface{}) {
+	t.failed = true
+}
+
+func TestAssertGolden(t *testing.T) {
+	s := rpc.NewServer()
+	s.RegisterCodec(json2.NewCodec(), "application/json")
+	s.RegisterService(new(Users), "Users")
+
+	w := AssertGolden(t, s, "application/json",
+		[]byte(`{"jsonrpc":"2.0","method":"Users.Get","params":{"ID":42},"id":1}`),
+		"testdata/users_get.golden")
+	if w.Code != http.StatusOK {
+		t.Errorf("Expected status 200, got %d", w.Code)
+	}
+
+	tb := &failTB{TB: t}
+	AssertGolden(tb, s, "application/json",
+		[]byte(`{"jsonrpc":"2.0","method":"Users.Get","params":{"ID":42},"id":2}`),
+		"testdata/users_get.golden")
+	if !tb.failed {
+		t.Error("Expected a mismatch for another id")
+	}
+}
+
+func newJSONServer() *rpc.Server {
+	s := rpc.NewServer()
+	s.RegisterCodec(json2.NewCodec(), "application/json")
+	s.RegisterService(new(Users), "Users")
+	return s
+}
+
+func BenchmarkUsersGet(b *testing.B) {
+	Bench{
+		Server:      newJSONServer(),
+		Codec:       json2.NewCodec(),
+		ContentType: "application/json",
+		Body:        []byte(`{"jsonrpc":"2.0","method":"Users.Get","params":{"ID":42},"id":1}`),
+	}.Run(b)
+}
+
+func TestInvoke(t *testing.T) {
+	m, err := newJSONServer().Router().Resolve("Users.Get")
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, _ := http.NewRequest("POST", "/", nil)
+	r.Header.Set("X-Name", "bob")
+	var user User
+	if err := m.Invoke(nil, r, &GetArgs{ID: 42}, &user); err != nil || user.Name != "bob" {
+		t.Errorf("Expected bob, got %v %v", user, err)
+	}
+}
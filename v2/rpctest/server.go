@@ -0,0 +1,127 @@
+// Copyright 2009 The Go Authors. All rights reserved.
+// Copyright 2012 The Gorilla Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package rpctest
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+
+	"github.com/gorilla/rpc/v2"
+)
+
+// ContentType is the content type of the calls of a MockServer.
+const ContentType = "application/x-rpctest"
+
+// MockServer calls the methods of an rpc.Server with Go values instead of
+// encoded requests, going through the hooks and middlewares of the server.
+type MockServer struct {
+	// Server is the server called, e.g. to register hooks.
+	Server *rpc.Server
+	err    error
+	header http.Header
+}
+
+// NewMockServer returns a MockServer calling a new rpc.Server.
+func NewMockServer() *MockServer {
+	s := rpc.NewServer()
+	s.RegisterCodec(codec{}, ContentType)
+	return &MockServer{Server: s, header: make(http.Header)}
+}
+
+// Register registers the service, see rpc.Server.RegisterService. The
+// registration error is returned by Err and by the calls.
+func (m *MockServer) Register(receiver interface{}, name string) *MockServer {
+	if err := m.Server.RegisterService(receiver, name); err != nil && m.err == nil {
+		m.err = err
+	}
+	return m
+}
+
+// Use adds the middleware, see rpc.Server.RegisterMiddleware.
+func (m *MockServer) Use(mw rpc.Middleware) *MockServer {
+	m.Server.RegisterMiddleware(mw)
+	return m
+}
+
+// Header sets a header of the requests of the calls.
+func (m *MockServer) Header(key, value string) *MockServer {
+	m.header.Set(key, value)
+	return m
+}
+
+// Err returns the first registration error.
+func (m *MockServer) Err() error {
+	return m.err
+}
+
+// Call calls the method with args and copies its reply into reply. The
+// args are passed to the method directly if they have the type of its
+// args, or else through JSON; so is the reply. The error is the one
+// returned by the method, or by the server, e.g. for unknown methods.
+func (m *MockServer) Call(ctx context.Context, method string, args, reply interface{}) error {
+	if m.err != nil {
+		return m.err
+	}
+	r := httptest.NewRequest("POST", "/", strings.NewReader(""))
+	for k, v := range m.header {
+		r.Header[k] = v
+	}
+	r.Header.Set("Content-Type", ContentType)
+	c := &call{method: method, args: args, reply: reply}
+	r = r.WithContext(context.WithValue(ctx, callKey{}, c))
+	m.Server.ServeHTTP(httptest.NewRecorder(), r)
+	return c.err
+}
+
+type callKey struct{}
+
+var errNoCall = errors.New("rpc: request isn't a call of a MockServer")
+
+// call is a call of a MockServer, read and answered by its codec.
+type call struct {
+	method string
+	args   interface{}
+	reply  interface{}
+	err    error
+}
+
+// codec is the codec of a MockServer, passing Go values.
+type codec struct{}
+
+func (codec) NewRequest(r *http.Request) rpc.CodecRequest {
+	c, _ := r.Context().Value(callKey{}).(*call)
+	return codecRequest{c}
+}
+
+type codecRequest struct {
+	*call
+}
+
+func (c codecRequest) Method() (string, error) {
+	if c.call == nil {
+		return "", errNoCall
+	}
+	return c.method, nil
+}
+
+func (c codecRequest) ReadRequest(args interface{}) error {
+	return copyValue(args, c.args)
+}
+
+func (c codecRequest) WriteResponse(w http.ResponseWriter, reply interface{}) {
+	if err := copyValue(c.reply, reply); err != nil {
+		c.err = err
+	}
+}
+
+func (c codecRequest) WriteError(w http.ResponseWriter, status int, err error) {
+	if c.call != nil {
+		c.err = err
+	}
+}
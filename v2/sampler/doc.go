@@ -0,0 +1,21 @@
+// Copyright 2009 The Go Authors. All rights reserved.
+// Copyright 2012 The Gorilla Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+/*
+Package gorilla/rpc/v2/sampler captures a fraction of the decoded params of
+calls and forwards them to a sink, for product analytics on the usage of
+the methods without the overhead of full audit logging.
+
+	smp := sampler.New(sampler.SinkFunc(func(s *sampler.Sample) {
+		events <- s
+	}))
+	smp.Rate = 0.01
+	smp.SetRate("Search.Query", 0.1)
+	s.RegisterMiddleware(smp.Middleware)
+
+The params are redacted before they are sampled: the members named in
+Redact, at any depth, are replaced with "[REDACTED]".
+*/
+package sampler
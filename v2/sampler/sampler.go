@@ -0,0 +1,168 @@
+// Copyright 2009 The Go Authors. All rights reserved.
+// Copyright 2012 The Gorilla Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package sampler
+
+import (
+	"encoding/json"
+	"math/rand"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/rpc/v2"
+)
+
+// Redacted replaces the values of the redacted members.
+const Redacted = "[REDACTED]"
+
+// DefaultRedact are the members redacted by the samplers returned by New.
+var DefaultRedact = []string{"password", "secret", "token", "apiKey", "authorization"}
+
+// Sample is the params of a sampled call.
+type Sample struct {
+	Method string          `json:"method"`
+	Time   time.Time       `json:"time"`
+	Params json.RawMessage `json:"params"`
+	Error  string          `json:"error,omitempty"`
+}
+
+// Sink receives the samples. It's called from the goroutine of the calls,
+// so slow sinks should buffer the samples.
+type Sink interface {
+	Sample(s *Sample)
+}
+
+// SinkFunc is a function implementing Sink.
+type SinkFunc func(s *Sample)
+
+// Sample calls f(s).
+func (f SinkFunc) Sample(s *Sample) {
+	f(s)
+}
+
+// Sampler samples the params of the calls at per-method rates.
+type Sampler struct {
+	// Sink receives the samples.
+	Sink Sink
+	// Rate is the fraction of the calls sampled for the methods without
+	// a rate set with SetRate, e.g. 0.01 for one call in a hundred.
+	Rate float64
+	// Redact are the names of the members redacted from the params, at
+	// any depth. Names are matched case-insensitively.
+	Redact []string
+
+	mutex sync.RWMutex
+	rates map[string]float64
+}
+
+// New returns a sampler forwarding the samples to sink, with a zero rate
+// and the DefaultRedact members.
+func New(sink Sink) *Sampler {
+	return &Sampler{
+		Sink:   sink,
+		Redact: DefaultRedact,
+		rates:  make(map[string]float64),
+	}
+}
+
+// SetRate sets the fraction of the calls of the method sampled, overriding
+// Rate.
+func (smp *Sampler) SetRate(method string, rate float64) {
+	smp.mutex.Lock()
+	defer smp.mutex.Unlock()
+	if smp.rates == nil {
+		smp.rates = make(map[string]float64)
+	}
+	smp.rates[method] = rate
+}
+
+// rate returns the rate of the method.
+func (smp *Sampler) rate(method string) float64 {
+	smp.mutex.RLock()
+	defer smp.mutex.RUnlock()
+	if rate, ok := smp.rates[method]; ok {
+		return rate
+	}
+	return smp.Rate
+}
+
+// sampled returns true if a call of the method is sampled.
+func (smp *Sampler) sampled(method string) bool {
+	rate := smp.rate(method)
+	return rate >= 1 || rate > 0 && rand.Float64() < rate
+}
+
+// Middleware samples the calls, to be registered with
+// rpc.Server.RegisterMiddleware.
+func (smp *Sampler) Middleware(next rpc.CallFunc) rpc.CallFunc {
+	return func(r *http.Request, method string, args, reply interface{}) error {
+		if !smp.sampled(method) {
+			return next(r, method, args, reply)
+		}
+		params, errParams := smp.redact(args)
+		start := time.Now()
+		err := next(r, method, args, reply)
+		if errParams != nil {
+			return err
+		}
+		s := &Sample{Method: method, Time: start, Params: params}
+		if err != nil {
+			s.Error = err.Error()
+		}
+		smp.Sink.Sample(s)
+		return err
+	}
+}
+
+// redact returns the args encoded as JSON, with the Redact members
+// replaced.
+func (smp *Sampler) redact(args interface{}) (json.RawMessage, error) {
+	data, err := json.Marshal(args)
+	if err != nil || len(smp.Redact) == 0 {
+		return data, err
+	}
+	var v interface{}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return nil, err
+	}
+	if !smp.redactValue(v) {
+		return data, nil
+	}
+	return json.Marshal(v)
+}
+
+// redactValue replaces the Redact members of v and returns true if any
+// was found.
+func (smp *Sampler) redactValue(v interface{}) bool {
+	found := false
+	switch v := v.(type) {
+	case map[string]interface{}:
+		for k, m := range v {
+			if smp.redacted(k) {
+				v[k], found = Redacted, true
+			} else if smp.redactValue(m) {
+				found = true
+			}
+		}
+	case []interface{}:
+		for _, e := range v {
+			if smp.redactValue(e) {
+				found = true
+			}
+		}
+	}
+	return found
+}
+
+func (smp *Sampler) redacted(name string) bool {
+	for _, r := range smp.Redact {
+		if strings.EqualFold(r, name) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,63 @@
+// Copyright 2009 The Go Authors. All rights reserved.
+// Copyright 2012 The Gorilla Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package sampler
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+)
+
+type loginArgs struct {
+	User     string
+	Password string
+	Devices  []map[string]string
+}
+
+func TestSampler(t *testing.T) {
+	var samples []*Sample
+	smp := New(SinkFunc(func(s *Sample) {
+		samples = append(samples, s)
+	}))
+	smp.SetRate("Auth.Login", 1)
+	call := smp.Middleware(func(r *http.Request, method string, args, reply interface{}) error {
+		if method == "Auth.Logout" {
+			return errors.New("failed")
+		}
+		return nil
+	})
+	r, _ := http.NewRequest("POST", "/", nil)
+
+	args := &loginArgs{
+		User:     "alice",
+		Password: "hunter2",
+		Devices:  []map[string]string{{"name": "phone", "token": "abc"}},
+	}
+	call(r, "Auth.Login", args, nil)
+	call(r, "Auth.Logout", args, nil)
+	if len(samples) != 1 || samples[0].Method != "Auth.Login" {
+		t.Fatalf("Expected only the call at rate 1 to be sampled, got %+v", samples)
+	}
+	want := `{"Devices":[{"name":"phone","token":"[REDACTED]"}],"Password":"[REDACTED]","User":"alice"}`
+	if string(samples[0].Params) != want {
+		t.Errorf("Wrong params %s, want %s", samples[0].Params, want)
+	}
+	if args.Password != "hunter2" {
+		t.Errorf("The args were modified: %+v", args)
+	}
+
+	samples = nil
+	smp.Rate = 0.5
+	for i := 0; i < 1000; i++ {
+		call(r, "Auth.Logout", args, nil)
+	}
+	if n := len(samples); n < 350 || n > 650 {
+		t.Errorf("Expected about half of the calls to be sampled, got %d", n)
+	}
+	if samples[0].Error != "failed" {
+		t.Errorf("Expected the error to be sampled, got %+v", samples[0])
+	}
+}
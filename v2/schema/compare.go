@@ -0,0 +1,190 @@
+// Copyright 2009 The Go Authors. All rights reserved.
+// Copyright 2012 The Gorilla Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package schema
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+)
+
+// ChangeKind is the kind of a change between snapshots.
+type ChangeKind string
+
+// Kinds of changes.
+const (
+	MethodAdded   ChangeKind = "method-added"
+	MethodRemoved ChangeKind = "method-removed"
+	MethodRenamed ChangeKind = "method-renamed"
+	FieldAdded    ChangeKind = "field-added"
+	FieldRemoved  ChangeKind = "field-removed"
+	TypeChanged   ChangeKind = "type-changed"
+)
+
+// Change is a change of the API between two snapshots.
+type Change struct {
+	Kind ChangeKind `json:"kind"`
+	// Method is the name of the method, the new name for MethodRenamed.
+	Method string `json:"method"`
+	// Path locates the changed field, e.g. "reply.Items[].Price".
+	Path string `json:"path,omitempty"`
+	// Old and New describe the change, e.g. the kinds of a TypeChanged
+	// field or the old name of a renamed method.
+	Old string `json:"old,omitempty"`
+	New string `json:"new,omitempty"`
+	// Breaking is set for changes breaking existing clients.
+	Breaking bool `json:"breaking"`
+}
+
+// String returns a line describing the change.
+func (c Change) String() string {
+	s := "compatible"
+	if c.Breaking {
+		s = "BREAKING"
+	}
+	s += fmt.Sprintf(" %s %s", c.Kind, c.Method)
+	if c.Path != "" {
+		s += " " + c.Path
+	}
+	if c.Old != "" || c.New != "" {
+		s += fmt.Sprintf(": %s -> %s", c.Old, c.New)
+	}
+	return s
+}
+
+// HasBreaking returns true if one of the changes is breaking.
+func HasBreaking(changes []Change) bool {
+	for _, c := range changes {
+		if c.Breaking {
+			return true
+		}
+	}
+	return false
+}
+
+// Compare returns the changes from the old snapshot to the new one, sorted
+// by method.
+func Compare(old, new *Snapshot) []Change {
+	var changes, removed, added []Change
+	for name, m := range old.Methods {
+		n, ok := new.Methods[name]
+		if !ok {
+			removed = append(removed, Change{Kind: MethodRemoved, Method: name, Breaking: true})
+			continue
+		}
+		changes = compareType(changes, name, "args", m.Args, n.Args)
+		changes = compareType(changes, name, "reply", m.Reply, n.Reply)
+	}
+	for name := range new.Methods {
+		if _, ok := old.Methods[name]; !ok {
+			added = append(added, Change{Kind: MethodAdded, Method: name})
+		}
+	}
+	// A removed method with the signature of an added one was renamed.
+	sortChanges(removed)
+	sortChanges(added)
+	for i := 0; i < len(removed); i++ {
+		for j := 0; j < len(added); j++ {
+			if sameMethod(old.Methods[removed[i].Method], new.Methods[added[j].Method]) {
+				changes = append(changes, Change{
+					Kind:     MethodRenamed,
+					Method:   added[j].Method,
+					Old:      removed[i].Method,
+					New:      added[j].Method,
+					Breaking: true,
+				})
+				removed = append(removed[:i], removed[i+1:]...)
+				added = append(added[:j], added[j+1:]...)
+				i--
+				break
+			}
+		}
+	}
+	changes = append(changes, removed...)
+	changes = append(changes, added...)
+	sortChanges(changes)
+	return changes
+}
+
+func sortChanges(changes []Change) {
+	sort.Stable(byMethodPath(changes))
+}
+
+// byMethodPath sorts changes by method, then path.
+type byMethodPath []Change
+
+func (c byMethodPath) Len() int      { return len(c) }
+func (c byMethodPath) Swap(i, j int) { c[i], c[j] = c[j], c[i] }
+
+func (c byMethodPath) Less(i, j int) bool {
+	if c[i].Method != c[j].Method {
+		return c[i].Method < c[j].Method
+	}
+	return c[i].Path < c[j].Path
+}
+
+// sameMethod returns true if the methods have the same schemas.
+func sameMethod(a, b *Method) bool {
+	ja, _ := json.Marshal(a)
+	jb, _ := json.Marshal(b)
+	return reflect.DeepEqual(ja, jb)
+}
+
+// compareType appends the changes from the old type to the new one.
+func compareType(changes []Change, method, path string, old, new *Type) []Change {
+	if old == nil || new == nil {
+		return changes
+	}
+	if old.Kind != new.Kind {
+		return append(changes, Change{
+			Kind:     TypeChanged,
+			Method:   method,
+			Path:     path,
+			Old:      old.Kind,
+			New:      new.Kind,
+			Breaking: true,
+		})
+	}
+	switch old.Kind {
+	case KindArray:
+		changes = compareType(changes, method, path+"[]", old.Elem, new.Elem)
+	case KindMap:
+		changes = compareType(changes, method, path+"{}", old.Elem, new.Elem)
+	case KindObject:
+		if old.Recursive || new.Recursive {
+			break
+		}
+		fields := make(map[string]*Field, len(new.Fields))
+		for _, f := range new.Fields {
+			fields[f.Name] = f
+		}
+		for _, f := range old.Fields {
+			n, ok := fields[f.Name]
+			if !ok {
+				changes = append(changes, Change{
+					Kind:     FieldRemoved,
+					Method:   method,
+					Path:     path + "." + f.Name,
+					Breaking: true,
+				})
+				continue
+			}
+			delete(fields, f.Name)
+			changes = compareType(changes, method, path+"."+f.Name, f.Type, n.Type)
+		}
+		for _, f := range new.Fields {
+			if _, ok := fields[f.Name]; ok {
+				changes = append(changes, Change{
+					Kind:   FieldAdded,
+					Method: method,
+					Path:   path + "." + f.Name,
+				})
+			}
+		}
+	}
+	return changes
+}
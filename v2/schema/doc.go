@@ -0,0 +1,31 @@
+// Copyright 2009 The Go Authors. All rights reserved.
+// Copyright 2012 The Gorilla Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+/*
+Package gorilla/rpc/v2/schema snapshots the methods of a server and the
+JSON schemas of their args and replies, and compares snapshots to catch
+breaking changes before they reach clients.
+
+A test checked in CI compares the server to the snapshot of the released
+API, and fails on breaking changes:
+
+	func TestSchema(t *testing.T) {
+		changes, err := schema.Check(newServer(), "testdata/schema.json")
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, c := range changes {
+			t.Log(c)
+		}
+		if schema.HasBreaking(changes) {
+			t.Error("breaking API changes")
+		}
+	}
+
+The snapshot is written with Save when releasing the API. Removed methods
+and fields, and fields whose JSON type changed, are breaking changes; a
+removed method with the signature of an added one is reported as renamed.
+*/
+package schema
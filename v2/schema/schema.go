@@ -0,0 +1,265 @@
+// Copyright 2009 The Go Authors. All rights reserved.
+// Copyright 2012 The Gorilla Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package schema
+
+import (
+	"encoding"
+	"encoding/json"
+	"io"
+	"os"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/gorilla/rpc/v2"
+)
+
+// JSON kinds of types.
+const (
+	KindString  = "string"
+	KindNumber  = "number"
+	KindInteger = "integer"
+	KindBoolean = "boolean"
+	KindArray   = "array"
+	KindMap     = "map"
+	KindObject  = "object"
+	KindStream  = "stream"
+	KindAny     = "any"
+)
+
+// Snapshot is the schema of the methods of a server.
+type Snapshot struct {
+	Methods map[string]*Method `json:"methods"`
+}
+
+// Method is the schema of a method.
+type Method struct {
+	Args  *Type `json:"args"`
+	Reply *Type `json:"reply"`
+}
+
+// Type is the JSON schema of a Go type.
+type Type struct {
+	// Kind is the JSON kind of the type, e.g. KindObject.
+	Kind string `json:"kind"`
+	// Name is the Go name of the type, if named. It is informative.
+	Name string `json:"name,omitempty"`
+	// Fields are the fields of objects.
+	Fields []*Field `json:"fields,omitempty"`
+	// Elem is the type of the elements of arrays and maps.
+	Elem *Type `json:"elem,omitempty"`
+	// Recursive is set on the inner occurrence of a recursive type,
+	// described by the outer one.
+	Recursive bool `json:"recursive,omitempty"`
+}
+
+// Field is a field of an object.
+type Field struct {
+	Name      string `json:"name"`
+	Type      *Type  `json:"type"`
+	OmitEmpty bool   `json:"omitempty,omitempty"`
+	// Enum is the name of the enum of the field, see rpc.RegisterEnum,
+	// and Values the values it allows.
+	Enum   string        `json:"enum,omitempty"`
+	Values []interface{} `json:"values,omitempty"`
+	// Default is the default value of the field, see rpc.DefaultTag.
+	Default interface{} `json:"default,omitempty"`
+}
+
+// Take returns the snapshot of the methods registered in the server.
+func Take(s *rpc.Server) (*Snapshot, error) {
+	snap := &Snapshot{Methods: make(map[string]*Method)}
+	for _, name := range s.Methods() {
+		m, err := s.Router().Resolve(name)
+		if err != nil {
+			return nil, err
+		}
+		snap.Methods[name] = &Method{
+			Args:  Of(m.ArgsType()),
+			Reply: Of(m.ReplyType()),
+		}
+	}
+	return snap, nil
+}
+
+var (
+	typeOfStream        = reflect.TypeOf(rpc.Stream{})
+	typeOfTime          = reflect.TypeOf(time.Time{})
+	typeOfRawMessage    = reflect.TypeOf(json.RawMessage{})
+	typeOfJSONMarshaler = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+	typeOfTextMarshaler = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+)
+
+// Of returns the JSON schema of a Go type, as encoded by encoding/json.
+func Of(t reflect.Type) *Type {
+	return typeOf(t, make(map[reflect.Type]bool))
+}
+
+func typeOf(t reflect.Type, visiting map[reflect.Type]bool) *Type {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	res := &Type{Name: typeName(t)}
+	switch {
+	case t == typeOfStream:
+		res.Kind = KindStream
+		return res
+	case t == typeOfTime:
+		res.Kind = KindString
+		return res
+	case t == typeOfRawMessage, t.Implements(typeOfJSONMarshaler),
+		reflect.PtrTo(t).Implements(typeOfJSONMarshaler):
+		res.Kind = KindAny
+		return res
+	case t.Implements(typeOfTextMarshaler), reflect.PtrTo(t).Implements(typeOfTextMarshaler):
+		res.Kind = KindString
+		return res
+	}
+	switch t.Kind() {
+	case reflect.String:
+		res.Kind = KindString
+	case reflect.Bool:
+		res.Kind = KindBoolean
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		res.Kind = KindInteger
+	case reflect.Float32, reflect.Float64:
+		res.Kind = KindNumber
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 && t.Kind() == reflect.Slice {
+			// []byte is encoded as a base64 string.
+			res.Kind = KindString
+			break
+		}
+		res.Kind = KindArray
+		res.Elem = typeOf(t.Elem(), visiting)
+	case reflect.Map:
+		res.Kind = KindMap
+		res.Elem = typeOf(t.Elem(), visiting)
+	case reflect.Struct:
+		res.Kind = KindObject
+		if visiting[t] {
+			res.Recursive = true
+			return res
+		}
+		visiting[t] = true
+		res.Fields = fields(t, visiting)
+		delete(visiting, t)
+	default:
+		res.Kind = KindAny
+	}
+	return res
+}
+
+// fields returns the fields of a struct as encoded by encoding/json,
+// including the fields of embedded structs.
+func fields(t reflect.Type, visiting map[reflect.Type]bool) []*Field {
+	var fs []*Field
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		tag := sf.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, opts := tag, ""
+		if i := strings.Index(tag, ","); i >= 0 {
+			name, opts = tag[:i], tag[i:]
+		}
+		ft := sf.Type
+		if ft.Kind() == reflect.Ptr {
+			ft = ft.Elem()
+		}
+		if sf.Anonymous && name == "" && ft.Kind() == reflect.Struct {
+			fs = append(fs, fields(ft, visiting)...)
+			continue
+		}
+		if sf.PkgPath != "" {
+			continue
+		}
+		if name == "" {
+			name = sf.Name
+		}
+		f := &Field{
+			Name:      name,
+			Type:      typeOf(sf.Type, visiting),
+			OmitEmpty: strings.Contains(opts, ",omitempty"),
+			Enum:      sf.Tag.Get(rpc.EnumTag),
+		}
+		if f.Enum != "" {
+			f.Values, _ = rpc.EnumValues(f.Enum)
+		}
+		if value, ok := sf.Tag.Lookup(rpc.DefaultTag); ok {
+			if d, err := rpc.ParseDefault(sf.Type, value); err == nil {
+				f.Default = d.Interface()
+			}
+		}
+		fs = append(fs, f)
+	}
+	return fs
+}
+
+func typeName(t reflect.Type) string {
+	if t.Name() == "" {
+		return ""
+	}
+	return t.String()
+}
+
+// Save writes the snapshot of the server to the file at path.
+func Save(s *rpc.Server, path string) error {
+	snap, err := Take(s)
+	if err != nil {
+		return err
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err = snap.Write(f); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// Load reads a snapshot from the file at path.
+func Load(path string) (*Snapshot, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return Read(f)
+}
+
+// Write writes the snapshot as indented JSON.
+func (s *Snapshot) Write(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(s)
+}
+
+// Read reads a snapshot written by Write.
+func Read(r io.Reader) (*Snapshot, error) {
+	snap := new(Snapshot)
+	if err := json.NewDecoder(r).Decode(snap); err != nil {
+		return nil, err
+	}
+	return snap, nil
+}
+
+// Check compares the server to the snapshot saved at path.
+func Check(s *rpc.Server, path string) ([]Change, error) {
+	old, err := Load(path)
+	if err != nil {
+		return nil, err
+	}
+	snap, err := Take(s)
+	if err != nil {
+		return nil, err
+	}
+	return Compare(old, snap), nil
+}
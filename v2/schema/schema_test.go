@@ -0,0 +1,135 @@
+// Copyright 2009 The Go Authors. All rights reserved.
+// Copyright 2012 The Gorilla Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package schema
+
+import (
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/rpc/v2"
+)
+
+type Item struct {
+	Name     string
+	Price    int64 `json:"price"`
+	Children []*Item
+}
+
+type ListArgs struct {
+	Filter string `json:"filter,omitempty"`
+	Secret string `json:"-"`
+}
+
+type ListReply struct {
+	Items   []Item
+	Updated time.Time
+}
+
+type Catalog struct{}
+
+func (Catalog) List(r *http.Request, args *ListArgs, reply *ListReply) error {
+	return nil
+}
+
+func (Catalog) Get(r *http.Request, args *string, reply *Item) error {
+	return nil
+}
+
+type ItemV2 struct {
+	Name     string
+	Price    string `json:"price"`
+	Currency string
+	Children []*ItemV2
+}
+
+type ListReplyV2 struct {
+	Items []ItemV2
+}
+
+type CatalogV2 struct{}
+
+func (CatalogV2) List(r *http.Request, args *ListArgs, reply *ListReplyV2) error {
+	return nil
+}
+
+func (CatalogV2) Fetch(r *http.Request, args *string, reply *Item) error {
+	return nil
+}
+
+func TestTake(t *testing.T) {
+	s := rpc.NewServer()
+	s.RegisterService(new(Catalog), "Catalog")
+	snap, err := Take(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	list := snap.Methods["Catalog.List"]
+	if list == nil || len(list.Args.Fields) != 1 || !list.Args.Fields[0].OmitEmpty {
+		t.Fatalf("Wrong args schema: %+v", list)
+	}
+	items := list.Reply.Fields[0].Type
+	if items.Kind != KindArray || items.Elem.Fields[1].Type.Kind != KindInteger {
+		t.Errorf("Wrong items schema: %+v", items)
+	}
+	if children := items.Elem.Fields[2].Type.Elem; !children.Recursive {
+		t.Errorf("Expected recursive children, got %+v", children)
+	}
+	if updated := list.Reply.Fields[1].Type; updated.Kind != KindString {
+		t.Errorf("Expected time as string, got %+v", updated)
+	}
+	paged := Of(reflect.TypeOf(struct {
+		Limit int `default:"10"`
+	}{}))
+	if d := paged.Fields[0].Default; d != 10 {
+		t.Errorf("Expected default 10, got %v", d)
+	}
+}
+
+func TestCheck(t *testing.T) {
+	dir, err := ioutil.TempDir("", "schema")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "schema.json")
+	s := rpc.NewServer()
+	s.RegisterService(new(Catalog), "Catalog")
+	if err := Save(s, path); err != nil {
+		t.Fatal(err)
+	}
+	if changes, err := Check(s, path); err != nil || len(changes) != 0 {
+		t.Fatalf("Expected no changes, got %v %v", changes, err)
+	}
+
+	s = rpc.NewServer()
+	s.RegisterService(new(CatalogV2), "Catalog")
+	changes, err := Check(s, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var lines []string
+	for _, c := range changes {
+		lines = append(lines, c.String())
+	}
+	expected := []string{
+		"BREAKING method-renamed Catalog.Fetch: Catalog.Get -> Catalog.Fetch",
+		"compatible field-added Catalog.List reply.Items[].Currency",
+		"BREAKING type-changed Catalog.List reply.Items[].price: integer -> string",
+		"BREAKING field-removed Catalog.List reply.Updated",
+	}
+	if got := strings.Join(lines, "\n"); got != strings.Join(expected, "\n") {
+		t.Errorf("Wrong changes:\n%s", got)
+	}
+	if !HasBreaking(changes) {
+		t.Error("Expected breaking changes")
+	}
+}
@@ -0,0 +1,82 @@
+// Copyright 2009 The Go Authors. All rights reserved.
+// Copyright 2012 The Gorilla Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package rpc
+
+import (
+	"reflect"
+)
+
+// isSequence returns true if replies of type t are sequences streamed to
+// the client: channels, and iterators such as iter.Seq, or iter.Seq2 with
+// an error as second value.
+//
+// Methods set their reply to the sequence, and the server sends its values
+// once the method returned, as a Stream does:
+//
+//	func (t *Export) Rows(r *http.Request, args *ExportArgs, reply *<-chan Row) error {
+//		rows := make(chan Row)
+//		go t.produce(r.Context(), args, rows) // closes rows when done
+//		*reply = rows
+//		return nil
+//	}
+//
+// The server stops reading a channel when the client goes away: producers
+// must then stop too, with the context of the request. An iterator stops
+// at the first error it yields, sent to the client as the error of the
+// call.
+func isSequence(t reflect.Type) bool {
+	switch t.Kind() {
+	case reflect.Chan:
+		return t.ChanDir()&reflect.RecvDir != 0
+	case reflect.Func:
+		if t.NumIn() != 1 || t.NumOut() != 0 {
+			return false
+		}
+		yield := t.In(0)
+		if yield.Kind() != reflect.Func || yield.NumOut() != 1 || yield.Out(0).Kind() != reflect.Bool {
+			return false
+		}
+		return yield.NumIn() == 1 || (yield.NumIn() == 2 && yield.In(1) == typeOfError)
+	}
+	return false
+}
+
+// sendSequence sends the values of a sequence, see isSequence, until it
+// ends, the client goes away or the iterator yields an error.
+func (s *Stream) sendSequence(seq reflect.Value) error {
+	if seq.IsNil() {
+		return nil
+	}
+	if seq.Kind() == reflect.Chan {
+		cases := []reflect.SelectCase{
+			{Dir: reflect.SelectRecv, Chan: seq},
+			{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(s.ctx.Done())},
+		}
+		for {
+			chosen, v, ok := reflect.Select(cases)
+			if chosen == 1 {
+				return s.ctx.Err()
+			}
+			if !ok {
+				return nil
+			}
+			if err := s.Send(v.Interface()); err != nil {
+				return err
+			}
+		}
+	}
+	var err error
+	yield := reflect.MakeFunc(seq.Type().In(0), func(in []reflect.Value) []reflect.Value {
+		if len(in) == 2 && !in[1].IsNil() {
+			err = in[1].Interface().(error)
+		} else {
+			err = s.Send(in[0].Interface())
+		}
+		return []reflect.Value{reflect.ValueOf(err == nil)}
+	})
+	seq.Call([]reflect.Value{yield})
+	return err
+}
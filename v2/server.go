@@ -6,10 +6,14 @@
 package rpc
 
 import (
+	"context"
 	"fmt"
 	"net/http"
 	"reflect"
 	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
 )
 
 var nilErrorValue = reflect.Zero(reflect.TypeOf((*error)(nil)).Elem())
@@ -42,42 +46,130 @@ type CodecRequest interface {
 
 // NewServer returns a new RPC server.
 func NewServer() *Server {
+	services := new(serviceMap)
 	return &Server{
 		codecs:   make(map[string]Codec),
-		services: new(serviceMap),
+		services: services,
+		router:   services,
 	}
 }
 
 // RequestInfo contains all the information we pass to before/after functions
 type RequestInfo struct {
-	Method     string
-	Error      error
-	Request    *http.Request
-	StatusCode int
+	Method  string
+	Error   error
+	Request *http.Request
+	// StatusCode and BytesWritten are the HTTP status and the size of the
+	// body of the response, as written by the codec, for AfterFuncs.
+	StatusCode   int
+	BytesWritten int64
+	// Body is the raw body of the request if the server keeps it, see
+	// Server.KeepRequestBody. It is shared by the calls of a batch and
+	// must not be modified.
+	Body []byte
+	// RemoteAddr is the address of the client, and Codec the content type
+	// selecting the codec of the call.
+	RemoteAddr string
+	Codec      string
+}
+
+type requestInfoKey struct{}
+
+// NewRequestInfoContext returns a copy of ctx carrying the info of a call.
+// The server sets it in the context of the requests passed to the
+// middlewares and methods, so that they can read it without depending on
+// the request, e.g. in code shared with other transports.
+func NewRequestInfoContext(ctx context.Context, info *RequestInfo) context.Context {
+	return context.WithValue(ctx, requestInfoKey{}, info)
+}
+
+// RequestInfoFromContext returns the info of the call carried by ctx, or
+// nil.
+func RequestInfoFromContext(ctx context.Context) *RequestInfo {
+	info, _ := ctx.Value(requestInfoKey{}).(*RequestInfo)
+	return info
+}
+
+// StatusError is implemented by errors returned by methods or middlewares
+// to set the HTTP status of the error response, instead of 400 Bad Request.
+// Codecs may ignore the status.
+type StatusError interface {
+	error
+	HTTPStatus() int
 }
 
 // Server serves registered RPC services using registered codecs.
 type Server struct {
-	codecs        map[string]Codec
-	services      *serviceMap
-	interceptFunc func(i *RequestInfo) *http.Request
-	beforeFunc    func(i *RequestInfo)
-	afterFunc     func(i *RequestInfo)
-	validateFunc  reflect.Value
+	codecs           map[string]Codec
+	services         *serviceMap
+	router           Router
+	interceptFunc    func(i *RequestInfo) *http.Request
+	beforeFunc       func(i *RequestInfo)
+	afterFunc        func(i *RequestInfo)
+	validateFunc     reflect.Value
+	replyValidator   func(i *RequestInfo, reply interface{}) error
+	replyValidation  bool
+	errorCatalog     *ErrorCatalog
+	fallbackFunc     FallbackFunc
+	methodInfos      methodInfos
+	enablerFunc      func(i *RequestInfo) bool
+	config           atomic.Value
+	configMutex      sync.Mutex
+	rateLimiters     rateLimiters
+	rateLimitStore   RateLimitStore
+	scopeFunc        func(r *http.Request) []string
+	drain            drain
+	contextMerge     bool
+	sessions         sessions
+	middlewares      []Middleware
+	profilerLabels   bool
+	affinity         bool
+	maxResponseBytes int
+	limitPolicy      LimitPolicy
+	dedup            dedup
+	transactions     transactions
+	pools            pools
+	keepBody         bool
+	codecSelector    func(r *http.Request) string
+	codecPatterns    []codecPattern
+	codecCount       int
+	lastCodec        Codec
+	strictRegister   bool
+	latencies        latencies
+	errorReporter    func(i *RequestInfo, err error, stack []byte)
+	accessLog        *AccessLog
+	verifyBody       bool
+	pathPrefix       string
 }
 
 // RegisterCodec adds a new codec to the server.
 //
 // Codecs are defined to process a given serialization scheme, e.g., JSON or
 // XML. A codec is chosen based on the "Content-Type" header from the request,
-// excluding the charset definition.
-func (s *Server) RegisterCodec(codec Codec, contentType string) {
-	s.codecs[strings.ToLower(contentType)] = codec
+// excluding the charset definition and other parameters.
+//
+// A codec can be registered for several content types at once, as aliases,
+// which may be patterns with "*" wildcards matching any characters but "/",
+// e.g. "application/vnd.*+json". Exact content types are matched first,
+// then the patterns in the order they are registered.
+func (s *Server) RegisterCodec(codec Codec, contentType string, aliases ...string) {
+	s.codecCount++
+	s.lastCodec = codec
+	for _, t := range append([]string{contentType}, aliases...) {
+		t = strings.ToLower(strings.TrimSpace(t))
+		if strings.Contains(t, "*") {
+			s.codecPatterns = append(s.codecPatterns, codecPattern{t, codec})
+		} else {
+			s.codecs[t] = codec
+		}
+	}
 }
 
 // RegisterInterceptFunc registers the specified function as the function
 // that will be called before every request. The function is allowed to intercept
-// the request e.g. add values to the context.
+// the request e.g. add values to the context. If it returns a request with a
+// new context, the values of the previous one are still visible, see
+// EnableContextMerge.
 //
 // Note: Only one function can be registered, subsequent calls to this
 // method will overwrite all the previous functions.
@@ -129,76 +221,195 @@ func (s *Server) RegisterAfterFunc(f func(i *RequestInfo)) {
 //    - The method has return type error.
 //
 // All other methods are ignored.
-func (s *Server) RegisterService(receiver interface{}, name string) error {
-	return s.services.register(receiver, name)
+//
+// Methods may also take a fourth http.Header or *ResponseMeta argument,
+// or omit the reply, as in func(*http.Request, *args) error, or both the
+// args and the reply, as in func(*http.Request) error. Their reply is then
+// encoded as an empty struct.
+//
+// Options, such as MethodPrefix or MethodsOf, or services implementing
+// MethodLister, restrict the methods registered.
+func (s *Server) RegisterService(receiver interface{}, name string, opts ...RegisterOption) error {
+	o := registerOptions{strict: s.strictRegister}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return s.services.register(receiver, name, o.withLister(receiver))
 }
 
 // HasMethod returns true if the given method is registered.
 //
 // The method uses a dotted notation as in "Service.Method".
 func (s *Server) HasMethod(method string) bool {
-	if _, _, err := s.services.get(method); err == nil {
+	if _, err := s.router.Resolve(method); err == nil {
 		return true
 	}
 	return false
 }
 
+// Methods returns the sorted names of the methods of the registered
+// services, in dotted notation as in "Service.Method".
+func (s *Server) Methods() []string {
+	return s.services.methods()
+}
+
+// ServiceInfo describes a registered service.
+type ServiceInfo struct {
+	// Name is the name of the service, given or inferred.
+	Name string
+	// ReceiverType is the type of the receiver of the methods.
+	ReceiverType reflect.Type
+	// Methods are the sorted names of the methods, without the service.
+	Methods []string
+	// Filtered is true if the methods were restricted at registration,
+	// by RegisterOptions or because the receiver implements MethodLister.
+	Filtered bool
+	// Strict is true if the service was registered with strict
+	// registration, see StrictRegistration.
+	Strict bool
+}
+
+// HasService returns true if a service is registered with the name.
+func (s *Server) HasService(name string) bool {
+	_, ok := s.services.info(name)
+	return ok
+}
+
+// ServiceInfo returns the description of the service registered with the
+// name, or false if there's none, e.g. for frameworks to check at startup
+// that the services they expect are registered.
+func (s *Server) ServiceInfo(name string) (ServiceInfo, bool) {
+	return s.services.info(name)
+}
+
 // ServeHTTP
 func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if s.dedup.store != nil && r.Method == "POST" {
+		if key := idempotencyKey(r); key != "" {
+			s.serveDedup(w, r, key)
+			return
+		}
+	}
+	s.serveHTTP(w, r)
+}
+
+// serveHTTP serves a request without deduplication.
+func (s *Server) serveHTTP(w http.ResponseWriter, r *http.Request) {
 	if r.Method != "POST" {
 		WriteError(w, http.StatusMethodNotAllowed, "rpc: POST method required, received "+r.Method)
 		return
 	}
-	contentType := r.Header.Get("Content-Type")
-	idx := strings.Index(contentType, ";")
-	if idx != -1 {
-		contentType = contentType[:idx]
-	}
-	var codec Codec
-	if contentType == "" && len(s.codecs) == 1 {
-		// If Content-Type is not set and only one codec has been registered,
-		// then default to that codec.
-		for _, c := range s.codecs {
-			codec = c
-		}
-	} else if codec = s.codecs[strings.ToLower(contentType)]; codec == nil {
+	r, ok := s.routePath(r)
+	if !ok {
+		WriteError(w, http.StatusNotFound, "rpc: no method in the path "+r.URL.Path)
+		return
+	}
+	codec, contentType := s.selectCodec(r)
+	if codec == nil {
 		WriteError(w, http.StatusUnsupportedMediaType, "rpc: unrecognized Content-Type: "+contentType)
 		return
 	}
+	if s.verifyBody {
+		var err error
+		if r, err = verifyBody(r); err != nil {
+			WriteError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+	}
+	if s.keepBody {
+		var err error
+		if r, err = keepBody(r); err != nil {
+			WriteError(w, http.StatusBadRequest, "rpc: reading body: "+err.Error())
+			return
+		}
+	}
+	r = s.withAffinity(w, r)
+	if ctx := WithPropagation(r.Context(), r.Header); ctx != r.Context() {
+		r = r.WithContext(ctx)
+	}
 	// Create a new codec request.
-	codecReq := codec.NewRequest(r)
+	codecReq := newCodecRequest(codec, r)
+	if batch, ok := codecReq.(BatchCodecRequest); ok {
+		s.serveBatch(w, r, batch, contentType)
+		return
+	}
+	s.serveRequest(w, r, codecReq, contentType)
+}
+
+// serveRequest serves a single call decoded by codecReq and returns the
+// error written in the response, if any.
+func (s *Server) serveRequest(w http.ResponseWriter, r *http.Request, codecReq CodecRequest, contentType string) error {
+	start := time.Now()
+	if !s.drain.begin() {
+		Throttle{RetryAfter: DrainRetryAfter}.SetHeaders(w.Header())
+		codecReq.WriteError(w, http.StatusServiceUnavailable, ErrDraining)
+		return ErrDraining
+	}
+	defer s.drain.end()
+	_, isMessage := w.(*messageWriter)
+	var cw *captureWriter
+	if s.afterFunc != nil || s.accessLog != nil {
+		cw = &captureWriter{ResponseWriter: w}
+		w = cw
+	}
 	// Get service method to be called.
-	method, errMethod := codecReq.Method()
+	method, errMethod := routeMethod(r, codecReq)
 	if errMethod != nil {
 		codecReq.WriteError(w, http.StatusBadRequest, errMethod)
-		return
+		return errMethod
+	}
+	methodSpec, errGet := s.router.Resolve(method)
+	statsMethod := method
+	if errGet != nil && s.fallbackFunc != nil {
+		methodSpec, errGet = NewRawMethod(method, s.fallbackFunc), nil
+		statsMethod = FallbackStatsMethod
 	}
-	serviceSpec, methodSpec, errGet := s.services.get(method)
 	if errGet != nil {
 		codecReq.WriteError(w, http.StatusBadRequest, errGet)
-		return
+		return errGet
+	}
+	defer s.recordLatency(statsMethod, start)
+	if s.enablerFunc != nil && !s.enablerFunc(&RequestInfo{Request: r, Method: method, Body: requestBody(r)}) {
+		codecReq.WriteError(w, http.StatusForbidden, ErrMethodDisabled)
+		return ErrMethodDisabled
 	}
+	r, release, status, errConfig := s.applyConfig(w.Header(), r, method)
+	if errConfig != nil {
+		codecReq.WriteError(w, status, errConfig)
+		return errConfig
+	}
+	defer release()
+	s.methodInfos.deprecation(w, method)
 	// Decode the args.
-	args := reflect.New(methodSpec.argsType)
+	pool := s.pools.get(method)
+	args := pool.newArgs(methodSpec.argsType)
 	if errRead := codecReq.ReadRequest(args.Interface()); errRead != nil {
 		codecReq.WriteError(w, http.StatusBadRequest, errRead)
-		return
+		return errRead
+	}
+	if errDefault := applyDefaults(args); errDefault != nil {
+		codecReq.WriteError(w, http.StatusInternalServerError, errDefault)
+		s.reportError(r, method, http.StatusInternalServerError, errDefault)
+		return errDefault
+	}
+	if errEnum := validateEnums(args); errEnum != nil {
+		codecReq.WriteError(w, http.StatusBadRequest, errEnum)
+		return errEnum
 	}
 
 	// Call the registered Intercept Function
 	if s.interceptFunc != nil {
-		req := s.interceptFunc(&RequestInfo{
-			Request: r,
-			Method:  method,
-		})
-		if req != nil {
-			r = req
-		}
+		var release func()
+		r, release = s.intercept(r, method)
+		defer release()
 	}
 
 	requestInfo := &RequestInfo{
-		Request: r,
-		Method:  method,
+		Request:    r,
+		Method:     method,
+		Body:       requestBody(r),
+		RemoteAddr: r.RemoteAddr,
+		Codec:      contentType,
 	}
 
 	// Call the registered Before Function
@@ -207,7 +418,15 @@ func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Prepare the reply, we need it even if validation fails
-	reply := reflect.New(methodSpec.replyType)
+	var reply reflect.Value
+	var stream *Stream
+	if methodSpec.class == MethodClassStream {
+		stream = newStream(w, r, codecReq)
+		reply = reflect.ValueOf(stream)
+	} else {
+		reply = pool.newReply(methodSpec.replyType)
+	}
+	defer pool.put(args, reply, stream != nil)
 	errValue := []reflect.Value{nilErrorValue}
 
 	// Call the registered Validator Function
@@ -215,55 +434,113 @@ func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		errValue = s.validateFunc.Call([]reflect.Value{reflect.ValueOf(requestInfo), args})
 	}
 
+	// Extract the result to error if needed.
+	var errResult error
+	if errInter := errValue[0].Interface(); errInter != nil {
+		errResult = errInter.(error)
+	}
+
 	// If still no errors after validation, call the method
-	if errValue[0].IsNil() {
-		if methodSpec.class == MethodClassWithHeader {
-			errValue = methodSpec.method.Func.Call([]reflect.Value{
-				serviceSpec.rcvr,
-				reflect.ValueOf(r),
-				args,
-				reply,
-				reflect.ValueOf(w.Header()),
-			})
-		} else {
-			errValue = methodSpec.method.Func.Call([]reflect.Value{
-				serviceSpec.rcvr,
-				reflect.ValueOf(r),
-				args,
-				reply,
-			})
+	meta := new(ResponseMeta)
+	if errResult == nil {
+		call := s.chain(func(r *http.Request, method string, _, _ interface{}) error {
+			return methodSpec.call(w, r, args, reply)
+		})
+		call = s.transactions.wrap(method, call)
+		callReq := r.WithContext(NewRequestInfoContext(r.Context(), requestInfo))
+		s.profile(meta.with(callReq), method, contentType, func(r *http.Request) {
+			errResult = s.recoverCall(call, r, method, args.Interface(), reply.Interface())
+		})
+	}
+	if errResult == nil && methodSpec.sequence {
+		stream = newStream(w, r, codecReq)
+		errResult = stream.sendSequence(reply.Elem())
+	}
+	if errResult == nil && stream == nil {
+		errResult = s.validateReply(requestInfo, reply)
+	} else if errResult != nil {
+		errResult = s.checkErrorCode(errResult)
+	}
+	if errResult == ErrDropReply {
+		if isMessage {
+			return errResult
 		}
+		panic(errAbortHandler)
 	}
 
-	// Extract the result to error if needed.
-	var errResult error
 	statusCode := http.StatusOK
-	errInter := errValue[0].Interface()
-	if errInter != nil {
+	if errResult != nil {
 		statusCode = http.StatusBadRequest
-		errResult = errInter.(error)
+		if e, ok := errResult.(StatusError); ok {
+			statusCode = e.HTTPStatus()
+		}
 	}
 
 	// Prevents Internet Explorer from MIME-sniffing a response away
 	// from the declared content-type
 	w.Header().Set("x-content-type-options", "nosniff")
+	if errResult == nil {
+		s.cacheControl(w.Header(), method)
+	}
+	meta.writeHeader(w)
 
 	// Encode the response.
-	if errResult == nil {
-		codecReq.WriteResponse(w, reply.Interface())
+	if stream != nil && (stream.close() || errResult == nil) {
+		// The results were streamed: only write the error, if any, as
+		// NDJSON streams only carry it in the trailers.
+		if errResult != nil && !stream.ndjson {
+			codecReq.WriteError(w, statusCode, errResult)
+		} else if stream.ndjson && stream.sent == 0 {
+			w.Header().Set("Content-Type", NDJSONContentType)
+		}
+		if stream.sent > 0 {
+			stream.writeTrailer(statusCode, errResult)
+		}
+	} else if errResult == nil && etag(w, r, reply.Interface()) {
+		statusCode = http.StatusNotModified
+		if isMessage {
+			codecReq.WriteError(w, statusCode, ErrNotModified)
+		} else {
+			w.WriteHeader(statusCode)
+		}
+	} else if errResult == nil {
+		rw := w
+		if status := meta.replyStatus(reply.Interface()); status != 0 {
+			rw, statusCode = &statusWriter{ResponseWriter: w, status: status}, status
+		}
+		if errResult = s.writeResponse(rw, codecReq, reply.Interface()); errResult != nil {
+			statusCode = http.StatusInternalServerError
+		}
 	} else {
 		codecReq.WriteError(w, statusCode, errResult)
 	}
+	meta.writeTrailer(w)
+	s.reportError(r, method, statusCode, errResult)
+
+	if cw == nil {
+		return errResult
+	}
+	if cw.status != 0 {
+		statusCode = cw.status
+	} else if cw.written > 0 {
+		statusCode = http.StatusOK
+	}
+	if s.accessLog != nil {
+		s.accessLog.log(r, method, contentType, statusCode, cw.written, start, errResult)
+	}
 
 	// Call the registered After Function
 	if s.afterFunc != nil {
 		s.afterFunc(&RequestInfo{
-			Request:    r,
-			Method:     method,
-			Error:      errResult,
-			StatusCode: statusCode,
+			Request:      r,
+			Method:       method,
+			Error:        errResult,
+			StatusCode:   statusCode,
+			BytesWritten: cw.written,
+			Body:         requestInfo.Body,
 		})
 	}
+	return errResult
 }
 
 func WriteError(w http.ResponseWriter, status int, msg string) {
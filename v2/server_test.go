@@ -8,6 +8,7 @@ package rpc
 import (
 	"errors"
 	"net/http"
+	"reflect"
 	"strconv"
 	"testing"
 )
@@ -296,3 +297,128 @@ func TestValidationFails(t *testing.T) {
 		t.Errorf("Response body was %s, should be %s.", w.Body, expected)
 	}
 }
+
+func TestMethodEnabler(t *testing.T) {
+	s := NewServer()
+	s.RegisterService(new(Service1), "")
+	s.RegisterCodec(MockCodec{2, 3}, "mock")
+	s.RegisterMethodEnablerFunc(func(i *RequestInfo) bool {
+		return i.Method != "Service1.MultiplyWithHeaders"
+	})
+
+	r, _ := http.NewRequest("POST", "Service1.Multiply", nil)
+	r.Header.Set("Content-Type", "mock")
+	w := NewMockResponseWriter()
+	s.ServeHTTP(w, r)
+	if w.Status != 200 {
+		t.Errorf("Status was %d, should be 200.", w.Status)
+	}
+
+	r, _ = http.NewRequest("POST", "Service1.MultiplyWithHeaders", nil)
+	r.Header.Set("Content-Type", "mock")
+	w = NewMockResponseWriter()
+	s.ServeHTTP(w, r)
+	if w.Status != 403 {
+		t.Errorf("Status was %d, should be 403.", w.Status)
+	}
+	if w.Body != ErrMethodDisabled.Error() {
+		t.Errorf("Response body was %s, should be %s.", w.Body, ErrMethodDisabled)
+	}
+}
+
+type statusError struct{}
+
+func (statusError) Error() string   { return "unavailable" }
+func (statusError) HTTPStatus() int { return http.StatusServiceUnavailable }
+
+func TestStatusError(t *testing.T) {
+	s := NewServer()
+	s.RegisterCodec(MockCodec{A: 1, B: 2}, "mock")
+	s.RegisterService(new(Service1), "")
+	s.RegisterMiddleware(func(next CallFunc) CallFunc {
+		return func(r *http.Request, method string, args, reply interface{}) error {
+			return statusError{}
+		}
+	})
+	w := serveMock(s, "Service1.Multiply", "")
+	if w.Status != http.StatusServiceUnavailable {
+		t.Errorf("Expected status %d, got %d", http.StatusServiceUnavailable, w.Status)
+	}
+}
+
+func TestMaxResponseBytes(t *testing.T) {
+	s := NewServer()
+	s.RegisterCodec(MockCodec{A: 1000, B: 1000}, "mock")
+	s.RegisterService(new(Service1), "")
+
+	s.SetMaxResponseBytes(4, LimitError)
+	w := serveMock(s, "Service1.Multiply", "")
+	if w.Status != http.StatusInternalServerError || w.Body != ErrResponseTooLarge.Error() {
+		t.Errorf("Expected ErrResponseTooLarge, got %d %q", w.Status, w.Body)
+	}
+
+	s.SetMaxResponseBytes(4, LimitTruncate)
+	if w := serveMock(s, "Service1.Multiply", ""); w.Body != "1000"+TruncatedMarker {
+		t.Errorf("Expected truncated response, got %q", w.Body)
+	}
+
+	s.SetMaxResponseBytes(7, LimitError)
+	if w := serveMock(s, "Service1.Multiply", ""); w.Body != "1000000" {
+		t.Errorf("Wrong response: %q", w.Body)
+	}
+}
+
+func TestServiceInfo(t *testing.T) {
+	s := NewServer()
+	s.RegisterService(new(Service1), "")
+	if !s.HasService("Service1") || s.HasService("Service2") {
+		t.Error("Expected only Service1 to be registered")
+	}
+	info, ok := s.ServiceInfo("Service1")
+	if !ok || info.Name != "Service1" || info.ReceiverType != reflect.TypeOf(&Service1{}) {
+		t.Errorf("Wrong info %+v", info)
+	}
+	if len(info.Methods) != 2 || info.Methods[0] != "Multiply" || info.Methods[1] != "MultiplyWithHeaders" {
+		t.Errorf("Wrong methods %v", info.Methods)
+	}
+	if info.Filtered || info.Strict {
+		t.Errorf("Expected default registration settings, got %+v", info)
+	}
+
+	s.StrictRegistration(true)
+	s.RegisterService(new(Service1), "Filtered", MethodPrefix("MultiplyWith"))
+	s.RegisterService(new(ListedService), "Listed")
+	for _, name := range []string{"Filtered", "Listed"} {
+		if info, _ := s.ServiceInfo(name); !info.Filtered || !info.Strict {
+			t.Errorf("Expected filtered strict registration of %s, got %+v", name, info)
+		}
+	}
+}
+
+type infoService struct {
+	info *RequestInfo
+}
+
+func (t *infoService) Get(r *http.Request, req *Service1Request, res *Service1Response) error {
+	t.info = RequestInfoFromContext(r.Context())
+	return nil
+}
+
+func TestRequestInfoContext(t *testing.T) {
+	s := NewServer()
+	service := new(infoService)
+	s.RegisterService(service, "Info")
+	s.RegisterCodec(MockCodec{2, 3}, "mock")
+	var before *RequestInfo
+	s.RegisterBeforeFunc(func(i *RequestInfo) {
+		before = i
+	})
+	serveMock(s, "Info.Get", "1.2.3.4:1234")
+	info := service.info
+	if info == nil || info != before {
+		t.Fatalf("Expected the info of the hooks in the context, got %+v", info)
+	}
+	if info.Method != "Info.Get" || info.RemoteAddr != "1.2.3.4:1234" || info.Codec != "mock" {
+		t.Errorf("Wrong info %+v", info)
+	}
+}
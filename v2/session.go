@@ -0,0 +1,257 @@
+// Copyright 2009 The Go Authors. All rights reserved.
+// Copyright 2012 The Gorilla Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package rpc
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"net"
+	"sort"
+	"sync"
+	"time"
+)
+
+// ErrNotDuplex is returned when calling the client of a session whose
+// transport doesn't support server-initiated calls.
+var ErrNotDuplex = errors.New("rpc: session transport doesn't support calls to the client")
+
+// ErrUnknownSession is returned when there's no active session with an id.
+var ErrUnknownSession = errors.New("rpc: unknown session")
+
+// Caller calls methods exposed by the other side of a connection.
+type Caller interface {
+	// Call calls the method with args and decodes the result into reply.
+	Call(ctx context.Context, method string, args, reply interface{}) error
+}
+
+// SessionConn is implemented by stateful transports, e.g. WebSocket or TCP,
+// to push messages to and close the connection of a Session.
+type SessionConn interface {
+	// Notify sends a notification to the client.
+	Notify(method string, params interface{}) error
+	// Close closes the connection.
+	Close() error
+}
+
+// RemoteAddrConn is implemented by SessionConns knowing the address of
+// their client, reported in SessionInfo.
+type RemoteAddrConn interface {
+	RemoteAddr() net.Addr
+}
+
+// Session is a client connection of a stateful transport. It's available to
+// the methods called through it using SessionFromContext.
+//
+// On duplex transports the session is also a Caller, letting methods call
+// back the client, e.g. to ask for a confirmation.
+type Session struct {
+	id       string
+	conn     SessionConn
+	mutex    sync.RWMutex
+	metadata map[string]interface{}
+	done     chan struct{}
+	onEnd    []func()
+	started  time.Time
+}
+
+// NewSession returns a new Session for the connection. It's meant to be
+// called by transports. If id is empty, a random one is generated.
+func NewSession(id string, conn SessionConn) *Session {
+	if id == "" {
+		id = newID()
+	}
+	return &Session{
+		id:       id,
+		conn:     conn,
+		metadata: make(map[string]interface{}),
+		done:     make(chan struct{}),
+		started:  time.Now(),
+	}
+}
+
+// newID returns a random hex id.
+func newID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// ID returns the session id, unique within the transport.
+func (s *Session) ID() string {
+	return s.id
+}
+
+// SessionInfo describes an active session, e.g. for operators.
+type SessionInfo struct {
+	ID         string                 `json:"id"`
+	RemoteAddr string                 `json:"remoteAddr,omitempty"`
+	Started    time.Time              `json:"started"`
+	Metadata   map[string]interface{} `json:"metadata,omitempty"`
+}
+
+// Info returns the description of the session.
+func (s *Session) Info() SessionInfo {
+	info := SessionInfo{ID: s.id, Started: s.started, Metadata: s.Metadata()}
+	if c, ok := s.conn.(RemoteAddrConn); ok {
+		info.RemoteAddr = c.RemoteAddr().String()
+	}
+	return info
+}
+
+// Get returns the metadata value stored for key, or nil.
+func (s *Session) Get(key string) interface{} {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	return s.metadata[key]
+}
+
+// Set stores a metadata value for key, e.g. the authenticated user.
+func (s *Session) Set(key string, value interface{}) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.metadata[key] = value
+}
+
+// Metadata returns a copy of the session metadata.
+func (s *Session) Metadata() map[string]interface{} {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	m := make(map[string]interface{}, len(s.metadata))
+	for k, v := range s.metadata {
+		m[k] = v
+	}
+	return m
+}
+
+// Notify sends a notification to the client of the session.
+func (s *Session) Notify(method string, params interface{}) error {
+	return s.conn.Notify(method, params)
+}
+
+// Call calls a method exposed by the client of the session and waits for
+// its reply, if the transport is duplex: its SessionConn implements Caller.
+// Otherwise ErrNotDuplex is returned.
+func (s *Session) Call(ctx context.Context, method string, args, reply interface{}) error {
+	c, ok := s.conn.(Caller)
+	if !ok {
+		return ErrNotDuplex
+	}
+	return c.Call(ctx, method, args, reply)
+}
+
+// Close closes the session connection.
+func (s *Session) Close() error {
+	return s.conn.Close()
+}
+
+// OnEnd registers a function called when the session ends, e.g. to clean
+// up the subscriptions of the client. If the session already ended, f is
+// called immediately.
+func (s *Session) OnEnd(f func()) {
+	s.mutex.Lock()
+	select {
+	case <-s.done:
+		s.mutex.Unlock()
+		f()
+		return
+	default:
+	}
+	s.onEnd = append(s.onEnd, f)
+	s.mutex.Unlock()
+}
+
+// Done returns a channel closed when the session ends.
+func (s *Session) Done() <-chan struct{} {
+	return s.done
+}
+
+// End marks the session as ended and calls the functions registered with
+// OnEnd. It's called by transports when the connection is gone; subsequent
+// calls do nothing.
+func (s *Session) End() {
+	s.mutex.Lock()
+	select {
+	case <-s.done:
+		s.mutex.Unlock()
+		return
+	default:
+	}
+	close(s.done)
+	onEnd := s.onEnd
+	s.onEnd = nil
+	s.mutex.Unlock()
+	for _, f := range onEnd {
+		f()
+	}
+}
+
+// sessions are the active sessions of the transports of a server, by id.
+type sessions struct {
+	mutex sync.Mutex
+	byID  map[string]*Session
+}
+
+// AddSession makes the server track the session until it ends, see
+// Sessions. It's meant to be called by transports.
+func (s *Server) AddSession(session *Session) {
+	s.sessions.mutex.Lock()
+	if s.sessions.byID == nil {
+		s.sessions.byID = make(map[string]*Session)
+	}
+	s.sessions.byID[session.id] = session
+	s.sessions.mutex.Unlock()
+	session.OnEnd(func() {
+		s.sessions.mutex.Lock()
+		defer s.sessions.mutex.Unlock()
+		if s.sessions.byID[session.id] == session {
+			delete(s.sessions.byID, session.id)
+		}
+	})
+}
+
+// Session returns the active session with the id, e.g. to close the
+// connection of an abusive client, or nil.
+func (s *Server) Session(id string) *Session {
+	s.sessions.mutex.Lock()
+	defer s.sessions.mutex.Unlock()
+	return s.sessions.byID[id]
+}
+
+// Sessions returns the active sessions of the stateful transports serving
+// calls with the server, sorted by id.
+func (s *Server) Sessions() []*Session {
+	s.sessions.mutex.Lock()
+	list := make([]*Session, 0, len(s.sessions.byID))
+	for _, session := range s.sessions.byID {
+		list = append(list, session)
+	}
+	s.sessions.mutex.Unlock()
+	sort.Sort(sessionsByID(list))
+	return list
+}
+
+// sessionsByID sorts sessions by id.
+type sessionsByID []*Session
+
+func (s sessionsByID) Len() int           { return len(s) }
+func (s sessionsByID) Less(i, j int) bool { return s[i].id < s[j].id }
+func (s sessionsByID) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
+
+type sessionKey struct{}
+
+// NewSessionContext returns a copy of ctx carrying the session.
+func NewSessionContext(ctx context.Context, s *Session) context.Context {
+	return context.WithValue(ctx, sessionKey{}, s)
+}
+
+// SessionFromContext returns the session carried by ctx, or nil if the
+// request wasn't received by a stateful transport.
+func SessionFromContext(ctx context.Context) *Session {
+	s, _ := ctx.Value(sessionKey{}).(*Session)
+	return s
+}
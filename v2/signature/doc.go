@@ -0,0 +1,31 @@
+// Copyright 2009 The Go Authors. All rights reserved.
+// Copyright 2012 The Gorilla Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+/*
+Package gorilla/rpc/v2/signature signs RPC responses with detached
+signatures, so that clients behind untrusted intermediaries, e.g. caches or
+gateways, can verify that a response body was produced by the server.
+
+The server wraps its handler; each response carries the signature of its
+body in the SignatureHeader:
+
+	keys := signature.NewStaticKeys(signature.NewEd25519Key("2024-01", priv))
+	http.Handle("/rpc", signature.Handler(s, keys))
+
+	X-Rpc-Signature: keyid="2024-01", alg="ed25519", sig="base64..."
+
+Clients verify the signatures with the public keys, e.g. by plugging
+a Transport in their HTTP client:
+
+	keys := signature.NewStaticKeys(signature.NewEd25519PublicKey("2024-01", pub))
+	c := json2.NewClient(url)
+	c.HTTPClient = &http.Client{Transport: &signature.Transport{Keys: keys}}
+
+Ed25519 keys need Go 1.13 or later. HMAC-SHA256 keys, shared by the server
+and the clients, are supported too.
+Keys are looked up by id, so they can be rotated by serving with a new key
+while clients know both.
+*/
+package signature
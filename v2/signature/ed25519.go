@@ -0,0 +1,46 @@
+// Copyright 2009 The Go Authors. All rights reserved.
+// Copyright 2012 The Gorilla Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build go1.13
+// +build go1.13
+
+package signature
+
+import "crypto/ed25519"
+
+// ed25519Key is an Ed25519 key pair, or only its public key.
+type ed25519Key struct {
+	id   string
+	priv ed25519.PrivateKey
+	pub  ed25519.PublicKey
+}
+
+// NewEd25519Key returns an Ed25519 key signing with priv.
+func NewEd25519Key(id string, priv ed25519.PrivateKey) Key {
+	return &ed25519Key{id: id, priv: priv, pub: priv.Public().(ed25519.PublicKey)}
+}
+
+// NewEd25519PublicKey returns an Ed25519 key verifying signatures with
+// pub. It can't sign.
+func NewEd25519PublicKey(id string, pub ed25519.PublicKey) Key {
+	return &ed25519Key{id: id, pub: pub}
+}
+
+func (k *ed25519Key) ID() string        { return k.id }
+func (k *ed25519Key) Algorithm() string { return Ed25519 }
+
+func (k *ed25519Key) Sign(body []byte) ([]byte, error) {
+	if k.priv == nil {
+		return nil, ErrCannotSign
+	}
+	return ed25519.Sign(k.priv, body), nil
+}
+
+func (k *ed25519Key) Verify(body, sig []byte) error {
+	if !ed25519.Verify(k.pub, body, sig) {
+		return ErrInvalidSignature
+	}
+	return nil
+}
@@ -0,0 +1,25 @@
+// Copyright 2009 The Go Authors. All rights reserved.
+// Copyright 2012 The Gorilla Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build go1.13
+// +build go1.13
+
+package signature
+
+import (
+	"crypto/ed25519"
+	"testing"
+)
+
+func TestSignatureEd25519(t *testing.T) {
+	pub, priv, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	testSignature(t, NewStaticKeys(NewEd25519Key("e", priv)), NewStaticKeys(NewEd25519PublicKey("e", pub)))
+	if _, err := NewEd25519PublicKey("e", nil).Sign(nil); err != ErrCannotSign {
+		t.Errorf("Expected ErrCannotSign, got %v", err)
+	}
+}
@@ -0,0 +1,257 @@
+// Copyright 2009 The Go Authors. All rights reserved.
+// Copyright 2012 The Gorilla Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package signature
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// SignatureHeader carries the signature of the response body.
+const SignatureHeader = "X-Rpc-Signature"
+
+// Algorithms of the keys.
+const (
+	HMACSHA256 = "hmac-sha256"
+	Ed25519    = "ed25519"
+)
+
+var (
+	// ErrNoSignature is returned when verifying a response without
+	// signature.
+	ErrNoSignature = errors.New("rpc: response not signed")
+	// ErrInvalidSignature is returned when verifying a response whose
+	// signature doesn't match its body.
+	ErrInvalidSignature = errors.New("rpc: invalid response signature")
+	// ErrUnknownKey is returned by KeyProviders for unknown key ids.
+	ErrUnknownKey = errors.New("rpc: unknown signature key")
+	// ErrCannotSign is returned by keys that can only verify signatures.
+	ErrCannotSign = errors.New("rpc: key cannot sign")
+)
+
+// Key signs and verifies response bodies.
+type Key interface {
+	// ID returns the id of the key.
+	ID() string
+	// Algorithm returns the signature algorithm, e.g. Ed25519.
+	Algorithm() string
+	// Sign returns the signature of body.
+	Sign(body []byte) ([]byte, error)
+	// Verify returns ErrInvalidSignature if sig isn't the signature of
+	// body.
+	Verify(body, sig []byte) error
+}
+
+// KeyProvider provides the keys signing and verifying responses.
+type KeyProvider interface {
+	// SigningKey returns the key signing the response to r.
+	SigningKey(r *http.Request) (Key, error)
+	// VerifyingKey returns the key with the given id, or ErrUnknownKey.
+	VerifyingKey(id string) (Key, error)
+}
+
+// hmacKey is an HMAC-SHA256 key.
+type hmacKey struct {
+	id     string
+	secret []byte
+}
+
+// NewHMACKey returns an HMAC-SHA256 key with the shared secret.
+func NewHMACKey(id string, secret []byte) Key {
+	return &hmacKey{id: id, secret: secret}
+}
+
+func (k *hmacKey) ID() string        { return k.id }
+func (k *hmacKey) Algorithm() string { return HMACSHA256 }
+
+func (k *hmacKey) Sign(body []byte) ([]byte, error) {
+	mac := hmac.New(sha256.New, k.secret)
+	mac.Write(body)
+	return mac.Sum(nil), nil
+}
+
+func (k *hmacKey) Verify(body, sig []byte) error {
+	expected, _ := k.Sign(body)
+	if !hmac.Equal(expected, sig) {
+		return ErrInvalidSignature
+	}
+	return nil
+}
+
+// StaticKeys is a KeyProvider signing with a single key and verifying with
+// a set of keys.
+type StaticKeys struct {
+	mutex   sync.RWMutex
+	current Key
+	keys    map[string]Key
+}
+
+// NewStaticKeys returns StaticKeys signing with current, and verifying with
+// current and the other keys, e.g. the previous ones during a rotation.
+func NewStaticKeys(current Key, others ...Key) *StaticKeys {
+	k := &StaticKeys{keys: make(map[string]Key)}
+	for _, key := range others {
+		k.keys[key.ID()] = key
+	}
+	k.Rotate(current)
+	return k
+}
+
+// Rotate signs with the key from now on. The previous keys still verify
+// signatures.
+func (k *StaticKeys) Rotate(current Key) {
+	k.mutex.Lock()
+	defer k.mutex.Unlock()
+	k.current = current
+	k.keys[current.ID()] = current
+}
+
+// SigningKey returns the current key.
+func (k *StaticKeys) SigningKey(r *http.Request) (Key, error) {
+	k.mutex.RLock()
+	defer k.mutex.RUnlock()
+	return k.current, nil
+}
+
+// VerifyingKey returns the key with the given id.
+func (k *StaticKeys) VerifyingKey(id string) (Key, error) {
+	k.mutex.RLock()
+	defer k.mutex.RUnlock()
+	if key, ok := k.keys[id]; ok {
+		return key, nil
+	}
+	return nil, ErrUnknownKey
+}
+
+// Sign signs body with the key and sets the SignatureHeader.
+func Sign(h http.Header, body []byte, key Key) error {
+	sig, err := key.Sign(body)
+	if err != nil {
+		return err
+	}
+	h.Set(SignatureHeader, "keyid="+strconv.Quote(key.ID())+
+		", alg="+strconv.Quote(key.Algorithm())+
+		", sig="+strconv.Quote(base64.StdEncoding.EncodeToString(sig)))
+	return nil
+}
+
+// Verify verifies the signature of body in the SignatureHeader with the key
+// found by id in keys.
+func Verify(h http.Header, body []byte, keys KeyProvider) error {
+	value := h.Get(SignatureHeader)
+	if value == "" {
+		return ErrNoSignature
+	}
+	params := parseParams(value)
+	key, err := keys.VerifyingKey(params["keyid"])
+	if err != nil {
+		return err
+	}
+	if key.Algorithm() != params["alg"] {
+		return ErrInvalidSignature
+	}
+	sig, err := base64.StdEncoding.DecodeString(params["sig"])
+	if err != nil {
+		return ErrInvalidSignature
+	}
+	return key.Verify(body, sig)
+}
+
+// parseParams parses the comma-separated name="value" pairs of a header.
+func parseParams(value string) map[string]string {
+	params := make(map[string]string)
+	for _, p := range strings.Split(value, ",") {
+		i := strings.Index(p, "=")
+		if i < 0 {
+			continue
+		}
+		v := strings.TrimSpace(p[i+1:])
+		if u, err := strconv.Unquote(v); err == nil {
+			v = u
+		}
+		params[strings.TrimSpace(p[:i])] = v
+	}
+	return params
+}
+
+// Handler returns a handler signing the responses of h with the keys.
+//
+// Responses are buffered to be signed, so streamed results are sent at
+// once when the method returns.
+func Handler(h http.Handler, keys KeyProvider) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		bw := &bufferWriter{ResponseWriter: w}
+		h.ServeHTTP(bw, r)
+		key, err := keys.SigningKey(r)
+		if err == nil {
+			err = Sign(w.Header(), bw.buf.Bytes(), key)
+		}
+		if err != nil {
+			http.Error(w, "rpc: signing response: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if bw.status != 0 {
+			w.WriteHeader(bw.status)
+		}
+		w.Write(bw.buf.Bytes())
+	})
+}
+
+// bufferWriter is an http.ResponseWriter buffering the response.
+type bufferWriter struct {
+	http.ResponseWriter
+	status int
+	buf    bytes.Buffer
+}
+
+func (w *bufferWriter) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+	}
+}
+
+func (w *bufferWriter) Write(p []byte) (int, error) {
+	return w.buf.Write(p)
+}
+
+// Transport is an http.RoundTripper verifying the signatures of responses.
+// Responses that fail the verification are returned as errors.
+type Transport struct {
+	// Base sends the requests. If nil, http.DefaultTransport is used.
+	Base http.RoundTripper
+	// Keys verify the signatures.
+	Keys KeyProvider
+}
+
+// RoundTrip sends the request and verifies the signature of the response.
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	base := t.Base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	resp, err := base.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	body, err := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	if err := Verify(resp.Header, body, t.Keys); err != nil {
+		return nil, err
+	}
+	resp.Body = ioutil.NopCloser(bytes.NewReader(body))
+	return resp, nil
+}
@@ -0,0 +1,64 @@
+// Copyright 2009 The Go Authors. All rights reserved.
+// Copyright 2012 The Gorilla Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package signature
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/rpc/v2"
+	"github.com/gorilla/rpc/v2/json2"
+)
+
+type Echo struct{}
+
+func (Echo) Say(r *http.Request, args *string, reply *string) error {
+	*reply = *args
+	return nil
+}
+
+// testSignature checks that a client verifies the responses signed by a
+// server.
+func testSignature(t *testing.T, server, client KeyProvider) {
+	s := rpc.NewServer()
+	s.RegisterCodec(json2.NewCodec(), "application/json")
+	s.RegisterService(new(Echo), "")
+	ts := httptest.NewServer(Handler(s, server))
+	defer ts.Close()
+
+	c := json2.NewClient(ts.URL)
+	c.HTTPClient = &http.Client{Transport: &Transport{Keys: client}}
+	var reply string
+	if err := c.Call(context.Background(), "Echo.Say", "hello", &reply); err != nil || reply != "hello" {
+		t.Errorf("Expected verified reply, got %q %v", reply, err)
+	}
+}
+
+func TestSignature(t *testing.T) {
+	testSignature(t, NewStaticKeys(NewHMACKey("h", []byte("secret"))), NewStaticKeys(NewHMACKey("h", []byte("secret"))))
+}
+
+func TestVerify(t *testing.T) {
+	key := NewHMACKey("k1", []byte("secret"))
+	keys := NewStaticKeys(NewHMACKey("k2", []byte("other")), key)
+	h := make(http.Header)
+	if err := Verify(h, []byte("body"), keys); err != ErrNoSignature {
+		t.Errorf("Expected ErrNoSignature, got %v", err)
+	}
+	Sign(h, []byte("body"), key)
+	if err := Verify(h, []byte("body"), keys); err != nil {
+		t.Errorf("Expected valid signature, got %v", err)
+	}
+	if err := Verify(h, []byte("tampered"), keys); err != ErrInvalidSignature {
+		t.Errorf("Expected ErrInvalidSignature, got %v", err)
+	}
+	Sign(h, []byte("body"), NewHMACKey("k3", []byte("secret")))
+	if err := Verify(h, []byte("body"), keys); err != ErrUnknownKey {
+		t.Errorf("Expected ErrUnknownKey, got %v", err)
+	}
+}
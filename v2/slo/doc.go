@@ -0,0 +1,29 @@
+// Copyright 2009 The Go Authors. All rights reserved.
+// Copyright 2012 The Gorilla Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+/*
+Package gorilla/rpc/v2/slo tracks the compliance of methods of a server
+with service level objectives, over rolling windows.
+
+	t := slo.New()
+	t.Define("Orders.Create", slo.Objective{
+		Success:       0.999,
+		Latency:       200 * time.Millisecond,
+		LatencyTarget: 0.99,
+		Window:        time.Hour,
+	})
+	t.OnBurn(10, func(s slo.Status) {
+		pager.Alert("%s burns its error budget %.1fx too fast", s.Method, s.BurnRate())
+	})
+	s.RegisterMiddleware(t.Middleware)
+
+	// Burn rates as metrics.
+	expvar.Publish("slo", t)
+
+The burn rate is the rate at which a method consumes its error budget: 1
+consumes exactly the budget over the window, 10 consumes it in a tenth of
+the window.
+*/
+package slo
@@ -0,0 +1,269 @@
+// Copyright 2009 The Go Authors. All rights reserved.
+// Copyright 2012 The Gorilla Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package slo
+
+import (
+	"encoding/json"
+	"math"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/gorilla/rpc/v2"
+	"github.com/gorilla/rpc/v2/json2"
+)
+
+// DefaultWindow is the window of objectives without one.
+const DefaultWindow = time.Hour
+
+// slots is the number of slots of the rolling windows.
+const slots = 60
+
+// Objective is the service level objective of a method, e.g. 99.9% of
+// successful calls and 99% of calls faster than 200ms.
+type Objective struct {
+	// Success is the objective fraction of successful calls, e.g. 0.999.
+	// If zero, the errors aren't tracked.
+	Success float64
+	// LatencyTarget is the objective fraction of calls faster than
+	// Latency, e.g. 0.99 for a p99 objective. If zero, the latency isn't
+	// tracked.
+	Latency       time.Duration
+	LatencyTarget float64
+	// Window is the rolling window of the compliance. If zero,
+	// DefaultWindow is used.
+	Window time.Duration
+	// MinRequests is the number of calls within the window before alerts
+	// are raised.
+	MinRequests int
+
+	// IsFailure returns true if an error counts as a failure. By default
+	// all errors are failures except JSON-RPC errors other than
+	// E_INTERNAL, which are considered application errors.
+	IsFailure func(err error) bool
+}
+
+// Status is the compliance of a method with its objective within the
+// window.
+type Status struct {
+	Method   string `json:"method"`
+	Calls    int    `json:"calls"`
+	Failures int    `json:"failures"`
+	Slow     int    `json:"slow"`
+	// SuccessBurnRate and LatencyBurnRate are the rates at which the
+	// error budgets are consumed: the fractions of failed and slow calls
+	// divided by the ones allowed by the objective.
+	SuccessBurnRate float64 `json:"successBurnRate"`
+	LatencyBurnRate float64 `json:"latencyBurnRate"`
+}
+
+// BurnRate returns the highest burn rate of the status.
+func (s Status) BurnRate() float64 {
+	return math.Max(s.SuccessBurnRate, s.LatencyBurnRate)
+}
+
+// slot counts the calls of a part of the window.
+type slot struct {
+	start    time.Time
+	calls    int
+	failures int
+	slow     int
+}
+
+// tracker tracks the compliance of a method.
+type tracker struct {
+	objective Objective
+	mutex     sync.Mutex
+	slots     [slots]slot
+	alerting  bool
+}
+
+func (t *tracker) window() time.Duration {
+	if t.objective.Window > 0 {
+		return t.objective.Window
+	}
+	return DefaultWindow
+}
+
+// record records a call and returns the status of the method.
+func (t *tracker) record(method string, now time.Time, failed, slow bool) Status {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	width := t.window() / slots
+	start := now.Truncate(width)
+	s := &t.slots[start.UnixNano()/int64(width)%slots]
+	if !s.start.Equal(start) {
+		*s = slot{start: start}
+	}
+	s.calls++
+	if failed {
+		s.failures++
+	}
+	if slow {
+		s.slow++
+	}
+	return t.status(method, now)
+}
+
+// status returns the status of the method; the mutex must be held.
+func (t *tracker) status(method string, now time.Time) Status {
+	st := Status{Method: method}
+	for _, s := range t.slots {
+		if now.Sub(s.start) < t.window() {
+			st.Calls += s.calls
+			st.Failures += s.failures
+			st.Slow += s.slow
+		}
+	}
+	if st.Calls == 0 {
+		return st
+	}
+	o := t.objective
+	if o.Success > 0 && o.Success < 1 {
+		st.SuccessBurnRate = float64(st.Failures) / float64(st.Calls) / (1 - o.Success)
+	}
+	if o.LatencyTarget > 0 && o.LatencyTarget < 1 {
+		st.LatencyBurnRate = float64(st.Slow) / float64(st.Calls) / (1 - o.LatencyTarget)
+	}
+	return st
+}
+
+func (t *tracker) isFailure(err error) bool {
+	if err == nil {
+		return false
+	}
+	if t.objective.IsFailure != nil {
+		return t.objective.IsFailure(err)
+	}
+	if jsonErr, ok := err.(*json2.Error); ok {
+		return jsonErr.Code == json2.E_INTERNAL
+	}
+	return true
+}
+
+// Tracker tracks the objectives defined for methods. Methods without an
+// objective aren't tracked.
+type Tracker struct {
+	mutex     sync.RWMutex
+	trackers  map[string]*tracker
+	threshold float64
+	alert     func(Status)
+}
+
+// New returns a Tracker without any objective.
+func New() *Tracker {
+	return &Tracker{trackers: make(map[string]*tracker)}
+}
+
+// Define sets the objective of a method, replacing any previous one and
+// its history.
+func (t *Tracker) Define(method string, o Objective) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	t.trackers[method] = &tracker{objective: o}
+}
+
+// Remove removes the objective of a method.
+func (t *Tracker) Remove(method string) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	delete(t.trackers, method)
+}
+
+// OnBurn sets the function called when the burn rate of a method reaches
+// the threshold, after MinRequests calls within the window. It's called
+// once until the burn rate goes below the threshold again, from the
+// goroutine of the call that crossed it.
+//
+// Note: Only one function can be registered, subsequent calls to this
+// method will overwrite all the previous functions.
+func (t *Tracker) OnBurn(threshold float64, f func(Status)) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	t.threshold, t.alert = threshold, f
+}
+
+// Status returns the status of the method, and false if it has no
+// objective.
+func (t *Tracker) Status(method string) (Status, bool) {
+	tr := t.get(method)
+	if tr == nil {
+		return Status{}, false
+	}
+	tr.mutex.Lock()
+	defer tr.mutex.Unlock()
+	return tr.status(method, time.Now()), true
+}
+
+// Statuses returns the status of all the methods with an objective,
+// sorted by method.
+func (t *Tracker) Statuses() []Status {
+	t.mutex.RLock()
+	methods := make([]string, 0, len(t.trackers))
+	for m := range t.trackers {
+		methods = append(methods, m)
+	}
+	t.mutex.RUnlock()
+	sort.Strings(methods)
+	statuses := make([]Status, 0, len(methods))
+	for _, m := range methods {
+		if s, ok := t.Status(m); ok {
+			statuses = append(statuses, s)
+		}
+	}
+	return statuses
+}
+
+// String returns the statuses as JSON, so that the Tracker can be
+// published with expvar.
+func (t *Tracker) String() string {
+	b, _ := json.Marshal(t.Statuses())
+	return string(b)
+}
+
+func (t *Tracker) get(method string) *tracker {
+	t.mutex.RLock()
+	defer t.mutex.RUnlock()
+	return t.trackers[method]
+}
+
+// Middleware records the calls of the methods with an objective, to be
+// registered with rpc.Server.RegisterMiddleware.
+func (t *Tracker) Middleware(next rpc.CallFunc) rpc.CallFunc {
+	return func(r *http.Request, method string, args, reply interface{}) error {
+		tr := t.get(method)
+		if tr == nil {
+			return next(r, method, args, reply)
+		}
+		start := time.Now()
+		err := next(r, method, args, reply)
+		now := time.Now()
+		o := tr.objective
+		slow := o.LatencyTarget > 0 && now.Sub(start) > o.Latency
+		status := tr.record(method, now, tr.isFailure(err), slow)
+		t.check(tr, status)
+		return err
+	}
+}
+
+// check calls the alert function when the status crosses the threshold.
+func (t *Tracker) check(tr *tracker, status Status) {
+	t.mutex.RLock()
+	threshold, alert := t.threshold, t.alert
+	t.mutex.RUnlock()
+	if alert == nil {
+		return
+	}
+	burning := status.Calls >= tr.objective.MinRequests && status.BurnRate() >= threshold
+	tr.mutex.Lock()
+	crossed := burning && !tr.alerting
+	tr.alerting = burning
+	tr.mutex.Unlock()
+	if crossed {
+		alert(status)
+	}
+}
@@ -0,0 +1,75 @@
+// Copyright 2009 The Go Authors. All rights reserved.
+// Copyright 2012 The Gorilla Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package slo
+
+import (
+	"encoding/json"
+	"errors"
+	"math"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/gorilla/rpc/v2/json2"
+)
+
+func TestTracker(t *testing.T) {
+	tr := New()
+	tr.Define("Orders.Create", Objective{
+		Success:       0.9,
+		Latency:       5 * time.Millisecond,
+		LatencyTarget: 0.5,
+		Window:        time.Minute,
+		MinRequests:   4,
+	})
+	var alerts []Status
+	tr.OnBurn(2, func(s Status) {
+		alerts = append(alerts, s)
+	})
+	var err error
+	var delay time.Duration
+	call := tr.Middleware(func(r *http.Request, method string, args, reply interface{}) error {
+		time.Sleep(delay)
+		return err
+	})
+	r, _ := http.NewRequest("POST", "/", nil)
+
+	// Application errors aren't failures.
+	err = &json2.Error{Code: json2.E_BAD_PARAMS}
+	for i := 0; i < 4; i++ {
+		call(r, "Orders.Create", nil, nil)
+	}
+	if s, _ := tr.Status("Orders.Create"); s.Calls != 4 || s.Failures != 0 || s.BurnRate() != 0 {
+		t.Fatalf("Expected no burn, got %+v", s)
+	}
+
+	err = errors.New("database is down")
+	call(r, "Orders.Create", nil, nil)
+	s, _ := tr.Status("Orders.Create")
+	if s.Failures != 1 || math.Abs(s.SuccessBurnRate-2) > 1e-9 {
+		t.Fatalf("Expected a burn rate of 2, got %+v", s)
+	}
+	call(r, "Orders.Create", nil, nil)
+	if len(alerts) != 1 || alerts[0].Failures != 1 {
+		t.Errorf("Expected a single alert, got %+v", alerts)
+	}
+
+	err, delay = nil, 10*time.Millisecond
+	call(r, "Orders.Create", nil, nil)
+	if s, _ := tr.Status("Orders.Create"); s.Slow != 1 || s.LatencyBurnRate == 0 {
+		t.Errorf("Expected a slow call, got %+v", s)
+	}
+	// Methods without an objective aren't tracked.
+	call(r, "Orders.List", nil, nil)
+	if _, ok := tr.Status("Orders.List"); ok {
+		t.Error("Expected no status for Orders.List")
+	}
+
+	var statuses []Status
+	if err := json.Unmarshal([]byte(tr.String()), &statuses); err != nil || len(statuses) != 1 {
+		t.Errorf("Wrong statuses %s %v", tr.String(), err)
+	}
+}
@@ -0,0 +1,161 @@
+// Copyright 2009 The Go Authors. All rights reserved.
+// Copyright 2012 The Gorilla Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package rpc
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// ErrStreamClosed is returned when sending on a stream after its method
+// returned.
+var ErrStreamClosed = errors.New("rpc: stream closed")
+
+var typeOfStream = reflect.TypeOf((*Stream)(nil))
+
+// NDJSONContentType is the content type of streams sending bare results,
+// one JSON value per line, instead of complete responses. Clients ask for
+// it in the "Accept" header.
+const NDJSONContentType = "application/x-ndjson"
+
+// ItemEncoder is implemented by codec requests encoding the results of
+// NDJSON streams, e.g. with the options of the codec. Results are encoded
+// with encoding/json otherwise.
+type ItemEncoder interface {
+	// EncodeItem returns the JSON encoding of a result, on a single line.
+	EncodeItem(v interface{}) ([]byte, error)
+}
+
+const (
+	// StatusTrailer is the trailer of streamed responses carrying the
+	// status of the call, since the HTTP status is sent before the
+	// results: 200 if the method succeeded, or the status of its error.
+	// Streams ending without it were interrupted.
+	StatusTrailer = "Rpc-Status"
+	// ErrorTrailer is the trailer of streamed responses carrying the error
+	// returned by the method, if any.
+	ErrorTrailer = "Rpc-Error"
+)
+
+// Stream sends the results of a streaming method. Methods are streaming
+// when their reply is a *Stream:
+//
+//	func (t *Feed) Watch(r *http.Request, args *WatchArgs, s *rpc.Stream) error {
+//		for event := range t.events(r.Context(), args.Topic) {
+//			if err := s.Send(event); err != nil {
+//				return err
+//			}
+//		}
+//		return nil
+//	}
+//
+// Each result is written by the codec as a complete response, flushed
+// right away, so clients read them as they come: with JSON codecs, one
+// response per line. An error returned by the method after some results
+// were sent is written as a last error response, and the final status of
+// the call is sent in the StatusTrailer and ErrorTrailer trailers, so that
+// clients can tell an interrupted stream from a complete one. Streams are
+// best served over HTTP/2, where they don't hold a connection each.
+//
+// Clients accepting the NDJSONContentType get the bare results instead,
+// one per line, as large exports are usually consumed. Errors after the
+// first result are then only sent in the trailers.
+//
+// Methods whose reply is a channel or an iterator, e.g. *<-chan Row or
+// *iter.Seq[Row], are streamed too: the server sends the values of the
+// sequence once the method returned.
+type Stream struct {
+	w        http.ResponseWriter
+	codecReq CodecRequest
+	ctx      context.Context
+	ndjson   bool
+	mutex    sync.Mutex
+	sent     int
+	closed   bool
+}
+
+func newStream(w http.ResponseWriter, r *http.Request, codecReq CodecRequest) *Stream {
+	ndjson := strings.Contains(r.Header.Get("Accept"), NDJSONContentType)
+	return &Stream{w: w, codecReq: codecReq, ctx: r.Context(), ndjson: ndjson}
+}
+
+// Context returns the context of the call, canceled when the client goes
+// away.
+func (s *Stream) Context() context.Context {
+	return s.ctx
+}
+
+// Send writes a result and flushes it to the client.
+func (s *Stream) Send(v interface{}) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.closed {
+		return ErrStreamClosed
+	}
+	if err := s.ctx.Err(); err != nil {
+		return err
+	}
+	if s.sent == 0 {
+		s.w.Header().Set("x-content-type-options", "nosniff")
+		s.w.Header().Add("Trailer", StatusTrailer)
+		s.w.Header().Add("Trailer", ErrorTrailer)
+		if s.ndjson {
+			s.w.Header().Set("Content-Type", NDJSONContentType)
+		}
+	}
+	if s.ndjson {
+		if err := s.writeItem(v); err != nil {
+			return err
+		}
+	} else {
+		s.codecReq.WriteResponse(s.w, v)
+	}
+	s.sent++
+	if f, ok := s.w.(http.Flusher); ok {
+		f.Flush()
+	}
+	return nil
+}
+
+// writeItem writes a result on a line of an NDJSON stream.
+func (s *Stream) writeItem(v interface{}) error {
+	var b []byte
+	var err error
+	if enc, ok := s.codecReq.(ItemEncoder); ok {
+		b, err = enc.EncodeItem(v)
+	} else {
+		b, err = json.Marshal(v)
+	}
+	if err != nil {
+		return &EncodeError{Err: err}
+	}
+	_, err = s.w.Write(append(b, '\n'))
+	return err
+}
+
+// close closes the stream and returns true if results were sent.
+func (s *Stream) close() (started bool) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.closed = true
+	return s.sent > 0
+}
+
+// writeTrailer sets the trailers of the final status of a stream whose
+// results were sent.
+func (s *Stream) writeTrailer(status int, err error) {
+	h := s.w.Header()
+	h.Set(StatusTrailer, strconv.Itoa(status))
+	if err != nil {
+		h.Set(ErrorTrailer, err.Error())
+	}
+}
@@ -0,0 +1,24 @@
+// Copyright 2009 The Go Authors. All rights reserved.
+// Copyright 2012 The Gorilla Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+/*
+Package gorilla/rpc/v2/tcp serves JSON-RPC 2.0 calls over plain TCP
+connections, one JSON value per message.
+
+	s := rpc.NewServer()
+	s.RegisterCodec(json2.NewCodec(), "application/json")
+	s.RegisterService(new(HelloService), "")
+
+	l, err := net.Listen("tcp", ":4000")
+	if err != nil {
+		log.Fatal(err)
+	}
+	log.Fatal(tcp.NewTransport(s).Serve(l))
+
+Each connection is a session: methods can get it with
+rpc.SessionFromContext(r.Context()) to keep per-connection state or to push
+notifications to the client.
+*/
+package tcp
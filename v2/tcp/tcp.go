@@ -0,0 +1,179 @@
+// Copyright 2009 The Go Authors. All rights reserved.
+// Copyright 2012 The Gorilla Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package tcp
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"sync"
+
+	"github.com/gorilla/rpc/v2"
+	"github.com/gorilla/rpc/v2/json2"
+)
+
+// notification is a JSON-RPC 2.0 notification sent to the client.
+type notification struct {
+	Version string      `json:"jsonrpc"`
+	Method  string      `json:"method"`
+	Params  interface{} `json:"params,omitempty"`
+}
+
+// Transport serves JSON-RPC 2.0 calls received over TCP connections using
+// an rpc.Server. Messages are JSON values sent one after the other on the
+// connection; responses are written followed by a newline.
+//
+// Each connection is an rpc.Session, available to the methods through
+// rpc.SessionFromContext(r.Context()). Calls are served concurrently and
+// responses are sent as soon as they are ready, so they may be out of order.
+// The connection is duplex: methods can call back the client with the
+// session Call method.
+type Transport struct {
+	// Server serves the calls.
+	Server *rpc.Server
+	// ContentType selects the server codec. If empty, "application/json"
+	// is used.
+	ContentType string
+	// OnConnect is called when a session starts.
+	OnConnect func(*rpc.Session)
+	// OnDisconnect is called when a session ends.
+	OnDisconnect func(*rpc.Session)
+	// Heartbeat, if set, pings the clients to detect dead connections.
+	// Pings are "rpc.ping" requests: any response, even an error, counts
+	// as an answer.
+	Heartbeat *rpc.Heartbeat
+}
+
+// NewTransport returns a new Transport serving calls with s.
+func NewTransport(s *rpc.Server) *Transport {
+	return &Transport{Server: s}
+}
+
+// Serve accepts connections on the listener and serves each of them in a
+// new goroutine. It returns when the listener fails.
+func (t *Transport) Serve(l net.Listener) error {
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			return err
+		}
+		go t.ServeConn(conn)
+	}
+}
+
+// ServeConn serves the calls received on the connection until it's closed.
+func (t *Transport) ServeConn(conn net.Conn) {
+	c := &sessionConn{conn: conn}
+	session := rpc.NewSession("", c)
+	t.Server.AddSession(session)
+	defer c.Close()
+	defer c.calls.Close()
+	if t.OnConnect != nil {
+		t.OnConnect(session)
+	}
+	if t.OnDisconnect != nil {
+		defer t.OnDisconnect(session)
+	}
+	defer session.End()
+	if t.Heartbeat != nil {
+		go t.Heartbeat.Run(session.Done(), c.ping, func() { c.Close() })
+	}
+
+	// Template for the requests of each message.
+	req, err := http.NewRequest("POST", "tcp://"+conn.LocalAddr().String(), nil)
+	if err != nil {
+		return
+	}
+	// The calls are canceled when the connection is lost.
+	ctx, cancel := context.WithCancel(rpc.NewSessionContext(req.Context(), session))
+	req = req.WithContext(ctx)
+	req.RemoteAddr = conn.RemoteAddr().String()
+	contentType := t.ContentType
+	if contentType == "" {
+		contentType = "application/json"
+	}
+	req.Header.Set("Content-Type", contentType)
+
+	var wg sync.WaitGroup
+	defer func() {
+		// Unblock the handlers waiting for the client before waiting
+		// for them.
+		c.calls.Close()
+		cancel()
+		wg.Wait()
+	}()
+	dec := json.NewDecoder(conn)
+	for {
+		var data json.RawMessage
+		if err := dec.Decode(&data); err != nil {
+			return
+		}
+		if c.calls.Deliver(data) {
+			continue
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if res := t.Server.ServeMessage(req, data); len(res) > 0 {
+				c.write(res)
+			}
+		}()
+	}
+}
+
+// sessionConn is the rpc.SessionConn of a TCP connection.
+type sessionConn struct {
+	conn  net.Conn
+	mutex sync.Mutex
+	calls json2.PendingCalls
+}
+
+func (c *sessionConn) write(data []byte) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if data[len(data)-1] != '\n' {
+		data = append(data, '\n')
+	}
+	_, err := c.conn.Write(data)
+	return err
+}
+
+// Notify sends a JSON-RPC 2.0 notification.
+func (c *sessionConn) Notify(method string, params interface{}) error {
+	b, err := json.Marshal(&notification{Version: json2.Version, Method: method, Params: params})
+	if err != nil {
+		return err
+	}
+	return c.write(b)
+}
+
+// Call calls a method exposed by the client: requests sent by the server
+// are JSON-RPC 2.0 requests, like the ones sent by the client.
+func (c *sessionConn) Call(ctx context.Context, method string, args, reply interface{}) error {
+	return c.calls.Call(ctx, c.write, method, args, reply)
+}
+
+// ping calls "rpc.ping" on the client. Errors returned by the client mean
+// it's alive.
+func (c *sessionConn) ping(ctx context.Context) error {
+	var reply interface{}
+	err := c.Call(ctx, "rpc.ping", nil, &reply)
+	if _, ok := err.(*json2.Error); ok || err == json2.ErrNullResult {
+		return nil
+	}
+	return err
+}
+
+// Close closes the connection.
+func (c *sessionConn) Close() error {
+	return c.conn.Close()
+}
+
+// RemoteAddr returns the address of the client, see rpc.RemoteAddrConn.
+func (c *sessionConn) RemoteAddr() net.Addr {
+	return c.conn.RemoteAddr()
+}
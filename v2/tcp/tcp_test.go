@@ -0,0 +1,198 @@
+// Copyright 2009 The Go Authors. All rights reserved.
+// Copyright 2012 The Gorilla Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package tcp
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/gorilla/rpc/v2"
+	"github.com/gorilla/rpc/v2/json2"
+)
+
+type CounterRequest struct {
+	Delta int
+}
+
+type CounterResponse struct {
+	Count int
+}
+
+// Counter keeps a count per session.
+type Counter struct {
+}
+
+func (c *Counter) Incr(r *http.Request, req *CounterRequest, res *CounterResponse) error {
+	session := rpc.SessionFromContext(r.Context())
+	if session == nil {
+		return errors.New("no session")
+	}
+	count, _ := session.Get("count").(int)
+	count += req.Delta
+	session.Set("count", count)
+	res.Count = count
+	return session.Notify("Counter.Changed", count)
+}
+
+func TestTransport(t *testing.T) {
+	s := rpc.NewServer()
+	s.RegisterCodec(json2.NewCodec(), "application/json")
+	s.RegisterService(new(Counter), "")
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	disconnected := make(chan *rpc.Session, 1)
+	tr := NewTransport(s)
+	tr.OnDisconnect = func(s *rpc.Session) {
+		disconnected <- s
+	}
+	go tr.Serve(l)
+
+	conn, err := net.Dial("tcp", l.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := bufio.NewReader(conn)
+	for i := 1; i <= 2; i++ {
+		buf, _ := json2.EncodeClientRequest("Counter.Incr", &CounterRequest{2})
+		conn.Write(buf)
+
+		// The notification is sent before the response.
+		line, err := r.ReadBytes('\n')
+		if err != nil {
+			t.Fatal(err)
+		}
+		var n struct {
+			Method string
+			Params int
+		}
+		if err := json.Unmarshal(line, &n); err != nil || n.Method != "Counter.Changed" || n.Params != 2*i {
+			t.Errorf("Wrong notification: %s", line)
+		}
+
+		line, err = r.ReadBytes('\n')
+		if err != nil {
+			t.Fatal(err)
+		}
+		var res CounterResponse
+		if err := json2.DecodeClientResponse(bytes.NewReader(line), &res); err != nil {
+			t.Fatal(err)
+		}
+		if res.Count != 2*i {
+			t.Errorf("Wrong response: %d", res.Count)
+		}
+	}
+	sessions := s.Sessions()
+	if len(sessions) != 1 || sessions[0].Info().RemoteAddr != conn.LocalAddr().String() {
+		t.Errorf("Expected the session to be listed, got %v", sessions)
+	}
+	conn.Close()
+	if session := <-disconnected; session.Get("count") != 4 {
+		t.Errorf("Wrong session count: %v", session.Get("count"))
+	}
+}
+
+func TestHeartbeat(t *testing.T) {
+	s := rpc.NewServer()
+	s.RegisterCodec(json2.NewCodec(), "application/json")
+	tr := NewTransport(s)
+	tr.Heartbeat = &rpc.Heartbeat{Interval: 10 * time.Millisecond, MaxMissed: 2}
+	ended := make(chan *rpc.Session, 1)
+	tr.OnDisconnect = func(session *rpc.Session) {
+		ended <- session
+	}
+
+	// A client answering pings, even with an error, stays connected.
+	server, client := net.Pipe()
+	go tr.ServeConn(server)
+	go func(client net.Conn) {
+		dec := json.NewDecoder(client)
+		for {
+			var req struct {
+				Id json.RawMessage `json:"id"`
+			}
+			if err := dec.Decode(&req); err != nil {
+				return
+			}
+			client.Write([]byte(`{"jsonrpc":"2.0","error":{"code":-32601,"message":"no"},"id":` + string(req.Id) + `}`))
+		}
+	}(client)
+	select {
+	case <-ended:
+		t.Fatal("Expected live session to stay connected")
+	case <-time.After(50 * time.Millisecond):
+	}
+	client.Close()
+	<-ended
+
+	// A client ignoring pings is disconnected.
+	server, client = net.Pipe()
+	go tr.ServeConn(server)
+	go io.Copy(ioutil.Discard, client)
+	select {
+	case session := <-ended:
+		select {
+		case <-session.Done():
+		default:
+			t.Error("Expected session to be done")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Expected dead session to be ended")
+	}
+}
+
+// Waiter calls the client back and waits for its answer.
+type Waiter struct {
+	errs chan error
+}
+
+func (w *Waiter) Wait(r *http.Request, req *struct{}, res *struct{}) error {
+	var reply interface{}
+	err := rpc.SessionFromContext(r.Context()).Call(r.Context(), "Client.Answer", nil, &reply)
+	w.errs <- err
+	return err
+}
+
+func TestDisconnectDuringCall(t *testing.T) {
+	s := rpc.NewServer()
+	s.RegisterCodec(json2.NewCodec(), "application/json")
+	waiter := &Waiter{errs: make(chan error, 1)}
+	s.RegisterService(waiter, "")
+	tr := NewTransport(s)
+
+	server, client := net.Pipe()
+	done := make(chan struct{})
+	go func() {
+		tr.ServeConn(server)
+		close(done)
+	}()
+	buf, _ := json2.EncodeClientRequest("Waiter.Wait", &struct{}{})
+	client.Write(buf)
+	// The client gets the call back and disconnects without answering.
+	if _, err := bufio.NewReader(client).ReadBytes('\n'); err != nil {
+		t.Fatal(err)
+	}
+	client.Close()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Expected the connection to be served until the disconnection")
+	}
+	if err := <-waiter.errs; err == nil {
+		t.Error("Expected the call back to fail")
+	}
+}
@@ -0,0 +1,67 @@
+// Copyright 2009 The Go Authors. All rights reserved.
+// Copyright 2012 The Gorilla Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package rpc
+
+import (
+	"context"
+	"errors"
+	"net/http"
+)
+
+// TenantHeader is the header carrying the tenant of calls between
+// services.
+const TenantHeader = "X-Tenant-Id"
+
+// ErrNoTenant is returned by HeaderTenant for requests without a tenant.
+var ErrNoTenant = errors.New("rpc: missing tenant")
+
+type tenantKey struct{}
+
+// WithTenant returns a context carrying the tenant. The clients of this
+// package send it in the TenantHeader of outgoing calls, see Propagate.
+func WithTenant(ctx context.Context, tenant string) context.Context {
+	return context.WithValue(ctx, tenantKey{}, tenant)
+}
+
+// TenantFrom returns the tenant carried by the context, and false if
+// there's none.
+func TenantFrom(ctx context.Context) (string, bool) {
+	tenant, ok := ctx.Value(tenantKey{}).(string)
+	return tenant, ok && tenant != ""
+}
+
+// TenantMiddleware returns a middleware setting the tenant of the calls in
+// their context, to be registered with Server.RegisterMiddleware before
+// the middlewares and methods depending on it. resolve returns the tenant
+// of a request, e.g. from its authenticated credentials or host; calls
+// for which it fails get its error.
+//
+// Requests already carrying a tenant in their context, e.g. set by an
+// InterceptFunc, keep it.
+func TenantMiddleware(resolve func(r *http.Request) (string, error)) Middleware {
+	return func(next CallFunc) CallFunc {
+		return func(r *http.Request, method string, args, reply interface{}) error {
+			if _, ok := TenantFrom(r.Context()); !ok {
+				tenant, err := resolve(r)
+				if err != nil {
+					return err
+				}
+				r = r.WithContext(WithTenant(r.Context(), tenant))
+			}
+			return next(r, method, args, reply)
+		}
+	}
+}
+
+// HeaderTenant returns the tenant of the TenantHeader of the request, to
+// be used with TenantMiddleware by services only reachable by trusted
+// callers, since clients can set any tenant.
+func HeaderTenant(r *http.Request) (string, error) {
+	if tenant := r.Header.Get(TenantHeader); tenant != "" {
+		return tenant, nil
+	}
+	return "", ErrNoTenant
+}
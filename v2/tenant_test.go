@@ -0,0 +1,49 @@
+// Copyright 2009 The Go Authors. All rights reserved.
+// Copyright 2012 The Gorilla Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package rpc
+
+import (
+	"context"
+	"net/http"
+	"testing"
+)
+
+func TestTenant(t *testing.T) {
+	s := NewServer()
+	s.RegisterCodec(MockCodec{A: 1, B: 2}, "mock")
+	s.RegisterService(new(Service1), "")
+	s.RegisterMiddleware(TenantMiddleware(HeaderTenant))
+	var tenant string
+	s.RegisterMiddleware(func(next CallFunc) CallFunc {
+		return func(r *http.Request, method string, args, reply interface{}) error {
+			tenant, _ = TenantFrom(r.Context())
+			return next(r, method, args, reply)
+		}
+	})
+
+	r, _ := http.NewRequest("POST", "Service1.Multiply", nil)
+	r.Header.Set("Content-Type", "mock")
+	r.Header.Set(TenantHeader, "acme")
+	w := NewMockResponseWriter()
+	s.ServeHTTP(w, r)
+	if w.Status != http.StatusOK || tenant != "acme" {
+		t.Errorf("Expected the tenant acme, got %d %q", w.Status, tenant)
+	}
+
+	w = serveMock(s, "Service1.Multiply", "")
+	if w.Status != http.StatusBadRequest || w.Body != ErrNoTenant.Error() {
+		t.Errorf("Expected the missing tenant error, got %d %s", w.Status, w.Body)
+	}
+
+	h := make(http.Header)
+	Propagate(WithTenant(context.Background(), "acme"), h)
+	if got := h.Get(TenantHeader); got != "acme" {
+		t.Errorf("Expected the tenant header to be propagated, got %q", got)
+	}
+	if _, ok := TenantFrom(context.Background()); ok {
+		t.Error("Expected no tenant")
+	}
+}
@@ -0,0 +1,81 @@
+// Copyright 2009 The Go Authors. All rights reserved.
+// Copyright 2012 The Gorilla Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package rpc
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// Throttle is the state of a limit applied to a call, sent to the client
+// in the Retry-After and RateLimit-* headers, so that it can slow down
+// before being rejected and knows when to retry when it is.
+//
+// The server sets them for the rate limits of its Config; middlewares
+// rejecting calls, e.g. admission queues or quotas, set them with
+// ResponseMeta.SetThrottle.
+type Throttle struct {
+	// Limit is the number of calls allowed by the limit, and Remaining the
+	// number of calls still allowed. Both are ignored if Limit is zero.
+	Limit     int
+	Remaining int
+	// Reset is the time until the limit is fully restored.
+	Reset time.Duration
+	// RetryAfter, set for rejected calls, is the time after which the
+	// call can be retried.
+	RetryAfter time.Duration
+}
+
+// SetHeaders sets the RateLimit-Limit, RateLimit-Remaining and
+// RateLimit-Reset headers of the limit, and the Retry-After header of
+// rejected calls, all in seconds.
+func (t Throttle) SetHeaders(h http.Header) {
+	if t.Limit > 0 {
+		h.Set("RateLimit-Limit", strconv.Itoa(t.Limit))
+		h.Set("RateLimit-Remaining", strconv.Itoa(t.Remaining))
+		h.Set("RateLimit-Reset", seconds(t.Reset))
+	}
+	if t.RetryAfter > 0 {
+		h.Set("Retry-After", seconds(t.RetryAfter))
+	}
+}
+
+// SetThrottle sets the throttling headers of the response, see Throttle.
+func (m *ResponseMeta) SetThrottle(t Throttle) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	if m.header == nil {
+		m.header = make(http.Header)
+	}
+	t.SetHeaders(m.header)
+}
+
+// seconds returns d in whole seconds, rounded up.
+func seconds(d time.Duration) string {
+	return strconv.FormatInt(int64(math.Ceil(d.Seconds())), 10)
+}
+
+// ParseRetryAfter parses the Retry-After header of a response, in seconds
+// or as an HTTP date, and returns false if it's missing or invalid.
+func ParseRetryAfter(h http.Header, now time.Time) (time.Duration, bool) {
+	v := h.Get("Retry-After")
+	if v == "" {
+		return 0, false
+	}
+	if n, err := strconv.Atoi(v); err == nil && n >= 0 {
+		return time.Duration(n) * time.Second, true
+	}
+	t, err := http.ParseTime(v)
+	if err != nil {
+		return 0, false
+	}
+	if d := t.Sub(now); d > 0 {
+		return d, true
+	}
+	return 0, true
+}
@@ -0,0 +1,55 @@
+// Copyright 2009 The Go Authors. All rights reserved.
+// Copyright 2012 The Gorilla Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package rpc
+
+import (
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestThrottleHeaders(t *testing.T) {
+	s := NewServer()
+	s.RegisterService(new(Service1), "")
+	s.RegisterCodec(MockCodec{2, 3}, "mock")
+	err := s.SetConfig(&Config{
+		Methods: map[string]*MethodConfig{
+			"Service1.Multiply": {RateLimit: 0.5, RateBurst: 2},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w := serveMock(s, "Service1.Multiply", "")
+	h := w.Header()
+	if h.Get("RateLimit-Limit") != "2" || h.Get("RateLimit-Remaining") != "1" || h.Get("RateLimit-Reset") != "2" || h.Get("Retry-After") != "" {
+		t.Errorf("Wrong headers of an allowed call: %v", h)
+	}
+	serveMock(s, "Service1.Multiply", "")
+	w = serveMock(s, "Service1.Multiply", "")
+	h = w.Header()
+	if w.Status != http.StatusTooManyRequests || h.Get("RateLimit-Remaining") != "0" || h.Get("Retry-After") != "2" {
+		t.Errorf("Wrong headers of a rejected call: %d %v", w.Status, h)
+	}
+
+	now := time.Date(2026, 10, 16, 10, 0, 0, 0, time.UTC)
+	for _, test := range []struct {
+		value string
+		wait  time.Duration
+		ok    bool
+	}{
+		{"", 0, false},
+		{"3", 3 * time.Second, true},
+		{"Fri, 16 Oct 2026 10:00:30 GMT", 30 * time.Second, true},
+		{"soon", 0, false},
+	} {
+		h := http.Header{"Retry-After": {test.value}}
+		if wait, ok := ParseRetryAfter(h, now); wait != test.wait || ok != test.ok {
+			t.Errorf("ParseRetryAfter(%q) = %v %v, want %v %v", test.value, wait, ok, test.wait, test.ok)
+		}
+	}
+}
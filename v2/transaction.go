@@ -0,0 +1,140 @@
+// Copyright 2009 The Go Authors. All rights reserved.
+// Copyright 2012 The Gorilla Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package rpc
+
+import (
+	"context"
+	"errors"
+	"net/http"
+)
+
+var (
+	// ErrTransactionAborted is returned for the calls of a batch rolled
+	// back because another call failed.
+	ErrTransactionAborted = errors.New("rpc: transaction aborted")
+	// ErrMixedTransactions is returned for the calls of a batch calling
+	// methods of different transaction managers.
+	ErrMixedTransactions = errors.New("rpc: batch mixes methods of different transaction managers")
+)
+
+// TransactionManager runs groups of calls in transactions, e.g. database
+// transactions, to give them all-or-nothing semantics.
+//
+// Begin returns the context of the transaction, passed to the methods and
+// to Commit or Rollback, so they can find the transaction in it:
+//
+//	func (m *txManager) Begin(ctx context.Context) (context.Context, error) {
+//		tx, err := m.db.BeginTx(ctx, nil)
+//		if err != nil {
+//			return nil, err
+//		}
+//		return context.WithValue(ctx, txKey{}, tx), nil
+//	}
+type TransactionManager interface {
+	// Begin starts a transaction.
+	Begin(ctx context.Context) (context.Context, error)
+	// Commit commits the transaction started by Begin.
+	Commit(ctx context.Context) error
+	// Rollback rolls back the transaction started by Begin.
+	Rollback(ctx context.Context) error
+}
+
+// transactionGroup is a TransactionManager and its methods.
+type transactionGroup struct {
+	tm      TransactionManager
+	methods map[string]bool
+}
+
+// transactions are the transaction managers of a server.
+type transactions []transactionGroup
+
+// RegisterTransactionManager runs the calls of the given methods in
+// transactions of tm, or the calls of all methods if none is given.
+//
+// Single calls run in their own transaction, rolled back when the call
+// fails. The calls of a batch run in a single transaction: if a call fails,
+// the transaction is rolled back and ErrTransactionAborted is returned for
+// the other calls. A batch can't mix methods of different managers, but can
+// include methods without a manager.
+//
+// The method uses a dotted notation as in "Service.Method".
+func (s *Server) RegisterTransactionManager(tm TransactionManager, methods ...string) {
+	g := transactionGroup{tm: tm}
+	if len(methods) > 0 {
+		g.methods = make(map[string]bool, len(methods))
+		for _, method := range methods {
+			g.methods[method] = true
+		}
+	}
+	s.transactions = append(s.transactions, g)
+}
+
+// manager returns the transaction manager of the method, or nil.
+func (t transactions) manager(method string) TransactionManager {
+	for _, g := range t {
+		if g.methods == nil || g.methods[method] {
+			return g.tm
+		}
+	}
+	return nil
+}
+
+// batch returns the transaction manager of the calls of a batch, or nil.
+func (t transactions) batch(reqs []CodecRequest) (TransactionManager, error) {
+	if len(t) == 0 {
+		return nil, nil
+	}
+	var tm TransactionManager
+	for _, req := range reqs {
+		method, err := req.Method()
+		if err != nil {
+			continue
+		}
+		if m := t.manager(method); m != nil {
+			if tm != nil && tm != m {
+				return nil, ErrMixedTransactions
+			}
+			tm = m
+		}
+	}
+	return tm, nil
+}
+
+// wrap returns a CallFunc running call in a transaction of the manager of
+// the method, if any and if not already in the transaction of a batch.
+func (t transactions) wrap(method string, call CallFunc) CallFunc {
+	tm := t.manager(method)
+	if tm == nil {
+		return call
+	}
+	return func(r *http.Request, method string, args, reply interface{}) error {
+		if inTransaction(r.Context()) {
+			return call(r, method, args, reply)
+		}
+		ctx, err := tm.Begin(r.Context())
+		if err != nil {
+			return err
+		}
+		if err = call(r.WithContext(withTransaction(ctx)), method, args, reply); err != nil {
+			tm.Rollback(ctx)
+			return err
+		}
+		return tm.Commit(ctx)
+	}
+}
+
+type transactionKey struct{}
+
+// withTransaction returns a copy of ctx marking that calls run in a
+// transaction.
+func withTransaction(ctx context.Context) context.Context {
+	return context.WithValue(ctx, transactionKey{}, true)
+}
+
+func inTransaction(ctx context.Context) bool {
+	in, _ := ctx.Value(transactionKey{}).(bool)
+	return in
+}
@@ -0,0 +1,98 @@
+// Copyright 2009 The Go Authors. All rights reserved.
+// Copyright 2012 The Gorilla Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package rpc
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"strconv"
+)
+
+// UUID is an RFC 4122 UUID, for args and replies. It is encoded as its
+// canonical string by text codecs, e.g. "f47ac10b-58cc-4372-a567-0e02b2c3d479"
+// in JSON, and as its 16 bytes by binary codecs, through MarshalText and
+// MarshalBinary. Invalid UUIDs are rejected with an InvalidParamsError when
+// decoding the args.
+//
+// Types with the same layout, like github.com/google/uuid.UUID, can be
+// converted to and from UUID.
+type UUID [16]byte
+
+// NewUUID returns a random (version 4) UUID.
+func NewUUID() UUID {
+	var u UUID
+	rand.Read(u[:])
+	u[6] = u[6]&0x0f | 0x40
+	u[8] = u[8]&0x3f | 0x80
+	return u
+}
+
+// ParseUUID parses a UUID in the canonical form, in either case.
+func ParseUUID(s string) (UUID, error) {
+	var u UUID
+	if len(s) != 36 || s[8] != '-' || s[13] != '-' || s[18] != '-' || s[23] != '-' {
+		return u, invalidUUID(s)
+	}
+	src := []byte(s[:8] + s[9:13] + s[14:18] + s[19:23] + s[24:])
+	if _, err := hex.Decode(u[:], src); err != nil {
+		return u, invalidUUID(s)
+	}
+	return u, nil
+}
+
+func invalidUUID(s string) error {
+	return &InvalidParamsError{Message: "invalid UUID " + strconv.Quote(s)}
+}
+
+// IsZero returns true for the nil UUID.
+func (u UUID) IsZero() bool {
+	return u == UUID{}
+}
+
+// String returns the canonical form of the UUID.
+func (u UUID) String() string {
+	b, _ := u.MarshalText()
+	return string(b)
+}
+
+// MarshalText implements encoding.TextMarshaler.
+func (u UUID) MarshalText() ([]byte, error) {
+	b := make([]byte, 36)
+	hex.Encode(b[0:8], u[0:4])
+	b[8] = '-'
+	hex.Encode(b[9:13], u[4:6])
+	b[13] = '-'
+	hex.Encode(b[14:18], u[6:8])
+	b[18] = '-'
+	hex.Encode(b[19:23], u[8:10])
+	b[23] = '-'
+	hex.Encode(b[24:], u[10:])
+	return b, nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler.
+func (u *UUID) UnmarshalText(b []byte) error {
+	v, err := ParseUUID(string(b))
+	if err != nil {
+		return err
+	}
+	*u = v
+	return nil
+}
+
+// MarshalBinary implements encoding.BinaryMarshaler.
+func (u UUID) MarshalBinary() ([]byte, error) {
+	return append([]byte(nil), u[:]...), nil
+}
+
+// UnmarshalBinary implements encoding.BinaryUnmarshaler.
+func (u *UUID) UnmarshalBinary(b []byte) error {
+	if len(b) != len(u) {
+		return &InvalidParamsError{Message: "invalid UUID of " + strconv.Itoa(len(b)) + " bytes"}
+	}
+	copy(u[:], b)
+	return nil
+}
@@ -0,0 +1,47 @@
+// Copyright 2009 The Go Authors. All rights reserved.
+// Copyright 2012 The Gorilla Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package rpc
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestUUID(t *testing.T) {
+	u := NewUUID()
+	if u.IsZero() || u[6]>>4 != 4 || u[8]>>6 != 2 {
+		t.Errorf("Wrong random UUID %s", u)
+	}
+	const s = "f47ac10b-58cc-4372-a567-0e02b2c3d479"
+	u, err := ParseUUID("F47AC10B-58CC-4372-A567-0E02B2C3D479")
+	if err != nil || u.String() != s {
+		t.Errorf("Wrong UUID %s %v", u, err)
+	}
+	var args struct{ ID UUID }
+	if err := json.Unmarshal([]byte(`{"ID":"`+s+`"}`), &args); err != nil || args.ID != u {
+		t.Errorf("Wrong decoding %s %v", args.ID, err)
+	}
+	if b, _ := json.Marshal(args); string(b) != `{"ID":"`+s+`"}` {
+		t.Errorf("Wrong encoding %s", b)
+	}
+	for _, invalid := range []string{"", "f47ac10b58cc4372a5670e02b2c3d479", "g47ac10b-58cc-4372-a567-0e02b2c3d479", "f47ac10b-58cc-4372-a567-0e02b2c3d4790"} {
+		if _, err := ParseUUID(invalid); err == nil {
+			t.Errorf("Expected %q to be invalid", invalid)
+		}
+	}
+	err = json.Unmarshal([]byte(`{"ID":"nope"}`), &args)
+	if _, ok := err.(*InvalidParamsError); !ok {
+		t.Errorf("Expected InvalidParamsError, got %v", err)
+	}
+	b, _ := u.MarshalBinary()
+	var v UUID
+	if err := v.UnmarshalBinary(b); err != nil || v != u {
+		t.Errorf("Wrong binary decoding %s %v", v, err)
+	}
+	if err := v.UnmarshalBinary(b[1:]); err == nil {
+		t.Error("Expected an error decoding 15 bytes")
+	}
+}
@@ -0,0 +1,78 @@
+// Copyright 2009 The Go Authors. All rights reserved.
+// Copyright 2012 The Gorilla Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package rpc
+
+import (
+	"errors"
+	"net/http"
+	"reflect"
+)
+
+// InvalidReplyError is the error of a call whose reply was rejected by the
+// response validation, see RegisterValidateResponseFunc. It's reported
+// with the 500 Internal Server Error status instead of the reply, as the
+// method broke its contract, not the client.
+type InvalidReplyError struct {
+	Err error
+}
+
+func (e *InvalidReplyError) Error() string {
+	return "rpc: invalid reply: " + e.Err.Error()
+}
+
+// Unwrap returns the error of the validation.
+func (e *InvalidReplyError) Unwrap() error {
+	return e.Err
+}
+
+// HTTPStatus returns the 500 Internal Server Error status.
+func (e *InvalidReplyError) HTTPStatus() int {
+	return http.StatusInternalServerError
+}
+
+// RegisterValidateResponseFunc registers the specified function as the
+// function that will be called after the Service method succeeded and
+// before its reply is encoded. If this function returns a non-nil error,
+// the reply isn't sent and the call fails with an InvalidReplyError
+// wrapping it. The second argument of this function is the *reply
+// parameter of the method.
+//
+// Note: Only one function can be registered, subsequent calls to this
+// method will overwrite all the previous functions.
+func (s *Server) RegisterValidateResponseFunc(f func(r *RequestInfo, reply interface{}) error) {
+	s.replyValidator = f
+}
+
+// EnableResponseValidation makes the server check the struct tags of the
+// replies before encoding them, as it does for the args: values of fields
+// with an EnumTag must be among the values of their enum, see
+// RegisterEnum. Invalid replies fail with an InvalidReplyError.
+func (s *Server) EnableResponseValidation(enabled bool) {
+	s.replyValidation = enabled
+}
+
+// validateReply checks the reply of a successful call.
+func (s *Server) validateReply(i *RequestInfo, reply reflect.Value) error {
+	if s.replyValidation {
+		if err := validateEnums(reply); err != nil {
+			if e, ok := err.(*InvalidParamsError); ok {
+				// The reply isn't a params error of the client.
+				msg := e.Message
+				if e.Field != "" {
+					msg = e.Field + ": " + msg
+				}
+				err = errors.New(msg)
+			}
+			return &InvalidReplyError{Err: err}
+		}
+	}
+	if s.replyValidator != nil {
+		if err := s.replyValidator(i, reply.Interface()); err != nil {
+			return &InvalidReplyError{Err: err}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,63 @@
+// Copyright 2009 The Go Authors. All rights reserved.
+// Copyright 2012 The Gorilla Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package rpc
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+)
+
+type ShirtService struct{}
+
+func (ShirtService) Get(r *http.Request, req *Service1Request, res *ShirtArgs) error {
+	res.Color, res.Size = "green", Small
+	return nil
+}
+
+func TestValidateResponse(t *testing.T) {
+	s := NewServer()
+	s.RegisterService(new(Service1), "")
+	s.RegisterCodec(MockCodec{2, 3}, "mock")
+	var info *RequestInfo
+	s.RegisterAfterFunc(func(i *RequestInfo) {
+		info = i
+	})
+	s.RegisterValidateResponseFunc(func(i *RequestInfo, reply interface{}) error {
+		if i.Method != "Service1.Multiply" {
+			t.Errorf("Wrong method %s", i.Method)
+		}
+		if reply.(*Service1Response).Result%2 != 0 {
+			return errors.New("odd result")
+		}
+		return nil
+	})
+	if w := serveMock(s, "Service1.Multiply", ""); w.Status != 200 || w.Body != "6" {
+		t.Errorf("Expected a valid reply, got %d %s", w.Status, w.Body)
+	}
+
+	s.RegisterCodec(MockCodec{3, 3}, "mock")
+	w := serveMock(s, "Service1.Multiply", "")
+	if w.Status != 500 || w.Body != "rpc: invalid reply: odd result" {
+		t.Errorf("Expected an invalid reply, got %d %s", w.Status, w.Body)
+	}
+	if _, ok := info.Error.(*InvalidReplyError); !ok || info.StatusCode != 500 {
+		t.Errorf("Expected the invalid reply in the AfterFunc, got %+v", info)
+	}
+}
+
+func TestResponseValidation(t *testing.T) {
+	RegisterEnum("TestColor", "red", "blue")
+	RegisterEnum("TestSize", Small, Large)
+	s := NewServer()
+	s.RegisterService(new(ShirtService), "")
+	s.RegisterCodec(MockCodec{2, 3}, "mock")
+	s.EnableResponseValidation(true)
+	w := serveMock(s, "ShirtService.Get", "")
+	if w.Status != 500 || w.Body != "rpc: invalid reply: Color: green isn't one of red, blue" {
+		t.Errorf("Expected an invalid reply, got %d %s", w.Status, w.Body)
+	}
+}
@@ -0,0 +1,87 @@
+// Copyright 2009 The Go Authors. All rights reserved.
+// Copyright 2012 The Gorilla Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package rpc
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// Warmer is implemented by codecs with per type caches, filled by
+// Server.Warmup before the server serves calls.
+type Warmer interface {
+	// Warmup prepares the decoding of args and the encoding of reply,
+	// both pointer types.
+	Warmup(args, reply reflect.Type)
+}
+
+// Warmup computes ahead the reflection data used to serve the registered
+// methods, instead of on their first calls, to avoid the latency of the
+// first requests after a deploy: the enums and defaults of the args are
+// checked, the pools of the methods are primed and codecs implementing
+// Warmer prepare the encoding of the args and replies.
+//
+// It returns an error if fields are tagged with an enum that isn't
+// registered or with a default that can't be parsed, which would fail all
+// the calls.
+func (s *Server) Warmup() error {
+	for _, name := range s.Methods() {
+		m, err := s.router.Resolve(name)
+		if err != nil {
+			return err
+		}
+		if err := checkEnumTags(m.argsType, make(map[reflect.Type]bool)); err != nil {
+			return fmt.Errorf("rpc: method %q: %v", name, err)
+		}
+		if err := checkDefaultTags(m.argsType, make(map[reflect.Type]bool)); err != nil {
+			return fmt.Errorf("rpc: method %q: %v", name, err)
+		}
+		args, reply := reflect.PtrTo(m.argsType), reflect.PtrTo(m.replyType)
+		for _, codec := range s.codecs {
+			if w, ok := codec.(Warmer); ok {
+				w.Warmup(args, reply)
+			}
+		}
+		for _, p := range s.codecPatterns {
+			if w, ok := p.codec.(Warmer); ok {
+				w.Warmup(args, reply)
+			}
+		}
+		if pool := s.pools.get(name); pool != nil {
+			pool.args.Put(pool.args.Get())
+			if m.class != MethodClassStream {
+				pool.reply.Put(pool.reply.Get())
+			}
+		}
+	}
+	return nil
+}
+
+// checkEnumTags returns an error if fields of t are tagged with an enum
+// that isn't registered.
+func checkEnumTags(t reflect.Type, visited map[reflect.Type]bool) error {
+	if !hasEnums(t) || visited[t] {
+		return nil
+	}
+	visited[t] = true
+	switch t.Kind() {
+	case reflect.Ptr, reflect.Slice, reflect.Array, reflect.Map:
+		return checkEnumTags(t.Elem(), visited)
+	case reflect.Struct:
+		for i := 0; i < t.NumField(); i++ {
+			sf := t.Field(i)
+			if name := sf.Tag.Get(EnumTag); name != "" {
+				if _, ok := EnumValues(name); !ok {
+					return fmt.Errorf("enum %q of field %s isn't registered", name, sf.Name)
+				}
+			}
+			if err := checkEnumTags(sf.Type, visited); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,49 @@
+// Copyright 2009 The Go Authors. All rights reserved.
+// Copyright 2012 The Gorilla Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package rpc
+
+import (
+	"net/http"
+	"reflect"
+	"testing"
+)
+
+type warmupCodec struct {
+	MockCodec
+	types []reflect.Type
+}
+
+func (c *warmupCodec) Warmup(args, reply reflect.Type) {
+	c.types = append(c.types, args, reply)
+}
+
+type TagArgs struct {
+	Kind string `enum:"WarmupUnregistered"`
+}
+
+type TagService struct{}
+
+func (TagService) Tag(r *http.Request, args *TagArgs, reply *string) error {
+	return nil
+}
+
+func TestWarmup(t *testing.T) {
+	s := NewServer()
+	s.RegisterService(new(Service1), "")
+	codec := &warmupCodec{MockCodec: MockCodec{2, 3}}
+	s.RegisterCodec(codec, "mock")
+	if err := s.Warmup(); err != nil {
+		t.Fatal(err)
+	}
+	if len(codec.types) != 4 || codec.types[0] != reflect.TypeOf(&Service1Request{}) || codec.types[1] != reflect.TypeOf(&Service1Response{}) {
+		t.Errorf("Wrong types warmed up: %v", codec.types)
+	}
+
+	s.RegisterService(new(TagService), "")
+	if err := s.Warmup(); err == nil {
+		t.Error("Expected an error for an unregistered enum")
+	}
+}
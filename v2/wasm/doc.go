@@ -0,0 +1,35 @@
+// Copyright 2009 The Go Authors. All rights reserved.
+// Copyright 2012 The Gorilla Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+/*
+Package gorilla/rpc/v2/wasm runs method handlers in WebAssembly modules, so
+untrusted or customer-supplied logic can be executed in a sandbox.
+
+A module receives each call as a JSON object with the method name and the
+raw params, and returns a JSON object with either a result or a JSON-RPC
+error:
+
+	{"method": "Pricing.Quote", "params": {"sku": "A1"}}
+	{"result": {"price": 42}}
+	{"error": {"code": -32000, "message": "unknown sku"}}
+
+Modules run by wazero export their memory and two functions:
+
+	alloc(size i32) i32            // returns a buffer for the request
+	handle(ptr i32, size i32) i64  // returns ptr<<32 | size of the response
+
+The wazero runtime is only built with the "wazero" build tag:
+
+	binary, _ := os.ReadFile("pricing.wasm")
+	m, err := wasm.NewWazeroModule(ctx, binary, 256)
+	if err != nil {
+		return err
+	}
+	h := wasm.NewHandler(m, 0)
+	wasm.Register(s, h, "Pricing.Quote", "Pricing.List")
+
+Other runtimes can be used by implementing Module and Instance.
+*/
+package wasm
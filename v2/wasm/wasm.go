@@ -0,0 +1,144 @@
+// Copyright 2009 The Go Authors. All rights reserved.
+// Copyright 2012 The Gorilla Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package wasm
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/gorilla/rpc/v2"
+	"github.com/gorilla/rpc/v2/json2"
+)
+
+// DefaultMaxInstances is the default number of instances of a module kept
+// to serve concurrent calls.
+const DefaultMaxInstances = 8
+
+// Module is a compiled WebAssembly module that can be instantiated.
+type Module interface {
+	// Instantiate returns a new sandboxed instance of the module.
+	Instantiate(ctx context.Context) (Instance, error)
+}
+
+// Instance is an instance of a module. Instances are used by a single call
+// at a time.
+type Instance interface {
+	// Handle passes an encoded request to the instance and returns the
+	// encoded response.
+	Handle(ctx context.Context, request []byte) ([]byte, error)
+
+	// Close releases the instance.
+	Close(ctx context.Context) error
+}
+
+// request is passed to the module.
+type request struct {
+	Method string          `json:"method"`
+	Params json.RawMessage `json:"params,omitempty"`
+}
+
+// response is returned by the module.
+type response struct {
+	Result json.RawMessage `json:"result"`
+	Error  *json2.Error    `json:"error"`
+}
+
+// Handler calls a module for each call of the methods it is registered for.
+// Instances are reused across calls; an instance whose call fails is
+// discarded, so a trapped or corrupted instance is never used again.
+type Handler struct {
+	module    Module
+	instances chan Instance
+}
+
+// NewHandler returns a handler for the module keeping at most max idle
+// instances. If max is 0, DefaultMaxInstances is used.
+func NewHandler(m Module, max int) *Handler {
+	if max <= 0 {
+		max = DefaultMaxInstances
+	}
+	return &Handler{module: m, instances: make(chan Instance, max)}
+}
+
+// Call calls the module for a method with raw params and returns the raw
+// result.
+func (h *Handler) Call(ctx context.Context, method string, params json.RawMessage) (json.RawMessage, error) {
+	req, err := json.Marshal(&request{Method: method, Params: params})
+	if err != nil {
+		return nil, err
+	}
+	var inst Instance
+	select {
+	case inst = <-h.instances:
+	default:
+		if inst, err = h.module.Instantiate(ctx); err != nil {
+			return nil, err
+		}
+	}
+	data, err := inst.Handle(ctx, req)
+	if err != nil {
+		inst.Close(ctx)
+		return nil, err
+	}
+	select {
+	case h.instances <- inst:
+	default:
+		inst.Close(ctx)
+	}
+	var res response
+	if err := json.Unmarshal(data, &res); err != nil {
+		return nil, errors.New("rpc: invalid response from wasm module: " + err.Error())
+	}
+	if res.Error != nil {
+		return nil, res.Error
+	}
+	return res.Result, nil
+}
+
+// Close releases the idle instances.
+func (h *Handler) Close(ctx context.Context) {
+	for {
+		select {
+		case inst := <-h.instances:
+			inst.Close(ctx)
+		default:
+			return
+		}
+	}
+}
+
+// ----------------------------------------------------------------------------
+// Registration
+// ----------------------------------------------------------------------------
+
+// router resolves the methods handled by modules, and the other methods
+// with the router it wraps.
+type router struct {
+	methods map[string]*rpc.ServiceMethod
+	next    rpc.Router
+}
+
+func (r *router) Resolve(method string) (*rpc.ServiceMethod, error) {
+	if m := r.methods[method]; m != nil {
+		return m, nil
+	}
+	return r.next.Resolve(method)
+}
+
+// Register registers the methods into the server, each one handled by the
+// handler.
+func Register(s *rpc.Server, h *Handler, methods ...string) {
+	r := &router{methods: make(map[string]*rpc.ServiceMethod), next: s.Router()}
+	call := func(req *http.Request, method string, params json.RawMessage) (interface{}, error) {
+		return h.Call(req.Context(), method, params)
+	}
+	for _, method := range methods {
+		r.methods[method] = rpc.NewRawMethod(method, call)
+	}
+	s.RegisterRouter(r)
+}
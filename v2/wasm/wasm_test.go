@@ -0,0 +1,90 @@
+// Copyright 2009 The Go Authors. All rights reserved.
+// Copyright 2012 The Gorilla Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package wasm
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/rpc/v2"
+	"github.com/gorilla/rpc/v2/json2"
+)
+
+// mockModule doubles its "n" param, and traps when it is negative.
+type mockModule struct {
+	instances int
+}
+
+func (m *mockModule) Instantiate(ctx context.Context) (Instance, error) {
+	m.instances++
+	return &mockInstance{}, nil
+}
+
+type mockInstance struct{}
+
+func (i *mockInstance) Handle(ctx context.Context, data []byte) ([]byte, error) {
+	var req struct {
+		Method string
+		Params struct{ N int }
+	}
+	if err := json.Unmarshal(data, &req); err != nil {
+		return nil, err
+	}
+	if req.Params.N < 0 {
+		return nil, errors.New("unreachable")
+	}
+	if req.Params.N == 0 {
+		return []byte(`{"error":{"code":-32000,"message":"zero"}}`), nil
+	}
+	return json.Marshal(map[string]int{"result": req.Params.N * 2})
+}
+
+func (i *mockInstance) Close(ctx context.Context) error {
+	return nil
+}
+
+func call(s *rpc.Server, n int) (int, error) {
+	buf, _ := json2.EncodeClientRequest("Wasm.Double", map[string]int{"n": n})
+	r, _ := http.NewRequest("POST", "http://localhost:8080/", bytes.NewBuffer(buf))
+	r.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	s.ServeHTTP(w, r)
+	var res int
+	err := json2.DecodeClientResponse(w.Body, &res)
+	return res, err
+}
+
+func TestHandler(t *testing.T) {
+	m := &mockModule{}
+	h := NewHandler(m, 1)
+	s := rpc.NewServer()
+	s.RegisterCodec(json2.NewCodec(), "application/json")
+	Register(s, h, "Wasm.Double")
+
+	if res, err := call(s, 21); err != nil || res != 42 {
+		t.Fatalf("Expected 42, got %v, %v", res, err)
+	}
+	if _, err := call(s, 0); err == nil || err.(*json2.Error).Message != "zero" {
+		t.Fatalf("Expected module error, got %v", err)
+	}
+	if m.instances != 1 {
+		t.Fatalf("Expected instance to be reused, got %d instances", m.instances)
+	}
+	if _, err := call(s, -1); err == nil {
+		t.Fatal("Expected trap error")
+	}
+	if _, err := call(s, 1); err != nil {
+		t.Fatal(err)
+	}
+	if m.instances != 2 {
+		t.Fatalf("Expected trapped instance to be discarded, got %d instances", m.instances)
+	}
+}
@@ -0,0 +1,100 @@
+// Copyright 2009 The Go Authors. All rights reserved.
+// Copyright 2012 The Gorilla Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build wazero
+// +build wazero
+
+package wasm
+
+import (
+	"context"
+	"errors"
+
+	"github.com/tetratelabs/wazero"
+	"github.com/tetratelabs/wazero/api"
+	"github.com/tetratelabs/wazero/imports/wasi_snapshot_preview1"
+)
+
+// WazeroModule is a module run by the wazero runtime.
+type WazeroModule struct {
+	runtime  wazero.Runtime
+	compiled wazero.CompiledModule
+}
+
+// NewWazeroModule compiles the WebAssembly binary. Instances are limited
+// to maxPages pages of 64KiB of memory, or to the wazero default if
+// maxPages is 0, and are stopped when the context of a call is done.
+func NewWazeroModule(ctx context.Context, binary []byte, maxPages uint32) (*WazeroModule, error) {
+	config := wazero.NewRuntimeConfig().WithCloseOnContextDone(true)
+	if maxPages > 0 {
+		config = config.WithMemoryLimitPages(maxPages)
+	}
+	r := wazero.NewRuntimeWithConfig(ctx, config)
+	if _, err := wasi_snapshot_preview1.Instantiate(ctx, r); err != nil {
+		r.Close(ctx)
+		return nil, err
+	}
+	compiled, err := r.CompileModule(ctx, binary)
+	if err != nil {
+		r.Close(ctx)
+		return nil, err
+	}
+	return &WazeroModule{runtime: r, compiled: compiled}, nil
+}
+
+// Instantiate returns a new instance of the module. Instances have no
+// access to the file system, the network or the environment.
+func (m *WazeroModule) Instantiate(ctx context.Context) (Instance, error) {
+	mod, err := m.runtime.InstantiateModule(ctx, m.compiled, wazero.NewModuleConfig().WithName(""))
+	if err != nil {
+		return nil, err
+	}
+	inst := &wazeroInstance{
+		mod:    mod,
+		alloc:  mod.ExportedFunction("alloc"),
+		handle: mod.ExportedFunction("handle"),
+	}
+	if inst.alloc == nil || inst.handle == nil || mod.Memory() == nil {
+		mod.Close(ctx)
+		return nil, errors.New("rpc: wasm module must export memory, alloc and handle")
+	}
+	return inst, nil
+}
+
+// Close releases the runtime and the compiled module.
+func (m *WazeroModule) Close(ctx context.Context) error {
+	return m.runtime.Close(ctx)
+}
+
+type wazeroInstance struct {
+	mod    api.Module
+	alloc  api.Function
+	handle api.Function
+}
+
+func (i *wazeroInstance) Handle(ctx context.Context, request []byte) ([]byte, error) {
+	res, err := i.alloc.Call(ctx, uint64(len(request)))
+	if err != nil {
+		return nil, err
+	}
+	ptr := uint32(res[0])
+	if !i.mod.Memory().Write(ptr, request) {
+		return nil, errors.New("rpc: wasm request out of memory range")
+	}
+	res, err = i.handle.Call(ctx, uint64(ptr), uint64(len(request)))
+	if err != nil {
+		return nil, err
+	}
+	data, ok := i.mod.Memory().Read(uint32(res[0]>>32), uint32(res[0]))
+	if !ok {
+		return nil, errors.New("rpc: wasm response out of memory range")
+	}
+	// Memory is reused by the next call.
+	return append([]byte(nil), data...), nil
+}
+
+func (i *wazeroInstance) Close(ctx context.Context) error {
+	return i.mod.Close(ctx)
+}
@@ -0,0 +1,423 @@
+// Copyright 2009 The Go Authors. All rights reserved.
+// Copyright 2012 The Gorilla Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build websocket
+// +build websocket
+
+package websocket
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gorilla/rpc/v2"
+	"github.com/gorilla/rpc/v2/json2"
+	ws "github.com/gorilla/websocket"
+)
+
+// DefaultReconnectWait is the time waited between reconnection attempts.
+const DefaultReconnectWait = time.Second
+
+var (
+	// ErrClosed is returned by calls on a closed Client.
+	ErrClosed = errors.New("rpc: websocket client is closed")
+	// ErrConnectionLost is returned by calls in flight when the connection
+	// is lost. The calls may or may not have been processed by the server.
+	ErrConnectionLost = errors.New("rpc: websocket connection lost")
+)
+
+// ----------------------------------------------------------------------------
+// Messages
+// ----------------------------------------------------------------------------
+
+// message is any JSON-RPC 2.0 message sent over the connection: a request,
+// a notification or a response.
+type message struct {
+	Version string           `json:"jsonrpc"`
+	Method  string           `json:"method,omitempty"`
+	Params  interface{}      `json:"params,omitempty"`
+	Result  *json.RawMessage `json:"result,omitempty"`
+	Error   *json.RawMessage `json:"error,omitempty"`
+	Id      *json.RawMessage `json:"id,omitempty"`
+}
+
+// inMessage is a message received from the other side.
+type inMessage struct {
+	Method string           `json:"method"`
+	Params *json.RawMessage `json:"params"`
+	Result *json.RawMessage `json:"result"`
+	Error  *json.RawMessage `json:"error"`
+	Id     *json.RawMessage `json:"id"`
+}
+
+// ----------------------------------------------------------------------------
+// Client
+// ----------------------------------------------------------------------------
+
+// subscription is a call replayed after each reconnection.
+type subscription struct {
+	method string
+	args   interface{}
+}
+
+// Client calls methods of a JSON-RPC 2.0 server over a WebSocket
+// connection. Concurrent calls are multiplexed over the connection and
+// matched to their responses by id.
+//
+// Notifications sent by the server are passed to OnNotification. When the
+// connection is lost, calls in flight fail with ErrConnectionLost and the
+// client reconnects, replaying the calls made with Subscribe.
+type Client struct {
+	// URL of the server, e.g. "ws://localhost:8080/rpc".
+	URL string
+	// Header is sent with the opening handshake.
+	Header http.Header
+	// Dialer opens the connection. If nil, ws.DefaultDialer is used.
+	Dialer *ws.Dialer
+	// ReconnectWait is the time waited between reconnection attempts. If
+	// zero, DefaultReconnectWait is used.
+	ReconnectWait time.Duration
+	// OnNotification is called for each notification sent by the server.
+	OnNotification func(method string, params json.RawMessage)
+	// Server, if set, serves the calls sent by the server to the client,
+	// e.g. to confirm an operation. Its codec for "application/json" is
+	// used.
+	Server *rpc.Server
+
+	mutex         sync.Mutex
+	conn          *ws.Conn
+	connected     chan struct{} // closed when conn is set
+	writeMutex    sync.Mutex
+	nextId        uint64
+	pending       map[uint64]chan *inMessage
+	subscriptions []subscription
+	closed        bool
+}
+
+// Dial returns a Client connected to the server at url.
+func Dial(url string) (*Client, error) {
+	c := &Client{URL: url}
+	if err := c.Connect(); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// Connect opens the connection to the server and starts receiving
+// messages. Clients created with Dial are already connected.
+func (c *Client) Connect() error {
+	conn, err := c.dial()
+	if err != nil {
+		return err
+	}
+	c.mutex.Lock()
+	c.setConn(conn)
+	c.mutex.Unlock()
+	go c.receive(conn)
+	return nil
+}
+
+func (c *Client) dial() (*ws.Conn, error) {
+	dialer := c.Dialer
+	if dialer == nil {
+		dialer = ws.DefaultDialer
+	}
+	conn, _, err := dialer.Dial(c.URL, c.Header)
+	return conn, err
+}
+
+// setConn sets the current connection. The mutex must be held.
+func (c *Client) setConn(conn *ws.Conn) {
+	c.conn = conn
+	if c.connected == nil {
+		c.connected = make(chan struct{})
+	}
+	if conn != nil {
+		close(c.connected)
+	}
+}
+
+// Call calls the method with args and decodes the result into reply.
+func (c *Client) Call(ctx context.Context, method string, args, reply interface{}) error {
+	res, err := c.roundTrip(ctx, method, args)
+	if err != nil {
+		return err
+	}
+	return decodeResponse(res, reply)
+}
+
+// Subscribe calls the method like Call, and calls it again each time the
+// client reconnects, so server-side subscriptions survive connection loss.
+func (c *Client) Subscribe(ctx context.Context, method string, args, reply interface{}) error {
+	if err := c.Call(ctx, method, args, reply); err != nil {
+		return err
+	}
+	c.mutex.Lock()
+	c.subscriptions = append(c.subscriptions, subscription{method, args})
+	c.mutex.Unlock()
+	return nil
+}
+
+// Notify sends a notification: a call without response.
+func (c *Client) Notify(method string, args interface{}) error {
+	return c.write(context.Background(), &message{Version: json2.Version, Method: method, Params: args})
+}
+
+// Close closes the connection. Calls in flight fail with ErrClosed.
+func (c *Client) Close() error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if c.closed {
+		return nil
+	}
+	c.closed = true
+	c.failPending()
+	if c.conn != nil {
+		return c.conn.Close()
+	}
+	if c.connected != nil {
+		// Wake up the writers waiting for a reconnection.
+		close(c.connected)
+	}
+	return nil
+}
+
+// roundTrip sends a request and waits for its response.
+func (c *Client) roundTrip(ctx context.Context, method string, args interface{}) (*inMessage, error) {
+	c.mutex.Lock()
+	if c.closed {
+		c.mutex.Unlock()
+		return nil, ErrClosed
+	}
+	if c.pending == nil {
+		c.pending = make(map[uint64]chan *inMessage)
+	}
+	c.nextId++
+	id := c.nextId
+	ch := make(chan *inMessage, 1)
+	c.pending[id] = ch
+	c.mutex.Unlock()
+
+	rawId := json.RawMessage(formatId(id))
+	err := c.write(ctx, &message{Version: json2.Version, Method: method, Params: args, Id: &rawId})
+	if err != nil {
+		c.forget(id)
+		return nil, err
+	}
+	select {
+	case res := <-ch:
+		if res == nil {
+			return nil, c.lostError()
+		}
+		return res, nil
+	case <-ctx.Done():
+		c.forget(id)
+		return nil, ctx.Err()
+	}
+}
+
+// write sends a message on the current connection.
+func (c *Client) write(ctx context.Context, m *message) error {
+	data, err := json.Marshal(m)
+	if err != nil {
+		return err
+	}
+	return c.writeRaw(ctx, data)
+}
+
+// writeRaw sends an encoded message, waiting for the client to reconnect
+// if needed, or for the context to be done.
+func (c *Client) writeRaw(ctx context.Context, data []byte) error {
+	c.mutex.Lock()
+	if c.closed {
+		c.mutex.Unlock()
+		return ErrClosed
+	}
+	conn, connected := c.conn, c.connected
+	c.mutex.Unlock()
+	if conn == nil {
+		if connected == nil {
+			return ErrConnectionLost
+		}
+		select {
+		case <-connected:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+		return c.writeRaw(ctx, data)
+	}
+	c.writeMutex.Lock()
+	defer c.writeMutex.Unlock()
+	return conn.WriteMessage(ws.TextMessage, data)
+}
+
+func (c *Client) forget(id uint64) {
+	c.mutex.Lock()
+	delete(c.pending, id)
+	c.mutex.Unlock()
+}
+
+func (c *Client) lostError() error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if c.closed {
+		return ErrClosed
+	}
+	return ErrConnectionLost
+}
+
+// failPending unblocks all calls in flight. The mutex must be held.
+func (c *Client) failPending() {
+	for id, ch := range c.pending {
+		close(ch)
+		delete(c.pending, id)
+	}
+}
+
+// receive reads messages until the connection fails, then reconnects.
+func (c *Client) receive(conn *ws.Conn) {
+	for {
+		_, data, err := conn.ReadMessage()
+		if err != nil {
+			break
+		}
+		var m inMessage
+		if err := json.Unmarshal(data, &m); err != nil {
+			continue
+		}
+		c.dispatch(&m, data)
+	}
+	conn.Close()
+	c.mutex.Lock()
+	if c.closed {
+		c.mutex.Unlock()
+		return
+	}
+	c.failPending()
+	c.connected = make(chan struct{})
+	c.conn = nil
+	c.mutex.Unlock()
+	c.reconnect()
+}
+
+// dispatch handles a received message.
+func (c *Client) dispatch(m *inMessage, data []byte) {
+	if m.Method != "" {
+		if m.Id != nil {
+			go c.serve(m, data)
+		} else if c.OnNotification != nil {
+			var params json.RawMessage
+			if m.Params != nil {
+				params = *m.Params
+			}
+			c.OnNotification(m.Method, params)
+		}
+		return
+	}
+	id, ok := parseId(m.Id)
+	if !ok {
+		return
+	}
+	c.mutex.Lock()
+	ch := c.pending[id]
+	delete(c.pending, id)
+	c.mutex.Unlock()
+	if ch != nil {
+		ch <- m
+	}
+}
+
+// serve serves a call sent by the server.
+func (c *Client) serve(m *inMessage, data []byte) {
+	var res []byte
+	if c.Server != nil {
+		r, err := http.NewRequest("POST", c.URL, nil)
+		if err != nil {
+			return
+		}
+		r.Header.Set("Content-Type", "application/json")
+		res = c.Server.ServeMessage(r, data)
+	} else {
+		var err error
+		res, err = json.Marshal(map[string]interface{}{
+			"jsonrpc": json2.Version,
+			"error":   &json2.Error{Code: json2.E_NO_METHOD, Message: "rpc: client doesn't serve calls"},
+			"id":      m.Id,
+		})
+		if err != nil {
+			return
+		}
+	}
+	if len(res) > 0 {
+		c.writeRaw(context.Background(), res)
+	}
+}
+
+// reconnect dials until it succeeds or the client is closed, then replays
+// the subscriptions.
+func (c *Client) reconnect() {
+	wait := c.ReconnectWait
+	if wait <= 0 {
+		wait = DefaultReconnectWait
+	}
+	for {
+		conn, err := c.dial()
+		c.mutex.Lock()
+		if c.closed {
+			c.mutex.Unlock()
+			if conn != nil {
+				conn.Close()
+			}
+			return
+		}
+		if err == nil {
+			c.setConn(conn)
+			subscriptions := append([]subscription(nil), c.subscriptions...)
+			c.mutex.Unlock()
+			go c.receive(conn)
+			for _, s := range subscriptions {
+				go c.roundTrip(context.Background(), s.method, s.args)
+			}
+			return
+		}
+		c.mutex.Unlock()
+		time.Sleep(wait)
+	}
+}
+
+// decodeResponse decodes the result of a response into reply.
+func decodeResponse(m *inMessage, reply interface{}) error {
+	if m.Error != nil && string(*m.Error) != "null" {
+		jsonErr := &json2.Error{}
+		if err := json.Unmarshal(*m.Error, jsonErr); err != nil {
+			return &json2.Error{Code: json2.E_SERVER, Message: string(*m.Error)}
+		}
+		return jsonErr
+	}
+	if m.Result == nil {
+		return json2.ErrNullResult
+	}
+	return json.Unmarshal(*m.Result, reply)
+}
+
+func formatId(id uint64) []byte {
+	b, _ := json.Marshal(id)
+	return b
+}
+
+func parseId(raw *json.RawMessage) (uint64, bool) {
+	if raw == nil {
+		return 0, false
+	}
+	var id uint64
+	if err := json.Unmarshal(*raw, &id); err != nil {
+		return 0, false
+	}
+	return id, true
+}
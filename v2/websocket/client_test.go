@@ -0,0 +1,172 @@
+// Copyright 2009 The Go Authors. All rights reserved.
+// Copyright 2012 The Gorilla Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build websocket
+// +build websocket
+
+package websocket
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	ws "github.com/gorilla/websocket"
+)
+
+type Service1Request struct {
+	A int
+	B int
+}
+
+type Service1Response struct {
+	Result int
+}
+
+// testServer answers Service1.Multiply, notifies "Tick" after each
+// Service1.Subscribe and drops the connection on Service1.Drop.
+type testServer struct {
+	subscribes int32
+}
+
+func (s *testServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	conn, err := (&ws.Upgrader{}).Upgrade(w, r, nil)
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+	for {
+		var req struct {
+			Method string           `json:"method"`
+			Params Service1Request  `json:"params"`
+			Id     *json.RawMessage `json:"id"`
+		}
+		if err := conn.ReadJSON(&req); err != nil {
+			return
+		}
+		switch req.Method {
+		case "Service1.Drop":
+			return
+		case "Service1.Subscribe":
+			atomic.AddInt32(&s.subscribes, 1)
+			conn.WriteJSON(map[string]interface{}{"jsonrpc": "2.0", "result": true, "id": req.Id})
+			conn.WriteJSON(map[string]interface{}{"jsonrpc": "2.0", "method": "Tick", "params": 1})
+		default:
+			res := Service1Response{req.Params.A * req.Params.B}
+			conn.WriteJSON(map[string]interface{}{"jsonrpc": "2.0", "result": res, "id": req.Id})
+		}
+	}
+}
+
+func dialTestServer(t *testing.T) (*Client, *testServer, func()) {
+	s := &testServer{}
+	ts := httptest.NewServer(s)
+	c, err := Dial("ws" + strings.TrimPrefix(ts.URL, "http"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.ReconnectWait = 10 * time.Millisecond
+	return c, s, func() {
+		c.Close()
+		ts.Close()
+	}
+}
+
+func TestClientCall(t *testing.T) {
+	c, _, done := dialTestServer(t)
+	defer done()
+
+	results := make(chan int, 10)
+	for i := 1; i <= 10; i++ {
+		go func(i int) {
+			var res Service1Response
+			if err := c.Call(context.Background(), "Service1.Multiply", &Service1Request{i, i}, &res); err != nil {
+				t.Error(err)
+			}
+			if res.Result != i*i {
+				t.Errorf("Wrong response for %d: %d", i, res.Result)
+			}
+			results <- res.Result
+		}(i)
+	}
+	for i := 0; i < 10; i++ {
+		<-results
+	}
+}
+
+func TestClientReconnect(t *testing.T) {
+	c, s, done := dialTestServer(t)
+	defer done()
+
+	ticks := make(chan string, 10)
+	c.OnNotification = func(method string, params json.RawMessage) {
+		ticks <- method
+	}
+	var ok bool
+	if err := c.Subscribe(context.Background(), "Service1.Subscribe", &Service1Request{}, &ok); err != nil {
+		t.Fatal(err)
+	}
+	if method := <-ticks; method != "Tick" {
+		t.Errorf("Expected Tick notification, got %s", method)
+	}
+
+	if err := c.Call(context.Background(), "Service1.Drop", &Service1Request{}, &ok); err != ErrConnectionLost {
+		t.Errorf("Expected ErrConnectionLost, got %v", err)
+	}
+	// The subscription is replayed after reconnecting.
+	select {
+	case <-ticks:
+	case <-time.After(time.Second):
+		t.Fatal("Expected Tick notification after reconnecting")
+	}
+	if n := atomic.LoadInt32(&s.subscribes); n != 2 {
+		t.Errorf("Expected 2 subscriptions, got %d", n)
+	}
+
+	var res Service1Response
+	if err := c.Call(context.Background(), "Service1.Multiply", &Service1Request{3, 4}, &res); err != nil {
+		t.Fatal(err)
+	}
+	if res.Result != 12 {
+		t.Errorf("Wrong response: %d", res.Result)
+	}
+}
+
+func TestClientClosed(t *testing.T) {
+	c, _, done := dialTestServer(t)
+	done()
+	var res Service1Response
+	if err := c.Call(context.Background(), "Service1.Multiply", &Service1Request{3, 4}, &res); err != ErrClosed {
+		t.Errorf("Expected ErrClosed, got %v", err)
+	}
+}
+
+func TestClientCallDuringReconnect(t *testing.T) {
+	ts := httptest.NewServer(&testServer{})
+	c, err := Dial("ws" + strings.TrimPrefix(ts.URL, "http"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	c.ReconnectWait = 10 * time.Millisecond
+	var ok bool
+	if err := c.Call(context.Background(), "Service1.Drop", &Service1Request{}, &ok); err != ErrConnectionLost {
+		t.Fatalf("Expected ErrConnectionLost, got %v", err)
+	}
+	// The server is gone: calls wait for the reconnection until their
+	// deadline only.
+	ts.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	var res Service1Response
+	if err := c.Call(ctx, "Service1.Multiply", &Service1Request{3, 4}, &res); err != context.DeadlineExceeded {
+		t.Errorf("Expected context.DeadlineExceeded, got %v", err)
+	}
+}
@@ -0,0 +1,45 @@
+// Copyright 2009 The Go Authors. All rights reserved.
+// Copyright 2012 The Gorilla Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+/*
+Package gorilla/rpc/v2/websocket provides JSON-RPC 2.0 over WebSocket
+connections. It's based on gorilla/websocket and only built with the
+"websocket" build tag.
+
+The Client multiplexes concurrent calls over a single connection, matching
+responses to calls by id:
+
+	c, err := websocket.Dial("ws://localhost:8080/rpc")
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+	c.OnNotification = func(method string, params json.RawMessage) {
+		log.Printf("<- %s %s", method, params)
+	}
+	var reply HelloReply
+	err = c.Call(ctx, "HelloService.Say", &HelloArgs{Who: "gorilla"}, &reply)
+
+When the connection is lost the client reconnects, and calls made with
+Subscribe are issued again so that server-side subscriptions are restored.
+
+The Handler serves calls received over WebSocket connections with an
+rpc.Server. Each connection is a session that methods can get with
+rpc.SessionFromContext(r.Context()) to keep per-connection state or to push
+notifications to the client:
+
+	s := rpc.NewServer()
+	s.RegisterCodec(json2.NewCodec(), "application/json")
+	s.RegisterService(new(HelloService), "")
+	http.Handle("/rpc", websocket.NewHandler(s))
+
+Connections are duplex: methods can call the client back with the session
+Call method, and the client serves these calls with its own rpc.Server:
+
+	c.Server = rpc.NewServer()
+	c.Server.RegisterCodec(json2.NewCodec(), "application/json")
+	c.Server.RegisterService(new(ConfirmService), "")
+*/
+package websocket
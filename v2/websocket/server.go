@@ -0,0 +1,175 @@
+// Copyright 2009 The Go Authors. All rights reserved.
+// Copyright 2012 The Gorilla Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build websocket
+// +build websocket
+
+package websocket
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"sync"
+
+	"github.com/gorilla/rpc/v2"
+	"github.com/gorilla/rpc/v2/json2"
+	ws "github.com/gorilla/websocket"
+)
+
+// Handler serves JSON-RPC 2.0 calls received over WebSocket connections
+// using an rpc.Server. Each connection is an rpc.Session, available to the
+// methods through rpc.SessionFromContext(r.Context()).
+//
+// Calls are served concurrently and responses are sent as soon as they are
+// ready, so they may be out of order. Methods can call back the client with
+// the session Call method; the Client serves these calls with its Server.
+type Handler struct {
+	// Server serves the calls.
+	Server *rpc.Server
+	// Upgrader upgrades the HTTP connections.
+	Upgrader ws.Upgrader
+	// ContentType selects the server codec. If empty, "application/json"
+	// is used.
+	ContentType string
+	// OnConnect is called when a session starts.
+	OnConnect func(*rpc.Session)
+	// OnDisconnect is called when a session ends.
+	OnDisconnect func(*rpc.Session)
+	// Heartbeat, if set, pings the clients to detect dead connections.
+	Heartbeat *rpc.Heartbeat
+}
+
+// NewHandler returns a new Handler serving calls with s.
+func NewHandler(s *rpc.Server) *Handler {
+	return &Handler{Server: s}
+}
+
+// ServeHTTP upgrades the connection and serves the calls received on it
+// until it's closed.
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	conn, err := h.Upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		// The upgrader already replied with an error.
+		return
+	}
+	c := &serverConn{conn: conn, pongs: make(chan struct{}, 1)}
+	session := rpc.NewSession("", c)
+	h.Server.AddSession(session)
+	defer c.Close()
+	defer c.calls.Close()
+	if h.OnConnect != nil {
+		h.OnConnect(session)
+	}
+	if h.OnDisconnect != nil {
+		defer h.OnDisconnect(session)
+	}
+	defer session.End()
+	if h.Heartbeat != nil {
+		conn.SetPongHandler(c.pong)
+		go h.Heartbeat.Run(session.Done(), c.ping, func() { c.Close() })
+	}
+
+	// Template for the requests of each message, whose calls are canceled
+	// when the connection is lost.
+	ctx, cancel := context.WithCancel(rpc.NewSessionContext(r.Context(), session))
+	req := r.WithContext(ctx)
+	req.Header = make(http.Header, len(r.Header))
+	for k, v := range r.Header {
+		req.Header[k] = v
+	}
+	contentType := h.ContentType
+	if contentType == "" {
+		contentType = "application/json"
+	}
+	req.Header.Set("Content-Type", contentType)
+
+	var wg sync.WaitGroup
+	defer func() {
+		// Unblock the handlers waiting for the client before waiting
+		// for them.
+		c.calls.Close()
+		cancel()
+		wg.Wait()
+	}()
+	for {
+		_, data, err := conn.ReadMessage()
+		if err != nil {
+			return
+		}
+		if c.calls.Deliver(data) {
+			continue
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if res := h.Server.ServeMessage(req, data); len(res) > 0 {
+				c.write(res)
+			}
+		}()
+	}
+}
+
+// serverConn is the rpc.SessionConn of a WebSocket connection.
+type serverConn struct {
+	conn  *ws.Conn
+	mutex sync.Mutex
+	calls json2.PendingCalls
+	pongs chan struct{}
+}
+
+func (c *serverConn) write(data []byte) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.conn.WriteMessage(ws.TextMessage, data)
+}
+
+// ping sends a ping and waits for the pong.
+func (c *serverConn) ping(ctx context.Context) error {
+	select {
+	case <-c.pongs:
+	default:
+	}
+	deadline, _ := ctx.Deadline()
+	if err := c.conn.WriteControl(ws.PingMessage, nil, deadline); err != nil {
+		return err
+	}
+	select {
+	case <-c.pongs:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (c *serverConn) pong(string) error {
+	select {
+	case c.pongs <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+// Notify sends a JSON-RPC 2.0 notification.
+func (c *serverConn) Notify(method string, params interface{}) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.conn.WriteJSON(&message{Version: json2.Version, Method: method, Params: params})
+}
+
+// Call calls a method exposed by the client.
+func (c *serverConn) Call(ctx context.Context, method string, args, reply interface{}) error {
+	return c.calls.Call(ctx, c.write, method, args, reply)
+}
+
+// Close closes the connection.
+func (c *serverConn) Close() error {
+	return c.conn.Close()
+}
+
+// RemoteAddr returns the address of the client, see rpc.RemoteAddrConn.
+func (c *serverConn) RemoteAddr() net.Addr {
+	return c.conn.RemoteAddr()
+}
@@ -0,0 +1,210 @@
+// Copyright 2009 The Go Authors. All rights reserved.
+// Copyright 2012 The Gorilla Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build websocket
+// +build websocket
+
+package websocket
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/rpc/v2"
+	"github.com/gorilla/rpc/v2/json2"
+	ws "github.com/gorilla/websocket"
+)
+
+type Service1 struct {
+}
+
+func (t *Service1) Multiply(r *http.Request, req *Service1Request, res *Service1Response) error {
+	session := rpc.SessionFromContext(r.Context())
+	if session == nil {
+		return errors.New("no session")
+	}
+	res.Result = req.A * req.B
+	return session.Notify("Service1.Multiplied", res.Result)
+}
+
+func TestHandler(t *testing.T) {
+	s := rpc.NewServer()
+	s.RegisterCodec(json2.NewCodec(), "application/json")
+	s.RegisterService(new(Service1), "")
+	ts := httptest.NewServer(NewHandler(s))
+	defer ts.Close()
+
+	c, err := Dial("ws" + strings.TrimPrefix(ts.URL, "http"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	notified := make(chan int, 1)
+	c.OnNotification = func(method string, params json.RawMessage) {
+		var n int
+		json.Unmarshal(params, &n)
+		notified <- n
+	}
+
+	var res Service1Response
+	if err := c.Call(context.Background(), "Service1.Multiply", &Service1Request{4, 2}, &res); err != nil {
+		t.Fatal(err)
+	}
+	if res.Result != 8 {
+		t.Errorf("Wrong response: %d", res.Result)
+	}
+	if n := <-notified; n != 8 {
+		t.Errorf("Wrong notification: %d", n)
+	}
+}
+
+type ConfirmRequest struct {
+	Question string
+}
+
+type ConfirmResponse struct {
+	Ok bool
+}
+
+// Client1 is served by the websocket client.
+type Client1 struct {
+}
+
+func (c *Client1) Confirm(r *http.Request, req *ConfirmRequest, res *ConfirmResponse) error {
+	res.Ok = req.Question == "Multiply?"
+	return nil
+}
+
+// Service2 asks the client for confirmation before answering.
+type Service2 struct {
+}
+
+func (t *Service2) Multiply(r *http.Request, req *Service1Request, res *Service1Response) error {
+	var confirm ConfirmResponse
+	session := rpc.SessionFromContext(r.Context())
+	if err := session.Call(r.Context(), "Client1.Confirm", &ConfirmRequest{"Multiply?"}, &confirm); err != nil {
+		return err
+	}
+	if !confirm.Ok {
+		return errors.New("not confirmed")
+	}
+	res.Result = req.A * req.B
+	return nil
+}
+
+func TestServerCalls(t *testing.T) {
+	s := rpc.NewServer()
+	s.RegisterCodec(json2.NewCodec(), "application/json")
+	s.RegisterService(new(Service2), "")
+	ts := httptest.NewServer(NewHandler(s))
+	defer ts.Close()
+
+	c, err := Dial("ws" + strings.TrimPrefix(ts.URL, "http"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	// Without a client server, the call back fails.
+	var res Service1Response
+	if err := c.Call(context.Background(), "Service2.Multiply", &Service1Request{4, 2}, &res); err == nil {
+		t.Fatal("Expected error without client server")
+	}
+
+	c.Server = rpc.NewServer()
+	c.Server.RegisterCodec(json2.NewCodec(), "application/json")
+	c.Server.RegisterService(new(Client1), "")
+	if err := c.Call(context.Background(), "Service2.Multiply", &Service1Request{4, 2}, &res); err != nil {
+		t.Fatal(err)
+	}
+	if res.Result != 8 {
+		t.Errorf("Wrong response: %d", res.Result)
+	}
+}
+
+func TestHeartbeat(t *testing.T) {
+	s := rpc.NewServer()
+	s.RegisterCodec(json2.NewCodec(), "application/json")
+	h := NewHandler(s)
+	h.Heartbeat = &rpc.Heartbeat{Interval: 10 * time.Millisecond, MaxMissed: 2}
+	ended := make(chan struct{})
+	h.OnConnect = func(session *rpc.Session) {
+		session.OnEnd(func() { close(ended) })
+	}
+	ts := httptest.NewServer(h)
+	defer ts.Close()
+
+	// A client answering pings stays connected.
+	c, err := Dial("ws" + strings.TrimPrefix(ts.URL, "http"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-ended:
+		t.Fatal("Expected live session to stay connected")
+	case <-time.After(50 * time.Millisecond):
+	}
+	c.Close()
+	<-ended
+
+	// A client ignoring pings is disconnected.
+	ended = make(chan struct{})
+	conn, _, err := ws.DefaultDialer.Dial("ws"+strings.TrimPrefix(ts.URL, "http"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	conn.SetPingHandler(func(string) error { return nil })
+	go func() {
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}()
+	select {
+	case <-ended:
+	case <-time.After(time.Second):
+		t.Fatal("Expected dead session to be ended")
+	}
+}
+
+func TestDisconnectDuringCall(t *testing.T) {
+	s := rpc.NewServer()
+	s.RegisterCodec(json2.NewCodec(), "application/json")
+	s.RegisterService(new(Service2), "")
+	h := NewHandler(s)
+	done := make(chan struct{})
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		h.ServeHTTP(w, r)
+		close(done)
+	}))
+	defer ts.Close()
+
+	conn, _, err := ws.DefaultDialer.Dial("ws"+strings.TrimPrefix(ts.URL, "http"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	buf, _ := json2.EncodeClientRequest("Service2.Multiply", &Service1Request{4, 2})
+	if err := conn.WriteMessage(ws.TextMessage, buf); err != nil {
+		t.Fatal(err)
+	}
+	// The client gets the call back and disconnects without answering.
+	if _, _, err := conn.ReadMessage(); err != nil {
+		t.Fatal(err)
+	}
+	conn.Close()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Expected the connection to be served until the disconnection")
+	}
+}